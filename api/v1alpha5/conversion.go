@@ -19,7 +19,6 @@ package v1alpha5
 import (
 	"github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	apiconversion "k8s.io/apimachinery/pkg/conversion"
-	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 )
 
@@ -35,10 +34,27 @@ func (src *Metal3Cluster) ConvertTo(dstRaw conversion.Hub) error {
 	}
 	// Manually restore data.
 	restored := &v1beta1.Metal3Cluster{}
-	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
-		return err
+	if !unmarshalData(src, restored, dst) {
+		return nil
 	}
 	dst.Status.Conditions = restored.Status.Conditions
+	dst.Spec.AllowBootstrapless = restored.Spec.AllowBootstrapless
+	dst.Spec.HostSelectionPolicy = restored.Spec.HostSelectionPolicy
+	dst.Spec.ControlPlaneEndpointFromPool = restored.Spec.ControlPlaneEndpointFromPool
+	dst.Spec.ProvidedDataValidation = restored.Spec.ProvidedDataValidation
+	dst.Spec.FailureDomainLabel = restored.Spec.FailureDomainLabel
+	dst.Spec.HostClusterKubeconfigSecret = restored.Spec.HostClusterKubeconfigSecret
+	dst.Spec.EnforceDataTemplates = restored.Spec.EnforceDataTemplates
+	// cloudProviderEnabled is restored unless noCloudProvider was changed in
+	// v1alpha5, it is then defaulted from noCloudProvider.
+	if src.Spec.NoCloudProvider == !restored.Spec.IsCloudProviderEnabled() {
+		dst.Spec.NoCloudProvider = restored.Spec.NoCloudProvider
+		dst.Spec.CloudProviderEnabled = restored.Spec.CloudProviderEnabled
+	}
+	dst.Status.ReadyMachines = restored.Status.ReadyMachines
+	dst.Status.ProvisioningMachines = restored.Status.ProvisioningMachines
+	dst.Status.FailedMachines = restored.Status.FailedMachines
+	dst.Status.FailureDomains = restored.Status.FailureDomains
 	return nil
 }
 
@@ -48,17 +64,27 @@ func (dst *Metal3Cluster) ConvertFrom(srcRaw conversion.Hub) error {
 		return err
 	}
 	// Preserve Hub data on down-conversion except for metadata
-	if err := utilconversion.MarshalData(src, dst); err != nil {
+	if err := marshalData(src, dst); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Status.Conditions was introduced in v1beta1, thus requiring a custom conversion function; the values is going to be preserved in an annotation thus allowing roundtrip without losing information.
+// Status.Conditions, Status.ReadyMachines, Status.ProvisioningMachines, Status.FailedMachines and Status.FailureDomains were introduced in v1beta1, thus requiring a custom conversion function; the values is going to be preserved in an annotation thus allowing roundtrip without losing information.
 func Convert_v1beta1_Metal3ClusterStatus_To_v1alpha5_Metal3ClusterStatus(in *v1beta1.Metal3ClusterStatus, out *Metal3ClusterStatus, s apiconversion.Scope) error {
 	return autoConvert_v1beta1_Metal3ClusterStatus_To_v1alpha5_Metal3ClusterStatus(in, out, s)
 }
 
+// Spec.AllowBootstrapless, Spec.HostSelectionPolicy, Spec.ControlPlaneEndpointFromPool, Spec.ProvidedDataValidation, Spec.FailureDomainLabel, Spec.HostClusterKubeconfigSecret, Spec.EnforceDataTemplates and Spec.CloudProviderEnabled were introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+// Spec.NoCloudProvider is set from Spec.CloudProviderEnabled when set.
+func Convert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(in *v1beta1.Metal3ClusterSpec, out *Metal3ClusterSpec, s apiconversion.Scope) error {
+	if err := autoConvert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(in, out, s); err != nil {
+		return err
+	}
+	out.NoCloudProvider = !in.IsCloudProviderEnabled()
+	return nil
+}
+
 func (src *Metal3ClusterList) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*v1beta1.Metal3ClusterList)
 	return Convert_v1alpha5_Metal3ClusterList_To_v1beta1_Metal3ClusterList(src, dst, nil)
@@ -76,10 +102,28 @@ func (src *Metal3Machine) ConvertTo(dstRaw conversion.Hub) error {
 	}
 	// Manually restore data.
 	restored := &v1beta1.Metal3Machine{}
-	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
-		return err
+	if !unmarshalData(src, restored, dst) {
+		return nil
 	}
 	dst.Status.Conditions = restored.Status.Conditions
+	dst.Spec.NodeReuseGroup = restored.Spec.NodeReuseGroup
+	dst.Spec.Bootstrapless = restored.Spec.Bootstrapless
+	dst.Spec.Metal3DrainTimeout = restored.Spec.Metal3DrainTimeout
+	dst.Spec.HostNamespace = restored.Spec.HostNamespace
+	dst.Spec.Image.UserDataFormat = restored.Spec.Image.UserDataFormat
+	dst.Spec.CustomDeploy = restored.Spec.CustomDeploy
+	dst.Spec.HostTolerations = restored.Spec.HostTolerations
+	dst.Spec.RootDeviceHints = restored.Spec.RootDeviceHints
+	dst.Spec.PowerState = restored.Spec.PowerState
+	dst.Spec.UserDataAppend = restored.Spec.UserDataAppend
+	dst.Spec.PowerManagementPolicy = restored.Spec.PowerManagementPolicy
+	dst.Spec.NodeMetadata = restored.Spec.NodeMetadata
+	dst.Spec.FirmwareSettings = restored.Spec.FirmwareSettings
+	dst.Spec.HostSelector.MatchHardware = restored.Spec.HostSelector.MatchHardware
+	dst.Spec.HostSelector.Preferences = restored.Spec.HostSelector.Preferences
+	dst.Status.RenderedHost = restored.Status.RenderedHost
+	dst.Status.EstimatedReadyTime = restored.Status.EstimatedReadyTime
+	dst.Status.FailureDomain = restored.Status.FailureDomain
 	return nil
 }
 
@@ -89,17 +133,27 @@ func (dst *Metal3Machine) ConvertFrom(srcRaw conversion.Hub) error {
 		return err
 	}
 	// Preserve Hub data on down-conversion except for metadata
-	if err := utilconversion.MarshalData(src, dst); err != nil {
+	if err := marshalData(src, dst); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Status.Conditions was introduced in v1beta1, thus requiring a custom conversion function; the values is going to be preserved in an annotation thus allowing roundtrip without losing information.
+// Status.Conditions, Status.RenderedHost, Status.EstimatedReadyTime and Status.FailureDomain were introduced in v1beta1, thus requiring a custom conversion function; the values is going to be preserved in an annotation thus allowing roundtrip without losing information.
 func Convert_v1beta1_Metal3MachineStatus_To_v1alpha5_Metal3MachineStatus(in *v1beta1.Metal3MachineStatus, out *Metal3MachineStatus, s apiconversion.Scope) error {
 	return autoConvert_v1beta1_Metal3MachineStatus_To_v1alpha5_Metal3MachineStatus(in, out, s)
 }
 
+// Spec.NodeReuseGroup, Spec.Bootstrapless, Spec.Metal3DrainTimeout, Spec.HostNamespace, Spec.CustomDeploy, Spec.HostTolerations, Spec.RootDeviceHints, Spec.PowerState, Spec.UserDataAppend, Spec.PowerManagementPolicy, Spec.NodeMetadata and Spec.FirmwareSettings were introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in *v1beta1.Metal3MachineSpec, out *Metal3MachineSpec, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in, out, s)
+}
+
+// HostSelector.MatchHardware and HostSelector.Preferences were introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector(in *v1beta1.HostSelector, out *HostSelector, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_HostSelector_To_v1alpha5_HostSelector(in, out, s)
+}
+
 func (src *Metal3MachineList) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*v1beta1.Metal3MachineList)
 	return Convert_v1alpha5_Metal3MachineList_To_v1beta1_Metal3MachineList(src, dst, nil)
@@ -115,7 +169,32 @@ func (src *Metal3MachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	if err := Convert_v1alpha5_Metal3MachineTemplate_To_v1beta1_Metal3MachineTemplate(src, dst, nil); err != nil {
 		return err
 	}
-
+	// Manually restore data.
+	restored := &v1beta1.Metal3MachineTemplate{}
+	if !unmarshalData(src, restored, dst) {
+		return nil
+	}
+	dst.Spec.NodeReuseScope = restored.Spec.NodeReuseScope
+	dst.Spec.NodeReuseLabelKeys = restored.Spec.NodeReuseLabelKeys
+	dst.Spec.NodeReuseGracePeriod = restored.Spec.NodeReuseGracePeriod
+	dst.Spec.UpdateAutomatedCleaningMode = restored.Spec.UpdateAutomatedCleaningMode
+	dst.Spec.SpreadTopologyKey = restored.Spec.SpreadTopologyKey
+	dst.Spec.Template.Spec.NodeReuseGroup = restored.Spec.Template.Spec.NodeReuseGroup
+	dst.Spec.Template.Spec.Bootstrapless = restored.Spec.Template.Spec.Bootstrapless
+	dst.Spec.Template.Spec.Metal3DrainTimeout = restored.Spec.Template.Spec.Metal3DrainTimeout
+	dst.Spec.Template.Spec.HostNamespace = restored.Spec.Template.Spec.HostNamespace
+	dst.Spec.Template.Spec.Image.UserDataFormat = restored.Spec.Template.Spec.Image.UserDataFormat
+	dst.Spec.Template.Spec.CustomDeploy = restored.Spec.Template.Spec.CustomDeploy
+	dst.Spec.Template.Spec.HostTolerations = restored.Spec.Template.Spec.HostTolerations
+	dst.Spec.Template.Spec.RootDeviceHints = restored.Spec.Template.Spec.RootDeviceHints
+	dst.Spec.Template.Spec.PowerState = restored.Spec.Template.Spec.PowerState
+	dst.Spec.Template.Spec.UserDataAppend = restored.Spec.Template.Spec.UserDataAppend
+	dst.Spec.Template.Spec.PowerManagementPolicy = restored.Spec.Template.Spec.PowerManagementPolicy
+	dst.Spec.Template.Spec.NodeMetadata = restored.Spec.Template.Spec.NodeMetadata
+	dst.Spec.Template.Spec.FirmwareSettings = restored.Spec.Template.Spec.FirmwareSettings
+	dst.Spec.Template.Spec.HostSelector.MatchHardware = restored.Spec.Template.Spec.HostSelector.MatchHardware
+	dst.Spec.Template.Spec.HostSelector.Preferences = restored.Spec.Template.Spec.HostSelector.Preferences
+	dst.Status = restored.Status
 	return nil
 }
 
@@ -124,8 +203,18 @@ func (dst *Metal3MachineTemplate) ConvertFrom(srcRaw conversion.Hub) error {
 	if err := Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(src, dst, nil); err != nil {
 		return err
 	}
+	// Preserve Hub data on down-conversion except for metadata
+	return marshalData(src, dst)
+}
 
-	return nil
+// Spec.NodeReuseScope, Spec.NodeReuseLabelKeys, Spec.NodeReuseGracePeriod, Spec.UpdateAutomatedCleaningMode and Spec.SpreadTopologyKey were introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(in *v1beta1.Metal3MachineTemplateSpec, out *Metal3MachineTemplateSpec, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(in, out, s)
+}
+
+// Status was introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(in *v1beta1.Metal3MachineTemplate, out *Metal3MachineTemplate, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(in, out, s)
 }
 
 func (src *Metal3MachineTemplateList) ConvertTo(dstRaw conversion.Hub) error {
@@ -143,7 +232,14 @@ func (src *Metal3Data) ConvertTo(dstRaw conversion.Hub) error {
 	if err := Convert_v1alpha5_Metal3Data_To_v1beta1_Metal3Data(src, dst, nil); err != nil {
 		return err
 	}
-
+	// Manually restore data.
+	restored := &v1beta1.Metal3Data{}
+	if !unmarshalData(src, restored, dst) {
+		return nil
+	}
+	dst.Status.HostRef = restored.Status.HostRef
+	dst.Status.RenderedTemplateGeneration = restored.Status.RenderedTemplateGeneration
+	dst.Status.Conditions = restored.Status.Conditions
 	return nil
 }
 
@@ -152,8 +248,13 @@ func (dst *Metal3Data) ConvertFrom(srcRaw conversion.Hub) error {
 	if err := Convert_v1beta1_Metal3Data_To_v1alpha5_Metal3Data(src, dst, nil); err != nil {
 		return err
 	}
+	// Preserve Hub data on down-conversion except for metadata
+	return marshalData(src, dst)
+}
 
-	return nil
+// Status.HostRef, Status.RenderedTemplateGeneration and Status.Conditions were introduced in v1beta1, thus requiring a custom conversion function; the values are going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(in *v1beta1.Metal3DataStatus, out *Metal3DataStatus, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(in, out, s)
 }
 
 func (src *Metal3DataList) ConvertTo(dstRaw conversion.Hub) error {
@@ -173,59 +274,192 @@ func (src *Metal3DataTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	}
 
 	restored := &v1beta1.Metal3DataTemplate{}
-	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
-		return err
+	if !unmarshalData(src, restored, dst) {
+		return nil
 	}
+	dst.Status.LastIndexes = restored.Status.LastIndexes
+	dst.Status.IndexSummary = restored.Status.IndexSummary
+	dst.Status.Conditions = restored.Status.Conditions
+	dst.Spec.RerenderOnTemplateChange = restored.Spec.RerenderOnTemplateChange
 
+	// The lists may have been modified in v1alpha5 since the data was
+	// preserved, the fields are only restored for the items in both.
 	if dst.Spec.MetaData != nil && restored.Spec.MetaData != nil {
-		for k := range dst.Spec.MetaData.IPAddressesFromPool {
-			dst.Spec.MetaData.IPAddressesFromPool[k].APIGroup = restored.Spec.MetaData.IPAddressesFromPool[k].APIGroup
-			dst.Spec.MetaData.IPAddressesFromPool[k].Kind = restored.Spec.MetaData.IPAddressesFromPool[k].Kind
+		dst.Spec.MetaData.FromTemplates = restored.Spec.MetaData.FromTemplates
+		restoreFromPools(dst.Spec.MetaData.IPAddressesFromPool, restored.Spec.MetaData.IPAddressesFromPool)
+		restoreFromPools(dst.Spec.MetaData.GatewaysFromPool, restored.Spec.MetaData.GatewaysFromPool)
+		restoreFromPools(dst.Spec.MetaData.PrefixesFromPool, restored.Spec.MetaData.PrefixesFromPool)
+		restoreFromPools(dst.Spec.MetaData.DNSServersFromPool, restored.Spec.MetaData.DNSServersFromPool)
+	}
+	if dst.Spec.NetworkData != nil && restored.Spec.NetworkData != nil {
+		dst.Spec.NetworkData.Format = restored.Spec.NetworkData.Format
+		links, restoredLinks := &dst.Spec.NetworkData.Links, restored.Spec.NetworkData.Links
+		for k := range links.Ethernets {
+			if k < len(restoredLinks.Ethernets) {
+				links.Ethernets[k].VendorExtensions = restoredLinks.Ethernets[k].VendorExtensions
+				links.Ethernets[k].DefaultRoutePriority = restoredLinks.Ethernets[k].DefaultRoutePriority
+			}
 		}
-		for k := range dst.Spec.MetaData.GatewaysFromPool {
-			dst.Spec.MetaData.GatewaysFromPool[k].APIGroup = restored.Spec.MetaData.GatewaysFromPool[k].APIGroup
-			dst.Spec.MetaData.GatewaysFromPool[k].Kind = restored.Spec.MetaData.GatewaysFromPool[k].Kind
+		for k := range links.Bonds {
+			if k < len(restoredLinks.Bonds) {
+				links.Bonds[k].DefaultRoutePriority = restoredLinks.Bonds[k].DefaultRoutePriority
+			}
 		}
-		for k := range dst.Spec.MetaData.PrefixesFromPool {
-			dst.Spec.MetaData.PrefixesFromPool[k].APIGroup = restored.Spec.MetaData.PrefixesFromPool[k].APIGroup
-			dst.Spec.MetaData.PrefixesFromPool[k].Kind = restored.Spec.MetaData.PrefixesFromPool[k].Kind
+		for k := range links.Vlans {
+			if k < len(restoredLinks.Vlans) {
+				links.Vlans[k].DefaultRoutePriority = restoredLinks.Vlans[k].DefaultRoutePriority
+			}
 		}
-		for k := range dst.Spec.MetaData.DNSServersFromPool {
-			dst.Spec.MetaData.DNSServersFromPool[k].APIGroup = restored.Spec.MetaData.DNSServersFromPool[k].APIGroup
-			dst.Spec.MetaData.DNSServersFromPool[k].Kind = restored.Spec.MetaData.DNSServersFromPool[k].Kind
+		networks, restoredNetworks := &dst.Spec.NetworkData.Networks, restored.Spec.NetworkData.Networks
+		for k := range networks.IPv4 {
+			if k < len(restoredNetworks.IPv4) {
+				networks.IPv4[k].FromPoolRef = restoredNetworks.IPv4[k].FromPoolRef
+				networks.IPv4[k].DefaultRoute = restoredNetworks.IPv4[k].DefaultRoute
+				restoreRoutesv4(networks.IPv4[k].Routes, restoredNetworks.IPv4[k].Routes)
+			}
 		}
-	}
-	if dst.Spec.NetworkData != nil && restored.Spec.NetworkData != nil {
-		for k := range dst.Spec.NetworkData.Networks.IPv4 {
-			dst.Spec.NetworkData.Networks.IPv4[k].FromPoolRef = restored.Spec.NetworkData.Networks.IPv4[k].FromPoolRef
+		for k := range networks.IPv6 {
+			if k < len(restoredNetworks.IPv6) {
+				networks.IPv6[k].FromPoolRef = restoredNetworks.IPv6[k].FromPoolRef
+				networks.IPv6[k].DefaultRoute = restoredNetworks.IPv6[k].DefaultRoute
+				restoreRoutesv6(networks.IPv6[k].Routes, restoredNetworks.IPv6[k].Routes)
+			}
+		}
+		for k := range networks.IPv4DHCP {
+			if k < len(restoredNetworks.IPv4DHCP) {
+				networks.IPv4DHCP[k].DefaultRoute = restoredNetworks.IPv4DHCP[k].DefaultRoute
+				restoreRoutesv4(networks.IPv4DHCP[k].Routes, restoredNetworks.IPv4DHCP[k].Routes)
+			}
 		}
-		for k := range dst.Spec.NetworkData.Networks.IPv6 {
-			dst.Spec.NetworkData.Networks.IPv6[k].FromPoolRef = restored.Spec.NetworkData.Networks.IPv6[k].FromPoolRef
+		for k := range networks.IPv6DHCP {
+			if k < len(restoredNetworks.IPv6DHCP) {
+				networks.IPv6DHCP[k].DefaultRoute = restoredNetworks.IPv6DHCP[k].DefaultRoute
+				restoreRoutesv6(networks.IPv6DHCP[k].Routes, restoredNetworks.IPv6DHCP[k].Routes)
+			}
+		}
+		for k := range networks.IPv6SLAAC {
+			if k < len(restoredNetworks.IPv6SLAAC) {
+				networks.IPv6SLAAC[k].DefaultRoute = restoredNetworks.IPv6SLAAC[k].DefaultRoute
+				restoreRoutesv6(networks.IPv6SLAAC[k].Routes, restoredNetworks.IPv6SLAAC[k].Routes)
+			}
 		}
 	}
 
 	return nil
 }
 
+// restoreFromPools restores the apiGroup and kind of the pools, introduced in
+// v1beta1.
+func restoreFromPools(dst, restored []v1beta1.FromPool) {
+	for k := range dst {
+		if k < len(restored) {
+			dst[k].APIGroup = restored[k].APIGroup
+			dst[k].Kind = restored[k].Kind
+		}
+	}
+}
+
+// restoreRoutesv4 restores the metrics and tables of the routes, introduced
+// in v1beta1.
+func restoreRoutesv4(dst, restored []v1beta1.NetworkDataRoutev4) {
+	for k := range dst {
+		if k < len(restored) {
+			dst[k].Metric = restored[k].Metric
+			dst[k].Table = restored[k].Table
+		}
+	}
+}
+
+// restoreRoutesv6 restores the metrics and tables of the routes, introduced
+// in v1beta1.
+func restoreRoutesv6(dst, restored []v1beta1.NetworkDataRoutev6) {
+	for k := range dst {
+		if k < len(restored) {
+			dst[k].Metric = restored[k].Metric
+			dst[k].Table = restored[k].Table
+		}
+	}
+}
+
 func (dst *Metal3DataTemplate) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*v1beta1.Metal3DataTemplate)
 	if err := Convert_v1beta1_Metal3DataTemplate_To_v1alpha5_Metal3DataTemplate(src, dst, nil); err != nil {
 		return err
 	}
 
-	return utilconversion.MarshalData(src, dst)
+	return marshalData(src, dst)
+}
+
+// Status.LastIndexes, Status.IndexSummary and Status.Conditions were introduced in v1beta1, thus requiring a custom conversion function; the values are going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(in *v1beta1.Metal3DataTemplateStatus, out *Metal3DataTemplateStatus, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(in, out, s)
+}
+
+// Spec.RerenderOnTemplateChange was introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(in *v1beta1.Metal3DataTemplateSpec, out *Metal3DataTemplateSpec, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(in, out, s)
+}
+
+func Convert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in *v1beta1.NetworkData, out *NetworkData, s apiconversion.Scope) error {
+	// format was added with v1beta1.
+	return autoConvert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in, out, s)
 }
 
 func Convert_v1beta1_NetworkDataIPv6_To_v1alpha5_NetworkDataIPv6(in *v1beta1.NetworkDataIPv6, out *NetworkDataIPv6, s apiconversion.Scope) error {
-	// fromPoolRef was added with v1beta1.
+	// fromPoolRef and defaultRoute were added with v1beta1.
 	return autoConvert_v1beta1_NetworkDataIPv6_To_v1alpha5_NetworkDataIPv6(in, out, s)
 }
 
 func Convert_v1beta1_NetworkDataIPv4_To_v1alpha5_NetworkDataIPv4(in *v1beta1.NetworkDataIPv4, out *NetworkDataIPv4, s apiconversion.Scope) error {
-	// fromPoolRef was added with v1beta1.
+	// fromPoolRef and defaultRoute were added with v1beta1.
 	return autoConvert_v1beta1_NetworkDataIPv4_To_v1alpha5_NetworkDataIPv4(in, out, s)
 }
 
+func Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(in *v1beta1.NetworkDataIPv4DHCP, out *NetworkDataIPv4DHCP, s apiconversion.Scope) error {
+	// defaultRoute was added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(in *v1beta1.NetworkDataIPv6DHCP, out *NetworkDataIPv6DHCP, s apiconversion.Scope) error {
+	// defaultRoute was added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(in *v1beta1.NetworkDataLinkEthernet, out *NetworkDataLinkEthernet, s apiconversion.Scope) error {
+	// vendorExtensions and defaultRoutePriority were added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(in *v1beta1.NetworkDataLinkBond, out *NetworkDataLinkBond, s apiconversion.Scope) error {
+	// defaultRoutePriority was added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(in *v1beta1.NetworkDataLinkVlan, out *NetworkDataLinkVlan, s apiconversion.Scope) error {
+	// defaultRoutePriority was added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(in *v1beta1.NetworkDataRoutev4, out *NetworkDataRoutev4, s apiconversion.Scope) error {
+	// metric and table were added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(in, out, s)
+}
+
+func Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(in *v1beta1.NetworkDataRoutev6, out *NetworkDataRoutev6, s apiconversion.Scope) error {
+	// metric and table were added with v1beta1.
+	return autoConvert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(in, out, s)
+}
+
+func Convert_v1beta1_MetaData_To_v1alpha5_MetaData(in *v1beta1.MetaData, out *MetaData, s apiconversion.Scope) error {
+	// fromTemplates was added with v1beta1.
+	return autoConvert_v1beta1_MetaData_To_v1alpha5_MetaData(in, out, s)
+}
+
+func Convert_v1beta1_Image_To_v1alpha5_Image(in *v1beta1.Image, out *Image, s apiconversion.Scope) error {
+	// userDataFormat was added with v1beta1.
+	return autoConvert_v1beta1_Image_To_v1alpha5_Image(in, out, s)
+}
+
 func Convert_v1beta1_FromPool_To_v1alpha5_FromPool(in *v1beta1.FromPool, out *FromPool, s apiconversion.Scope) error {
 	// apiGroup and kind was added with v1beta1.
 	return autoConvert_v1beta1_FromPool_To_v1alpha5_FromPool(in, out, s)
@@ -246,7 +480,13 @@ func (src *Metal3DataClaim) ConvertTo(dstRaw conversion.Hub) error {
 	if err := Convert_v1alpha5_Metal3DataClaim_To_v1beta1_Metal3DataClaim(src, dst, nil); err != nil {
 		return err
 	}
-
+	// Manually restore data.
+	restored := &v1beta1.Metal3DataClaim{}
+	if !unmarshalData(src, restored, dst) {
+		return nil
+	}
+	dst.Status.IndexAllocation = restored.Status.IndexAllocation
+	dst.Status.Conditions = restored.Status.Conditions
 	return nil
 }
 
@@ -255,8 +495,13 @@ func (dst *Metal3DataClaim) ConvertFrom(srcRaw conversion.Hub) error {
 	if err := Convert_v1beta1_Metal3DataClaim_To_v1alpha5_Metal3DataClaim(src, dst, nil); err != nil {
 		return err
 	}
+	// Preserve Hub data on down-conversion except for metadata
+	return marshalData(src, dst)
+}
 
-	return nil
+// Status.IndexAllocation was introduced in v1beta1, thus requiring a custom conversion function; the value is going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(in *v1beta1.Metal3DataClaimStatus, out *Metal3DataClaimStatus, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(in, out, s)
 }
 
 func (src *Metal3DataClaimList) ConvertTo(dstRaw conversion.Hub) error {
@@ -271,12 +516,37 @@ func (dst *Metal3DataClaimList) ConvertFrom(srcRaw conversion.Hub) error {
 
 func (src *Metal3Remediation) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*v1beta1.Metal3Remediation)
-	return Convert_v1alpha5_Metal3Remediation_To_v1beta1_Metal3Remediation(src, dst, nil)
+	if err := Convert_v1alpha5_Metal3Remediation_To_v1beta1_Metal3Remediation(src, dst, nil); err != nil {
+		return err
+	}
+	// Manually restore data.
+	restored := &v1beta1.Metal3Remediation{}
+	if !unmarshalData(src, restored, dst) {
+		return nil
+	}
+	dst.Status.LastPhaseTransition = restored.Status.LastPhaseTransition
+	dst.Status.HostRef = restored.Status.HostRef
+	dst.Status.ConsumerRef = restored.Status.ConsumerRef
+	dst.Status.NodeRemediationMechanism = restored.Status.NodeRemediationMechanism
+	dst.Status.FailureDomain = restored.Status.FailureDomain
+	dst.Status.HostZone = restored.Status.HostZone
+	dst.Status.History = restored.Status.History
+	dst.Status.Conditions = restored.Status.Conditions
+	return nil
 }
 
 func (dst *Metal3Remediation) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*v1beta1.Metal3Remediation)
-	return Convert_v1beta1_Metal3Remediation_To_v1alpha5_Metal3Remediation(src, dst, nil)
+	if err := Convert_v1beta1_Metal3Remediation_To_v1alpha5_Metal3Remediation(src, dst, nil); err != nil {
+		return err
+	}
+	// Preserve Hub data on down-conversion except for metadata
+	return marshalData(src, dst)
+}
+
+// Status.LastPhaseTransition, Status.HostRef, Status.ConsumerRef, Status.NodeRemediationMechanism, Status.FailureDomain, Status.HostZone, Status.History and Status.Conditions were introduced in v1beta1, thus requiring a custom conversion function; the values are going to be preserved in an annotation thus allowing roundtrip without losing information.
+func Convert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(in *v1beta1.Metal3RemediationStatus, out *Metal3RemediationStatus, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(in, out, s)
 }
 
 func (src *Metal3RemediationList) ConvertTo(dstRaw conversion.Hub) error {
@@ -291,12 +561,32 @@ func (dst *Metal3RemediationList) ConvertFrom(srcRaw conversion.Hub) error {
 
 func (src *Metal3RemediationTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*v1beta1.Metal3RemediationTemplate)
-	return Convert_v1alpha5_Metal3RemediationTemplate_To_v1beta1_Metal3RemediationTemplate(src, dst, nil)
+	if err := Convert_v1alpha5_Metal3RemediationTemplate_To_v1beta1_Metal3RemediationTemplate(src, dst, nil); err != nil {
+		return err
+	}
+	// Manually restore data.
+	restored := &v1beta1.Metal3RemediationTemplate{}
+	if !unmarshalData(src, restored, dst) {
+		return nil
+	}
+	dst.Status.Status.LastPhaseTransition = restored.Status.Status.LastPhaseTransition
+	dst.Status.Status.HostRef = restored.Status.Status.HostRef
+	dst.Status.Status.ConsumerRef = restored.Status.Status.ConsumerRef
+	dst.Status.Status.NodeRemediationMechanism = restored.Status.Status.NodeRemediationMechanism
+	dst.Status.Status.FailureDomain = restored.Status.Status.FailureDomain
+	dst.Status.Status.HostZone = restored.Status.Status.HostZone
+	dst.Status.Status.History = restored.Status.Status.History
+	dst.Status.Status.Conditions = restored.Status.Status.Conditions
+	return nil
 }
 
 func (dst *Metal3RemediationTemplate) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*v1beta1.Metal3RemediationTemplate)
-	return Convert_v1beta1_Metal3RemediationTemplate_To_v1alpha5_Metal3RemediationTemplate(src, dst, nil)
+	if err := Convert_v1beta1_Metal3RemediationTemplate_To_v1alpha5_Metal3RemediationTemplate(src, dst, nil); err != nil {
+		return err
+	}
+	// Preserve Hub data on down-conversion except for metadata
+	return marshalData(src, dst)
 }
 
 func (src *Metal3RemediationTemplateList) ConvertTo(dstRaw conversion.Hub) error {
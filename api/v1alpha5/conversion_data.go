@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha5
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// UnknownFieldsAnnotation keeps on the hub the fields of the conversion
+	// data that this version does not know, e.g. written by a newer version
+	// before a rollback, so that they are written back in the conversion data
+	// when converting down again instead of being dropped.
+	UnknownFieldsAnnotation = "infrastructure.cluster.x-k8s.io/conversion-unknown-fields"
+
+	// maxConversionDataSize is the size above which the conversion data is
+	// not preserved, to keep the annotations of the object below the 256KiB
+	// limit of the API server.
+	maxConversionDataSize = 128 * 1024
+)
+
+var conversionlog = logf.Log.WithName("conversion")
+
+// marshalData stores the hub in the conversion data annotation of the spoke,
+// with the unknown fields preserved on the hub. The data is dropped if it
+// exceeds maxConversionDataSize.
+func marshalData(src, dst metav1.Object) error {
+	unknownFields, hasUnknownFields := dst.GetAnnotations()[UnknownFieldsAnnotation]
+	dst.SetAnnotations(copyAnnotations(dst.GetAnnotations()))
+	if err := utilconversion.MarshalData(src, dst); err != nil {
+		return err
+	}
+	annotations := dst.GetAnnotations()
+	delete(annotations, UnknownFieldsAnnotation)
+	if hasUnknownFields {
+		data, err := mergeUnknownFields(annotations[utilconversion.DataAnnotation], unknownFields)
+		if err != nil {
+			conversionlog.Error(err, "Invalid unknown fields, not preserving them",
+				"name", dst.GetName(), "namespace", dst.GetNamespace(),
+			)
+		} else {
+			annotations[utilconversion.DataAnnotation] = data
+		}
+	}
+	if size := len(annotations[utilconversion.DataAnnotation]); size > maxConversionDataSize {
+		conversionlog.Info("Conversion data too large, not preserving it",
+			"name", dst.GetName(), "namespace", dst.GetNamespace(), "size", size,
+		)
+		delete(annotations, utilconversion.DataAnnotation)
+	}
+	dst.SetAnnotations(annotations)
+	return nil
+}
+
+// unmarshalData restores the hub from the conversion data annotation of the
+// spoke, and keeps the fields it does not know in the UnknownFieldsAnnotation
+// of the hub. Invalid conversion data is logged and ignored, so that the
+// conversion succeeds with the spoke fields only. It returns whether the data
+// was restored.
+func unmarshalData(src metav1.Object, restored, dst metav1.Object) bool {
+	data, ok := src.GetAnnotations()[utilconversion.DataAnnotation]
+	if !ok {
+		return false
+	}
+	if _, err := utilconversion.UnmarshalData(src, restored); err != nil {
+		conversionlog.Error(err, "Invalid conversion data, ignoring it",
+			"name", src.GetName(), "namespace", src.GetNamespace(),
+		)
+		deleteAnnotation(src, utilconversion.DataAnnotation)
+		deleteAnnotation(dst, utilconversion.DataAnnotation)
+		return false
+	}
+	deleteAnnotation(dst, utilconversion.DataAnnotation)
+
+	unknownFields, err := getUnknownFields(data, restored)
+	if err != nil {
+		conversionlog.Error(err, "Failed to compute the unknown fields, not preserving them",
+			"name", src.GetName(), "namespace", src.GetNamespace(),
+		)
+		return true
+	}
+	if unknownFields != "" {
+		annotations := copyAnnotations(dst.GetAnnotations())
+		annotations[UnknownFieldsAnnotation] = unknownFields
+		dst.SetAnnotations(annotations)
+	}
+	return true
+}
+
+// getUnknownFields returns, as JSON, the fields of the conversion data that
+// are not part of the restored hub, or "" if there are none.
+func getUnknownFields(data string, restored interface{}) (string, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return "", err
+	}
+	known, err := runtime.DefaultUnstructuredConverter.ToUnstructured(restored)
+	if err != nil {
+		return "", err
+	}
+	unknown := subtractFields(raw, known)
+	if len(unknown) == 0 {
+		return "", nil
+	}
+	out, err := json.Marshal(unknown)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// subtractFields returns the fields of raw that are not in known, recursing
+// into the objects present in both. Lists are compared as a whole, an unknown
+// field within a list item is not preserved.
+func subtractFields(raw, known map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range raw {
+		knownValue, ok := known[key]
+		if !ok {
+			out[key] = value
+			continue
+		}
+		rawMap, rawIsMap := value.(map[string]interface{})
+		knownMap, knownIsMap := knownValue.(map[string]interface{})
+		if rawIsMap && knownIsMap {
+			if sub := subtractFields(rawMap, knownMap); len(sub) != 0 {
+				out[key] = sub
+			}
+		}
+	}
+	return out
+}
+
+// mergeUnknownFields adds the unknown fields to the conversion data, without
+// overriding the fields already set.
+func mergeUnknownFields(data, unknownFields string) (string, error) {
+	dataMap := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &dataMap); err != nil {
+		return "", err
+	}
+	unknownMap := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(unknownFields), &unknownMap); err != nil {
+		return "", err
+	}
+	addFields(dataMap, unknownMap)
+	out, err := json.Marshal(dataMap)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// addFields adds the fields of extra missing from dst, recursing into the
+// objects present in both.
+func addFields(dst, extra map[string]interface{}) {
+	for key, value := range extra {
+		dstValue, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		dstMap, dstIsMap := dstValue.(map[string]interface{})
+		extraMap, extraIsMap := value.(map[string]interface{})
+		if dstIsMap && extraIsMap {
+			addFields(dstMap, extraMap)
+		}
+	}
+}
+
+// deleteAnnotation removes the annotation from a copy of the annotations of
+// the object.
+func deleteAnnotation(o metav1.Object, key string) {
+	if _, ok := o.GetAnnotations()[key]; !ok {
+		return
+	}
+	annotations := copyAnnotations(o.GetAnnotations())
+	delete(annotations, key)
+	o.SetAnnotations(annotations)
+}
+
+// copyAnnotations returns a copy of the annotations, as the generated
+// conversion functions share the annotations map between the hub and the
+// spoke.
+func copyAnnotations(annotations map[string]string) map[string]string {
+	out := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		out[k] = v
+	}
+	return out
+}
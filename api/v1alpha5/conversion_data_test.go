@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha5
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// futureData is conversion data written by a newer version, with fields this
+// version does not know.
+const futureData = `{
+	"spec": {"futureField": "future", "futureObject": {"key": "value"}},
+	"status": {"futureStatus": {"nested": {"count": 3}}}
+}`
+
+type convertibleObject interface {
+	conversion.Convertible
+	metav1.Object
+}
+
+type hubObject interface {
+	conversion.Hub
+	metav1.Object
+}
+
+func TestConversionPreservesUnknownFields(t *testing.T) {
+	testCases := []struct {
+		name  string
+		spoke func() convertibleObject
+		hub   func() hubObject
+	}{
+		{"Metal3Cluster", func() convertibleObject { return &Metal3Cluster{} }, func() hubObject { return &v1beta1.Metal3Cluster{} }},
+		{"Metal3Machine", func() convertibleObject { return &Metal3Machine{} }, func() hubObject { return &v1beta1.Metal3Machine{} }},
+		{"Metal3MachineTemplate", func() convertibleObject { return &Metal3MachineTemplate{} }, func() hubObject { return &v1beta1.Metal3MachineTemplate{} }},
+		{"Metal3Data", func() convertibleObject { return &Metal3Data{} }, func() hubObject { return &v1beta1.Metal3Data{} }},
+		{"Metal3DataTemplate", func() convertibleObject { return &Metal3DataTemplate{} }, func() hubObject { return &v1beta1.Metal3DataTemplate{} }},
+		{"Metal3DataClaim", func() convertibleObject { return &Metal3DataClaim{} }, func() hubObject { return &v1beta1.Metal3DataClaim{} }},
+		{"Metal3Remediation", func() convertibleObject { return &Metal3Remediation{} }, func() hubObject { return &v1beta1.Metal3Remediation{} }},
+		{"Metal3RemediationTemplate", func() convertibleObject { return &Metal3RemediationTemplate{} }, func() hubObject { return &v1beta1.Metal3RemediationTemplate{} }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// Object written through v1alpha5 by a newer version.
+			spoke := tc.spoke()
+			spoke.SetName("test")
+			spoke.SetAnnotations(map[string]string{
+				utilconversion.DataAnnotation: futureData,
+				"foo":                         "bar",
+			})
+
+			// Upgrade to the hub of this version, the unknown fields are kept
+			// in an annotation.
+			hub := tc.hub()
+			g.Expect(spoke.ConvertTo(hub)).To(Succeed())
+			g.Expect(hub.GetAnnotations()).NotTo(HaveKey(utilconversion.DataAnnotation))
+			g.Expect(hub.GetAnnotations()).To(HaveKeyWithValue("foo", "bar"))
+			g.Expect(hub.GetAnnotations()).To(HaveKey(UnknownFieldsAnnotation))
+			g.Expect(hub.GetAnnotations()[UnknownFieldsAnnotation]).To(MatchJSON(futureData))
+
+			// Downgrade again, the unknown fields are written back in the
+			// conversion data for the newer version.
+			downgraded := tc.spoke()
+			g.Expect(downgraded.ConvertFrom(hub)).To(Succeed())
+			g.Expect(downgraded.GetAnnotations()).NotTo(HaveKey(UnknownFieldsAnnotation))
+			g.Expect(hub.GetAnnotations()).To(HaveKey(UnknownFieldsAnnotation))
+			data := map[string]interface{}{}
+			g.Expect(json.Unmarshal([]byte(downgraded.GetAnnotations()[utilconversion.DataAnnotation]), &data)).To(Succeed())
+			g.Expect(data).To(HaveKeyWithValue("spec", HaveKeyWithValue("futureField", "future")))
+			g.Expect(data).To(HaveKeyWithValue("spec", HaveKeyWithValue("futureObject", HaveKeyWithValue("key", "value"))))
+			g.Expect(data).To(HaveKeyWithValue("status", HaveKeyWithValue("futureStatus",
+				HaveKeyWithValue("nested", HaveKeyWithValue("count", BeNumerically("==", 3))))))
+
+			// And upgrade once more, without losing them.
+			upgraded := tc.hub()
+			g.Expect(downgraded.ConvertTo(upgraded)).To(Succeed())
+			g.Expect(upgraded.GetAnnotations()[UnknownFieldsAnnotation]).To(MatchJSON(futureData))
+		})
+	}
+}
+
+func TestConversionKnownFieldsNotDuplicated(t *testing.T) {
+	g := NewWithT(t)
+
+	hub := &v1beta1.Metal3Machine{}
+	hub.Spec.NodeReuseGroup = "group"
+	spoke := &Metal3Machine{}
+	g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+	g.Expect(hub.GetAnnotations()).NotTo(HaveKey(utilconversion.DataAnnotation))
+
+	restored := &v1beta1.Metal3Machine{}
+	g.Expect(spoke.ConvertTo(restored)).To(Succeed())
+	g.Expect(restored.Spec.NodeReuseGroup).To(Equal("group"))
+	g.Expect(restored.GetAnnotations()).NotTo(HaveKey(UnknownFieldsAnnotation))
+}
+
+func TestConversionInvalidData(t *testing.T) {
+	g := NewWithT(t)
+
+	spoke := &Metal3Machine{}
+	spoke.Spec.Image.URL = "http://image"
+	spoke.SetAnnotations(map[string]string{utilconversion.DataAnnotation: "{invalid"})
+
+	hub := &v1beta1.Metal3Machine{}
+	g.Expect(spoke.ConvertTo(hub)).To(Succeed())
+	g.Expect(hub.Spec.Image.URL).To(Equal("http://image"))
+	g.Expect(hub.GetAnnotations()).NotTo(HaveKey(utilconversion.DataAnnotation))
+	g.Expect(hub.GetAnnotations()).NotTo(HaveKey(UnknownFieldsAnnotation))
+
+	// Invalid unknown fields are not merged in the conversion data.
+	hub.SetAnnotations(map[string]string{UnknownFieldsAnnotation: "{invalid"})
+	hub.Spec.NodeReuseGroup = "group"
+	downgraded := &Metal3Machine{}
+	g.Expect(downgraded.ConvertFrom(hub)).To(Succeed())
+	g.Expect(downgraded.GetAnnotations()).NotTo(HaveKey(UnknownFieldsAnnotation))
+	restored := &v1beta1.Metal3Machine{}
+	g.Expect(downgraded.ConvertTo(restored)).To(Succeed())
+	g.Expect(restored.Spec.NodeReuseGroup).To(Equal("group"))
+}
+
+func TestConversionDataSizeLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	hub := &v1beta1.Metal3Machine{}
+	hub.Spec.Image.URL = "http://" + strings.Repeat("a", maxConversionDataSize)
+	hub.Spec.NodeReuseGroup = "group"
+	spoke := &Metal3Machine{}
+	g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+	g.Expect(spoke.Spec.Image.URL).To(Equal(hub.Spec.Image.URL))
+	g.Expect(spoke.GetAnnotations()).NotTo(HaveKey(utilconversion.DataAnnotation))
+
+	// The hub-only fields are lost, the spoke fields are converted.
+	restored := &v1beta1.Metal3Machine{}
+	g.Expect(spoke.ConvertTo(restored)).To(Succeed())
+	g.Expect(restored.Spec.Image.URL).To(Equal(hub.Spec.Image.URL))
+	g.Expect(restored.Spec.NodeReuseGroup).To(BeEmpty())
+}
@@ -14,6 +14,7 @@ limitations under the License.
 package v1alpha5
 
 import (
+	"encoding/json"
 	"math/rand"
 	"testing"
 	"time"
@@ -22,8 +23,10 @@ import (
 	"github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/pointer"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 )
 
@@ -88,4 +91,124 @@ func TestFuzzyConversion(t *testing.T) {
 		Hub:    &v1beta1.Metal3DataClaim{},
 		Spoke:  &Metal3DataClaim{},
 	}))
+
+	t.Run("for Metal3Remediation", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme: scheme,
+		Hub:    &v1beta1.Metal3Remediation{},
+		Spoke:  &Metal3Remediation{},
+	}))
+
+	t.Run("for Metal3RemediationTemplate", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme: scheme,
+		Hub:    &v1beta1.Metal3RemediationTemplate{},
+		Spoke:  &Metal3RemediationTemplate{},
+	}))
+}
+
+func TestMetal3MachineTemplateStorageRoundTrip(t *testing.T) {
+	g := NewWithT(t)
+	hub := &v1beta1.Metal3MachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: v1beta1.Metal3MachineTemplateSpec{
+			NodeReuse: true,
+			Template: v1beta1.Metal3MachineTemplateResource{
+				Spec: v1beta1.Metal3MachineSpec{
+					AutomatedCleaningMode: pointer.String("disabled"),
+					Image: v1beta1.Image{
+						URL:          "http://image",
+						Checksum:     "http://image.sha256sum",
+						ChecksumType: pointer.String("sha256"),
+					},
+					NodeReuseGroup:        "group",
+					PowerState:            v1beta1.PowerStateOff,
+					PowerManagementPolicy: v1beta1.PowerManagementPolicyOnDemand,
+					UserDataAppend:        &v1beta1.UserDataAppend{Name: "append"},
+				},
+			},
+		},
+	}
+
+	// GET through v1alpha5, stored as JSON by the client.
+	spoke := &Metal3MachineTemplate{}
+	g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+	g.Expect(spoke.Spec.NodeReuse).To(BeTrue())
+	data, err := json.Marshal(spoke)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// PUT back unchanged through v1alpha5.
+	stored := &Metal3MachineTemplate{}
+	g.Expect(json.Unmarshal(data, stored)).To(Succeed())
+	upgraded := &v1beta1.Metal3MachineTemplate{}
+	g.Expect(stored.ConvertTo(upgraded)).To(Succeed())
+	g.Expect(upgraded).To(Equal(hub))
+}
+
+func TestMetal3DataTemplateConversionModifiedLists(t *testing.T) {
+	g := NewWithT(t)
+	hub := &v1beta1.Metal3DataTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "template"},
+		Spec: v1beta1.Metal3DataTemplateSpec{
+			MetaData: &v1beta1.MetaData{
+				IPAddressesFromPool: []v1beta1.FromPool{
+					{Key: "ip", Name: "pool", APIGroup: "ipam.metal3.io", Kind: "IPPool"},
+				},
+			},
+			NetworkData: &v1beta1.NetworkData{
+				Links: v1beta1.NetworkDataLink{
+					Ethernets: []v1beta1.NetworkDataLinkEthernet{
+						{Type: "phy", Id: "eth0", DefaultRoutePriority: pointer.Int(10)},
+					},
+				},
+			},
+		},
+	}
+	spoke := &Metal3DataTemplate{}
+	g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+
+	// Items added through v1alpha5 have no preserved fields.
+	spoke.Spec.MetaData.IPAddressesFromPool = append(spoke.Spec.MetaData.IPAddressesFromPool,
+		FromPool{Key: "ip2", Name: "pool2"},
+	)
+	spoke.Spec.NetworkData.Links.Ethernets = append(spoke.Spec.NetworkData.Links.Ethernets,
+		NetworkDataLinkEthernet{Type: "phy", Id: "eth1"},
+	)
+
+	upgraded := &v1beta1.Metal3DataTemplate{}
+	g.Expect(spoke.ConvertTo(upgraded)).To(Succeed())
+	g.Expect(upgraded.Spec.MetaData.IPAddressesFromPool).To(Equal([]v1beta1.FromPool{
+		{Key: "ip", Name: "pool", APIGroup: "ipam.metal3.io", Kind: "IPPool"},
+		{Key: "ip2", Name: "pool2"},
+	}))
+	g.Expect(upgraded.Spec.NetworkData.Links.Ethernets[0].DefaultRoutePriority).To(Equal(pointer.Int(10)))
+	g.Expect(upgraded.Spec.NetworkData.Links.Ethernets[1].DefaultRoutePriority).To(BeNil())
+}
+
+func TestMetal3ClusterConversionCloudProviderEnabled(t *testing.T) {
+	g := NewWithT(t)
+	hub := &v1beta1.Metal3Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: v1beta1.Metal3ClusterSpec{
+			CloudProviderEnabled: pointer.Bool(false),
+		},
+	}
+	spoke := &Metal3Cluster{}
+	g.Expect(spoke.ConvertFrom(hub)).To(Succeed())
+	g.Expect(spoke.Spec.NoCloudProvider).To(BeTrue())
+
+	upgraded := &v1beta1.Metal3Cluster{}
+	g.Expect(spoke.ConvertTo(upgraded)).To(Succeed())
+	g.Expect(upgraded.Spec.CloudProviderEnabled).To(Equal(pointer.Bool(false)))
+	g.Expect(upgraded.Spec.NoCloudProvider).To(BeFalse())
+
+	// noCloudProvider changed through v1alpha5 takes precedence, the
+	// cloudProviderEnabled is defaulted from it.
+	spoke.Spec.NoCloudProvider = false
+	upgraded = &v1beta1.Metal3Cluster{}
+	g.Expect(spoke.ConvertTo(upgraded)).To(Succeed())
+	g.Expect(upgraded.Spec.CloudProviderEnabled).To(BeNil())
+	g.Expect(upgraded.Spec.NoCloudProvider).To(BeFalse())
+	g.Expect(upgraded.Spec.IsCloudProviderEnabled()).To(BeTrue())
 }
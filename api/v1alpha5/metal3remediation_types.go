@@ -63,7 +63,7 @@ type RemediationStrategy struct {
 
 	// Sets the timeout between remediation retries.
 	// +optional
-	Timeout *metav1.Duration `json:"timeout"`
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 // Metal3RemediationStatus defines the observed state of Metal3Remediation.
@@ -63,11 +63,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.HostSelector)(nil), (*HostSelector)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector(a.(*v1beta1.HostSelector), b.(*HostSelector), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*HostSelectorRequirement)(nil), (*v1beta1.HostSelectorRequirement)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_HostSelectorRequirement_To_v1beta1_HostSelectorRequirement(a.(*HostSelectorRequirement), b.(*v1beta1.HostSelectorRequirement), scope)
 	}); err != nil {
@@ -83,21 +78,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Image)(nil), (*Image)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Image_To_v1alpha5_Image(a.(*v1beta1.Image), b.(*Image), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*MetaData)(nil), (*v1beta1.MetaData)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_MetaData_To_v1beta1_MetaData(a.(*MetaData), b.(*v1beta1.MetaData), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.MetaData)(nil), (*MetaData)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_MetaData_To_v1alpha5_MetaData(a.(*v1beta1.MetaData), b.(*MetaData), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*MetaDataFromAnnotation)(nil), (*v1beta1.MetaDataFromAnnotation)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_MetaDataFromAnnotation_To_v1beta1_MetaDataFromAnnotation(a.(*MetaDataFromAnnotation), b.(*v1beta1.MetaDataFromAnnotation), scope)
 	}); err != nil {
@@ -203,11 +188,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3ClusterSpec)(nil), (*Metal3ClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(a.(*v1beta1.Metal3ClusterSpec), b.(*Metal3ClusterSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3ClusterStatus)(nil), (*v1beta1.Metal3ClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3ClusterStatus_To_v1beta1_Metal3ClusterStatus(a.(*Metal3ClusterStatus), b.(*v1beta1.Metal3ClusterStatus), scope)
 	}); err != nil {
@@ -258,11 +238,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3DataClaimStatus)(nil), (*Metal3DataClaimStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(a.(*v1beta1.Metal3DataClaimStatus), b.(*Metal3DataClaimStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3DataList)(nil), (*v1beta1.Metal3DataList)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3DataList_To_v1beta1_Metal3DataList(a.(*Metal3DataList), b.(*v1beta1.Metal3DataList), scope)
 	}); err != nil {
@@ -288,11 +263,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3DataStatus)(nil), (*Metal3DataStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(a.(*v1beta1.Metal3DataStatus), b.(*Metal3DataStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3DataTemplate)(nil), (*v1beta1.Metal3DataTemplate)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3DataTemplate_To_v1beta1_Metal3DataTemplate(a.(*Metal3DataTemplate), b.(*v1beta1.Metal3DataTemplate), scope)
 	}); err != nil {
@@ -318,21 +288,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3DataTemplateSpec)(nil), (*Metal3DataTemplateSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(a.(*v1beta1.Metal3DataTemplateSpec), b.(*Metal3DataTemplateSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3DataTemplateStatus)(nil), (*v1beta1.Metal3DataTemplateStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3DataTemplateStatus_To_v1beta1_Metal3DataTemplateStatus(a.(*Metal3DataTemplateStatus), b.(*v1beta1.Metal3DataTemplateStatus), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3DataTemplateStatus)(nil), (*Metal3DataTemplateStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(a.(*v1beta1.Metal3DataTemplateStatus), b.(*Metal3DataTemplateStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3Machine)(nil), (*v1beta1.Metal3Machine)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3Machine_To_v1beta1_Metal3Machine(a.(*Metal3Machine), b.(*v1beta1.Metal3Machine), scope)
 	}); err != nil {
@@ -358,11 +318,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3MachineSpec)(nil), (*Metal3MachineSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(a.(*v1beta1.Metal3MachineSpec), b.(*Metal3MachineSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3MachineStatus)(nil), (*v1beta1.Metal3MachineStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3MachineStatus_To_v1beta1_Metal3MachineStatus(a.(*Metal3MachineStatus), b.(*v1beta1.Metal3MachineStatus), scope)
 	}); err != nil {
@@ -373,11 +328,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3MachineTemplate)(nil), (*Metal3MachineTemplate)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(a.(*v1beta1.Metal3MachineTemplate), b.(*Metal3MachineTemplate), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3MachineTemplateList)(nil), (*v1beta1.Metal3MachineTemplateList)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3MachineTemplateList_To_v1beta1_Metal3MachineTemplateList(a.(*Metal3MachineTemplateList), b.(*v1beta1.Metal3MachineTemplateList), scope)
 	}); err != nil {
@@ -403,11 +353,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3MachineTemplateSpec)(nil), (*Metal3MachineTemplateSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(a.(*v1beta1.Metal3MachineTemplateSpec), b.(*Metal3MachineTemplateSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3Remediation)(nil), (*v1beta1.Metal3Remediation)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3Remediation_To_v1beta1_Metal3Remediation(a.(*Metal3Remediation), b.(*v1beta1.Metal3Remediation), scope)
 	}); err != nil {
@@ -443,11 +388,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.Metal3RemediationStatus)(nil), (*Metal3RemediationStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(a.(*v1beta1.Metal3RemediationStatus), b.(*Metal3RemediationStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Metal3RemediationTemplate)(nil), (*v1beta1.Metal3RemediationTemplate)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_Metal3RemediationTemplate_To_v1beta1_Metal3RemediationTemplate(a.(*Metal3RemediationTemplate), b.(*v1beta1.Metal3RemediationTemplate), scope)
 	}); err != nil {
@@ -503,11 +443,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkData)(nil), (*NetworkData)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkData_To_v1alpha5_NetworkData(a.(*v1beta1.NetworkData), b.(*NetworkData), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataIPv4)(nil), (*v1beta1.NetworkDataIPv4)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataIPv4_To_v1beta1_NetworkDataIPv4(a.(*NetworkDataIPv4), b.(*v1beta1.NetworkDataIPv4), scope)
 	}); err != nil {
@@ -518,11 +453,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataIPv4DHCP)(nil), (*NetworkDataIPv4DHCP)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(a.(*v1beta1.NetworkDataIPv4DHCP), b.(*NetworkDataIPv4DHCP), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataIPv6)(nil), (*v1beta1.NetworkDataIPv6)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataIPv6_To_v1beta1_NetworkDataIPv6(a.(*NetworkDataIPv6), b.(*v1beta1.NetworkDataIPv6), scope)
 	}); err != nil {
@@ -533,11 +463,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataIPv6DHCP)(nil), (*NetworkDataIPv6DHCP)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(a.(*v1beta1.NetworkDataIPv6DHCP), b.(*NetworkDataIPv6DHCP), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataLink)(nil), (*v1beta1.NetworkDataLink)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataLink_To_v1beta1_NetworkDataLink(a.(*NetworkDataLink), b.(*v1beta1.NetworkDataLink), scope)
 	}); err != nil {
@@ -553,31 +478,16 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataLinkBond)(nil), (*NetworkDataLinkBond)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(a.(*v1beta1.NetworkDataLinkBond), b.(*NetworkDataLinkBond), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataLinkEthernet)(nil), (*v1beta1.NetworkDataLinkEthernet)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataLinkEthernet_To_v1beta1_NetworkDataLinkEthernet(a.(*NetworkDataLinkEthernet), b.(*v1beta1.NetworkDataLinkEthernet), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataLinkEthernet)(nil), (*NetworkDataLinkEthernet)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(a.(*v1beta1.NetworkDataLinkEthernet), b.(*NetworkDataLinkEthernet), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataLinkVlan)(nil), (*v1beta1.NetworkDataLinkVlan)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataLinkVlan_To_v1beta1_NetworkDataLinkVlan(a.(*NetworkDataLinkVlan), b.(*v1beta1.NetworkDataLinkVlan), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataLinkVlan)(nil), (*NetworkDataLinkVlan)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(a.(*v1beta1.NetworkDataLinkVlan), b.(*NetworkDataLinkVlan), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataNetwork)(nil), (*v1beta1.NetworkDataNetwork)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataNetwork_To_v1beta1_NetworkDataNetwork(a.(*NetworkDataNetwork), b.(*v1beta1.NetworkDataNetwork), scope)
 	}); err != nil {
@@ -593,21 +503,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataRoutev4)(nil), (*NetworkDataRoutev4)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(a.(*v1beta1.NetworkDataRoutev4), b.(*NetworkDataRoutev4), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataRoutev6)(nil), (*v1beta1.NetworkDataRoutev6)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataRoutev6_To_v1beta1_NetworkDataRoutev6(a.(*NetworkDataRoutev6), b.(*v1beta1.NetworkDataRoutev6), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDataRoutev6)(nil), (*NetworkDataRoutev6)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(a.(*v1beta1.NetworkDataRoutev6), b.(*NetworkDataRoutev6), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkDataService)(nil), (*v1beta1.NetworkDataService)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha5_NetworkDataService_To_v1beta1_NetworkDataService(a.(*NetworkDataService), b.(*v1beta1.NetworkDataService), scope)
 	}); err != nil {
@@ -683,26 +583,126 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.HostSelector)(nil), (*HostSelector)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector(a.(*v1beta1.HostSelector), b.(*HostSelector), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Image)(nil), (*Image)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Image_To_v1alpha5_Image(a.(*v1beta1.Image), b.(*Image), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.MetaData)(nil), (*MetaData)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_MetaData_To_v1alpha5_MetaData(a.(*v1beta1.MetaData), b.(*MetaData), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3ClusterSpec)(nil), (*Metal3ClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(a.(*v1beta1.Metal3ClusterSpec), b.(*Metal3ClusterSpec), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.Metal3ClusterStatus)(nil), (*Metal3ClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_Metal3ClusterStatus_To_v1alpha5_Metal3ClusterStatus(a.(*v1beta1.Metal3ClusterStatus), b.(*Metal3ClusterStatus), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3DataClaimStatus)(nil), (*Metal3DataClaimStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(a.(*v1beta1.Metal3DataClaimStatus), b.(*Metal3DataClaimStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3DataStatus)(nil), (*Metal3DataStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(a.(*v1beta1.Metal3DataStatus), b.(*Metal3DataStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3DataTemplateSpec)(nil), (*Metal3DataTemplateSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(a.(*v1beta1.Metal3DataTemplateSpec), b.(*Metal3DataTemplateSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3DataTemplateStatus)(nil), (*Metal3DataTemplateStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(a.(*v1beta1.Metal3DataTemplateStatus), b.(*Metal3DataTemplateStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3MachineSpec)(nil), (*Metal3MachineSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(a.(*v1beta1.Metal3MachineSpec), b.(*Metal3MachineSpec), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.Metal3MachineStatus)(nil), (*Metal3MachineStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_Metal3MachineStatus_To_v1alpha5_Metal3MachineStatus(a.(*v1beta1.Metal3MachineStatus), b.(*Metal3MachineStatus), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3MachineTemplateSpec)(nil), (*Metal3MachineTemplateSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(a.(*v1beta1.Metal3MachineTemplateSpec), b.(*Metal3MachineTemplateSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3MachineTemplate)(nil), (*Metal3MachineTemplate)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(a.(*v1beta1.Metal3MachineTemplate), b.(*Metal3MachineTemplate), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.Metal3RemediationStatus)(nil), (*Metal3RemediationStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(a.(*v1beta1.Metal3RemediationStatus), b.(*Metal3RemediationStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataIPv4DHCP)(nil), (*NetworkDataIPv4DHCP)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(a.(*v1beta1.NetworkDataIPv4DHCP), b.(*NetworkDataIPv4DHCP), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.NetworkDataIPv4)(nil), (*NetworkDataIPv4)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_NetworkDataIPv4_To_v1alpha5_NetworkDataIPv4(a.(*v1beta1.NetworkDataIPv4), b.(*NetworkDataIPv4), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataIPv6DHCP)(nil), (*NetworkDataIPv6DHCP)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(a.(*v1beta1.NetworkDataIPv6DHCP), b.(*NetworkDataIPv6DHCP), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.NetworkDataIPv6)(nil), (*NetworkDataIPv6)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_NetworkDataIPv6_To_v1alpha5_NetworkDataIPv6(a.(*v1beta1.NetworkDataIPv6), b.(*NetworkDataIPv6), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataLinkBond)(nil), (*NetworkDataLinkBond)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(a.(*v1beta1.NetworkDataLinkBond), b.(*NetworkDataLinkBond), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataLinkEthernet)(nil), (*NetworkDataLinkEthernet)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(a.(*v1beta1.NetworkDataLinkEthernet), b.(*NetworkDataLinkEthernet), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataLinkVlan)(nil), (*NetworkDataLinkVlan)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(a.(*v1beta1.NetworkDataLinkVlan), b.(*NetworkDataLinkVlan), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataRoutev4)(nil), (*NetworkDataRoutev4)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(a.(*v1beta1.NetworkDataRoutev4), b.(*NetworkDataRoutev4), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDataRoutev6)(nil), (*NetworkDataRoutev6)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(a.(*v1beta1.NetworkDataRoutev6), b.(*NetworkDataRoutev6), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkData)(nil), (*NetworkData)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkData_To_v1alpha5_NetworkData(a.(*v1beta1.NetworkData), b.(*NetworkData), scope)
+	}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -761,14 +761,11 @@ func Convert_v1alpha5_HostSelector_To_v1beta1_HostSelector(in *HostSelector, out
 func autoConvert_v1beta1_HostSelector_To_v1alpha5_HostSelector(in *v1beta1.HostSelector, out *HostSelector, s conversion.Scope) error {
 	out.MatchLabels = *(*map[string]string)(unsafe.Pointer(&in.MatchLabels))
 	out.MatchExpressions = *(*[]HostSelectorRequirement)(unsafe.Pointer(&in.MatchExpressions))
+	// WARNING: in.MatchHardware requires manual conversion: does not exist in peer-type
+	// WARNING: in.Preferences requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector is an autogenerated conversion function.
-func Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector(in *v1beta1.HostSelector, out *HostSelector, s conversion.Scope) error {
-	return autoConvert_v1beta1_HostSelector_To_v1alpha5_HostSelector(in, out, s)
-}
-
 func autoConvert_v1alpha5_HostSelectorRequirement_To_v1beta1_HostSelectorRequirement(in *HostSelectorRequirement, out *v1beta1.HostSelectorRequirement, s conversion.Scope) error {
 	out.Key = in.Key
 	out.Operator = selection.Operator(in.Operator)
@@ -811,14 +808,10 @@ func autoConvert_v1beta1_Image_To_v1alpha5_Image(in *v1beta1.Image, out *Image,
 	out.Checksum = in.Checksum
 	out.ChecksumType = (*string)(unsafe.Pointer(in.ChecksumType))
 	out.DiskFormat = (*string)(unsafe.Pointer(in.DiskFormat))
+	// WARNING: in.UserDataFormat requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Image_To_v1alpha5_Image is an autogenerated conversion function.
-func Convert_v1beta1_Image_To_v1alpha5_Image(in *v1beta1.Image, out *Image, s conversion.Scope) error {
-	return autoConvert_v1beta1_Image_To_v1alpha5_Image(in, out, s)
-}
-
 func autoConvert_v1alpha5_MetaData_To_v1beta1_MetaData(in *MetaData, out *v1beta1.MetaData, s conversion.Scope) error {
 	out.Strings = *(*[]v1beta1.MetaDataString)(unsafe.Pointer(&in.Strings))
 	out.ObjectNames = *(*[]v1beta1.MetaDataObjectName)(unsafe.Pointer(&in.ObjectNames))
@@ -931,14 +924,10 @@ func autoConvert_v1beta1_MetaData_To_v1alpha5_MetaData(in *v1beta1.MetaData, out
 	out.FromHostInterfaces = *(*[]MetaDataHostInterface)(unsafe.Pointer(&in.FromHostInterfaces))
 	out.FromLabels = *(*[]MetaDataFromLabel)(unsafe.Pointer(&in.FromLabels))
 	out.FromAnnotations = *(*[]MetaDataFromAnnotation)(unsafe.Pointer(&in.FromAnnotations))
+	// WARNING: in.FromTemplates requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_MetaData_To_v1alpha5_MetaData is an autogenerated conversion function.
-func Convert_v1beta1_MetaData_To_v1alpha5_MetaData(in *v1beta1.MetaData, out *MetaData, s conversion.Scope) error {
-	return autoConvert_v1beta1_MetaData_To_v1alpha5_MetaData(in, out, s)
-}
-
 func autoConvert_v1alpha5_MetaDataFromAnnotation_To_v1beta1_MetaDataFromAnnotation(in *MetaDataFromAnnotation, out *v1beta1.MetaDataFromAnnotation, s conversion.Scope) error {
 	out.Key = in.Key
 	out.Object = in.Object
@@ -1220,15 +1209,18 @@ func autoConvert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(in *v1b
 	if err := Convert_v1beta1_APIEndpoint_To_v1alpha5_APIEndpoint(&in.ControlPlaneEndpoint, &out.ControlPlaneEndpoint, s); err != nil {
 		return err
 	}
+	// WARNING: in.ControlPlaneEndpointFromPool requires manual conversion: does not exist in peer-type
 	out.NoCloudProvider = in.NoCloudProvider
+	// WARNING: in.CloudProviderEnabled requires manual conversion: does not exist in peer-type
+	// WARNING: in.AllowBootstrapless requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostSelectionPolicy requires manual conversion: does not exist in peer-type
+	// WARNING: in.ProvidedDataValidation requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomainLabel requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostClusterKubeconfigSecret requires manual conversion: does not exist in peer-type
+	// WARNING: in.EnforceDataTemplates requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec is an autogenerated conversion function.
-func Convert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(in *v1beta1.Metal3ClusterSpec, out *Metal3ClusterSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3ClusterSpec_To_v1alpha5_Metal3ClusterSpec(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3ClusterStatus_To_v1beta1_Metal3ClusterStatus(in *Metal3ClusterStatus, out *v1beta1.Metal3ClusterStatus, s conversion.Scope) error {
 	out.LastUpdated = (*v1.Time)(unsafe.Pointer(in.LastUpdated))
 	out.FailureReason = (*errors.ClusterStatusError)(unsafe.Pointer(in.FailureReason))
@@ -1247,6 +1239,10 @@ func autoConvert_v1beta1_Metal3ClusterStatus_To_v1alpha5_Metal3ClusterStatus(in
 	out.FailureReason = (*errors.ClusterStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
 	out.Ready = in.Ready
+	// WARNING: in.ReadyMachines requires manual conversion: does not exist in peer-type
+	// WARNING: in.ProvisioningMachines requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailedMachines requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomains requires manual conversion: does not exist in peer-type
 	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
@@ -1317,7 +1313,17 @@ func Convert_v1beta1_Metal3DataClaim_To_v1alpha5_Metal3DataClaim(in *v1beta1.Met
 
 func autoConvert_v1alpha5_Metal3DataClaimList_To_v1beta1_Metal3DataClaimList(in *Metal3DataClaimList, out *v1beta1.Metal3DataClaimList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.Metal3DataClaim)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.Metal3DataClaim, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_Metal3DataClaim_To_v1beta1_Metal3DataClaim(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1328,7 +1334,17 @@ func Convert_v1alpha5_Metal3DataClaimList_To_v1beta1_Metal3DataClaimList(in *Met
 
 func autoConvert_v1beta1_Metal3DataClaimList_To_v1alpha5_Metal3DataClaimList(in *v1beta1.Metal3DataClaimList, out *Metal3DataClaimList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]Metal3DataClaim)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3DataClaim, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Metal3DataClaim_To_v1alpha5_Metal3DataClaim(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1371,17 +1387,24 @@ func Convert_v1alpha5_Metal3DataClaimStatus_To_v1beta1_Metal3DataClaimStatus(in
 func autoConvert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(in *v1beta1.Metal3DataClaimStatus, out *Metal3DataClaimStatus, s conversion.Scope) error {
 	out.RenderedData = (*corev1.ObjectReference)(unsafe.Pointer(in.RenderedData))
 	out.ErrorMessage = (*string)(unsafe.Pointer(in.ErrorMessage))
+	// WARNING: in.IndexAllocation requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus is an autogenerated conversion function.
-func Convert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(in *v1beta1.Metal3DataClaimStatus, out *Metal3DataClaimStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3DataClaimStatus_To_v1alpha5_Metal3DataClaimStatus(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3DataList_To_v1beta1_Metal3DataList(in *Metal3DataList, out *v1beta1.Metal3DataList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.Metal3Data)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.Metal3Data, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_Metal3Data_To_v1beta1_Metal3Data(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1392,7 +1415,17 @@ func Convert_v1alpha5_Metal3DataList_To_v1beta1_Metal3DataList(in *Metal3DataLis
 
 func autoConvert_v1beta1_Metal3DataList_To_v1alpha5_Metal3DataList(in *v1beta1.Metal3DataList, out *Metal3DataList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]Metal3Data)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3Data, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Metal3Data_To_v1alpha5_Metal3Data(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1445,14 +1478,12 @@ func Convert_v1alpha5_Metal3DataStatus_To_v1beta1_Metal3DataStatus(in *Metal3Dat
 func autoConvert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(in *v1beta1.Metal3DataStatus, out *Metal3DataStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
 	out.ErrorMessage = (*string)(unsafe.Pointer(in.ErrorMessage))
+	// WARNING: in.HostRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.RenderedTemplateGeneration requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus is an autogenerated conversion function.
-func Convert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(in *v1beta1.Metal3DataStatus, out *Metal3DataStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3DataStatus_To_v1alpha5_Metal3DataStatus(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3DataTemplate_To_v1beta1_Metal3DataTemplate(in *Metal3DataTemplate, out *v1beta1.Metal3DataTemplate, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha5_Metal3DataTemplateSpec_To_v1beta1_Metal3DataTemplateSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1577,14 +1608,10 @@ func autoConvert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSp
 	} else {
 		out.NetworkData = nil
 	}
+	// WARNING: in.RerenderOnTemplateChange requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec is an autogenerated conversion function.
-func Convert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(in *v1beta1.Metal3DataTemplateSpec, out *Metal3DataTemplateSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3DataTemplateSpec_To_v1alpha5_Metal3DataTemplateSpec(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3DataTemplateStatus_To_v1beta1_Metal3DataTemplateStatus(in *Metal3DataTemplateStatus, out *v1beta1.Metal3DataTemplateStatus, s conversion.Scope) error {
 	out.LastUpdated = (*v1.Time)(unsafe.Pointer(in.LastUpdated))
 	out.Indexes = *(*map[string]int)(unsafe.Pointer(&in.Indexes))
@@ -1599,14 +1626,12 @@ func Convert_v1alpha5_Metal3DataTemplateStatus_To_v1beta1_Metal3DataTemplateStat
 func autoConvert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(in *v1beta1.Metal3DataTemplateStatus, out *Metal3DataTemplateStatus, s conversion.Scope) error {
 	out.LastUpdated = (*v1.Time)(unsafe.Pointer(in.LastUpdated))
 	out.Indexes = *(*map[string]int)(unsafe.Pointer(&in.Indexes))
+	// WARNING: in.LastIndexes requires manual conversion: does not exist in peer-type
+	// WARNING: in.IndexSummary requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus is an autogenerated conversion function.
-func Convert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(in *v1beta1.Metal3DataTemplateStatus, out *Metal3DataTemplateStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3DataTemplateStatus_To_v1alpha5_Metal3DataTemplateStatus(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3Machine_To_v1beta1_Metal3Machine(in *Metal3Machine, out *v1beta1.Metal3Machine, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha5_Metal3MachineSpec_To_v1beta1_Metal3MachineSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1707,6 +1732,7 @@ func autoConvert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in *v1b
 	if err := Convert_v1beta1_Image_To_v1alpha5_Image(&in.Image, &out.Image, s); err != nil {
 		return err
 	}
+	// WARNING: in.CustomDeploy requires manual conversion: does not exist in peer-type
 	out.UserData = (*corev1.SecretReference)(unsafe.Pointer(in.UserData))
 	if err := Convert_v1beta1_HostSelector_To_v1alpha5_HostSelector(&in.HostSelector, &out.HostSelector, s); err != nil {
 		return err
@@ -1715,14 +1741,20 @@ func autoConvert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in *v1b
 	out.MetaData = (*corev1.SecretReference)(unsafe.Pointer(in.MetaData))
 	out.NetworkData = (*corev1.SecretReference)(unsafe.Pointer(in.NetworkData))
 	out.AutomatedCleaningMode = (*string)(unsafe.Pointer(in.AutomatedCleaningMode))
+	// WARNING: in.NodeReuseGroup requires manual conversion: does not exist in peer-type
+	// WARNING: in.Bootstrapless requires manual conversion: does not exist in peer-type
+	// WARNING: in.Metal3DrainTimeout requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostNamespace requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostTolerations requires manual conversion: does not exist in peer-type
+	// WARNING: in.RootDeviceHints requires manual conversion: does not exist in peer-type
+	// WARNING: in.PowerState requires manual conversion: does not exist in peer-type
+	// WARNING: in.UserDataAppend requires manual conversion: does not exist in peer-type
+	// WARNING: in.PowerManagementPolicy requires manual conversion: does not exist in peer-type
+	// WARNING: in.NodeMetadata requires manual conversion: does not exist in peer-type
+	// WARNING: in.FirmwareSettings requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec is an autogenerated conversion function.
-func Convert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in *v1beta1.Metal3MachineSpec, out *Metal3MachineSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3MachineSpec_To_v1alpha5_Metal3MachineSpec(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3MachineStatus_To_v1beta1_Metal3MachineStatus(in *Metal3MachineStatus, out *v1beta1.Metal3MachineStatus, s conversion.Scope) error {
 	out.LastUpdated = (*v1.Time)(unsafe.Pointer(in.LastUpdated))
 	out.FailureReason = (*errors.MachineStatusError)(unsafe.Pointer(in.FailureReason))
@@ -1753,6 +1785,9 @@ func autoConvert_v1beta1_Metal3MachineStatus_To_v1alpha5_Metal3MachineStatus(in
 	out.RenderedData = (*corev1.ObjectReference)(unsafe.Pointer(in.RenderedData))
 	out.MetaData = (*corev1.SecretReference)(unsafe.Pointer(in.MetaData))
 	out.NetworkData = (*corev1.SecretReference)(unsafe.Pointer(in.NetworkData))
+	// WARNING: in.RenderedHost requires manual conversion: does not exist in peer-type
+	// WARNING: in.EstimatedReadyTime requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomain requires manual conversion: does not exist in peer-type
 	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
@@ -1775,17 +1810,23 @@ func autoConvert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate
 	if err := Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(&in.Spec, &out.Spec, s); err != nil {
 		return err
 	}
+	// WARNING: in.Status requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate is an autogenerated conversion function.
-func Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(in *v1beta1.Metal3MachineTemplate, out *Metal3MachineTemplate, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3MachineTemplateList_To_v1beta1_Metal3MachineTemplateList(in *Metal3MachineTemplateList, out *v1beta1.Metal3MachineTemplateList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.Metal3MachineTemplate)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.Metal3MachineTemplate, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_Metal3MachineTemplate_To_v1beta1_Metal3MachineTemplate(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1796,7 +1837,17 @@ func Convert_v1alpha5_Metal3MachineTemplateList_To_v1beta1_Metal3MachineTemplate
 
 func autoConvert_v1beta1_Metal3MachineTemplateList_To_v1alpha5_Metal3MachineTemplateList(in *v1beta1.Metal3MachineTemplateList, out *Metal3MachineTemplateList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]Metal3MachineTemplate)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3MachineTemplate, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Metal3MachineTemplate_To_v1alpha5_Metal3MachineTemplate(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1847,14 +1898,14 @@ func autoConvert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemp
 		return err
 	}
 	out.NodeReuse = in.NodeReuse
+	// WARNING: in.NodeReuseScope requires manual conversion: does not exist in peer-type
+	// WARNING: in.NodeReuseLabelKeys requires manual conversion: does not exist in peer-type
+	// WARNING: in.NodeReuseGracePeriod requires manual conversion: does not exist in peer-type
+	// WARNING: in.UpdateAutomatedCleaningMode requires manual conversion: does not exist in peer-type
+	// WARNING: in.SpreadTopologyKey requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec is an autogenerated conversion function.
-func Convert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(in *v1beta1.Metal3MachineTemplateSpec, out *Metal3MachineTemplateSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3MachineTemplateSpec_To_v1alpha5_Metal3MachineTemplateSpec(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3Remediation_To_v1beta1_Metal3Remediation(in *Metal3Remediation, out *v1beta1.Metal3Remediation, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha5_Metal3RemediationSpec_To_v1beta1_Metal3RemediationSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1889,7 +1940,17 @@ func Convert_v1beta1_Metal3Remediation_To_v1alpha5_Metal3Remediation(in *v1beta1
 
 func autoConvert_v1alpha5_Metal3RemediationList_To_v1beta1_Metal3RemediationList(in *Metal3RemediationList, out *v1beta1.Metal3RemediationList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.Metal3Remediation)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.Metal3Remediation, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_Metal3Remediation_To_v1beta1_Metal3Remediation(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1900,7 +1961,17 @@ func Convert_v1alpha5_Metal3RemediationList_To_v1beta1_Metal3RemediationList(in
 
 func autoConvert_v1beta1_Metal3RemediationList_To_v1alpha5_Metal3RemediationList(in *v1beta1.Metal3RemediationList, out *Metal3RemediationList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]Metal3Remediation)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3Remediation, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Metal3Remediation_To_v1alpha5_Metal3Remediation(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1945,14 +2016,17 @@ func autoConvert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationSt
 	out.Phase = in.Phase
 	out.RetryCount = in.RetryCount
 	out.LastRemediated = (*v1.Time)(unsafe.Pointer(in.LastRemediated))
+	// WARNING: in.LastPhaseTransition requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.ConsumerRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.NodeRemediationMechanism requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomain requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostZone requires manual conversion: does not exist in peer-type
+	// WARNING: in.History requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus is an autogenerated conversion function.
-func Convert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(in *v1beta1.Metal3RemediationStatus, out *Metal3RemediationStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_Metal3RemediationStatus_To_v1alpha5_Metal3RemediationStatus(in, out, s)
-}
-
 func autoConvert_v1alpha5_Metal3RemediationTemplate_To_v1beta1_Metal3RemediationTemplate(in *Metal3RemediationTemplate, out *v1beta1.Metal3RemediationTemplate, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha5_Metal3RemediationTemplateSpec_To_v1beta1_Metal3RemediationTemplateSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1987,7 +2061,17 @@ func Convert_v1beta1_Metal3RemediationTemplate_To_v1alpha5_Metal3RemediationTemp
 
 func autoConvert_v1alpha5_Metal3RemediationTemplateList_To_v1beta1_Metal3RemediationTemplateList(in *Metal3RemediationTemplateList, out *v1beta1.Metal3RemediationTemplateList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.Metal3RemediationTemplate)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.Metal3RemediationTemplate, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_Metal3RemediationTemplate_To_v1beta1_Metal3RemediationTemplate(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1998,7 +2082,17 @@ func Convert_v1alpha5_Metal3RemediationTemplateList_To_v1beta1_Metal3Remediation
 
 func autoConvert_v1beta1_Metal3RemediationTemplateList_To_v1alpha5_Metal3RemediationTemplateList(in *v1beta1.Metal3RemediationTemplateList, out *Metal3RemediationTemplateList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]Metal3RemediationTemplate)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3RemediationTemplate, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Metal3RemediationTemplate_To_v1alpha5_Metal3RemediationTemplate(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -2098,6 +2192,7 @@ func Convert_v1alpha5_NetworkData_To_v1beta1_NetworkData(in *NetworkData, out *v
 }
 
 func autoConvert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in *v1beta1.NetworkData, out *NetworkData, s conversion.Scope) error {
+	// WARNING: in.Format requires manual conversion: does not exist in peer-type
 	if err := Convert_v1beta1_NetworkDataLink_To_v1alpha5_NetworkDataLink(&in.Links, &out.Links, s); err != nil {
 		return err
 	}
@@ -2110,16 +2205,21 @@ func autoConvert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in *v1beta1.Network
 	return nil
 }
 
-// Convert_v1beta1_NetworkData_To_v1alpha5_NetworkData is an autogenerated conversion function.
-func Convert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in *v1beta1.NetworkData, out *NetworkData, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkData_To_v1alpha5_NetworkData(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataIPv4_To_v1beta1_NetworkDataIPv4(in *NetworkDataIPv4, out *v1beta1.NetworkDataIPv4, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
 	out.IPAddressFromIPPool = in.IPAddressFromIPPool
-	out.Routes = *(*[]v1beta1.NetworkDataRoutev4)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]v1beta1.NetworkDataRoutev4, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataRoutev4_To_v1beta1_NetworkDataRoutev4(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
 	return nil
 }
 
@@ -2133,14 +2233,35 @@ func autoConvert_v1beta1_NetworkDataIPv4_To_v1alpha5_NetworkDataIPv4(in *v1beta1
 	out.Link = in.Link
 	out.IPAddressFromIPPool = in.IPAddressFromIPPool
 	// WARNING: in.FromPoolRef requires manual conversion: does not exist in peer-type
-	out.Routes = *(*[]NetworkDataRoutev4)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkDataRoutev4, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
+	// WARNING: in.DefaultRoute requires manual conversion: does not exist in peer-type
 	return nil
 }
 
 func autoConvert_v1alpha5_NetworkDataIPv4DHCP_To_v1beta1_NetworkDataIPv4DHCP(in *NetworkDataIPv4DHCP, out *v1beta1.NetworkDataIPv4DHCP, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
-	out.Routes = *(*[]v1beta1.NetworkDataRoutev4)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]v1beta1.NetworkDataRoutev4, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataRoutev4_To_v1beta1_NetworkDataRoutev4(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
 	return nil
 }
 
@@ -2152,20 +2273,36 @@ func Convert_v1alpha5_NetworkDataIPv4DHCP_To_v1beta1_NetworkDataIPv4DHCP(in *Net
 func autoConvert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(in *v1beta1.NetworkDataIPv4DHCP, out *NetworkDataIPv4DHCP, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
-	out.Routes = *(*[]NetworkDataRoutev4)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkDataRoutev4, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
+	// WARNING: in.DefaultRoute requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(in *v1beta1.NetworkDataIPv4DHCP, out *NetworkDataIPv4DHCP, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataIPv6_To_v1beta1_NetworkDataIPv6(in *NetworkDataIPv6, out *v1beta1.NetworkDataIPv6, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
 	out.IPAddressFromIPPool = in.IPAddressFromIPPool
-	out.Routes = *(*[]v1beta1.NetworkDataRoutev6)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]v1beta1.NetworkDataRoutev6, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataRoutev6_To_v1beta1_NetworkDataRoutev6(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
 	return nil
 }
 
@@ -2179,14 +2316,35 @@ func autoConvert_v1beta1_NetworkDataIPv6_To_v1alpha5_NetworkDataIPv6(in *v1beta1
 	out.Link = in.Link
 	out.IPAddressFromIPPool = in.IPAddressFromIPPool
 	// WARNING: in.FromPoolRef requires manual conversion: does not exist in peer-type
-	out.Routes = *(*[]NetworkDataRoutev6)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkDataRoutev6, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
+	// WARNING: in.DefaultRoute requires manual conversion: does not exist in peer-type
 	return nil
 }
 
 func autoConvert_v1alpha5_NetworkDataIPv6DHCP_To_v1beta1_NetworkDataIPv6DHCP(in *NetworkDataIPv6DHCP, out *v1beta1.NetworkDataIPv6DHCP, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
-	out.Routes = *(*[]v1beta1.NetworkDataRoutev6)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]v1beta1.NetworkDataRoutev6, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataRoutev6_To_v1beta1_NetworkDataRoutev6(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
 	return nil
 }
 
@@ -2198,19 +2356,55 @@ func Convert_v1alpha5_NetworkDataIPv6DHCP_To_v1beta1_NetworkDataIPv6DHCP(in *Net
 func autoConvert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(in *v1beta1.NetworkDataIPv6DHCP, out *NetworkDataIPv6DHCP, s conversion.Scope) error {
 	out.ID = in.ID
 	out.Link = in.Link
-	out.Routes = *(*[]NetworkDataRoutev6)(unsafe.Pointer(&in.Routes))
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkDataRoutev6, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Routes = nil
+	}
+	// WARNING: in.DefaultRoute requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(in *v1beta1.NetworkDataIPv6DHCP, out *NetworkDataIPv6DHCP, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataLink_To_v1beta1_NetworkDataLink(in *NetworkDataLink, out *v1beta1.NetworkDataLink, s conversion.Scope) error {
-	out.Ethernets = *(*[]v1beta1.NetworkDataLinkEthernet)(unsafe.Pointer(&in.Ethernets))
-	out.Bonds = *(*[]v1beta1.NetworkDataLinkBond)(unsafe.Pointer(&in.Bonds))
-	out.Vlans = *(*[]v1beta1.NetworkDataLinkVlan)(unsafe.Pointer(&in.Vlans))
+	if in.Ethernets != nil {
+		in, out := &in.Ethernets, &out.Ethernets
+		*out = make([]v1beta1.NetworkDataLinkEthernet, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataLinkEthernet_To_v1beta1_NetworkDataLinkEthernet(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Ethernets = nil
+	}
+	if in.Bonds != nil {
+		in, out := &in.Bonds, &out.Bonds
+		*out = make([]v1beta1.NetworkDataLinkBond, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataLinkBond_To_v1beta1_NetworkDataLinkBond(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Bonds = nil
+	}
+	if in.Vlans != nil {
+		in, out := &in.Vlans, &out.Vlans
+		*out = make([]v1beta1.NetworkDataLinkVlan, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataLinkVlan_To_v1beta1_NetworkDataLinkVlan(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Vlans = nil
+	}
 	return nil
 }
 
@@ -2220,9 +2414,39 @@ func Convert_v1alpha5_NetworkDataLink_To_v1beta1_NetworkDataLink(in *NetworkData
 }
 
 func autoConvert_v1beta1_NetworkDataLink_To_v1alpha5_NetworkDataLink(in *v1beta1.NetworkDataLink, out *NetworkDataLink, s conversion.Scope) error {
-	out.Ethernets = *(*[]NetworkDataLinkEthernet)(unsafe.Pointer(&in.Ethernets))
-	out.Bonds = *(*[]NetworkDataLinkBond)(unsafe.Pointer(&in.Bonds))
-	out.Vlans = *(*[]NetworkDataLinkVlan)(unsafe.Pointer(&in.Vlans))
+	if in.Ethernets != nil {
+		in, out := &in.Ethernets, &out.Ethernets
+		*out = make([]NetworkDataLinkEthernet, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Ethernets = nil
+	}
+	if in.Bonds != nil {
+		in, out := &in.Bonds, &out.Bonds
+		*out = make([]NetworkDataLinkBond, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Bonds = nil
+	}
+	if in.Vlans != nil {
+		in, out := &in.Vlans, &out.Vlans
+		*out = make([]NetworkDataLinkVlan, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Vlans = nil
+	}
 	return nil
 }
 
@@ -2251,14 +2475,10 @@ func autoConvert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(in
 	out.MTU = in.MTU
 	out.MACAddress = (*NetworkLinkEthernetMac)(unsafe.Pointer(in.MACAddress))
 	out.BondLinks = *(*[]string)(unsafe.Pointer(&in.BondLinks))
+	// WARNING: in.DefaultRoutePriority requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(in *v1beta1.NetworkDataLinkBond, out *NetworkDataLinkBond, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataLinkBond_To_v1alpha5_NetworkDataLinkBond(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataLinkEthernet_To_v1beta1_NetworkDataLinkEthernet(in *NetworkDataLinkEthernet, out *v1beta1.NetworkDataLinkEthernet, s conversion.Scope) error {
 	out.Type = in.Type
 	out.Id = in.Id
@@ -2277,14 +2497,11 @@ func autoConvert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthe
 	out.Id = in.Id
 	out.MTU = in.MTU
 	out.MACAddress = (*NetworkLinkEthernetMac)(unsafe.Pointer(in.MACAddress))
+	// WARNING: in.VendorExtensions requires manual conversion: does not exist in peer-type
+	// WARNING: in.DefaultRoutePriority requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(in *v1beta1.NetworkDataLinkEthernet, out *NetworkDataLinkEthernet, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataLinkEthernet_To_v1alpha5_NetworkDataLinkEthernet(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataLinkVlan_To_v1beta1_NetworkDataLinkVlan(in *NetworkDataLinkVlan, out *v1beta1.NetworkDataLinkVlan, s conversion.Scope) error {
 	out.VlanID = in.VlanID
 	out.Id = in.Id
@@ -2305,14 +2522,10 @@ func autoConvert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(in
 	out.MTU = in.MTU
 	out.MACAddress = (*NetworkLinkEthernetMac)(unsafe.Pointer(in.MACAddress))
 	out.VlanLink = in.VlanLink
+	// WARNING: in.DefaultRoutePriority requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(in *v1beta1.NetworkDataLinkVlan, out *NetworkDataLinkVlan, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataLinkVlan_To_v1alpha5_NetworkDataLinkVlan(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataNetwork_To_v1beta1_NetworkDataNetwork(in *NetworkDataNetwork, out *v1beta1.NetworkDataNetwork, s conversion.Scope) error {
 	if in.IPv4 != nil {
 		in, out := &in.IPv4, &out.IPv4
@@ -2336,9 +2549,39 @@ func autoConvert_v1alpha5_NetworkDataNetwork_To_v1beta1_NetworkDataNetwork(in *N
 	} else {
 		out.IPv6 = nil
 	}
-	out.IPv4DHCP = *(*[]v1beta1.NetworkDataIPv4DHCP)(unsafe.Pointer(&in.IPv4DHCP))
-	out.IPv6DHCP = *(*[]v1beta1.NetworkDataIPv6DHCP)(unsafe.Pointer(&in.IPv6DHCP))
-	out.IPv6SLAAC = *(*[]v1beta1.NetworkDataIPv6DHCP)(unsafe.Pointer(&in.IPv6SLAAC))
+	if in.IPv4DHCP != nil {
+		in, out := &in.IPv4DHCP, &out.IPv4DHCP
+		*out = make([]v1beta1.NetworkDataIPv4DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataIPv4DHCP_To_v1beta1_NetworkDataIPv4DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv4DHCP = nil
+	}
+	if in.IPv6DHCP != nil {
+		in, out := &in.IPv6DHCP, &out.IPv6DHCP
+		*out = make([]v1beta1.NetworkDataIPv6DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataIPv6DHCP_To_v1beta1_NetworkDataIPv6DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv6DHCP = nil
+	}
+	if in.IPv6SLAAC != nil {
+		in, out := &in.IPv6SLAAC, &out.IPv6SLAAC
+		*out = make([]v1beta1.NetworkDataIPv6DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha5_NetworkDataIPv6DHCP_To_v1beta1_NetworkDataIPv6DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv6SLAAC = nil
+	}
 	return nil
 }
 
@@ -2370,9 +2613,39 @@ func autoConvert_v1beta1_NetworkDataNetwork_To_v1alpha5_NetworkDataNetwork(in *v
 	} else {
 		out.IPv6 = nil
 	}
-	out.IPv4DHCP = *(*[]NetworkDataIPv4DHCP)(unsafe.Pointer(&in.IPv4DHCP))
-	out.IPv6DHCP = *(*[]NetworkDataIPv6DHCP)(unsafe.Pointer(&in.IPv6DHCP))
-	out.IPv6SLAAC = *(*[]NetworkDataIPv6DHCP)(unsafe.Pointer(&in.IPv6SLAAC))
+	if in.IPv4DHCP != nil {
+		in, out := &in.IPv4DHCP, &out.IPv4DHCP
+		*out = make([]NetworkDataIPv4DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataIPv4DHCP_To_v1alpha5_NetworkDataIPv4DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv4DHCP = nil
+	}
+	if in.IPv6DHCP != nil {
+		in, out := &in.IPv6DHCP, &out.IPv6DHCP
+		*out = make([]NetworkDataIPv6DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv6DHCP = nil
+	}
+	if in.IPv6SLAAC != nil {
+		in, out := &in.IPv6SLAAC, &out.IPv6SLAAC
+		*out = make([]NetworkDataIPv6DHCP, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDataIPv6DHCP_To_v1alpha5_NetworkDataIPv6DHCP(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.IPv6SLAAC = nil
+	}
 	return nil
 }
 
@@ -2407,14 +2680,11 @@ func autoConvert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(in *v
 	if err := Convert_v1beta1_NetworkDataServicev4_To_v1alpha5_NetworkDataServicev4(&in.Services, &out.Services, s); err != nil {
 		return err
 	}
+	// WARNING: in.Metric requires manual conversion: does not exist in peer-type
+	// WARNING: in.Table requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4 is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(in *v1beta1.NetworkDataRoutev4, out *NetworkDataRoutev4, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataRoutev4_To_v1alpha5_NetworkDataRoutev4(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataRoutev6_To_v1beta1_NetworkDataRoutev6(in *NetworkDataRoutev6, out *v1beta1.NetworkDataRoutev6, s conversion.Scope) error {
 	out.Network = v1alpha1.IPAddressv6Str(in.Network)
 	out.Prefix = in.Prefix
@@ -2441,14 +2711,11 @@ func autoConvert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(in *v
 	if err := Convert_v1beta1_NetworkDataServicev6_To_v1alpha5_NetworkDataServicev6(&in.Services, &out.Services, s); err != nil {
 		return err
 	}
+	// WARNING: in.Metric requires manual conversion: does not exist in peer-type
+	// WARNING: in.Table requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6 is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(in *v1beta1.NetworkDataRoutev6, out *NetworkDataRoutev6, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDataRoutev6_To_v1alpha5_NetworkDataRoutev6(in, out, s)
-}
-
 func autoConvert_v1alpha5_NetworkDataService_To_v1beta1_NetworkDataService(in *NetworkDataService, out *v1beta1.NetworkDataService, s conversion.Scope) error {
 	out.DNS = *(*[]v1alpha1.IPAddressStr)(unsafe.Pointer(&in.DNS))
 	out.DNSFromIPPool = (*string)(unsafe.Pointer(in.DNSFromIPPool))
@@ -18,7 +18,10 @@ package v1beta1
 
 import (
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -28,7 +31,90 @@ const (
 	// UnhealthyAnnotation is the annotation that sets unhealthy status of BMH.
 	UnhealthyAnnotation = "capi.metal3.io/unhealthy"
 
+	// PausedAnnotation is the annotation that pauses the reconciliation of a
+	// single CAPM3 object, without pausing the whole cluster.
+	PausedAnnotation = "capm3.metal3.io/paused"
+
+	// HostLastReleasedAnnotation is set on a BareMetalHost to the time, in
+	// RFC 3339 format, at which it was last released by a Metal3Machine.
+	HostLastReleasedAnnotation = "capm3.metal3.io/last-released"
+
+	// HostProvisionCountAnnotation is set on a BareMetalHost to the number of
+	// times it was provisioned by a Metal3Machine.
+	HostProvisionCountAnnotation = "capm3.metal3.io/provision-count"
+
+	// HostTaintsAnnotation is set on a BareMetalHost to a comma-separated list
+	// of taint keys. The host is only chosen by the Metal3Machines tolerating
+	// all of them in their hostTolerations.
+	HostTaintsAnnotation = "capm3.metal3.io/taints"
+
+	// HostRootDeviceHintsAnnotation is set on a BareMetalHost whose
+	// rootDeviceHints were set from the Metal3Machine, so that they are
+	// cleared when the host is released.
+	HostRootDeviceHintsAnnotation = "capm3.metal3.io/root-device-hints"
+
+	// HostReverseSyncedLabelsAnnotation is set on a BareMetalHost to the
+	// comma-separated list of the labels copied from its Node by the reverse
+	// label sync, so that they are removed when the host is released.
+	HostReverseSyncedLabelsAnnotation = "capm3.metal3.io/reverse-synced-labels"
+
+	// FirmwareSettingsAnnotation is set on the HostFirmwareSettings of a
+	// BareMetalHost whose settings were set from the Metal3Machine. It holds
+	// the previous values of these settings in JSON, null for a setting that
+	// was not set, restored when the host is released.
+	FirmwareSettingsAnnotation = "capm3.metal3.io/previous-firmware-settings"
+
+	// HostNodeReuseSinceAnnotation is set on a BareMetalHost to the time, in
+	// RFC 3339 format, since which it carries the node reuse label. The label
+	// may be removed once it is older than the node reuse label TTL.
+	HostNodeReuseSinceAnnotation = "capm3.metal3.io/node-reuse-since"
+
+	// HostNodeReuseTemplateAnnotation is set on a BareMetalHost released with
+	// the node reuse label to the name of the Metal3MachineTemplate of the
+	// Metal3Machine that released it, which reports its reservation.
+	HostNodeReuseTemplateAnnotation = "capm3.metal3.io/node-reuse-template"
+
+	// HostNodeReuseGracePeriodAnnotation is set on a BareMetalHost released
+	// with the node reuse label to the nodeReuseGracePeriod of the
+	// Metal3MachineTemplate, in Go duration format. It overrides the node
+	// reuse label TTL for the host.
+	HostNodeReuseGracePeriodAnnotation = "capm3.metal3.io/node-reuse-grace-period"
+
+	// PreferDeleteLabel is set by users on a BareMetalHost to have the
+	// Machine consuming it deleted first when its MachineSet scales in.
+	PreferDeleteLabel = "infrastructure.cluster.x-k8s.io/prefer-delete"
+
+	// DeleteMachineAnnotationPrefix prefixes the value of the
+	// cluster.x-k8s.io/delete-machine annotation set on the Machine of an
+	// unhealthy BareMetalHost, followed by the reason. Only the annotations
+	// with this prefix are removed when the host recovers.
+	DeleteMachineAnnotationPrefix = "capm3:"
+
+	// BareMetalHostLabel is set to the name of the BareMetalHost of a
+	// Metal3Machine on its Metal3Data, Metal3DataClaim, IP claims and
+	// rendered secrets.
+	BareMetalHostLabel = "capm3.metal3.io/baremetalhost"
+
+	// DataSecretsOwnerLabel is set on a BareMetalHost to the name of the
+	// Metal3Data owning the metaData and networkData secrets it references.
+	DataSecretsOwnerLabel = "capm3.metal3.io/data-secrets-owner"
+
+	// DataNameLabel is set to the name of the Metal3Data on the metaData and
+	// networkData secrets rendered for it, to find them whatever their name.
+	DataNameLabel = "infrastructure.cluster.x-k8s.io/data-name"
+
 	LiveISODiskFormat = "live-iso"
+
+	// CloudInitUserDataFormat is the userDataFormat of an image booting with
+	// cloud-init.
+	CloudInitUserDataFormat = "cloud-init"
+	// IgnitionUserDataFormat is the userDataFormat of an image booting with
+	// Ignition.
+	IgnitionUserDataFormat = "ignition"
+
+	// UserDataAppendDefaultKey is the key of the template in the ConfigMap
+	// referenced by the userDataAppend of a Metal3Machine, when unset.
+	UserDataAppendDefaultKey = "userData"
 )
 
 // APIEndpoint represents a reachable Kubernetes API endpoint.
@@ -51,6 +137,67 @@ type HostSelector struct {
 	// Label match expressions that must be true on a chosen BareMetalHost
 	// +optional
 	MatchExpressions []HostSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// MatchHardware is the hardware, reported by the inspection of the
+	// BareMetalHost, that a chosen BareMetalHost must have. The hosts not
+	// inspected yet are not chosen.
+	// +optional
+	MatchHardware *HardwareSelector `json:"matchHardware,omitempty"`
+
+	// Preferences are the weighted preferences among the BareMetalHosts
+	// matching the other criteria. Each host is scored with the sum of the
+	// weights of the preferences it matches, and a host with the highest score
+	// is chosen, the hostSelectionPolicy picking among hosts of equal score. A
+	// host matching no preference is still chosen when no other host is
+	// available.
+	// +optional
+	Preferences []HostSelectorPreference `json:"preferences,omitempty"`
+}
+
+// HostSelectorPreference is a weighted preference for the BareMetalHosts
+// matching all its criteria.
+type HostSelectorPreference struct {
+	// Weight is added to the score of the hosts matching the preference.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+
+	// Label match expressions that must be true on a preferred BareMetalHost
+	// +optional
+	MatchExpressions []HostSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// MatchHardware is the inspected hardware of a preferred BareMetalHost.
+	// The hosts not inspected yet do not match it.
+	// +optional
+	MatchHardware *HardwareSelector `json:"matchHardware,omitempty"`
+}
+
+// HardwareSelector specifies matching criteria for the inspected hardware of
+// BareMetalHosts. The unset criteria match any host.
+type HardwareSelector struct {
+	// MinCPU is the minimum number of CPUs.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCPU int `json:"minCPU,omitempty"`
+
+	// CPUArch is the CPU architecture, e.g. x86_64 or aarch64.
+	// +optional
+	CPUArch string `json:"cpuArch,omitempty"`
+
+	// MinRAMGiB is the minimum RAM, in GiB.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinRAMGiB int `json:"minRAMGiB,omitempty"`
+
+	// MinDiskGiB is the minimum size, in GiB, of the largest disk.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinDiskGiB int `json:"minDiskGiB,omitempty"`
+
+	// NICCount is the minimum number of NICs.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NICCount int `json:"nicCount,omitempty"`
 }
 
 type HostSelectorRequirement struct {
@@ -61,14 +208,21 @@ type HostSelectorRequirement struct {
 
 // Image holds the details of an image to use during provisioning.
 type Image struct {
-	// URL is a location of an image to deploy.
+	// URL is a location of an image to deploy. The URL of a live-iso image
+	// can be a Go text/template rendered for each machine with the fields of
+	// the metadata templates, e.g.
+	// `http://172.22.0.1/isos/{{ .BareMetalHostName }}.iso`, so that the
+	// machines of a template boot their own ISO.
 	URL string `json:"url"`
 
 	// Checksum is a md5sum, sha256sum or sha512sum value or a URL to retrieve one.
+	// The URL may point to a file listing the checksums of several images,
+	// e.g. a SHA256SUMS file, the checksum of the image is found by its name.
 	Checksum string `json:"checksum"`
 
 	// ChecksumType is the checksum algorithm for the image.
-	// e.g md5, sha256, sha512
+	// e.g md5, sha256, sha512. When unset, it is inferred from the length of
+	// a checksum given as a value, 32, 64 or 128 hexadecimal digits.
 	// +kubebuilder:validation:Enum=md5;sha256;sha512
 	// +optional
 	ChecksumType *string `json:"checksumType,omitempty"`
@@ -77,6 +231,139 @@ type Image struct {
 	// +kubebuilder:validation:Enum=raw;qcow2;vdi;vmdk;live-iso
 	// +optional
 	DiskFormat *string `json:"format,omitempty"`
+
+	// UserDataFormat is the format of the user data expected by the image,
+	// cloud-init or ignition. When set, the provisioning is refused if the
+	// bootstrap data is in another format.
+	// +kubebuilder:validation:Enum=cloud-init;ignition
+	// +optional
+	UserDataFormat *string `json:"userDataFormat,omitempty"`
+}
+
+// RootDeviceHints holds the hints for choosing the root device of a
+// BareMetalHost, mirroring the rootDeviceHints of the BareMetalHost.
+type RootDeviceHints struct {
+	// A Linux device name like "/dev/vda", or a by-path link to it like
+	// "/dev/disk/by-path/pci-0000:01:00.0-scsi-0:2:0:0". The hint must match
+	// the actual value exactly.
+	// +optional
+	DeviceName string `json:"deviceName,omitempty"`
+
+	// A SCSI bus address like 0:0:0:0. The hint must match the actual
+	// value exactly.
+	// +optional
+	HCTL string `json:"hctl,omitempty"`
+
+	// A vendor-specific device identifier. The hint can be a
+	// substring of the actual value.
+	// +optional
+	Model string `json:"model,omitempty"`
+
+	// The name of the vendor or manufacturer of the device. The hint
+	// can be a substring of the actual value.
+	// +optional
+	Vendor string `json:"vendor,omitempty"`
+
+	// Device serial number. The hint must match the actual value
+	// exactly.
+	// +optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// The minimum size of the device in Gigabytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinSizeGigabytes int `json:"minSizeGigabytes,omitempty"`
+
+	// Unique storage identifier. The hint must match the actual value
+	// exactly.
+	// +optional
+	WWN string `json:"wwn,omitempty"`
+
+	// Unique storage identifier with the vendor extension
+	// appended. The hint must match the actual value exactly.
+	// +optional
+	WWNWithExtension string `json:"wwnWithExtension,omitempty"`
+
+	// Unique vendor storage identifier. The hint must match the
+	// actual value exactly.
+	// +optional
+	WWNVendorExtension string `json:"wwnVendorExtension,omitempty"`
+
+	// True if the device should use spinning media, false otherwise.
+	// +optional
+	Rotational *bool `json:"rotational,omitempty"`
+}
+
+// CustomDeploy is the custom deploy method run by the deploy ramdisk instead of
+// writing an image.
+type CustomDeploy struct {
+	// Method is the custom deploy method to use, it must be supported by the
+	// deploy ramdisk.
+	// +kubebuilder:validation:MinLength=1
+	Method string `json:"method"`
+}
+
+// checksumTypeLengths are the lengths, in hexadecimal digits, of the
+// checksums of each checksum type.
+var checksumTypeLengths = map[string]int{
+	"md5":    32,
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// IsChecksumURL returns true if the checksum is a URL to retrieve the checksum
+// from, rather than the checksum value.
+func IsChecksumURL(checksum string) bool {
+	return strings.Contains(checksum, "://")
+}
+
+// DigestChecksumType returns the checksum type of a checksum value, md5,
+// sha256 or sha512, inferred from its length, or an empty string if the
+// checksum is not a hexadecimal value of one of those lengths.
+func DigestChecksumType(checksum string) string {
+	if !isHexDigest(checksum) {
+		return ""
+	}
+	for checksumType, length := range checksumTypeLengths {
+		if len(checksum) == length {
+			return checksumType
+		}
+	}
+	return ""
+}
+
+func isHexDigest(checksum string) bool {
+	if checksum == "" {
+		return false
+	}
+	for _, c := range checksum {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// EffectiveChecksumType returns the ChecksumType of the image, or when unset
+// the type inferred from the length of a checksum value. It is empty for a
+// checksum URL without ChecksumType.
+func (i *Image) EffectiveChecksumType() string {
+	if i.ChecksumType != nil && *i.ChecksumType != "" {
+		return *i.ChecksumType
+	}
+	if IsChecksumURL(i.Checksum) {
+		return ""
+	}
+	return DigestChecksumType(i.Checksum)
+}
+
+// templateActions matches the actions of a Go text/template.
+var templateActions = regexp.MustCompile(`{{.*?}}`)
+
+// IsURLTemplate returns whether the URL of the image is a Go text/template
+// rendered for each machine.
+func (i *Image) IsURLTemplate() bool {
+	return strings.Contains(i.URL, "{{")
 }
 
 // Validate performs validation on [Image], returning a list of field errors using the provided base path.
@@ -84,12 +371,37 @@ type Image struct {
 func (i *Image) Validate(base field.Path) field.ErrorList {
 	var errors field.ErrorList
 
+	imageURL := i.URL
+	if i.IsURLTemplate() {
+		if i.DiskFormat == nil || *i.DiskFormat != LiveISODiskFormat {
+			errors = append(errors, field.Invalid(base.Child("URL"), i.URL,
+				"templates are only supported for live-iso images"))
+		} else if _, err := template.New("url").Parse(i.URL); err != nil {
+			errors = append(errors, field.Invalid(base.Child("URL"), i.URL, err.Error()))
+		}
+		// The URL is validated with the actions replaced by a rendered
+		// value.
+		imageURL = templateActions.ReplaceAllString(i.URL, "x")
+	}
 	if i.URL == "" {
 		errors = append(errors, field.Required(base.Child("URL"), "cannot be empty"))
 	} else {
-		_, err := url.ParseRequestURI(i.URL)
+		imageURL, err := url.ParseRequestURI(imageURL)
 		if err != nil {
 			errors = append(errors, field.Invalid(base.Child("URL"), i.URL, "not a valid URL"))
+		} else if imageURL.Scheme != "http" && imageURL.Scheme != "https" && imageURL.Scheme != "file" {
+			errors = append(errors, field.NotSupported(base.Child("URL"), imageURL.Scheme+"://",
+				[]string{"http://", "https://", "file://"},
+			))
+		}
+	}
+	if i.ChecksumType != nil {
+		switch *i.ChecksumType {
+		case "", "md5", "sha256", "sha512":
+		default:
+			errors = append(errors, field.NotSupported(base.Child("ChecksumType"), *i.ChecksumType,
+				[]string{"md5", "sha256", "sha512"},
+			))
 		}
 	}
 	// Checksum is not required for live-iso.
@@ -103,6 +415,34 @@ func (i *Image) Validate(base field.Path) field.ErrorList {
 			if err != nil {
 				errors = append(errors, field.Invalid(base.Child("Checksum"), i.Checksum, "not a valid URL"))
 			}
+		} else if i.Checksum != "" && !IsChecksumURL(i.Checksum) {
+			errors = append(errors, i.validateChecksumValue(base)...)
+		}
+	}
+	if i.UserDataFormat != nil && *i.UserDataFormat != CloudInitUserDataFormat && *i.UserDataFormat != IgnitionUserDataFormat {
+		errors = append(errors, field.NotSupported(base.Child("UserDataFormat"), *i.UserDataFormat,
+			[]string{CloudInitUserDataFormat, IgnitionUserDataFormat},
+		))
+	}
+	return errors
+}
+
+// validateChecksumValue validates a checksum given as a value rather than a
+// URL, it must be a md5, sha256 or sha512 hexadecimal value, of the length of
+// the ChecksumType if set.
+func (i *Image) validateChecksumValue(base field.Path) field.ErrorList {
+	var errors field.ErrorList
+
+	digestType := DigestChecksumType(i.Checksum)
+	if digestType == "" {
+		return append(errors, field.Invalid(base.Child("Checksum"), i.Checksum,
+			"must be a URL or a md5, sha256 or sha512 hexadecimal value of 32, 64 or 128 digits"))
+	}
+	if i.ChecksumType != nil && *i.ChecksumType != "" && *i.ChecksumType != digestType {
+		if length, ok := checksumTypeLengths[*i.ChecksumType]; ok {
+			errors = append(errors, field.Invalid(base.Child("Checksum"), i.Checksum,
+				"is a "+digestType+" value but checksumType is "+*i.ChecksumType+
+					", which expects "+strconv.Itoa(length)+" hexadecimal digits"))
 		}
 	}
 	return errors
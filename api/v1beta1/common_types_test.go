@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -25,6 +26,11 @@ import (
 
 func TestImageValidate(t *testing.T) {
 	diskFormat := LiveISODiskFormat
+	ignition := IgnitionUserDataFormat
+	invalidUserDataFormat := "cloud-config"
+	sha512 := "sha512"
+	sha255 := "sha255"
+	emptyChecksumType := ""
 	cases := []struct {
 		Image         Image
 		ErrorExpected bool
@@ -97,6 +103,150 @@ func TestImageValidate(t *testing.T) {
 			ErrorExpected: false,
 			Name:          "Valid spec with live-iso diskFormat",
 		},
+		{
+			Image: Image{
+				URL:        "http://172.22.0.1/isos/{{ .BareMetalHostName }}.iso",
+				DiskFormat: &diskFormat,
+			},
+			ErrorExpected: false,
+			Name:          "Valid live-iso Image.URL template",
+		},
+		{
+			Image: Image{
+				URL:        "http://172.22.0.1/isos/{{ .BareMetalHostName }.iso",
+				DiskFormat: &diskFormat,
+			},
+			ErrorExpected: true,
+			Name:          "Invalid live-iso Image.URL template",
+		},
+		{
+			Image: Image{
+				URL:        "{{ .BareMetalHostLabels.iso }}",
+				DiskFormat: &diskFormat,
+			},
+			ErrorExpected: true,
+			Name:          "Live-iso Image.URL template without scheme",
+		},
+		{
+			Image: Image{
+				URL:      "http://172.22.0.1/images/{{ .BareMetalHostName }}.qcow2",
+				Checksum: "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef",
+			},
+			ErrorExpected: true,
+			Name:          "Image.URL template of a disk image",
+		},
+		{
+			Image: Image{
+				URL:            "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:       "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.sha256sum",
+				UserDataFormat: &ignition,
+			},
+			ErrorExpected: false,
+			Name:          "Valid Image.UserDataFormat",
+		},
+		{
+			Image: Image{
+				URL:            "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:       "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.sha256sum",
+				UserDataFormat: &invalidUserDataFormat,
+			},
+			ErrorExpected: true,
+			Name:          "Invalid Image.UserDataFormat",
+		},
+		{
+			Image: Image{
+				URL:      "ftp://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum: "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef",
+			},
+			ErrorExpected: true,
+			Name:          "Unsupported Image.URL scheme",
+		},
+		{
+			Image: Image{
+				URL:      "file:///images/rhcos-ootpa-latest.qcow2",
+				Checksum: "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef",
+			},
+			ErrorExpected: false,
+			Name:          "Valid Image.URL file scheme",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.sha512sum",
+				ChecksumType: &sha512,
+			},
+			ErrorExpected: false,
+			Name:          "Valid Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.sha256sum",
+				ChecksumType: &emptyChecksumType,
+			},
+			ErrorExpected: false,
+			Name:          "Empty Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     "http://172.22.0.1/images/rhcos-ootpa-latest.qcow2.sha256sum",
+				ChecksumType: &sha255,
+			},
+			ErrorExpected: true,
+			Name:          "Invalid Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:      "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum: "9f8e52de5bc4f4d9b6a6a9e4b2e3b8c1",
+			},
+			ErrorExpected: false,
+			Name:          "Valid Image with Image.Checksum as md5 sum",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     strings.Repeat("0a1B", 32),
+				ChecksumType: &sha512,
+			},
+			ErrorExpected: false,
+			Name:          "Valid Image with Image.Checksum as sha512 sum matching Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef",
+				ChecksumType: &sha512,
+			},
+			ErrorExpected: true,
+			Name:          "Image.Checksum as sha256 sum not matching Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:          "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum:     "http://172.22.0.1/images/SHA256SUMS",
+				ChecksumType: &sha512,
+			},
+			ErrorExpected: false,
+			Name:          "Image.Checksum as URL is not checked against Image.ChecksumType",
+		},
+		{
+			Image: Image{
+				URL:      "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum: "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384e",
+			},
+			ErrorExpected: true,
+			Name:          "Image.Checksum of unknown length",
+		},
+		{
+			Image: Image{
+				URL:      "https://172.22.0.1/images/rhcos-ootpa-latest.qcow2",
+				Checksum: "g7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef",
+			},
+			ErrorExpected: true,
+			Name:          "Image.Checksum not hexadecimal",
+		},
 	}
 
 	for _, tc := range cases {
@@ -111,3 +261,45 @@ func TestImageValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestImageEffectiveChecksumType(t *testing.T) {
+	sha256 := "sha256"
+	cases := []struct {
+		Image    Image
+		Expected string
+		Name     string
+	}{
+		{
+			Image:    Image{Checksum: "9f8e52de5bc4f4d9b6a6a9e4b2e3b8c1"},
+			Expected: "md5",
+			Name:     "md5 sum",
+		},
+		{
+			Image:    Image{Checksum: "f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef"},
+			Expected: "sha256",
+			Name:     "sha256 sum",
+		},
+		{
+			Image:    Image{Checksum: strings.Repeat("0a1b", 32)},
+			Expected: "sha512",
+			Name:     "sha512 sum",
+		},
+		{
+			Image:    Image{Checksum: "http://172.22.0.1/images/SHA256SUMS"},
+			Expected: "",
+			Name:     "URL without ChecksumType",
+		},
+		{
+			Image:    Image{Checksum: "http://172.22.0.1/images/SHA256SUMS", ChecksumType: &sha256},
+			Expected: "sha256",
+			Name:     "URL with ChecksumType",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(tc.Image.EffectiveChecksumType()).To(Equal(tc.Expected))
+		})
+	}
+}
@@ -18,6 +18,13 @@ package v1beta1
 
 import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
+// Conditions common to the CAPM3 objects.
+const (
+	// PausedCondition is true when the reconciliation of the object is paused,
+	// either with the Cluster or with the PausedAnnotation on the object.
+	PausedCondition clusterv1.ConditionType = "Paused"
+)
+
 // Metal3Cluster Conditions and Reasons.
 const (
 	// BaremetalInfrastructureReadyCondition reports the current status of
@@ -26,13 +33,157 @@ const (
 	BaremetalInfrastructureReadyCondition clusterv1.ConditionType = "BaremetalInfrastructureReady"
 	// ControlPlaneEndpointFailedReason is used to indicate that provided ControlPlaneEndpoint is invalid.
 	ControlPlaneEndpointFailedReason = "ControlPlaneEndpointFailed"
+	// WaitingForControlPlaneEndpointReason is used while the ControlPlaneEndpoint
+	// is allocated from the pool referenced by controlPlaneEndpointFromPool.
+	WaitingForControlPlaneEndpointReason = "WaitingForControlPlaneEndpoint"
 	// InternalFailureReason is used to indicate that an internal failure
 	// occurred. The `Message` field of the Condition should be consluted for
 	// details on the failure.
 	InternalFailureReason = "InternalFailureOccured"
 )
 
+// BareMetalHost Reasons.
+const (
+	// NodeReuseLabelExpiredReason is the reason of the event emitted when the
+	// node reuse label of an available BareMetalHost is removed, after it was
+	// kept longer than the node reuse label TTL while its owner did not need
+	// the host.
+	NodeReuseLabelExpiredReason = "NodeReuseLabelExpired"
+)
+
+// Metal3Data Conditions and Reasons.
+//
+// The Ready condition of the Metal3Data summarizes SecretsRenderedCondition.
+const (
+	// SecretsRenderedCondition is true once the metaData and networkData
+	// secrets of the Metal3Data are rendered.
+	SecretsRenderedCondition clusterv1.ConditionType = "SecretsRendered"
+	// WaitingForMetal3DataTemplateReason (Severity=Info) is used while the
+	// Metal3DataTemplate of the Metal3Data is not found.
+	WaitingForMetal3DataTemplateReason = "WaitingForMetal3DataTemplate"
+	// DataTemplateNotSetReason (Severity=Error) is used when the Metal3Data
+	// references no Metal3DataTemplate, its secrets can not be rendered.
+	DataTemplateNotSetReason = "DataTemplateNotSet"
+	// WaitingForRenderingInputsReason (Severity=Info) is used while the
+	// Machine, the BareMetalHost or the IP addresses needed to render the
+	// secrets are not available. The message gives the missing input.
+	WaitingForRenderingInputsReason = "WaitingForRenderingInputs"
+	// SecretsRenderingFailedReason is used when the secrets could not be
+	// rendered, with the Warning severity when the rendering is retried and
+	// the Error severity when it is not.
+	SecretsRenderingFailedReason = "SecretsRenderingFailed"
+	// HostDataInUseCondition is true while the deletion of the Metal3Data
+	// waits for a BareMetalHost still referencing its secrets to release
+	// them.
+	HostDataInUseCondition clusterv1.ConditionType = "HostDataInUse"
+	// HostReferencesSecretsReason is used when a BareMetalHost references
+	// the secrets of the deleted Metal3Data.
+	HostReferencesSecretsReason = "HostReferencesSecrets"
+	// RenderedDataSizeValidCondition is true when the secrets rendered for
+	// the Metal3Data are stored, and false when one of them is too large to be
+	// stored. The message then gives the attempted size, and the Metal3Data is
+	// not requeued until it or its claims change.
+	RenderedDataSizeValidCondition clusterv1.ConditionType = "RenderedDataSizeValid"
+	// SecretSizeLimitExceededReason is used when the rendered secret exceeds
+	// the size limit of the secrets and is not written.
+	SecretSizeLimitExceededReason = "SecretSizeLimitExceeded"
+	// SecretRejectedReason is used when the API server rejects the rendered
+	// secret as too large.
+	SecretRejectedReason = "SecretRejected"
+)
+
+// Metal3DataTemplate Conditions and Reasons.
+//
+// The Ready condition of the Metal3DataTemplate summarizes
+// DataClaimsReconciledCondition.
+const (
+	// DataClaimsReconciledCondition is true once a Metal3Data is created for
+	// each Metal3DataClaim of the Metal3DataTemplate.
+	DataClaimsReconciledCondition clusterv1.ConditionType = "DataClaimsReconciled"
+	// DataClaimsReconcileFailedReason is used when the Metal3DataClaims could
+	// not be reconciled, with the Info severity when it is requeued and the
+	// Warning severity otherwise.
+	DataClaimsReconcileFailedReason = "DataClaimsReconcileFailed"
+)
+
+// Metal3DataClaim Conditions and Reasons.
+const (
+	// Metal3MachineFoundCondition is false while the Metal3Machine owning
+	// the Metal3DataClaim is not found. The claim is deleted, releasing its
+	// index, once the condition has been false for the orphan grace period.
+	Metal3MachineFoundCondition clusterv1.ConditionType = "Metal3MachineFound"
+	// OwnerNotFoundReason is used when the owner Metal3Machine of the
+	// Metal3DataClaim does not exist.
+	OwnerNotFoundReason = "OwnerNotFound"
+)
+
+// Metal3Remediation Conditions and Reasons.
+//
+// The Ready condition of the Metal3Remediation summarizes
+// HostRemediatedCondition.
+const (
+	// HostRemediatedCondition is true once the host of the unhealthy machine
+	// is remediated, or its Machine deleted by the escalate strategy. It is
+	// computed from the phase of the remediation.
+	HostRemediatedCondition clusterv1.ConditionType = "HostRemediated"
+	// RemediationInProgressReason (Severity=Info) is used while the host is
+	// rebooted and the Node waited for.
+	RemediationInProgressReason = "RemediationInProgress"
+	// RemediationEscalatedReason (Severity=Warning) is used while the host is
+	// deprovisioned, after the reboots of the escalate strategy failed.
+	RemediationEscalatedReason = "RemediationEscalated"
+	// RemediationFailedReason (Severity=Error) is used when the host is not
+	// remediated, because it is powered off or the retries are exhausted and
+	// the Machine is deleted.
+	RemediationFailedReason = "RemediationFailed"
+	// FailureDomainVacatedReason is the reason of the event emitted when the
+	// remediation escalates to the deletion of the unhealthy Machine, giving
+	// the failure domain and the host zone it vacates.
+	FailureDomainVacatedReason = "FailureDomainVacated"
+)
+
+// Metal3MachineTemplate Conditions and Reasons.
+const (
+	// TemplateInSyncCondition is true when the Metal3Machines cloned from the
+	// Metal3MachineTemplate match its image, customDeploy, hostSelector and
+	// dataTemplate, and false while some differ, e.g. after an update allowed
+	// with the AllowTemplateUpdateAnnotation. The message then gives the
+	// number of drifted Metal3Machines.
+	TemplateInSyncCondition clusterv1.ConditionType = "TemplateInSync"
+	// Metal3MachinesDriftedReason is used when Metal3Machines differ from the
+	// Metal3MachineTemplate.
+	Metal3MachinesDriftedReason = "Metal3MachinesDrifted"
+	// Metal3MachinesOutOfDateReason is used in the events listing the
+	// Metal3Machines cloned from an older revision of the
+	// Metal3MachineTemplate.
+	Metal3MachinesOutOfDateReason = "Metal3MachinesOutOfDate"
+)
+
+// Metal3MachinePool Conditions and Reasons.
+const (
+	// HostsAssociatedCondition is true once as many BareMetalHosts as the
+	// replicas of the MachinePool are associated with the Metal3MachinePool
+	// and none of the released ones is still deprovisioning. It is false
+	// with the NoAvailableHostReason when too few BareMetalHosts are
+	// available.
+	HostsAssociatedCondition clusterv1.ConditionType = "HostsAssociated"
+	// ScalingDownReason (Severity=Info) is used while the BareMetalHosts
+	// released by a scale down are deprovisioned.
+	ScalingDownReason = "ScalingDown"
+)
+
 // Metal3Machine Conditions and Reasons.
+//
+// Following the Cluster API conventions, the Ready condition of the
+// Metal3Machine, mirrored by the InfrastructureReady condition of the Machine,
+// summarizes the conditions of the provisioning steps, which have a positive
+// polarity: AssociateBMHCondition (host selection), Metal3DataReadyCondition
+// (data rendering), BareMetalHostProvisionedCondition (provisioning) and
+// KubernetesNodeReadyCondition (node matching). While false, they have the
+// Info severity when waiting, Warning when failing and retried, and Error
+// when the user must act. The other conditions have a positive polarity too,
+// they are only set when the feature they check is in use and do not affect
+// the Ready condition, the step they block carries their reason.
 const (
 	// AssociateBMHCondition documents the status of associated the Metal3Machine with a BaremetalHost.
 	AssociateBMHCondition clusterv1.ConditionType = "AssociateBMH"
@@ -42,8 +193,26 @@ const (
 	WaitingForClusterInfrastructureReason = "WaitingForClusterInfrastructure"
 	// WaitingForBootstrapReadyReason used when waiting for bootstrap to be ready before proceeding.
 	WaitingForBootstrapReadyReason = "WaitingForBootstrapReady"
+	// BootstrapSkipNotAllowedReason (Severity=Error) is used when the Metal3Machine is
+	// bootstrapless but the Metal3Cluster does not allow it.
+	BootstrapSkipNotAllowedReason = "BootstrapSkipNotAllowed"
 	// AssociateBMHFailedReason documents any errors while associating Metal3Machine with a BaremetalHost.
 	AssociateBMHFailedReason = "AssociateBMHFailed"
+	// NoAvailableHostReason (Severity=Warning) is used when no BaremetalHost matching the
+	// Metal3Machine is available. The Metal3Machine is requeued when the host pool changes.
+	NoAvailableHostReason = "NoAvailableHost"
+	// NoAvailableHostInFailureDomainReason (Severity=Warning) is used instead
+	// of NoAvailableHostReason when the Machine has a failure domain, no
+	// BareMetalHost matching the Metal3Machine is available in it.
+	NoAvailableHostInFailureDomainReason = "NoAvailableHostInFailureDomain"
+	// HostDeletedReason (Severity=Error) is used when the BareMetalHost of the
+	// Metal3Machine was deleted. The Metal3Machine is failed, it is not
+	// associated with another host and its Machine must be replaced.
+	HostDeletedReason = "HostDeleted"
+	// HostNotAdoptableReason (Severity=Warning) is used when the BareMetalHost
+	// named by the adopt-host annotation of the Metal3Machine is not found, is
+	// not externally provisioned or is consumed by another machine.
+	HostNotAdoptableReason = "HostNotAdoptable"
 	// WaitingForMetal3MachineOwnerRefReason is used when Metal3Machine is waiting for OwnerReference to be
 	// set before proceeding.
 	WaitingForMetal3MachineOwnerRefReason = "WaitingForM3MachineOwnerRef"
@@ -56,12 +225,155 @@ const (
 	// PauseAnnotationSetFailedReason is used when failed to set pause annotation on associated bmh.
 	PauseAnnotationSetFailedReason = "PauseAnnotationSetFailedReason"
 
+	// BareMetalHostProvisionedCondition is true once the BareMetalHost of the
+	// Metal3Machine is provisioned.
+	BareMetalHostProvisionedCondition clusterv1.ConditionType = "BareMetalHostProvisioned"
+	// ProvisioningBareMetalHostReason (Severity=Info) is used while the
+	// BareMetalHost is provisioned. The message gives its provisioning state.
+	ProvisioningBareMetalHostReason = "ProvisioningBareMetalHost"
+	// BareMetalHostProvisioningFailedReason (Severity=Warning) is used while
+	// the BareMetalHost reports a provisioning error. The message gives the
+	// error.
+	BareMetalHostProvisioningFailedReason = "BareMetalHostProvisioningFailed"
+	// WaitingForProvisioningSlotReason (Severity=Info) is used while the
+	// provisioning of the BareMetalHost is held because the maximum number of
+	// BareMetalHosts are already being provisioned.
+	WaitingForProvisioningSlotReason = "WaitingForProvisioningSlot"
+	// WaitingForFirmwareSettingsReason (Severity=Info) is used while the
+	// firmwareSettings of the Metal3Machine are not validated yet in the
+	// HostFirmwareSettings of the BareMetalHost.
+	WaitingForFirmwareSettingsReason = "WaitingForFirmwareSettings"
+	// WaitingForFirmwareSettingsAppliedReason (Severity=Info) is used while the
+	// firmwareSettings of the Metal3Machine are not applied yet to the
+	// provisioned BareMetalHost.
+	WaitingForFirmwareSettingsAppliedReason = "WaitingForFirmwareSettingsApplied"
+
 	// KubernetesNodeReadyCondition documents the transition of a Metal3Machine into a Kubernetes Node.
 	KubernetesNodeReadyCondition clusterv1.ConditionType = "KubernetesNodeReady"
+	// WaitingForNodeReason (Severity=Info) is used while the Node of the
+	// provisioned BareMetalHost is not found or not reachable yet.
+	WaitingForNodeReason = "WaitingForNode"
 	// Could not find the BMH associated with the Metal3Machine.
 	MissingBMHReason = "MissingBMH"
 	// Could not set the ProviderID on the target cluster's Node object.
 	SettingProviderIDOnNodeFailedReason = "SettingProviderIDOnNodeFailed"
+	// WaitingForLiveISOBootReason is used when a live-iso Metal3Machine is
+	// waiting for its BareMetalHost to be provisioned and powered on.
+	WaitingForLiveISOBootReason = "WaitingForLiveISOBoot"
+	// LiveISOURLRenderFailedReason is used when the template of the live-iso
+	// URL of the Metal3Machine can not be rendered for its BareMetalHost.
+	LiveISOURLRenderFailedReason = "LiveISOURLRenderFailed"
+	// ProviderIDFormatMatchedCondition is true when the Node of the
+	// Metal3Machine uses the configured providerID format, and false while it
+	// still uses the legacy format (metal3://<bmh-uuid>).
+	ProviderIDFormatMatchedCondition clusterv1.ConditionType = "ProviderIDFormatMatched"
+	// LegacyProviderIDFormatReason is used when the Node uses the legacy providerID format.
+	LegacyProviderIDFormatReason = "LegacyProviderIDFormat"
+	// BootstrapSkippedCondition is true when the Metal3Machine is bootstrapless
+	// or boots a live-iso image, and the BareMetalHost is provisioned without
+	// user data.
+	BootstrapSkippedCondition clusterv1.ConditionType = "BootstrapSkipped"
+	// NodeDrainedCondition documents the drain of the Node before the
+	// BareMetalHost is deprovisioned, when metal3DrainTimeout is set, and the
+	// wait for the pods of the Node tainted out of service by a remediation.
+	NodeDrainedCondition clusterv1.ConditionType = "NodeDrained"
+	// DrainingNodeReason is used while the pods of the Node are evicted. The
+	// message gives the effective drain timeout.
+	DrainingNodeReason = "DrainingNode"
+	// DrainTimeoutExceededReason is used when the drain did not complete
+	// within the nodeDrainTimeout of the Machine, or the CAPM3 default, and
+	// the deprovisioning or the remediation proceeded.
+	DrainTimeoutExceededReason = "DrainTimeoutExceeded"
+	// WaitingForVolumeDetachReason is used while volumes are attached to the
+	// drained Node. The message gives the effective volume detach timeout.
+	WaitingForVolumeDetachReason = "WaitingForVolumeDetach"
+	// VolumeDetachTimeoutExceededReason is used when the volumes of the Node
+	// did not detach within the nodeVolumeDetachTimeout of the Machine, or
+	// the CAPM3 default, and the deprovisioning or the remediation proceeded.
+	VolumeDetachTimeoutExceededReason = "VolumeDetachTimeoutExceeded"
+	// WorkloadClusterUnreachableReason is used when the Node could not be
+	// drained because the workload cluster is unreachable, and for the
+	// WorkloadClusterReachableCondition.
+	WorkloadClusterUnreachableReason = "WorkloadClusterUnreachable"
+	// HostAttachedCondition is true when the BareMetalHost of the
+	// Metal3Machine is attached, and false while it is detached with the
+	// baremetalhost.metal3.io/detached annotation. The host is not modified
+	// until it is attached again.
+	HostAttachedCondition clusterv1.ConditionType = "HostAttached"
+	// HostDetachedReason is used when the BareMetalHost is detached.
+	HostDetachedReason = "HostDetached"
+	// ProvidedDataValidCondition is true when the metaData and networkData
+	// secrets provided in the Metal3Machine spec are valid, and false while
+	// one of them does not exist, misses its key or is empty. It is not set
+	// when no secret is provided.
+	ProvidedDataValidCondition clusterv1.ConditionType = "ProvidedDataValid"
+	// ProvidedDataSecretNotFoundReason is used when the provided secret does
+	// not exist.
+	ProvidedDataSecretNotFoundReason = "ProvidedDataSecretNotFound"
+	// ProvidedDataKeyMissingReason is used when the provided secret does not
+	// contain the expected key.
+	ProvidedDataKeyMissingReason = "ProvidedDataKeyMissing"
+	// ProvidedDataEmptyReason is used when the expected key of the provided
+	// secret is empty.
+	ProvidedDataEmptyReason = "ProvidedDataEmpty"
+	// BootstrapFormatMatchedCondition is true when the format of the
+	// bootstrap data is the userDataFormat expected by the image, and false
+	// while they differ. The BareMetalHost is not provisioned until they
+	// match. It is not set when the image or the bootstrap data format is
+	// unknown.
+	BootstrapFormatMatchedCondition clusterv1.ConditionType = "BootstrapFormatMatched"
+	// BootstrapFormatMismatchReason is used when the bootstrap data format
+	// differs from the userDataFormat of the image.
+	BootstrapFormatMismatchReason = "BootstrapFormatMismatch"
+	// PolicyCompliantCondition is true when the Metal3Machine complies with
+	// the policies of its Metal3Cluster, e.g. enforceDataTemplates, and false
+	// while it violates one. The BareMetalHost is not provisioned until the
+	// violation is fixed. It is not set when no policy is enforced.
+	PolicyCompliantCondition clusterv1.ConditionType = "PolicyCompliant"
+	// DataTemplateRequiredReason is used when the Metal3Machine references
+	// metaData or networkData secrets while its Metal3Cluster enforces the
+	// use of Metal3DataTemplates.
+	DataTemplateRequiredReason = "DataTemplateRequired"
+	// UserDataAppendedCondition is true once the userDataAppend of the
+	// Metal3Machine is appended to the bootstrap data, and false while it can
+	// not be. The BareMetalHost is not provisioned until it is fixed. It is
+	// not set without userDataAppend.
+	UserDataAppendedCondition clusterv1.ConditionType = "UserDataAppended"
+	// UserDataNotCloudConfigReason is used when the bootstrap data is not in
+	// the cloud-config format, and can not be appended to.
+	UserDataNotCloudConfigReason = "UserDataNotCloudConfig"
+	// UserDataAppendRenderFailedReason is used when the template of the
+	// userDataAppend can not be fetched or rendered.
+	UserDataAppendRenderFailedReason = "UserDataAppendRenderFailed"
+	// FirmwareSettingsValidCondition is true when the firmwareSettings of the
+	// Metal3Machine are accepted by the FirmwareSchema of the BareMetalHost,
+	// and false while they are refused. The BareMetalHost is not provisioned
+	// until it is fixed. It is not set without firmwareSettings.
+	FirmwareSettingsValidCondition clusterv1.ConditionType = "FirmwareSettingsValid"
+	// InvalidFirmwareSettingsReason is used when the HostFirmwareSettings of
+	// the BareMetalHost report the settings as not valid, the message gives
+	// the validation error.
+	InvalidFirmwareSettingsReason = "InvalidFirmwareSettings"
+	// WorkloadClusterKubeconfigAvailableCondition is true when the kubeconfig
+	// secret of the workload cluster can be used to reach the cluster, and
+	// false while it can not. The object is requeued until the secret is
+	// fixed.
+	WorkloadClusterKubeconfigAvailableCondition clusterv1.ConditionType = "WorkloadClusterKubeconfigAvailable"
+	// KubeconfigNotFoundReason is used when the kubeconfig secret of the
+	// workload cluster does not exist.
+	KubeconfigNotFoundReason = "KubeconfigNotFound"
+	// KubeconfigInvalidReason is used when the kubeconfig secret of the
+	// workload cluster misses its value or does not hold a valid kubeconfig.
+	KubeconfigInvalidReason = "KubeconfigInvalid"
+	// KubeconfigUnauthorizedReason is used when the workload cluster rejects
+	// the credentials of the kubeconfig.
+	KubeconfigUnauthorizedReason = "KubeconfigUnauthorized"
+	// WorkloadClusterReachableCondition is true when the API server of the
+	// workload cluster is reached, and false with the
+	// WorkloadClusterUnreachableReason while it can not be reached or is not
+	// serving yet, e.g. during an upgrade of the control plane. The object is
+	// requeued until the cluster is reachable.
+	WorkloadClusterReachableCondition clusterv1.ConditionType = "WorkloadClusterReachable"
 	// Metal3DataReadyCondition reports a summary of Metal3Data status.
 	Metal3DataReadyCondition clusterv1.ConditionType = "Metal3DataReady"
 	// WaitingForMetal3DataReason used when waiting for Metal3Data
@@ -71,9 +383,42 @@ const (
 	AssociateM3MetaDataFailedReason = "AssociateM3MetaDataFailed"
 	// DisassociateM3MetaDataFailedReason is used when failed to remove OwnerReference of Meta3DataTemplate.
 	DisassociateM3MetaDataFailedReason = "DisassociateM3MetaDataFailed"
+	// PoweredOffCondition is true while the BareMetalHost of a Metal3Machine
+	// with powerState off is powered off. It is false while the host powers
+	// off, or powers on again until the Machine has a Node. It does not
+	// affect the readiness of the Metal3Machine.
+	PoweredOffCondition clusterv1.ConditionType = "PoweredOff"
+	// PoweringOffReason is used while the BareMetalHost powers off.
+	PoweringOffReason = "PoweringOff"
+	// PoweringOnReason is used while the BareMetalHost powers on, after the
+	// powerState is set back to on.
+	PoweringOnReason = "PoweringOn"
+	// HostErrorReason is used in the delete-machine annotation of the Machine
+	// when its BareMetalHost is in error.
+	HostErrorReason = "HostError"
+	// HostPoweredOffReason is used in the delete-machine annotation of the
+	// Machine when its BareMetalHost is powered off while it should be on.
+	HostPoweredOffReason = "HostPoweredOff"
+	// PreferDeleteLabelReason is used in the delete-machine annotation of the
+	// Machine when its BareMetalHost has the PreferDeleteLabel.
+	PreferDeleteLabelReason = "PreferDeleteLabel"
+	// DeletePreferredReason is the reason of the event emitted when the
+	// delete-machine annotation is set on the Machine.
+	DeletePreferredReason = "DeletePreferred"
+	// RootDeviceHintsConflictReason is the reason of the event emitted when
+	// the BareMetalHost sets rootDeviceHints differing from the ones of the
+	// Metal3Machine, which are ignored.
+	RootDeviceHintsConflictReason = "RootDeviceHintsConflict"
+	// NodeMetadataConflictReason is the reason of the event emitted when
+	// labels or annotations of the nodeMetadata of the Metal3Machine are
+	// owned by others on the Node, they are not set.
+	NodeMetadataConflictReason = "NodeMetadataConflict"
 	// DeletingReason (Severity=Info) documents a condition not in Status=True because the underlying object it is currently being deleted.
 	DeletingReason = "Deleting"
 	// DeletionFailedReason (Severity=Warning) documents a condition not in Status=True because the underlying object
 	// encountered problems during deletion. This is a warning because the reconciler will retry deletion.
 	DeletionFailedReason = "DeletionFailed"
+	// WaitingForPreTerminateHookReason (Severity=Info) documents a condition not in Status=True because the
+	// deprovisioning of the BareMetalHost is held by pre-terminate delete hooks set on the Machine.
+	WaitingForPreTerminateHookReason = "WaitingForPreTerminateHook"
 )
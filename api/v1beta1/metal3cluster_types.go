@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
@@ -27,6 +28,9 @@ const (
 	// ClusterFinalizer allows Metal3ClusterReconciler to clean up resources associated with Metal3Cluster before
 	// removing it from the apiserver.
 	ClusterFinalizer = "metal3cluster.infrastructure.cluster.x-k8s.io"
+	// DefaultFailureDomainLabel is the label of the BareMetalHosts giving
+	// their failure domain, unless the Metal3Cluster sets failureDomainLabel.
+	DefaultFailureDomainLabel = "infrastructure.cluster.x-k8s.io/failure-domain"
 )
 
 // Metal3ClusterSpec defines the desired state of Metal3Cluster.
@@ -34,18 +38,119 @@ type Metal3ClusterSpec struct {
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint APIEndpoint `json:"controlPlaneEndpoint,omitempty"`
+	// ControlPlaneEndpointFromPool references a pool, a Metal3IPPool (default)
+	// or a CAPI IPAM pool, from which the host of the ControlPlaneEndpoint is
+	// allocated instead of being set statically. The cluster infrastructure is
+	// ready once the address is allocated and set in the ControlPlaneEndpoint,
+	// and the address is released when the Metal3Cluster is deleted.
+	// +optional
+	ControlPlaneEndpointFromPool *corev1.TypedLocalObjectReference `json:"controlPlaneEndpointFromPool,omitempty"`
 	// Determines if the cluster is not to be deployed with an external cloud provider.
 	// If set to true, CAPM3 will use node labels to set providerID on the kubernetes nodes.
 	// If set to false, providerID is set on nodes by other entities and CAPM3 uses the value of the providerID on the m3m resource.
+	// Deprecated: use CloudProviderEnabled instead, the webhook keeps both
+	// fields consistent.
 	// +optional
 	NoCloudProvider bool `json:"noCloudProvider,omitempty"`
+	// CloudProviderEnabled determines if the cluster is deployed with an
+	// external cloud provider setting the providerID of the Nodes. When
+	// false, CAPM3 sets the providerID on the Nodes. It defaults to the
+	// opposite of the deprecated NoCloudProvider.
+	// +optional
+	CloudProviderEnabled *bool `json:"cloudProviderEnabled,omitempty"`
+	// AllowBootstrapless allows the Metal3Machines of the cluster to be
+	// provisioned without bootstrap data when they set bootstrapless. It
+	// prevents unbootstrapped nodes from being created by accident.
+	// +optional
+	AllowBootstrapless bool `json:"allowBootstrapless,omitempty"`
+	// HostSelectionPolicy is the order in which the BareMetalHosts matching
+	// a Metal3Machine are considered: random (default), leastRecentlyUsed
+	// for the host released the longest time ago, based on the
+	// HostLastReleasedAnnotation, newestInspectionFirst for the most
+	// recently inspected host, or leastProvisioned for the host provisioned
+	// the fewest times, based on the HostProvisionCountAnnotation.
+	// +kubebuilder:validation:Enum=random;leastRecentlyUsed;newestInspectionFirst;leastProvisioned
+	// +optional
+	HostSelectionPolicy HostSelectionPolicy `json:"hostSelectionPolicy,omitempty"`
+	// ProvidedDataValidation controls what happens when the metaData or
+	// networkData secret provided in a Metal3Machine does not exist, misses
+	// its key or is empty: warn (default) sets the ProvidedDataValid
+	// condition of the Metal3Machine to false and does not write the secret
+	// to the BareMetalHost, strict blocks the provisioning of the
+	// Metal3Machine until the secret is fixed.
+	// +kubebuilder:validation:Enum=warn;strict
+	// +optional
+	ProvidedDataValidation ProvidedDataValidation `json:"providedDataValidation,omitempty"`
+	// FailureDomainLabel is the label of the BareMetalHosts giving their
+	// failure domain, e.g. their rack, infrastructure.cluster.x-k8s.io/failure-domain
+	// by default. The values of the label are reported as the failureDomains
+	// of the Metal3Cluster, and the hosts of a Machine with a failureDomain
+	// are chosen among the BareMetalHosts with this label value.
+	// +optional
+	FailureDomainLabel string `json:"failureDomainLabel,omitempty"`
+	// HostClusterKubeconfigSecret references a secret, in the namespace of
+	// the Metal3Cluster, holding under its value key the kubeconfig of the
+	// cluster in which the BareMetalHosts are registered and the
+	// baremetal-operator runs, when it is not the cluster of CAPM3. The
+	// Metal3Machines of the cluster are associated with the BareMetalHosts
+	// of that cluster, and their secrets are copied there. Requires the
+	// controller to be started with --enable-host-clusters.
+	// +optional
+	HostClusterKubeconfigSecret *corev1.LocalObjectReference `json:"hostClusterKubeconfigSecret,omitempty"`
+	// EnforceDataTemplates, when true, requires the Metal3Machines of the
+	// cluster to render their metaData and networkData from a
+	// Metal3DataTemplate. The Metal3Machines referencing metaData or
+	// networkData secrets directly are rejected, and those created before
+	// are not provisioned and get a false PolicyCompliant condition.
+	// +optional
+	EnforceDataTemplates bool `json:"enforceDataTemplates,omitempty"`
+}
+
+// HostSelectionPolicy is the order in which the BareMetalHosts are chosen.
+type HostSelectionPolicy string
+
+const (
+	// HostSelectionRandom chooses a random host.
+	HostSelectionRandom HostSelectionPolicy = "random"
+	// HostSelectionLeastRecentlyUsed chooses the host released the longest
+	// time ago. Hosts never released are chosen first.
+	HostSelectionLeastRecentlyUsed HostSelectionPolicy = "leastRecentlyUsed"
+	// HostSelectionNewestInspectionFirst chooses the host inspected last.
+	// Hosts never inspected are chosen last.
+	HostSelectionNewestInspectionFirst HostSelectionPolicy = "newestInspectionFirst"
+	// HostSelectionLeastProvisioned chooses the host provisioned the fewest
+	// times, then the host released the longest time ago.
+	HostSelectionLeastProvisioned HostSelectionPolicy = "leastProvisioned"
+)
+
+// ProvidedDataValidation is the handling of invalid provided secrets.
+type ProvidedDataValidation string
+
+const (
+	// ProvidedDataValidationWarn reports invalid provided secrets, and
+	// provisions without them.
+	ProvidedDataValidationWarn ProvidedDataValidation = "warn"
+	// ProvidedDataValidationStrict blocks the provisioning on invalid
+	// provided secrets.
+	ProvidedDataValidationStrict ProvidedDataValidation = "strict"
+)
+
+// IsCloudProviderEnabled returns whether the cluster is deployed with an
+// external cloud provider, from CloudProviderEnabled when set and from the
+// deprecated NoCloudProvider otherwise.
+func (s *Metal3ClusterSpec) IsCloudProviderEnabled() bool {
+	if s.CloudProviderEnabled != nil {
+		return *s.CloudProviderEnabled
+	}
+	return !s.NoCloudProvider
 }
 
 // IsValid returns an error if the object is not valid, otherwise nil. The
 // string representation of the error is suitable for human consumption.
 func (s *Metal3ClusterSpec) IsValid() error {
 	missing := []string{}
-	if s.ControlPlaneEndpoint.Host == "" {
+	// The host is allocated from the pool.
+	if s.ControlPlaneEndpoint.Host == "" && s.ControlPlaneEndpointFromPool == nil {
 		missing = append(missing, "ControlPlaneEndpoint.Host")
 	}
 
@@ -82,6 +187,22 @@ type Metal3ClusterStatus struct {
 	// metal3Cluster controller after creation.
 	// +optional
 	Ready bool `json:"ready"`
+	// ReadyMachines is the number of ready Metal3Machines of the cluster.
+	// +optional
+	ReadyMachines int32 `json:"readyMachines,omitempty"`
+	// ProvisioningMachines is the number of Metal3Machines of the cluster
+	// neither ready nor failed, and not being deleted.
+	// +optional
+	ProvisioningMachines int32 `json:"provisioningMachines,omitempty"`
+	// FailedMachines is the number of Metal3Machines of the cluster with a
+	// failure reason or message.
+	// +optional
+	FailedMachines int32 `json:"failedMachines,omitempty"`
+	// FailureDomains are the values of the failureDomainLabel of the
+	// BareMetalHosts the Metal3Machines of the cluster can be placed on.
+	// They are all suitable for control plane machines.
+	// +optional
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
 	// Conditions defines current service state of the Metal3Cluster.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -97,6 +218,9 @@ type Metal3ClusterStatus struct {
 // +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.failureReason",description="Most recent error"
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this BMCluster belongs"
 // +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint",description="Control plane endpoint"
+// +kubebuilder:printcolumn:name="Ready Machines",type="integer",JSONPath=".status.readyMachines",description="Number of ready Metal3Machines"
+// +kubebuilder:printcolumn:name="Provisioning",type="integer",JSONPath=".status.provisioningMachines",description="Number of provisioning Metal3Machines"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedMachines",description="Number of failed Metal3Machines"
 
 // Metal3Cluster is the Schema for the metal3clusters API.
 type Metal3Cluster struct {
@@ -14,9 +14,17 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -25,6 +33,7 @@ import (
 func (c *Metal3Cluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(c).
+		WithDefaulter(&metal3ClusterDefaulter{}).
 		Complete()
 }
 
@@ -35,19 +44,71 @@ var _ webhook.Defaulter = &Metal3Cluster{}
 var _ webhook.Validator = &Metal3Cluster{}
 
 func (c *Metal3Cluster) Default() {
+	c.defaultFrom(nil)
+}
+
+// metal3ClusterDefaulter defaults the Metal3Clusters in the webhook, with the
+// old object of the updates.
+type metal3ClusterDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &metal3ClusterDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type.
+func (d *metal3ClusterDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	c, ok := obj.(*Metal3Cluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Metal3Cluster but got a %T", obj))
+	}
+	var oldM3c *Metal3Cluster
+	if req, err := admission.RequestFromContext(ctx); err == nil && len(req.OldObject.Raw) != 0 {
+		oldM3c = &Metal3Cluster{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldM3c); err != nil {
+			return apierrors.NewBadRequest(fmt.Sprintf("failed to decode the old Metal3Cluster: %v", err))
+		}
+	}
+	c.defaultFrom(oldM3c)
+	return nil
+}
+
+// defaultFrom defaults the Metal3Cluster, updated from the old one if not
+// nil.
+func (c *Metal3Cluster) defaultFrom(oldM3c *Metal3Cluster) {
 	if c.Spec.ControlPlaneEndpoint.Port == 0 {
 		c.Spec.ControlPlaneEndpoint.Port = 6443
 	}
+
+	// cloudProviderEnabled replaces the deprecated noCloudProvider, both are
+	// kept consistent for the clients reading either of them. On update, a
+	// field is only derived from the other one if the other one changed, so
+	// that the clients only aware of noCloudProvider can still change it.
+	// Contradictory values are refused by the validation.
+	if oldM3c != nil && c.Spec.CloudProviderEnabled != nil && oldM3c.Spec.CloudProviderEnabled != nil {
+		cloudProviderChanged := *c.Spec.CloudProviderEnabled != *oldM3c.Spec.CloudProviderEnabled
+		noCloudProviderChanged := c.Spec.NoCloudProvider != oldM3c.Spec.NoCloudProvider
+		switch {
+		case noCloudProviderChanged && !cloudProviderChanged:
+			c.Spec.CloudProviderEnabled = pointer.Bool(!c.Spec.NoCloudProvider)
+		case cloudProviderChanged && !noCloudProviderChanged:
+			c.Spec.NoCloudProvider = !*c.Spec.CloudProviderEnabled
+		}
+		return
+	}
+	if c.Spec.CloudProviderEnabled == nil {
+		c.Spec.CloudProviderEnabled = pointer.Bool(!c.Spec.NoCloudProvider)
+	} else if !*c.Spec.CloudProviderEnabled {
+		c.Spec.NoCloudProvider = true
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (c *Metal3Cluster) ValidateCreate() (admission.Warnings, error) {
-	return nil, c.validate()
+	return nil, c.validate(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
-func (c *Metal3Cluster) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
-	return nil, c.validate()
+func (c *Metal3Cluster) ValidateUpdate(oldRaw runtime.Object) (admission.Warnings, error) {
+	oldM3c, _ := oldRaw.(*Metal3Cluster)
+	return nil, c.validate(oldM3c)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -55,9 +116,28 @@ func (c *Metal3Cluster) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
-func (c *Metal3Cluster) validate() error {
+func (c *Metal3Cluster) validate(oldM3c *Metal3Cluster) error {
 	var allErrs field.ErrorList
-	if c.Spec.ControlPlaneEndpoint.Host == "" {
+	fromPoolPath := field.NewPath("spec", "controlPlaneEndpointFromPool")
+	if oldM3c != nil && oldM3c.Spec.ControlPlaneEndpointFromPool != nil {
+		// The host is set by the controller once allocated from the pool.
+		if !reflect.DeepEqual(c.Spec.ControlPlaneEndpointFromPool, oldM3c.Spec.ControlPlaneEndpointFromPool) {
+			allErrs = append(allErrs,
+				field.Forbidden(fromPoolPath, "is immutable"),
+			)
+		}
+	} else if c.Spec.ControlPlaneEndpointFromPool != nil {
+		if c.Spec.ControlPlaneEndpoint.Host != "" {
+			allErrs = append(allErrs,
+				field.Forbidden(fromPoolPath, "cannot be set together with spec.controlPlaneEndpoint.host"),
+			)
+		}
+		if c.Spec.ControlPlaneEndpointFromPool.Name == "" {
+			allErrs = append(allErrs,
+				field.Required(fromPoolPath.Child("name"), "is required"),
+			)
+		}
+	} else if c.Spec.ControlPlaneEndpoint.Host == "" {
 		allErrs = append(
 			allErrs,
 			field.Invalid(
@@ -68,6 +148,44 @@ func (c *Metal3Cluster) validate() error {
 		)
 	}
 
+	if host := c.Spec.ControlPlaneEndpoint.Host; host != "" && net.ParseIP(host) == nil {
+		for _, msg := range validation.IsDNS1123Subdomain(host) {
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath("spec", "controlPlaneEndpoint", "host"), host,
+				"must be a valid IP address or RFC 1123 hostname: "+msg,
+			))
+		}
+	}
+
+	if c.Spec.CloudProviderEnabled != nil && *c.Spec.CloudProviderEnabled && c.Spec.NoCloudProvider {
+		allErrs = append(allErrs, field.Invalid(
+			field.NewPath("spec", "noCloudProvider"), c.Spec.NoCloudProvider,
+			"contradicts spec.cloudProviderEnabled, spec.noCloudProvider is deprecated in favor of spec.cloudProviderEnabled",
+		))
+	}
+
+	// The failure domain label is a label key of the BareMetalHosts.
+	if c.Spec.FailureDomainLabel != "" {
+		for _, msg := range validation.IsQualifiedName(c.Spec.FailureDomainLabel) {
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath("spec", "failureDomainLabel"), c.Spec.FailureDomainLabel, msg,
+			))
+		}
+	}
+
+	// The Metal3Machines would lose their BareMetalHosts if the host cluster
+	// changed.
+	hostClusterPath := field.NewPath("spec", "hostClusterKubeconfigSecret")
+	if oldM3c != nil && !reflect.DeepEqual(c.Spec.HostClusterKubeconfigSecret, oldM3c.Spec.HostClusterKubeconfigSecret) {
+		allErrs = append(allErrs,
+			field.Forbidden(hostClusterPath, "is immutable"),
+		)
+	} else if c.Spec.HostClusterKubeconfigSecret != nil && c.Spec.HostClusterKubeconfigSecret.Name == "" {
+		allErrs = append(allErrs,
+			field.Required(hostClusterPath.Child("name"), "is required"),
+		)
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
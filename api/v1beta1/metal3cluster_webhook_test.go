@@ -14,10 +14,17 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestMetal3ClusterDefault(t *testing.T) {
@@ -36,6 +43,152 @@ func TestMetal3ClusterDefault(t *testing.T) {
 	g.Expect(c.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(6443))
 }
 
+func TestMetal3ClusterDefaultCloudProvider(t *testing.T) {
+	tests := []struct {
+		name                         string
+		spec                         Metal3ClusterSpec
+		expectedPort                 int
+		expectedCloudProviderEnabled bool
+		expectedNoCloudProvider      bool
+	}{
+		{
+			name:                         "should default the port when the host is set",
+			spec:                         Metal3ClusterSpec{ControlPlaneEndpoint: APIEndpoint{Host: "192.168.111.249"}},
+			expectedPort:                 6443,
+			expectedCloudProviderEnabled: true,
+		},
+		{
+			name:                         "should keep the port when set",
+			spec:                         Metal3ClusterSpec{ControlPlaneEndpoint: APIEndpoint{Host: "192.168.111.249", Port: 443}},
+			expectedPort:                 443,
+			expectedCloudProviderEnabled: true,
+		},
+		{
+			name:                         "should default cloudProviderEnabled from the deprecated noCloudProvider",
+			spec:                         Metal3ClusterSpec{NoCloudProvider: true},
+			expectedPort:                 6443,
+			expectedCloudProviderEnabled: false,
+			expectedNoCloudProvider:      true,
+		},
+		{
+			name:                         "should default noCloudProvider from cloudProviderEnabled",
+			spec:                         Metal3ClusterSpec{CloudProviderEnabled: pointer.Bool(false)},
+			expectedPort:                 6443,
+			expectedCloudProviderEnabled: false,
+			expectedNoCloudProvider:      true,
+		},
+		{
+			name:                         "should keep cloudProviderEnabled when enabled",
+			spec:                         Metal3ClusterSpec{CloudProviderEnabled: pointer.Bool(true)},
+			expectedPort:                 6443,
+			expectedCloudProviderEnabled: true,
+		},
+		{
+			name:                         "should keep contradictory fields for the validation",
+			spec:                         Metal3ClusterSpec{CloudProviderEnabled: pointer.Bool(true), NoCloudProvider: true},
+			expectedPort:                 6443,
+			expectedCloudProviderEnabled: true,
+			expectedNoCloudProvider:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := &Metal3Cluster{Spec: tt.spec}
+			c.Default()
+
+			g.Expect(c.Spec.ControlPlaneEndpoint.Port).To(Equal(tt.expectedPort))
+			g.Expect(c.Spec.CloudProviderEnabled).To(Equal(pointer.Bool(tt.expectedCloudProviderEnabled)))
+			g.Expect(c.Spec.NoCloudProvider).To(Equal(tt.expectedNoCloudProvider))
+		})
+	}
+}
+
+func TestMetal3ClusterDefaultCloudProviderUpdate(t *testing.T) {
+	defaulted := &Metal3Cluster{Spec: Metal3ClusterSpec{
+		ControlPlaneEndpoint: APIEndpoint{Host: "192.168.111.249", Port: 6443},
+		CloudProviderEnabled: pointer.Bool(false),
+		NoCloudProvider:      true,
+	}}
+	legacy := &Metal3Cluster{Spec: Metal3ClusterSpec{
+		ControlPlaneEndpoint: APIEndpoint{Host: "192.168.111.249", Port: 6443},
+		NoCloudProvider:      true,
+	}}
+
+	tests := []struct {
+		name                         string
+		old                          *Metal3Cluster
+		cloudProviderEnabled         *bool
+		noCloudProvider              bool
+		expectedCloudProviderEnabled bool
+		expectedNoCloudProvider      bool
+	}{
+		{
+			name:                         "should derive cloudProviderEnabled when only the deprecated noCloudProvider changes",
+			old:                          defaulted,
+			cloudProviderEnabled:         pointer.Bool(false),
+			noCloudProvider:              false,
+			expectedCloudProviderEnabled: true,
+			expectedNoCloudProvider:      false,
+		},
+		{
+			name:                         "should derive noCloudProvider when only cloudProviderEnabled changes",
+			old:                          defaulted,
+			cloudProviderEnabled:         pointer.Bool(true),
+			noCloudProvider:              true,
+			expectedCloudProviderEnabled: true,
+			expectedNoCloudProvider:      false,
+		},
+		{
+			name:                         "should keep the fields when both change",
+			old:                          defaulted,
+			cloudProviderEnabled:         pointer.Bool(true),
+			noCloudProvider:              false,
+			expectedCloudProviderEnabled: true,
+			expectedNoCloudProvider:      false,
+		},
+		{
+			name:                         "should keep the fields when none changes",
+			old:                          defaulted,
+			cloudProviderEnabled:         pointer.Bool(false),
+			noCloudProvider:              true,
+			expectedCloudProviderEnabled: false,
+			expectedNoCloudProvider:      true,
+		},
+		{
+			name:                         "should default cloudProviderEnabled of a Metal3Cluster stored before it",
+			old:                          legacy,
+			noCloudProvider:              false,
+			expectedCloudProviderEnabled: true,
+			expectedNoCloudProvider:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			oldRaw, err := json.Marshal(tt.old)
+			g.Expect(err).NotTo(HaveOccurred())
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+			c := tt.old.DeepCopy()
+			c.Spec.CloudProviderEnabled = tt.cloudProviderEnabled
+			c.Spec.NoCloudProvider = tt.noCloudProvider
+
+			g.Expect((&metal3ClusterDefaulter{}).Default(ctx, c)).To(Succeed())
+			g.Expect(c.Spec.CloudProviderEnabled).To(Equal(pointer.Bool(tt.expectedCloudProviderEnabled)))
+			g.Expect(c.Spec.NoCloudProvider).To(Equal(tt.expectedNoCloudProvider))
+			_, err = c.ValidateUpdate(tt.old)
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
 func TestMetal3ClusterValidation(t *testing.T) {
 	valid := &Metal3Cluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -51,6 +204,30 @@ func TestMetal3ClusterValidation(t *testing.T) {
 	invalidHost := valid.DeepCopy()
 	invalidHost.Spec.ControlPlaneEndpoint.Host = ""
 
+	validPaused := valid.DeepCopy()
+	validPaused.Annotations = map[string]string{PausedAnnotation: ""}
+	validPaused.Spec.ControlPlaneEndpoint.Port = 6443
+
+	validFailureDomainLabel := valid.DeepCopy()
+	validFailureDomainLabel.Spec.FailureDomainLabel = "example.com/rack"
+
+	invalidFailureDomainLabel := valid.DeepCopy()
+	invalidFailureDomainLabel.Spec.FailureDomainLabel = "rack/"
+
+	validIPv6Host := valid.DeepCopy()
+	validIPv6Host.Spec.ControlPlaneEndpoint.Host = "fd55::1"
+
+	invalidHostName := valid.DeepCopy()
+	invalidHostName.Spec.ControlPlaneEndpoint.Host = "https://abc.com"
+
+	validCloudProvider := valid.DeepCopy()
+	validCloudProvider.Spec.CloudProviderEnabled = pointer.Bool(false)
+	validCloudProvider.Spec.NoCloudProvider = true
+
+	invalidCloudProvider := valid.DeepCopy()
+	invalidCloudProvider.Spec.CloudProviderEnabled = pointer.Bool(true)
+	invalidCloudProvider.Spec.NoCloudProvider = true
+
 	tests := []struct {
 		name      string
 		expectErr bool
@@ -66,6 +243,41 @@ func TestMetal3ClusterValidation(t *testing.T) {
 			expectErr: false,
 			c:         valid,
 		},
+		{
+			name:      "should succeed when paused and endpoint changed",
+			expectErr: false,
+			c:         validPaused,
+		},
+		{
+			name:      "should succeed with an IPv6 endpoint",
+			expectErr: false,
+			c:         validIPv6Host,
+		},
+		{
+			name:      "should return error when the endpoint is not a hostname",
+			expectErr: true,
+			c:         invalidHostName,
+		},
+		{
+			name:      "should succeed when the cloud provider fields agree",
+			expectErr: false,
+			c:         validCloudProvider,
+		},
+		{
+			name:      "should return error when the cloud provider fields contradict each other",
+			expectErr: true,
+			c:         invalidCloudProvider,
+		},
+		{
+			name:      "should succeed with a failure domain label",
+			expectErr: false,
+			c:         validFailureDomainLabel,
+		},
+		{
+			name:      "should return error with an invalid failure domain label",
+			expectErr: true,
+			c:         invalidFailureDomainLabel,
+		},
 	}
 
 	for _, tt := range tests {
@@ -86,3 +298,166 @@ func TestMetal3ClusterValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMetal3ClusterControlPlaneEndpointFromPoolValidation(t *testing.T) {
+	poolRef := &corev1.TypedLocalObjectReference{Name: "vip-pool"}
+	fromPool := &Metal3Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3ClusterSpec{
+			ControlPlaneEndpoint:         APIEndpoint{Port: 6443},
+			ControlPlaneEndpointFromPool: poolRef,
+		},
+	}
+	both := fromPool.DeepCopy()
+	both.Spec.ControlPlaneEndpoint.Host = "abc.com"
+	noPoolName := fromPool.DeepCopy()
+	noPoolName.Spec.ControlPlaneEndpointFromPool.Name = ""
+	otherPool := both.DeepCopy()
+	otherPool.Spec.ControlPlaneEndpointFromPool.Name = "other-pool"
+	static := both.DeepCopy()
+	static.Spec.ControlPlaneEndpointFromPool = nil
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Metal3Cluster
+		old       *Metal3Cluster
+	}{
+		{
+			name:      "should succeed with the pool only",
+			expectErr: false,
+			c:         fromPool,
+		},
+		{
+			name:      "should return error with both the host and the pool",
+			expectErr: true,
+			c:         both,
+		},
+		{
+			name:      "should return error without pool name",
+			expectErr: true,
+			c:         noPoolName,
+		},
+		{
+			name:      "should succeed when the host is allocated from the pool",
+			expectErr: false,
+			c:         both,
+			old:       fromPool,
+		},
+		{
+			name:      "should return error when the pool changes",
+			expectErr: true,
+			c:         otherPool,
+			old:       both,
+		},
+		{
+			name:      "should return error when the pool is removed",
+			expectErr: true,
+			c:         static,
+			old:       both,
+		},
+		{
+			name:      "should return error when the pool is added to a static endpoint",
+			expectErr: true,
+			c:         both,
+			old:       static,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var err error
+			if tt.old == nil {
+				_, err = tt.c.ValidateCreate()
+			} else {
+				_, err = tt.c.ValidateUpdate(tt.old)
+			}
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestMetal3ClusterHostClusterKubeconfigSecretValidation(t *testing.T) {
+	local := &Metal3Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3ClusterSpec{
+			ControlPlaneEndpoint: APIEndpoint{Host: "abc.com", Port: 6443},
+		},
+	}
+	hostCluster := local.DeepCopy()
+	hostCluster.Spec.HostClusterKubeconfigSecret = &corev1.LocalObjectReference{Name: "hardware-kubeconfig"}
+	noSecretName := local.DeepCopy()
+	noSecretName.Spec.HostClusterKubeconfigSecret = &corev1.LocalObjectReference{}
+	otherHostCluster := local.DeepCopy()
+	otherHostCluster.Spec.HostClusterKubeconfigSecret = &corev1.LocalObjectReference{Name: "other-kubeconfig"}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Metal3Cluster
+		old       *Metal3Cluster
+	}{
+		{
+			name:      "should succeed with a host cluster",
+			expectErr: false,
+			c:         hostCluster,
+		},
+		{
+			name:      "should return error without secret name",
+			expectErr: true,
+			c:         noSecretName,
+		},
+		{
+			name:      "should succeed when the host cluster is unchanged",
+			expectErr: false,
+			c:         hostCluster,
+			old:       hostCluster,
+		},
+		{
+			name:      "should return error when the host cluster changes",
+			expectErr: true,
+			c:         otherHostCluster,
+			old:       hostCluster,
+		},
+		{
+			name:      "should return error when the host cluster is removed",
+			expectErr: true,
+			c:         local,
+			old:       hostCluster,
+		},
+		{
+			name:      "should return error when the host cluster is added",
+			expectErr: true,
+			c:         hostCluster,
+			old:       local,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var err error
+			if tt.old == nil {
+				_, err = tt.c.ValidateCreate()
+			} else {
+				_, err = tt.c.ValidateUpdate(tt.old)
+			}
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -19,12 +19,17 @@ package v1beta1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const (
 	// DataFinalizer allows Metal3DataReconciler to clean up resources
 	// associated with Metal3Data before removing it from the apiserver.
 	DataFinalizer = "metal3data.infrastructure.cluster.x-k8s.io"
+
+	// DataForceDeleteAnnotation lets a Metal3Data be deleted while its
+	// BareMetalHost is provisioned and still uses its secrets.
+	DataForceDeleteAnnotation = "capm3.metal3.io/force-delete"
 )
 
 // Metal3DataSpec defines the desired state of Metal3Data.
@@ -63,6 +68,19 @@ type Metal3DataStatus struct {
 	// ErrorMessage contains the error message
 	// +optional
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// HostRef is the BareMetalHost the secrets were rendered for.
+	// +optional
+	HostRef *corev1.ObjectReference `json:"hostRef,omitempty"`
+
+	// RenderedTemplateGeneration is the generation of the Metal3DataTemplate
+	// the secrets were rendered from.
+	// +optional
+	RenderedTemplateGeneration int64 `json:"renderedTemplateGeneration,omitempty"`
+
+	// Conditions defines current service state of the Metal3Data.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -93,6 +111,16 @@ type Metal3DataList struct {
 	Items           []Metal3Data `json:"items"`
 }
 
+// GetConditions returns the list of conditions for a Metal3Data API object.
+func (c *Metal3Data) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3Data object.
+func (c *Metal3Data) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&Metal3Data{}, &Metal3DataList{})
 }
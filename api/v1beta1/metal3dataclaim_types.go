@@ -19,14 +19,28 @@ package v1beta1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const (
 	// DataClaimFinalizer allows Metal3DataReconciler to clean up resources
 	// associated with Metal3DataClaim before removing it from the apiserver.
 	DataClaimFinalizer = "metal3dataclaim.infrastructure.cluster.x-k8s.io"
+
+	// IndexAllocationPreferred means that the preferred index of the
+	// Metal3Machine was allocated.
+	IndexAllocationPreferred IndexAllocationReason = "Preferred"
+	// IndexAllocationHostLastIndex means that the index last used by the
+	// BareMetalHost of the Metal3Machine was allocated.
+	IndexAllocationHostLastIndex IndexAllocationReason = "HostLastIndex"
+	// IndexAllocationFirstFree means that the first free index was allocated.
+	IndexAllocationFirstFree IndexAllocationReason = "FirstFree"
 )
 
+// IndexAllocationReason is the reason why an index was allocated.
+// +kubebuilder:validation:Enum=Preferred;HostLastIndex;FirstFree
+type IndexAllocationReason string
+
 // Metal3DataClaimSpec defines the desired state of Metal3DataClaim.
 type Metal3DataClaimSpec struct {
 	// Template is the Metal3DataTemplate this was generated for.
@@ -42,6 +56,28 @@ type Metal3DataClaimStatus struct {
 	// ErrorMessage contains the error message
 	// +optional
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// IndexAllocation reports how the index of the Metal3Data was allocated.
+	// +optional
+	IndexAllocation *IndexAllocation `json:"indexAllocation,omitempty"`
+
+	// Conditions defines current service state of the Metal3DataClaim.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// IndexAllocation is the outcome of the allocation of the index of a
+// Metal3Data.
+type IndexAllocation struct {
+	// Index is the allocated index.
+	Index int `json:"index"`
+
+	// Reason is the reason why this index was allocated.
+	Reason IndexAllocationReason `json:"reason"`
+
+	// Message explains why the preferred index, if any, was not allocated.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -72,6 +108,16 @@ type Metal3DataClaimList struct {
 	Items           []Metal3DataClaim `json:"items"`
 }
 
+// GetConditions returns the list of conditions for a Metal3DataClaim API object.
+func (c *Metal3DataClaim) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3DataClaim object.
+func (c *Metal3DataClaim) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&Metal3DataClaim{}, &Metal3DataClaimList{})
 }
@@ -20,6 +20,7 @@ import (
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 const (
@@ -71,6 +72,19 @@ type MetaDataFromAnnotation struct {
 	Annotation string `json:"annotation"`
 }
 
+// MetaDataFromTemplate contains the information to render a Go text/template.
+type MetaDataFromTemplate struct {
+	// Key will be used as the key to set in the metadata map for cloud-init
+	Key string `json:"key"`
+	// Template is the Go text/template to render. It can refer to
+	// .MachineName, .Metal3MachineName, .ClusterName, .BareMetalHostName,
+	// .BareMetalHostLabels, .BareMetalHostAnnotations,
+	// .BareMetalHostRAMMebibytes and .Index, e.g.
+	// `{{ .ClusterName }}-{{ .MachineName }}-rack{{ .BareMetalHostLabels.rack }}`.
+	// Rendering fails if a referenced map key is missing.
+	Template string `json:"template"`
+}
+
 // MetaDataString contains the information to render the string.
 type MetaDataString struct {
 	// Key will be used as the key to set in the metadata map for cloud-init
@@ -189,6 +203,11 @@ type MetaData struct {
 	// Annotations
 	// +optional
 	FromAnnotations []MetaDataFromAnnotation `json:"fromAnnotations,omitempty"`
+
+	// FromTemplates is the list of metadata items to be rendered from Go
+	// text/templates
+	// +optional
+	FromTemplates []MetaDataFromTemplate `json:"fromTemplates,omitempty"`
 }
 
 // NetworkLinkEthernetMac represents the Mac address content.
@@ -222,6 +241,20 @@ type NetworkDataLinkEthernet struct {
 	// MACAddress is the MAC address of the interface, containing the object
 	// used to render it.
 	MACAddress *NetworkLinkEthernetMac `json:"macAddress"`
+
+	// VendorExtensions contains vendor specific metadata of the link, such as
+	// SR-IOV virtual function hints, rendered as is in the "extra" field of
+	// the link.
+	// +optional
+	VendorExtensions map[string]string `json:"vendorExtensions,omitempty"`
+
+	// DefaultRoutePriority orders the default routes of the networks of the
+	// link against those of the other links, the lowest priority being
+	// preferred. The default routes without a metric are rendered with
+	// increasing metrics following the priorities, which must be unique.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DefaultRoutePriority *int `json:"defaultRoutePriority,omitempty"`
 }
 
 // NetworkDataLinkBond represents a bond link object.
@@ -246,6 +279,14 @@ type NetworkDataLinkBond struct {
 
 	// BondLinks is the list of links that are part of the bond.
 	BondLinks []string `json:"bondLinks"`
+
+	// DefaultRoutePriority orders the default routes of the networks of the
+	// link against those of the other links, the lowest priority being
+	// preferred. The default routes without a metric are rendered with
+	// increasing metrics following the priorities, which must be unique.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DefaultRoutePriority *int `json:"defaultRoutePriority,omitempty"`
 }
 
 // NetworkDataLinkVlan represents a vlan link object.
@@ -269,6 +310,14 @@ type NetworkDataLinkVlan struct {
 
 	// VlanLink is the name of the link on which the vlan should be added
 	VlanLink string `json:"vlanLink"`
+
+	// DefaultRoutePriority orders the default routes of the networks of the
+	// link against those of the other links, the lowest priority being
+	// preferred. The default routes without a metric are rendered with
+	// increasing metrics following the priorities, which must be unique.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DefaultRoutePriority *int `json:"defaultRoutePriority,omitempty"`
 }
 
 // NetworkDataLink contains list of different link objects.
@@ -363,6 +412,20 @@ type NetworkDataRoutev4 struct {
 	// Services is a list of IPv4 services
 	// +optional
 	Services NetworkDataServicev4 `json:"services,omitempty"`
+
+	// Metric is the metric of the route. It takes precedence over the metric
+	// derived from the defaultRoutePriority of the link.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=4294967295
+	// +optional
+	Metric *int `json:"metric,omitempty"`
+
+	// Table is the id of the policy routing table of the route, from 1 to 252.
+	// The route is added to the main table if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=252
+	// +optional
+	Table *int `json:"table,omitempty"`
 }
 
 // NetworkDataRoutev6 represents an ipv6 route object.
@@ -381,6 +444,20 @@ type NetworkDataRoutev6 struct {
 	// Services is a list of IPv6 services
 	// +optional
 	Services NetworkDataServicev6 `json:"services,omitempty"`
+
+	// Metric is the metric of the route. It takes precedence over the metric
+	// derived from the defaultRoutePriority of the link.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=4294967295
+	// +optional
+	Metric *int `json:"metric,omitempty"`
+
+	// Table is the id of the policy routing table of the route, from 1 to 252.
+	// The route is added to the main table if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=252
+	// +optional
+	Table *int `json:"table,omitempty"`
 }
 
 // NetworkDataIPv4 represents an ipv4 static network object.
@@ -401,6 +478,11 @@ type NetworkDataIPv4 struct {
 	// Routes contains a list of IPv4 routes
 	// +optional
 	Routes []NetworkDataRoutev4 `json:"routes,omitempty"`
+
+	// DefaultRoute, if false, suppresses the default routes of the network,
+	// even when their gateway is fetched from a pool.
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
 }
 
 // NetworkDataIPv6 represents an ipv6 static network object.
@@ -421,6 +503,11 @@ type NetworkDataIPv6 struct {
 	// Routes contains a list of IPv6 routes
 	// +optional
 	Routes []NetworkDataRoutev6 `json:"routes,omitempty"`
+
+	// DefaultRoute, if false, suppresses the default routes of the network,
+	// even when their gateway is fetched from a pool.
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
 }
 
 // NetworkDataIPv4DHCP represents an ipv4 DHCP network object.
@@ -435,6 +522,11 @@ type NetworkDataIPv4DHCP struct {
 	// Routes contains a list of IPv4 routes
 	// +optional
 	Routes []NetworkDataRoutev4 `json:"routes,omitempty"`
+
+	// DefaultRoute, if false, suppresses the default routes of the network,
+	// even when their gateway is fetched from a pool.
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
 }
 
 // NetworkDataIPv6DHCP represents an ipv6 DHCP network object.
@@ -449,6 +541,11 @@ type NetworkDataIPv6DHCP struct {
 	// Routes contains a list of IPv6 routes
 	// +optional
 	Routes []NetworkDataRoutev6 `json:"routes,omitempty"`
+
+	// DefaultRoute, if false, suppresses the default routes of the network,
+	// even when their gateway is fetched from a pool.
+	// +optional
+	DefaultRoute *bool `json:"defaultRoute,omitempty"`
 }
 
 // NetworkDataNetwork represents a network object.
@@ -475,8 +572,29 @@ type NetworkDataNetwork struct {
 	IPv6SLAAC []NetworkDataIPv6DHCP `json:"ipv6SLAAC,omitempty"`
 }
 
+// NetworkDataFormat is the format in which the networkData is rendered.
+type NetworkDataFormat string
+
+const (
+	// NetworkDataFormatOpenStack renders the networkData as an OpenStack
+	// network_data.json document. It is the default format.
+	NetworkDataFormatOpenStack NetworkDataFormat = "openstack"
+	// NetworkDataFormatNetplan renders the networkData as a cloud-init network
+	// config version 2 document, in the netplan format.
+	NetworkDataFormatNetplan NetworkDataFormat = "netplan"
+)
+
 // NetworkData represents a networkData object.
 type NetworkData struct {
+	// Format is the format of the rendered networkData, openstack for an
+	// OpenStack network_data.json document, the default, or netplan for a
+	// cloud-init network config version 2 document. The netplan format does
+	// not support the services, the ethernet links of another type than phy,
+	// nor their vendorExtensions.
+	// +kubebuilder:validation:Enum=openstack;netplan
+	// +optional
+	Format NetworkDataFormat `json:"format,omitempty"`
+
 	// Links is a structure containing lists of different types objects
 	// +optional
 	Links NetworkDataLink `json:"links,omitempty"`
@@ -511,6 +629,12 @@ type Metal3DataTemplateSpec struct {
 	// secret
 	// +optional
 	NetworkData *NetworkData `json:"networkData,omitempty"`
+
+	// RerenderOnTemplateChange allows metaData and networkData to be modified,
+	// and re-renders the secrets of the existing Metal3Data in place when
+	// they are. The IP addresses already claimed are kept.
+	// +optional
+	RerenderOnTemplateChange bool `json:"rerenderOnTemplateChange,omitempty"`
 }
 
 // Metal3DataTemplateStatus defines the observed state of Metal3DataTemplate.
@@ -522,6 +646,37 @@ type Metal3DataTemplateStatus struct {
 	// Indexes contains the map of Metal3Machine and index used
 	// +optional
 	Indexes map[string]int `json:"indexes,omitempty"`
+
+	// LastIndexes contains the map of BareMetalHost and last index used, to
+	// allocate the same index when the BareMetalHost is reused.
+	// +optional
+	LastIndexes map[string]int `json:"lastIndexes,omitempty"`
+
+	// IndexSummary summarizes the allocated and free indexes.
+	// +optional
+	IndexSummary *Metal3DataTemplateIndexSummary `json:"indexSummary,omitempty"`
+
+	// Conditions defines current service state of the Metal3DataTemplate.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// Metal3DataTemplateIndexSummary summarizes the indexes allocated from a
+// Metal3DataTemplate.
+type Metal3DataTemplateIndexSummary struct {
+	// Allocated is the number of allocated indexes.
+	Allocated int `json:"allocated"`
+
+	// HighestIndex is the highest allocated index, unset when no index is
+	// allocated.
+	// +optional
+	HighestIndex *int `json:"highestIndex,omitempty"`
+
+	// Free lists the free indexes below the highest allocated index as
+	// comma-separated ranges, e.g. "3,10-19". Only the first ranges are
+	// listed, followed by "...".
+	// +optional
+	Free string `json:"free,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -530,6 +685,9 @@ type Metal3DataTemplateStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:object:root=true
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this template belongs"
+// +kubebuilder:printcolumn:name="Allocations",type="integer",JSONPath=".status.indexSummary.allocated",description="Number of allocated indexes"
+// +kubebuilder:printcolumn:name="Generation",type="integer",JSONPath=".metadata.generation",description="Generation of the Metal3DataTemplate",priority=1
+// +kubebuilder:printcolumn:name="Last Updated",type="date",JSONPath=".status.lastUpdated",description="Time of the last update of the status",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Metal3DataTemplate"
 
 // Metal3DataTemplate is the Schema for the metal3datatemplates API.
@@ -554,6 +712,16 @@ type Metal3DataTemplateList struct {
 	Items           []Metal3DataTemplate `json:"items"`
 }
 
+// GetConditions returns the list of conditions for a Metal3DataTemplate API object.
+func (c *Metal3DataTemplate) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3DataTemplate object.
+func (c *Metal3DataTemplate) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&Metal3DataTemplate{}, &Metal3DataTemplateList{})
 }
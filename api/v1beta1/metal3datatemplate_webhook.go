@@ -14,9 +14,13 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
+	"net"
 	"reflect"
 	"strconv"
+	"text/template"
 
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -53,6 +57,15 @@ func (c *Metal3DataTemplate) ValidateUpdate(old runtime.Object) (admission.Warni
 		return nil, apierrors.NewInternalError(errors.New("unable to convert existing object"))
 	}
 
+	// The rendered secrets follow the modifications of the templates that
+	// re-render them.
+	if c.Spec.RerenderOnTemplateChange {
+		if reflect.DeepEqual(c.Spec, oldM3dt.Spec) {
+			return nil, nil
+		}
+		return nil, c.validate()
+	}
+
 	if !reflect.DeepEqual(c.Spec.MetaData, oldM3dt.Spec.MetaData) {
 		allErrs = append(allErrs,
 			field.Invalid(
@@ -73,10 +86,16 @@ func (c *Metal3DataTemplate) ValidateUpdate(old runtime.Object) (admission.Warni
 		)
 	}
 
-	if len(allErrs) == 0 {
+	if len(allErrs) != 0 {
+		return nil, apierrors.NewInvalid(GroupVersion.WithKind("Metal3Data").GroupKind(), c.Name, allErrs)
+	}
+
+	// Only validate a modified spec, so that templates created before a rule
+	// was added can still be updated, e.g. to remove their finalizer.
+	if reflect.DeepEqual(c.Spec, oldM3dt.Spec) {
 		return nil, nil
 	}
-	return nil, apierrors.NewInvalid(GroupVersion.WithKind("Metal3Data").GroupKind(), c.Name, allErrs)
+	return nil, c.validate()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -84,10 +103,54 @@ func (c *Metal3DataTemplate) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
+const (
+	// maxVendorExtensions is the maximum number of vendor extensions of a link.
+	maxVendorExtensions = 32
+	// maxVendorExtensionsSize is the maximum total size in bytes of the keys
+	// and values of the vendor extensions of a link.
+	maxVendorExtensionsSize = 4096
+	// minMTU and maxMTU are the bounds of the MTU of a link, 1280 being the
+	// minimum MTU of IPv6.
+	minMTU = 1280
+	maxMTU = 9000
+	// maxNameservers is the maximum number of DNS nameservers of a network,
+	// the number supported by the resolver of the nodes.
+	maxNameservers = 3
+	// maxRouteMetric is the maximum metric of a route, a 32-bit unsigned
+	// integer.
+	maxRouteMetric = 4294967295
+	// minRouteTable and maxRouteTable are the bounds of the policy routing
+	// table of a route, the ids above being reserved for the default, main
+	// and local tables.
+	minRouteTable = 1
+	maxRouteTable = 252
+)
+
 func (c *Metal3DataTemplate) validate() error {
 	var allErrs field.ErrorList
 
+	if c.Spec.MetaData != nil {
+		// The templates are only parsed, the objects they refer to are not
+		// known at admission time.
+		for i, entry := range c.Spec.MetaData.FromTemplates {
+			if _, err := template.New(entry.Key).Parse(entry.Template); err != nil {
+				allErrs = append(allErrs, field.Invalid(
+					field.NewPath("spec", "metaData", "fromTemplates", strconv.Itoa(i), "template"),
+					entry.Template, err.Error(),
+				))
+			}
+		}
+	}
+
 	if c.Spec.NetworkData != nil {
+		allErrs = append(allErrs, validateLinks(c.Spec.NetworkData.Links)...)
+		allErrs = append(allErrs, validateNetworks(c.Spec.NetworkData.Networks)...)
+		allErrs = append(allErrs, validateServices(c.Spec.NetworkData.Services.DNS, 0,
+			field.NewPath("spec", "networkData", "services", "dns"),
+		)...)
+		if c.Spec.NetworkData.Format == NetworkDataFormatNetplan {
+			allErrs = append(allErrs, validateNetplan(c.Spec.NetworkData)...)
+		}
 		for i, network := range c.Spec.NetworkData.Networks.IPv4 {
 			if (network.FromPoolRef == nil || network.FromPoolRef.Name == "") && network.IPAddressFromIPPool == "" {
 				allErrs = append(allErrs, field.Required(
@@ -111,3 +174,294 @@ func (c *Metal3DataTemplate) validate() error {
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("Metal3DataTemplate").GroupKind(), c.Name, allErrs)
 }
+
+func validateVendorExtensions(extensions map[string]string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(extensions) > maxVendorExtensions {
+		allErrs = append(allErrs, field.TooMany(fldPath, len(extensions), maxVendorExtensions))
+	}
+	size := 0
+	for k, v := range extensions {
+		size += len(k) + len(v)
+	}
+	if size > maxVendorExtensionsSize {
+		allErrs = append(allErrs, field.TooLong(fldPath, "", maxVendorExtensionsSize))
+	}
+	return allErrs
+}
+
+// validateLinks checks the MTU and the vendor extensions of the links, that
+// the link names are set and unique, that the default route priorities are
+// unique, and that the bonds only aggregate ethernet links of the template.
+func validateLinks(links NetworkDataLink) field.ErrorList {
+	var allErrs field.ErrorList
+	linksPath := field.NewPath("spec", "networkData", "links")
+	names := map[string]bool{}
+	priorities := map[int]bool{}
+	ethernets := map[string]bool{}
+
+	validateName := func(name string, fldPath *field.Path) {
+		switch {
+		case name == "":
+			allErrs = append(allErrs, field.Required(fldPath, "link name must be set"))
+		case names[name]:
+			allErrs = append(allErrs, field.Duplicate(fldPath, name))
+		default:
+			names[name] = true
+		}
+	}
+
+	validatePriority := func(priority *int, fldPath *field.Path) {
+		switch {
+		case priority == nil:
+		case priorities[*priority]:
+			allErrs = append(allErrs, field.Duplicate(fldPath, *priority))
+		default:
+			priorities[*priority] = true
+		}
+	}
+
+	for i, link := range links.Ethernets {
+		fldPath := linksPath.Child("ethernets", strconv.Itoa(i))
+		validateName(link.Id, fldPath.Child("id"))
+		validatePriority(link.DefaultRoutePriority, fldPath.Child("defaultRoutePriority"))
+		ethernets[link.Id] = true
+		allErrs = append(allErrs, validateMTU(link.MTU, fldPath.Child("mtu"))...)
+		allErrs = append(allErrs, validateVendorExtensions(link.VendorExtensions, fldPath.Child("vendorExtensions"))...)
+	}
+	for i, link := range links.Bonds {
+		fldPath := linksPath.Child("bonds", strconv.Itoa(i))
+		validateName(link.Id, fldPath.Child("id"))
+		validatePriority(link.DefaultRoutePriority, fldPath.Child("defaultRoutePriority"))
+		allErrs = append(allErrs, validateMTU(link.MTU, fldPath.Child("mtu"))...)
+		for j, member := range link.BondLinks {
+			if !ethernets[member] {
+				allErrs = append(allErrs, field.NotFound(fldPath.Child("bondLinks", strconv.Itoa(j)), member))
+			}
+		}
+	}
+	for i, link := range links.Vlans {
+		fldPath := linksPath.Child("vlans", strconv.Itoa(i))
+		validateName(link.Id, fldPath.Child("id"))
+		validatePriority(link.DefaultRoutePriority, fldPath.Child("defaultRoutePriority"))
+		allErrs = append(allErrs, validateMTU(link.MTU, fldPath.Child("mtu"))...)
+	}
+	return allErrs
+}
+
+// validateNetplan checks that the networkData only uses constructs that can
+// be rendered in the netplan format: the services are only supported on the
+// routes, the ethernet links must be of type phy without vendorExtensions, and
+// the networks must be set on a link of the template.
+func validateNetplan(networkData *NetworkData) field.ErrorList {
+	var allErrs field.ErrorList
+	networkDataPath := field.NewPath("spec", "networkData")
+	links := map[string]bool{}
+
+	if len(networkData.Services.DNS) > 0 || networkData.Services.DNSFromIPPool != nil {
+		allErrs = append(allErrs, field.Forbidden(networkDataPath.Child("services"),
+			"not supported with the netplan format, set the services on the routes instead",
+		))
+	}
+	for i, link := range networkData.Links.Ethernets {
+		fldPath := networkDataPath.Child("links", "ethernets", strconv.Itoa(i))
+		links[link.Id] = true
+		if link.Type != "phy" {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), link.Type, []string{"phy"}))
+		}
+		if len(link.VendorExtensions) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("vendorExtensions"),
+				"not supported with the netplan format",
+			))
+		}
+	}
+	for _, link := range networkData.Links.Bonds {
+		links[link.Id] = true
+	}
+	for _, link := range networkData.Links.Vlans {
+		links[link.Id] = true
+	}
+
+	validateLink := func(link string, fldPath *field.Path) {
+		if !links[link] {
+			allErrs = append(allErrs, field.NotFound(fldPath, link))
+		}
+	}
+	networksPath := networkDataPath.Child("networks")
+	for i, network := range networkData.Networks.IPv4 {
+		validateLink(network.Link, networksPath.Child("ipv4", strconv.Itoa(i), "link"))
+	}
+	for i, network := range networkData.Networks.IPv4DHCP {
+		validateLink(network.Link, networksPath.Child("ipv4DHCP", strconv.Itoa(i), "link"))
+	}
+	for i, network := range networkData.Networks.IPv6 {
+		validateLink(network.Link, networksPath.Child("ipv6", strconv.Itoa(i), "link"))
+	}
+	for i, network := range networkData.Networks.IPv6DHCP {
+		validateLink(network.Link, networksPath.Child("ipv6DHCP", strconv.Itoa(i), "link"))
+	}
+	for i, network := range networkData.Networks.IPv6SLAAC {
+		validateLink(network.Link, networksPath.Child("ipv6SLAAC", strconv.Itoa(i), "link"))
+	}
+	return allErrs
+}
+
+// validateMTU checks that the MTU is unset or within minMTU and maxMTU.
+func validateMTU(mtu int, fldPath *field.Path) field.ErrorList {
+	if mtu == 0 || (mtu >= minMTU && mtu <= maxMTU) {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(fldPath, mtu,
+		fmt.Sprintf("must be between %d and %d", minMTU, maxMTU),
+	)}
+}
+
+// validateNetworks checks the routes of the networks.
+func validateNetworks(networks NetworkDataNetwork) field.ErrorList {
+	var allErrs field.ErrorList
+	networksPath := field.NewPath("spec", "networkData", "networks")
+
+	for i, network := range networks.IPv4 {
+		allErrs = append(allErrs, validateRoutesv4(network.Routes,
+			networksPath.Child("ipv4", strconv.Itoa(i), "routes"),
+		)...)
+	}
+	for i, network := range networks.IPv4DHCP {
+		allErrs = append(allErrs, validateRoutesv4(network.Routes,
+			networksPath.Child("ipv4DHCP", strconv.Itoa(i), "routes"),
+		)...)
+	}
+	for i, network := range networks.IPv6 {
+		allErrs = append(allErrs, validateRoutesv6(network.Routes,
+			networksPath.Child("ipv6", strconv.Itoa(i), "routes"),
+		)...)
+	}
+	for i, network := range networks.IPv6DHCP {
+		allErrs = append(allErrs, validateRoutesv6(network.Routes,
+			networksPath.Child("ipv6DHCP", strconv.Itoa(i), "routes"),
+		)...)
+	}
+	for i, network := range networks.IPv6SLAAC {
+		allErrs = append(allErrs, validateRoutesv6(network.Routes,
+			networksPath.Child("ipv6SLAAC", strconv.Itoa(i), "routes"),
+		)...)
+	}
+	return allErrs
+}
+
+func validateRoutesv4(routes []NetworkDataRoutev4, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	nameservers := 0
+	for i, route := range routes {
+		routePath := fldPath.Child(strconv.Itoa(i))
+		var gateway *string
+		if route.Gateway.String != nil {
+			gateway = (*string)(route.Gateway.String)
+		}
+		allErrs = append(allErrs, validateRoute(string(route.Network), route.Prefix, gateway, false, routePath)...)
+		allErrs = append(allErrs, validateRouteMetricAndTable(route.Metric, route.Table, routePath)...)
+		dns := make([]ipamv1.IPAddressStr, 0, len(route.Services.DNS))
+		for _, address := range route.Services.DNS {
+			dns = append(dns, ipamv1.IPAddressStr(address))
+		}
+		allErrs = append(allErrs, validateServices(dns, nameservers, routePath.Child("services", "dns"))...)
+		nameservers += len(dns)
+	}
+	return allErrs
+}
+
+func validateRoutesv6(routes []NetworkDataRoutev6, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	nameservers := 0
+	for i, route := range routes {
+		routePath := fldPath.Child(strconv.Itoa(i))
+		var gateway *string
+		if route.Gateway.String != nil {
+			gateway = (*string)(route.Gateway.String)
+		}
+		allErrs = append(allErrs, validateRoute(string(route.Network), route.Prefix, gateway, true, routePath)...)
+		allErrs = append(allErrs, validateRouteMetricAndTable(route.Metric, route.Table, routePath)...)
+		dns := make([]ipamv1.IPAddressStr, 0, len(route.Services.DNS))
+		for _, address := range route.Services.DNS {
+			dns = append(dns, ipamv1.IPAddressStr(address))
+		}
+		allErrs = append(allErrs, validateServices(dns, nameservers, routePath.Child("services", "dns"))...)
+		nameservers += len(dns)
+	}
+	return allErrs
+}
+
+// validateRoute checks that the network of the route and its prefix form a
+// CIDR of the family of the network, and that the gateway, if given as a
+// string, is of the same family.
+func validateRoute(network string, prefix int, gateway *string, ipv6 bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+
+	cidr := fmt.Sprintf("%s/%d", network, prefix)
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("network"), network,
+			fmt.Sprintf("%s is not a valid CIDR", cidr),
+		))
+	} else if isIPv6(ip) != ipv6 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("network"), network,
+			"must be an "+family+" network",
+		))
+	}
+
+	if gateway != nil {
+		gatewayIP := net.ParseIP(*gateway)
+		if gatewayIP == nil || isIPv6(gatewayIP) != ipv6 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("gateway", "string"), *gateway,
+				"must be an "+family+" address, the family of the network",
+			))
+		}
+	}
+	return allErrs
+}
+
+// validateRouteMetricAndTable checks that the metric of the route is a
+// 32-bit unsigned integer, and that its table is between minRouteTable and
+// maxRouteTable.
+func validateRouteMetricAndTable(metric, table *int, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if metric != nil && (*metric < 0 || int64(*metric) > maxRouteMetric) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("metric"), *metric,
+			fmt.Sprintf("must be between 0 and %d", maxRouteMetric),
+		))
+	}
+	if table != nil && (*table < minRouteTable || *table > maxRouteTable) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("table"), *table,
+			fmt.Sprintf("must be between %d and %d", minRouteTable, maxRouteTable),
+		))
+	}
+	return allErrs
+}
+
+// validateServices checks that the DNS nameservers are valid addresses, and
+// that with the count nameservers already configured on the network, there
+// are at most maxNameservers.
+func validateServices(dns []ipamv1.IPAddressStr, count int, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, address := range dns {
+		switch {
+		case address == "":
+			allErrs = append(allErrs, field.Required(fldPath.Child(strconv.Itoa(i)), "address must be set"))
+		case net.ParseIP(string(address)) == nil:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(strconv.Itoa(i)), address, "not a valid IP address"))
+		}
+	}
+	if count+len(dns) > maxNameservers {
+		allErrs = append(allErrs, field.TooMany(fldPath, count+len(dns), maxNameservers))
+	}
+	return allErrs
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
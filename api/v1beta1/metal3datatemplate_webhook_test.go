@@ -14,11 +14,14 @@ limitations under the License.
 package v1beta1
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
 func TestMetal3DataTemplateDefault(t *testing.T) {
@@ -37,6 +40,57 @@ func TestMetal3DataTemplateDefault(t *testing.T) {
 }
 
 func TestMetal3DataTemplateValidation(t *testing.T) {
+	ethernetWithExtensions := func(extensions map[string]string) *NetworkData {
+		return &NetworkData{
+			Links: NetworkDataLink{
+				Ethernets: []NetworkDataLinkEthernet{
+					{
+						Type:             "phy",
+						Id:               "eth0",
+						VendorExtensions: extensions,
+					},
+				},
+			},
+		}
+	}
+	tooManyExtensions := map[string]string{}
+	for i := 0; i <= maxVendorExtensions; i++ {
+		tooManyExtensions["key"+strconv.Itoa(i)] = "value"
+	}
+
+	withNetworkData := func(networkData *NetworkData) *Metal3DataTemplate {
+		return &Metal3DataTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "foo",
+			},
+			Spec: Metal3DataTemplateSpec{
+				NetworkData: networkData,
+			},
+		}
+	}
+	withRoutesv4 := func(routes ...NetworkDataRoutev4) *Metal3DataTemplate {
+		return withNetworkData(&NetworkData{
+			Networks: NetworkDataNetwork{
+				IPv4DHCP: []NetworkDataIPv4DHCP{{ID: "net0", Link: "eth0", Routes: routes}},
+			},
+		})
+	}
+	withRoutesv6 := func(routes ...NetworkDataRoutev6) *Metal3DataTemplate {
+		return withNetworkData(&NetworkData{
+			Networks: NetworkDataNetwork{
+				IPv6SLAAC: []NetworkDataIPv6DHCP{{ID: "net0", Link: "eth0", Routes: routes}},
+			},
+		})
+	}
+	gatewayv4 := func(gateway string) NetworkGatewayv4 {
+		address := ipamv1.IPAddressv4Str(gateway)
+		return NetworkGatewayv4{String: &address}
+	}
+	gatewayv6 := func(gateway string) NetworkGatewayv6 {
+		address := ipamv1.IPAddressv6Str(gateway)
+		return NetworkGatewayv6{String: &address}
+	}
+
 	tests := []struct {
 		name      string
 		expectErr bool
@@ -52,6 +106,353 @@ func TestMetal3DataTemplateValidation(t *testing.T) {
 				Spec: Metal3DataTemplateSpec{},
 			},
 		},
+		{
+			name:      "should succeed with vendor extensions on an ethernet link",
+			expectErr: false,
+			c: &Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: Metal3DataTemplateSpec{
+					NetworkData: ethernetWithExtensions(map[string]string{
+						"sriov_numvfs": "8",
+						"sriov_trust":  "on",
+					}),
+				},
+			},
+		},
+		{
+			name:      "should fail with too many vendor extensions",
+			expectErr: true,
+			c: &Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: Metal3DataTemplateSpec{
+					NetworkData: ethernetWithExtensions(tooManyExtensions),
+				},
+			},
+		},
+		{
+			name:      "should fail with too large vendor extensions",
+			expectErr: true,
+			c: &Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: Metal3DataTemplateSpec{
+					NetworkData: ethernetWithExtensions(map[string]string{
+						"blob": strings.Repeat("a", maxVendorExtensionsSize),
+					}),
+				},
+			},
+		},
+		{
+			name:      "should succeed with a valid metadata template",
+			expectErr: false,
+			c: &Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: Metal3DataTemplateSpec{
+					MetaData: &MetaData{
+						FromTemplates: []MetaDataFromTemplate{
+							{Key: "name", Template: "{{ .ClusterName }}-{{ .MachineName }}-rack{{ .BareMetalHostLabels.rack }}"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with an unparsable metadata template",
+			expectErr: true,
+			c: &Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: Metal3DataTemplateSpec{
+					MetaData: &MetaData{
+						FromTemplates: []MetaDataFromTemplate{
+							{Key: "name", Template: "{{ .ClusterName "},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed with valid links",
+			expectErr: false,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{
+						{Type: "phy", Id: "eth0", MTU: 1280},
+						{Type: "phy", Id: "eth1", MTU: 9000},
+					},
+					Bonds: []NetworkDataLinkBond{
+						{BondMode: "802.3ad", Id: "bond0", MTU: 1500, BondLinks: []string{"eth0", "eth1"}},
+					},
+					Vlans: []NetworkDataLinkVlan{
+						{VlanID: 10, Id: "vlan10", VlanLink: "bond0"},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should fail with a too small ethernet MTU",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0", MTU: 1279}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with a too large bond MTU",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0"}},
+					Bonds: []NetworkDataLinkBond{
+						{BondMode: "802.3ad", Id: "bond0", MTU: 9001, BondLinks: []string{"eth0"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should fail with a negative vlan MTU",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Vlans: []NetworkDataLinkVlan{{VlanID: 10, Id: "vlan10", MTU: -1, VlanLink: "eth0"}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with an empty link name",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: ""}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with duplicate link names",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0"}},
+					Vlans:     []NetworkDataLinkVlan{{VlanID: 10, Id: "eth0", VlanLink: "eth0"}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with a bond member that is not an ethernet",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0"}},
+					Bonds: []NetworkDataLinkBond{
+						{BondMode: "802.3ad", Id: "bond0", BondLinks: []string{"eth0", "eth1"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should succeed with unique default route priorities",
+			expectErr: false,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{
+						{Type: "phy", Id: "eth0", DefaultRoutePriority: pointer.Int(0)},
+						{Type: "phy", Id: "eth1"},
+					},
+					Vlans: []NetworkDataLinkVlan{
+						{VlanID: 10, Id: "vlan10", VlanLink: "eth1", DefaultRoutePriority: pointer.Int(1)},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should fail with duplicate default route priorities",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{
+						{Type: "phy", Id: "eth0", DefaultRoutePriority: pointer.Int(1)},
+						{Type: "phy", Id: "eth1"},
+					},
+					Bonds: []NetworkDataLinkBond{
+						{BondMode: "802.3ad", Id: "bond0", BondLinks: []string{"eth1"}, DefaultRoutePriority: pointer.Int(1)},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should succeed with valid IPv4 routes",
+			expectErr: false,
+			c: withRoutesv4(
+				NetworkDataRoutev4{Network: "0.0.0.0", Prefix: 0, Gateway: gatewayv4("192.168.0.1")},
+				NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 8, Gateway: NetworkGatewayv4{FromIPPool: pointer.String("pool")}},
+			),
+		},
+		{
+			name:      "should fail with an invalid IPv4 route network",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "10.0.0", Prefix: 8, Gateway: gatewayv4("10.0.0.1")}),
+		},
+		{
+			name:      "should fail with an IPv4 route prefix out of range",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 33, Gateway: gatewayv4("10.0.0.1")}),
+		},
+		{
+			name:      "should fail with an IPv6 network in an IPv4 route",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "fd00::", Prefix: 64, Gateway: gatewayv4("10.0.0.1")}),
+		},
+		{
+			name:      "should fail with an IPv6 gateway in an IPv4 route",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 8, Gateway: gatewayv4("fd00::1")}),
+		},
+		{
+			name:      "should succeed with a valid IPv6 route",
+			expectErr: false,
+			c:         withRoutesv6(NetworkDataRoutev6{Network: "fd00::", Prefix: 64, Gateway: gatewayv6("fd00::1")}),
+		},
+		{
+			name:      "should fail with an IPv4 gateway in an IPv6 route",
+			expectErr: true,
+			c:         withRoutesv6(NetworkDataRoutev6{Network: "fd00::", Prefix: 64, Gateway: gatewayv6("10.0.0.1")}),
+		},
+		{
+			name:      "should succeed with route metrics and tables in range",
+			expectErr: false,
+			c: withRoutesv4(
+				NetworkDataRoutev4{Network: "0.0.0.0", Gateway: gatewayv4("192.168.0.1"), Metric: pointer.Int(0), Table: pointer.Int(1)},
+				NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 8, Gateway: gatewayv4("192.168.0.1"), Metric: pointer.Int(4294967295), Table: pointer.Int(252)},
+			),
+		},
+		{
+			name:      "should fail with a negative route metric",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "0.0.0.0", Gateway: gatewayv4("192.168.0.1"), Metric: pointer.Int(-1)}),
+		},
+		{
+			name:      "should fail with a route metric above 4294967295",
+			expectErr: true,
+			c:         withRoutesv6(NetworkDataRoutev6{Network: "::", Gateway: gatewayv6("fd00::1"), Metric: pointer.Int(4294967296)}),
+		},
+		{
+			name:      "should fail with the route table 0",
+			expectErr: true,
+			c:         withRoutesv4(NetworkDataRoutev4{Network: "0.0.0.0", Gateway: gatewayv4("192.168.0.1"), Table: pointer.Int(0)}),
+		},
+		{
+			name:      "should fail with a reserved route table",
+			expectErr: true,
+			c:         withRoutesv6(NetworkDataRoutev6{Network: "::", Gateway: gatewayv6("fd00::1"), Table: pointer.Int(254)}),
+		},
+		{
+			name:      "should succeed with three nameservers on a network",
+			expectErr: false,
+			c: withRoutesv4(
+				NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 8, Gateway: gatewayv4("10.0.0.1"),
+					Services: NetworkDataServicev4{DNS: []ipamv1.IPAddressv4Str{"8.8.8.8", "8.8.4.4"}}},
+				NetworkDataRoutev4{Network: "0.0.0.0", Prefix: 0, Gateway: gatewayv4("10.0.0.1"),
+					Services: NetworkDataServicev4{DNS: []ipamv1.IPAddressv4Str{"1.1.1.1"}}},
+			),
+		},
+		{
+			name:      "should fail with more than three nameservers on a network",
+			expectErr: true,
+			c: withRoutesv4(
+				NetworkDataRoutev4{Network: "10.0.0.0", Prefix: 8, Gateway: gatewayv4("10.0.0.1"),
+					Services: NetworkDataServicev4{DNS: []ipamv1.IPAddressv4Str{"8.8.8.8", "8.8.4.4"}}},
+				NetworkDataRoutev4{Network: "0.0.0.0", Prefix: 0, Gateway: gatewayv4("10.0.0.1"),
+					Services: NetworkDataServicev4{DNS: []ipamv1.IPAddressv4Str{"1.1.1.1", "1.0.0.1"}}},
+			),
+		},
+		{
+			name:      "should fail with more than three global nameservers",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Services: NetworkDataService{
+					DNS: []ipamv1.IPAddressStr{"8.8.8.8", "8.8.4.4", "1.1.1.1", "fd00::53"},
+				},
+			}),
+		},
+		{
+			name:      "should fail with an empty nameserver address",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Services: NetworkDataService{DNS: []ipamv1.IPAddressStr{""}},
+			}),
+		},
+		{
+			name:      "should fail with an invalid nameserver address",
+			expectErr: true,
+			c: withRoutesv6(NetworkDataRoutev6{Network: "fd00::", Prefix: 64, Gateway: gatewayv6("fd00::1"),
+				Services: NetworkDataServicev6{DNS: []ipamv1.IPAddressv6Str{"fd00::zz"}}}),
+		},
+		{
+			name:      "should succeed with a netplan compatible networkData",
+			expectErr: false,
+			c: withNetworkData(&NetworkData{
+				Format: NetworkDataFormatNetplan,
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0"}},
+					Vlans:     []NetworkDataLinkVlan{{VlanID: 10, Id: "vlan10", VlanLink: "eth0"}},
+				},
+				Networks: NetworkDataNetwork{
+					IPv4DHCP: []NetworkDataIPv4DHCP{{ID: "net0", Link: "vlan10", Routes: []NetworkDataRoutev4{
+						{Network: "0.0.0.0", Gateway: gatewayv4("10.0.0.1"),
+							Services: NetworkDataServicev4{DNS: []ipamv1.IPAddressv4Str{"8.8.8.8"}}},
+					}}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with global services in the netplan format",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Format:   NetworkDataFormatNetplan,
+				Services: NetworkDataService{DNS: []ipamv1.IPAddressStr{"8.8.8.8"}},
+			}),
+		},
+		{
+			name:      "should fail with a non phy ethernet in the netplan format",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Format: NetworkDataFormatNetplan,
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{{Type: "tap", Id: "eth0"}},
+				},
+			}),
+		},
+		{
+			name:      "should fail with vendor extensions in the netplan format",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Format: NetworkDataFormatNetplan,
+				Links: NetworkDataLink{
+					Ethernets: []NetworkDataLinkEthernet{
+						{Type: "phy", Id: "eth0", VendorExtensions: map[string]string{"vf": "1"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should fail with a network on an undefined link in the netplan format",
+			expectErr: true,
+			c: withNetworkData(&NetworkData{
+				Format: NetworkDataFormatNetplan,
+				Networks: NetworkDataNetwork{
+					IPv6SLAAC: []NetworkDataIPv6DHCP{{ID: "net0", Link: "eth0"}},
+				},
+			}),
+		},
 	}
 
 	for _, tt := range tests {
@@ -84,6 +485,39 @@ func TestMetal3DataTemplateUpdateValidation(t *testing.T) {
 			new:       &Metal3DataTemplateSpec{},
 			old:       &Metal3DataTemplateSpec{},
 		},
+		{
+			name:      "should succeed when an invalid spec is not modified",
+			expectErr: false,
+			new: &Metal3DataTemplateSpec{
+				NetworkData: &NetworkData{
+					Links: NetworkDataLink{
+						Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0", MTU: 100}},
+					},
+				},
+			},
+			old: &Metal3DataTemplateSpec{
+				NetworkData: &NetworkData{
+					Links: NetworkDataLink{
+						Ethernets: []NetworkDataLinkEthernet{{Type: "phy", Id: "eth0", MTU: 100}},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when a modified spec is invalid",
+			expectErr: true,
+			new: &Metal3DataTemplateSpec{
+				ClusterName: "abc",
+				MetaData: &MetaData{
+					FromTemplates: []MetaDataFromTemplate{{Key: "name", Template: "{{ .ClusterName "}},
+				},
+			},
+			old: &Metal3DataTemplateSpec{
+				MetaData: &MetaData{
+					FromTemplates: []MetaDataFromTemplate{{Key: "name", Template: "{{ .ClusterName "}},
+				},
+			},
+		},
 		{
 			name:      "should fail when old is nil",
 			expectErr: true,
@@ -110,6 +544,44 @@ func TestMetal3DataTemplateUpdateValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should succeed when Metadata value changes with rerenderOnTemplateChange",
+			expectErr: false,
+			new: &Metal3DataTemplateSpec{
+				RerenderOnTemplateChange: true,
+				MetaData: &MetaData{
+					Strings: []MetaDataString{{
+						Key:   "abc",
+						Value: "def",
+					}},
+				},
+				NetworkData: &NetworkData{
+					Services: NetworkDataService{DNS: []ipamv1.IPAddressStr{"8.8.8.8"}},
+				},
+			},
+			old: &Metal3DataTemplateSpec{
+				RerenderOnTemplateChange: true,
+				MetaData: &MetaData{
+					Strings: []MetaDataString{{
+						Key:   "abc",
+						Value: "defg",
+					}},
+				},
+			},
+		},
+		{
+			name:      "should fail when a modified spec is invalid with rerenderOnTemplateChange",
+			expectErr: true,
+			new: &Metal3DataTemplateSpec{
+				RerenderOnTemplateChange: true,
+				MetaData: &MetaData{
+					FromTemplates: []MetaDataFromTemplate{{Key: "name", Template: "{{ .ClusterName "}},
+				},
+			},
+			old: &Metal3DataTemplateSpec{
+				RerenderOnTemplateChange: true,
+			},
+		},
 		{
 			name:      "should fail when Metadata types changes",
 			expectErr: true,
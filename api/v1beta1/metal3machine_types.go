@@ -31,6 +31,73 @@ const (
 	CleaningModeMetadata = "metadata"
 	ClonedFromGroupKind  = "Metal3MachineTemplate.infrastructure.cluster.x-k8s.io"
 	LiveIsoDiskFormat    = "live-iso"
+	// ClearFailureAnnotation can be set on a Metal3Machine to clear once the failure
+	// fields set by older versions on the Metal3Machine and its owner Machine.
+	// The annotation is removed by the controller after the fields are cleared.
+	ClearFailureAnnotation = "metal3machine.infrastructure.cluster.x-k8s.io/clear-failure"
+	// PreferredDataIndexAnnotation can be set on a Metal3Machine to the index its
+	// Metal3Data should get from the Metal3DataTemplate, at most
+	// MaxPreferredDataIndex. The first free index is allocated instead if the
+	// preferred index is taken or invalid.
+	PreferredDataIndexAnnotation = "infrastructure.cluster.x-k8s.io/preferred-data-index"
+	// MaxPreferredDataIndex is the highest index that can be preferred with the
+	// PreferredDataIndexAnnotation, so that the names and addresses derived from
+	// the index stay in range.
+	MaxPreferredDataIndex = 65535
+	// ForcePowerOffAnnotation can be set on a Metal3Machine of a control plane
+	// to allow setting its powerState to off.
+	ForcePowerOffAnnotation = "metal3machine.infrastructure.cluster.x-k8s.io/force-power-off"
+	// HostSelectionDebugAnnotation can be set to "true" on a Metal3Machine to
+	// get, in the HostSelectionReportAnnotation, the reason each
+	// BareMetalHost was rejected the next time a host is chosen.
+	HostSelectionDebugAnnotation = "infrastructure.cluster.x-k8s.io/host-selection-debug"
+	// HostSelectionReportAnnotation is set by the controller on a
+	// Metal3Machine with the HostSelectionDebugAnnotation to a JSON summary of
+	// the last host selection.
+	HostSelectionReportAnnotation = "infrastructure.cluster.x-k8s.io/host-selection-report"
+	// AdoptHostAnnotation can be set on a Metal3Machine to the name of an
+	// externally provisioned BareMetalHost to adopt. The host is associated
+	// with the Metal3Machine without being provisioned, and the Metal3Machine
+	// is ready once the Node named after the host exists in the workload
+	// cluster.
+	AdoptHostAnnotation = "infrastructure.cluster.x-k8s.io/adopt-host"
+	// DeprovisionAdoptedHostAnnotation can be set on a Metal3Machine with the
+	// AdoptHostAnnotation to deprovision the adopted BareMetalHost when the
+	// Metal3Machine is deleted. Without it, the host is only released.
+	DeprovisionAdoptedHostAnnotation = "infrastructure.cluster.x-k8s.io/deprovision-adopted-host"
+	// NodeLabelsFromMetal3MachineAnnotation is set by the controller on the
+	// Node of a Metal3Machine to the comma separated keys of the labels it set
+	// from the nodeMetadata, so that they are removed once removed from it.
+	NodeLabelsFromMetal3MachineAnnotation = "infrastructure.cluster.x-k8s.io/labels-from-metal3machine"
+	// NodeAnnotationsFromMetal3MachineAnnotation is set by the controller on
+	// the Node of a Metal3Machine to the comma separated keys of the
+	// annotations it set from the nodeMetadata.
+	NodeAnnotationsFromMetal3MachineAnnotation = "infrastructure.cluster.x-k8s.io/annotations-from-metal3machine"
+)
+
+// PowerState is the desired power state of the BareMetalHost of a
+// Metal3Machine once it is provisioned.
+type PowerState string
+
+const (
+	// PowerStateOn powers the BareMetalHost on once it is provisioned.
+	PowerStateOn PowerState = "on"
+	// PowerStateOff keeps the BareMetalHost powered off once it is
+	// provisioned.
+	PowerStateOff PowerState = "off"
+)
+
+// PowerManagementPolicy is the policy powering on the BareMetalHost of a
+// Metal3Machine.
+type PowerManagementPolicy string
+
+const (
+	// PowerManagementPolicyAlwaysOn powers the BareMetalHost on as soon as it
+	// is associated with the Metal3Machine.
+	PowerManagementPolicyAlwaysOn PowerManagementPolicy = "AlwaysOn"
+	// PowerManagementPolicyOnDemand keeps the BareMetalHost powered off until
+	// it is provisioned, and powers it off once it is deprovisioned.
+	PowerManagementPolicyOnDemand PowerManagementPolicy = "OnDemand"
 )
 
 // Metal3MachineSpec defines the desired state of Metal3Machine.
@@ -40,9 +107,17 @@ type Metal3MachineSpec struct {
 	// +optional
 	ProviderID *string `json:"providerID,omitempty"`
 
-	// Image is the image to be provisioned.
+	// Image is the image to be provisioned. It must be set unless
+	// customDeploy is set.
+	// +optional
 	Image Image `json:"image"`
 
+	// CustomDeploy is the custom deploy method set on the BareMetalHost
+	// instead of the image, run by a custom deploy ramdisk. It can not be set
+	// along with the image.
+	// +optional
+	CustomDeploy *CustomDeploy `json:"customDeploy,omitempty"`
+
 	// UserData references the Secret that holds user data needed by the bare metal
 	// operator. The Namespace is optional; it will default to the metal3machine's
 	// namespace if not specified.
@@ -76,6 +151,133 @@ type Metal3MachineSpec struct {
 	// +kubebuilder:validation:Enum:=metadata;disabled
 	// +optional
 	AutomatedCleaningMode *string `json:"automatedCleaningMode,omitempty"`
+
+	// NodeReuseGroup is the value of the node reuse label set on the
+	// BareMetalHost when it is released with node reuse enabled. When set, it
+	// replaces the KubeadmControlPlane or MachineDeployment name, so that hosts
+	// can be reused across owners sharing the same group, for example when
+	// renaming a MachineDeployment. Hosts labeled for another group are never
+	// picked.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`
+	// +optional
+	NodeReuseGroup string `json:"nodeReuseGroup,omitempty"`
+
+	// Bootstrapless provisions the BareMetalHost without user data, for images
+	// that do not need to be bootstrapped, e.g. with baked-in ignition. The
+	// Metal3Machine does not wait for the bootstrap data of the Machine, whose
+	// spec.bootstrap.dataSecretName can be set to "". It is only honored if
+	// allowBootstrapless is set on the Metal3Cluster.
+	// +optional
+	Bootstrapless bool `json:"bootstrapless,omitempty"`
+
+	// Metal3DrainTimeout enables the drain of the Node before the
+	// BareMetalHost is deprovisioned, for deletions that are not drained by
+	// the Machine controller, e.g. a Metal3Machine deleted directly. The Node
+	// is cordoned and its pods, except DaemonSet and mirror pods, are evicted.
+	// The deprovisioning proceeds once the drain completes and the volumes of
+	// the Node are detached, the timeouts expire, or the workload cluster is
+	// unreachable. The nodeDrainTimeout of the Machine, when set, takes
+	// precedence over this timeout.
+	// +optional
+	Metal3DrainTimeout *metav1.Duration `json:"metal3DrainTimeout,omitempty"`
+
+	// HostNamespace is the namespace of the BareMetalHosts the Metal3Machine
+	// can consume, when they are kept in a namespace other than the one of
+	// the Metal3Machine. The namespace must be allowed with the
+	// --bmh-namespaces flag of the controller. When unset, the hosts are
+	// chosen in the namespace of the Metal3Machine and in the allowed
+	// namespaces.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	HostNamespace string `json:"hostNamespace,omitempty"`
+
+	// HostTolerations are the keys of the taints, set on BareMetalHosts with
+	// the capm3.metal3.io/taints annotation, that the Metal3Machine tolerates.
+	// A tainted BareMetalHost is only chosen if all its taints are tolerated.
+	// +optional
+	HostTolerations []string `json:"hostTolerations,omitempty"`
+
+	// RootDeviceHints are set on the BareMetalHost when it is associated, if
+	// it does not set its own, and cleared when it is released. Hints already
+	// set on the BareMetalHost are kept.
+	// +optional
+	RootDeviceHints *RootDeviceHints `json:"rootDeviceHints,omitempty"`
+
+	// PowerState is the power state of the BareMetalHost once it is
+	// provisioned, on by default. A Metal3Machine kept powered off is ready
+	// once its BareMetalHost is provisioned, and runs no Node until it is
+	// powered on. Control plane Metal3Machines can only be powered off with
+	// the force-power-off annotation.
+	// +kubebuilder:validation:Enum=on;off
+	// +optional
+	PowerState PowerState `json:"powerState,omitempty"`
+
+	// UserDataAppend references a template, in a ConfigMap, appended to the
+	// cloud-config bootstrap data of the Machine before it is set on the
+	// BareMetalHost, e.g. to add host specific kernel arguments. It is
+	// rendered with the same fields as the metadata templates of a
+	// Metal3DataTemplate. It is only supported with cloud-config bootstrap
+	// data.
+	// +optional
+	UserDataAppend *UserDataAppend `json:"userDataAppend,omitempty"`
+
+	// PowerManagementPolicy is AlwaysOn by default, the BareMetalHost is
+	// powered on as soon as it is associated. With OnDemand, the
+	// BareMetalHost is kept powered off until its image and bootstrap data
+	// are set, and powered off once it is deprovisioned.
+	// +kubebuilder:validation:Enum=AlwaysOn;OnDemand
+	// +optional
+	PowerManagementPolicy PowerManagementPolicy `json:"powerManagementPolicy,omitempty"`
+
+	// NodeMetadata holds the labels and annotations set on the Node of the
+	// Metal3Machine once it appears in the workload cluster. They are kept
+	// reconciled, the keys removed from nodeMetadata are removed from the
+	// Node. Labels and annotations set on the Node by others are not
+	// overwritten.
+	// +optional
+	NodeMetadata *NodeMetadata `json:"nodeMetadata,omitempty"`
+
+	// FirmwareSettings are the firmware (BIOS) settings, by name, set in the
+	// HostFirmwareSettings of the BareMetalHost before it is provisioned,
+	// e.g. to enable SR-IOV. The provisioning waits until the settings are
+	// validated against the FirmwareSchema of the host. The previous values
+	// are restored when the host is released.
+	// +optional
+	FirmwareSettings map[string]string `json:"firmwareSettings,omitempty"`
+}
+
+// NodeMetadata holds the labels and annotations set on a Node.
+type NodeMetadata struct {
+	// Labels are set on the Node.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are set on the Node.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// UserDataAppend references the Go text/template appended to the bootstrap
+// data.
+type UserDataAppend struct {
+	// Name is the name of the ConfigMap, in the namespace of the
+	// Metal3Machine.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the template in the ConfigMap, userData by default.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ConfigMapKey returns the key of the template in the ConfigMap.
+func (u *UserDataAppend) ConfigMapKey() string {
+	if u.Key == "" {
+		return UserDataAppendDefaultKey
+	}
+	return u.Key
 }
 
 // Metal3MachineStatus defines the observed state of Metal3Machine.
@@ -160,11 +362,67 @@ type Metal3MachineStatus struct {
 	// network data used to deploy the BareMetalHost.
 	// +optional
 	NetworkData *corev1.SecretReference `json:"networkData,omitempty"`
+
+	// RenderedHost mirrors the BareMetalHost associated with the
+	// Metal3Machine, for scheduling and debugging. It is refreshed when the
+	// status of the BareMetalHost changes and cleared on disassociation.
+	// +optional
+	RenderedHost *RenderedHost `json:"renderedHost,omitempty"`
+
+	// EstimatedReadyTime is the estimated time at which the Metal3Machine
+	// will be ready, while it is provisioning. It is based on the recent
+	// provisioning durations of the Metal3Machines of the cluster, and is
+	// empty until one was recorded.
+	// +optional
+	EstimatedReadyTime *metav1.Time `json:"estimatedReadyTime,omitempty"`
+
+	// FailureDomain is the failure domain of the BareMetalHost of the
+	// Metal3Machine, the value of its failureDomainLabel set in the
+	// Metal3Cluster.
+	// +optional
+	FailureDomain string `json:"failureDomain,omitempty"`
+
 	// Conditions defines current service state of the Metal3Machine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
+// RenderedHost is a summary of the BareMetalHost of a Metal3Machine, copied
+// from its status.
+type RenderedHost struct {
+	// Name is the name of the BareMetalHost.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the BareMetalHost.
+	Namespace string `json:"namespace"`
+
+	// HardwareProfile is the name of the hardware profile of the
+	// BareMetalHost.
+	// +optional
+	HardwareProfile string `json:"hardwareProfile,omitempty"`
+
+	// CPUCount is the number of CPUs of the BareMetalHost.
+	// +optional
+	CPUCount int `json:"cpuCount,omitempty"`
+
+	// RAMMebibytes is the amount of RAM of the BareMetalHost, in MiB.
+	// +optional
+	RAMMebibytes int `json:"ramMebibytes,omitempty"`
+
+	// StorageDevices is the number of storage devices of the BareMetalHost.
+	// +optional
+	StorageDevices int `json:"storageDevices,omitempty"`
+
+	// MACAddresses is the list of the MAC addresses of the NICs of the
+	// BareMetalHost.
+	// +optional
+	MACAddresses []string `json:"macAddresses,omitempty"`
+
+	// ProvisioningState is the provisioning state of the BareMetalHost.
+	// +optional
+	ProvisioningState string `json:"provisioningState,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:path=metal3machines,scope=Namespaced,categories=cluster-api,shortName=m3m;m3machine;m3machines;metal3m;metal3machine
 // +kubebuilder:object:root=true
@@ -14,10 +14,22 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -25,6 +37,7 @@ import (
 func (c *Metal3Machine) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(c).
+		WithValidator(&metal3MachineValidator{reader: mgr.GetClient()}).
 		Complete()
 }
 
@@ -33,18 +46,43 @@ func (c *Metal3Machine) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 var _ webhook.Defaulter = &Metal3Machine{}
 var _ webhook.Validator = &Metal3Machine{}
+var _ webhook.CustomValidator = &metal3MachineValidator{}
+
+// metal3MachineValidator validates the Metal3Machines. Its reader gets the
+// Clusters and Metal3Clusters of the Metal3Machines, to validate them against
+// the policies of their Metal3Cluster. Without reader, the policies are only
+// enforced by the controller.
+type metal3MachineValidator struct {
+	reader client.Reader
+}
 
+// Default sets the defaults of the spec. The controllers never write them,
+// they read the spec of the Metal3Machines stored before with WithDefaults.
 func (c *Metal3Machine) Default() {
+	c.Spec = *c.Spec.WithDefaults(c.Namespace)
+}
+
+// WithDefaults returns a copy of the spec with the defaults set by the webhook
+// for a Metal3Machine in the given namespace.
+func (s *Metal3MachineSpec) WithDefaults(namespace string) *Metal3MachineSpec {
+	spec := s.DeepCopy()
+	if spec.DataTemplate != nil && spec.DataTemplate.Namespace == "" {
+		spec.DataTemplate.Namespace = namespace
+	}
+	if spec.Image.ChecksumType != nil && *spec.Image.ChecksumType == "" {
+		spec.Image.ChecksumType = nil
+	}
+	return spec
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (c *Metal3Machine) ValidateCreate() (admission.Warnings, error) {
-	return nil, c.validate()
+	return (&metal3MachineValidator{}).ValidateCreate(context.TODO(), c)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
-func (c *Metal3Machine) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
-	return nil, c.validate()
+func (c *Metal3Machine) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	return (&metal3MachineValidator{}).ValidateUpdate(context.TODO(), old, c)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -52,13 +90,297 @@ func (c *Metal3Machine) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
-func (c *Metal3Machine) validate() error {
+// ValidateCreate implements webhook.CustomValidator.
+func (v *metal3MachineValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	c, ok := obj.(*Metal3Machine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Metal3Machine but got a %T", obj))
+	}
+	return nil, c.validate(ctx, nil, v.reader)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *metal3MachineValidator) ValidateUpdate(ctx context.Context, old, obj runtime.Object) (admission.Warnings, error) {
+	c, ok := obj.(*Metal3Machine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a Metal3Machine but got a %T", obj))
+	}
+	var warnings admission.Warnings
+	oldM3m, ok := old.(*Metal3Machine)
+	if ok && oldM3m != nil {
+		oldFormat, format := providerIDFormat(oldM3m.Spec.ProviderID), providerIDFormat(c.Spec.ProviderID)
+		if oldFormat != "" && format != "" && oldFormat != format {
+			warnings = append(warnings, fmt.Sprintf("the providerID changes from the %s format to the %s format, "+
+				"the providerID of an existing Node can not be changed, the Machine must be replaced for its Node to use the new format",
+				oldFormat, format))
+		}
+		// The adopted host is only chosen on association, the annotation
+		// can not be changed afterwards.
+		if c.Annotations[AdoptHostAnnotation] != oldM3m.Annotations[AdoptHostAnnotation] {
+			return warnings, apierrors.NewInvalid(GroupVersion.WithKind("Metal3Machine").GroupKind(), c.Name, field.ErrorList{
+				field.Forbidden(field.NewPath("Metadata", "Annotations").Key(AdoptHostAnnotation), "the annotation is immutable"),
+			})
+		}
+		// Objects stored before a validation rule was added can still be
+		// updated without changing their spec, e.g. to remove their
+		// finalizer when they are deleted. The spec was defaulted before
+		// the validation, the old one may not be.
+		if !c.DeletionTimestamp.IsZero() || reflect.DeepEqual(c.Spec, *oldM3m.Spec.WithDefaults(oldM3m.Namespace)) {
+			return warnings, nil
+		}
+	}
+	return warnings, c.validate(ctx, oldM3m, v.reader)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *metal3MachineValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate validates the spec of the Metal3Machine, and the policies of its
+// Metal3Cluster, read with the given reader, on creation or when the secrets
+// they cover change.
+func (c *Metal3Machine) validate(ctx context.Context, old *Metal3Machine, reader client.Reader) error {
 	var allErrs field.ErrorList
 
-	allErrs = append(allErrs, c.Spec.Image.Validate(*field.NewPath("Spec", "Image"))...)
+	allErrs = append(allErrs, c.Spec.validateDeployment(field.NewPath("Spec"))...)
+	allErrs = append(allErrs, c.Spec.validateHostTolerations(field.NewPath("Spec"))...)
+	allErrs = append(allErrs, c.Spec.validateHostSelector(field.NewPath("Spec"))...)
+	allErrs = append(allErrs, c.Spec.validateNodeMetadata(field.NewPath("Spec"))...)
+	allErrs = append(allErrs, c.Spec.validateFirmwareSettings(field.NewPath("Spec"))...)
+	allErrs = append(allErrs, c.Spec.validateDataTemplate(field.NewPath("Spec"), c.Namespace)...)
+
+	// A live-iso image is booted without user data, the machine can not
+	// bootstrap a control plane node.
+	if c.Spec.Image.DiskFormat != nil && *c.Spec.Image.DiskFormat == LiveISODiskFormat && c.isControlPlane() {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("Spec", "Image", "DiskFormat"),
+				"live-iso images are not supported for Metal3Machines of a KubeadmControlPlane",
+			),
+		)
+	}
+
+	// An adopted host is already provisioned, its metaData and networkData
+	// are not rendered from a Metal3DataTemplate.
+	if _, adopt := c.Annotations[AdoptHostAnnotation]; adopt && c.Spec.DataTemplate != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("Spec", "DataTemplate"),
+				fmt.Sprintf("dataTemplate can not be set on a Metal3Machine with the %s annotation", AdoptHostAnnotation),
+			),
+		)
+	}
+
+	// Powering off a control plane machine may break the quorum of etcd, it
+	// must be forced.
+	if _, forced := c.Annotations[ForcePowerOffAnnotation]; c.Spec.PowerState == PowerStateOff && c.isControlPlane() && !forced {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("Spec", "PowerState"),
+				fmt.Sprintf("Metal3Machines of a KubeadmControlPlane can only be powered off with the %s annotation", ForcePowerOffAnnotation),
+			),
+		)
+	}
+
+	if old == nil || !reflect.DeepEqual(c.Spec.MetaData, old.Spec.MetaData) || !reflect.DeepEqual(c.Spec.NetworkData, old.Spec.NetworkData) {
+		allErrs = append(allErrs, c.validateClusterPolicies(ctx, reader)...)
+	}
 
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("Metal3Machine").GroupKind(), c.Name, allErrs)
 }
+
+// validateClusterPolicies validates the Metal3Machine against the policies of
+// the Metal3Cluster of its cluster, found from its cluster name label.
+// Nothing is checked without reader, cluster name label, Cluster or
+// Metal3Cluster, the controller checks the policies again before
+// provisioning.
+func (c *Metal3Machine) validateClusterPolicies(ctx context.Context, reader client.Reader) field.ErrorList {
+	clusterName := c.Labels[clusterv1.ClusterNameLabel]
+	if reader == nil || clusterName == "" {
+		return nil
+	}
+	labelPath := field.NewPath("Metadata", "Labels").Key(clusterv1.ClusterNameLabel)
+	cluster := &clusterv1.Cluster{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: clusterName}, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return field.ErrorList{field.InternalError(labelPath, err)}
+	}
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil || ref.Kind != "Metal3Cluster" {
+		return nil
+	}
+	metal3Cluster := &Metal3Cluster{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: ref.Name}, metal3Cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return field.ErrorList{field.InternalError(labelPath, err)}
+	}
+	if !metal3Cluster.Spec.EnforceDataTemplates {
+		return nil
+	}
+	return c.Spec.ValidateDataTemplatesEnforced(field.NewPath("Spec"))
+}
+
+// ValidateDataTemplatesEnforced validates that the metaData and networkData
+// are rendered from a Metal3DataTemplate rather than referenced directly, as
+// required by the enforceDataTemplates policy of the Metal3Cluster.
+func (s *Metal3MachineSpec) ValidateDataTemplatesEnforced(base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if s.MetaData != nil {
+		allErrs = append(allErrs, field.Forbidden(base.Child("MetaData"),
+			"the Metal3Cluster enforces data templates, use a dataTemplate instead"))
+	}
+	if s.NetworkData != nil {
+		allErrs = append(allErrs, field.Forbidden(base.Child("NetworkData"),
+			"the Metal3Cluster enforces data templates, use a dataTemplate instead"))
+	}
+	return allErrs
+}
+
+// validateDeployment validates the image, or the custom deploy method used
+// instead of the image.
+func (s *Metal3MachineSpec) validateDeployment(base *field.Path) field.ErrorList {
+	if s.CustomDeploy == nil {
+		return s.Image.Validate(*base.Child("Image"))
+	}
+	var allErrs field.ErrorList
+	if s.CustomDeploy.Method == "" {
+		allErrs = append(allErrs, field.Required(base.Child("CustomDeploy", "Method"), "cannot be empty"))
+	}
+	if s.Image != (Image{}) {
+		allErrs = append(allErrs, field.Forbidden(base.Child("Image"), "cannot be set along with customDeploy"))
+	}
+	return allErrs
+}
+
+// validateHostTolerations validates the syntax of the tolerated taint keys,
+// which follow the syntax of the label keys.
+func (s *Metal3MachineSpec) validateHostTolerations(base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, key := range s.HostTolerations {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(base.Child("HostTolerations").Index(i), key, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateHostSelector validates the matchExpressions of the hostSelector and
+// of its preferences, as they are converted to label selector requirements
+// when choosing a host, that the matchHardware criteria are not negative and
+// that the preference weights are in range.
+func (s *Metal3MachineSpec) validateHostSelector(base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	selectorPath := base.Child("HostSelector")
+	allErrs = append(allErrs, validateHostSelectorRequirements(s.HostSelector.MatchExpressions, selectorPath.Child("MatchExpressions"))...)
+	allErrs = append(allErrs, validateHardwareSelector(s.HostSelector.MatchHardware, selectorPath.Child("MatchHardware"))...)
+	for i, pref := range s.HostSelector.Preferences {
+		prefPath := selectorPath.Child("Preferences").Index(i)
+		if pref.Weight < 1 || pref.Weight > 100 {
+			allErrs = append(allErrs, field.Invalid(prefPath.Child("Weight"), pref.Weight, "must be between 1 and 100"))
+		}
+		allErrs = append(allErrs, validateHostSelectorRequirements(pref.MatchExpressions, prefPath.Child("MatchExpressions"))...)
+		allErrs = append(allErrs, validateHardwareSelector(pref.MatchHardware, prefPath.Child("MatchHardware"))...)
+	}
+	return allErrs
+}
+
+func validateHostSelectorRequirements(reqs []HostSelectorRequirement, base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, req := range reqs {
+		operator := selection.Operator(strings.ToLower(string(req.Operator)))
+		if _, err := labels.NewRequirement(req.Key, operator, req.Values); err != nil {
+			allErrs = append(allErrs, field.Invalid(base.Index(i), req, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateHardwareSelector(hw *HardwareSelector, base *field.Path) field.ErrorList {
+	if hw == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	for _, criterion := range []struct {
+		name  string
+		value int
+	}{
+		{"MinCPU", hw.MinCPU},
+		{"MinRAMGiB", hw.MinRAMGiB},
+		{"MinDiskGiB", hw.MinDiskGiB},
+		{"NICCount", hw.NICCount},
+	} {
+		if criterion.value < 0 {
+			allErrs = append(allErrs, field.Invalid(base.Child(criterion.name), criterion.value, "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateNodeMetadata validates the syntax of the labels and annotations set
+// on the Node.
+func (s *Metal3MachineSpec) validateNodeMetadata(base *field.Path) field.ErrorList {
+	if s.NodeMetadata == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, metav1validation.ValidateLabels(s.NodeMetadata.Labels, base.Child("NodeMetadata", "Labels"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(s.NodeMetadata.Annotations, base.Child("NodeMetadata", "Annotations"))...)
+	return allErrs
+}
+
+// validateFirmwareSettings validates that the firmwareSettings have names.
+func (s *Metal3MachineSpec) validateFirmwareSettings(base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for name := range s.FirmwareSettings {
+		if strings.TrimSpace(name) == "" {
+			allErrs = append(allErrs, field.Invalid(base.Child("FirmwareSettings"), name, "the setting name can not be empty"))
+		}
+	}
+	return allErrs
+}
+
+// validateDataTemplate validates that the dataTemplate is in the namespace of
+// the object, the only one where it is looked up.
+func (s *Metal3MachineSpec) validateDataTemplate(base *field.Path, namespace string) field.ErrorList {
+	if s.DataTemplate == nil || s.DataTemplate.Namespace == "" || s.DataTemplate.Namespace == namespace {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(base.Child("DataTemplate", "Namespace"), s.DataTemplate.Namespace,
+		"must be the namespace of the object",
+	)}
+}
+
+// isControlPlane returns whether the Metal3Machine is created by a
+// KubeadmControlPlane, which labels its machines and owns them until they are
+// adopted by the Machine.
+func (c *Metal3Machine) isControlPlane() bool {
+	if _, ok := c.Labels[clusterv1.MachineControlPlaneLabel]; ok {
+		return true
+	}
+	for _, ref := range c.OwnerReferences {
+		if ref.Kind == "KubeadmControlPlane" {
+			return true
+		}
+	}
+	return false
+}
+
+// providerIDFormat returns the format of the providerID of a Metal3Machine, or
+// an empty string if it is not set.
+func providerIDFormat(providerID *string) string {
+	if providerID == nil || *providerID == "" {
+		return ""
+	}
+	if strings.Contains(strings.TrimPrefix(*providerID, "metal3://"), "/") {
+		return "metal3://<namespace>/<bmh>/<m3m>"
+	}
+	return "metal3://<bmh-uuid>"
+}
@@ -14,22 +14,51 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func TestMetal3MachineDefault(_ *testing.T) {
-	// No-op because we do not default anything in M3M yet
+func TestMetal3MachineDefault(t *testing.T) {
+	g := NewWithT(t)
+
 	c := &Metal3Machine{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "fooboo",
 		},
-		Spec: Metal3MachineSpec{},
+		Spec: Metal3MachineSpec{
+			Image: Image{
+				URL:          "http://abc.com/image",
+				Checksum:     "http://abc.com/image.sha256sum",
+				ChecksumType: pointer.String(""),
+			},
+			DataTemplate: &corev1.ObjectReference{Name: "abc"},
+		},
 	}
+	legacy := c.DeepCopy()
 	c.Default()
+
+	g.Expect(c.Spec.DataTemplate.Namespace).To(Equal("fooboo"))
+	g.Expect(c.Spec.Image.ChecksumType).To(BeNil())
+	// The effective spec of an object stored before is the defaulted one,
+	// without modifying the object.
+	g.Expect(legacy.Spec.WithDefaults(legacy.Namespace)).To(Equal(&c.Spec))
+	g.Expect(legacy.Spec.DataTemplate.Namespace).To(BeEmpty())
+
+	// Defaulting is idempotent.
+	defaulted := c.DeepCopy()
+	defaulted.Default()
+	g.Expect(defaulted.Spec).To(Equal(c.Spec))
 }
 
 func TestMetal3MachineValidation(t *testing.T) {
@@ -54,6 +83,133 @@ func TestMetal3MachineValidation(t *testing.T) {
 	validIso.Spec.Image.Checksum = ""
 	validIso.Spec.Image.DiskFormat = pointer.String(LiveISODiskFormat)
 
+	invalidIsoControlPlane := validIso.DeepCopy()
+	invalidIsoControlPlane.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+
+	invalidIsoKubeadmControlPlane := validIso.DeepCopy()
+	invalidIsoKubeadmControlPlane.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "controlplane.cluster.x-k8s.io/v1beta1",
+		Kind:       "KubeadmControlPlane",
+		Name:       "kcp",
+	}}
+
+	validControlPlane := valid.DeepCopy()
+	validControlPlane.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+
+	validPoweredOff := valid.DeepCopy()
+	validPoweredOff.Spec.PowerState = PowerStateOff
+
+	invalidPoweredOffControlPlane := validControlPlane.DeepCopy()
+	invalidPoweredOffControlPlane.Spec.PowerState = PowerStateOff
+
+	validForcedPoweredOffControlPlane := invalidPoweredOffControlPlane.DeepCopy()
+	validForcedPoweredOffControlPlane.Annotations = map[string]string{ForcePowerOffAnnotation: ""}
+
+	validPaused := valid.DeepCopy()
+	validPaused.Annotations = map[string]string{PausedAnnotation: ""}
+	validPaused.Spec.Image.URL = "http://abc.com/other-image"
+
+	validCustomDeploy := valid.DeepCopy()
+	validCustomDeploy.Spec.Image = Image{}
+	validCustomDeploy.Spec.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidCustomDeployWithImage := valid.DeepCopy()
+	invalidCustomDeployWithImage.Spec.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidCustomDeployMethod := validCustomDeploy.DeepCopy()
+	invalidCustomDeployMethod.Spec.CustomDeploy.Method = ""
+
+	validHostTolerations := valid.DeepCopy()
+	validHostTolerations.Spec.HostTolerations = []string{"gpu", "example.com/experimental-firmware"}
+
+	invalidHostTolerations := valid.DeepCopy()
+	invalidHostTolerations.Spec.HostTolerations = []string{"gpu", "bad key!"}
+
+	validDataTemplate := valid.DeepCopy()
+	validDataTemplate.Spec.DataTemplate = &corev1.ObjectReference{Name: "data-template", Namespace: "foo"}
+
+	invalidDataTemplateNamespace := valid.DeepCopy()
+	invalidDataTemplateNamespace.Spec.DataTemplate = &corev1.ObjectReference{Name: "data-template", Namespace: "bar"}
+
+	validHostSelector := valid.DeepCopy()
+	validHostSelector.Spec.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "role", Operator: "In", Values: []string{"worker", "storage"}},
+		{Key: "example.com/gpu", Operator: "exists"},
+	}
+
+	invalidHostSelectorOperator := valid.DeepCopy()
+	invalidHostSelectorOperator.Spec.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "role", Operator: "pancakes", Values: []string{"worker"}},
+	}
+
+	invalidHostSelectorKey := valid.DeepCopy()
+	invalidHostSelectorKey.Spec.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "bad key!", Operator: "in", Values: []string{"worker"}},
+	}
+
+	invalidHostSelectorValues := valid.DeepCopy()
+	invalidHostSelectorValues.Spec.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "role", Operator: "in"},
+	}
+
+	validMatchHardware := valid.DeepCopy()
+	validMatchHardware.Spec.HostSelector.MatchHardware = &HardwareSelector{
+		MinCPU: 32, CPUArch: "x86_64", MinRAMGiB: 128, MinDiskGiB: 500, NICCount: 2,
+	}
+
+	invalidMatchHardwareCPU := valid.DeepCopy()
+	invalidMatchHardwareCPU.Spec.HostSelector.MatchHardware = &HardwareSelector{MinCPU: -1}
+
+	invalidMatchHardwareDisk := valid.DeepCopy()
+	invalidMatchHardwareDisk.Spec.HostSelector.MatchHardware = &HardwareSelector{MinRAMGiB: 64, MinDiskGiB: -100}
+
+	validPreferences := valid.DeepCopy()
+	validPreferences.Spec.HostSelector.Preferences = []HostSelectorPreference{
+		{Weight: 50, MatchExpressions: []HostSelectorRequirement{{Key: "generation", Operator: "in", Values: []string{"gen11"}}}},
+		{Weight: 10, MatchHardware: &HardwareSelector{MinRAMGiB: 512}},
+	}
+
+	invalidPreferenceWeight := valid.DeepCopy()
+	invalidPreferenceWeight.Spec.HostSelector.Preferences = []HostSelectorPreference{
+		{Weight: 0, MatchHardware: &HardwareSelector{MinRAMGiB: 512}},
+	}
+
+	invalidPreferenceExpression := valid.DeepCopy()
+	invalidPreferenceExpression.Spec.HostSelector.Preferences = []HostSelectorPreference{
+		{Weight: 50, MatchExpressions: []HostSelectorRequirement{{Key: "generation", Operator: "pancakes"}}},
+	}
+
+	invalidPreferenceHardware := valid.DeepCopy()
+	invalidPreferenceHardware.Spec.HostSelector.Preferences = []HostSelectorPreference{
+		{Weight: 50, MatchHardware: &HardwareSelector{NICCount: -2}},
+	}
+
+	validNodeMetadata := valid.DeepCopy()
+	validNodeMetadata.Spec.NodeMetadata = &NodeMetadata{
+		Labels:      map[string]string{"example.com/rack": "r1"},
+		Annotations: map[string]string{"example.com/bmc-vendor": "Dell Inc."},
+	}
+
+	invalidNodeMetadataLabel := valid.DeepCopy()
+	invalidNodeMetadataLabel.Spec.NodeMetadata = &NodeMetadata{
+		Labels: map[string]string{"example.com/bmc-vendor": "Dell Inc."},
+	}
+
+	validFirmwareSettings := valid.DeepCopy()
+	validFirmwareSettings.Spec.FirmwareSettings = map[string]string{"SriovEnable": "Enabled"}
+
+	invalidFirmwareSettingsName := valid.DeepCopy()
+	invalidFirmwareSettingsName.Spec.FirmwareSettings = map[string]string{"": "Enabled"}
+
+	validAdoptHost := valid.DeepCopy()
+	validAdoptHost.Annotations = map[string]string{AdoptHostAnnotation: "host-0"}
+
+	invalidAdoptHostDataTemplate := validDataTemplate.DeepCopy()
+	invalidAdoptHostDataTemplate.Annotations = map[string]string{AdoptHostAnnotation: "host-0"}
+
+	invalidImageScheme := valid.DeepCopy()
+	invalidImageScheme.Spec.Image.URL = "ftp://abc.com/image"
+
 	tests := []struct {
 		name      string
 		expectErr bool
@@ -79,6 +235,166 @@ func TestMetal3MachineValidation(t *testing.T) {
 			expectErr: false,
 			c:         validIso,
 		},
+		{
+			name:      "should return error when disk format is 'live-iso' for a control plane machine",
+			expectErr: true,
+			c:         invalidIsoControlPlane,
+		},
+		{
+			name:      "should return error when disk format is 'live-iso' for a machine owned by a KubeadmControlPlane",
+			expectErr: true,
+			c:         invalidIsoKubeadmControlPlane,
+		},
+		{
+			name:      "should succeed for a control plane machine with a disk image",
+			expectErr: false,
+			c:         validControlPlane,
+		},
+		{
+			name:      "should succeed when powered off",
+			expectErr: false,
+			c:         validPoweredOff,
+		},
+		{
+			name:      "should return error when a control plane machine is powered off",
+			expectErr: true,
+			c:         invalidPoweredOffControlPlane,
+		},
+		{
+			name:      "should succeed when a control plane machine is forced to be powered off",
+			expectErr: false,
+			c:         validForcedPoweredOffControlPlane,
+		},
+		{
+			name:      "should succeed when paused and image changed",
+			expectErr: false,
+			c:         validPaused,
+		},
+		{
+			name:      "should succeed with a custom deploy method and no image",
+			expectErr: false,
+			c:         validCustomDeploy,
+		},
+		{
+			name:      "should return error with both a custom deploy method and an image",
+			expectErr: true,
+			c:         invalidCustomDeployWithImage,
+		},
+		{
+			name:      "should return error when the custom deploy method is empty",
+			expectErr: true,
+			c:         invalidCustomDeployMethod,
+		},
+		{
+			name:      "should succeed with valid host tolerations",
+			expectErr: false,
+			c:         validHostTolerations,
+		},
+		{
+			name:      "should return error with an invalid host toleration",
+			expectErr: true,
+			c:         invalidHostTolerations,
+		},
+		{
+			name:      "should succeed with a dataTemplate in the same namespace",
+			expectErr: false,
+			c:         validDataTemplate,
+		},
+		{
+			name:      "should return error with a dataTemplate in another namespace",
+			expectErr: true,
+			c:         invalidDataTemplateNamespace,
+		},
+		{
+			name:      "should succeed with valid hostSelector matchExpressions",
+			expectErr: false,
+			c:         validHostSelector,
+		},
+		{
+			name:      "should return error with an invalid hostSelector operator",
+			expectErr: true,
+			c:         invalidHostSelectorOperator,
+		},
+		{
+			name:      "should return error with an invalid hostSelector key",
+			expectErr: true,
+			c:         invalidHostSelectorKey,
+		},
+		{
+			name:      "should return error with missing hostSelector values",
+			expectErr: true,
+			c:         invalidHostSelectorValues,
+		},
+		{
+			name:      "should succeed with valid hostSelector matchHardware",
+			expectErr: false,
+			c:         validMatchHardware,
+		},
+		{
+			name:      "should return error with a negative hostSelector matchHardware minCPU",
+			expectErr: true,
+			c:         invalidMatchHardwareCPU,
+		},
+		{
+			name:      "should return error with a negative hostSelector matchHardware minDiskGiB",
+			expectErr: true,
+			c:         invalidMatchHardwareDisk,
+		},
+		{
+			name:      "should succeed with valid hostSelector preferences",
+			expectErr: false,
+			c:         validPreferences,
+		},
+		{
+			name:      "should return error with a hostSelector preference weight out of range",
+			expectErr: true,
+			c:         invalidPreferenceWeight,
+		},
+		{
+			name:      "should return error with an invalid hostSelector preference operator",
+			expectErr: true,
+			c:         invalidPreferenceExpression,
+		},
+		{
+			name:      "should return error with a negative hostSelector preference matchHardware nicCount",
+			expectErr: true,
+			c:         invalidPreferenceHardware,
+		},
+		{
+			name:      "should return error with an unsupported image URL scheme",
+			expectErr: true,
+			c:         invalidImageScheme,
+		},
+		{
+			name:      "should succeed with valid node metadata",
+			expectErr: false,
+			c:         validNodeMetadata,
+		},
+		{
+			name:      "should return error with an invalid node label value",
+			expectErr: true,
+			c:         invalidNodeMetadataLabel,
+		},
+		{
+			name:      "should succeed with firmwareSettings",
+			expectErr: false,
+			c:         validFirmwareSettings,
+		},
+		{
+			name:      "should return error with an empty firmware setting name",
+			expectErr: true,
+			c:         invalidFirmwareSettingsName,
+		},
+		{
+			name:      "should succeed when adopting a host",
+			expectErr: false,
+			c:         validAdoptHost,
+		},
+		{
+			name:      "should return error when adopting a host with a dataTemplate",
+			expectErr: true,
+			c:         invalidAdoptHostDataTemplate,
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,3 +415,292 @@ func TestMetal3MachineValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMetal3MachineUpdateWarnings(t *testing.T) {
+	g := NewWithT(t)
+
+	old := &Metal3Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3MachineSpec{
+			Image: Image{
+				URL:      "http://abc.com/image",
+				Checksum: "http://abc.com/image.sha256sum",
+			},
+		},
+	}
+	old.Spec.ProviderID = pointer.String("metal3://d668eb95-5df6-4c10-a01a-fc69f4299fc6")
+	migrated := old.DeepCopy()
+	migrated.Spec.ProviderID = pointer.String("metal3://foo/host-0/machine-0")
+
+	warnings, err := migrated.ValidateUpdate(old)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(warnings).To(HaveLen(1))
+	g.Expect(warnings[0]).To(ContainSubstring("the Machine must be replaced"))
+
+	warnings, err = migrated.ValidateUpdate(migrated.DeepCopy())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(warnings).To(BeEmpty())
+
+	// Setting the providerID is not a format change.
+	unset := old.DeepCopy()
+	unset.Spec.ProviderID = nil
+	warnings, err = migrated.ValidateUpdate(unset)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(warnings).To(BeEmpty())
+}
+
+func TestMetal3MachineUpdateAdoptHost(t *testing.T) {
+	g := NewWithT(t)
+
+	old := &Metal3Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "foo",
+			Annotations: map[string]string{AdoptHostAnnotation: "host-0"},
+		},
+		Spec: Metal3MachineSpec{
+			Image: Image{
+				URL:      "http://abc.com/image",
+				Checksum: "http://abc.com/image.sha256sum",
+			},
+		},
+	}
+
+	deprovisioned := old.DeepCopy()
+	deprovisioned.Annotations[DeprovisionAdoptedHostAnnotation] = ""
+	_, err := deprovisioned.ValidateUpdate(old)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	changed := old.DeepCopy()
+	changed.Annotations[AdoptHostAnnotation] = "host-1"
+	_, err = changed.ValidateUpdate(old)
+	g.Expect(err).To(HaveOccurred())
+
+	removed := old.DeepCopy()
+	removed.Annotations = nil
+	_, err = removed.ValidateUpdate(old)
+	g.Expect(err).To(HaveOccurred())
+
+	deleted := changed.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	_, err = deleted.ValidateUpdate(old)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMetal3MachineUpdateStoredInvalid(t *testing.T) {
+	// An object stored before the validation of the image URL scheme.
+	stored := &Metal3Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "foo",
+			Finalizers: []string{MachineFinalizer},
+		},
+		Spec: Metal3MachineSpec{
+			Image: Image{
+				URL:      "ftp://abc.com/image",
+				Checksum: "http://abc.com/image.sha256sum",
+			},
+			DataTemplate: &corev1.ObjectReference{Name: "abc"},
+		},
+	}
+
+	// The webhook defaults the updated object before validating it.
+	newAnnotation := stored.DeepCopy()
+	newAnnotation.Annotations = map[string]string{"foo": "bar"}
+	newAnnotation.Default()
+
+	deleted := stored.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleted.Finalizers = nil
+
+	newSpec := stored.DeepCopy()
+	newSpec.Spec.Image.Checksum = "http://abc.com/image-2.sha256sum"
+
+	fixed := stored.DeepCopy()
+	fixed.Spec.Image.URL = "http://abc.com/image"
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Metal3Machine
+	}{
+		{
+			name:      "should succeed when the spec is not modified",
+			expectErr: false,
+			c:         newAnnotation,
+		},
+		{
+			name:      "should succeed when the finalizer is removed on deletion",
+			expectErr: false,
+			c:         deleted,
+		},
+		{
+			name:      "should fail when the spec is modified and still invalid",
+			expectErr: true,
+			c:         newSpec,
+		},
+		{
+			name:      "should succeed when the spec is fixed",
+			expectErr: false,
+			c:         fixed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := tt.c.ValidateUpdate(stored)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+// objectReader is a client.Reader getting the given objects by name.
+type objectReader []client.Object
+
+func (r objectReader) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	for _, o := range r {
+		if reflect.TypeOf(o) == reflect.TypeOf(obj) && client.ObjectKeyFromObject(o) == key {
+			reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(o.DeepCopyObject()).Elem())
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func (r objectReader) List(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+	return nil
+}
+
+func TestMetal3MachineEnforceDataTemplates(t *testing.T) {
+	newCluster := func(name string, enforce bool) []client.Object {
+		return []client.Object{
+			&clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo"},
+				Spec: clusterv1.ClusterSpec{
+					InfrastructureRef: &corev1.ObjectReference{Kind: "Metal3Cluster", Name: name + "-m3c"},
+				},
+			},
+			&Metal3Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: name + "-m3c", Namespace: "foo"},
+				Spec:       Metal3ClusterSpec{EnforceDataTemplates: enforce},
+			},
+		}
+	}
+	validator := &metal3MachineValidator{
+		reader: objectReader(append(newCluster("enforced", true), newCluster("not-enforced", false)...)),
+	}
+
+	newMachine := func(clusterName string) *Metal3Machine {
+		m3m := &Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "m3m", Namespace: "foo"},
+			Spec: Metal3MachineSpec{
+				Image: Image{
+					URL:      "http://abc.com/image",
+					Checksum: "http://abc.com/image.sha256sum",
+				},
+			},
+		}
+		if clusterName != "" {
+			m3m.Labels = map[string]string{clusterv1.ClusterNameLabel: clusterName}
+		}
+		return m3m
+	}
+
+	withTemplate := newMachine("enforced")
+	withTemplate.Spec.DataTemplate = &corev1.ObjectReference{Name: "template", Namespace: "foo"}
+
+	withMetaData := newMachine("enforced")
+	withMetaData.Spec.MetaData = &corev1.SecretReference{Name: "metadata"}
+
+	withNetworkData := newMachine("enforced")
+	withNetworkData.Spec.NetworkData = &corev1.SecretReference{Name: "networkdata"}
+
+	notEnforced := newMachine("not-enforced")
+	notEnforced.Spec.MetaData = &corev1.SecretReference{Name: "metadata"}
+
+	withoutCluster := newMachine("")
+	withoutCluster.Spec.MetaData = &corev1.SecretReference{Name: "metadata"}
+
+	unknownCluster := newMachine("unknown")
+	unknownCluster.Spec.MetaData = &corev1.SecretReference{Name: "metadata"}
+
+	// A Metal3Machine created before the policy can still be updated, unless
+	// its secrets change.
+	existingUpdated := withMetaData.DeepCopy()
+	existingUpdated.Spec.Image.Checksum = "http://abc.com/image-2.sha256sum"
+	existingNewSecret := withMetaData.DeepCopy()
+	existingNewSecret.Spec.NetworkData = &corev1.SecretReference{Name: "networkdata"}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Metal3Machine
+		old       *Metal3Machine
+	}{
+		{
+			name:      "should succeed with a dataTemplate when enforced",
+			expectErr: false,
+			c:         withTemplate,
+		},
+		{
+			name:      "should fail with a metaData secret when enforced",
+			expectErr: true,
+			c:         withMetaData,
+		},
+		{
+			name:      "should fail with a networkData secret when enforced",
+			expectErr: true,
+			c:         withNetworkData,
+		},
+		{
+			name:      "should succeed with a metaData secret when not enforced",
+			expectErr: false,
+			c:         notEnforced,
+		},
+		{
+			name:      "should succeed without cluster name label",
+			expectErr: false,
+			c:         withoutCluster,
+		},
+		{
+			name:      "should succeed when the cluster does not exist",
+			expectErr: false,
+			c:         unknownCluster,
+		},
+		{
+			name:      "should succeed when updating an existing machine without changing its secrets",
+			expectErr: false,
+			c:         existingUpdated,
+			old:       withMetaData,
+		},
+		{
+			name:      "should fail when updating the secrets of an existing machine",
+			expectErr: true,
+			c:         existingNewSecret,
+			old:       withMetaData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			var err error
+			if tt.old == nil {
+				_, err = validator.ValidateCreate(context.TODO(), tt.c)
+			} else {
+				_, err = validator.ValidateUpdate(context.TODO(), tt.old, tt.c)
+			}
+			if tt.expectErr {
+				g.Expect(err).To(MatchError(ContainSubstring("enforces data templates")))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
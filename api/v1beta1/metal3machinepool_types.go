@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+const (
+	// MachinePoolFinalizer allows ReconcileMetal3MachinePool to release the
+	// BareMetalHosts of the Metal3MachinePool before removing it from the
+	// apiserver.
+	MachinePoolFinalizer = "metal3machinepool.infrastructure.cluster.x-k8s.io"
+)
+
+// Metal3MachinePoolSpec defines the desired state of Metal3MachinePool.
+type Metal3MachinePoolSpec struct {
+	// ProviderIDList are the providerIDs of the Nodes of the BareMetalHosts
+	// of the pool, in the metal3://<namespace>/<bmh>/<metal3machinepool>
+	// format. It is set by the controller and copied by Cluster API to the
+	// MachinePool.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+
+	// Template is the specification of the BareMetalHosts of the pool, one
+	// per replica of the MachinePool.
+	Template Metal3MachinePoolHostTemplate `json:"template"`
+}
+
+// Metal3MachinePoolHostTemplate describes how the BareMetalHosts of a
+// Metal3MachinePool are chosen and provisioned.
+type Metal3MachinePoolHostTemplate struct {
+	// Image is the image to be provisioned. It must be set unless
+	// customDeploy is set.
+	// +optional
+	Image Image `json:"image,omitempty"`
+
+	// CustomDeploy is the custom deploy method set on the BareMetalHosts
+	// instead of the image. It can not be set along with the image.
+	// +optional
+	CustomDeploy *CustomDeploy `json:"customDeploy,omitempty"`
+
+	// HostSelector specifies matching criteria for labels on BareMetalHosts.
+	// This is used to limit the set of BareMetalHost objects considered for
+	// the replicas of the pool.
+	// +optional
+	HostSelector HostSelector `json:"hostSelector,omitempty"`
+
+	// When set to disabled, automated cleaning of host disks will be skipped
+	// during provisioning and deprovisioning.
+	// +kubebuilder:validation:Enum:=metadata;disabled
+	// +optional
+	AutomatedCleaningMode *string `json:"automatedCleaningMode,omitempty"`
+}
+
+// Metal3MachinePoolStatus defines the observed state of Metal3MachinePool.
+type Metal3MachinePoolStatus struct {
+	// Ready is true when as many BareMetalHosts as the replicas of the
+	// MachinePool are associated with the pool.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Replicas is the number of BareMetalHosts of the pool whose Node has a
+	// providerID, the length of the providerIDList.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// Hosts are the names of the BareMetalHosts associated with the pool, in
+	// the namespace of the Metal3MachinePool.
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+
+	// LastUpdated identifies when this status was last observed.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the Metal3MachinePool and will contain a succinct value
+	// suitable for machine pool interpretation.
+	// +optional
+	FailureReason *capierrors.MachinePoolStatusFailure `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the Metal3MachinePool and will contain a more verbose string
+	// suitable for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the Metal3MachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=metal3machinepools,scope=Namespaced,categories=cluster-api,shortName=m3mp;m3machinepool;m3machinepools;metal3mp;metal3machinepool
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Metal3MachinePool"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Number of BareMetalHosts with a Node"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="metal3machinepool is Ready"
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this Metal3MachinePool belongs"
+
+// Metal3MachinePool is the Schema for the metal3machinepools API. It is
+// experimental and reconciled only with the --enable-machine-pool flag.
+type Metal3MachinePool struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec Metal3MachinePoolSpec `json:"spec,omitempty"`
+	// +optional
+	Status Metal3MachinePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// Metal3MachinePoolList contains a list of Metal3MachinePool.
+type Metal3MachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Metal3MachinePool `json:"items"`
+}
+
+// GetConditions returns the list of conditions for a Metal3MachinePool API object.
+func (c *Metal3MachinePool) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3MachinePool object.
+func (c *Metal3MachinePool) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&Metal3MachinePool{}, &Metal3MachinePoolList{})
+}
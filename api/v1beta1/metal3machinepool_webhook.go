@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (c *Metal3MachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-metal3machinepool,mutating=false,failurePolicy=fail,groups=infrastructure.cluster.x-k8s.io,resources=metal3machinepools,versions=v1beta1,name=validation.metal3machinepool.infrastructure.cluster.x-k8s.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-metal3machinepool,mutating=true,failurePolicy=fail,groups=infrastructure.cluster.x-k8s.io,resources=metal3machinepools,versions=v1beta1,name=default.metal3machinepool.infrastructure.cluster.x-k8s.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.Defaulter = &Metal3MachinePool{}
+var _ webhook.Validator = &Metal3MachinePool{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+func (c *Metal3MachinePool) Default() {
+	if c.Spec.Template.Image.ChecksumType != nil && *c.Spec.Template.Image.ChecksumType == "" {
+		c.Spec.Template.Image.ChecksumType = nil
+	}
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (c *Metal3MachinePool) ValidateCreate() (admission.Warnings, error) {
+	return nil, c.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (c *Metal3MachinePool) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
+	// The finalizer of a deleted pool must be removable whatever its spec.
+	if !c.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, c.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (c *Metal3MachinePool) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate validates the host template of the Metal3MachinePool with the
+// rules of the Metal3Machines.
+func (c *Metal3MachinePool) validate() error {
+	var allErrs field.ErrorList
+	base := field.NewPath("Spec", "Template")
+	spec := &Metal3MachineSpec{
+		Image:        c.Spec.Template.Image,
+		CustomDeploy: c.Spec.Template.CustomDeploy,
+		HostSelector: c.Spec.Template.HostSelector,
+	}
+	allErrs = append(allErrs, spec.validateDeployment(base)...)
+	allErrs = append(allErrs, spec.validateHostSelector(base)...)
+
+	// The BareMetalHosts of the pool are bootstrapped with the bootstrap data
+	// of the MachinePool, which a live-iso ignores.
+	if c.Spec.Template.Image.DiskFormat != nil && *c.Spec.Template.Image.DiskFormat == LiveISODiskFormat {
+		allErrs = append(allErrs,
+			field.Forbidden(base.Child("Image", "DiskFormat"), "live-iso images are not supported for Metal3MachinePools"),
+		)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Metal3MachinePool").GroupKind(), c.Name, allErrs)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestMetal3MachinePoolDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	c := &Metal3MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3MachinePoolSpec{
+			Template: Metal3MachinePoolHostTemplate{
+				Image: Image{
+					URL:          "http://abc.com/image",
+					Checksum:     "http://abc.com/image.sha256sum",
+					ChecksumType: pointer.String(""),
+				},
+			},
+		},
+	}
+	c.Default()
+
+	g.Expect(c.Spec.Template.Image.ChecksumType).To(BeNil())
+}
+
+func TestMetal3MachinePoolValidation(t *testing.T) {
+	valid := &Metal3MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3MachinePoolSpec{
+			Template: Metal3MachinePoolHostTemplate{
+				Image: Image{
+					URL:      "http://abc.com/image",
+					Checksum: "http://abc.com/image.sha256sum",
+				},
+				HostSelector: HostSelector{
+					MatchLabels: map[string]string{"pool": "workers"},
+				},
+			},
+		},
+	}
+
+	validCustomDeploy := valid.DeepCopy()
+	validCustomDeploy.Spec.Template.Image = Image{}
+	validCustomDeploy.Spec.Template.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidImage := valid.DeepCopy()
+	invalidImage.Spec.Template.Image.URL = ""
+
+	invalidCustomDeploy := valid.DeepCopy()
+	invalidCustomDeploy.Spec.Template.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidLiveISO := valid.DeepCopy()
+	invalidLiveISO.Spec.Template.Image.DiskFormat = pointer.String(LiveISODiskFormat)
+	invalidLiveISO.Spec.Template.Image.Checksum = ""
+
+	invalidHostSelector := valid.DeepCopy()
+	invalidHostSelector.Spec.Template.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "role", Operator: "pancakes", Values: []string{"worker"}},
+	}
+
+	invalidMatchHardware := valid.DeepCopy()
+	invalidMatchHardware.Spec.Template.HostSelector.MatchHardware = &HardwareSelector{MinCPU: -1}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Metal3MachinePool
+	}{
+		{
+			name:      "should succeed when image is valid",
+			expectErr: false,
+			c:         valid,
+		},
+		{
+			name:      "should succeed with a customDeploy",
+			expectErr: false,
+			c:         validCustomDeploy,
+		},
+		{
+			name:      "should return error when the image URL is missing",
+			expectErr: true,
+			c:         invalidImage,
+		},
+		{
+			name:      "should return error with both an image and a customDeploy",
+			expectErr: true,
+			c:         invalidCustomDeploy,
+		},
+		{
+			name:      "should return error with a live-iso image",
+			expectErr: true,
+			c:         invalidLiveISO,
+		},
+		{
+			name:      "should return error with an invalid hostSelector operator",
+			expectErr: true,
+			c:         invalidHostSelector,
+		},
+		{
+			name:      "should return error with a negative hostSelector matchHardware minCPU",
+			expectErr: true,
+			c:         invalidMatchHardware,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			if tt.expectErr {
+				_, err := tt.c.ValidateCreate()
+				g.Expect(err).To(HaveOccurred())
+				_, err = tt.c.ValidateUpdate(nil)
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				_, err := tt.c.ValidateCreate()
+				g.Expect(err).NotTo(HaveOccurred())
+				_, err = tt.c.ValidateUpdate(nil)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	// A deleted pool can be updated to remove its finalizer.
+	g := NewWithT(t)
+	deleted := invalidImage.DeepCopy()
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	_, err := deleted.ValidateUpdate(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+}
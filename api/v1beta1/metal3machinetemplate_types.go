@@ -18,6 +18,53 @@ package v1beta1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// AllowTemplateUpdateAnnotation allows the update of the image, the
+	// customDeploy, the hostSelector and the dataTemplate of a
+	// Metal3MachineTemplate. The existing Metal3Machines are not updated, only
+	// the new ones use the new values.
+	AllowTemplateUpdateAnnotation = "infrastructure.cluster.x-k8s.io/allow-template-update"
+
+	// TemplateGenerationAnnotation records on a Metal3Machine the generation
+	// of the Metal3MachineTemplate it was cloned from.
+	TemplateGenerationAnnotation = "infrastructure.cluster.x-k8s.io/template-generation"
+
+	// TemplateHashAnnotation records on a Metal3Machine the hash of the spec
+	// of the Metal3MachineTemplate it was cloned from.
+	TemplateHashAnnotation = "infrastructure.cluster.x-k8s.io/template-hash"
+)
+
+// AutomatedCleaningModeUpdatePolicy defines when the automatedCleaningMode of a
+// Metal3MachineTemplate is applied to the Metal3Machines cloned from it.
+type AutomatedCleaningModeUpdatePolicy string
+
+const (
+	// UpdateAutomatedCleaningModeAlways synchronizes the automatedCleaningMode
+	// of the template to all Metal3Machines cloned from it, including the
+	// existing ones.
+	UpdateAutomatedCleaningModeAlways AutomatedCleaningModeUpdatePolicy = "Always"
+	// UpdateAutomatedCleaningModeOnCreate only applies the automatedCleaningMode
+	// of the template to the Metal3Machines created after the change.
+	UpdateAutomatedCleaningModeOnCreate AutomatedCleaningModeUpdatePolicy = "OnCreate"
+)
+
+// NodeReuseScope defines which machines reuse the BareMetalHosts released
+// with nodeReuse.
+type NodeReuseScope string
+
+const (
+	// NodeReuseScopeOwner reuses the hosts for the machines of the same
+	// KubeadmControlPlane or MachineDeployment.
+	NodeReuseScopeOwner NodeReuseScope = "Owner"
+	// NodeReuseScopeCluster reuses the hosts for the machines of the same
+	// cluster.
+	NodeReuseScopeCluster NodeReuseScope = "Cluster"
+	// NodeReuseScopeLabels reuses the hosts for the machines whose Machines
+	// have the same values of the nodeReuseLabelKeys labels.
+	NodeReuseScopeLabels NodeReuseScope = "Labels"
 )
 
 // Metal3MachineTemplateSpec defines the desired state of Metal3MachineTemplate.
@@ -29,13 +76,96 @@ type Metal3MachineTemplateSpec struct {
 	// +kubebuilder:default=false
 	// +optional
 	NodeReuse bool `json:"nodeReuse"`
+
+	// NodeReuseScope defines which machines reuse the hosts released with
+	// nodeReuse: the machines of the same KubeadmControlPlane or
+	// MachineDeployment (Owner), of the same cluster (Cluster), or whose
+	// Machines have the same values of the nodeReuseLabelKeys labels
+	// (Labels), e.g. across renamed MachineDeployments. The nodeReuseGroup of
+	// the Metal3Machines takes precedence.
+	// +kubebuilder:validation:Enum=Owner;Cluster;Labels
+	// +kubebuilder:default=Owner
+	// +optional
+	NodeReuseScope NodeReuseScope `json:"nodeReuseScope,omitempty"`
+
+	// NodeReuseLabelKeys are the keys of the Machine labels whose values
+	// scope the node reuse with the Labels nodeReuseScope.
+	// +optional
+	NodeReuseLabelKeys []string `json:"nodeReuseLabelKeys,omitempty"`
+
+	// NodeReuseGracePeriod is the time during which a host released with
+	// nodeReuse stays reserved for the machines of its scope. Once it is
+	// elapsed, the node reuse label is removed and the host returns to the
+	// general pool, unless the owner of the label still needs hosts, e.g.
+	// during a rolling upgrade. It overrides the --node-reuse-label-ttl flag
+	// of the controller manager for the hosts released by the template, zero
+	// keeping them reserved until they are consumed.
+	// +optional
+	NodeReuseGracePeriod *metav1.Duration `json:"nodeReuseGracePeriod,omitempty"`
+
+	// UpdateAutomatedCleaningMode defines whether a change of the
+	// automatedCleaningMode of the template is propagated to the existing
+	// Metal3Machines (Always) or only used for new ones (OnCreate).
+	// +kubebuilder:validation:Enum=OnCreate;Always
+	// +kubebuilder:default=Always
+	// +optional
+	UpdateAutomatedCleaningMode AutomatedCleaningModeUpdatePolicy `json:"updateAutomatedCleaningMode,omitempty"`
+
+	// SpreadTopologyKey is the key of a BareMetalHost label, e.g.
+	// metal3.io/rack, across whose values the machines of the same
+	// MachineDeployment or KubeadmControlPlane are spread. A host is chosen
+	// among those whose value is shared by the fewest hosts of the other
+	// machines, so that machines share a value only when no other is
+	// available.
+	// +optional
+	SpreadTopologyKey string `json:"spreadTopologyKey,omitempty"`
+}
+
+// Metal3MachineTemplateStatus defines the observed state of Metal3MachineTemplate.
+type Metal3MachineTemplateStatus struct {
+	// Conditions defines current service state of the Metal3MachineTemplate.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// OutOfDateReplicas is the number of Metal3Machines cloned from the
+	// Metal3MachineTemplate whose recorded template hash differs from the
+	// current one.
+	// +optional
+	OutOfDateReplicas int32 `json:"outOfDateReplicas,omitempty"`
+
+	// NodeReuseReservations are the available BareMetalHosts released by the
+	// Metal3Machines cloned from the Metal3MachineTemplate that are still
+	// reserved for node reuse.
+	// +optional
+	NodeReuseReservations []NodeReuseReservation `json:"nodeReuseReservations,omitempty"`
+}
+
+// NodeReuseReservation is a BareMetalHost reserved for node reuse.
+type NodeReuseReservation struct {
+	// Host is the name of the BareMetalHost.
+	Host string `json:"host"`
+
+	// Value is the value of the node reuse label of the host.
+	Value string `json:"value"`
+
+	// Since is the time since which the host is reserved.
+	// +optional
+	Since *metav1.Time `json:"since,omitempty"`
+
+	// ExpiresAt is the time after which the reservation may be removed, unset
+	// if it does not expire.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Out of date",type="integer",JSONPath=".status.outOfDateReplicas",description="Number of Metal3Machines cloned from an older revision of the Metal3MachineTemplate"
+// +kubebuilder:printcolumn:name="Reserved",type="string",JSONPath=".status.nodeReuseReservations[*].host",description="BareMetalHosts reserved for node reuse",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Metal3MachineTemplate"
 // +kubebuilder:resource:path=metal3machinetemplates,scope=Namespaced,categories=cluster-api,shortName=m3mt;m3machinetemplate;m3machinetemplates;metal3mt;metal3machinetemplate
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 
 // Metal3MachineTemplate is the Schema for the metal3machinetemplates API.
 type Metal3MachineTemplate struct {
@@ -45,6 +175,19 @@ type Metal3MachineTemplate struct {
 
 	// +optional
 	Spec Metal3MachineTemplateSpec `json:"spec,omitempty"`
+
+	// +optional
+	Status Metal3MachineTemplateStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the list of conditions for a Metal3MachineTemplate API object.
+func (c *Metal3MachineTemplate) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3MachineTemplate object.
+func (c *Metal3MachineTemplate) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
 }
 
 // +kubebuilder:object:root=true
@@ -14,9 +14,13 @@ limitations under the License.
 package v1beta1
 
 import (
+	"reflect"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -35,6 +39,12 @@ var _ webhook.Defaulter = &Metal3MachineTemplate{}
 var _ webhook.Validator = &Metal3MachineTemplate{}
 
 func (c *Metal3MachineTemplate) Default() {
+	if c.Spec.UpdateAutomatedCleaningMode == "" {
+		c.Spec.UpdateAutomatedCleaningMode = UpdateAutomatedCleaningModeAlways
+	}
+	if c.Spec.NodeReuseScope == "" {
+		c.Spec.NodeReuseScope = NodeReuseScopeOwner
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
@@ -43,10 +53,62 @@ func (c *Metal3MachineTemplate) ValidateCreate() (admission.Warnings, error) {
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
-func (c *Metal3MachineTemplate) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
+// The image, customDeploy, hostSelector and dataTemplate can not be modified,
+// as the change is not rolled out to the existing Metal3Machines, unless the
+// template has the AllowTemplateUpdateAnnotation or is updated in a dry run of
+// the topology controller.
+func (c *Metal3MachineTemplate) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	oldM3mt, ok := old.(*Metal3MachineTemplate)
+	if !ok || oldM3mt == nil {
+		return nil, c.validate()
+	}
+
+	if !c.allowsTemplateUpdate() {
+		// The fields defaulted in the Metal3Machines are compared with their
+		// defaults, setting them to their default value is not a modification.
+		var allErrs field.ErrorList
+		specPath := field.NewPath("spec", "template", "spec")
+		spec := c.Spec.Template.Spec.WithDefaults(c.Namespace)
+		oldSpec := oldM3mt.Spec.Template.Spec.WithDefaults(oldM3mt.Namespace)
+		if !reflect.DeepEqual(spec.Image, oldSpec.Image) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("image"), templateImmutableMsg))
+		}
+		if !reflect.DeepEqual(spec.CustomDeploy, oldSpec.CustomDeploy) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("customDeploy"), templateImmutableMsg))
+		}
+		if !reflect.DeepEqual(spec.HostSelector, oldSpec.HostSelector) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("hostSelector"), templateImmutableMsg))
+		}
+		if !reflect.DeepEqual(spec.DataTemplate, oldSpec.DataTemplate) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("dataTemplate"), templateImmutableMsg))
+		}
+		if len(allErrs) != 0 {
+			return nil, apierrors.NewInvalid(GroupVersion.WithKind("Metal3MachineTemplate").GroupKind(), c.Name, allErrs)
+		}
+	}
+
+	// Templates stored before a validation rule was added can still be
+	// updated without changing their spec, e.g. when they are deleted.
+	if !c.DeletionTimestamp.IsZero() || reflect.DeepEqual(c.Spec, oldM3mt.Spec) {
+		return nil, nil
+	}
 	return nil, c.validate()
 }
 
+const templateImmutableMsg = "cannot be modified, the change would only apply to new Metal3Machines. " +
+	"Create a new Metal3MachineTemplate and reference it to roll out the change, or set the " +
+	AllowTemplateUpdateAnnotation + " annotation"
+
+// allowsTemplateUpdate returns true if the image, customDeploy, hostSelector
+// and dataTemplate of the template can be modified.
+func (c *Metal3MachineTemplate) allowsTemplateUpdate() bool {
+	if _, ok := c.Annotations[AllowTemplateUpdateAnnotation]; ok {
+		return true
+	}
+	_, ok := c.Annotations[clusterv1.TopologyDryRunAnnotation]
+	return ok
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
 func (c *Metal3MachineTemplate) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
@@ -55,10 +117,65 @@ func (c *Metal3MachineTemplate) ValidateDelete() (admission.Warnings, error) {
 func (c *Metal3MachineTemplate) validate() error {
 	var allErrs field.ErrorList
 
-	allErrs = append(allErrs, c.Spec.Template.Spec.Image.Validate(*field.NewPath("Spec", "Template", "Spec", "Image"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateDeployment(field.NewPath("Spec", "Template", "Spec"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateHostTolerations(field.NewPath("Spec", "Template", "Spec"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateHostSelector(field.NewPath("Spec", "Template", "Spec"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateNodeMetadata(field.NewPath("Spec", "Template", "Spec"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateFirmwareSettings(field.NewPath("Spec", "Template", "Spec"))...)
+	allErrs = append(allErrs, c.Spec.Template.Spec.validateDataTemplate(field.NewPath("Spec", "Template", "Spec"), c.Namespace)...)
+
+	if c.Spec.SpreadTopologyKey != "" {
+		for _, msg := range validation.IsQualifiedName(c.Spec.SpreadTopologyKey) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("Spec", "SpreadTopologyKey"), c.Spec.SpreadTopologyKey, msg))
+		}
+	}
+
+	allErrs = append(allErrs, c.Spec.validateNodeReuseScope(field.NewPath("Spec"))...)
+
+	if c.Spec.NodeReuseGracePeriod != nil && c.Spec.NodeReuseGracePeriod.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("Spec", "NodeReuseGracePeriod"),
+			c.Spec.NodeReuseGracePeriod, "must not be negative"))
+	}
+
+	switch c.Spec.UpdateAutomatedCleaningMode {
+	case "", UpdateAutomatedCleaningModeAlways, UpdateAutomatedCleaningModeOnCreate:
+	default:
+		allErrs = append(allErrs, field.NotSupported(
+			field.NewPath("Spec", "UpdateAutomatedCleaningMode"),
+			c.Spec.UpdateAutomatedCleaningMode,
+			[]string{string(UpdateAutomatedCleaningModeAlways), string(UpdateAutomatedCleaningModeOnCreate)},
+		))
+	}
 
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("Metal3MachineTemplate").GroupKind(), c.Name, allErrs)
 }
+
+// validateNodeReuseScope validates that the nodeReuseLabelKeys are valid label
+// keys, given with the Labels nodeReuseScope only.
+func (s *Metal3MachineTemplateSpec) validateNodeReuseScope(base *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch s.NodeReuseScope {
+	case "", NodeReuseScopeOwner, NodeReuseScopeCluster:
+		if len(s.NodeReuseLabelKeys) != 0 {
+			allErrs = append(allErrs, field.Forbidden(base.Child("NodeReuseLabelKeys"),
+				"can only be set with the Labels nodeReuseScope"))
+		}
+	case NodeReuseScopeLabels:
+		if len(s.NodeReuseLabelKeys) == 0 {
+			allErrs = append(allErrs, field.Required(base.Child("NodeReuseLabelKeys"),
+				"is required with the Labels nodeReuseScope"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(base.Child("NodeReuseScope"), s.NodeReuseScope,
+			[]string{string(NodeReuseScopeOwner), string(NodeReuseScopeCluster), string(NodeReuseScopeLabels)}))
+	}
+	for i, key := range s.NodeReuseLabelKeys {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(base.Child("NodeReuseLabelKeys").Index(i), key, msg))
+		}
+	}
+	return allErrs
+}
@@ -15,14 +15,18 @@ package v1beta1
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
-func TestMetal3MachineTemplateDefault(_ *testing.T) {
-	// No-op because we do not default anything in M3M yet
+func TestMetal3MachineTemplateDefault(t *testing.T) {
+	g := NewWithT(t)
+
 	c := &Metal3MachineTemplate{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "fooboo",
@@ -30,6 +34,12 @@ func TestMetal3MachineTemplateDefault(_ *testing.T) {
 		Spec: Metal3MachineTemplateSpec{},
 	}
 	c.Default()
+	g.Expect(c.Spec.UpdateAutomatedCleaningMode).To(Equal(UpdateAutomatedCleaningModeAlways))
+	g.Expect(c.Spec.NodeReuseScope).To(Equal(NodeReuseScopeOwner))
+
+	c.Spec.UpdateAutomatedCleaningMode = UpdateAutomatedCleaningModeOnCreate
+	c.Default()
+	g.Expect(c.Spec.UpdateAutomatedCleaningMode).To(Equal(UpdateAutomatedCleaningModeOnCreate))
 }
 
 func TestMetal3MachineTemplateValidation(t *testing.T) {
@@ -58,6 +68,64 @@ func TestMetal3MachineTemplateValidation(t *testing.T) {
 	validIso.Spec.Template.Spec.Image.Checksum = ""
 	validIso.Spec.Template.Spec.Image.DiskFormat = pointer.String(LiveISODiskFormat)
 
+	validOnCreate := valid.DeepCopy()
+	validOnCreate.Spec.UpdateAutomatedCleaningMode = UpdateAutomatedCleaningModeOnCreate
+
+	invalidUpdateMode := valid.DeepCopy()
+	invalidUpdateMode.Spec.UpdateAutomatedCleaningMode = "Never"
+
+	validSpreadTopologyKey := valid.DeepCopy()
+	validSpreadTopologyKey.Spec.SpreadTopologyKey = "metal3.io/rack"
+
+	invalidSpreadTopologyKey := valid.DeepCopy()
+	invalidSpreadTopologyKey.Spec.SpreadTopologyKey = "metal3.io/rack/"
+
+	validNodeReuseLabels := valid.DeepCopy()
+	validNodeReuseLabels.Spec.NodeReuseScope = NodeReuseScopeLabels
+	validNodeReuseLabels.Spec.NodeReuseLabelKeys = []string{"example.com/pool"}
+
+	invalidNodeReuseLabelsMissing := valid.DeepCopy()
+	invalidNodeReuseLabelsMissing.Spec.NodeReuseScope = NodeReuseScopeLabels
+
+	invalidNodeReuseLabelsScope := valid.DeepCopy()
+	invalidNodeReuseLabelsScope.Spec.NodeReuseScope = NodeReuseScopeCluster
+	invalidNodeReuseLabelsScope.Spec.NodeReuseLabelKeys = []string{"example.com/pool"}
+
+	invalidNodeReuseLabelKey := validNodeReuseLabels.DeepCopy()
+	invalidNodeReuseLabelKey.Spec.NodeReuseLabelKeys = []string{"example.com/pool/"}
+
+	validNodeReuseGracePeriod := valid.DeepCopy()
+	validNodeReuseGracePeriod.Spec.NodeReuseGracePeriod = &metav1.Duration{Duration: time.Hour}
+
+	invalidNodeReuseGracePeriod := valid.DeepCopy()
+	invalidNodeReuseGracePeriod.Spec.NodeReuseGracePeriod = &metav1.Duration{Duration: -time.Hour}
+
+	validCustomDeploy := valid.DeepCopy()
+	validCustomDeploy.Spec.Template.Spec.Image = Image{}
+	validCustomDeploy.Spec.Template.Spec.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidCustomDeployWithImage := valid.DeepCopy()
+	invalidCustomDeployWithImage.Spec.Template.Spec.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	invalidHostTolerations := valid.DeepCopy()
+	invalidHostTolerations.Spec.Template.Spec.HostTolerations = []string{"bad key!"}
+
+	invalidDataTemplateNamespace := valid.DeepCopy()
+	invalidDataTemplateNamespace.Spec.Template.Spec.DataTemplate = &corev1.ObjectReference{Name: "data-template", Namespace: "bar"}
+
+	invalidHostSelector := valid.DeepCopy()
+	invalidHostSelector.Spec.Template.Spec.HostSelector.MatchExpressions = []HostSelectorRequirement{
+		{Key: "role", Operator: "pancakes", Values: []string{"worker"}},
+	}
+
+	invalidNodeMetadataAnnotation := valid.DeepCopy()
+	invalidNodeMetadataAnnotation.Spec.Template.Spec.NodeMetadata = &NodeMetadata{
+		Annotations: map[string]string{"example.com/rack/": "r1"},
+	}
+
+	invalidChecksumType := valid.DeepCopy()
+	invalidChecksumType.Spec.Template.Spec.Image.ChecksumType = pointer.String("sha255")
+
 	tests := []struct {
 		name      string
 		expectErr bool
@@ -83,6 +151,91 @@ func TestMetal3MachineTemplateValidation(t *testing.T) {
 			expectErr: false,
 			c:         validIso,
 		},
+		{
+			name:      "should succeed with a custom deploy method and no image",
+			expectErr: false,
+			c:         validCustomDeploy,
+		},
+		{
+			name:      "should return error with both a custom deploy method and an image",
+			expectErr: true,
+			c:         invalidCustomDeployWithImage,
+		},
+		{
+			name:      "should succeed with the Labels nodeReuseScope and label keys",
+			expectErr: false,
+			c:         validNodeReuseLabels,
+		},
+		{
+			name:      "should return error with the Labels nodeReuseScope without label keys",
+			expectErr: true,
+			c:         invalidNodeReuseLabelsMissing,
+		},
+		{
+			name:      "should return error with nodeReuseLabelKeys and the Cluster nodeReuseScope",
+			expectErr: true,
+			c:         invalidNodeReuseLabelsScope,
+		},
+		{
+			name:      "should return error with an invalid nodeReuseLabelKeys key",
+			expectErr: true,
+			c:         invalidNodeReuseLabelKey,
+		},
+		{
+			name:      "should succeed with a nodeReuseGracePeriod",
+			expectErr: false,
+			c:         validNodeReuseGracePeriod,
+		},
+		{
+			name:      "should return error with a negative nodeReuseGracePeriod",
+			expectErr: true,
+			c:         invalidNodeReuseGracePeriod,
+		},
+		{
+			name:      "should succeed with a spreadTopologyKey",
+			expectErr: false,
+			c:         validSpreadTopologyKey,
+		},
+		{
+			name:      "should return error with an invalid spreadTopologyKey",
+			expectErr: true,
+			c:         invalidSpreadTopologyKey,
+		},
+		{
+			name:      "should succeed when automatedCleaningMode is only applied on create",
+			expectErr: false,
+			c:         validOnCreate,
+		},
+		{
+			name:      "should return error when updateAutomatedCleaningMode is not supported",
+			expectErr: true,
+			c:         invalidUpdateMode,
+		},
+		{
+			name:      "should return error with an invalid host toleration",
+			expectErr: true,
+			c:         invalidHostTolerations,
+		},
+		{
+			name:      "should return error with a dataTemplate in another namespace",
+			expectErr: true,
+			c:         invalidDataTemplateNamespace,
+		},
+		{
+			name:      "should return error with an invalid hostSelector operator",
+			expectErr: true,
+			c:         invalidHostSelector,
+		},
+		{
+			name:      "should return error with an invalid checksum type",
+			expectErr: true,
+			c:         invalidChecksumType,
+		},
+		{
+			name:      "should return error with an invalid node annotation key",
+			expectErr: true,
+			c:         invalidNodeMetadataAnnotation,
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,3 +256,167 @@ func TestMetal3MachineTemplateValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMetal3MachineTemplateUpdateValidation(t *testing.T) {
+	old := &Metal3MachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: Metal3MachineTemplateSpec{
+			Template: Metal3MachineTemplateResource{
+				Spec: Metal3MachineSpec{
+					Image: Image{
+						URL:      "http://abc.com/image",
+						Checksum: "http://abc.com/image.sha256sum",
+					},
+					HostSelector: HostSelector{
+						MatchLabels: map[string]string{"role": "worker"},
+					},
+					DataTemplate: &corev1.ObjectReference{Name: "data-template"},
+				},
+			},
+		},
+	}
+
+	newImage := old.DeepCopy()
+	newImage.Spec.Template.Spec.Image.Checksum = "http://abc.com/image-2.sha256sum"
+
+	newHostSelector := old.DeepCopy()
+	newHostSelector.Spec.Template.Spec.HostSelector.MatchLabels["role"] = "storage"
+
+	newCustomDeploy := old.DeepCopy()
+	newCustomDeploy.Spec.Template.Spec.Image = Image{}
+	newCustomDeploy.Spec.Template.Spec.CustomDeploy = &CustomDeploy{Method: "install_coreos"}
+
+	newDataTemplate := old.DeepCopy()
+	newDataTemplate.Spec.Template.Spec.DataTemplate = nil
+
+	// The defaults of the Metal3Machines set explicitly.
+	defaultedTemplate := old.DeepCopy()
+	defaultedTemplate.Spec.Template.Spec.DataTemplate.Namespace = "foo"
+	defaultedTemplate.Spec.Template.Spec.Image.ChecksumType = pointer.String("")
+
+	otherNamespaceDataTemplate := old.DeepCopy()
+	otherNamespaceDataTemplate.Spec.Template.Spec.DataTemplate.Namespace = "bar"
+
+	allowedImage := newImage.DeepCopy()
+	allowedImage.Annotations = map[string]string{AllowTemplateUpdateAnnotation: ""}
+
+	dryRunImage := newImage.DeepCopy()
+	dryRunImage.Annotations = map[string]string{clusterv1.TopologyDryRunAnnotation: ""}
+
+	allowedInvalidImage := allowedImage.DeepCopy()
+	allowedInvalidImage.Spec.Template.Spec.Image.URL = ""
+
+	newCleaningMode := old.DeepCopy()
+	newCleaningMode.Spec.Template.Spec.AutomatedCleaningMode = pointer.String(CleaningModeDisabled)
+
+	// A template stored before the validation of the checksum type.
+	storedInvalid := old.DeepCopy()
+	storedInvalid.Spec.Template.Spec.Image.ChecksumType = pointer.String("sha255")
+	storedInvalidAnnotated := storedInvalid.DeepCopy()
+	storedInvalidAnnotated.Annotations = map[string]string{"foo": "bar"}
+
+	invalidCleaningMode := storedInvalid.DeepCopy()
+	invalidCleaningMode.Spec.Template.Spec.AutomatedCleaningMode = pointer.String(CleaningModeDisabled)
+
+	deletedNewImage := newImage.DeepCopy()
+	deletedNewImage.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		old       *Metal3MachineTemplate
+		c         *Metal3MachineTemplate
+	}{
+		{
+			name:      "should succeed when the spec is not modified",
+			expectErr: false,
+			c:         old.DeepCopy(),
+		},
+		{
+			name:      "should succeed when the spec of a stored invalid template is not modified",
+			expectErr: false,
+			old:       storedInvalid,
+			c:         storedInvalidAnnotated,
+		},
+		{
+			name:      "should fail when the spec of a stored invalid template is modified",
+			expectErr: true,
+			old:       storedInvalid,
+			c:         invalidCleaningMode,
+		},
+		{
+			name:      "should fail when the image is modified",
+			expectErr: true,
+			c:         newImage,
+		},
+		{
+			name:      "should fail when the image of a deleted template is modified",
+			expectErr: true,
+			c:         deletedNewImage,
+		},
+		{
+			name:      "should fail when the customDeploy is modified",
+			expectErr: true,
+			c:         newCustomDeploy,
+		},
+		{
+			name:      "should fail when the hostSelector is modified",
+			expectErr: true,
+			c:         newHostSelector,
+		},
+		{
+			name:      "should fail when the dataTemplate is modified",
+			expectErr: true,
+			c:         newDataTemplate,
+		},
+		{
+			name:      "should succeed when the fields are set to their default",
+			expectErr: false,
+			c:         defaultedTemplate,
+		},
+		{
+			name:      "should fail when the dataTemplate namespace is modified",
+			expectErr: true,
+			c:         otherNamespaceDataTemplate,
+		},
+		{
+			name:      "should succeed when the image is modified with the allow-template-update annotation",
+			expectErr: false,
+			c:         allowedImage,
+		},
+		{
+			name:      "should succeed when the image is modified in a topology dry run",
+			expectErr: false,
+			c:         dryRunImage,
+		},
+		{
+			name:      "should fail when an allowed update is invalid",
+			expectErr: true,
+			c:         allowedInvalidImage,
+		},
+		{
+			name:      "should succeed when another field is modified",
+			expectErr: false,
+			c:         newCleaningMode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldTemplate := old
+			if tt.old != nil {
+				oldTemplate = tt.old
+			}
+			_, err := tt.c.ValidateUpdate(oldTemplate)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
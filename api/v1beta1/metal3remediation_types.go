@@ -17,7 +17,9 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 type RemediationType string
@@ -27,8 +29,19 @@ const (
 	// removing it from the apiserver.
 	RemediationFinalizer = "metal3remediation.infrastructure.cluster.x-k8s.io"
 
+	// RemediationDeleteHookAnnotation is the pre-terminate delete hook set on
+	// the Machine while its host is remediated, so that the Machine is not
+	// deleted in the middle of a reboot. Its value is the name of the
+	// Metal3Remediation.
+	RemediationDeleteHookAnnotation = clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/capm3-remediation"
+
 	// RebootRemediationStrategy sets RemediationType to Reboot.
 	RebootRemediationStrategy RemediationType = "Reboot"
+
+	// EscalateRemediationStrategy sets RemediationType to Escalate. The host is
+	// rebooted up to retryLimit times, then the Machine is deleted so that the host
+	// is deprovisioned and the Machine replaced.
+	EscalateRemediationStrategy RemediationType = "Escalate"
 )
 
 const (
@@ -44,6 +57,43 @@ const (
 	// PhaseFailed represents the state where host will not be remediated.
 	// Remediation Controller will set the state to PhaseFailed when a user has set bmh.Spec.Online to false.
 	PhaseFailed = "Failed"
+
+	// PhaseDeprovisioning represents the state where the reboots of the Escalate strategy have failed and the
+	// controller is deleting the unhealthy Machine object so that the host is deprovisioned.
+	PhaseDeprovisioning = "Deprovisioning"
+
+	// PhaseDone represents the state where the Escalate strategy has deleted the unhealthy Machine object.
+	PhaseDone = "Done"
+)
+
+const (
+	// RemediationHistoryLimit is the maximum number of remediation attempts
+	// kept in the history of a Metal3Remediation, the oldest are dropped.
+	RemediationHistoryLimit = 10
+
+	// RemediationOutcomeSucceeded is the outcome of a remediation attempt
+	// after which the host was powered on and the Node restored.
+	RemediationOutcomeSucceeded = "Succeeded"
+
+	// RemediationOutcomeTimedOut is the outcome of a remediation attempt
+	// after which the Node did not get healthy before the timeout.
+	RemediationOutcomeTimedOut = "TimedOut"
+)
+
+// NodeRemediationMechanism is how the Node of the workload cluster is handled
+// while the host is rebooted.
+// +kubebuilder:validation:Enum=OutOfServiceTaint;Deletion
+type NodeRemediationMechanism string
+
+const (
+	// NodeRemediationOutOfServiceTaint taints the Node with the out-of-service
+	// NoExecute taint while the host is powered off, supported by Kubernetes
+	// 1.28 and later.
+	NodeRemediationOutOfServiceTaint NodeRemediationMechanism = "OutOfServiceTaint"
+
+	// NodeRemediationDeletion deletes the Node while the host is powered off,
+	// and restores its annotations and labels once it is recreated.
+	NodeRemediationDeletion NodeRemediationMechanism = "Deletion"
 )
 
 // Metal3RemediationSpec defines the desired state of Metal3Remediation.
@@ -65,7 +115,7 @@ type RemediationStrategy struct {
 
 	// Sets the timeout between remediation retries.
 	// +optional
-	Timeout *metav1.Duration `json:"timeout"`
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 // Metal3RemediationStatus defines the observed state of Metal3Remediation.
@@ -83,6 +133,91 @@ type Metal3RemediationStatus struct {
 	// LastRemediated identifies when the host was last remediated
 	// +optional
 	LastRemediated *metav1.Time `json:"lastRemediated,omitempty"`
+
+	// LastPhaseTransition identifies when the remediation last changed phase.
+	// +optional
+	LastPhaseTransition *metav1.Time `json:"lastPhaseTransition,omitempty"`
+
+	// HostRef references the BareMetalHost of the unhealthy machine. It is
+	// recorded when the remediation starts, so that the host can still be
+	// cleaned up if the Machine and the Metal3Machine are deleted first.
+	// +optional
+	HostRef *corev1.ObjectReference `json:"hostRef,omitempty"`
+
+	// ConsumerRef references the Metal3Machine that consumed the host when
+	// the remediation started. The host is left alone once it is consumed by
+	// another Metal3Machine.
+	// +optional
+	ConsumerRef *corev1.ObjectReference `json:"consumerRef,omitempty"`
+
+	// NodeRemediationMechanism is how the Node of the workload cluster was
+	// handled while the host was rebooted.
+	// +optional
+	NodeRemediationMechanism NodeRemediationMechanism `json:"nodeRemediationMechanism,omitempty"`
+
+	// FailureDomain is the failure domain of the unhealthy Machine, or of its
+	// BareMetalHost when the Machine has none, recorded when the remediation
+	// starts.
+	// +optional
+	FailureDomain string `json:"failureDomain,omitempty"`
+
+	// HostZone is the topology.kubernetes.io/zone label of the BareMetalHost
+	// of the unhealthy Machine, recorded when the remediation starts.
+	// +optional
+	HostZone string `json:"hostZone,omitempty"`
+
+	// History records the last remediation attempts, at most
+	// RemediationHistoryLimit, from the oldest to the latest.
+	// +optional
+	History []RemediationAttempt `json:"history,omitempty"`
+
+	// Conditions defines current service state of the Metal3Remediation.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// RemediationAttempt records an attempt to remediate the host of the
+// unhealthy machine.
+type RemediationAttempt struct {
+	// RetryCount is the retryCount of the remediation when the attempt
+	// started, it identifies the attempt.
+	RetryCount int `json:"retryCount"`
+
+	// StartTime is when the attempt started.
+	StartTime metav1.Time `json:"startTime"`
+
+	// Strategy is the remediation strategy of the attempt.
+	// +optional
+	Strategy RemediationType `json:"strategy,omitempty"`
+
+	// PowerTransitions are the changes of the power state of the host
+	// observed during the attempt.
+	// +optional
+	PowerTransitions []PowerTransition `json:"powerTransitions,omitempty"`
+
+	// NodeAction is how the Node of the workload cluster was handled during
+	// the attempt, empty if it was left alone.
+	// +optional
+	NodeAction NodeRemediationMechanism `json:"nodeAction,omitempty"`
+
+	// Outcome is the outcome of the attempt, Succeeded or TimedOut, empty
+	// while the attempt is in progress.
+	// +optional
+	Outcome string `json:"outcome,omitempty"`
+
+	// EndTime is when the outcome of the attempt was recorded.
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+}
+
+// PowerTransition is a change of the power state of a host observed during a
+// remediation attempt.
+type PowerTransition struct {
+	// PoweredOn is the power state of the host after the transition.
+	PoweredOn bool `json:"poweredOn"`
+
+	// Time is when the transition was observed.
+	Time metav1.Time `json:"time"`
 }
 
 // +kubebuilder:object:root=true
@@ -120,6 +255,16 @@ type Metal3RemediationList struct {
 	Items           []Metal3Remediation `json:"items"`
 }
 
+// GetConditions returns the list of conditions for a Metal3Remediation API object.
+func (r *Metal3Remediation) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a Metal3Remediation object.
+func (r *Metal3Remediation) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&Metal3Remediation{}, &Metal3RemediationList{})
 }
@@ -74,13 +74,13 @@ func (r *Metal3Remediation) validate() error {
 		)
 	}
 
-	if r.Spec.Strategy.Type != RebootRemediationStrategy {
+	if r.Spec.Strategy.Type != RebootRemediationStrategy && r.Spec.Strategy.Type != EscalateRemediationStrategy {
 		allErrs = append(
 			allErrs,
 			field.Invalid(
 				field.NewPath("spec", "strategy", "type"),
 				r.Spec.Strategy.Type,
-				"is only supported remediation strategy",
+				"is not a supported remediation strategy",
 			),
 		)
 	}
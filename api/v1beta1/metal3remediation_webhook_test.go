@@ -78,6 +78,20 @@ func TestMetal3RemediationValidation(t *testing.T) {
 			strategy:  RebootRemediationStrategy,
 			expectErr: false,
 		},
+		{
+			name:      "when the Remediation Type is Escalate",
+			timeout:   &threeMinutes,
+			limit:     2,
+			strategy:  EscalateRemediationStrategy,
+			expectErr: false,
+		},
+		{
+			name:      "when the Remediation Type is Escalate and the RetryLimit is less than minRetryLimit",
+			timeout:   &threeMinutes,
+			limit:     0,
+			strategy:  EscalateRemediationStrategy,
+			expectErr: true,
+		},
 		{
 			name:      "when the Remediation Type is not Reboot",
 			timeout:   &threeMinutes,
@@ -100,13 +100,14 @@ func (r *Metal3RemediationTemplate) validate() error {
 		)
 	}
 
-	if r.Spec.Template.Spec.Strategy.Type != RebootRemediationStrategy {
+	if r.Spec.Template.Spec.Strategy.Type != RebootRemediationStrategy &&
+		r.Spec.Template.Spec.Strategy.Type != EscalateRemediationStrategy {
 		allErrs = append(
 			allErrs,
 			field.Invalid(
 				field.NewPath("spec", "template", "spec", "strategy", "type"),
 				r.Spec.Template.Spec.Strategy.Type,
-				"only supported remediation strategy is reboot",
+				"supported remediation strategies are reboot and escalate",
 			),
 		)
 	}
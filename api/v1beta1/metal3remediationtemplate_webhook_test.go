@@ -126,6 +126,13 @@ func TestMetal3RemediationTemplateValidation(t *testing.T) {
 			strategy:  RebootRemediationStrategy,
 			expectErr: false,
 		},
+		{
+			name:      "when the Remediation Type is Escalate",
+			timeout:   &threeMinutes,
+			limit:     2,
+			strategy:  EscalateRemediationStrategy,
+			expectErr: false,
+		},
 		{
 			name:      "when the Remediation Type is not Reboot",
 			timeout:   &threeMinutes,
@@ -45,6 +45,21 @@ func (in *APIEndpoint) DeepCopy() *APIEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomDeploy) DeepCopyInto(out *CustomDeploy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomDeploy.
+func (in *CustomDeploy) DeepCopy() *CustomDeploy {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomDeploy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FromPool) DeepCopyInto(out *FromPool) {
 	*out = *in
@@ -60,6 +75,21 @@ func (in *FromPool) DeepCopy() *FromPool {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareSelector) DeepCopyInto(out *HardwareSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareSelector.
+func (in *HardwareSelector) DeepCopy() *HardwareSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostSelector) DeepCopyInto(out *HostSelector) {
 	*out = *in
@@ -77,6 +107,18 @@ func (in *HostSelector) DeepCopyInto(out *HostSelector) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MatchHardware != nil {
+		in, out := &in.MatchHardware, &out.MatchHardware
+		*out = new(HardwareSelector)
+		**out = **in
+	}
+	if in.Preferences != nil {
+		in, out := &in.Preferences, &out.Preferences
+		*out = make([]HostSelectorPreference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSelector.
@@ -89,6 +131,33 @@ func (in *HostSelector) DeepCopy() *HostSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSelectorPreference) DeepCopyInto(out *HostSelectorPreference) {
+	*out = *in
+	if in.MatchExpressions != nil {
+		in, out := &in.MatchExpressions, &out.MatchExpressions
+		*out = make([]HostSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MatchHardware != nil {
+		in, out := &in.MatchHardware, &out.MatchHardware
+		*out = new(HardwareSelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSelectorPreference.
+func (in *HostSelectorPreference) DeepCopy() *HostSelectorPreference {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSelectorPreference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostSelectorRequirement) DeepCopyInto(out *HostSelectorRequirement) {
 	*out = *in
@@ -122,6 +191,11 @@ func (in *Image) DeepCopyInto(out *Image) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.UserDataFormat != nil {
+		in, out := &in.UserDataFormat, &out.UserDataFormat
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Image.
@@ -134,6 +208,21 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexAllocation) DeepCopyInto(out *IndexAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexAllocation.
+func (in *IndexAllocation) DeepCopy() *IndexAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetaData) DeepCopyInto(out *MetaData) {
 	*out = *in
@@ -192,6 +281,11 @@ func (in *MetaData) DeepCopyInto(out *MetaData) {
 		*out = make([]MetaDataFromAnnotation, len(*in))
 		copy(*out, *in)
 	}
+	if in.FromTemplates != nil {
+		in, out := &in.FromTemplates, &out.FromTemplates
+		*out = make([]MetaDataFromTemplate, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetaData.
@@ -234,6 +328,21 @@ func (in *MetaDataFromLabel) DeepCopy() *MetaDataFromLabel {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetaDataFromTemplate) DeepCopyInto(out *MetaDataFromTemplate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetaDataFromTemplate.
+func (in *MetaDataFromTemplate) DeepCopy() *MetaDataFromTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MetaDataFromTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetaDataHostInterface) DeepCopyInto(out *MetaDataHostInterface) {
 	*out = *in
@@ -344,7 +453,7 @@ func (in *Metal3Cluster) DeepCopyInto(out *Metal3Cluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -402,6 +511,21 @@ func (in *Metal3ClusterList) DeepCopyObject() runtime.Object {
 func (in *Metal3ClusterSpec) DeepCopyInto(out *Metal3ClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.ControlPlaneEndpointFromPool != nil {
+		in, out := &in.ControlPlaneEndpointFromPool, &out.ControlPlaneEndpointFromPool
+		*out = new(v1.TypedLocalObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudProviderEnabled != nil {
+		in, out := &in.CloudProviderEnabled, &out.CloudProviderEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.HostClusterKubeconfigSecret != nil {
+		in, out := &in.HostClusterKubeconfigSecret, &out.HostClusterKubeconfigSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3ClusterSpec.
@@ -431,6 +555,13 @@ func (in *Metal3ClusterStatus) DeepCopyInto(out *Metal3ClusterStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make(apiv1beta1.FailureDomains, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(apiv1beta1.Conditions, len(*in))
@@ -565,6 +696,18 @@ func (in *Metal3DataClaimStatus) DeepCopyInto(out *Metal3DataClaimStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.IndexAllocation != nil {
+		in, out := &in.IndexAllocation, &out.IndexAllocation
+		*out = new(IndexAllocation)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3DataClaimStatus.
@@ -644,6 +787,18 @@ func (in *Metal3DataStatus) DeepCopyInto(out *Metal3DataStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.HostRef != nil {
+		in, out := &in.HostRef, &out.HostRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3DataStatus.
@@ -683,6 +838,26 @@ func (in *Metal3DataTemplate) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3DataTemplateIndexSummary) DeepCopyInto(out *Metal3DataTemplateIndexSummary) {
+	*out = *in
+	if in.HighestIndex != nil {
+		in, out := &in.HighestIndex, &out.HighestIndex
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3DataTemplateIndexSummary.
+func (in *Metal3DataTemplateIndexSummary) DeepCopy() *Metal3DataTemplateIndexSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3DataTemplateIndexSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metal3DataTemplateList) DeepCopyInto(out *Metal3DataTemplateList) {
 	*out = *in
@@ -754,6 +929,25 @@ func (in *Metal3DataTemplateStatus) DeepCopyInto(out *Metal3DataTemplateStatus)
 			(*out)[key] = val
 		}
 	}
+	if in.LastIndexes != nil {
+		in, out := &in.LastIndexes, &out.LastIndexes
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.IndexSummary != nil {
+		in, out := &in.IndexSummary, &out.IndexSummary
+		*out = new(Metal3DataTemplateIndexSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3DataTemplateStatus.
@@ -825,6 +1019,154 @@ func (in *Metal3MachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachinePool) DeepCopyInto(out *Metal3MachinePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachinePool.
+func (in *Metal3MachinePool) DeepCopy() *Metal3MachinePool {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachinePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Metal3MachinePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachinePoolHostTemplate) DeepCopyInto(out *Metal3MachinePoolHostTemplate) {
+	*out = *in
+	in.Image.DeepCopyInto(&out.Image)
+	if in.CustomDeploy != nil {
+		in, out := &in.CustomDeploy, &out.CustomDeploy
+		*out = new(CustomDeploy)
+		**out = **in
+	}
+	in.HostSelector.DeepCopyInto(&out.HostSelector)
+	if in.AutomatedCleaningMode != nil {
+		in, out := &in.AutomatedCleaningMode, &out.AutomatedCleaningMode
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachinePoolHostTemplate.
+func (in *Metal3MachinePoolHostTemplate) DeepCopy() *Metal3MachinePoolHostTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachinePoolHostTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachinePoolList) DeepCopyInto(out *Metal3MachinePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Metal3MachinePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachinePoolList.
+func (in *Metal3MachinePoolList) DeepCopy() *Metal3MachinePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachinePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Metal3MachinePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachinePoolSpec) DeepCopyInto(out *Metal3MachinePoolSpec) {
+	*out = *in
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachinePoolSpec.
+func (in *Metal3MachinePoolSpec) DeepCopy() *Metal3MachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachinePoolStatus) DeepCopyInto(out *Metal3MachinePoolStatus) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachinePoolStatusFailure)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachinePoolStatus.
+func (in *Metal3MachinePoolStatus) DeepCopy() *Metal3MachinePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachinePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metal3MachineSpec) DeepCopyInto(out *Metal3MachineSpec) {
 	*out = *in
@@ -834,6 +1176,11 @@ func (in *Metal3MachineSpec) DeepCopyInto(out *Metal3MachineSpec) {
 		**out = **in
 	}
 	in.Image.DeepCopyInto(&out.Image)
+	if in.CustomDeploy != nil {
+		in, out := &in.CustomDeploy, &out.CustomDeploy
+		*out = new(CustomDeploy)
+		**out = **in
+	}
 	if in.UserData != nil {
 		in, out := &in.UserData, &out.UserData
 		*out = new(v1.SecretReference)
@@ -860,6 +1207,38 @@ func (in *Metal3MachineSpec) DeepCopyInto(out *Metal3MachineSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Metal3DrainTimeout != nil {
+		in, out := &in.Metal3DrainTimeout, &out.Metal3DrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.HostTolerations != nil {
+		in, out := &in.HostTolerations, &out.HostTolerations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RootDeviceHints != nil {
+		in, out := &in.RootDeviceHints, &out.RootDeviceHints
+		*out = new(RootDeviceHints)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UserDataAppend != nil {
+		in, out := &in.UserDataAppend, &out.UserDataAppend
+		*out = new(UserDataAppend)
+		**out = **in
+	}
+	if in.NodeMetadata != nil {
+		in, out := &in.NodeMetadata, &out.NodeMetadata
+		*out = new(NodeMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FirmwareSettings != nil {
+		in, out := &in.FirmwareSettings, &out.FirmwareSettings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachineSpec.
@@ -914,6 +1293,15 @@ func (in *Metal3MachineStatus) DeepCopyInto(out *Metal3MachineStatus) {
 		*out = new(v1.SecretReference)
 		**out = **in
 	}
+	if in.RenderedHost != nil {
+		in, out := &in.RenderedHost, &out.RenderedHost
+		*out = new(RenderedHost)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EstimatedReadyTime != nil {
+		in, out := &in.EstimatedReadyTime, &out.EstimatedReadyTime
+		*out = (*in).DeepCopy()
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(apiv1beta1.Conditions, len(*in))
@@ -939,6 +1327,7 @@ func (in *Metal3MachineTemplate) DeepCopyInto(out *Metal3MachineTemplate) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachineTemplate.
@@ -1011,6 +1400,16 @@ func (in *Metal3MachineTemplateResource) DeepCopy() *Metal3MachineTemplateResour
 func (in *Metal3MachineTemplateSpec) DeepCopyInto(out *Metal3MachineTemplateSpec) {
 	*out = *in
 	in.Template.DeepCopyInto(&out.Template)
+	if in.NodeReuseLabelKeys != nil {
+		in, out := &in.NodeReuseLabelKeys, &out.NodeReuseLabelKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeReuseGracePeriod != nil {
+		in, out := &in.NodeReuseGracePeriod, &out.NodeReuseGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachineTemplateSpec.
@@ -1023,6 +1422,35 @@ func (in *Metal3MachineTemplateSpec) DeepCopy() *Metal3MachineTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Metal3MachineTemplateStatus) DeepCopyInto(out *Metal3MachineTemplateStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeReuseReservations != nil {
+		in, out := &in.NodeReuseReservations, &out.NodeReuseReservations
+		*out = make([]NodeReuseReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3MachineTemplateStatus.
+func (in *Metal3MachineTemplateStatus) DeepCopy() *Metal3MachineTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(Metal3MachineTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metal3Remediation) DeepCopyInto(out *Metal3Remediation) {
 	*out = *in
@@ -1109,6 +1537,34 @@ func (in *Metal3RemediationStatus) DeepCopyInto(out *Metal3RemediationStatus) {
 		in, out := &in.LastRemediated, &out.LastRemediated
 		*out = (*in).DeepCopy()
 	}
+	if in.LastPhaseTransition != nil {
+		in, out := &in.LastPhaseTransition, &out.LastPhaseTransition
+		*out = (*in).DeepCopy()
+	}
+	if in.HostRef != nil {
+		in, out := &in.HostRef, &out.HostRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	if in.ConsumerRef != nil {
+		in, out := &in.ConsumerRef, &out.ConsumerRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]RemediationAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Metal3RemediationStatus.
@@ -1261,6 +1717,11 @@ func (in *NetworkDataIPv4) DeepCopyInto(out *NetworkDataIPv4) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataIPv4.
@@ -1283,6 +1744,11 @@ func (in *NetworkDataIPv4DHCP) DeepCopyInto(out *NetworkDataIPv4DHCP) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataIPv4DHCP.
@@ -1310,6 +1776,11 @@ func (in *NetworkDataIPv6) DeepCopyInto(out *NetworkDataIPv6) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataIPv6.
@@ -1332,6 +1803,11 @@ func (in *NetworkDataIPv6DHCP) DeepCopyInto(out *NetworkDataIPv6DHCP) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DefaultRoute != nil {
+		in, out := &in.DefaultRoute, &out.DefaultRoute
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataIPv6DHCP.
@@ -1393,6 +1869,11 @@ func (in *NetworkDataLinkBond) DeepCopyInto(out *NetworkDataLinkBond) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DefaultRoutePriority != nil {
+		in, out := &in.DefaultRoutePriority, &out.DefaultRoutePriority
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataLinkBond.
@@ -1413,6 +1894,18 @@ func (in *NetworkDataLinkEthernet) DeepCopyInto(out *NetworkDataLinkEthernet) {
 		*out = new(NetworkLinkEthernetMac)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.VendorExtensions != nil {
+		in, out := &in.VendorExtensions, &out.VendorExtensions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DefaultRoutePriority != nil {
+		in, out := &in.DefaultRoutePriority, &out.DefaultRoutePriority
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataLinkEthernet.
@@ -1433,6 +1926,11 @@ func (in *NetworkDataLinkVlan) DeepCopyInto(out *NetworkDataLinkVlan) {
 		*out = new(NetworkLinkEthernetMac)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DefaultRoutePriority != nil {
+		in, out := &in.DefaultRoutePriority, &out.DefaultRoutePriority
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataLinkVlan.
@@ -1500,6 +1998,16 @@ func (in *NetworkDataRoutev4) DeepCopyInto(out *NetworkDataRoutev4) {
 	*out = *in
 	in.Gateway.DeepCopyInto(&out.Gateway)
 	in.Services.DeepCopyInto(&out.Services)
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(int)
+		**out = **in
+	}
+	if in.Table != nil {
+		in, out := &in.Table, &out.Table
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataRoutev4.
@@ -1517,6 +2025,16 @@ func (in *NetworkDataRoutev6) DeepCopyInto(out *NetworkDataRoutev6) {
 	*out = *in
 	in.Gateway.DeepCopyInto(&out.Gateway)
 	in.Services.DeepCopyInto(&out.Services)
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(int)
+		**out = **in
+	}
+	if in.Table != nil {
+		in, out := &in.Table, &out.Table
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDataRoutev6.
@@ -1679,6 +2197,101 @@ func (in *NetworkLinkEthernetMac) DeepCopy() *NetworkLinkEthernetMac {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMetadata) DeepCopyInto(out *NodeMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMetadata.
+func (in *NodeMetadata) DeepCopy() *NodeMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeReuseReservation) DeepCopyInto(out *NodeReuseReservation) {
+	*out = *in
+	if in.Since != nil {
+		in, out := &in.Since, &out.Since
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeReuseReservation.
+func (in *NodeReuseReservation) DeepCopy() *NodeReuseReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeReuseReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerTransition) DeepCopyInto(out *PowerTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerTransition.
+func (in *PowerTransition) DeepCopy() *PowerTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemediationAttempt) DeepCopyInto(out *RemediationAttempt) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.PowerTransitions != nil {
+		in, out := &in.PowerTransitions, &out.PowerTransitions
+		*out = make([]PowerTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemediationAttempt.
+func (in *RemediationAttempt) DeepCopy() *RemediationAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(RemediationAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemediationStrategy) DeepCopyInto(out *RemediationStrategy) {
 	*out = *in
@@ -1698,3 +2311,58 @@ func (in *RemediationStrategy) DeepCopy() *RemediationStrategy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RenderedHost) DeepCopyInto(out *RenderedHost) {
+	*out = *in
+	if in.MACAddresses != nil {
+		in, out := &in.MACAddresses, &out.MACAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RenderedHost.
+func (in *RenderedHost) DeepCopy() *RenderedHost {
+	if in == nil {
+		return nil
+	}
+	out := new(RenderedHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RootDeviceHints) DeepCopyInto(out *RootDeviceHints) {
+	*out = *in
+	if in.Rotational != nil {
+		in, out := &in.Rotational, &out.Rotational
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RootDeviceHints.
+func (in *RootDeviceHints) DeepCopy() *RootDeviceHints {
+	if in == nil {
+		return nil
+	}
+	out := new(RootDeviceHints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserDataAppend) DeepCopyInto(out *UserDataAppend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserDataAppend.
+func (in *UserDataAppend) DeepCopy() *UserDataAppend {
+	if in == nil {
+		return nil
+	}
+	out := new(UserDataAppend)
+	in.DeepCopyInto(out)
+	return out
+}
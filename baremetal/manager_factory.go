@@ -20,7 +20,10 @@ import (
 	"github.com/go-logr/logr"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	capm3remote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -46,21 +49,83 @@ type ManagerFactoryInterface interface {
 	NewRemediationManager(*infrav1.Metal3Remediation, *infrav1.Metal3Machine, *clusterv1.Machine, logr.Logger) (
 		RemediationManagerInterface, error,
 	)
+	NewMachinePoolManager(*clusterv1.Cluster, *expv1.MachinePool, *infrav1.Metal3MachinePool, logr.Logger) (
+		MachinePoolManagerInterface, error,
+	)
 }
 
-// ManagerFactory only contains a client.
+// ManagerFactory contains a client and the settings of the managers.
 type ManagerFactory struct {
-	client client.Client
+	client           client.Client
+	clientGetter     ClientGetter
+	providerIDFormat ProviderIDFormat
+	recorder         record.EventRecorder
+	hostClientGetter HostClientGetter
+	hostSelector     labels.Selector
+	apiReader        client.Reader
 }
 
 // NewManagerFactory returns a new factory.
 func NewManagerFactory(client client.Client) ManagerFactory {
-	return ManagerFactory{client: client}
+	return ManagerFactory{
+		client:           client,
+		clientGetter:     capm3remote.NewClusterClient,
+		providerIDFormat: ProviderIDFormatNamespacedName,
+	}
+}
+
+// WithClientGetter returns a copy of the factory whose managers use the given
+// getter to access the workload clusters.
+func (f ManagerFactory) WithClientGetter(clientGetter ClientGetter) ManagerFactory {
+	f.clientGetter = clientGetter
+	return f
+}
+
+// WithProviderIDFormat returns a copy of the factory whose machine managers
+// set the providerID of the Nodes in the given format.
+func (f ManagerFactory) WithProviderIDFormat(format ProviderIDFormat) ManagerFactory {
+	f.providerIDFormat = format
+	return f
+}
+
+// WithEventRecorder returns a copy of the factory whose cluster, machine and
+// machine template managers record their events with the given recorder.
+func (f ManagerFactory) WithEventRecorder(recorder record.EventRecorder) ManagerFactory {
+	f.recorder = recorder
+	return f
+}
+
+// WithHostClientGetter returns a copy of the factory whose machine managers
+// use the given getter to access the BareMetalHosts registered in another
+// cluster than the one of the controller.
+func (f ManagerFactory) WithHostClientGetter(hostClientGetter HostClientGetter) ManagerFactory {
+	f.hostClientGetter = hostClientGetter
+	return f
+}
+
+// WithHostLabelSelector returns a copy of the factory whose machine managers
+// only choose the BareMetalHosts matching the given selector.
+func (f ManagerFactory) WithHostLabelSelector(selector labels.Selector) ManagerFactory {
+	f.hostSelector = selector
+	return f
+}
+
+// WithAPIReader returns a copy of the factory whose machine managers read the
+// annotated BareMetalHosts missing from the cache with the given uncached
+// reader.
+func (f ManagerFactory) WithAPIReader(reader client.Reader) ManagerFactory {
+	f.apiReader = reader
+	return f
 }
 
 // NewClusterManager creates a new ClusterManager.
 func (f ManagerFactory) NewClusterManager(cluster *clusterv1.Cluster, capm3Cluster *infrav1.Metal3Cluster, clusterLog logr.Logger) (ClusterManagerInterface, error) {
-	return NewClusterManager(f.client, cluster, capm3Cluster, clusterLog)
+	clusterMgr, err := NewClusterManager(f.client, cluster, capm3Cluster, clusterLog)
+	if err != nil {
+		return nil, err
+	}
+	clusterMgr.(*ClusterManager).Recorder = f.recorder
+	return clusterMgr, nil
 }
 
 // NewMachineManager creates a new MachineManager.
@@ -68,8 +133,17 @@ func (f ManagerFactory) NewMachineManager(capiCluster *clusterv1.Cluster,
 	capm3Cluster *infrav1.Metal3Cluster,
 	capiMachine *clusterv1.Machine, capm3Machine *infrav1.Metal3Machine,
 	machineLog logr.Logger) (MachineManagerInterface, error) {
-	return NewMachineManager(f.client, capiCluster, capm3Cluster, capiMachine,
+	machineMgr, err := NewMachineManager(f.client, capiCluster, capm3Cluster, capiMachine,
 		capm3Machine, machineLog)
+	if err != nil {
+		return nil, err
+	}
+	machineMgr.ProviderIDFormat = f.providerIDFormat
+	machineMgr.Recorder = f.recorder
+	machineMgr.HostClientGetter = f.hostClientGetter
+	machineMgr.HostLabelSelector = f.hostSelector
+	machineMgr.APIReader = f.apiReader
+	return machineMgr, nil
 }
 
 // NewDataTemplateManager creates a new DataTemplateManager.
@@ -86,12 +160,24 @@ func (f ManagerFactory) NewDataManager(metadata *infrav1.Metal3Data, metadataLog
 func (f ManagerFactory) NewMachineTemplateManager(capm3Template *infrav1.Metal3MachineTemplate,
 	capm3MachineList *infrav1.Metal3MachineList,
 	metadataLog logr.Logger) (TemplateManagerInterface, error) {
-	return NewMachineTemplateManager(f.client, capm3Template, capm3MachineList, metadataLog)
+	templateMgr, err := NewMachineTemplateManager(f.client, capm3Template, capm3MachineList, metadataLog)
+	if err != nil {
+		return nil, err
+	}
+	templateMgr.Recorder = f.recorder
+	return templateMgr, nil
 }
 
 // NewRemediationManager creates a new RemediationManager.
 func (f ManagerFactory) NewRemediationManager(remediation *infrav1.Metal3Remediation,
 	metal3machine *infrav1.Metal3Machine, machine *clusterv1.Machine,
 	remediationLog logr.Logger) (RemediationManagerInterface, error) {
-	return NewRemediationManager(f.client, capm3remote.NewClusterClient, remediation, metal3machine, machine, remediationLog)
+	return NewRemediationManager(f.client, f.clientGetter, remediation, metal3machine, machine, remediationLog)
+}
+
+// NewMachinePoolManager creates a new MachinePoolManager.
+func (f ManagerFactory) NewMachinePoolManager(cluster *clusterv1.Cluster,
+	machinePool *expv1.MachinePool, metal3MachinePool *infrav1.Metal3MachinePool,
+	machinePoolLog logr.Logger) (MachinePoolManagerInterface, error) {
+	return NewMachinePoolManager(f.client, cluster, machinePool, metal3MachinePool, machinePoolLog)
 }
@@ -22,7 +22,9 @@ import (
 	. "github.com/onsi/gomega"
 
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -70,6 +72,41 @@ var _ = Describe("Manager factory testing", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("returns a metal3 machine manager with the providerID format", func() {
+		machineMgr, err := managerFactory.WithProviderIDFormat(ProviderIDFormatUID).NewMachineManager(
+			&clusterv1.Cluster{}, &infrav1.Metal3Cluster{}, &clusterv1.Machine{},
+			&infrav1.Metal3Machine{}, clusterLog,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machineMgr.(*MachineManager).ProviderIDFormat).To(Equal(ProviderIDFormatUID))
+
+		machineMgr, err = managerFactory.NewMachineManager(&clusterv1.Cluster{},
+			&infrav1.Metal3Cluster{}, &clusterv1.Machine{}, &infrav1.Metal3Machine{},
+			clusterLog,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machineMgr.(*MachineManager).ProviderIDFormat).To(Equal(ProviderIDFormatNamespacedName))
+	})
+
+	It("returns a Metal3Machine manager with the event recorder", func() {
+		recorder := record.NewFakeRecorder(1)
+		machineMgr, err := managerFactory.WithEventRecorder(recorder).NewMachineManager(
+			&clusterv1.Cluster{}, &infrav1.Metal3Cluster{}, &clusterv1.Machine{},
+			&infrav1.Metal3Machine{}, clusterLog,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machineMgr.(*MachineManager).Recorder).To(Equal(recorder))
+	})
+
+	It("returns a Metal3Cluster manager with the event recorder", func() {
+		recorder := record.NewFakeRecorder(1)
+		clusterMgr, err := managerFactory.WithEventRecorder(recorder).NewClusterManager(
+			&clusterv1.Cluster{}, &infrav1.Metal3Cluster{}, clusterLog,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(clusterMgr.(*ClusterManager).Recorder).To(Equal(recorder))
+	})
+
 	It("returns a DataTemplate manager", func() {
 		_, err := managerFactory.NewDataTemplateManager(&infrav1.Metal3DataTemplate{}, clusterLog)
 		Expect(err).NotTo(HaveOccurred())
@@ -85,8 +122,22 @@ var _ = Describe("Manager factory testing", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("returns a MachineTemplate manager with the event recorder", func() {
+		recorder := record.NewFakeRecorder(1)
+		templateMgr, err := managerFactory.WithEventRecorder(recorder).NewMachineTemplateManager(
+			&infrav1.Metal3MachineTemplate{}, &infrav1.Metal3MachineList{}, clusterLog,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templateMgr.(*MachineTemplateManager).Recorder).To(Equal(recorder))
+	})
+
 	It("returns a Remediation manager", func() {
 		_, err := managerFactory.NewRemediationManager(&infrav1.Metal3Remediation{}, &infrav1.Metal3Machine{}, &clusterv1.Machine{}, clusterLog)
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	It("returns a MachinePool manager", func() {
+		_, err := managerFactory.NewMachinePoolManager(&clusterv1.Cluster{}, &expv1.MachinePool{}, &infrav1.Metal3MachinePool{}, clusterLog)
+		Expect(err).NotTo(HaveOccurred())
+	})
 })
@@ -19,6 +19,8 @@ package baremetal
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -26,23 +28,67 @@ import (
 	// TODO Why blank import ?
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // ClusterManagerInterface is an interface for a ClusterManager.
 type ClusterManagerInterface interface {
 	Create(context.Context) error
-	Delete() error
+	AllocateControlPlaneEndpoint(context.Context) error
+	Delete(context.Context) error
 	UpdateClusterStatus() error
+	UpdateMachinesStatus(context.Context) error
 	SetFinalizer()
 	UnsetFinalizer()
 	CountDescendants(context.Context) (int, error)
+	ExpireNodeReuseLabels(context.Context) (time.Duration, error)
+	SetPauseAnnotations(context.Context) error
+	RemovePauseAnnotations(context.Context) error
+}
+
+// nodeReuseLabelRecheckAfter is the time after which an expired node reuse
+// label, kept because its owner still needs hosts, is checked again.
+const nodeReuseLabelRecheckAfter = time.Minute * 5
+
+// NodeReuseLabelTTL is the time after which the node reuse label of an
+// available BareMetalHost is removed, unless the KubeadmControlPlane,
+// MachineDeployment or node reuse group it names still needs hosts. Zero keeps
+// the labels until the hosts are consumed.
+var NodeReuseLabelTTL time.Duration
+
+// nodeReuseLabelTTLOf returns the time after which the node reuse label of the
+// host may be removed: the grace period of the Metal3MachineTemplate that
+// released it if any, otherwise NodeReuseLabelTTL.
+func nodeReuseLabelTTLOf(host *bmov1alpha1.BareMetalHost) time.Duration {
+	if gracePeriod, err := time.ParseDuration(host.Annotations[infrav1.HostNodeReuseGracePeriodAnnotation]); err == nil {
+		return gracePeriod
+	}
+	return NodeReuseLabelTTL
+}
+
+// deleteNodeReuseAnnotations deletes the annotations recording the node reuse
+// reservation of a host.
+func deleteNodeReuseAnnotations(annotations map[string]string) {
+	delete(annotations, infrav1.HostNodeReuseSinceAnnotation)
+	delete(annotations, infrav1.HostNodeReuseTemplateAnnotation)
+	delete(annotations, infrav1.HostNodeReuseGracePeriodAnnotation)
 }
 
 // ClusterManager is responsible for performing metal3 cluster reconciliation.
@@ -52,6 +98,9 @@ type ClusterManager struct {
 	Cluster       *clusterv1.Cluster
 	Metal3Cluster *infrav1.Metal3Cluster
 	Log           logr.Logger
+	// Recorder, when set, records the events of the BareMetalHosts whose
+	// node reuse label expired.
+	Recorder record.EventRecorder
 	// name string
 }
 
@@ -128,11 +177,172 @@ func (s *ClusterManager) ControlPlaneEndpoint() ([]infrav1.APIEndpoint, error) {
 	}, nil
 }
 
-// Delete function, no-op for now.
-func (s *ClusterManager) Delete() error {
+// AllocateControlPlaneEndpoint sets the host of the ControlPlaneEndpoint to
+// the address allocated from the pool referenced by
+// controlPlaneEndpointFromPool, claiming it if needed. It returns a transient
+// error until the address is allocated, and is a no-op without pool.
+func (s *ClusterManager) AllocateControlPlaneEndpoint(ctx context.Context) error {
+	poolRef := s.Metal3Cluster.Spec.ControlPlaneEndpointFromPool
+	if poolRef == nil {
+		return nil
+	}
+
+	var address string
+	var err error
+	if isMetal3IPPoolRef(*poolRef) {
+		address, err = s.addressFromM3Pool(ctx, *poolRef)
+	} else {
+		address, err = s.addressFromPool(ctx, *poolRef)
+	}
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		s.Log.Info("Waiting for the control plane endpoint to be allocated", "pool", poolRef.Name)
+		s.Metal3Cluster.Status.Ready = false
+		conditions.MarkFalse(s.Metal3Cluster, infrav1.BaremetalInfrastructureReadyCondition,
+			infrav1.WaitingForControlPlaneEndpointReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for an address from pool %s", poolRef.Name,
+		)
+		return WithTransientError(nil, requeueAfter)
+	}
+
+	if s.Metal3Cluster.Spec.ControlPlaneEndpoint.Host != address {
+		s.Log.Info("Control plane endpoint allocated", "pool", poolRef.Name, "address", address)
+		s.Metal3Cluster.Spec.ControlPlaneEndpoint.Host = address
+	}
 	return nil
 }
 
+// controlPlaneEndpointClaimName returns the name of the claim for the
+// control plane endpoint.
+func (s *ClusterManager) controlPlaneEndpointClaimName(poolRef corev1.TypedLocalObjectReference) string {
+	return s.Metal3Cluster.Name + "-" + poolRef.Name
+}
+
+// controlPlaneEndpointClaimMeta returns the ObjectMeta of the claim for the
+// control plane endpoint, owned by the Metal3Cluster.
+func (s *ClusterManager) controlPlaneEndpointClaimMeta(poolRef corev1.TypedLocalObjectReference) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:       s.controlPlaneEndpointClaimName(poolRef),
+		Namespace:  s.Metal3Cluster.Namespace,
+		Finalizers: []string{infrav1.ClusterFinalizer},
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "Metal3Cluster",
+				Name:       s.Metal3Cluster.Name,
+				UID:        s.Metal3Cluster.UID,
+				Controller: pointer.Bool(true),
+			},
+		},
+		Labels: inheritWatchLabel(map[string]string{
+			clusterv1.ClusterNameLabel: s.Cluster.Name,
+		}, s.Metal3Cluster.Labels),
+	}
+}
+
+// addressFromM3Pool returns the address allocated from a Metal3IPPool, or ""
+// if not allocated yet. It creates the Metal3IPClaim if it does not exist.
+func (s *ClusterManager) addressFromM3Pool(ctx context.Context, poolRef corev1.TypedLocalObjectReference) (string, error) {
+	ipClaim := &ipamv1.IPClaim{}
+	key := client.ObjectKey{Name: s.controlPlaneEndpointClaimName(poolRef), Namespace: s.Metal3Cluster.Namespace}
+	if err := s.client.Get(ctx, key, ipClaim); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", errors.Wrap(err, "failed to get the control plane endpoint claim")
+		}
+		ipClaim = &ipamv1.IPClaim{
+			ObjectMeta: s.controlPlaneEndpointClaimMeta(poolRef),
+			Spec: ipamv1.IPClaimSpec{
+				Pool: corev1.ObjectReference{
+					Name:      poolRef.Name,
+					Namespace: s.Metal3Cluster.Namespace,
+				},
+			},
+		}
+		return "", createObject(ctx, s.client, ipClaim)
+	}
+	if !ipClaim.DeletionTimestamp.IsZero() {
+		return "", nil
+	}
+	if ipClaim.Status.ErrorMessage != nil {
+		err := errors.Errorf("control plane endpoint allocation from %s failed: %s", poolRef.Name, *ipClaim.Status.ErrorMessage)
+		s.setError(err.Error(), capierrors.InvalidConfigurationClusterError)
+		return "", err
+	}
+	if ipClaim.Status.Address == nil {
+		return "", nil
+	}
+	ipAddress := &ipamv1.IPAddress{}
+	key = client.ObjectKey{Name: ipClaim.Status.Address.Name, Namespace: s.Metal3Cluster.Namespace}
+	if err := s.client.Get(ctx, key, ipAddress); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to get the control plane endpoint address")
+	}
+	return string(ipAddress.Spec.Address), nil
+}
+
+// addressFromPool returns the address allocated from a CAPI IPAM pool, or ""
+// if not allocated yet. It creates the IPAddressClaim if it does not exist.
+func (s *ClusterManager) addressFromPool(ctx context.Context, poolRef corev1.TypedLocalObjectReference) (string, error) {
+	claim := &caipamv1.IPAddressClaim{}
+	key := client.ObjectKey{Name: s.controlPlaneEndpointClaimName(poolRef), Namespace: s.Metal3Cluster.Namespace}
+	if err := s.client.Get(ctx, key, claim); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", errors.Wrap(err, "failed to get the control plane endpoint claim")
+		}
+		claim = &caipamv1.IPAddressClaim{
+			ObjectMeta: s.controlPlaneEndpointClaimMeta(poolRef),
+			Spec: caipamv1.IPAddressClaimSpec{
+				PoolRef: poolRef,
+			},
+		}
+		return "", createObject(ctx, s.client, claim)
+	}
+	if !claim.DeletionTimestamp.IsZero() || claim.Status.AddressRef.Name == "" {
+		return "", nil
+	}
+	address := &caipamv1.IPAddress{}
+	key = client.ObjectKey{Name: claim.Status.AddressRef.Name, Namespace: s.Metal3Cluster.Namespace}
+	if err := s.client.Get(ctx, key, address); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to get the control plane endpoint address")
+	}
+	return address.Spec.Address, nil
+}
+
+// Delete releases the control plane endpoint allocated from the pool, if any,
+// and deletes the provisioning metrics of the cluster.
+func (s *ClusterManager) Delete(ctx context.Context) error {
+	ProvisioningMetrics.DeleteCluster(s.Cluster)
+	poolRef := s.Metal3Cluster.Spec.ControlPlaneEndpointFromPool
+	if poolRef == nil {
+		return nil
+	}
+	var claim client.Object = &caipamv1.IPAddressClaim{}
+	if isMetal3IPPoolRef(*poolRef) {
+		claim = &ipamv1.IPClaim{}
+	}
+	key := client.ObjectKey{Name: s.controlPlaneEndpointClaimName(*poolRef), Namespace: s.Metal3Cluster.Namespace}
+	if err := s.client.Get(ctx, key, claim); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get the control plane endpoint claim")
+	}
+	s.Log.Info("Releasing the control plane endpoint", "claim", claim.GetName())
+	if controllerutil.RemoveFinalizer(claim, infrav1.ClusterFinalizer) {
+		if err := updateObject(ctx, s.client, claim); err != nil {
+			return err
+		}
+	}
+	return deleteObject(ctx, s.client, claim)
+}
+
 // UpdateClusterStatus updates a metal3Cluster object's status.
 func (s *ClusterManager) UpdateClusterStatus() error {
 	// Get APIEndpoints from  metal3Cluster Spec
@@ -153,6 +363,297 @@ func (s *ClusterManager) UpdateClusterStatus() error {
 	return nil
 }
 
+// UpdateMachinesStatus updates the counts of the Metal3Machines of the
+// cluster and the failure domains of the BareMetalHosts in the status of the
+// metal3Cluster.
+func (s *ClusterManager) UpdateMachinesStatus(ctx context.Context) error {
+	m3ms := infrav1.Metal3MachineList{}
+	if err := s.client.List(ctx, &m3ms, client.InNamespace(s.Metal3Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: s.Cluster.Name},
+	); err != nil {
+		return errors.Wrap(err, "failed to list the Metal3Machines of the cluster")
+	}
+	s.Metal3Cluster.Status.ReadyMachines, s.Metal3Cluster.Status.ProvisioningMachines,
+		s.Metal3Cluster.Status.FailedMachines = countMachines(m3ms.Items)
+
+	label := failureDomainLabel(s.Metal3Cluster)
+	failureDomains := clusterv1.FailureDomains{}
+	namespaces := append([]string{s.Metal3Cluster.Namespace}, BMHNamespaces...)
+	for _, namespace := range namespaces {
+		hosts := bmov1alpha1.BareMetalHostList{}
+		if err := s.client.List(ctx, &hosts, client.InNamespace(namespace), client.HasLabels{label}); err != nil {
+			return errors.Wrap(err, "failed to list the BareMetalHosts of the failure domains")
+		}
+		for i := range hosts.Items {
+			if failureDomain := hostFailureDomain(s.Metal3Cluster, &hosts.Items[i]); failureDomain != "" {
+				failureDomains[failureDomain] = clusterv1.FailureDomainSpec{ControlPlane: true}
+			}
+		}
+	}
+	if len(failureDomains) == 0 {
+		failureDomains = nil
+	}
+	s.Metal3Cluster.Status.FailureDomains = failureDomains
+	return nil
+}
+
+// SetPauseAnnotations pauses the BareMetalHosts consumed by the
+// Metal3Machines of the paused cluster, as the Metal3Machines do, so that they
+// are not mutated while the cluster is moved. The BareMetalHosts registered in
+// a host cluster are not moved, and are left to the Metal3Machines.
+func (s *ClusterManager) SetPauseAnnotations(ctx context.Context) error {
+	return s.forEachHost(ctx, func(m3m *infrav1.Metal3Machine, host *bmov1alpha1.BareMetalHost) bool {
+		if _, ok := host.Annotations[bmov1alpha1.PausedAnnotation]; ok {
+			return false
+		}
+		if host.Annotations == nil {
+			host.Annotations = map[string]string{}
+		}
+		if err := setHostStatusAnnotation(host); err != nil {
+			s.Log.Error(err, "Failed to save the status of the BareMetalHost", "host", host.Name)
+			return false
+		}
+		s.Log.Info("Adding PausedAnnotation in BareMetalHost", "host", host.Name)
+		host.Annotations[bmov1alpha1.PausedAnnotation] = PausedAnnotationKey
+		return true
+	})
+}
+
+// RemovePauseAnnotations removes the pause annotation set by CAPM3 from the
+// BareMetalHosts consumed by the Metal3Machines of the resumed cluster. The
+// BareMetalHosts of paused Metal3Machines, or paused by someone else, stay
+// paused.
+func (s *ClusterManager) RemovePauseAnnotations(ctx context.Context) error {
+	return s.forEachHost(ctx, func(m3m *infrav1.Metal3Machine, host *bmov1alpha1.BareMetalHost) bool {
+		if annotations.HasPaused(m3m) || host.Annotations[bmov1alpha1.PausedAnnotation] != PausedAnnotationKey ||
+			getLabel(host.Labels, clusterv1.ClusterNameLabel) != s.Cluster.Name {
+			return false
+		}
+		s.Log.Info("Removing PausedAnnotation from BareMetalHost", "host", host.Name)
+		delete(host.Annotations, bmov1alpha1.PausedAnnotation)
+		return true
+	})
+}
+
+// forEachHost calls mutate with the BareMetalHost consumed by each
+// Metal3Machine of the cluster, and patches the hosts it modified. The hosts
+// registered in a host cluster, gone or detached are skipped.
+func (s *ClusterManager) forEachHost(ctx context.Context,
+	mutate func(*infrav1.Metal3Machine, *bmov1alpha1.BareMetalHost) bool,
+) error {
+	if s.Metal3Cluster.Spec.HostClusterKubeconfigSecret != nil {
+		return nil
+	}
+	m3ms := infrav1.Metal3MachineList{}
+	if err := s.client.List(ctx, &m3ms, client.InNamespace(s.Metal3Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: s.Cluster.Name},
+	); err != nil {
+		return errors.Wrap(err, "failed to list the Metal3Machines of the cluster")
+	}
+	for i := range m3ms.Items {
+		m3m := &m3ms.Items[i]
+		host, err := getHost(ctx, m3m, s.client, s.Log)
+		if err != nil {
+			return err
+		}
+		if host == nil || isHostDetached(host) {
+			continue
+		}
+		helper, err := patch.NewHelper(host, s.client)
+		if err != nil {
+			return errors.Wrap(err, "failed to init patch helper")
+		}
+		if !mutate(m3m, host) {
+			continue
+		}
+		if err := helper.Patch(ctx, host); err != nil {
+			return errors.Wrapf(err, "failed to patch the BareMetalHost %s", host.Name)
+		}
+	}
+	return nil
+}
+
+// ExpireNodeReuseLabels removes the node reuse label of the available
+// BareMetalHosts in the namespace of the metal3Cluster that carry it for
+// longer than their TTL, the nodeReuseGracePeriod of the Metal3MachineTemplate
+// that released them or NodeReuseLabelTTL, unless its owner still needs hosts,
+// e.g. during a rolling upgrade. The hosts labelled without the since
+// annotation, e.g. by older releases, are given it, which starts their TTL. It
+// returns the time after which the next label may expire, zero if none.
+func (s *ClusterManager) ExpireNodeReuseLabels(ctx context.Context) (time.Duration, error) {
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := s.client.List(ctx, &hosts, client.InNamespace(s.Metal3Cluster.Namespace)); err != nil {
+		return 0, errors.Wrap(err, "failed to list the BareMetalHosts")
+	}
+
+	var next time.Duration
+	requeueIn := func(d time.Duration) {
+		if next == 0 || d < next {
+			next = d
+		}
+	}
+	now := time.Now()
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		owner, ok := lookupLabel(host.Labels, nodeReuseLabelName)
+		if !ok || host.Spec.ConsumerRef != nil {
+			continue
+		}
+		ttl := nodeReuseLabelTTLOf(host)
+		if ttl <= 0 {
+			continue
+		}
+		hostPatch := client.MergeFrom(host.DeepCopy())
+		since, err := time.Parse(time.RFC3339, host.Annotations[infrav1.HostNodeReuseSinceAnnotation])
+		if err != nil {
+			if host.Annotations == nil {
+				host.Annotations = map[string]string{}
+			}
+			host.Annotations[infrav1.HostNodeReuseSinceAnnotation] = now.UTC().Format(time.RFC3339)
+			if err := s.client.Patch(ctx, host, hostPatch); err != nil {
+				return 0, errors.Wrapf(err, "failed to annotate BareMetalHost %s", host.Name)
+			}
+			requeueIn(ttl)
+			continue
+		}
+		if age := now.Sub(since); age < ttl {
+			requeueIn(ttl - age)
+			continue
+		}
+		needed, err := s.nodeReuseOwnerNeedsHosts(ctx, owner)
+		if err != nil {
+			return 0, err
+		}
+		if needed {
+			s.Log.Info("Keeping the expired node reuse label, its owner still needs hosts", "host", host.Name, "owner", owner)
+			requeueIn(nodeReuseLabelRecheckAfter)
+			continue
+		}
+
+		deleteLabel(host.Labels, nodeReuseLabelName)
+		deleteNodeReuseAnnotations(host.Annotations)
+		if err := s.client.Patch(ctx, host, hostPatch); err != nil {
+			return 0, errors.Wrapf(err, "failed to remove the node reuse label of BareMetalHost %s", host.Name)
+		}
+		NodeReuseLabelExpirations.Inc()
+		s.Log.Info("Removed the expired node reuse label", "host", host.Name, "owner", owner)
+		if s.Recorder != nil {
+			s.Recorder.Eventf(host, corev1.EventTypeNormal, infrav1.NodeReuseLabelExpiredReason,
+				"node reuse label %s removed after %s, its owner no longer needs the host", owner, ttl,
+			)
+		}
+	}
+	return next, nil
+}
+
+// nodeReuseOwnerNeedsHosts returns whether the owner named by the value of a
+// node reuse label still needs hosts: a node reuse group, a cluster or a set
+// of Machine label values, for the Cluster and Labels node reuse scopes, with
+// a Metal3Machine waiting for a host or being deleted, or a
+// KubeadmControlPlane or MachineDeployment being scaled or rolled out. An
+// owner that no longer exists needs none. The label values are hashed, so any
+// Metal3Machine of the namespace not in a node reuse group is considered for
+// them.
+func (s *ClusterManager) nodeReuseOwnerNeedsHosts(ctx context.Context, value string) (bool, error) {
+	namespace := s.Metal3Cluster.Namespace
+	m3ms := infrav1.Metal3MachineList{}
+	if err := s.client.List(ctx, &m3ms, client.InNamespace(namespace)); err != nil {
+		return false, errors.Wrap(err, "failed to list the Metal3Machines")
+	}
+	// The cluster and label values are prefixed, see nodeReuseLabelValue.
+	clusterScope := strings.HasPrefix(value, "cluster-")
+	labelsScope := strings.HasPrefix(value, "labels-")
+	for _, m3m := range m3ms.Items {
+		switch {
+		case m3m.Spec.NodeReuseGroup == value:
+		case m3m.Spec.NodeReuseGroup != "":
+			continue
+		case clusterScope && nodeReuseClusterValue(m3m.Labels[clusterv1.ClusterNameLabel]) == value:
+		case labelsScope:
+		default:
+			continue
+		}
+		if !m3m.DeletionTimestamp.IsZero() || m3m.Annotations[HostAnnotation] == "" {
+			return true, nil
+		}
+	}
+
+	// The KubeadmControlPlane and MachineDeployment names are prefixed in the
+	// label value, see getKubeadmControlPlaneName and getMachineDeploymentName.
+	switch {
+	case strings.HasPrefix(value, "kcp-"):
+		kcp := &controlplanev1.KubeadmControlPlane{}
+		key := client.ObjectKey{Namespace: namespace, Name: strings.TrimPrefix(value, "kcp-")}
+		if err := s.client.Get(ctx, key, kcp); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, errors.Wrapf(err, "failed to get KubeadmControlPlane %s", key.Name)
+		}
+		return rolloutPending(kcp.Generation, kcp.Status.ObservedGeneration, kcp.Spec.Replicas,
+			kcp.Status.Replicas, kcp.Status.UpdatedReplicas,
+		), nil
+	case strings.HasPrefix(value, "md-"):
+		md := &clusterv1.MachineDeployment{}
+		key := client.ObjectKey{Namespace: namespace, Name: strings.TrimPrefix(value, "md-")}
+		if err := s.client.Get(ctx, key, md); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, errors.Wrapf(err, "failed to get MachineDeployment %s", key.Name)
+		}
+		return rolloutPending(md.Generation, md.Status.ObservedGeneration, md.Spec.Replicas,
+			md.Status.Replicas, md.Status.UpdatedReplicas,
+		), nil
+	}
+	return false, nil
+}
+
+// rolloutPending returns whether a KubeadmControlPlane or MachineDeployment is
+// being scaled or rolled out, or its status does not reflect its spec yet.
+func rolloutPending(generation, observedGeneration int64, desired *int32, replicas, updatedReplicas int32) bool {
+	if observedGeneration < generation {
+		return true
+	}
+	want := int32(1)
+	if desired != nil {
+		want = *desired
+	}
+	return replicas != want || updatedReplicas != replicas
+}
+
+// countMachines returns the number of ready, provisioning and failed
+// Metal3Machines. The Metal3Machines being deleted are not counted.
+func countMachines(m3ms []infrav1.Metal3Machine) (ready, provisioning, failed int32) {
+	for _, m3m := range m3ms {
+		switch {
+		case !m3m.DeletionTimestamp.IsZero():
+		case m3m.Status.FailureReason != nil || m3m.Status.FailureMessage != nil:
+			failed++
+		case m3m.Status.Ready:
+			ready++
+		default:
+			provisioning++
+		}
+	}
+	return ready, provisioning, failed
+}
+
+// failureDomainLabel returns the label of the BareMetalHosts giving their
+// failure domain.
+func failureDomainLabel(metal3Cluster *infrav1.Metal3Cluster) string {
+	if metal3Cluster == nil || metal3Cluster.Spec.FailureDomainLabel == "" {
+		return infrav1.DefaultFailureDomainLabel
+	}
+	return metal3Cluster.Spec.FailureDomainLabel
+}
+
+// hostFailureDomain returns the failure domain of the BareMetalHost, the value
+// of its failureDomainLabel, empty if it has none.
+func hostFailureDomain(metal3Cluster *infrav1.Metal3Cluster, host *bmov1alpha1.BareMetalHost) string {
+	return host.Labels[failureDomainLabel(metal3Cluster)]
+}
+
 // setError sets the FailureMessage and FailureReason fields on the metal3Cluster and logs
 // the message. It assumes the reason is invalid configuration, since that is
 // currently the only relevant Metal3ClusterStatusError choice.
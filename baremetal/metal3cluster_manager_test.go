@@ -18,18 +18,30 @@ package baremetal
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -182,7 +194,7 @@ var _ = Describe("Metal3Cluster manager", func() {
 		func(tc testCaseBMClusterManager) {
 			clusterMgr, err := newBMClusterSetup(tc)
 			Expect(err).NotTo(HaveOccurred())
-			err = clusterMgr.Delete()
+			err = clusterMgr.Delete(context.TODO())
 
 			if tc.ExpectSuccess {
 				Expect(err).NotTo(HaveOccurred())
@@ -197,6 +209,178 @@ var _ = Describe("Metal3Cluster manager", func() {
 		}),
 	)
 
+	It("Deletes the provisioning metrics of the cluster", func() {
+		cluster := newCluster(clusterName)
+		cluster.UID = "deleted-cluster"
+		ProvisioningMetrics.IncProvisioningFailures(cluster)
+		Expect(testutil.CollectAndCount(ProvisioningMetrics, "capm3_baremetalhost_provisioning_failures_total")).To(Equal(1))
+
+		clusterMgr, err := newBMClusterSetup(testCaseBMClusterManager{
+			Cluster:   cluster,
+			BMCluster: newMetal3Cluster(metal3ClusterName, bmcOwnerRef, nil, nil),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(clusterMgr.Delete(context.TODO())).To(Succeed())
+		Expect(testutil.CollectAndCount(ProvisioningMetrics, "capm3_baremetalhost_provisioning_failures_total")).To(Equal(0))
+	})
+
+	type testCaseControlPlaneEndpointFromPool struct {
+		PoolRef            corev1.TypedLocalObjectReference
+		Objects            []client.Object
+		ExpectedHost       string
+		ExpectRequeue      bool
+		ExpectClaimCreated bool
+		ExpectErrorMsg     string
+	}
+
+	m3PoolRef := corev1.TypedLocalObjectReference{
+		APIGroup: pointer.String("ipam.metal3.io"),
+		Kind:     "IPPool",
+		Name:     "vip-pool",
+	}
+	capiPoolRef := corev1.TypedLocalObjectReference{
+		APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
+		Kind:     "InClusterIPPool",
+		Name:     "vip-pool",
+	}
+	claimName := metal3ClusterName + "-vip-pool"
+	m3Claim := func(address string, errorMessage *string) *ipamv1.IPClaim {
+		claim := &ipamv1.IPClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       claimName,
+				Namespace:  namespaceName,
+				Finalizers: []string{infrav1.ClusterFinalizer},
+			},
+			Status: ipamv1.IPClaimStatus{ErrorMessage: errorMessage},
+		}
+		if address != "" {
+			claim.Status.Address = &corev1.ObjectReference{Name: address}
+		}
+		return claim
+	}
+	capiClaim := func(address string) *caipamv1.IPAddressClaim {
+		return &caipamv1.IPAddressClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       claimName,
+				Namespace:  namespaceName,
+				Finalizers: []string{infrav1.ClusterFinalizer},
+			},
+			Spec: caipamv1.IPAddressClaimSpec{PoolRef: capiPoolRef},
+			Status: caipamv1.IPAddressClaimStatus{
+				AddressRef: corev1.LocalObjectReference{Name: address},
+			},
+		}
+	}
+
+	DescribeTable("Test AllocateControlPlaneEndpoint",
+		func(tc testCaseControlPlaneEndpointFromPool) {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(tc.Objects...).Build()
+			spec := bmcSpecAPIEmpty()
+			spec.ControlPlaneEndpoint.Port = 6443
+			spec.ControlPlaneEndpointFromPool = &tc.PoolRef
+			m3c := newMetal3Cluster(metal3ClusterName, bmcOwnerRef, spec, nil)
+			clusterMgr := &ClusterManager{
+				client:        fakeClient,
+				Metal3Cluster: m3c,
+				Cluster:       newCluster(clusterName),
+				Log:           logr.Discard(),
+			}
+
+			Expect(clusterMgr.Create(context.TODO())).To(Succeed())
+			err := clusterMgr.AllocateControlPlaneEndpoint(context.TODO())
+			switch {
+			case tc.ExpectErrorMsg != "":
+				Expect(err).To(MatchError(ContainSubstring(tc.ExpectErrorMsg)))
+			case tc.ExpectRequeue:
+				var reconcileError ReconcileError
+				Expect(errors.As(err, &reconcileError)).To(BeTrue())
+				Expect(reconcileError.IsTransient()).To(BeTrue())
+				Expect(m3c.Status.Ready).To(BeFalse())
+				Expect(conditions.GetReason(m3c, infrav1.BaremetalInfrastructureReadyCondition)).To(Equal(infrav1.WaitingForControlPlaneEndpointReason))
+			default:
+				Expect(err).NotTo(HaveOccurred())
+				Expect(clusterMgr.UpdateClusterStatus()).To(Succeed())
+				Expect(m3c.Status.Ready).To(BeTrue())
+			}
+			Expect(m3c.Spec.ControlPlaneEndpoint.Host).To(Equal(tc.ExpectedHost))
+
+			var claim client.Object = &caipamv1.IPAddressClaim{}
+			if isMetal3IPPoolRef(tc.PoolRef) {
+				claim = &ipamv1.IPClaim{}
+			}
+			err = fakeClient.Get(context.TODO(), client.ObjectKey{Name: claimName, Namespace: namespaceName}, claim)
+			Expect(err).NotTo(HaveOccurred())
+			if tc.ExpectClaimCreated {
+				Expect(claim.GetOwnerReferences()).To(HaveLen(1))
+				Expect(claim.GetOwnerReferences()[0].Name).To(Equal(metal3ClusterName))
+				Expect(claim.GetFinalizers()).To(ConsistOf(infrav1.ClusterFinalizer))
+			}
+
+			// Deleting the Metal3Cluster releases the claim.
+			Expect(clusterMgr.Delete(context.TODO())).To(Succeed())
+			err = fakeClient.Get(context.TODO(), client.ObjectKey{Name: claimName, Namespace: namespaceName}, claim)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		},
+		Entry("Metal3IPPool, claim created", testCaseControlPlaneEndpointFromPool{
+			PoolRef:            m3PoolRef,
+			ExpectRequeue:      true,
+			ExpectClaimCreated: true,
+		}),
+		Entry("Metal3IPPool, address not allocated yet", testCaseControlPlaneEndpointFromPool{
+			PoolRef:       m3PoolRef,
+			Objects:       []client.Object{m3Claim("", nil)},
+			ExpectRequeue: true,
+		}),
+		Entry("Metal3IPPool, address allocated", testCaseControlPlaneEndpointFromPool{
+			PoolRef: m3PoolRef,
+			Objects: []client.Object{
+				m3Claim("vip-pool-192-168-111-10", nil),
+				&ipamv1.IPAddress{
+					ObjectMeta: metav1.ObjectMeta{Name: "vip-pool-192-168-111-10", Namespace: namespaceName},
+					Spec:       ipamv1.IPAddressSpec{Address: "192.168.111.10"},
+				},
+			},
+			ExpectedHost: "192.168.111.10",
+		}),
+		Entry("Metal3IPPool, allocation failed", testCaseControlPlaneEndpointFromPool{
+			PoolRef:        m3PoolRef,
+			Objects:        []client.Object{m3Claim("", pointer.String("pool exhausted"))},
+			ExpectErrorMsg: "pool exhausted",
+		}),
+		Entry("CAPI pool, claim created", testCaseControlPlaneEndpointFromPool{
+			PoolRef:            capiPoolRef,
+			ExpectRequeue:      true,
+			ExpectClaimCreated: true,
+		}),
+		Entry("CAPI pool, address allocated", testCaseControlPlaneEndpointFromPool{
+			PoolRef: capiPoolRef,
+			Objects: []client.Object{
+				capiClaim("vip-address"),
+				&caipamv1.IPAddress{
+					ObjectMeta: metav1.ObjectMeta{Name: "vip-address", Namespace: namespaceName},
+					Spec:       caipamv1.IPAddressSpec{Address: "192.168.111.11"},
+				},
+			},
+			ExpectedHost: "192.168.111.11",
+		}),
+	)
+
+	It("Does not allocate without pool", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+		m3c := newMetal3Cluster(metal3ClusterName, bmcOwnerRef, bmcSpec(), nil)
+		clusterMgr := &ClusterManager{
+			client:        fakeClient,
+			Metal3Cluster: m3c,
+			Cluster:       newCluster(clusterName),
+			Log:           logr.Discard(),
+		}
+		Expect(clusterMgr.AllocateControlPlaneEndpoint(context.TODO())).To(Succeed())
+		Expect(m3c.Spec.ControlPlaneEndpoint.Host).To(Equal("192.168.111.249"))
+		claims := ipamv1.IPClaimList{}
+		Expect(fakeClient.List(context.TODO(), &claims)).To(Succeed())
+		Expect(claims.Items).To(BeEmpty())
+	})
+
 	DescribeTable("Test BMCluster Create",
 		func(tc testCaseBMClusterManager) {
 			clusterMgr, err := newBMClusterSetup(tc)
@@ -376,6 +560,486 @@ var _ = Describe("Metal3Cluster manager", func() {
 		},
 		descendantsTestCases,
 	)
+
+	failureReason := capierrors.UpdateMachineError
+
+	type testCaseCountMachines struct {
+		Machines             []infrav1.Metal3Machine
+		ExpectedReady        int32
+		ExpectedProvisioning int32
+		ExpectedFailed       int32
+	}
+
+	DescribeTable("Test countMachines",
+		func(tc testCaseCountMachines) {
+			ready, provisioning, failed := countMachines(tc.Machines)
+			Expect(ready).To(Equal(tc.ExpectedReady))
+			Expect(provisioning).To(Equal(tc.ExpectedProvisioning))
+			Expect(failed).To(Equal(tc.ExpectedFailed))
+		},
+		Entry("No machines", testCaseCountMachines{}),
+		Entry("Ready, provisioning and failed machines", testCaseCountMachines{
+			Machines: []infrav1.Metal3Machine{
+				{Status: infrav1.Metal3MachineStatus{Ready: true}},
+				{Status: infrav1.Metal3MachineStatus{Ready: true}},
+				{},
+				{Status: infrav1.Metal3MachineStatus{FailureMessage: pointer.String("failed")}},
+			},
+			ExpectedReady:        2,
+			ExpectedProvisioning: 1,
+			ExpectedFailed:       1,
+		}),
+		Entry("Ready machine with a failure counted as failed", testCaseCountMachines{
+			Machines: []infrav1.Metal3Machine{
+				{Status: infrav1.Metal3MachineStatus{Ready: true, FailureReason: &failureReason}},
+			},
+			ExpectedFailed: 1,
+		}),
+		Entry("Machine being deleted not counted", testCaseCountMachines{
+			Machines: []infrav1.Metal3Machine{
+				{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{Time: time.Now()}}},
+			},
+		}),
+	)
+
+	type testCaseUpdateMachinesStatus struct {
+		FailureDomainLabel     string
+		ExpectedFailureDomains clusterv1.FailureDomains
+	}
+
+	DescribeTable("Test UpdateMachinesStatus",
+		func(tc testCaseUpdateMachinesStatus) {
+			newHost := func(name, namespace string, labels map[string]string) client.Object {
+				return &bmov1alpha1.BareMetalHost{ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: namespace, Labels: labels,
+				}}
+			}
+			newM3M := func(name, cluster string, status infrav1.Metal3MachineStatus) client.Object {
+				return &infrav1.Metal3Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name, Namespace: namespaceName,
+						Labels: map[string]string{clusterv1.ClusterNameLabel: cluster},
+					},
+					Status: status,
+				}
+			}
+			objects := []client.Object{
+				newM3M("ready", clusterName, infrav1.Metal3MachineStatus{Ready: true}),
+				newM3M("provisioning", clusterName, infrav1.Metal3MachineStatus{}),
+				newM3M("other", "other", infrav1.Metal3MachineStatus{Ready: true}),
+				newHost("host-0", namespaceName, map[string]string{infrav1.DefaultFailureDomainLabel: "rack-a"}),
+				newHost("host-1", namespaceName, map[string]string{infrav1.DefaultFailureDomainLabel: "rack-a"}),
+				newHost("host-2", "bmh-ns", map[string]string{infrav1.DefaultFailureDomainLabel: "rack-b"}),
+				newHost("host-3", "other", map[string]string{infrav1.DefaultFailureDomainLabel: "rack-c"}),
+				newHost("host-4", namespaceName, map[string]string{"example.com/rack": "rack-d"}),
+				newHost("host-5", namespaceName, nil),
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			DeferCleanup(func(namespaces []string) { BMHNamespaces = namespaces }, BMHNamespaces)
+			BMHNamespaces = []string{"bmh-ns"}
+
+			metal3Cluster := newMetal3Cluster(metal3ClusterName, nil,
+				&infrav1.Metal3ClusterSpec{FailureDomainLabel: tc.FailureDomainLabel}, nil,
+			)
+			clusterMgr := &ClusterManager{
+				client:        fakeClient,
+				Metal3Cluster: metal3Cluster,
+				Cluster:       newCluster(clusterName),
+				Log:           logr.Discard(),
+			}
+
+			Expect(clusterMgr.UpdateMachinesStatus(context.TODO())).To(Succeed())
+			Expect(metal3Cluster.Status.ReadyMachines).To(Equal(int32(1)))
+			Expect(metal3Cluster.Status.ProvisioningMachines).To(Equal(int32(1)))
+			Expect(metal3Cluster.Status.FailedMachines).To(Equal(int32(0)))
+			Expect(metal3Cluster.Status.FailureDomains).To(Equal(tc.ExpectedFailureDomains))
+		},
+		Entry("Failure domains from the default label", testCaseUpdateMachinesStatus{
+			ExpectedFailureDomains: clusterv1.FailureDomains{
+				"rack-a": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"rack-b": clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		}),
+		Entry("Failure domains from the label of the Metal3Cluster", testCaseUpdateMachinesStatus{
+			FailureDomainLabel: "example.com/rack",
+			ExpectedFailureDomains: clusterv1.FailureDomains{
+				"rack-d": clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		}),
+		Entry("No failure domains", testCaseUpdateMachinesStatus{
+			FailureDomainLabel: "example.com/zone",
+		}),
+	)
+
+	type testCaseExpireNodeReuseLabels struct {
+		TTL             time.Duration
+		GracePeriod     string
+		Label           string
+		LabelValue      string
+		Age             *time.Duration
+		Consumed        bool
+		Owners          []client.Object
+		ExpectExpired   bool
+		ExpectedRequeue time.Duration
+	}
+
+	age := func(d time.Duration) *time.Duration { return &d }
+	replicas := func(desired, current, updated int32) (*int32, int32, int32) {
+		return pointer.Int32(desired), current, updated
+	}
+	newKCP := func(desired *int32, current, updated int32) client.Object {
+		kcp := &controlplanev1.KubeadmControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "cp", Namespace: namespaceName},
+		}
+		kcp.Spec.Replicas = desired
+		kcp.Status.Replicas = current
+		kcp.Status.UpdatedReplicas = updated
+		return kcp
+	}
+	newMD := func(desired *int32, current, updated int32) client.Object {
+		md := &clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: namespaceName},
+		}
+		md.Spec.Replicas = desired
+		md.Status.Replicas = current
+		md.Status.UpdatedReplicas = updated
+		return md
+	}
+	newGroupM3M := func(name string, associated bool) client.Object {
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName},
+			Spec:       infrav1.Metal3MachineSpec{NodeReuseGroup: "group"},
+		}
+		if associated {
+			m3m.Annotations = map[string]string{HostAnnotation: namespaceName + "/host"}
+		}
+		return m3m
+	}
+	newClusterM3M := func(name string, associated bool) client.Object {
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: namespaceName,
+				Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			},
+		}
+		if associated {
+			m3m.Annotations = map[string]string{HostAnnotation: namespaceName + "/host"}
+		}
+		return m3m
+	}
+
+	DescribeTable("Test ExpireNodeReuseLabels",
+		func(tc testCaseExpireNodeReuseLabels) {
+			DeferCleanup(func(ttl time.Duration) { NodeReuseLabelTTL = ttl }, NodeReuseLabelTTL)
+			NodeReuseLabelTTL = tc.TTL
+			DeferCleanup(func() { LegacyLabelKeys = nil })
+			LegacyLabelKeys = testLegacyLabelKeys
+
+			label := tc.Label
+			if label == "" {
+				label = nodeReuseLabelName
+			}
+			host := &bmov1alpha1.BareMetalHost{ObjectMeta: metav1.ObjectMeta{
+				Name: "host", Namespace: namespaceName,
+				Labels:      map[string]string{label: tc.LabelValue, "rack": "a"},
+				Annotations: map[string]string{},
+			}}
+			if tc.GracePeriod != "" {
+				host.Annotations[infrav1.HostNodeReuseGracePeriodAnnotation] = tc.GracePeriod
+			}
+			if tc.Age != nil {
+				host.Annotations[infrav1.HostNodeReuseSinceAnnotation] = time.Now().Add(-*tc.Age).UTC().Format(time.RFC3339)
+			}
+			if tc.Consumed {
+				host.Spec.ConsumerRef = &corev1.ObjectReference{Name: "other"}
+			}
+			scheme := setupScheme()
+			Expect(controlplanev1.AddToScheme(scheme)).To(Succeed())
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(append(tc.Owners, host)...).Build()
+			recorder := record.NewFakeRecorder(1)
+			clusterMgr := &ClusterManager{
+				client:        fakeClient,
+				Metal3Cluster: newMetal3Cluster(metal3ClusterName, nil, nil, nil),
+				Cluster:       newCluster(clusterName),
+				Log:           logr.Discard(),
+				Recorder:      recorder,
+			}
+			expirations := testutil.ToFloat64(NodeReuseLabelExpirations)
+
+			requeue, err := clusterMgr.ExpireNodeReuseLabels(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			// The ages are stored with a precision of a second.
+			Expect(requeue).To(BeNumerically("~", tc.ExpectedRequeue, time.Second))
+
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), host)).To(Succeed())
+			Expect(host.Labels).To(HaveKeyWithValue("rack", "a"))
+			if tc.ExpectExpired {
+				Expect(host.Labels).NotTo(HaveKey(label))
+				Expect(host.Annotations).NotTo(HaveKey(infrav1.HostNodeReuseSinceAnnotation))
+				Expect(host.Annotations).NotTo(HaveKey(infrav1.HostNodeReuseGracePeriodAnnotation))
+				Expect(testutil.ToFloat64(NodeReuseLabelExpirations)).To(Equal(expirations + 1))
+				Expect(recorder.Events).To(Receive(ContainSubstring(infrav1.NodeReuseLabelExpiredReason)))
+				return
+			}
+			Expect(host.Labels).To(HaveKeyWithValue(label, tc.LabelValue))
+			Expect(testutil.ToFloat64(NodeReuseLabelExpirations)).To(Equal(expirations))
+			Expect(recorder.Events).NotTo(Receive())
+			if (tc.TTL != 0 || tc.GracePeriod != "") && !tc.Consumed {
+				Expect(host.Annotations).To(HaveKey(infrav1.HostNodeReuseSinceAnnotation))
+			}
+		},
+		Entry("TTL disabled", testCaseExpireNodeReuseLabels{
+			LabelValue: "kcp-cp",
+			Age:        age(time.Hour * 24 * 365),
+		}),
+		Entry("Label older than the grace period of its template, TTL disabled", testCaseExpireNodeReuseLabels{
+			GracePeriod:   "1h0m0s",
+			LabelValue:    "kcp-cp",
+			Age:           age(time.Hour + time.Minute),
+			ExpectExpired: true,
+		}),
+		Entry("Label younger than the grace period of its template", testCaseExpireNodeReuseLabels{
+			TTL:             time.Minute,
+			GracePeriod:     "1h0m0s",
+			LabelValue:      "kcp-cp",
+			Age:             age(time.Hour - time.Minute),
+			ExpectedRequeue: time.Minute,
+		}),
+		Entry("Label of a template without grace period, TTL disabled", testCaseExpireNodeReuseLabels{
+			GracePeriod: "0s",
+			LabelValue:  "kcp-cp",
+			Age:         age(time.Hour * 24 * 365),
+		}),
+		Entry("Label younger than the TTL", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "kcp-cp",
+			Age:             age(time.Hour - time.Minute),
+			ExpectedRequeue: time.Minute,
+		}),
+		Entry("Label older than the TTL, owner gone", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "kcp-cp",
+			Age:           age(time.Hour + time.Minute),
+			ExpectExpired: true,
+		}),
+		Entry("Legacy label older than the TTL, owner gone", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			Label:         testLegacyNodeReuseLabel,
+			LabelValue:    "md-workers",
+			Age:           age(time.Hour + time.Minute),
+			ExpectExpired: true,
+		}),
+		Entry("Label without since annotation", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "kcp-cp",
+			ExpectedRequeue: time.Hour,
+		}),
+		Entry("Label of a consumed host", testCaseExpireNodeReuseLabels{
+			TTL:        time.Hour,
+			LabelValue: "kcp-cp",
+			Age:        age(time.Hour * 2),
+			Consumed:   true,
+		}),
+		Entry("Label older than the TTL, KubeadmControlPlane rolling out", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "kcp-cp",
+			Age:             age(time.Hour * 2),
+			Owners:          []client.Object{newKCP(replicas(3, 4, 1))},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, KubeadmControlPlane stable", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "kcp-cp",
+			Age:           age(time.Hour * 2),
+			Owners:        []client.Object{newKCP(replicas(3, 3, 3))},
+			ExpectExpired: true,
+		}),
+		Entry("Label older than the TTL, MachineDeployment scaling up", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "md-workers",
+			Age:             age(time.Hour * 2),
+			Owners:          []client.Object{newMD(replicas(3, 2, 2))},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, MachineDeployment stable", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "md-workers",
+			Age:           age(time.Hour * 2),
+			Owners:        []client.Object{newMD(replicas(2, 2, 2))},
+			ExpectExpired: true,
+		}),
+		Entry("Label older than the TTL, node reuse group waiting for a host", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "group",
+			Age:             age(time.Hour * 2),
+			Owners:          []client.Object{newGroupM3M("m3m-0", true), newGroupM3M("m3m-1", false)},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, node reuse group complete", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "group",
+			Age:           age(time.Hour * 2),
+			Owners:        []client.Object{newGroupM3M("m3m-0", true)},
+			ExpectExpired: true,
+		}),
+		Entry("Label older than the TTL, cluster scope waiting for a host", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "cluster-" + clusterName,
+			Age:             age(time.Hour * 2),
+			Owners:          []client.Object{newClusterM3M("m3m-0", true), newClusterM3M("m3m-1", false)},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, cluster scope with a truncated cluster name", testCaseExpireNodeReuseLabels{
+			TTL:        time.Hour,
+			LabelValue: nodeReuseClusterValue(clusterName + strings.Repeat("-long", 12)),
+			Age:        age(time.Hour * 2),
+			Owners: []client.Object{func() client.Object {
+				m3m := newClusterM3M("m3m-0", false)
+				m3m.SetLabels(map[string]string{clusterv1.ClusterNameLabel: clusterName + strings.Repeat("-long", 12)})
+				return m3m
+			}()},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, cluster scope of another cluster", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "cluster-other",
+			Age:           age(time.Hour * 2),
+			Owners:        []client.Object{newClusterM3M("m3m-0", false)},
+			ExpectExpired: true,
+		}),
+		Entry("Label older than the TTL, labels scope waiting for a host", testCaseExpireNodeReuseLabels{
+			TTL:             time.Hour,
+			LabelValue:      "labels-0a1b2c3d",
+			Age:             age(time.Hour * 2),
+			Owners:          []client.Object{newClusterM3M("m3m-0", false)},
+			ExpectedRequeue: nodeReuseLabelRecheckAfter,
+		}),
+		Entry("Label older than the TTL, labels scope ignoring node reuse groups", testCaseExpireNodeReuseLabels{
+			TTL:           time.Hour,
+			LabelValue:    "labels-0a1b2c3d",
+			Age:           age(time.Hour * 2),
+			Owners:        []client.Object{newGroupM3M("m3m-0", false)},
+			ExpectExpired: true,
+		}),
+	)
+
+	type testCasePauseAnnotations struct {
+		Pause              bool
+		HostAnnotations    map[string]string
+		HostLabels         map[string]string
+		M3MPaused          bool
+		HostCluster        bool
+		NoHost             bool
+		ExpectedPauseValue string
+		ExpectPaused       bool
+	}
+
+	DescribeTable("Test SetPauseAnnotations and RemovePauseAnnotations",
+		func(tc testCasePauseAnnotations) {
+			m3m := &infrav1.Metal3Machine{ObjectMeta: metav1.ObjectMeta{
+				Name: "m3m", Namespace: namespaceName,
+				Labels:      map[string]string{clusterv1.ClusterNameLabel: clusterName},
+				Annotations: map[string]string{HostAnnotation: namespaceName + "/host"},
+			}}
+			if tc.M3MPaused {
+				m3m.Annotations[clusterv1.PausedAnnotation] = "true"
+			}
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "host", Namespace: namespaceName,
+					Labels:      tc.HostLabels,
+					Annotations: tc.HostAnnotations,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateProvisioned},
+				},
+			}
+			objects := []client.Object{m3m}
+			if !tc.NoHost {
+				objects = append(objects, host)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			m3c := newMetal3Cluster(metal3ClusterName, nil, nil, nil)
+			if tc.HostCluster {
+				m3c.Spec.HostClusterKubeconfigSecret = &corev1.LocalObjectReference{Name: "hardware"}
+			}
+			clusterMgr := &ClusterManager{
+				client:        fakeClient,
+				Metal3Cluster: m3c,
+				Cluster:       newCluster(clusterName),
+				Log:           logr.Discard(),
+			}
+
+			if tc.Pause {
+				Expect(clusterMgr.SetPauseAnnotations(context.TODO())).To(Succeed())
+			} else {
+				Expect(clusterMgr.RemovePauseAnnotations(context.TODO())).To(Succeed())
+			}
+			if tc.NoHost {
+				return
+			}
+
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), host)).To(Succeed())
+			if !tc.ExpectPaused {
+				Expect(host.Annotations).NotTo(HaveKey(bmov1alpha1.PausedAnnotation))
+				return
+			}
+			Expect(host.Annotations).To(HaveKeyWithValue(bmov1alpha1.PausedAnnotation, tc.ExpectedPauseValue))
+			if tc.Pause && tc.ExpectedPauseValue == PausedAnnotationKey {
+				Expect(host.Annotations).To(HaveKey(bmov1alpha1.StatusAnnotation))
+			}
+		},
+		Entry("Pauses the host", testCasePauseAnnotations{
+			Pause:              true,
+			ExpectedPauseValue: PausedAnnotationKey,
+			ExpectPaused:       true,
+		}),
+		Entry("Keeps the pause of the user", testCasePauseAnnotations{
+			Pause:              true,
+			HostAnnotations:    map[string]string{bmov1alpha1.PausedAnnotation: ""},
+			ExpectedPauseValue: "",
+			ExpectPaused:       true,
+		}),
+		Entry("Does not pause the host of a host cluster", testCasePauseAnnotations{
+			Pause:       true,
+			HostCluster: true,
+		}),
+		Entry("Does not pause a detached host", testCasePauseAnnotations{
+			Pause:           true,
+			HostAnnotations: map[string]string{bmov1alpha1.DetachedAnnotation: ""},
+		}),
+		Entry("Ignores a missing host", testCasePauseAnnotations{
+			Pause:  true,
+			NoHost: true,
+		}),
+		Entry("Resumes the host", testCasePauseAnnotations{
+			HostAnnotations: map[string]string{bmov1alpha1.PausedAnnotation: PausedAnnotationKey},
+			HostLabels:      map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		}),
+		Entry("Does not resume the host paused by the user", testCasePauseAnnotations{
+			HostAnnotations:    map[string]string{bmov1alpha1.PausedAnnotation: ""},
+			HostLabels:         map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			ExpectedPauseValue: "",
+			ExpectPaused:       true,
+		}),
+		Entry("Does not resume the host of a paused Metal3Machine", testCasePauseAnnotations{
+			HostAnnotations:    map[string]string{bmov1alpha1.PausedAnnotation: PausedAnnotationKey},
+			HostLabels:         map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			M3MPaused:          true,
+			ExpectedPauseValue: PausedAnnotationKey,
+			ExpectPaused:       true,
+		}),
+		Entry("Does not resume the host of another cluster", testCasePauseAnnotations{
+			HostAnnotations:    map[string]string{bmov1alpha1.PausedAnnotation: PausedAnnotationKey},
+			HostLabels:         map[string]string{clusterv1.ClusterNameLabel: "other"},
+			ExpectedPauseValue: PausedAnnotationKey,
+			ExpectPaused:       true,
+		}),
+		Entry("Ignores a missing host on resume", testCasePauseAnnotations{
+			NoHost: true,
+		}),
+	)
 })
 
 func newBMClusterSetup(tc testCaseBMClusterManager) (*ClusterManager, error) {
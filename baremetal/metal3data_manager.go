@@ -21,8 +21,11 @@ import (
 	"fmt"
 
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/go-logr/logr"
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -34,10 +37,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/yaml"
@@ -49,6 +54,12 @@ const (
 	capimachine   = "machine"
 	DataLabelName = "infrastructure.cluster.x-k8s.io/data-name"
 	PoolLabelName = "infrastructure.cluster.x-k8s.io/pool-name"
+	// ipClaimRequeueAfter is the requeue delay of a Metal3Data after creating
+	// IP claims. The claims are watched, it only covers a missed event.
+	ipClaimRequeueAfter = time.Second * 5
+	// defaultRouteMetricStep is the difference between the metrics of the
+	// default routes of links of consecutive defaultRoutePriority.
+	defaultRouteMetricStep = 100
 )
 
 var (
@@ -61,6 +72,8 @@ type DataManagerInterface interface {
 	UnsetFinalizer()
 	Reconcile(ctx context.Context) error
 	ReleaseLeases(ctx context.Context) error
+	WaitForHostRelease(ctx context.Context) error
+	ReleaseSecrets(ctx context.Context) error
 }
 
 // DataManager is responsible for performing machine reconciliation.
@@ -120,22 +133,86 @@ func (m *DataManager) Reconcile(ctx context.Context) error {
 		m.setError(ctx, errors.Cause(err).Error())
 		return err
 	}
+	// The size of the secrets is only known once they are rendered.
+	if m.Data.Status.Ready {
+		conditions.MarkTrue(m.Data, infrav1.RenderedDataSizeValidCondition)
+	} else {
+		conditions.Delete(m.Data, infrav1.RenderedDataSizeValidCondition)
+	}
 
 	return nil
 }
 
+// writeRenderedSecret creates the secret rendered for the Metal3Data, or
+// updates it when it is re-rendered. A secret exceeding the size limit of the
+// secrets is not written, and a secret rejected as too large by the API server
+// is not retried: the RenderedDataSizeValidCondition is set to false and a
+// terminal error is returned, as the same rendering would fail again.
+func (m *DataManager) writeRenderedSecret(ctx context.Context, secret *corev1.Secret, create bool) error {
+	size := 0
+	for key, value := range secret.Data {
+		size += len(key) + len(value)
+	}
+	if size > corev1.MaxSecretSize {
+		return m.setRenderedDataTooLarge(infrav1.SecretSizeLimitExceededReason, fmt.Sprintf(
+			"secret %s of %d bytes exceeds the limit of %d bytes", secret.Name, size, corev1.MaxSecretSize,
+		))
+	}
+
+	var err error
+	if create {
+		err = createObject(ctx, m.client, secret)
+	} else {
+		err = updateObject(ctx, m.client, secret)
+	}
+	if isTooLargeError(err) {
+		return m.setRenderedDataTooLarge(infrav1.SecretRejectedReason, fmt.Sprintf(
+			"secret %s of %d bytes rejected by the API server: %s", secret.Name, size, err,
+		))
+	}
+	return err
+}
+
+// setRenderedDataTooLarge sets the RenderedDataSizeValidCondition to false and
+// returns the matching terminal error.
+func (m *DataManager) setRenderedDataTooLarge(reason, message string) error {
+	m.Log.Info("Rendered data is too large", "reason", reason, "message", message)
+	conditions.MarkFalse(m.Data, infrav1.RenderedDataSizeValidCondition, reason,
+		clusterv1.ConditionSeverityError, "%s", message)
+	return WithTerminalError(errors.New(message))
+}
+
+// isTooLargeError returns true if the API server rejected an object as too
+// large, either for the request size or for the size of a field.
+func isTooLargeError(err error) bool {
+	return apierrors.IsRequestEntityTooLargeError(err) ||
+		(apierrors.IsInvalid(err) && apierrors.HasStatusCause(err, metav1.CauseType(field.ErrorTypeTooLong)))
+}
+
+// templateRef returns the reference to the Metal3DataTemplate of the
+// Metal3Data, in the namespace of the Metal3Data if unset. The spec is not
+// modified, the reference can not be changed once the Metal3Data is created.
+func (m *DataManager) templateRef() *corev1.ObjectReference {
+	templateRef := m.Data.Spec.Template.DeepCopy()
+	if templateRef.Namespace == "" {
+		templateRef.Namespace = m.Data.Namespace
+	}
+	return templateRef
+}
+
 // CreateSecrets creates the secret if they do not exist.
+// The existing secrets are re-rendered in place when the Metal3DataTemplate
+// changed and has rerenderOnTemplateChange set.
 func (m *DataManager) createSecrets(ctx context.Context) error {
+	defer LogDuration(m.Log, "secret rendering", time.Now())
 	var metaDataErr, networkDataErr error
+	var metaDataSecret, networkDataSecret corev1.Secret
 
 	if m.Data.Spec.Template.Name == "" {
 		return nil
 	}
-	if m.Data.Spec.Template.Namespace == "" {
-		m.Data.Spec.Template.Namespace = m.Data.Namespace
-	}
 	// Fetch the Metal3DataTemplate object to get the templates
-	m3dt, err := fetchM3DataTemplate(ctx, &m.Data.Spec.Template, m.client,
+	m3dt, err := fetchM3DataTemplate(ctx, m.templateRef(), m.client,
 		m.Log, m.Data.Labels[clusterv1.ClusterNameLabel],
 	)
 	if err != nil {
@@ -145,6 +222,8 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 		return nil
 	}
 	m.Log.Info("Fetched Metal3DataTemplate")
+	rerender := m3dt.Spec.RerenderOnTemplateChange &&
+		m.Data.Status.RenderedTemplateGeneration != m3dt.Generation
 
 	// Fetch the Metal3Machine, to get the related info
 	m3m, err := m.getM3Machine(ctx, m3dt)
@@ -156,27 +235,54 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 	}
 	m.Log.Info("Fetched Metal3Machine")
 
+	// Fetch the BMH associated with the M3M
+	bmh, err := getHost(ctx, m3m, m.client, m.Log)
+	if err != nil {
+		return err
+	}
+	if bmh != nil {
+		m.Data.Status.HostRef = &corev1.ObjectReference{
+			APIVersion: bmov1alpha1.GroupVersion.String(),
+			Kind:       "BareMetalHost",
+			Name:       bmh.Name,
+			Namespace:  bmh.Namespace,
+			UID:        bmh.UID,
+		}
+	}
+	if err := m.setHostLabel(ctx, *m3dt, bmh); err != nil {
+		return err
+	}
+
 	// If the MetaData is given as part of Metal3DataTemplate
 	if m3dt.Spec.MetaData != nil {
 		m.Log.Info("Metadata is part of Metal3DataTemplate")
 		// If the secret name is unset, set it
 		if m.Data.Spec.MetaData == nil || m.Data.Spec.MetaData.Name == "" {
+			name, err := m.renderedSecretName(ctx, m3dt.Spec.ClusterName, m3m.Name, "metadata")
+			if err != nil {
+				return err
+			}
 			m.Data.Spec.MetaData = &corev1.SecretReference{
-				Name:      m3m.Name + "-metadata",
+				Name:      name,
 				Namespace: m.Data.Namespace,
 			}
 		}
 
 		// Try to fetch the secret. If it exists, we do not modify it, to be able
-		// to reprovision a node in the exact same state.
+		// to reprovision a node in the exact same state, unless it is re-rendered.
 		m.Log.Info("Checking if secret exists", "secret", m.Data.Spec.MetaData.Name)
-		_, metaDataErr = checkSecretExists(ctx, m.client, m.Data.Spec.MetaData.Name,
+		metaDataSecret, metaDataErr = checkSecretExists(ctx, m.client, m.Data.Spec.MetaData.Name,
 			m.Data.Namespace,
 		)
 
 		if metaDataErr != nil && !apierrors.IsNotFound(metaDataErr) {
 			return metaDataErr
 		}
+		if metaDataErr == nil {
+			if err := m.adoptSecret(ctx, &metaDataSecret); err != nil {
+				return err
+			}
+		}
 		if apierrors.IsNotFound(metaDataErr) {
 			m.Log.Info("MetaData secret creation needed", "secret", m.Data.Spec.MetaData.Name)
 		}
@@ -187,28 +293,37 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 		m.Log.Info("NetworkData is part of Metal3DataTemplate")
 		// If the secret name is unset, set it
 		if m.Data.Spec.NetworkData == nil || m.Data.Spec.NetworkData.Name == "" {
+			name, err := m.renderedSecretName(ctx, m3dt.Spec.ClusterName, m3m.Name, "networkdata")
+			if err != nil {
+				return err
+			}
 			m.Data.Spec.NetworkData = &corev1.SecretReference{
-				Name:      m3m.Name + "-networkdata",
+				Name:      name,
 				Namespace: m.Data.Namespace,
 			}
 		}
 
 		// Try to fetch the secret. If it exists, we do not modify it, to be able
-		// to reprovision a node in the exact same state.
+		// to reprovision a node in the exact same state, unless it is re-rendered.
 		m.Log.Info("Checking if secret exists", "secret", m.Data.Spec.NetworkData.Name)
-		_, networkDataErr = checkSecretExists(ctx, m.client, m.Data.Spec.NetworkData.Name,
+		networkDataSecret, networkDataErr = checkSecretExists(ctx, m.client, m.Data.Spec.NetworkData.Name,
 			m.Data.Namespace,
 		)
 		if networkDataErr != nil && !apierrors.IsNotFound(networkDataErr) {
 			return networkDataErr
 		}
+		if networkDataErr == nil {
+			if err := m.adoptSecret(ctx, &networkDataSecret); err != nil {
+				return err
+			}
+		}
 		if apierrors.IsNotFound(networkDataErr) {
 			m.Log.Info("NetworkData secret creation needed", "secret", m.Data.Spec.NetworkData.Name)
 		}
 	}
 
 	// No secret needs creation
-	if metaDataErr == nil && networkDataErr == nil {
+	if metaDataErr == nil && networkDataErr == nil && !rerender {
 		m.Log.Info("Metal3Data Reconciled")
 		m.Data.Status.Ready = true
 		return nil
@@ -227,11 +342,6 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 	}
 	m.Log.Info("Fetched Machine")
 
-	// Fetch the BMH associated with the M3M
-	bmh, err := getHost(ctx, m3m, m.client, m.Log)
-	if err != nil {
-		return err
-	}
 	if bmh == nil {
 		errMessage := "Waiting for BareMetalHost to become available"
 		m.Log.Info(errMessage)
@@ -241,12 +351,21 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 
 	// Fetch all the Metal3IPPools and create Metal3IPClaims as needed. Check if the
 	// IP address has been allocated, if so, fetch the address, gateway and prefix.
-	poolAddresses, err := m.getAddressesFromPool(ctx, *m3dt)
+	// The existing claims are reused, the addresses do not change on re-render.
+	poolAddresses, err := m.getAddressesFromPool(ctx, *m3dt, bmh)
 	if err != nil {
 		return err
 	}
 
-	// Create the owner Ref for the secret
+	// The secrets belong to the same cluster and watch-filter shard as the Metal3Data
+	secretLabels := inheritWatchLabel(map[string]string{
+		clusterv1.ClusterNameLabel: m3dt.Labels[clusterv1.ClusterNameLabel],
+		infrav1.BareMetalHostLabel: bmh.Name,
+		infrav1.DataNameLabel:      m.Data.Name,
+	}, m.Data.Labels, m3dt.Labels)
+
+	// Create the owner Refs for the secret, the Metal3Data controls it and the
+	// Metal3Machine consuming the BareMetalHost uses it.
 	ownerRefs := []metav1.OwnerReference{
 		{
 			Controller: pointer.Bool(true),
@@ -255,6 +374,12 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 			Name:       m.Data.Name,
 			UID:        m.Data.UID,
 		},
+		{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       "Metal3Machine",
+			Name:       m3m.Name,
+			UID:        m3m.UID,
+		},
 	}
 
 	// The MetaData secret must be created
@@ -264,10 +389,23 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		if err := createSecret(ctx, m.client, m.Data.Spec.MetaData.Name,
-			m.Data.Namespace, m3dt.Labels[clusterv1.ClusterNameLabel],
+		// The secret was checked not to exist above.
+		secret := newMetal3Secret(m.Data.Spec.MetaData.Name,
+			m.Data.Namespace, secretLabels,
 			ownerRefs, map[string][]byte{"metaData": metadata},
-		); err != nil {
+		)
+		secret.Finalizers = []string{infrav1.DataFinalizer}
+		if err := m.writeRenderedSecret(ctx, secret, true); err != nil {
+			return err
+		}
+	} else if rerender && m3dt.Spec.MetaData != nil {
+		m.Log.Info("Re-rendering Metadata secret", "generation", m3dt.Generation)
+		metadata, err := renderMetaData(m.Data, m3dt, m3m, capiMachine, bmh, poolAddresses)
+		if err != nil {
+			return err
+		}
+		metaDataSecret.Data = map[string][]byte{"metaData": metadata}
+		if err := m.writeRenderedSecret(ctx, &metaDataSecret, false); err != nil {
 			return err
 		}
 	}
@@ -279,29 +417,116 @@ func (m *DataManager) createSecrets(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		if err := createSecret(ctx, m.client, m.Data.Spec.NetworkData.Name,
-			m.Data.Namespace, m3dt.Labels[clusterv1.ClusterNameLabel],
-			ownerRefs, map[string][]byte{"networkData": networkData},
-		); err != nil {
+		// The secret was checked not to exist above.
+		secret := newMetal3Secret(m.Data.Spec.NetworkData.Name,
+			m.Data.Namespace, secretLabels,
+			ownerRefs, networkDataSecretData(m3dt, networkData),
+		)
+		secret.Finalizers = []string{infrav1.DataFinalizer}
+		if err := m.writeRenderedSecret(ctx, secret, true); err != nil {
+			return err
+		}
+	} else if rerender && m3dt.Spec.NetworkData != nil {
+		m.Log.Info("Re-rendering Networkdata secret", "generation", m3dt.Generation)
+		networkData, err := renderNetworkData(m3dt, bmh, poolAddresses)
+		if err != nil {
+			return err
+		}
+		networkDataSecret.Data = networkDataSecretData(m3dt, networkData)
+		if err := m.writeRenderedSecret(ctx, &networkDataSecret, false); err != nil {
 			return err
 		}
 	}
 
 	m.Log.Info("Metal3Data reconciled")
+	m.Data.Status.RenderedTemplateGeneration = m3dt.Generation
 	m.Data.Status.Ready = true
 	return nil
 }
 
+// setHostLabel sets the BareMetalHostLabel to the name of the host of the
+// Metal3Machine on the Metal3Data and on the objects rendered for it, or
+// removes it if the machine has no host. The Metal3Data is persisted with the
+// other changes by the controller patch, the other objects are only patched
+// when their label changes.
+func (m *DataManager) setHostLabel(ctx context.Context, m3dt infrav1.Metal3DataTemplate,
+	bmh *bmov1alpha1.BareMetalHost,
+) error {
+	hostName := ""
+	if bmh != nil {
+		hostName = bmh.Name
+	}
+	if m.Data.Labels == nil {
+		m.Data.Labels = map[string]string{}
+	}
+	setHostLabel(m.Data.Labels, hostName)
+
+	objects := []client.Object{
+		&infrav1.Metal3DataClaim{ObjectMeta: metav1.ObjectMeta{Name: m.Data.Spec.Claim.Name}},
+	}
+	if m.Data.Spec.MetaData != nil && m.Data.Spec.MetaData.Name != "" {
+		objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: m.Data.Spec.MetaData.Name}})
+	}
+	if m.Data.Spec.NetworkData != nil && m.Data.Spec.NetworkData.Name != "" {
+		objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: m.Data.Spec.NetworkData.Name}})
+	}
+	poolRefs, err := getReferencedPools(m3dt)
+	if err != nil {
+		return err
+	}
+	for pool, ref := range poolRefs {
+		if !isMetal3IPPoolRef(ref) {
+			objects = append(objects, &caipamv1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{Name: m.Data.Name + "-" + pool}})
+			continue
+		}
+		objects = append(objects, &ipamv1.IPClaim{ObjectMeta: metav1.ObjectMeta{Name: m.Data.Name + "-" + pool}})
+		if EnableBMHNameBasedPreallocation && bmh != nil {
+			objects = append(objects, &ipamv1.IPClaim{ObjectMeta: metav1.ObjectMeta{Name: bmh.Name + "-" + pool}})
+		}
+	}
+
+	for _, obj := range objects {
+		key := client.ObjectKey{Name: obj.GetName(), Namespace: m.Data.Namespace}
+		if err := m.client.Get(ctx, key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if obj.GetLabels()[infrav1.BareMetalHostLabel] == hostName {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		setHostLabel(labels, hostName)
+		obj.SetLabels(labels)
+		if err := m.client.Patch(ctx, obj, patch); err != nil {
+			return errors.Wrapf(err, "failed to set the host label of %s", obj.GetName())
+		}
+	}
+	return nil
+}
+
+// setHostLabel sets the BareMetalHostLabel in the labels, or removes it if
+// hostName is empty.
+func setHostLabel(labels map[string]string, hostName string) {
+	if hostName == "" {
+		delete(labels, infrav1.BareMetalHostLabel)
+		return
+	}
+	labels[infrav1.BareMetalHostLabel] = hostName
+}
+
 // ReleaseLeases releases addresses from pool.
 func (m *DataManager) ReleaseLeases(ctx context.Context) error {
 	if m.Data.Spec.Template.Name == "" {
 		return nil
 	}
-	if m.Data.Spec.Template.Namespace == "" {
-		m.Data.Spec.Template.Namespace = m.Data.Namespace
-	}
 	// Fetch the Metal3DataTemplate object to get the templates
-	m3dt, err := fetchM3DataTemplate(ctx, &m.Data.Spec.Template, m.client,
+	m3dt, err := fetchM3DataTemplate(ctx, m.templateRef(), m.client,
 		m.Log, m.Data.Labels[clusterv1.ClusterNameLabel],
 	)
 	if err != nil {
@@ -315,6 +540,181 @@ func (m *DataManager) ReleaseLeases(ctx context.Context) error {
 	return m.releaseAddressesFromPool(ctx, *m3dt)
 }
 
+// WaitForHostRelease returns a transient error, and sets the
+// HostDataInUseCondition, while a BareMetalHost references the secrets of the
+// Metal3Data in its metaData, networkData or userData. The Metal3Machine
+// clears those references when deprovisioning the host, the deletion of the
+// Metal3Data then proceeds. It can be forced with the
+// DataForceDeleteAnnotation.
+func (m *DataManager) WaitForHostRelease(ctx context.Context) error {
+	if _, ok := m.Data.Annotations[infrav1.DataForceDeleteAnnotation]; ok {
+		conditions.Delete(m.Data, infrav1.HostDataInUseCondition)
+		return nil
+	}
+	hostName, err := m.hostUsingData(ctx)
+	if err != nil {
+		return err
+	}
+	if hostName == "" {
+		conditions.Delete(m.Data, infrav1.HostDataInUseCondition)
+		return nil
+	}
+	errMessage := fmt.Sprintf("Waiting for BareMetalHost %s to stop using the secrets of the Metal3Data",
+		hostName,
+	)
+	m.Log.Info(errMessage)
+	conditions.Set(m.Data, &clusterv1.Condition{
+		Type:    infrav1.HostDataInUseCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  infrav1.HostReferencesSecretsReason,
+		Message: errMessage,
+	})
+	return WithTransientError(errors.New(errMessage), requeueAfter)
+}
+
+// hostUsingData returns the name of a BareMetalHost referencing the secrets
+// of the Metal3Data, or an empty string. The hosts labeled with the
+// DataSecretsOwnerLabel set to the Metal3Data are listed, along with the host
+// recorded in the status, which may have been provisioned before the label
+// was introduced.
+func (m *DataManager) hostUsingData(ctx context.Context) (string, error) {
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := m.client.List(ctx, &hosts, client.InNamespace(m.Data.Namespace),
+		client.MatchingLabels{infrav1.DataSecretsOwnerLabel: m.Data.Name},
+	); err != nil {
+		return "", err
+	}
+	if m.Data.Status.HostRef != nil {
+		bmh := bmov1alpha1.BareMetalHost{}
+		err := m.client.Get(ctx, types.NamespacedName{
+			Name:      m.Data.Status.HostRef.Name,
+			Namespace: m.Data.Status.HostRef.Namespace,
+		}, &bmh)
+		if err == nil {
+			hosts.Items = append(hosts.Items, bmh)
+		} else if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+	for i := range hosts.Items {
+		bmh := &hosts.Items[i]
+		for _, ref := range []*corev1.SecretReference{bmh.Spec.MetaData, bmh.Spec.NetworkData, bmh.Spec.UserData} {
+			if m.ownsSecret(ref, bmh.Namespace) {
+				return bmh.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// ownsSecret returns true if the secret reference of a host in the given
+// namespace is one of the secrets of the Metal3Data.
+func (m *DataManager) ownsSecret(ref *corev1.SecretReference, hostNamespace string) bool {
+	if ref == nil || ref.Name == "" {
+		return false
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = hostNamespace
+	}
+	if namespace != m.Data.Namespace {
+		return false
+	}
+	for _, dataRef := range []*corev1.SecretReference{m.Data.Spec.MetaData, m.Data.Spec.NetworkData} {
+		if dataRef != nil && dataRef.Name == ref.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseSecrets removes the DataFinalizer from the secrets of the
+// Metal3Data, they are then garbage collected along with the Metal3Data.
+func (m *DataManager) ReleaseSecrets(ctx context.Context) error {
+	for _, ref := range []*corev1.SecretReference{m.Data.Spec.MetaData, m.Data.Spec.NetworkData} {
+		if ref == nil || ref.Name == "" {
+			continue
+		}
+		secret, err := checkSecretExists(ctx, m.client, ref.Name, m.Data.Namespace)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !Contains(secret.Finalizers, infrav1.DataFinalizer) {
+			continue
+		}
+		patch := client.MergeFrom(secret.DeepCopy())
+		secret.Finalizers = Filter(secret.Finalizers, infrav1.DataFinalizer)
+		if err := m.client.Patch(ctx, &secret, patch); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to remove the finalizer of secret %s", ref.Name)
+		}
+	}
+	return nil
+}
+
+// adoptSecret adds the DataFinalizer and the DataNameLabel to a secret of the
+// Metal3Data created before they were introduced.
+func (m *DataManager) adoptSecret(ctx context.Context, secret *corev1.Secret) error {
+	if !secret.DeletionTimestamp.IsZero() || !m.isSecretOwner(secret) {
+		return nil
+	}
+	if Contains(secret.Finalizers, infrav1.DataFinalizer) && secret.Labels[infrav1.DataNameLabel] == m.Data.Name {
+		return nil
+	}
+	patch := client.MergeFrom(secret.DeepCopy())
+	if !Contains(secret.Finalizers, infrav1.DataFinalizer) {
+		secret.Finalizers = append(secret.Finalizers, infrav1.DataFinalizer)
+	}
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[infrav1.DataNameLabel] = m.Data.Name
+	return errors.Wrapf(m.client.Patch(ctx, secret, patch),
+		"failed to adopt secret %s", secret.Name,
+	)
+}
+
+// isSecretOwner returns true if the Metal3Data owns the secret.
+func (m *DataManager) isSecretOwner(secret *corev1.Secret) bool {
+	for _, ownerRef := range secret.OwnerReferences {
+		if ownerRef.Kind == "Metal3Data" && ownerRef.Name == m.Data.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// renderedSecretName returns the name of the secret of the Metal3Data with the
+// given suffix. A secret of the Metal3Data, found by its DataNameLabel, or
+// named after the machine only, as before the names were generated by
+// dataSecretName, is kept so that the machines provisioned before keep their
+// secrets. Only the secrets owned by the Metal3Data are kept, another secret
+// with the legacy name, e.g. created by a user, is left untouched.
+func (m *DataManager) renderedSecretName(ctx context.Context, clusterName, machineName, suffix string) (string, error) {
+	secrets := corev1.SecretList{}
+	if err := m.client.List(ctx, &secrets, client.InNamespace(m.Data.Namespace),
+		client.MatchingLabels{infrav1.DataNameLabel: m.Data.Name},
+	); err != nil {
+		return "", errors.Wrap(err, "failed to list the secrets of the Metal3Data")
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if strings.HasSuffix(secret.Name, "-"+suffix) && m.isSecretOwner(secret) {
+			return secret.Name, nil
+		}
+	}
+	legacyName := machineName + "-" + suffix
+	legacySecret, err := checkSecretExists(ctx, m.client, legacyName, m.Data.Namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil && m.isSecretOwner(&legacySecret) {
+		return legacyName, nil
+	}
+	return dataSecretName(clusterName, machineName, suffix), nil
+}
+
 // addressFromPool contains the elements coming from an IPPool.
 type addressFromPool struct {
 	Address    ipamv1.IPAddressStr
@@ -323,6 +723,9 @@ type addressFromPool struct {
 	dnsServers []ipamv1.IPAddressStr
 }
 
+// reconciledClaim is a claim of a referenced pool. fetchAgain is set when the
+// claim was just created, it is fetched again by the next reconcile, once
+// the cache has seen it.
 type reconciledClaim struct {
 	claim      *caipamv1.IPAddressClaim
 	m3Claim    *ipamv1.IPClaim
@@ -333,8 +736,11 @@ type reconciledClaim struct {
 // It does so by creating IP claims for each referenced pool. It will check whether the claim was fulfilled
 // and return a map containing all pools and addresses. If some claims are not fulfilled yet, it will
 // return a Transient type ReconcileError, indicating that some addresses were not fully allocated yet.
+// The claims of all the pools are created before any address is waited for, and the reconcile is
+// requeued shortly after creating claims instead of fetching them again, as the allocation is done
+// by another controller.
 func (m *DataManager) getAddressesFromPool(ctx context.Context,
-	m3dt infrav1.Metal3DataTemplate,
+	m3dt infrav1.Metal3DataTemplate, bmh *bmov1alpha1.BareMetalHost,
 ) (map[string]addressFromPool, error) {
 	var err error
 
@@ -349,7 +755,7 @@ func (m *DataManager) getAddressesFromPool(ctx context.Context,
 		var rc reconciledClaim
 		var err error
 		if isMetal3IPPoolRef(ref) {
-			rc, err = m.ensureM3IPClaim(ctx, ref)
+			rc, err = m.ensureM3IPClaim(ctx, ref, bmh)
 		} else {
 			rc, err = m.ensureIPClaim(ctx, ref)
 		}
@@ -359,23 +765,16 @@ func (m *DataManager) getAddressesFromPool(ctx context.Context,
 		claims[pool] = rc
 	}
 
-	requeue := false
+	requeue, created := false, false
 	for pool, ref := range poolRefs {
 		rc, ok := claims[pool]
 		if !ok {
 			continue
 		}
 		if rc.fetchAgain {
-			var err error
-			if rc.m3Claim != nil {
-				rc.m3Claim, err = fetchM3IPClaim(ctx, m.client, m.Log, m.Data.Name+"-"+ref.Name, m.Data.Namespace)
-			} else {
-				err = m.client.Get(ctx, types.NamespacedName{Namespace: m.Data.Namespace, Name: m.Data.Name + "-" + ref.Name}, rc.claim)
-			}
-			if err != nil {
-				// We ignore erros here. If they are persistent they will be handled during the next reconciliation.
-				continue
-			}
+			// A new claim is not allocated yet.
+			created = true
+			continue
 		}
 		m.Log.Info("Allocating address from IPPool", "pool name", pool)
 		var itemRequeue bool
@@ -390,7 +789,10 @@ func (m *DataManager) getAddressesFromPool(ctx context.Context,
 		}
 	}
 
-	m.Log.Info("done allocating addresses", "addresses", addresses, "requeue", requeue)
+	m.Log.Info("done allocating addresses", "addresses", addresses, "requeue", requeue || created)
+	if created {
+		return addresses, WithTransientError(nil, ipClaimRequeueAfter)
+	}
 	if requeue {
 		return addresses, WithTransientError(nil, requeueAfter)
 	}
@@ -464,6 +866,24 @@ func (p poolRefs) addName(name string) error {
 	return p.addRef(corev1.TypedLocalObjectReference{Name: name})
 }
 
+// addNetwork adds the pool a static network allocates its address from.
+// A FromPoolRef takes precedence over the IPAddressFromIPPool name.
+func (p poolRefs) addNetwork(ipAddressFromIPPool string, fromPoolRef *corev1.TypedLocalObjectReference) error {
+	if fromPoolRef != nil && fromPoolRef.Name != "" {
+		return p.addRef(*fromPoolRef)
+	}
+	return p.addName(ipAddressFromIPPool)
+}
+
+// networkPoolName returns the name under which the address of a static network
+// is cached, following the same precedence as [poolRefs.addNetwork].
+func networkPoolName(ipAddressFromIPPool string, fromPoolRef *corev1.TypedLocalObjectReference) string {
+	if fromPoolRef != nil && fromPoolRef.Name != "" {
+		return fromPoolRef.Name
+	}
+	return ipAddressFromIPPool
+}
+
 // getReferencedPools returns a map containing references to all pools mentioned by a [Metal3DataTemplate].
 func getReferencedPools(m3dt infrav1.Metal3DataTemplate) (map[string]corev1.TypedLocalObjectReference, error) {
 	pools := poolRefs{}
@@ -491,14 +911,8 @@ func getReferencedPools(m3dt infrav1.Metal3DataTemplate) (map[string]corev1.Type
 	}
 	if m3dt.Spec.NetworkData != nil {
 		for _, network := range m3dt.Spec.NetworkData.Networks.IPv4 {
-			if network.FromPoolRef != nil && network.FromPoolRef.Name != "" {
-				if err := pools.addRef(*network.FromPoolRef); err != nil {
-					return pools, err
-				}
-			} else if network.IPAddressFromIPPool != "" {
-				if err := pools.addName(network.IPAddressFromIPPool); err != nil {
-					return pools, err
-				}
+			if err := pools.addNetwork(network.IPAddressFromIPPool, network.FromPoolRef); err != nil {
+				return pools, err
 			}
 
 			for _, route := range network.Routes {
@@ -516,14 +930,8 @@ func getReferencedPools(m3dt infrav1.Metal3DataTemplate) (map[string]corev1.Type
 		}
 
 		for _, network := range m3dt.Spec.NetworkData.Networks.IPv6 {
-			if network.FromPoolRef != nil && network.FromPoolRef.Name != "" {
-				if err := pools.addRef(*network.FromPoolRef); err != nil {
-					return pools, err
-				}
-			} else if network.IPAddressFromIPPool != "" {
-				if err := pools.addName(network.IPAddressFromIPPool); err != nil {
-					return pools, err
-				}
+			if err := pools.addNetwork(network.IPAddressFromIPPool, network.FromPoolRef); err != nil {
+				return pools, err
 			}
 			for _, route := range network.Routes {
 				if route.Gateway.FromIPPool != nil {
@@ -595,9 +1003,20 @@ func getReferencedPools(m3dt infrav1.Metal3DataTemplate) (map[string]corev1.Type
 // m3IPClaimObjectMeta always returns ObjectMeta with Data labels, additional labels (DataLabelName/PoolLabelName)
 // will be added to Data labels in case preallocation is enabled.
 func (m *DataManager) m3IPClaimObjectMeta(name, poolRefName string, preallocationEnabled bool) *metav1.ObjectMeta {
+	// The claim gets its own copy of the labels, not to share the map of the
+	// Metal3Data.
+	labels := make(map[string]string, len(m.Data.Labels)+2)
+	for k, v := range m.Data.Labels {
+		labels[k] = v
+	}
 	if preallocationEnabled {
+		if m.Data.Labels == nil {
+			m.Data.Labels = map[string]string{}
+		}
 		m.Data.Labels[DataLabelName] = m.Data.Name
 		m.Data.Labels[PoolLabelName] = poolRefName
+		labels[DataLabelName] = m.Data.Name
+		labels[PoolLabelName] = poolRefName
 	}
 	return &metav1.ObjectMeta{
 		Name:       name + "-" + poolRefName,
@@ -612,13 +1031,16 @@ func (m *DataManager) m3IPClaimObjectMeta(name, poolRefName string, preallocatio
 				Controller: pointer.Bool(true),
 			},
 		},
-		Labels: m.Data.Labels,
+		Labels: labels,
 	}
 }
 
 // ensureM3IPClaim ensures that a claim for a referenced pool exists.
 // It returns the claim and whether to fetch the claim again when fetching IP addresses.
-func (m *DataManager) ensureM3IPClaim(ctx context.Context, poolRef corev1.TypedLocalObjectReference) (reconciledClaim, error) {
+// The BareMetalHost is the one fetched by the caller for the Metal3Data.
+func (m *DataManager) ensureM3IPClaim(ctx context.Context, poolRef corev1.TypedLocalObjectReference,
+	bmh *bmov1alpha1.BareMetalHost,
+) (reconciledClaim, error) {
 	ipClaim, err := fetchM3IPClaim(ctx, m.client, m.Log, m.Data.Name+"-"+poolRef.Name, m.Data.Namespace)
 	if err == nil {
 		return reconciledClaim{m3Claim: ipClaim}, nil
@@ -629,36 +1051,9 @@ func (m *DataManager) ensureM3IPClaim(ctx context.Context, poolRef corev1.TypedL
 		return reconciledClaim{m3Claim: ipClaim}, err
 	}
 
-	m3dt, err := fetchM3DataTemplate(ctx, &m.Data.Spec.Template, m.client,
-		m.Log, m.Data.Labels[clusterv1.ClusterNameLabel],
-	)
-	if err != nil {
-		return reconciledClaim{m3Claim: ipClaim}, err
-	}
-	if m3dt == nil {
-		return reconciledClaim{m3Claim: ipClaim}, nil
-	}
-	m.Log.Info("Fetched Metal3DataTemplate")
-
-	// Fetch the Metal3Machine, to get the related info
-	m3m, err := m.getM3Machine(ctx, m3dt)
-	if err != nil {
-		return reconciledClaim{m3Claim: ipClaim}, err
-	}
-	if m3m == nil {
-		return reconciledClaim{m3Claim: ipClaim}, nil
-	}
-	m.Log.Info("Fetched Metal3Machine")
-
-	// Fetch the BMH associated with the M3M
-	bmh, err := getHost(ctx, m3m, m.client, m.Log)
-	if err != nil {
-		return reconciledClaim{m3Claim: ipClaim}, err
-	}
 	if bmh == nil {
 		return reconciledClaim{m3Claim: ipClaim}, WithTransientError(nil, requeueAfter)
 	}
-	m.Log.Info("Fetched BMH")
 
 	ipClaim, err = fetchM3IPClaim(ctx, m.client, m.Log, bmh.Name+"-"+poolRef.Name, m.Data.Namespace)
 	if err == nil {
@@ -914,6 +1309,17 @@ func (m *DataManager) releaseAddressFromPool(ctx context.Context, poolRef corev1
 	return deleteObject(ctx, m.client, claim)
 }
 
+// networkDataSecretData returns the data of the networkData secret. A
+// networkData rendered in the netplan format is also set under the
+// network-config key, read by cloud-init.
+func networkDataSecretData(m3dt *infrav1.Metal3DataTemplate, networkData []byte) map[string][]byte {
+	data := map[string][]byte{"networkData": networkData}
+	if m3dt.Spec.NetworkData != nil && m3dt.Spec.NetworkData.Format == infrav1.NetworkDataFormatNetplan {
+		data["network-config"] = networkData
+	}
+	return data
+}
+
 // renderNetworkData renders the networkData into an object that will be
 // marshalled into the secret.
 func renderNetworkData(m3dt *infrav1.Metal3DataTemplate,
@@ -922,6 +1328,9 @@ func renderNetworkData(m3dt *infrav1.Metal3DataTemplate,
 	if m3dt.Spec.NetworkData == nil {
 		return nil, nil
 	}
+	if m3dt.Spec.NetworkData.Format == infrav1.NetworkDataFormatNetplan {
+		return renderNetplanNetworkData(m3dt.Spec.NetworkData, bmh, poolAddresses)
+	}
 	var err error
 
 	networkData := map[string][]interface{}{}
@@ -931,7 +1340,9 @@ func renderNetworkData(m3dt *infrav1.Metal3DataTemplate,
 		return nil, err
 	}
 
-	networkData["networks"], err = renderNetworkNetworks(m3dt.Spec.NetworkData.Networks, poolAddresses)
+	networkData["networks"], err = renderNetworkNetworks(m3dt.Spec.NetworkData.Networks, poolAddresses,
+		defaultRouteMetrics(m3dt.Spec.NetworkData.Links),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -981,12 +1392,16 @@ func renderNetworkLinks(networkLinks infrav1.NetworkDataLink, bmh *bmov1alpha1.B
 		if err != nil {
 			return nil, err
 		}
-		data = append(data, map[string]interface{}{
+		linkData := map[string]interface{}{
 			"type":                 link.Type,
 			"id":                   link.Id,
 			"mtu":                  link.MTU,
 			"ethernet_mac_address": macAddress,
-		})
+		}
+		if len(link.VendorExtensions) > 0 {
+			linkData["extra"] = link.VendorExtensions
+		}
+		data = append(data, linkData)
 	}
 
 	// Bond links
@@ -1024,20 +1439,61 @@ func renderNetworkLinks(networkLinks infrav1.NetworkDataLink, bmh *bmov1alpha1.B
 	return data, nil
 }
 
-// renderNetworkNetworks renders the different types of network.
+// defaultRouteMetrics returns the metric of the default routes of the links
+// with a defaultRoutePriority, by link name. The links are ordered by priority
+// and get increasing metrics, by steps of defaultRouteMetricStep.
+func defaultRouteMetrics(links infrav1.NetworkDataLink) map[string]int {
+	type linkPriority struct {
+		id       string
+		priority int
+	}
+	priorities := []linkPriority{}
+	addPriority := func(id string, priority *int) {
+		if priority != nil {
+			priorities = append(priorities, linkPriority{id: id, priority: *priority})
+		}
+	}
+	for _, link := range links.Ethernets {
+		addPriority(link.Id, link.DefaultRoutePriority)
+	}
+	for _, link := range links.Bonds {
+		addPriority(link.Id, link.DefaultRoutePriority)
+	}
+	for _, link := range links.Vlans {
+		addPriority(link.Id, link.DefaultRoutePriority)
+	}
+	sort.SliceStable(priorities, func(i, j int) bool {
+		return priorities[i].priority < priorities[j].priority
+	})
+	metrics := make(map[string]int, len(priorities))
+	for i, link := range priorities {
+		metrics[link.id] = (i + 1) * defaultRouteMetricStep
+	}
+	return metrics
+}
+
+// renderNetworkNetworks renders the different types of network. The default
+// routes of the networks are rendered with the metric of their link in
+// defaultMetrics, unless they have a metric.
 func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses map[string]addressFromPool,
+	defaultMetrics map[string]int,
 ) ([]interface{}, error) {
 	data := []interface{}{}
 
 	// IPv4 networks static allocation
 	for _, network := range networks.IPv4 {
-		poolAddress, ok := poolAddresses[network.IPAddressFromIPPool]
+		poolAddress, ok := poolAddresses[networkPoolName(network.IPAddressFromIPPool, network.FromPoolRef)]
 		if !ok {
 			return nil, errors.New("Pool not found in cache")
 		}
+		if isIPv6(string(poolAddress.Address)) {
+			return nil, fmt.Errorf("address %s allocated for network %s is not an IPv4 address", poolAddress.Address, network.ID)
+		}
 		ip := ipamv1.IPAddressv4Str(poolAddress.Address)
 		mask := translateMask(poolAddress.Prefix, true)
-		routes, err := getRoutesv4(network.Routes, poolAddresses)
+		routes, err := getRoutesv4(networkRoutesv4(network.Routes, network.DefaultRoute), poolAddresses,
+			linkDefaultMetric(defaultMetrics, network.Link),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -1053,13 +1509,18 @@ func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses ma
 
 	// IPv6 networks static allocation
 	for _, network := range networks.IPv6 {
-		poolAddress, ok := poolAddresses[network.IPAddressFromIPPool]
+		poolAddress, ok := poolAddresses[networkPoolName(network.IPAddressFromIPPool, network.FromPoolRef)]
 		if !ok {
 			return nil, errors.New("Pool not found in cache")
 		}
+		if isIPv4(string(poolAddress.Address)) {
+			return nil, fmt.Errorf("address %s allocated for network %s is not an IPv6 address", poolAddress.Address, network.ID)
+		}
 		ip := ipamv1.IPAddressv6Str(poolAddress.Address)
 		mask := translateMask(poolAddress.Prefix, false)
-		routes, err := getRoutesv6(network.Routes, poolAddresses)
+		routes, err := getRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute), poolAddresses,
+			linkDefaultMetric(defaultMetrics, network.Link),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -1075,7 +1536,9 @@ func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses ma
 
 	// IPv4 networks DHCP allocation
 	for _, network := range networks.IPv4DHCP {
-		routes, err := getRoutesv4(network.Routes, poolAddresses)
+		routes, err := getRoutesv4(networkRoutesv4(network.Routes, network.DefaultRoute), poolAddresses,
+			linkDefaultMetric(defaultMetrics, network.Link),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -1089,7 +1552,9 @@ func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses ma
 
 	// IPv6 networks DHCP allocation
 	for _, network := range networks.IPv6DHCP {
-		routes, err := getRoutesv6(network.Routes, poolAddresses)
+		routes, err := getRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute), poolAddresses,
+			linkDefaultMetric(defaultMetrics, network.Link),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -1103,7 +1568,9 @@ func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses ma
 
 	// IPv6 networks SLAAC allocation
 	for _, network := range networks.IPv6SLAAC {
-		routes, err := getRoutesv6(network.Routes, poolAddresses)
+		routes, err := getRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute), poolAddresses,
+			linkDefaultMetric(defaultMetrics, network.Link),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -1118,9 +1585,66 @@ func renderNetworkNetworks(networks infrav1.NetworkDataNetwork, poolAddresses ma
 	return data, nil
 }
 
+// linkDefaultMetric returns the metric of the default routes of the link, or
+// nil if the link has no defaultRoutePriority.
+func linkDefaultMetric(defaultMetrics map[string]int, link string) *int {
+	metric, ok := defaultMetrics[link]
+	if !ok {
+		return nil
+	}
+	return &metric
+}
+
+// routeMetric returns the metric to render for a route, its own metric or, for
+// a default route, defaultMetric.
+func routeMetric(network string, prefix int, metric, defaultMetric *int) *int {
+	if metric != nil {
+		return metric
+	}
+	if isDefaultRoute(network, prefix) {
+		return defaultMetric
+	}
+	return nil
+}
+
+// isDefaultRoute returns whether the route to the network is a default route.
+func isDefaultRoute(network string, prefix int) bool {
+	return prefix == 0 && net.ParseIP(network).IsUnspecified()
+}
+
+// networkRoutesv4 returns the IPv4 routes of a network, without its default
+// routes if defaultRoute is false.
+func networkRoutesv4(routes []infrav1.NetworkDataRoutev4, defaultRoute *bool) []infrav1.NetworkDataRoutev4 {
+	if defaultRoute == nil || *defaultRoute {
+		return routes
+	}
+	filtered := []infrav1.NetworkDataRoutev4{}
+	for _, route := range routes {
+		if !isDefaultRoute(string(route.Network), route.Prefix) {
+			filtered = append(filtered, route)
+		}
+	}
+	return filtered
+}
+
+// networkRoutesv6 returns the IPv6 routes of a network, without its default
+// routes if defaultRoute is false.
+func networkRoutesv6(routes []infrav1.NetworkDataRoutev6, defaultRoute *bool) []infrav1.NetworkDataRoutev6 {
+	if defaultRoute == nil || *defaultRoute {
+		return routes
+	}
+	filtered := []infrav1.NetworkDataRoutev6{}
+	for _, route := range routes {
+		if !isDefaultRoute(string(route.Network), route.Prefix) {
+			filtered = append(filtered, route)
+		}
+	}
+	return filtered
+}
+
 // getRoutesv4 returns the IPv4 routes.
 func getRoutesv4(netRoutes []infrav1.NetworkDataRoutev4,
-	poolAddresses map[string]addressFromPool,
+	poolAddresses map[string]addressFromPool, defaultMetric *int,
 ) ([]interface{}, error) {
 	routes := []interface{}{}
 	for _, route := range netRoutes {
@@ -1147,6 +1671,11 @@ func getRoutesv4(netRoutes []infrav1.NetworkDataRoutev4,
 				return []interface{}{}, errors.New("Pool not found in cache")
 			}
 			for _, service := range poolAddress.dnsServers {
+				// Only render the DNS servers of the route's address family,
+				// a dual-stack pool may return servers of both families.
+				if isIPv6(string(service)) {
+					continue
+				}
 				services = append(services, map[string]interface{}{
 					"type":    "dns",
 					"address": service,
@@ -1154,19 +1683,26 @@ func getRoutesv4(netRoutes []infrav1.NetworkDataRoutev4,
 			}
 		}
 		mask := translateMask(route.Prefix, true)
-		routes = append(routes, map[string]interface{}{
+		routeData := map[string]interface{}{
 			"network":  route.Network,
 			"netmask":  mask,
 			"gateway":  gateway,
 			"services": services,
-		})
+		}
+		if metric := routeMetric(string(route.Network), route.Prefix, route.Metric, defaultMetric); metric != nil {
+			routeData["metric"] = *metric
+		}
+		if route.Table != nil {
+			routeData["table"] = *route.Table
+		}
+		routes = append(routes, routeData)
 	}
 	return routes, nil
 }
 
 // getRoutesv6 returns the IPv6 routes.
 func getRoutesv6(netRoutes []infrav1.NetworkDataRoutev6,
-	poolAddresses map[string]addressFromPool,
+	poolAddresses map[string]addressFromPool, defaultMetric *int,
 ) ([]interface{}, error) {
 	routes := []interface{}{}
 	for _, route := range netRoutes {
@@ -1193,6 +1729,11 @@ func getRoutesv6(netRoutes []infrav1.NetworkDataRoutev6,
 				return []interface{}{}, errors.New("Pool not found in cache")
 			}
 			for _, service := range poolAddress.dnsServers {
+				// Only render the DNS servers of the route's address family,
+				// a dual-stack pool may return servers of both families.
+				if isIPv4(string(service)) {
+					continue
+				}
 				services = append(services, map[string]interface{}{
 					"type":    "dns",
 					"address": service,
@@ -1200,16 +1741,295 @@ func getRoutesv6(netRoutes []infrav1.NetworkDataRoutev6,
 			}
 		}
 		mask := translateMask(route.Prefix, false)
-		routes = append(routes, map[string]interface{}{
+		routeData := map[string]interface{}{
 			"network":  route.Network,
 			"netmask":  mask,
 			"gateway":  gateway,
 			"services": services,
-		})
+		}
+		if metric := routeMetric(string(route.Network), route.Prefix, route.Metric, defaultMetric); metric != nil {
+			routeData["metric"] = *metric
+		}
+		if route.Table != nil {
+			routeData["table"] = *route.Table
+		}
+		routes = append(routes, routeData)
 	}
 	return routes, nil
 }
 
+// netplanRoute is a route of a network, of either family, to render in the
+// netplan format.
+type netplanRoute struct {
+	network     string
+	prefix      int
+	gateway     string
+	gatewayPool *string
+	metric      *int
+	table       *int
+	dns         []string
+	dnsPool     *string
+}
+
+// renderNetplanNetworkData renders the networkData as a cloud-init network
+// config version 2 document. The addresses, routes and nameservers of the
+// networks are set on their link.
+func renderNetplanNetworkData(networkData *infrav1.NetworkData,
+	bmh *bmov1alpha1.BareMetalHost, poolAddresses map[string]addressFromPool,
+) ([]byte, error) {
+	ethernets := map[string]map[string]interface{}{}
+	bonds := map[string]map[string]interface{}{}
+	vlans := map[string]map[string]interface{}{}
+
+	for _, link := range networkData.Links.Ethernets {
+		macAddress, err := getLinkMacAddress(link.MACAddress, bmh)
+		if err != nil {
+			return nil, err
+		}
+		ethernets[link.Id] = map[string]interface{}{
+			"match":    map[string]interface{}{"macaddress": macAddress},
+			"set-name": link.Id,
+			"mtu":      link.MTU,
+		}
+	}
+	for _, link := range networkData.Links.Bonds {
+		macAddress, err := getLinkMacAddress(link.MACAddress, bmh)
+		if err != nil {
+			return nil, err
+		}
+		bonds[link.Id] = map[string]interface{}{
+			"interfaces": link.BondLinks,
+			"macaddress": macAddress,
+			"mtu":        link.MTU,
+			"parameters": map[string]interface{}{"mode": link.BondMode},
+		}
+	}
+	for _, link := range networkData.Links.Vlans {
+		macAddress, err := getLinkMacAddress(link.MACAddress, bmh)
+		if err != nil {
+			return nil, err
+		}
+		vlans[link.Id] = map[string]interface{}{
+			"id":         link.VlanID,
+			"link":       link.VlanLink,
+			"macaddress": macAddress,
+			"mtu":        link.MTU,
+		}
+	}
+
+	getLink := func(link, network string) (map[string]interface{}, error) {
+		for _, links := range []map[string]map[string]interface{}{ethernets, bonds, vlans} {
+			if linkData, ok := links[link]; ok {
+				return linkData, nil
+			}
+		}
+		return nil, fmt.Errorf("link %s of network %s is not defined", link, network)
+	}
+	defaultMetrics := defaultRouteMetrics(networkData.Links)
+
+	// addNetwork sets the address, the routes and the nameservers of a
+	// network on its link.
+	addNetwork := func(id, link, address string, routes []netplanRoute, ipv6 bool) error {
+		linkData, err := getLink(link, id)
+		if err != nil {
+			return err
+		}
+		if address != "" {
+			addresses, _ := linkData["addresses"].([]string)
+			linkData["addresses"] = append(addresses, address)
+		}
+		routesData, nameservers, err := renderNetplanRoutes(routes, poolAddresses,
+			linkDefaultMetric(defaultMetrics, link), ipv6,
+		)
+		if err != nil {
+			return err
+		}
+		if len(routesData) > 0 {
+			existing, _ := linkData["routes"].([]interface{})
+			linkData["routes"] = append(existing, routesData...)
+		}
+		if len(nameservers) > 0 {
+			existing, _ := linkData["nameservers"].(map[string]interface{})
+			if existing == nil {
+				existing = map[string]interface{}{"addresses": []string{}}
+				linkData["nameservers"] = existing
+			}
+			addresses, _ := existing["addresses"].([]string)
+			for _, nameserver := range nameservers {
+				if !Contains(addresses, nameserver) {
+					addresses = append(addresses, nameserver)
+				}
+			}
+			existing["addresses"] = addresses
+		}
+		return nil
+	}
+
+	for _, network := range networkData.Networks.IPv4 {
+		poolAddress, ok := poolAddresses[networkPoolName(network.IPAddressFromIPPool, network.FromPoolRef)]
+		if !ok {
+			return nil, errors.New("Pool not found in cache")
+		}
+		if isIPv6(string(poolAddress.Address)) {
+			return nil, fmt.Errorf("address %s allocated for network %s is not an IPv4 address", poolAddress.Address, network.ID)
+		}
+		address := fmt.Sprintf("%s/%d", poolAddress.Address, poolAddress.Prefix)
+		if err := addNetwork(network.ID, network.Link, address, netplanRoutesv4(networkRoutesv4(network.Routes, network.DefaultRoute)), false); err != nil {
+			return nil, err
+		}
+	}
+	for _, network := range networkData.Networks.IPv6 {
+		poolAddress, ok := poolAddresses[networkPoolName(network.IPAddressFromIPPool, network.FromPoolRef)]
+		if !ok {
+			return nil, errors.New("Pool not found in cache")
+		}
+		if isIPv4(string(poolAddress.Address)) {
+			return nil, fmt.Errorf("address %s allocated for network %s is not an IPv6 address", poolAddress.Address, network.ID)
+		}
+		address := fmt.Sprintf("%s/%d", poolAddress.Address, poolAddress.Prefix)
+		if err := addNetwork(network.ID, network.Link, address, netplanRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute)), true); err != nil {
+			return nil, err
+		}
+	}
+	for _, network := range networkData.Networks.IPv4DHCP {
+		if err := addNetwork(network.ID, network.Link, "", netplanRoutesv4(networkRoutesv4(network.Routes, network.DefaultRoute)), false); err != nil {
+			return nil, err
+		}
+		linkData, _ := getLink(network.Link, network.ID)
+		linkData["dhcp4"] = true
+	}
+	for _, network := range networkData.Networks.IPv6DHCP {
+		if err := addNetwork(network.ID, network.Link, "", netplanRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute)), true); err != nil {
+			return nil, err
+		}
+		linkData, _ := getLink(network.Link, network.ID)
+		linkData["dhcp6"] = true
+	}
+	for _, network := range networkData.Networks.IPv6SLAAC {
+		if err := addNetwork(network.ID, network.Link, "", netplanRoutesv6(networkRoutesv6(network.Routes, network.DefaultRoute)), true); err != nil {
+			return nil, err
+		}
+		linkData, _ := getLink(network.Link, network.ID)
+		linkData["accept-ra"] = true
+	}
+
+	config := map[string]interface{}{"version": 2}
+	if len(ethernets) > 0 {
+		config["ethernets"] = ethernets
+	}
+	if len(bonds) > 0 {
+		config["bonds"] = bonds
+	}
+	if len(vlans) > 0 {
+		config["vlans"] = vlans
+	}
+	return yaml.Marshal(map[string]interface{}{"network": config})
+}
+
+// netplanRoutesv4 returns the IPv4 routes to render in the netplan format.
+func netplanRoutesv4(routes []infrav1.NetworkDataRoutev4) []netplanRoute {
+	netplanRoutes := []netplanRoute{}
+	for _, route := range routes {
+		netplanRoute := netplanRoute{
+			network:     string(route.Network),
+			prefix:      route.Prefix,
+			gatewayPool: route.Gateway.FromIPPool,
+			metric:      route.Metric,
+			table:       route.Table,
+			dnsPool:     route.Services.DNSFromIPPool,
+		}
+		if route.Gateway.String != nil {
+			netplanRoute.gateway = string(*route.Gateway.String)
+		}
+		for _, service := range route.Services.DNS {
+			netplanRoute.dns = append(netplanRoute.dns, string(service))
+		}
+		netplanRoutes = append(netplanRoutes, netplanRoute)
+	}
+	return netplanRoutes
+}
+
+// netplanRoutesv6 returns the IPv6 routes to render in the netplan format.
+func netplanRoutesv6(routes []infrav1.NetworkDataRoutev6) []netplanRoute {
+	netplanRoutes := []netplanRoute{}
+	for _, route := range routes {
+		netplanRoute := netplanRoute{
+			network:     string(route.Network),
+			prefix:      route.Prefix,
+			gatewayPool: route.Gateway.FromIPPool,
+			metric:      route.Metric,
+			table:       route.Table,
+			dnsPool:     route.Services.DNSFromIPPool,
+		}
+		if route.Gateway.String != nil {
+			netplanRoute.gateway = string(*route.Gateway.String)
+		}
+		for _, service := range route.Services.DNS {
+			netplanRoute.dns = append(netplanRoute.dns, string(service))
+		}
+		netplanRoutes = append(netplanRoutes, netplanRoute)
+	}
+	return netplanRoutes
+}
+
+// renderNetplanRoutes renders the routes in the netplan format and returns
+// the DNS servers of their services. The DNS servers fetched from a pool are
+// only returned for the family of the routes.
+func renderNetplanRoutes(routes []netplanRoute, poolAddresses map[string]addressFromPool,
+	defaultMetric *int, ipv6 bool,
+) ([]interface{}, []string, error) {
+	data := []interface{}{}
+	nameservers := []string{}
+	for _, route := range routes {
+		gateway := route.gateway
+		if route.gatewayPool != nil {
+			poolAddress, ok := poolAddresses[*route.gatewayPool]
+			if !ok {
+				return nil, nil, errors.New("Failed to fetch pool from cache")
+			}
+			gateway = string(poolAddress.Gateway)
+		}
+		nameservers = append(nameservers, route.dns...)
+		if route.dnsPool != nil {
+			poolAddress, ok := poolAddresses[*route.dnsPool]
+			if !ok {
+				return nil, nil, errors.New("Pool not found in cache")
+			}
+			for _, service := range poolAddress.dnsServers {
+				if isIPv6(string(service)) == ipv6 {
+					nameservers = append(nameservers, string(service))
+				}
+			}
+		}
+		routeData := map[string]interface{}{
+			"to": fmt.Sprintf("%s/%d", route.network, route.prefix),
+		}
+		if gateway != "" {
+			routeData["via"] = gateway
+		}
+		if metric := routeMetric(route.network, route.prefix, route.metric, defaultMetric); metric != nil {
+			routeData["metric"] = *metric
+		}
+		if route.table != nil {
+			routeData["table"] = *route.table
+		}
+		data = append(data, routeData)
+	}
+	return data, nameservers, nil
+}
+
+// isIPv4 returns whether the given address, with or without prefix, is an IPv4 address.
+func isIPv4(address string) bool {
+	ip := net.ParseIP(strings.Split(address, "/")[0])
+	return ip != nil && ip.To4() != nil
+}
+
+// isIPv6 returns whether the given address, with or without prefix, is an IPv6 address.
+func isIPv6(address string) bool {
+	ip := net.ParseIP(strings.Split(address, "/")[0])
+	return ip != nil && ip.To4() == nil
+}
+
 // translateMask transforms a mask given as integer into a dotted-notation string.
 func translateMask(maskInt int, ipv4 bool) interface{} {
 	if ipv4 {
@@ -1262,13 +2082,27 @@ func renderMetaData(m3d *infrav1.Metal3Data, m3dt *infrav1.Metal3DataTemplate,
 		metadata[entry.Key] = value
 	}
 
+	// Keys rendered from pools. With dual-stack, one entry per address family
+	// is expected, so two pools must not silently overwrite the same key.
+	poolKeys := make(map[string]string)
+	setPoolKey := func(entry infrav1.FromPool, value string) error {
+		if pool, exists := poolKeys[entry.Key]; exists && pool != entry.Name {
+			return fmt.Errorf("metadata key %s is rendered from both pool %s and pool %s", entry.Key, pool, entry.Name)
+		}
+		poolKeys[entry.Key] = entry.Name
+		metadata[entry.Key] = value
+		return nil
+	}
+
 	// IP addresses
 	for _, entry := range m3dt.Spec.MetaData.IPAddressesFromPool {
 		poolAddress, ok := poolAddresses[entry.Name]
 		if !ok {
 			return nil, errors.New("Pool not found in cache")
 		}
-		metadata[entry.Key] = string(poolAddress.Address)
+		if err := setPoolKey(entry, string(poolAddress.Address)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Prefixes
@@ -1277,7 +2111,9 @@ func renderMetaData(m3d *infrav1.Metal3Data, m3dt *infrav1.Metal3DataTemplate,
 		if !ok {
 			return nil, errors.New("Pool not found in cache")
 		}
-		metadata[entry.Key] = strconv.Itoa(poolAddress.Prefix)
+		if err := setPoolKey(entry, strconv.Itoa(poolAddress.Prefix)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Gateways
@@ -1286,7 +2122,9 @@ func renderMetaData(m3d *infrav1.Metal3Data, m3dt *infrav1.Metal3DataTemplate,
 		if !ok {
 			return nil, errors.New("Pool not found in cache")
 		}
-		metadata[entry.Key] = string(poolAddress.Gateway)
+		if err := setPoolKey(entry, string(poolAddress.Gateway)); err != nil {
+			return nil, err
+		}
 	}
 
 	// Indexes
@@ -1344,6 +2182,15 @@ func renderMetaData(m3d *infrav1.Metal3Data, m3dt *infrav1.Metal3DataTemplate,
 		}
 	}
 
+	// Templates
+	for _, entry := range m3dt.Spec.MetaData.FromTemplates {
+		value, err := renderMetaDataTemplate(entry, m3d, m3m, machine, bmh)
+		if err != nil {
+			return nil, err
+		}
+		metadata[entry.Key] = value
+	}
+
 	// Strings
 	for _, entry := range m3dt.Spec.MetaData.Strings {
 		metadata[entry.Key] = entry.Value
@@ -1353,6 +2200,56 @@ func renderMetaData(m3d *infrav1.Metal3Data, m3dt *infrav1.Metal3DataTemplate,
 	return yaml.Marshal(metadata)
 }
 
+// metaDataTemplateContext is the data the metadata templates are rendered
+// with. Only these fields are exposed to the templates.
+type metaDataTemplateContext struct {
+	MachineName               string
+	Metal3MachineName         string
+	ClusterName               string
+	BareMetalHostName         string
+	BareMetalHostLabels       map[string]string
+	BareMetalHostAnnotations  map[string]string
+	BareMetalHostRAMMebibytes int
+	Index                     int
+}
+
+// newMetaDataTemplateContext returns the data the templates of the machine
+// are rendered with.
+func newMetaDataTemplateContext(index int, m3m *infrav1.Metal3Machine, machine *clusterv1.Machine,
+	bmh *bmov1alpha1.BareMetalHost,
+) metaDataTemplateContext {
+	data := metaDataTemplateContext{
+		MachineName:              machine.Name,
+		Metal3MachineName:        m3m.Name,
+		ClusterName:              machine.Spec.ClusterName,
+		BareMetalHostName:        bmh.Name,
+		BareMetalHostLabels:      bmh.Labels,
+		BareMetalHostAnnotations: bmh.Annotations,
+		Index:                    index,
+	}
+	if bmh.Status.HardwareDetails != nil {
+		data.BareMetalHostRAMMebibytes = bmh.Status.HardwareDetails.RAMMebibytes
+	}
+	return data
+}
+
+// renderMetaDataTemplate renders the template of a metadata item. Referencing
+// a missing label or annotation is an error.
+func renderMetaDataTemplate(entry infrav1.MetaDataFromTemplate, m3d *infrav1.Metal3Data,
+	m3m *infrav1.Metal3Machine, machine *clusterv1.Machine, bmh *bmov1alpha1.BareMetalHost,
+) (string, error) {
+	tmpl, err := template.New(entry.Key).Option("missingkey=error").Parse(entry.Template)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the template of metadata key %s: %w", entry.Key, err)
+	}
+	data := newMetaDataTemplateContext(m3d.Spec.Index, m3m, machine, bmh)
+	var value strings.Builder
+	if err := tmpl.Execute(&value, data); err != nil {
+		return "", fmt.Errorf("failed to render the template of metadata key %s: %w", entry.Key, err)
+	}
+	return value.String(), nil
+}
+
 // getBMHMacByName returns the mac address of the interface matching the name.
 func getBMHMacByName(name string, bmh *bmov1alpha1.BareMetalHost) (string, error) {
 	if bmh == nil || bmh.Status.HardwareDetails == nil || bmh.Status.HardwareDetails.NIC == nil {
@@ -19,6 +19,9 @@ package baremetal
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -30,15 +33,19 @@ import (
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 var _ = Describe("Metal3Data manager", func() {
@@ -202,29 +209,50 @@ var _ = Describe("Metal3Data manager", func() {
 			} else {
 				Expect(tc.m3d.Status.Ready).To(BeFalse())
 			}
+			if tc.bmh != nil {
+				Expect(tc.m3d.Status.HostRef).NotTo(BeNil())
+				Expect(tc.m3d.Status.HostRef.Name).To(Equal(tc.bmh.Name))
+			}
 			if tc.expectedMetadata != nil {
 				tmpSecret := corev1.Secret{}
 				err = fakeClient.Get(context.TODO(),
 					client.ObjectKey{
-						Name:      metal3machineName + "-metadata",
+						Name:      tc.m3d.Spec.MetaData.Name,
 						Namespace: namespaceName,
 					},
 					&tmpSecret,
 				)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(tmpSecret.Data["metaData"])).To(Equal(*tc.expectedMetadata))
+				// Only the secrets of the Metal3Data get its finalizer.
+				if tc.metadataSecret == nil || len(tc.metadataSecret.OwnerReferences) > 0 {
+					Expect(tmpSecret.Finalizers).To(ContainElement(infrav1.DataFinalizer))
+				} else {
+					Expect(tmpSecret.Finalizers).To(BeEmpty())
+				}
+				if tc.bmh != nil {
+					Expect(tmpSecret.Labels).To(HaveKeyWithValue(infrav1.BareMetalHostLabel, tc.bmh.Name))
+				}
 			}
 			if tc.expectedNetworkData != nil {
 				tmpSecret := corev1.Secret{}
 				err = fakeClient.Get(context.TODO(),
 					client.ObjectKey{
-						Name:      metal3machineName + "-networkdata",
+						Name:      tc.m3d.Spec.NetworkData.Name,
 						Namespace: namespaceName,
 					},
 					&tmpSecret,
 				)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(tmpSecret.Data["networkData"])).To(Equal(*tc.expectedNetworkData))
+				if tc.networkdataSecret == nil || len(tc.networkdataSecret.OwnerReferences) > 0 {
+					Expect(tmpSecret.Finalizers).To(ContainElement(infrav1.DataFinalizer))
+				} else {
+					Expect(tmpSecret.Finalizers).To(BeEmpty())
+				}
+				if tc.bmh != nil {
+					Expect(tmpSecret.Labels).To(HaveKeyWithValue(infrav1.BareMetalHostLabel, tc.bmh.Name))
+				}
 			}
 		},
 		Entry("Empty", testCaseCreateSecrets{
@@ -364,13 +392,33 @@ var _ = Describe("Metal3Data manager", func() {
 				Spec:       infrav1.Metal3DataClaimSpec{},
 			},
 			metadataSecret: &corev1.Secret{
-				ObjectMeta: testObjectMeta(metal3machineName+"-metadata", namespaceName, ""),
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName + "-metadata",
+					Namespace: namespaceName,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: infrav1.GroupVersion.String(),
+							Kind:       "Metal3Data",
+							Name:       metal3DataName,
+						},
+					},
+				},
 				Data: map[string][]byte{
 					"metaData": []byte("Hello"),
 				},
 			},
 			networkdataSecret: &corev1.Secret{
-				ObjectMeta: testObjectMeta(metal3machineName+"-networkdata", namespaceName, ""),
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName + "-networkdata",
+					Namespace: namespaceName,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: infrav1.GroupVersion.String(),
+							Kind:       "Metal3Data",
+							Name:       metal3DataName,
+						},
+					},
+				},
 				Data: map[string][]byte{
 					"networkData": []byte("Bye"),
 				},
@@ -557,257 +605,1514 @@ var _ = Describe("Metal3Data manager", func() {
 		}),
 	)
 
-	type testCaseReleaseLeases struct {
-		m3d           *infrav1.Metal3Data
-		m3dt          *infrav1.Metal3DataTemplate
-		expectError   bool
-		expectRequeue bool
-	}
-
-	DescribeTable("Test ReleaseLeases",
-		func(tc testCaseReleaseLeases) {
-			objects := []client.Object{}
-			if tc.m3dt != nil {
-				objects = append(objects, tc.m3dt)
-			}
-			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
-			dataMgr, err := NewDataManager(fakeClient, tc.m3d,
-				logr.Discard(),
-			)
-			Expect(err).NotTo(HaveOccurred())
-			err = dataMgr.ReleaseLeases(context.TODO())
-			if tc.expectError || tc.expectRequeue {
-				Expect(err).To(HaveOccurred())
-				if tc.expectRequeue {
-					Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
-				} else {
-					Expect(err).NotTo(BeAssignableToTypeOf(ReconcileError{}))
-				}
-			} else {
-				Expect(err).NotTo(HaveOccurred())
-			}
-		},
-		Entry("Empty spec", testCaseReleaseLeases{
-			m3d: &infrav1.Metal3Data{},
-		}),
-		Entry("M3dt not found", testCaseReleaseLeases{
-			m3d: &infrav1.Metal3Data{
-				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
-				Spec: infrav1.Metal3DataSpec{
-					Template: corev1.ObjectReference{
-						Name: metal3DataTemplateName,
-					},
-				},
-			},
-			expectRequeue: true,
-		}),
-		Entry("M3dt found", testCaseReleaseLeases{
-			m3d: &infrav1.Metal3Data{
-				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
-				Spec: infrav1.Metal3DataSpec{
-					Template: corev1.ObjectReference{
-						Name: metal3DataTemplateName,
-					},
-				},
+	It("Creates dual-stack secrets from an IPv4 and an IPv6 pool", func() {
+		m3d := &infrav1.Metal3Data{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Metal3Data",
+				APIVersion: infrav1.GroupVersion.String(),
 			},
-			m3dt: &infrav1.Metal3DataTemplate{
-				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, ""),
+			ObjectMeta: testObjectMetaWithOR(metal3DataName, metal3machineName),
+			Spec: infrav1.Metal3DataSpec{
+				Template: *testObjectReference(metal3DataTemplateName),
+				Claim:    *testObjectReference(metal3DataClaimName),
 			},
-		}),
-	)
-
-	type testCaseGetAddressesFromPool struct {
-		m3dtSpec      infrav1.Metal3DataTemplateSpec
-		m3IPClaims    []string
-		ipClaims      []string
-		expectError   bool
-		expectRequeue bool
-	}
-
-	DescribeTable("Test GetAddressesFromPool",
-		func(tc testCaseGetAddressesFromPool) {
-			objects := []client.Object{}
-			for _, poolName := range tc.m3IPClaims {
-				pool := &ipamv1.IPClaim{
-					ObjectMeta: testObjectMeta(metal3DataName+"-"+poolName, namespaceName, ""),
-					Spec: ipamv1.IPClaimSpec{
-						Pool: *testObjectReference("abc"),
-					},
-				}
-				objects = append(objects, pool)
-			}
-			for _, poolName := range tc.ipClaims {
-				claim := &caipamv1.IPAddressClaim{
-					ObjectMeta: testObjectMeta(metal3DataName+"-"+poolName, namespaceName, ""),
-					Spec: caipamv1.IPAddressClaimSpec{
-						PoolRef: corev1.TypedLocalObjectReference{
-							Name:     "abc",
-							APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
-							Kind:     "TestPool",
-						},
-					},
-				}
-				objects = append(objects, claim)
-			}
-			m3d := &infrav1.Metal3Data{
-				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
-				Spec: infrav1.Metal3DataSpec{
-					Template: *testObjectReference(metal3DataTemplateName),
-				},
-				TypeMeta: metav1.TypeMeta{
-					Kind:       "Metal3Data",
-					APIVersion: infrav1.GroupVersion.String(),
-				},
-			}
-			m3dt := infrav1.Metal3DataTemplate{
-				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, ""),
-				Spec:       tc.m3dtSpec,
-			}
-			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
-			dataMgr, err := NewDataManager(fakeClient, m3d,
-				logr.Discard(),
-			)
-			Expect(err).NotTo(HaveOccurred())
-			poolAddresses, err := dataMgr.getAddressesFromPool(context.TODO(), m3dt)
-			if tc.expectError || tc.expectRequeue {
-				Expect(err).To(HaveOccurred())
-				if tc.expectRequeue {
-					Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
-				} else {
-					Expect(err).NotTo(BeAssignableToTypeOf(ReconcileError{}))
-				}
-			} else {
-				Expect(err).NotTo(HaveOccurred())
-			}
-			expectedPoolAddress := make(map[string]addressFromPool)
-			for _, poolName := range tc.m3IPClaims {
-				expectedPoolAddress[poolName] = addressFromPool{}
-			}
-			for _, poolName := range tc.ipClaims {
-				expectedPoolAddress[poolName] = addressFromPool{}
-			}
-			Expect(expectedPoolAddress).To(Equal(poolAddresses))
-		},
-		Entry("Metadata ok", testCaseGetAddressesFromPool{
-			m3dtSpec: infrav1.Metal3DataTemplateSpec{
+		}
+		m3dt := &infrav1.Metal3DataTemplate{
+			ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+			Spec: infrav1.Metal3DataTemplateSpec{
 				MetaData: &infrav1.MetaData{
 					IPAddressesFromPool: []infrav1.FromPool{
 						{
-							Key:  "Address-1",
-							Name: "abcd-1",
-						},
-					},
-					PrefixesFromPool: []infrav1.FromPool{
-						{
-							Key:  "Prefix-1",
-							Name: "abcd-2",
+							Key:  "local-ipv4",
+							Name: "pool-v4",
 						},
-					},
-					GatewaysFromPool: []infrav1.FromPool{
 						{
-							Key:  "Gateway-1",
-							Name: "abcd-3",
+							Key:  "local-ipv6",
+							Name: "pool-v6",
 						},
 					},
 				},
 				NetworkData: &infrav1.NetworkData{
-					Networks: infrav1.NetworkDataNetwork{
-						IPv4: []infrav1.NetworkDataIPv4{
-							{
-								IPAddressFromIPPool: "abcd-4",
-								Routes: []infrav1.NetworkDataRoutev4{
-									{
-										Gateway: infrav1.NetworkGatewayv4{
-											FromIPPool: pointer.String("abcd-5"),
-										},
-									},
-								},
-							},
-						},
-						IPv6: []infrav1.NetworkDataIPv6{
-							{
-								IPAddressFromIPPool: "abcd-6",
-								Routes: []infrav1.NetworkDataRoutev6{
-									{
-										Gateway: infrav1.NetworkGatewayv6{
-											FromIPPool: pointer.String("abcd-7"),
-										},
-									},
-								},
-							},
-						},
-						IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+					Links: infrav1.NetworkDataLink{
+						Ethernets: []infrav1.NetworkDataLinkEthernet{
 							{
-								Routes: []infrav1.NetworkDataRoutev4{
-									{
-										Gateway: infrav1.NetworkGatewayv4{
-											FromIPPool: pointer.String("abcd-8"),
-										},
-									},
+								Type: "phy",
+								Id:   "eth0",
+								MTU:  1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("XX:XX:XX:XX:XX:XX"),
 								},
 							},
 						},
-						IPv6DHCP: []infrav1.NetworkDataIPv6DHCP{
+					},
+					Networks: infrav1.NetworkDataNetwork{
+						IPv4: []infrav1.NetworkDataIPv4{
 							{
-								Routes: []infrav1.NetworkDataRoutev6{
-									{
-										Gateway: infrav1.NetworkGatewayv6{
-											FromIPPool: pointer.String("abcd-9"),
-										},
-									},
-								},
+								ID:                  "eth0-v4",
+								Link:                "eth0",
+								IPAddressFromIPPool: "pool-v4",
 							},
 						},
-						IPv6SLAAC: []infrav1.NetworkDataIPv6DHCP{
+						IPv6: []infrav1.NetworkDataIPv6{
 							{
-								Routes: []infrav1.NetworkDataRoutev6{
-									{
-										Gateway: infrav1.NetworkGatewayv6{
-											FromIPPool: pointer.String("abcd-10"),
-										},
-									},
-								},
+								ID:                  "eth0-v6",
+								Link:                "eth0",
+								IPAddressFromIPPool: "pool-v6",
 							},
 						},
 					},
 				},
 			},
-			m3IPClaims: []string{
-				"abcd-1",
-				"abcd-2",
-				"abcd-3",
-				"abcd-4",
-				"abcd-5",
-				"abcd-6",
-				"abcd-7",
-				"abcd-8",
-				"abcd-9",
-				"abcd-10",
-			},
-			expectRequeue: true,
-		}),
-		Entry("IPAddressesFromPool", testCaseGetAddressesFromPool{
-			m3dtSpec: infrav1.Metal3DataTemplateSpec{
-				MetaData: &infrav1.MetaData{
-					IPAddressesFromPool: []infrav1.FromPool{
-						{
-							Key:  "Address-1",
-							Name: "abcd",
-						},
+		}
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+				UID:       m3muid,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						Name:       machineName,
+						Kind:       "Machine",
+						APIVersion: clusterv1.GroupVersion.String(),
 					},
 				},
-				NetworkData: &infrav1.NetworkData{},
+				Annotations: map[string]string{
+					"metal3.io/BareMetalHost": namespaceName + "/" + baremetalhostName,
+				},
 			},
-			m3IPClaims: []string{
-				"abcd",
+			Spec: infrav1.Metal3MachineSpec{
+				DataTemplate: testObjectReference(metal3DataTemplateName),
 			},
-			expectRequeue: true,
-		}),
-		Entry("PrefixesFromPool", testCaseGetAddressesFromPool{
-			m3dtSpec: infrav1.Metal3DataTemplateSpec{
-				MetaData: &infrav1.MetaData{
-					PrefixesFromPool: []infrav1.FromPool{
-						{
+		}
+		objects := []client.Object{
+			m3dt,
+			m3m,
+			&infrav1.Metal3DataClaim{
+				ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+			},
+			&clusterv1.Machine{
+				ObjectMeta: testObjectMeta(machineName, namespaceName, muid),
+			},
+			&bmov1alpha1.BareMetalHost{
+				ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+		dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		// The first pass creates one claim per pool and waits for the allocations.
+		err = dataMgr.createSecrets(context.TODO())
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+
+		addresses := map[string]ipamv1.IPAddressSpec{
+			"pool-v4": {
+				Address: ipamv1.IPAddressStr("192.168.0.14"),
+				Prefix:  24,
+			},
+			"pool-v6": {
+				Address: ipamv1.IPAddressStr("2001:db8::14"),
+				Prefix:  64,
+			},
+		}
+		for pool, spec := range addresses {
+			ipClaim := &ipamv1.IPClaim{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      metal3DataName + "-" + pool,
+				Namespace: namespaceName,
+			}, ipClaim)).To(Succeed())
+			Expect(ipClaim.Spec.Pool.Name).To(Equal(pool))
+
+			ipAddress := &ipamv1.IPAddress{
+				ObjectMeta: testObjectMeta(pool+"-address", namespaceName, ""),
+				Spec:       spec,
+			}
+			Expect(fakeClient.Create(context.TODO(), ipAddress)).To(Succeed())
+			ipClaim.Status.Address = &corev1.ObjectReference{Name: ipAddress.Name}
+			Expect(fakeClient.Update(context.TODO(), ipClaim)).To(Succeed())
+		}
+
+		Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+		Expect(m3d.Status.Ready).To(BeTrue())
+
+		tmpSecret := corev1.Secret{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+			Name:      m3d.Spec.MetaData.Name,
+			Namespace: namespaceName,
+		}, &tmpSecret)).To(Succeed())
+		Expect(string(tmpSecret.Data["metaData"])).To(Equal(fmt.Sprintf(
+			"local-ipv4: 192.168.0.14\nlocal-ipv6: 2001:db8::14\nproviderid: %s\n", providerid,
+		)))
+
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+			Name:      m3d.Spec.NetworkData.Name,
+			Namespace: namespaceName,
+		}, &tmpSecret)).To(Succeed())
+		Expect(string(tmpSecret.Data["networkData"])).To(Equal("links:\n" +
+			"- ethernet_mac_address: XX:XX:XX:XX:XX:XX\n  id: eth0\n  mtu: 1500\n  type: phy\n" +
+			"networks:\n" +
+			"- id: eth0-v4\n  ip_address: 192.168.0.14\n  link: eth0\n  netmask: 255.255.255.0\n  routes: []\n  type: ipv4\n" +
+			"- id: eth0-v6\n  ip_address: 2001:db8::14\n  link: eth0\n  netmask: 'ffff:ffff:ffff:ffff::'\n  routes: []\n  type: ipv6\n" +
+			"services: []\n",
+		))
+	})
+
+	DescribeTable("Test renderedSecretName",
+		func(secret *corev1.Secret, expectedName string) {
+			m3d := &infrav1.Metal3Data{ObjectMeta: testObjectMeta(metal3DataName, namespaceName, "")}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(secret).Build()
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			name, err := dataMgr.renderedSecretName(context.TODO(), clusterName, metal3machineName, "metadata")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal(expectedName))
+		},
+		Entry("Secret of the Metal3Data found by its label", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "renamed-metadata",
+				Namespace: namespaceName,
+				Labels:    map[string]string{infrav1.DataNameLabel: metal3DataName},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3Data",
+					Name:       metal3DataName,
+				}},
+			},
+		}, "renamed-metadata"),
+		Entry("Legacy secret of the Metal3Data", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      metal3machineName + "-metadata",
+				Namespace: namespaceName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3Data",
+					Name:       metal3DataName,
+				}},
+			},
+		}, metal3machineName+"-metadata"),
+		Entry("Secret with the legacy name not owned by a Metal3Data", &corev1.Secret{
+			ObjectMeta: testObjectMeta(metal3machineName+"-metadata", namespaceName, ""),
+		}, dataSecretName(clusterName, metal3machineName, "metadata")),
+		Entry("Secret labeled for the Metal3Data but not owned by it", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "user-metadata",
+				Namespace: namespaceName,
+				Labels:    map[string]string{infrav1.DataNameLabel: metal3DataName},
+			},
+		}, dataSecretName(clusterName, metal3machineName, "metadata")),
+	)
+
+	It("Renders distinct secrets for machines with the same name in different clusters", func() {
+		objects := []client.Object{
+			&infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					UID:       m3muid,
+					OwnerReferences: []metav1.OwnerReference{{
+						Name:       machineName,
+						Kind:       "Machine",
+						APIVersion: clusterv1.GroupVersion.String(),
+					}},
+					Annotations: map[string]string{HostAnnotation: namespaceName + "/" + baremetalhostName},
+				},
+			},
+			&clusterv1.Machine{ObjectMeta: testObjectMeta(machineName, namespaceName, muid)},
+			&bmov1alpha1.BareMetalHost{ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid)},
+			// A secret of the same machine name rendered by a Metal3Data of
+			// another cluster must not be reused.
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      metal3machineName + "-metadata",
+				Namespace: namespaceName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3Data",
+					Name:       "other-data",
+				}},
+			}},
+		}
+		m3ds := []*infrav1.Metal3Data{}
+		for _, clusterName := range []string{"cluster-a", "cluster-b"} {
+			objects = append(objects,
+				&infrav1.Metal3DataTemplate{
+					ObjectMeta: testObjectMeta(clusterName, namespaceName, ""),
+					Spec: infrav1.Metal3DataTemplateSpec{
+						ClusterName: clusterName,
+						MetaData: &infrav1.MetaData{
+							Strings: []infrav1.MetaDataString{{Key: "cluster", Value: clusterName}},
+						},
+					},
+				},
+				&infrav1.Metal3DataClaim{ObjectMeta: testObjectMetaWithOR(clusterName, metal3machineName)},
+			)
+			m3d := &infrav1.Metal3Data{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Metal3Data",
+					APIVersion: infrav1.GroupVersion.String(),
+				},
+				ObjectMeta: testObjectMetaWithOR(clusterName, metal3machineName),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(clusterName),
+					Claim:    *testObjectReference(clusterName),
+				},
+			}
+			m3d.Labels = map[string]string{clusterv1.ClusterNameLabel: clusterName}
+			m3ds = append(m3ds, m3d)
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+
+		// The machine of the second cluster reuses the name of the first one.
+		names := map[string]bool{}
+		for _, m3d := range m3ds {
+			m3m := &infrav1.Metal3Machine{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+			}, m3m)).To(Succeed())
+			m3m.Spec.DataTemplate = testObjectReference(m3d.Spec.Template.Name)
+			Expect(fakeClient.Update(context.TODO(), m3m)).To(Succeed())
+
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+			Expect(m3d.Spec.MetaData.Name).NotTo(Equal(metal3machineName + "-metadata"))
+			names[m3d.Spec.MetaData.Name] = true
+
+			secret := corev1.Secret{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      m3d.Spec.MetaData.Name,
+				Namespace: namespaceName,
+			}, &secret)).To(Succeed())
+			Expect(string(secret.Data["metaData"])).To(HavePrefix("cluster: " + m3d.Name + "\n"))
+			Expect(secret.Labels).To(HaveKeyWithValue(infrav1.DataNameLabel, m3d.Name))
+			Expect(secret.OwnerReferences).To(ConsistOf(
+				HaveField("Kind", "Metal3Data"),
+				HaveField("Kind", "Metal3Machine"),
+			))
+		}
+		Expect(names).To(HaveLen(2))
+	})
+
+	It("Renders many Metal3Data with a bounded number of API calls", func() {
+		const count = 100
+		m3dt := &infrav1.Metal3DataTemplate{
+			ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+			Spec: infrav1.Metal3DataTemplateSpec{
+				MetaData: &infrav1.MetaData{
+					IPAddressesFromPool: []infrav1.FromPool{{Key: "local-ipv4", Name: "pool-v4"}},
+				},
+				NetworkData: &infrav1.NetworkData{
+					Networks: infrav1.NetworkDataNetwork{
+						IPv4: []infrav1.NetworkDataIPv4{{ID: "eth0-v4", Link: "eth0", IPAddressFromIPPool: "pool-v4"}},
+					},
+				},
+			},
+		}
+		objects := []client.Object{m3dt}
+		m3ds := []*infrav1.Metal3Data{}
+		for i := 0; i < count; i++ {
+			name := fmt.Sprintf("%s-%d", metal3machineName, i)
+			hostName := fmt.Sprintf("%s-%d", baremetalhostName, i)
+			objects = append(objects,
+				&infrav1.Metal3Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespaceName,
+						OwnerReferences: []metav1.OwnerReference{{
+							Name:       name,
+							Kind:       "Machine",
+							APIVersion: clusterv1.GroupVersion.String(),
+						}},
+						Annotations: map[string]string{HostAnnotation: namespaceName + "/" + hostName},
+					},
+					Spec: infrav1.Metal3MachineSpec{DataTemplate: testObjectReference(metal3DataTemplateName)},
+				},
+				&infrav1.Metal3DataClaim{ObjectMeta: testObjectMetaWithOR(name, name)},
+				&clusterv1.Machine{ObjectMeta: testObjectMeta(name, namespaceName, "")},
+				&bmov1alpha1.BareMetalHost{ObjectMeta: testObjectMeta(hostName, namespaceName, "")},
+			)
+			m3ds = append(m3ds, &infrav1.Metal3Data{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Metal3Data",
+					APIVersion: infrav1.GroupVersion.String(),
+				},
+				ObjectMeta: testObjectMetaWithOR(name, name),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(metal3DataTemplateName),
+					Claim:    *testObjectReference(name),
+				},
+			})
+		}
+
+		calls := map[string]int{}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					calls["get"]++
+					return c.Get(ctx, key, obj, opts...)
+				},
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					calls["list"]++
+					return c.List(ctx, list, opts...)
+				},
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					calls["create"]++
+					return c.Create(ctx, obj, opts...)
+				},
+			}).Build()
+		reconcileAll := func(expectRequeue bool) {
+			for _, m3d := range m3ds {
+				dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+				err = dataMgr.Reconcile(context.TODO())
+				if expectRequeue {
+					var reconcileError ReconcileError
+					Expect(errors.As(err, &reconcileError)).To(BeTrue())
+					Expect(reconcileError.IsTransient()).To(BeTrue())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+			}
+		}
+
+		// The first pass creates the claims and requeues shortly, without
+		// waiting for the allocations.
+		reconcileAll(true)
+		Expect(calls["create"]).To(Equal(count))
+		Expect(calls["get"]).To(BeNumerically("<=", 13*count))
+		// The metaData and networkData secrets of each Metal3Data are only
+		// looked up by their label once, when naming them.
+		Expect(calls["list"]).To(Equal(2 * count))
+
+		for i, m3d := range m3ds {
+			ipClaim := &ipamv1.IPClaim{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      m3d.Name + "-pool-v4",
+				Namespace: namespaceName,
+			}, ipClaim)).To(Succeed())
+			ipAddress := &ipamv1.IPAddress{
+				ObjectMeta: testObjectMeta(ipClaim.Name, namespaceName, ""),
+				Spec: ipamv1.IPAddressSpec{
+					Address: ipamv1.IPAddressStr(fmt.Sprintf("192.168.0.%d", i+1)),
+					Prefix:  24,
+				},
+			}
+			Expect(fakeClient.Create(context.TODO(), ipAddress)).To(Succeed())
+			ipClaim.Status.Address = &corev1.ObjectReference{Name: ipAddress.Name}
+			Expect(fakeClient.Update(context.TODO(), ipClaim)).To(Succeed())
+		}
+
+		// The second pass renders the secrets.
+		calls = map[string]int{}
+		reconcileAll(false)
+		Expect(calls["create"]).To(Equal(2 * count))
+		Expect(calls["get"]).To(BeNumerically("<=", 13*count))
+		Expect(calls["list"]).To(BeZero())
+		for _, m3d := range m3ds {
+			Expect(m3d.Status.Ready).To(BeTrue())
+		}
+	})
+
+	type testCasePoolKinds struct {
+		fromPool    infrav1.FromPool
+		ipv4Network infrav1.NetworkDataIPv4
+		capiPool    bool
+	}
+
+	type testCaseSetHostLabel struct {
+		hostName      string
+		existingLabel string
+		expectPatched bool
+	}
+
+	DescribeTable("Test setHostLabel",
+		func(tc testCaseSetHostLabel) {
+			labels := func() map[string]string {
+				if tc.existingLabel == "" {
+					return nil
+				}
+				return map[string]string{infrav1.BareMetalHostLabel: tc.existingLabel}
+			}
+			objectMeta := func(name string) metav1.ObjectMeta {
+				return metav1.ObjectMeta{Name: name, Namespace: namespaceName, Labels: labels()}
+			}
+			m3dt := infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						IPAddressesFromPool: []infrav1.FromPool{
+							{Key: "address", Name: "m3pool"},
+							{Key: "capiAddress", Name: "capipool", APIGroup: "ipam.cluster.x-k8s.io", Kind: "InClusterIPPool"},
+						},
+					},
+				},
+			}
+			m3d := &infrav1.Metal3Data{
+				ObjectMeta: metav1.ObjectMeta{Name: metal3DataName, Namespace: namespaceName, Labels: labels()},
+				Spec: infrav1.Metal3DataSpec{
+					Claim:       *testObjectReference(metal3DataClaimName),
+					MetaData:    &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+					NetworkData: &corev1.SecretReference{Name: metal3machineName + "-networkdata"},
+				},
+			}
+			objects := []client.Object{
+				&infrav1.Metal3DataClaim{ObjectMeta: objectMeta(metal3DataClaimName)},
+				&corev1.Secret{ObjectMeta: objectMeta(metal3machineName + "-metadata")},
+				&corev1.Secret{ObjectMeta: objectMeta(metal3machineName + "-networkdata")},
+				&ipamv1.IPClaim{ObjectMeta: objectMeta(metal3DataName + "-m3pool")},
+				&caipamv1.IPAddressClaim{ObjectMeta: objectMeta(metal3DataName + "-capipool")},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			resourceVersions := map[string]string{}
+			for _, obj := range objects {
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+				resourceVersions[obj.GetName()] = obj.GetResourceVersion()
+			}
+
+			var bmh *bmov1alpha1.BareMetalHost
+			if tc.hostName != "" {
+				bmh = &bmov1alpha1.BareMetalHost{
+					ObjectMeta: testObjectMeta(tc.hostName, namespaceName, bmhuid),
+				}
+			}
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataMgr.setHostLabel(context.TODO(), m3dt, bmh)).To(Succeed())
+
+			// The Metal3Data is persisted by the controller patch.
+			if tc.hostName == "" {
+				Expect(m3d.Labels).NotTo(HaveKey(infrav1.BareMetalHostLabel))
+			} else {
+				Expect(m3d.Labels).To(HaveKeyWithValue(infrav1.BareMetalHostLabel, tc.hostName))
+			}
+			for _, obj := range objects {
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+				if tc.hostName == "" {
+					Expect(obj.GetLabels()).NotTo(HaveKey(infrav1.BareMetalHostLabel))
+				} else {
+					Expect(obj.GetLabels()).To(HaveKeyWithValue(infrav1.BareMetalHostLabel, tc.hostName))
+				}
+				if tc.expectPatched {
+					Expect(obj.GetResourceVersion()).NotTo(Equal(resourceVersions[obj.GetName()]))
+				} else {
+					Expect(obj.GetResourceVersion()).To(Equal(resourceVersions[obj.GetName()]))
+				}
+			}
+		},
+		Entry("Labels the objects with the host", testCaseSetHostLabel{
+			hostName:      baremetalhostName,
+			expectPatched: true,
+		}),
+		Entry("Relabels the objects when the host changes", testCaseSetHostLabel{
+			hostName:      baremetalhostName,
+			existingLabel: "previous-host",
+			expectPatched: true,
+		}),
+		Entry("Does not patch the objects already labeled", testCaseSetHostLabel{
+			hostName:      baremetalhostName,
+			existingLabel: baremetalhostName,
+		}),
+		Entry("Removes the label when the host is released", testCaseSetHostLabel{
+			existingLabel: baremetalhostName,
+			expectPatched: true,
+		}),
+		Entry("Does not patch the objects without host", testCaseSetHostLabel{}),
+	)
+
+	DescribeTable("Creates the same secrets from a Metal3 IPPool and a CAPI IPAM pool",
+		func(tc testCasePoolKinds) {
+			m3d := &infrav1.Metal3Data{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Metal3Data",
+					APIVersion: infrav1.GroupVersion.String(),
+				},
+				ObjectMeta: testObjectMetaWithOR(metal3DataName, metal3machineName),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(metal3DataTemplateName),
+					Claim:    *testObjectReference(metal3DataClaimName),
+				},
+			}
+			addressPool := tc.fromPool
+			addressPool.Key = "local-ipv4"
+			prefixPool := tc.fromPool
+			prefixPool.Key = "local-prefix"
+			gatewayPool := tc.fromPool
+			gatewayPool.Key = "local-gateway"
+			m3dt := &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						IPAddressesFromPool: []infrav1.FromPool{addressPool},
+						PrefixesFromPool:    []infrav1.FromPool{prefixPool},
+						GatewaysFromPool:    []infrav1.FromPool{gatewayPool},
+					},
+					NetworkData: &infrav1.NetworkData{
+						Links: infrav1.NetworkDataLink{
+							Ethernets: []infrav1.NetworkDataLinkEthernet{
+								{
+									Type: "phy",
+									Id:   "eth0",
+									MTU:  1500,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										String: pointer.String("XX:XX:XX:XX:XX:XX"),
+									},
+								},
+							},
+						},
+						Networks: infrav1.NetworkDataNetwork{
+							IPv4: []infrav1.NetworkDataIPv4{tc.ipv4Network},
+						},
+					},
+				},
+			}
+			m3m := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					UID:       m3muid,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							Name:       machineName,
+							Kind:       "Machine",
+							APIVersion: clusterv1.GroupVersion.String(),
+						},
+					},
+					Annotations: map[string]string{
+						"metal3.io/BareMetalHost": namespaceName + "/" + baremetalhostName,
+					},
+				},
+				Spec: infrav1.Metal3MachineSpec{
+					DataTemplate: testObjectReference(metal3DataTemplateName),
+				},
+			}
+			objects := []client.Object{
+				m3dt,
+				m3m,
+				&infrav1.Metal3DataClaim{
+					ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+				},
+				&clusterv1.Machine{
+					ObjectMeta: testObjectMeta(machineName, namespaceName, muid),
+				},
+				&bmov1alpha1.BareMetalHost{
+					ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid),
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			// The first pass creates the claim and waits for the allocation.
+			err = dataMgr.createSecrets(context.TODO())
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+
+			claimKey := client.ObjectKey{
+				Name:      metal3DataName + "-pool-v4",
+				Namespace: namespaceName,
+			}
+			if tc.capiPool {
+				claim := &caipamv1.IPAddressClaim{}
+				Expect(fakeClient.Get(context.TODO(), claimKey, claim)).To(Succeed())
+				Expect(claim.Spec.PoolRef.Name).To(Equal("pool-v4"))
+				Expect(claim.Spec.PoolRef.Kind).To(Equal("InClusterIPPool"))
+				Expect(claim.Finalizers).To(ContainElement(infrav1.DataFinalizer))
+
+				address := &caipamv1.IPAddress{
+					ObjectMeta: testObjectMeta("pool-v4-address", namespaceName, ""),
+					Spec: caipamv1.IPAddressSpec{
+						ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+						PoolRef:  claim.Spec.PoolRef,
+						Address:  "192.168.0.14",
+						Prefix:   24,
+						Gateway:  "192.168.0.1",
+					},
+				}
+				Expect(fakeClient.Create(context.TODO(), address)).To(Succeed())
+				claim.Status.AddressRef = corev1.LocalObjectReference{Name: address.Name}
+				Expect(fakeClient.Update(context.TODO(), claim)).To(Succeed())
+			} else {
+				ipClaim := &ipamv1.IPClaim{}
+				Expect(fakeClient.Get(context.TODO(), claimKey, ipClaim)).To(Succeed())
+				Expect(ipClaim.Spec.Pool.Name).To(Equal("pool-v4"))
+
+				ipAddress := &ipamv1.IPAddress{
+					ObjectMeta: testObjectMeta("pool-v4-address", namespaceName, ""),
+					Spec: ipamv1.IPAddressSpec{
+						Address: ipamv1.IPAddressStr("192.168.0.14"),
+						Prefix:  24,
+						Gateway: (*ipamv1.IPAddressStr)(pointer.String("192.168.0.1")),
+					},
+				}
+				Expect(fakeClient.Create(context.TODO(), ipAddress)).To(Succeed())
+				ipClaim.Status.Address = &corev1.ObjectReference{Name: ipAddress.Name}
+				Expect(fakeClient.Update(context.TODO(), ipClaim)).To(Succeed())
+			}
+
+			Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+			Expect(m3d.Status.Ready).To(BeTrue())
+
+			tmpSecret := corev1.Secret{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      m3d.Spec.MetaData.Name,
+				Namespace: namespaceName,
+			}, &tmpSecret)).To(Succeed())
+			Expect(string(tmpSecret.Data["metaData"])).To(Equal(fmt.Sprintf(
+				"local-gateway: 192.168.0.1\nlocal-ipv4: 192.168.0.14\nlocal-prefix: \"24\"\nproviderid: %s\n", providerid,
+			)))
+
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      m3d.Spec.NetworkData.Name,
+				Namespace: namespaceName,
+			}, &tmpSecret)).To(Succeed())
+			Expect(string(tmpSecret.Data["networkData"])).To(Equal("links:\n" +
+				"- ethernet_mac_address: XX:XX:XX:XX:XX:XX\n  id: eth0\n  mtu: 1500\n  type: phy\n" +
+				"networks:\n" +
+				"- id: eth0-v4\n  ip_address: 192.168.0.14\n  link: eth0\n  netmask: 255.255.255.0\n  routes: []\n  type: ipv4\n" +
+				"services: []\n",
+			))
+
+			// Deleting the Metal3Data releases the claim.
+			Expect(dataMgr.ReleaseLeases(context.TODO())).To(Succeed())
+			var claim client.Object = &ipamv1.IPClaim{}
+			if tc.capiPool {
+				claim = &caipamv1.IPAddressClaim{}
+			}
+			err = fakeClient.Get(context.TODO(), claimKey, claim)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		},
+		Entry("Metal3 IPPool", testCasePoolKinds{
+			fromPool: infrav1.FromPool{
+				Name: "pool-v4",
+			},
+			ipv4Network: infrav1.NetworkDataIPv4{
+				ID:                  "eth0-v4",
+				Link:                "eth0",
+				IPAddressFromIPPool: "pool-v4",
+			},
+		}),
+		Entry("CAPI IPAM pool", testCasePoolKinds{
+			fromPool: infrav1.FromPool{
+				Name:     "pool-v4",
+				APIGroup: "ipam.cluster.x-k8s.io",
+				Kind:     "InClusterIPPool",
+			},
+			ipv4Network: infrav1.NetworkDataIPv4{
+				ID:   "eth0-v4",
+				Link: "eth0",
+				FromPoolRef: &corev1.TypedLocalObjectReference{
+					APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
+					Kind:     "InClusterIPPool",
+					Name:     "pool-v4",
+				},
+			},
+			capiPool: true,
+		}),
+	)
+
+	DescribeTable("Re-renders the secrets when the template changes",
+		func(rerender bool) {
+			m3d := &infrav1.Metal3Data{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Metal3Data",
+					APIVersion: infrav1.GroupVersion.String(),
+				},
+				ObjectMeta: testObjectMetaWithOR(metal3DataName, metal3machineName),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(metal3DataTemplateName),
+					Claim:    *testObjectReference(metal3DataClaimName),
+				},
+			}
+			m3dt := &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					RerenderOnTemplateChange: rerender,
+					MetaData: &infrav1.MetaData{
+						IPAddressesFromPool: []infrav1.FromPool{{Key: "local-ipv4", Name: "pool-v4"}},
+					},
+					NetworkData: &infrav1.NetworkData{
+						Links: infrav1.NetworkDataLink{
+							Ethernets: []infrav1.NetworkDataLinkEthernet{
+								{
+									Type: "phy",
+									Id:   "eth0",
+									MTU:  1500,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										String: pointer.String("XX:XX:XX:XX:XX:XX"),
+									},
+								},
+							},
+						},
+						Networks: infrav1.NetworkDataNetwork{
+							IPv4: []infrav1.NetworkDataIPv4{{
+								ID:                  "eth0-v4",
+								Link:                "eth0",
+								IPAddressFromIPPool: "pool-v4",
+							}},
+						},
+					},
+				},
+			}
+			m3dt.Generation = 1
+			m3m := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					UID:       m3muid,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							Name:       machineName,
+							Kind:       "Machine",
+							APIVersion: clusterv1.GroupVersion.String(),
+						},
+					},
+					Annotations: map[string]string{
+						"metal3.io/BareMetalHost": namespaceName + "/" + baremetalhostName,
+					},
+				},
+				Spec: infrav1.Metal3MachineSpec{
+					DataTemplate: testObjectReference(metal3DataTemplateName),
+				},
+			}
+			ipAddress := &ipamv1.IPAddress{
+				ObjectMeta: testObjectMeta("pool-v4-address", namespaceName, ""),
+				Spec: ipamv1.IPAddressSpec{
+					Address: ipamv1.IPAddressStr("192.168.0.14"),
+					Prefix:  24,
+				},
+			}
+			objects := []client.Object{
+				m3dt,
+				m3m,
+				ipAddress,
+				&infrav1.Metal3DataClaim{
+					ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+				},
+				&clusterv1.Machine{
+					ObjectMeta: testObjectMeta(machineName, namespaceName, muid),
+				},
+				&bmov1alpha1.BareMetalHost{
+					ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid),
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			// The first pass creates the claim, allocated before the second one.
+			err = dataMgr.createSecrets(context.TODO())
+			Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+			claimKey := client.ObjectKey{Name: metal3DataName + "-pool-v4", Namespace: namespaceName}
+			ipClaim := &ipamv1.IPClaim{}
+			Expect(fakeClient.Get(context.TODO(), claimKey, ipClaim)).To(Succeed())
+			ipClaim.Status.Address = &corev1.ObjectReference{Name: ipAddress.Name}
+			Expect(fakeClient.Update(context.TODO(), ipClaim)).To(Succeed())
+			Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+			Expect(m3d.Status.RenderedTemplateGeneration).To(Equal(int64(1)))
+
+			getSecret := func(ref *corev1.SecretReference, key string) string {
+				secret := corev1.Secret{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+					Name:      ref.Name,
+					Namespace: namespaceName,
+				}, &secret)).To(Succeed())
+				return string(secret.Data[key])
+			}
+			metaData := getSecret(m3d.Spec.MetaData, "metaData")
+			networkData := getSecret(m3d.Spec.NetworkData, "networkData")
+			Expect(networkData).To(ContainSubstring("ip_address: 192.168.0.14"))
+			Expect(networkData).To(ContainSubstring("services: []"))
+
+			// Add a DNS server and a metadata entry to the template.
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(m3dt), m3dt)).To(Succeed())
+			m3dt.Spec.NetworkData.Services.DNS = []ipamv1.IPAddressStr{"8.8.8.8"}
+			m3dt.Spec.MetaData.Strings = []infrav1.MetaDataString{{Key: "role", Value: "worker"}}
+			m3dt.Generation = 2
+			Expect(fakeClient.Update(context.TODO(), m3dt)).To(Succeed())
+			Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+			Expect(m3d.Status.Ready).To(BeTrue())
+
+			if !rerender {
+				Expect(getSecret(m3d.Spec.MetaData, "metaData")).To(Equal(metaData))
+				Expect(getSecret(m3d.Spec.NetworkData, "networkData")).To(Equal(networkData))
+				Expect(m3d.Status.RenderedTemplateGeneration).To(Equal(int64(1)))
+				return
+			}
+			Expect(getSecret(m3d.Spec.MetaData, "metaData")).To(Equal(fmt.Sprintf(
+				"local-ipv4: 192.168.0.14\nproviderid: %s\nrole: worker\n", providerid,
+			)))
+			// The claimed address is kept.
+			rerendered := getSecret(m3d.Spec.NetworkData, "networkData")
+			Expect(rerendered).To(ContainSubstring("ip_address: 192.168.0.14"))
+			Expect(rerendered).To(ContainSubstring("services:\n- address: 8.8.8.8\n  type: dns\n"))
+			Expect(m3d.Status.RenderedTemplateGeneration).To(Equal(int64(2)))
+			ipClaims := &ipamv1.IPClaimList{}
+			Expect(fakeClient.List(context.TODO(), ipClaims)).To(Succeed())
+			Expect(ipClaims.Items).To(HaveLen(1))
+
+			// The secrets are not rendered again for the same generation.
+			Expect(fakeClient.Delete(context.TODO(), ipAddress)).To(Succeed())
+			Expect(dataMgr.createSecrets(context.TODO())).To(Succeed())
+			Expect(getSecret(m3d.Spec.NetworkData, "networkData")).To(Equal(rerendered))
+		},
+		Entry("Template with re-render", true),
+		Entry("Template without re-render", false),
+	)
+
+	type testCaseRenderedDataTooLarge struct {
+		value          string
+		createErr      error
+		expectReason   string
+		expectTerminal bool
+	}
+
+	DescribeTable("Stops retrying when the rendered data is too large",
+		func(tc testCaseRenderedDataTooLarge) {
+			m3d := &infrav1.Metal3Data{
+				ObjectMeta: testObjectMetaWithOR(metal3DataName, metal3machineName),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(metal3DataTemplateName),
+					Claim:    *testObjectReference(metal3DataClaimName),
+				},
+			}
+			m3dt := &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, m3dtuid),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						Strings: []infrav1.MetaDataString{{Key: "payload", Value: tc.value}},
+					},
+				},
+			}
+			objects := []client.Object{
+				m3dt,
+				&infrav1.Metal3Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      metal3machineName,
+						Namespace: namespaceName,
+						UID:       m3muid,
+						OwnerReferences: []metav1.OwnerReference{{
+							Name:       machineName,
+							Kind:       "Machine",
+							APIVersion: clusterv1.GroupVersion.String(),
+						}},
+						Annotations: map[string]string{
+							"metal3.io/BareMetalHost": namespaceName + "/" + baremetalhostName,
+						},
+					},
+					Spec: infrav1.Metal3MachineSpec{
+						DataTemplate: testObjectReference(metal3DataTemplateName),
+					},
+				},
+				&infrav1.Metal3DataClaim{
+					ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+				},
+				&clusterv1.Machine{
+					ObjectMeta: testObjectMeta(machineName, namespaceName, muid),
+				},
+				&bmov1alpha1.BareMetalHost{
+					ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid),
+				},
+			}
+			createErr := tc.createErr
+			creates := 0
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						creates++
+						if createErr != nil {
+							return createErr
+						}
+						return c.Create(ctx, obj, opts...)
+					},
+				}).Build()
+			dataMgr, err := NewDataManager(fakeClient, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = dataMgr.Reconcile(context.TODO())
+			Expect(err).To(HaveOccurred())
+			Expect(m3d.Status.ErrorMessage).NotTo(BeNil())
+			var reconcileError ReconcileError
+			if !tc.expectTerminal {
+				Expect(errors.As(err, &reconcileError)).To(BeFalse())
+				Expect(conditions.Has(m3d, infrav1.RenderedDataSizeValidCondition)).To(BeFalse())
+				return
+			}
+			Expect(errors.As(err, &reconcileError)).To(BeTrue())
+			Expect(reconcileError.IsTerminal()).To(BeTrue())
+			condition := conditions.Get(m3d, infrav1.RenderedDataSizeValidCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(tc.expectReason))
+			Expect(condition.Message).To(MatchRegexp(`secret %s of [0-9]+ bytes`, m3d.Spec.MetaData.Name))
+			if tc.createErr == nil {
+				// The secret is not written when it exceeds the size limit.
+				Expect(creates).To(BeZero())
+			}
+
+			// The condition is set to true once the secret is written.
+			createErr = nil
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(m3dt), m3dt)).To(Succeed())
+			m3dt.Spec.MetaData.Strings[0].Value = "small"
+			Expect(fakeClient.Update(context.TODO(), m3dt)).To(Succeed())
+			Expect(dataMgr.Reconcile(context.TODO())).To(Succeed())
+			Expect(conditions.IsTrue(m3d, infrav1.RenderedDataSizeValidCondition)).To(BeTrue())
+			Expect(m3d.Status.ErrorMessage).To(BeNil())
+		},
+		Entry("Secret exceeding the size limit", testCaseRenderedDataTooLarge{
+			value:          strings.Repeat("x", corev1.MaxSecretSize),
+			expectReason:   infrav1.SecretSizeLimitExceededReason,
+			expectTerminal: true,
+		}),
+		Entry("Secret rejected with RequestEntityTooLarge", testCaseRenderedDataTooLarge{
+			value:          "large",
+			createErr:      apierrors.NewRequestEntityTooLargeError("limit is 3145728"),
+			expectReason:   infrav1.SecretRejectedReason,
+			expectTerminal: true,
+		}),
+		Entry("Secret rejected as invalid for its size", testCaseRenderedDataTooLarge{
+			value: "large",
+			createErr: apierrors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Secret").GroupKind(), "metadata",
+				field.ErrorList{field.TooLong(field.NewPath("data"), "", corev1.MaxSecretSize)},
+			),
+			expectReason:   infrav1.SecretRejectedReason,
+			expectTerminal: true,
+		}),
+		Entry("Secret rejected as invalid for another reason", testCaseRenderedDataTooLarge{
+			value: "large",
+			createErr: apierrors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Secret").GroupKind(), "metadata",
+				field.ErrorList{field.Invalid(field.NewPath("type"), "", "invalid type")},
+			),
+		}),
+	)
+
+	type testCaseReleaseLeases struct {
+		m3d           *infrav1.Metal3Data
+		m3dt          *infrav1.Metal3DataTemplate
+		expectError   bool
+		expectRequeue bool
+	}
+
+	DescribeTable("Test ReleaseLeases",
+		func(tc testCaseReleaseLeases) {
+			objects := []client.Object{}
+			if tc.m3dt != nil {
+				objects = append(objects, tc.m3dt)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			dataMgr, err := NewDataManager(fakeClient, tc.m3d,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			err = dataMgr.ReleaseLeases(context.TODO())
+			if tc.expectError || tc.expectRequeue {
+				Expect(err).To(HaveOccurred())
+				if tc.expectRequeue {
+					Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+				} else {
+					Expect(err).NotTo(BeAssignableToTypeOf(ReconcileError{}))
+				}
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		},
+		Entry("Empty spec", testCaseReleaseLeases{
+			m3d: &infrav1.Metal3Data{},
+		}),
+		Entry("M3dt not found", testCaseReleaseLeases{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Template: corev1.ObjectReference{
+						Name: metal3DataTemplateName,
+					},
+				},
+			},
+			expectRequeue: true,
+		}),
+		Entry("M3dt found", testCaseReleaseLeases{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Template: corev1.ObjectReference{
+						Name: metal3DataTemplateName,
+					},
+				},
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, ""),
+			},
+		}),
+	)
+
+	type testCaseWaitForHostRelease struct {
+		m3dAnnotations  map[string]string
+		hostRef         bool
+		hostLabel       bool
+		noHost          bool
+		hostMetaData    *corev1.SecretReference
+		hostNetworkData *corev1.SecretReference
+		hostUserData    *corev1.SecretReference
+		expectRequeue   bool
+	}
+
+	DescribeTable("Test WaitForHostRelease",
+		func(tc testCaseWaitForHostRelease) {
+			now := metav1.Now()
+			m3d := &infrav1.Metal3Data{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              metal3DataName,
+					Namespace:         namespaceName,
+					Annotations:       tc.m3dAnnotations,
+					DeletionTimestamp: &now,
+				},
+				Spec: infrav1.Metal3DataSpec{
+					Claim: *testObjectReference(metal3DataClaimName),
+					MetaData: &corev1.SecretReference{
+						Name: metal3machineName + "-metadata",
+					},
+					NetworkData: &corev1.SecretReference{
+						Name: metal3machineName + "-networkdata",
+					},
+				},
+			}
+			if tc.hostRef {
+				m3d.Status.HostRef = &corev1.ObjectReference{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+				}
+			}
+			var bmh *bmov1alpha1.BareMetalHost
+			if !tc.noHost {
+				bmh = &bmov1alpha1.BareMetalHost{
+					ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, ""),
+					Spec: bmov1alpha1.BareMetalHostSpec{
+						MetaData:    tc.hostMetaData,
+						NetworkData: tc.hostNetworkData,
+						UserData:    tc.hostUserData,
+					},
+					Status: bmov1alpha1.BareMetalHostStatus{
+						Provisioning: bmov1alpha1.ProvisionStatus{
+							State: bmov1alpha1.StateProvisioned,
+						},
+					},
+				}
+				if tc.hostLabel {
+					bmh.Labels = map[string]string{infrav1.DataSecretsOwnerLabel: metal3DataName}
+				}
+			}
+			dataMgr, err := NewDataManager(fakeClient(bmh), m3d,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = dataMgr.WaitForHostRelease(context.TODO())
+			if tc.expectRequeue {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+				Expect(conditions.IsTrue(m3d, infrav1.HostDataInUseCondition)).To(BeTrue())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conditions.Has(m3d, infrav1.HostDataInUseCondition)).To(BeFalse())
+			}
+		},
+		Entry("Labeled host referencing the metaData", testCaseWaitForHostRelease{
+			hostLabel:     true,
+			hostMetaData:  &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+			expectRequeue: true,
+		}),
+		Entry("Labeled host referencing the networkData", testCaseWaitForHostRelease{
+			hostLabel:       true,
+			hostNetworkData: &corev1.SecretReference{Name: metal3machineName + "-networkdata", Namespace: namespaceName},
+			expectRequeue:   true,
+		}),
+		Entry("Recorded host referencing the secrets in userData", testCaseWaitForHostRelease{
+			hostRef:       true,
+			hostUserData:  &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+			expectRequeue: true,
+		}),
+		Entry("Host referencing the secrets with the force annotation", testCaseWaitForHostRelease{
+			m3dAnnotations: map[string]string{infrav1.DataForceDeleteAnnotation: ""},
+			hostLabel:      true,
+			hostMetaData:   &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+		}),
+		Entry("Host references cleared", testCaseWaitForHostRelease{
+			hostRef:   true,
+			hostLabel: true,
+		}),
+		Entry("Host referencing other secrets", testCaseWaitForHostRelease{
+			hostRef:         true,
+			hostMetaData:    &corev1.SecretReference{Name: "other-metadata"},
+			hostNetworkData: &corev1.SecretReference{Name: metal3machineName + "-networkdata", Namespace: "other"},
+		}),
+		Entry("Host neither labeled nor recorded", testCaseWaitForHostRelease{
+			hostMetaData: &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+		}),
+		Entry("Recorded host not found", testCaseWaitForHostRelease{
+			hostRef: true,
+			noHost:  true,
+		}),
+	)
+
+	It("Keeps the secrets until the host releases them when the Metal3Machine is deleted first", func() {
+		now := metav1.Now()
+		m3d := &infrav1.Metal3Data{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              metal3DataName,
+				Namespace:         namespaceName,
+				DeletionTimestamp: &now,
+				Finalizers:        []string{infrav1.DataFinalizer},
+			},
+			Spec: infrav1.Metal3DataSpec{
+				Claim: *testObjectReference(metal3DataClaimName),
+				NetworkData: &corev1.SecretReference{
+					Name: metal3machineName + "-networkdata",
+				},
+			},
+			Status: infrav1.Metal3DataStatus{
+				HostRef: &corev1.ObjectReference{Name: baremetalhostName, Namespace: namespaceName},
+			},
+		}
+		secret := newMetal3Secret(metal3machineName+"-networkdata", namespaceName, nil, nil, nil)
+		secret.Finalizers = []string{infrav1.DataFinalizer}
+		// The Metal3Machine is already gone, the host was not deprovisioned.
+		bmh := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+				Labels:    map[string]string{infrav1.DataSecretsOwnerLabel: metal3DataName},
+			},
+			Spec: bmov1alpha1.BareMetalHostSpec{
+				NetworkData: &corev1.SecretReference{Name: metal3machineName + "-networkdata"},
+			},
+		}
+		c := fakeClient(secret, bmh)
+		dataMgr, err := NewDataManager(c, m3d, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = dataMgr.WaitForHostRelease(context.TODO())
+		Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+		Expect(conditions.IsTrue(m3d, infrav1.HostDataInUseCondition)).To(BeTrue())
+
+		// The host releases the secrets.
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(bmh), bmh)).To(Succeed())
+		bmh.Spec.NetworkData = nil
+		delete(bmh.Labels, infrav1.DataSecretsOwnerLabel)
+		Expect(c.Update(context.TODO(), bmh)).To(Succeed())
+
+		Expect(dataMgr.WaitForHostRelease(context.TODO())).To(Succeed())
+		Expect(conditions.Has(m3d, infrav1.HostDataInUseCondition)).To(BeFalse())
+		Expect(dataMgr.ReleaseSecrets(context.TODO())).To(Succeed())
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		Expect(secret.Finalizers).To(BeEmpty())
+	})
+
+	It("Keeps the secrets until the host releases them when the namespace is deleted", func() {
+		now := metav1.Now()
+		m3d := &infrav1.Metal3Data{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              metal3DataName,
+				Namespace:         namespaceName,
+				DeletionTimestamp: &now,
+				Finalizers:        []string{infrav1.DataFinalizer},
+			},
+			Spec: infrav1.Metal3DataSpec{
+				Claim: *testObjectReference(metal3DataClaimName),
+				MetaData: &corev1.SecretReference{
+					Name: metal3machineName + "-metadata",
+				},
+				NetworkData: &corev1.SecretReference{
+					Name: metal3machineName + "-networkdata",
+				},
+			},
+		}
+		// All the objects of the namespace are being deleted.
+		secrets := []*corev1.Secret{
+			newMetal3Secret(metal3machineName+"-metadata", namespaceName, nil, nil, nil),
+			newMetal3Secret(metal3machineName+"-networkdata", namespaceName, nil, nil, nil),
+		}
+		for _, secret := range secrets {
+			secret.DeletionTimestamp = &now
+			secret.Finalizers = []string{infrav1.DataFinalizer}
+		}
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              metal3machineName,
+				Namespace:         namespaceName,
+				DeletionTimestamp: &now,
+				Finalizers:        []string{infrav1.MachineFinalizer},
+			},
+		}
+		bmh := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              baremetalhostName,
+				Namespace:         namespaceName,
+				Labels:            map[string]string{infrav1.DataSecretsOwnerLabel: metal3DataName},
+				DeletionTimestamp: &now,
+				Finalizers:        []string{bmov1alpha1.BareMetalHostFinalizer},
+			},
+			Spec: bmov1alpha1.BareMetalHostSpec{
+				MetaData:    &corev1.SecretReference{Name: metal3machineName + "-metadata"},
+				NetworkData: &corev1.SecretReference{Name: metal3machineName + "-networkdata"},
+			},
+		}
+		c := fakeClient(secrets[0], secrets[1], m3m, bmh)
+		dataMgr, err := NewDataManager(c, m3d, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = dataMgr.WaitForHostRelease(context.TODO())
+		Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+		for _, secret := range secrets {
+			Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(secret), &corev1.Secret{})).To(Succeed())
+		}
+
+		// The Metal3Machine clears the references while deprovisioning.
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(bmh), bmh)).To(Succeed())
+		bmh.Spec.MetaData = nil
+		bmh.Spec.NetworkData = nil
+		delete(bmh.Labels, infrav1.DataSecretsOwnerLabel)
+		Expect(c.Update(context.TODO(), bmh)).To(Succeed())
+
+		Expect(dataMgr.WaitForHostRelease(context.TODO())).To(Succeed())
+		Expect(dataMgr.ReleaseSecrets(context.TODO())).To(Succeed())
+		for _, secret := range secrets {
+			err := c.Get(context.TODO(), client.ObjectKeyFromObject(secret), &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+	})
+
+	DescribeTable("Test ReleaseSecrets",
+		func(secret *corev1.Secret) {
+			m3d := &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, m3duid),
+				Spec: infrav1.Metal3DataSpec{
+					MetaData: &corev1.SecretReference{
+						Name: metal3machineName + "-metadata",
+					},
+				},
+			}
+			c := fakeClient(secret)
+			dataMgr, err := NewDataManager(c, m3d, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dataMgr.ReleaseSecrets(context.TODO())).To(Succeed())
+			if secret != nil {
+				Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+				Expect(secret.Finalizers).To(Equal([]string{"other"}))
+			}
+		},
+		Entry("Secret not found", nil),
+		Entry("Secret with the finalizer", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       metal3machineName + "-metadata",
+				Namespace:  namespaceName,
+				Finalizers: []string{"other", infrav1.DataFinalizer},
+			},
+		}),
+		Entry("Secret without the finalizer", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       metal3machineName + "-metadata",
+				Namespace:  namespaceName,
+				Finalizers: []string{"other"},
+			},
+		}),
+	)
+
+	type testCaseGetAddressesFromPool struct {
+		m3dtSpec      infrav1.Metal3DataTemplateSpec
+		m3IPClaims    []string
+		ipClaims      []string
+		expectError   bool
+		expectRequeue bool
+	}
+
+	DescribeTable("Test GetAddressesFromPool",
+		func(tc testCaseGetAddressesFromPool) {
+			objects := []client.Object{}
+			for _, poolName := range tc.m3IPClaims {
+				pool := &ipamv1.IPClaim{
+					ObjectMeta: testObjectMeta(metal3DataName+"-"+poolName, namespaceName, ""),
+					Spec: ipamv1.IPClaimSpec{
+						Pool: *testObjectReference("abc"),
+					},
+				}
+				objects = append(objects, pool)
+			}
+			for _, poolName := range tc.ipClaims {
+				claim := &caipamv1.IPAddressClaim{
+					ObjectMeta: testObjectMeta(metal3DataName+"-"+poolName, namespaceName, ""),
+					Spec: caipamv1.IPAddressClaimSpec{
+						PoolRef: corev1.TypedLocalObjectReference{
+							Name:     "abc",
+							APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
+							Kind:     "TestPool",
+						},
+					},
+				}
+				objects = append(objects, claim)
+			}
+			m3d := &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Template: *testObjectReference(metal3DataTemplateName),
+				},
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Metal3Data",
+					APIVersion: infrav1.GroupVersion.String(),
+				},
+			}
+			m3dt := infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, ""),
+				Spec:       tc.m3dtSpec,
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			dataMgr, err := NewDataManager(fakeClient, m3d,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			poolAddresses, err := dataMgr.getAddressesFromPool(context.TODO(), m3dt, &bmov1alpha1.BareMetalHost{
+				ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, ""),
+			})
+			if tc.expectError || tc.expectRequeue {
+				Expect(err).To(HaveOccurred())
+				if tc.expectRequeue {
+					Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+				} else {
+					Expect(err).NotTo(BeAssignableToTypeOf(ReconcileError{}))
+				}
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			expectedPoolAddress := make(map[string]addressFromPool)
+			for _, poolName := range tc.m3IPClaims {
+				expectedPoolAddress[poolName] = addressFromPool{}
+			}
+			for _, poolName := range tc.ipClaims {
+				expectedPoolAddress[poolName] = addressFromPool{}
+			}
+			Expect(expectedPoolAddress).To(Equal(poolAddresses))
+		},
+		Entry("Metadata ok", testCaseGetAddressesFromPool{
+			m3dtSpec: infrav1.Metal3DataTemplateSpec{
+				MetaData: &infrav1.MetaData{
+					IPAddressesFromPool: []infrav1.FromPool{
+						{
+							Key:  "Address-1",
+							Name: "abcd-1",
+						},
+					},
+					PrefixesFromPool: []infrav1.FromPool{
+						{
+							Key:  "Prefix-1",
+							Name: "abcd-2",
+						},
+					},
+					GatewaysFromPool: []infrav1.FromPool{
+						{
+							Key:  "Gateway-1",
+							Name: "abcd-3",
+						},
+					},
+				},
+				NetworkData: &infrav1.NetworkData{
+					Networks: infrav1.NetworkDataNetwork{
+						IPv4: []infrav1.NetworkDataIPv4{
+							{
+								IPAddressFromIPPool: "abcd-4",
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("abcd-5"),
+										},
+									},
+								},
+							},
+						},
+						IPv6: []infrav1.NetworkDataIPv6{
+							{
+								IPAddressFromIPPool: "abcd-6",
+								Routes: []infrav1.NetworkDataRoutev6{
+									{
+										Gateway: infrav1.NetworkGatewayv6{
+											FromIPPool: pointer.String("abcd-7"),
+										},
+									},
+								},
+							},
+						},
+						IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+							{
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("abcd-8"),
+										},
+									},
+								},
+							},
+						},
+						IPv6DHCP: []infrav1.NetworkDataIPv6DHCP{
+							{
+								Routes: []infrav1.NetworkDataRoutev6{
+									{
+										Gateway: infrav1.NetworkGatewayv6{
+											FromIPPool: pointer.String("abcd-9"),
+										},
+									},
+								},
+							},
+						},
+						IPv6SLAAC: []infrav1.NetworkDataIPv6DHCP{
+							{
+								Routes: []infrav1.NetworkDataRoutev6{
+									{
+										Gateway: infrav1.NetworkGatewayv6{
+											FromIPPool: pointer.String("abcd-10"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			m3IPClaims: []string{
+				"abcd-1",
+				"abcd-2",
+				"abcd-3",
+				"abcd-4",
+				"abcd-5",
+				"abcd-6",
+				"abcd-7",
+				"abcd-8",
+				"abcd-9",
+				"abcd-10",
+			},
+			expectRequeue: true,
+		}),
+		Entry("IPAddressesFromPool", testCaseGetAddressesFromPool{
+			m3dtSpec: infrav1.Metal3DataTemplateSpec{
+				MetaData: &infrav1.MetaData{
+					IPAddressesFromPool: []infrav1.FromPool{
+						{
+							Key:  "Address-1",
+							Name: "abcd",
+						},
+					},
+				},
+				NetworkData: &infrav1.NetworkData{},
+			},
+			m3IPClaims: []string{
+				"abcd",
+			},
+			expectRequeue: true,
+		}),
+		Entry("PrefixesFromPool", testCaseGetAddressesFromPool{
+			m3dtSpec: infrav1.Metal3DataTemplateSpec{
+				MetaData: &infrav1.MetaData{
+					PrefixesFromPool: []infrav1.FromPool{
+						{
 							Key:  "Prefix-1",
 							Name: "abcd",
 						},
@@ -1937,67 +3242,550 @@ var _ = Describe("Metal3Data manager", func() {
 								},
 							},
 						},
-						"type":    "ipv4",
-						"id":      "abc",
-						"link":    "def",
-						"netmask": "255.255.255.0",
-					},
-				},
-			},
-		}),
-		Entry("Error in link", testCaseRenderNetworkData{
-			m3dt: &infrav1.Metal3DataTemplate{
-				Spec: infrav1.Metal3DataTemplateSpec{
-					NetworkData: &infrav1.NetworkData{
-						Links: infrav1.NetworkDataLink{
-							Ethernets: []infrav1.NetworkDataLinkEthernet{
-								{
-									Type: "phy",
-									Id:   "eth0",
-									MTU:  1500,
-									MACAddress: &infrav1.NetworkLinkEthernetMac{
-										FromHostInterface: pointer.String("eth0"),
-									},
-								},
+						"type":    "ipv4",
+						"id":      "abc",
+						"link":    "def",
+						"netmask": "255.255.255.0",
+					},
+				},
+			},
+		}),
+		Entry("Ethernet with vendor extensions", testCaseRenderNetworkData{
+			m3dt: &infrav1.Metal3DataTemplate{
+				Spec: infrav1.Metal3DataTemplateSpec{
+					NetworkData: &infrav1.NetworkData{
+						Links: infrav1.NetworkDataLink{
+							Ethernets: []infrav1.NetworkDataLinkEthernet{
+								{
+									Type: "phy",
+									Id:   "eth0",
+									MTU:  1500,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										String: pointer.String("XX:XX:XX:XX:XX:XX"),
+									},
+									VendorExtensions: map[string]string{
+										"sriov_numvfs": "8",
+										"sriov_trust":  "on",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedOutput: map[string][]interface{}{
+				"services": {},
+				"links": {
+					map[interface{}]interface{}{
+						"type":                 "phy",
+						"id":                   "eth0",
+						"mtu":                  1500,
+						"ethernet_mac_address": "XX:XX:XX:XX:XX:XX",
+						"extra": map[interface{}]interface{}{
+							"sriov_numvfs": "8",
+							"sriov_trust":  "on",
+						},
+					},
+				},
+				"networks": {},
+			},
+		}),
+		Entry("Default routes of two links with priorities", testCaseRenderNetworkData{
+			m3dt: &infrav1.Metal3DataTemplate{
+				Spec: infrav1.Metal3DataTemplateSpec{
+					NetworkData: &infrav1.NetworkData{
+						Links: infrav1.NetworkDataLink{
+							Ethernets: []infrav1.NetworkDataLinkEthernet{
+								{
+									Type: "phy",
+									Id:   "mgmt",
+									MTU:  1500,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										String: pointer.String("XX:XX:XX:XX:XX:XX"),
+									},
+									DefaultRoutePriority: pointer.Int(0),
+								},
+								{
+									Type: "phy",
+									Id:   "storage",
+									MTU:  9000,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										String: pointer.String("YY:YY:YY:YY:YY:YY"),
+									},
+									DefaultRoutePriority: pointer.Int(10),
+								},
+							},
+						},
+						Networks: infrav1.NetworkDataNetwork{
+							IPv4: []infrav1.NetworkDataIPv4{
+								{
+									ID:                  "storage-v4",
+									Link:                "storage",
+									IPAddressFromIPPool: "abc",
+									Routes: []infrav1.NetworkDataRoutev4{
+										{
+											Network: "0.0.0.0",
+											Gateway: infrav1.NetworkGatewayv4{
+												String: (*ipamv1.IPAddressv4Str)(pointer.String("192.168.0.1")),
+											},
+										},
+										{
+											Network: "10.0.0.0",
+											Prefix:  16,
+											Gateway: infrav1.NetworkGatewayv4{
+												String: (*ipamv1.IPAddressv4Str)(pointer.String("192.168.0.254")),
+											},
+										},
+									},
+								},
+							},
+							IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+								{
+									ID:   "mgmt-v4",
+									Link: "mgmt",
+									Routes: []infrav1.NetworkDataRoutev4{
+										{
+											Network: "0.0.0.0",
+											Gateway: infrav1.NetworkGatewayv4{
+												String: (*ipamv1.IPAddressv4Str)(pointer.String("172.22.0.1")),
+											},
+										},
+									},
+								},
+							},
+							IPv6SLAAC: []infrav1.NetworkDataIPv6DHCP{
+								{
+									ID:   "storage-v6",
+									Link: "storage",
+									Routes: []infrav1.NetworkDataRoutev6{
+										{
+											Network: "::",
+											Gateway: infrav1.NetworkGatewayv6{
+												String: (*ipamv1.IPAddressv6Str)(pointer.String("fd00::1")),
+											},
+											Metric: pointer.Int(50),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			poolAddresses: map[string]addressFromPool{
+				"abc": {
+					Address: "192.168.0.14",
+					Prefix:  24,
+				},
+			},
+			expectedOutput: map[string][]interface{}{
+				"services": {},
+				"links": {
+					map[interface{}]interface{}{
+						"type":                 "phy",
+						"id":                   "mgmt",
+						"mtu":                  1500,
+						"ethernet_mac_address": "XX:XX:XX:XX:XX:XX",
+					},
+					map[interface{}]interface{}{
+						"type":                 "phy",
+						"id":                   "storage",
+						"mtu":                  9000,
+						"ethernet_mac_address": "YY:YY:YY:YY:YY:YY",
+					},
+				},
+				"networks": {
+					map[interface{}]interface{}{
+						"ip_address": "192.168.0.14",
+						"routes": []interface{}{
+							map[interface{}]interface{}{
+								"network":  "0.0.0.0",
+								"netmask":  "0.0.0.0",
+								"gateway":  "192.168.0.1",
+								"services": []interface{}{},
+								"metric":   200,
+							},
+							map[interface{}]interface{}{
+								"network":  "10.0.0.0",
+								"netmask":  "255.255.0.0",
+								"gateway":  "192.168.0.254",
+								"services": []interface{}{},
+							},
+						},
+						"type":    "ipv4",
+						"id":      "storage-v4",
+						"link":    "storage",
+						"netmask": "255.255.255.0",
+					},
+					map[interface{}]interface{}{
+						"routes": []interface{}{
+							map[interface{}]interface{}{
+								"network":  "0.0.0.0",
+								"netmask":  "0.0.0.0",
+								"gateway":  "172.22.0.1",
+								"services": []interface{}{},
+								"metric":   100,
+							},
+						},
+						"type": "ipv4_dhcp",
+						"id":   "mgmt-v4",
+						"link": "mgmt",
+					},
+					map[interface{}]interface{}{
+						"routes": []interface{}{
+							map[interface{}]interface{}{
+								"network":  "::",
+								"netmask":  "::",
+								"gateway":  "fd00::1",
+								"services": []interface{}{},
+								"metric":   50,
+							},
+						},
+						"type": "ipv6_slaac",
+						"id":   "storage-v6",
+						"link": "storage",
+					},
+				},
+			},
+		}),
+		Entry("Error in link", testCaseRenderNetworkData{
+			m3dt: &infrav1.Metal3DataTemplate{
+				Spec: infrav1.Metal3DataTemplateSpec{
+					NetworkData: &infrav1.NetworkData{
+						Links: infrav1.NetworkDataLink{
+							Ethernets: []infrav1.NetworkDataLinkEthernet{
+								{
+									Type: "phy",
+									Id:   "eth0",
+									MTU:  1500,
+									MACAddress: &infrav1.NetworkLinkEthernetMac{
+										FromHostInterface: pointer.String("eth0"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		}),
+		Entry("Address error", testCaseRenderNetworkData{
+			m3d: &infrav1.Metal3Data{
+				Spec: infrav1.Metal3DataSpec{
+					Index: 2,
+				},
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				Spec: infrav1.Metal3DataTemplateSpec{
+					NetworkData: &infrav1.NetworkData{
+						Networks: infrav1.NetworkDataNetwork{
+							IPv4: []infrav1.NetworkDataIPv4{
+								{
+									ID:                  "abc",
+									Link:                "def",
+									IPAddressFromIPPool: "abc",
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		}),
+		Entry("Empty", testCaseRenderNetworkData{
+			m3dt: &infrav1.Metal3DataTemplate{
+				Spec: infrav1.Metal3DataTemplateSpec{
+					NetworkData: nil,
+				},
+			},
+			expectedOutput: map[string][]interface{}{},
+		}),
+	)
+
+	It("Renders the netplan network config of a representative template", func() {
+		m3dt := &infrav1.Metal3DataTemplate{
+			Spec: infrav1.Metal3DataTemplateSpec{
+				NetworkData: &infrav1.NetworkData{
+					Format: infrav1.NetworkDataFormatNetplan,
+					Links: infrav1.NetworkDataLink{
+						Ethernets: []infrav1.NetworkDataLinkEthernet{
+							{
+								Type: "phy",
+								Id:   "eth0",
+								MTU:  9000,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									FromHostInterface: pointer.String("eth0"),
+								},
+							},
+							{
+								Type: "phy",
+								Id:   "eth1",
+								MTU:  9000,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:00:01"),
+								},
+							},
+							{
+								Type: "phy",
+								Id:   "eth2",
+								MTU:  1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:00:02"),
+								},
+								DefaultRoutePriority: pointer.Int(1),
+							},
+						},
+						Bonds: []infrav1.NetworkDataLinkBond{
+							{
+								BondMode: "802.3ad",
+								Id:       "bond0",
+								MTU:      9000,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									FromHostInterface: pointer.String("eth0"),
+								},
+								BondLinks:            []string{"eth0", "eth1"},
+								DefaultRoutePriority: pointer.Int(0),
+							},
+						},
+						Vlans: []infrav1.NetworkDataLinkVlan{
+							{
+								VlanID: 100,
+								Id:     "vlan100",
+								MTU:    1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:01:00"),
+								},
+								VlanLink: "bond0",
+							},
+						},
+					},
+					Networks: infrav1.NetworkDataNetwork{
+						IPv4: []infrav1.NetworkDataIPv4{
+							{
+								ID:                  "provisioning",
+								Link:                "bond0",
+								IPAddressFromIPPool: "pool4",
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Network: "0.0.0.0",
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("pool4"),
+										},
+										Services: infrav1.NetworkDataServicev4{
+											DNSFromIPPool: pointer.String("pool4"),
+										},
+									},
+								},
+							},
+						},
+						IPv6: []infrav1.NetworkDataIPv6{
+							{
+								ID:                  "storage",
+								Link:                "vlan100",
+								IPAddressFromIPPool: "pool6",
+								Routes: []infrav1.NetworkDataRoutev6{
+									{
+										Network: "fd00:1::",
+										Prefix:  48,
+										Gateway: infrav1.NetworkGatewayv6{
+											FromIPPool: pointer.String("pool6"),
+										},
+										Metric: pointer.Int(50),
+										Services: infrav1.NetworkDataServicev6{
+											DNS: []ipamv1.IPAddressv6Str{"fd00::53"},
+										},
+									},
+								},
+							},
+						},
+						IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+							{
+								ID:   "external",
+								Link: "eth2",
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Network: "0.0.0.0",
+										Gateway: infrav1.NetworkGatewayv4{
+											String: (*ipamv1.IPAddressv4Str)(pointer.String("192.168.1.1")),
+										},
+										Services: infrav1.NetworkDataServicev4{
+											DNS: []ipamv1.IPAddressv4Str{"8.8.8.8"},
+										},
+									},
+								},
+							},
+						},
+						IPv6SLAAC: []infrav1.NetworkDataIPv6DHCP{
+							{
+								ID:   "external6",
+								Link: "eth2",
 							},
 						},
 					},
 				},
 			},
-			expectError: true,
-		}),
-		Entry("Address error", testCaseRenderNetworkData{
-			m3d: &infrav1.Metal3Data{
-				Spec: infrav1.Metal3DataSpec{
-					Index: 2,
+		}
+		bmh := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, ""),
+			Status: bmov1alpha1.BareMetalHostStatus{
+				HardwareDetails: &bmov1alpha1.HardwareDetails{
+					NIC: []bmov1alpha1.NIC{
+						{Name: "eth0", MAC: "00:00:00:00:00:00"},
+					},
 				},
 			},
-			m3dt: &infrav1.Metal3DataTemplate{
-				Spec: infrav1.Metal3DataTemplateSpec{
-					NetworkData: &infrav1.NetworkData{
-						Networks: infrav1.NetworkDataNetwork{
-							IPv4: []infrav1.NetworkDataIPv4{
-								{
-									ID:                  "abc",
-									Link:                "def",
-									IPAddressFromIPPool: "abc",
+		}
+		poolAddresses := map[string]addressFromPool{
+			"pool4": {
+				Address:    ipamv1.IPAddressStr("192.168.0.14"),
+				Prefix:     24,
+				Gateway:    ipamv1.IPAddressStr("192.168.0.1"),
+				dnsServers: []ipamv1.IPAddressStr{"192.168.0.53", "fd00::53"},
+			},
+			"pool6": {
+				Address: ipamv1.IPAddressStr("fd00:2::14"),
+				Prefix:  64,
+				Gateway: ipamv1.IPAddressStr("fd00:2::1"),
+			},
+		}
+
+		result, err := renderNetworkData(m3dt, bmh, poolAddresses)
+		Expect(err).NotTo(HaveOccurred())
+		expected, err := os.ReadFile(filepath.Join("testdata", "netplan_network_config.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(Equal(string(expected)))
+
+		data := networkDataSecretData(m3dt, result)
+		Expect(data).To(HaveKeyWithValue("networkData", result))
+		Expect(data).To(HaveKeyWithValue("network-config", result))
+	})
+
+	It("Renders the route metrics and tables of networks sharing a gateway", func() {
+		m3dt := &infrav1.Metal3DataTemplate{
+			Spec: infrav1.Metal3DataTemplateSpec{
+				NetworkData: &infrav1.NetworkData{
+					Links: infrav1.NetworkDataLink{
+						Ethernets: []infrav1.NetworkDataLinkEthernet{
+							{
+								Type: "phy",
+								Id:   "eth0",
+								MTU:  1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:00:00"),
+								},
+							},
+							{
+								Type: "phy",
+								Id:   "eth1",
+								MTU:  1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:00:01"),
+								},
+							},
+							{
+								Type: "phy",
+								Id:   "eth2",
+								MTU:  1500,
+								MACAddress: &infrav1.NetworkLinkEthernetMac{
+									String: pointer.String("00:00:00:00:00:02"),
+								},
+							},
+						},
+					},
+					Networks: infrav1.NetworkDataNetwork{
+						IPv4: []infrav1.NetworkDataIPv4{
+							{
+								ID:                  "provisioning",
+								Link:                "eth0",
+								IPAddressFromIPPool: "provisioning",
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Network: "0.0.0.0",
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("provisioning"),
+										},
+										Metric: pointer.Int(200),
+										Table:  pointer.Int(100),
+									},
+								},
+							},
+							{
+								ID:                  "workload",
+								Link:                "eth1",
+								IPAddressFromIPPool: "workload",
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Network: "0.0.0.0",
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("workload"),
+										},
+										Metric: pointer.Int(100),
+									},
+									{
+										Network: "10.0.0.0",
+										Prefix:  8,
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("workload"),
+										},
+										Metric: pointer.Int(4294967295),
+										Table:  pointer.Int(252),
+									},
+								},
+							},
+						},
+						IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+							{
+								ID:           "storage",
+								Link:         "eth2",
+								DefaultRoute: pointer.Bool(false),
+								Routes: []infrav1.NetworkDataRoutev4{
+									{
+										Network: "0.0.0.0",
+										Gateway: infrav1.NetworkGatewayv4{
+											FromIPPool: pointer.String("workload"),
+										},
+									},
+									{
+										Network: "172.16.0.0",
+										Prefix:  12,
+										Gateway: infrav1.NetworkGatewayv4{
+											String: (*ipamv1.IPAddressv4Str)(pointer.String("172.16.0.1")),
+										},
+									},
 								},
 							},
 						},
 					},
 				},
 			},
-			expectError: true,
-		}),
-		Entry("Empty", testCaseRenderNetworkData{
-			m3dt: &infrav1.Metal3DataTemplate{
-				Spec: infrav1.Metal3DataTemplateSpec{
-					NetworkData: nil,
-				},
+		}
+		poolAddresses := map[string]addressFromPool{
+			"provisioning": {
+				Address: ipamv1.IPAddressStr("192.168.0.14"),
+				Prefix:  24,
+				Gateway: ipamv1.IPAddressStr("192.168.0.1"),
 			},
-			expectedOutput: map[string][]interface{}{},
-		}),
-	)
+			"workload": {
+				Address: ipamv1.IPAddressStr("192.168.1.14"),
+				Prefix:  24,
+				Gateway: ipamv1.IPAddressStr("192.168.1.1"),
+			},
+		}
+
+		result, err := renderNetworkData(m3dt, nil, poolAddresses)
+		Expect(err).NotTo(HaveOccurred())
+		expected, err := os.ReadFile(filepath.Join("testdata", "openstack_network_data_route_metrics.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(Equal(string(expected)))
+
+		// The same routes in the netplan format.
+		m3dt.Spec.NetworkData.Format = infrav1.NetworkDataFormatNetplan
+		result, err = renderNetworkData(m3dt, nil, poolAddresses)
+		Expect(err).NotTo(HaveOccurred())
+		expected, err = os.ReadFile(filepath.Join("testdata", "netplan_network_config_route_metrics.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(Equal(string(expected)))
+	})
 
 	type testRenderNetworkServices struct {
 		services       infrav1.NetworkDataService
@@ -2104,6 +3892,32 @@ var _ = Describe("Metal3Data manager", func() {
 				},
 			},
 		}),
+		Entry("Ethernet, vendor extensions", testCaseRenderNetworkLinks{
+			links: infrav1.NetworkDataLink{
+				Ethernets: []infrav1.NetworkDataLinkEthernet{
+					{
+						Type: "hw_veb",
+						Id:   "eth0",
+						MTU:  9000,
+						MACAddress: &infrav1.NetworkLinkEthernetMac{
+							String: pointer.String("XX:XX:XX:XX:XX:XX"),
+						},
+						VendorExtensions: map[string]string{
+							"sriov_numvfs": "8",
+						},
+					},
+				},
+			},
+			expectedOutput: []interface{}{
+				map[string]interface{}{
+					"type":                 "hw_veb",
+					"id":                   "eth0",
+					"mtu":                  9000,
+					"ethernet_mac_address": "XX:XX:XX:XX:XX:XX",
+					"extra":                map[string]string{"sriov_numvfs": "8"},
+				},
+			},
+		}),
 		Entry("Ethernet, MAC error", testCaseRenderNetworkLinks{
 			links: infrav1.NetworkDataLink{
 				Ethernets: []infrav1.NetworkDataLinkEthernet{
@@ -2213,60 +4027,211 @@ var _ = Describe("Metal3Data manager", func() {
 			},
 			expectError: true,
 		}),
-	)
-
-	type testCaseRenderNetworkNetworks struct {
-		networks       infrav1.NetworkDataNetwork
-		m3d            *infrav1.Metal3Data
-		poolAddresses  map[string]addressFromPool
-		expectError    bool
-		expectedOutput []interface{}
-	}
-
-	DescribeTable("Test renderNetworkNetworks",
-		func(tc testCaseRenderNetworkNetworks) {
-			result, err := renderNetworkNetworks(tc.networks, tc.poolAddresses)
-			if tc.expectError {
-				Expect(err).To(HaveOccurred())
-				return
-			}
-			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(tc.expectedOutput))
-		},
-		Entry("IPv4 network", testCaseRenderNetworkNetworks{
+	)
+
+	type testCaseRenderNetworkNetworks struct {
+		networks       infrav1.NetworkDataNetwork
+		m3d            *infrav1.Metal3Data
+		poolAddresses  map[string]addressFromPool
+		expectError    bool
+		expectedOutput []interface{}
+	}
+
+	DescribeTable("Test renderNetworkNetworks",
+		func(tc testCaseRenderNetworkNetworks) {
+			result, err := renderNetworkNetworks(tc.networks, tc.poolAddresses, nil)
+			if tc.expectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(tc.expectedOutput))
+		},
+		Entry("IPv4 network", testCaseRenderNetworkNetworks{
+			poolAddresses: map[string]addressFromPool{
+				"abc": {
+					Address: ipamv1.IPAddressStr("192.168.0.14"),
+					Prefix:  24,
+					Gateway: ipamv1.IPAddressStr("192.168.1.1"),
+				},
+			},
+			networks: infrav1.NetworkDataNetwork{
+				IPv4: []infrav1.NetworkDataIPv4{
+					{
+						ID:                  "abc",
+						Link:                "def",
+						IPAddressFromIPPool: "abc",
+						Routes: []infrav1.NetworkDataRoutev4{
+							{
+								Network: "10.0.0.0",
+								Prefix:  16,
+								Gateway: infrav1.NetworkGatewayv4{
+									FromIPPool: pointer.String("abc"),
+								},
+								Services: infrav1.NetworkDataServicev4{
+									DNS: []ipamv1.IPAddressv4Str{
+										ipamv1.IPAddressv4Str("8.8.8.8"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			m3d: &infrav1.Metal3Data{
+				Spec: infrav1.Metal3DataSpec{
+					Index: 2,
+				},
+			},
+			expectedOutput: []interface{}{
+				map[string]interface{}{
+					"ip_address": ipamv1.IPAddressv4Str("192.168.0.14"),
+					"routes": []interface{}{
+						map[string]interface{}{
+							"network": ipamv1.IPAddressv4Str("10.0.0.0"),
+							"netmask": ipamv1.IPAddressv4Str("255.255.0.0"),
+							"gateway": ipamv1.IPAddressv4Str("192.168.1.1"),
+							"services": []interface{}{
+								map[string]interface{}{
+									"type":    "dns",
+									"address": ipamv1.IPAddressv4Str("8.8.8.8"),
+								},
+							},
+						},
+					},
+					"type":    "ipv4",
+					"id":      "abc",
+					"link":    "def",
+					"netmask": ipamv1.IPAddressv4Str("255.255.255.0"),
+				},
+			},
+		}),
+		Entry("IPv4 network, error", testCaseRenderNetworkNetworks{
+			networks: infrav1.NetworkDataNetwork{
+				IPv4: []infrav1.NetworkDataIPv4{
+					{
+						IPAddressFromIPPool: "abc",
+					},
+				},
+			},
+			m3d: &infrav1.Metal3Data{
+				Spec: infrav1.Metal3DataSpec{
+					Index: 1000,
+				},
+			},
+			expectError: true,
+		}),
+		Entry("IPv4 network, IPv6 address error", testCaseRenderNetworkNetworks{
+			poolAddresses: map[string]addressFromPool{
+				"abc": {
+					Address: ipamv1.IPAddressStr("fe80::2001:38"),
+					Prefix:  96,
+				},
+			},
+			networks: infrav1.NetworkDataNetwork{
+				IPv4: []infrav1.NetworkDataIPv4{
+					{
+						ID:                  "abc",
+						Link:                "def",
+						IPAddressFromIPPool: "abc",
+					},
+				},
+			},
+			expectError: true,
+		}),
+		Entry("IPv6 network, IPv4 address error", testCaseRenderNetworkNetworks{
 			poolAddresses: map[string]addressFromPool{
 				"abc": {
 					Address: ipamv1.IPAddressStr("192.168.0.14"),
 					Prefix:  24,
-					Gateway: ipamv1.IPAddressStr("192.168.1.1"),
 				},
 			},
 			networks: infrav1.NetworkDataNetwork{
-				IPv4: []infrav1.NetworkDataIPv4{
+				IPv6: []infrav1.NetworkDataIPv6{
 					{
 						ID:                  "abc",
 						Link:                "def",
 						IPAddressFromIPPool: "abc",
+					},
+				},
+			},
+			expectError: true,
+		}),
+		Entry("Dual-stack networks on one link", testCaseRenderNetworkNetworks{
+			poolAddresses: map[string]addressFromPool{
+				"pool-v4": {
+					Address: ipamv1.IPAddressStr("192.168.0.14"),
+					Prefix:  24,
+					Gateway: ipamv1.IPAddressStr("192.168.0.1"),
+					dnsServers: []ipamv1.IPAddressStr{
+						ipamv1.IPAddressStr("8.8.8.8"),
+						ipamv1.IPAddressStr("2001:4860:4860::8888"),
+					},
+				},
+				"pool-v6": {
+					Address: ipamv1.IPAddressStr("2001:db8::14"),
+					Prefix:  64,
+					Gateway: ipamv1.IPAddressStr("2001:db8::1"),
+					dnsServers: []ipamv1.IPAddressStr{
+						ipamv1.IPAddressStr("8.8.8.8"),
+						ipamv1.IPAddressStr("2001:4860:4860::8888"),
+					},
+				},
+			},
+			networks: infrav1.NetworkDataNetwork{
+				IPv4: []infrav1.NetworkDataIPv4{
+					{
+						ID:                  "eth0-v4",
+						Link:                "eth0",
+						IPAddressFromIPPool: "pool-v4",
 						Routes: []infrav1.NetworkDataRoutev4{
 							{
-								Network: "10.0.0.0",
-								Prefix:  16,
+								Network: "0.0.0.0",
+								Prefix:  0,
 								Gateway: infrav1.NetworkGatewayv4{
-									FromIPPool: pointer.String("abc"),
+									FromIPPool: pointer.String("pool-v4"),
 								},
 								Services: infrav1.NetworkDataServicev4{
-									DNS: []ipamv1.IPAddressv4Str{
-										ipamv1.IPAddressv4Str("8.8.8.8"),
-									},
+									DNSFromIPPool: pointer.String("pool-v4"),
 								},
 							},
 						},
 					},
 				},
-			},
-			m3d: &infrav1.Metal3Data{
-				Spec: infrav1.Metal3DataSpec{
-					Index: 2,
+				IPv6: []infrav1.NetworkDataIPv6{
+					{
+						ID:   "eth0-v6",
+						Link: "eth0",
+						FromPoolRef: &corev1.TypedLocalObjectReference{
+							Name:     "pool-v6",
+							APIGroup: pointer.String("ipam.metal3.io"),
+							Kind:     "IPPool",
+						},
+						Routes: []infrav1.NetworkDataRoutev6{
+							{
+								Network: "::",
+								Prefix:  0,
+								Gateway: infrav1.NetworkGatewayv6{
+									FromIPPool: pointer.String("pool-v6"),
+								},
+								Services: infrav1.NetworkDataServicev6{
+									DNSFromIPPool: pointer.String("pool-v6"),
+								},
+							},
+						},
+					},
+				},
+				IPv4DHCP: []infrav1.NetworkDataIPv4DHCP{
+					{
+						ID:   "eth1-v4",
+						Link: "eth1",
+					},
+				},
+				IPv6DHCP: []infrav1.NetworkDataIPv6DHCP{
+					{
+						ID:   "eth1-v6",
+						Link: "eth1",
+					},
 				},
 			},
 			expectedOutput: []interface{}{
@@ -2274,38 +4239,55 @@ var _ = Describe("Metal3Data manager", func() {
 					"ip_address": ipamv1.IPAddressv4Str("192.168.0.14"),
 					"routes": []interface{}{
 						map[string]interface{}{
-							"network": ipamv1.IPAddressv4Str("10.0.0.0"),
-							"netmask": ipamv1.IPAddressv4Str("255.255.0.0"),
-							"gateway": ipamv1.IPAddressv4Str("192.168.1.1"),
+							"network": ipamv1.IPAddressv4Str("0.0.0.0"),
+							"netmask": ipamv1.IPAddressv4Str("0.0.0.0"),
+							"gateway": ipamv1.IPAddressv4Str("192.168.0.1"),
 							"services": []interface{}{
 								map[string]interface{}{
 									"type":    "dns",
-									"address": ipamv1.IPAddressv4Str("8.8.8.8"),
+									"address": ipamv1.IPAddressStr("8.8.8.8"),
 								},
 							},
 						},
 					},
 					"type":    "ipv4",
-					"id":      "abc",
-					"link":    "def",
+					"id":      "eth0-v4",
+					"link":    "eth0",
 					"netmask": ipamv1.IPAddressv4Str("255.255.255.0"),
 				},
-			},
-		}),
-		Entry("IPv4 network, error", testCaseRenderNetworkNetworks{
-			networks: infrav1.NetworkDataNetwork{
-				IPv4: []infrav1.NetworkDataIPv4{
-					{
-						IPAddressFromIPPool: "abc",
+				map[string]interface{}{
+					"ip_address": ipamv1.IPAddressv6Str("2001:db8::14"),
+					"routes": []interface{}{
+						map[string]interface{}{
+							"network": ipamv1.IPAddressv6Str("::"),
+							"netmask": ipamv1.IPAddressv6Str("::"),
+							"gateway": ipamv1.IPAddressv6Str("2001:db8::1"),
+							"services": []interface{}{
+								map[string]interface{}{
+									"type":    "dns",
+									"address": ipamv1.IPAddressStr("2001:4860:4860::8888"),
+								},
+							},
+						},
 					},
+					"type":    "ipv6",
+					"id":      "eth0-v6",
+					"link":    "eth0",
+					"netmask": ipamv1.IPAddressv6Str("ffff:ffff:ffff:ffff::"),
 				},
-			},
-			m3d: &infrav1.Metal3Data{
-				Spec: infrav1.Metal3DataSpec{
-					Index: 1000,
+				map[string]interface{}{
+					"routes": []interface{}{},
+					"type":   "ipv4_dhcp",
+					"id":     "eth1-v4",
+					"link":   "eth1",
+				},
+				map[string]interface{}{
+					"routes": []interface{}{},
+					"type":   "ipv6_dhcp",
+					"id":     "eth1-v6",
+					"link":   "eth1",
 				},
 			},
-			expectError: true,
 		}),
 		Entry("IPv6 network", testCaseRenderNetworkNetworks{
 			poolAddresses: map[string]addressFromPool{
@@ -2589,10 +4571,10 @@ var _ = Describe("Metal3Data manager", func() {
 				},
 			},
 		}
-		output, err := getRoutesv4(netRoutes, poolAddresses)
+		output, err := getRoutesv4(netRoutes, poolAddresses, nil)
 		Expect(output).To(Equal(ExpectedOutput))
 		Expect(err).NotTo(HaveOccurred())
-		_, err = getRoutesv4(netRoutes, map[string]addressFromPool{})
+		_, err = getRoutesv4(netRoutes, map[string]addressFromPool{}, nil)
 		Expect(err).To(HaveOccurred())
 	})
 
@@ -2655,10 +4637,10 @@ var _ = Describe("Metal3Data manager", func() {
 				},
 			},
 		}
-		output, err := getRoutesv6(netRoutes, poolAddresses)
+		output, err := getRoutesv6(netRoutes, poolAddresses, nil)
 		Expect(output).To(Equal(ExpectedOutput))
 		Expect(err).NotTo(HaveOccurred())
-		_, err = getRoutesv6(netRoutes, map[string]addressFromPool{})
+		_, err = getRoutesv6(netRoutes, map[string]addressFromPool{}, nil)
 		Expect(err).To(HaveOccurred())
 	})
 
@@ -3053,6 +5035,92 @@ var _ = Describe("Metal3Data manager", func() {
 				"Annotation-5": "BMHAnnotation",
 			},
 		}),
+		Entry("Dual-stack addresses", testCaseRenderMetaData{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta("data-abc", namespaceName, ""),
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						IPAddressesFromPool: []infrav1.FromPool{
+							{
+								Key:  "local-ipv4",
+								Name: "pool-v4",
+							},
+							{
+								Key:  "local-ipv6",
+								Name: "pool-v6",
+							},
+						},
+						PrefixesFromPool: []infrav1.FromPool{
+							{
+								Key:  "prefix-ipv4",
+								Name: "pool-v4",
+							},
+							{
+								Key:  "prefix-ipv6",
+								Name: "pool-v6",
+							},
+						},
+					},
+				},
+			},
+			m3m: &infrav1.Metal3Machine{
+				ObjectMeta: testObjectMeta(metal3machineName, namespaceName, m3muid),
+			},
+			bmh: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: testObjectMeta(baremetalhostName, namespaceName, bmhuid),
+			},
+			poolAddresses: map[string]addressFromPool{
+				"pool-v4": {
+					Address: "192.168.0.14",
+					Prefix:  24,
+				},
+				"pool-v6": {
+					Address: "2001:db8::14",
+					Prefix:  64,
+				},
+			},
+			expectedMetaData: map[string]string{
+				"local-ipv4":  "192.168.0.14",
+				"local-ipv6":  "2001:db8::14",
+				"prefix-ipv4": "24",
+				"prefix-ipv6": "64",
+				"providerid":  fmt.Sprintf("%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+			},
+		}),
+		Entry("Same key from two pools", testCaseRenderMetaData{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta("data-abc", namespaceName, ""),
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						IPAddressesFromPool: []infrav1.FromPool{
+							{
+								Key:  "local-ip",
+								Name: "pool-v4",
+							},
+							{
+								Key:  "local-ip",
+								Name: "pool-v6",
+							},
+						},
+					},
+				},
+			},
+			poolAddresses: map[string]addressFromPool{
+				"pool-v4": {
+					Address: "192.168.0.14",
+				},
+				"pool-v6": {
+					Address: "2001:db8::14",
+				},
+			},
+			expectError: true,
+		}),
 		Entry("Interface absent", testCaseRenderMetaData{
 			m3dt: &infrav1.Metal3DataTemplate{
 				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
@@ -3204,6 +5272,129 @@ var _ = Describe("Metal3Data manager", func() {
 			},
 			expectError: true,
 		}),
+		Entry("Template", testCaseRenderMetaData{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta("data-abc", namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Index: 2,
+				},
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						FromTemplates: []infrav1.MetaDataFromTemplate{
+							{
+								Key:      "Template-1",
+								Template: "{{ .ClusterName }}-{{ .MachineName }}-rack{{ .BareMetalHostLabels.rack }}-{{ .Metal3MachineName }}-{{ .BareMetalHostName }}-{{ .Index }}",
+							},
+						},
+					},
+				},
+			},
+			m3m: &infrav1.Metal3Machine{
+				ObjectMeta: testObjectMeta(metal3machineName, namespaceName, ""),
+			},
+			machine: &clusterv1.Machine{
+				ObjectMeta: testObjectMeta(machineName, namespaceName, ""),
+				Spec: clusterv1.MachineSpec{
+					ClusterName: clusterName,
+				},
+			},
+			bmh: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						"rack": "12",
+					},
+				},
+			},
+			expectedMetaData: map[string]string{
+				"Template-1": clusterName + "-" + machineName + "-rack12-" + metal3machineName + "-" + baremetalhostName + "-2",
+				"providerid": fmt.Sprintf("%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+			},
+		}),
+		Entry("Invalid template", testCaseRenderMetaData{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta("data-abc", namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Index: 2,
+				},
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						FromTemplates: []infrav1.MetaDataFromTemplate{
+							{
+								Key:      "Template-1",
+								Template: "{{ .ClusterName ",
+							},
+						},
+					},
+				},
+			},
+			m3m: &infrav1.Metal3Machine{
+				ObjectMeta: testObjectMeta(metal3machineName, namespaceName, ""),
+			},
+			machine: &clusterv1.Machine{
+				ObjectMeta: testObjectMeta(machineName, namespaceName, ""),
+				Spec: clusterv1.MachineSpec{
+					ClusterName: clusterName,
+				},
+			},
+			bmh: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						"rack": "12",
+					},
+				},
+			},
+			expectError: true,
+		}),
+		Entry("Template with a missing label", testCaseRenderMetaData{
+			m3d: &infrav1.Metal3Data{
+				ObjectMeta: testObjectMeta("data-abc", namespaceName, ""),
+				Spec: infrav1.Metal3DataSpec{
+					Index: 2,
+				},
+			},
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: testObjectMeta(metal3DataTemplateName+"-abc", "", ""),
+				Spec: infrav1.Metal3DataTemplateSpec{
+					MetaData: &infrav1.MetaData{
+						FromTemplates: []infrav1.MetaDataFromTemplate{
+							{
+								Key:      "Template-1",
+								Template: "rack{{ .BareMetalHostLabels.row }}",
+							},
+						},
+					},
+				},
+			},
+			m3m: &infrav1.Metal3Machine{
+				ObjectMeta: testObjectMeta(metal3machineName, namespaceName, ""),
+			},
+			machine: &clusterv1.Machine{
+				ObjectMeta: testObjectMeta(machineName, namespaceName, ""),
+				Spec: clusterv1.MachineSpec{
+					ClusterName: clusterName,
+				},
+			},
+			bmh: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						"rack": "12",
+					},
+				},
+			},
+			expectError: true,
+		}),
 	)
 
 	type testCaseGetBMHMacByName struct {
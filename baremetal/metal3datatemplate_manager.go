@@ -19,9 +19,13 @@ package baremetal
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
@@ -29,8 +33,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -41,8 +47,15 @@ type DataTemplateManagerInterface interface {
 	UnsetFinalizer()
 	SetClusterOwnerRef(*clusterv1.Cluster) error
 	UpdateDatas(context.Context) (int, error)
+	DeleteOrphanedClaims(context.Context) (time.Duration, error)
 }
 
+// DataClaimOrphanGracePeriod is the time after which a Metal3DataClaim whose
+// owner Metal3Machine is not found is deleted, releasing its index and its
+// Metal3Data. It protects the claims from a lagging cache, e.g. during a
+// pivot.
+var DataClaimOrphanGracePeriod = 10 * time.Minute
+
 // DataTemplateManager is responsible for performing machine reconciliation.
 type DataTemplateManager struct {
 	client       client.Client
@@ -205,10 +218,84 @@ func (m *DataTemplateManager) UpdateDatas(ctx context.Context) (int, error) {
 			return 0, err
 		}
 	}
+	if err := m.pruneLastIndexes(ctx); err != nil {
+		return 0, err
+	}
+	m.DataTemplate.Status.IndexSummary = indexSummary(indexes)
 	m.updateStatusTimestamp()
 	return len(indexes), nil
 }
 
+// pruneLastIndexes removes the last indexes of the BareMetalHosts that were
+// deleted, or whose key is invalid. The last indexes of the existing
+// BareMetalHosts are kept after their Metal3DataClaim is deleted, so that the
+// index is allocated again when the BareMetalHost is reused.
+func (m *DataTemplateManager) pruneLastIndexes(ctx context.Context) error {
+	for hostKey := range m.DataTemplate.Status.LastIndexes {
+		namespace, name, err := cache.SplitMetaNamespaceKey(hostKey)
+		if err != nil || name == "" {
+			delete(m.DataTemplate.Status.LastIndexes, hostKey)
+			continue
+		}
+		host := &bmov1alpha1.BareMetalHost{}
+		err = m.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, host)
+		if apierrors.IsNotFound(err) {
+			m.Log.Info("Pruning the last index of a deleted BareMetalHost", "host", hostKey)
+			delete(m.DataTemplate.Status.LastIndexes, hostKey)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxFreeIndexRanges is the maximum number of ranges of free indexes listed in
+// the index summary, to bound the size of the status.
+const maxFreeIndexRanges = 32
+
+// indexSummary summarizes the allocated indexes, listing the free indexes
+// below the highest one as ranges.
+func indexSummary(indexes map[int]string) *infrav1.Metal3DataTemplateIndexSummary {
+	summary := &infrav1.Metal3DataTemplateIndexSummary{
+		Allocated: len(indexes),
+	}
+	if len(indexes) == 0 {
+		return summary
+	}
+	allocated := make([]int, 0, len(indexes))
+	for index := range indexes {
+		allocated = append(allocated, index)
+	}
+	sort.Ints(allocated)
+	summary.HighestIndex = pointer.Int(allocated[len(allocated)-1])
+
+	free := []string{}
+	next := 0
+	for _, index := range allocated {
+		if index > next {
+			if len(free) == maxFreeIndexRanges {
+				free = append(free, "...")
+				break
+			}
+			free = append(free, indexRange(next, index-1))
+		}
+		next = index + 1
+	}
+	summary.Free = strings.Join(free, ",")
+	return summary
+}
+
+// indexRange formats a range of indexes, a single index if it starts and ends
+// on the same one.
+func indexRange(first, last int) string {
+	if first == last {
+		return strconv.Itoa(first)
+	}
+	return fmt.Sprintf("%d-%d", first, last)
+}
+
 func (m *DataTemplateManager) updateData(ctx context.Context,
 	dataClaim *infrav1.Metal3DataClaim, indexes map[int]string,
 ) (map[int]string, error) {
@@ -285,17 +372,11 @@ func (m *DataTemplateManager) createData(ctx context.Context,
 
 	// Get a new index for this machine
 	m.Log.Info("Getting index", "Claim", dataClaim.Name)
-	claimIndex := len(indexes)
-	// The length of the map might be smaller than the highest index stored,
-	// this means we have a gap to find
-	for index := 0; index < len(indexes); index++ {
-		if _, ok := indexes[index]; !ok {
-			if claimIndex == len(indexes) {
-				claimIndex = index
-				break
-			}
-		}
+	allocation, hostKey, err := m.allocateIndex(ctx, dataClaim.Namespace, m3mName, indexes)
+	if err != nil {
+		return indexes, err
 	}
+	claimIndex := allocation.Index
 
 	// Set the index and Metal3Data names
 	if m.DataTemplate.Spec.TemplateReference != "" {
@@ -315,7 +396,7 @@ func (m *DataTemplateManager) createData(ctx context.Context,
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      dataName,
 			Namespace: m.DataTemplate.Namespace,
-			Labels:    dataClaim.Labels,
+			Labels:    inheritWatchLabel(dataClaim.Labels, m.DataTemplate.Labels),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					Controller: pointer.Bool(true),
@@ -365,6 +446,13 @@ func (m *DataTemplateManager) createData(ctx context.Context,
 
 	m.DataTemplate.Status.Indexes[dataClaim.Name] = claimIndex
 	indexes[claimIndex] = dataClaim.Name
+	if hostKey != "" {
+		if m.DataTemplate.Status.LastIndexes == nil {
+			m.DataTemplate.Status.LastIndexes = make(map[string]int)
+		}
+		m.DataTemplate.Status.LastIndexes[hostKey] = claimIndex
+	}
+	dataClaim.Status.IndexAllocation = allocation
 
 	dataClaim.Status.RenderedData = &corev1.ObjectReference{
 		Name:      dataName,
@@ -374,6 +462,70 @@ func (m *DataTemplateManager) createData(ctx context.Context,
 	return indexes, nil
 }
 
+// allocateIndex returns the index to allocate to the Metal3Machine, and the
+// key of its BareMetalHost if known. The index set in the
+// PreferredDataIndexAnnotation of the Metal3Machine is allocated if free,
+// then the index last used by its BareMetalHost, then the first free index.
+func (m *DataTemplateManager) allocateIndex(ctx context.Context,
+	namespace, m3mName string, indexes map[int]string,
+) (*infrav1.IndexAllocation, string, error) {
+	m3m := &infrav1.Metal3Machine{}
+	key := client.ObjectKey{Name: m3mName, Namespace: namespace}
+	if err := m.client.Get(ctx, key, m3m); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, "", err
+		}
+	}
+	hostKey := m3m.Annotations[HostAnnotation]
+
+	message := ""
+	if value, ok := m3m.Annotations[infrav1.PreferredDataIndexAnnotation]; ok {
+		preferred, err := strconv.Atoi(value)
+		switch {
+		case err != nil || preferred < 0 || preferred > infrav1.MaxPreferredDataIndex:
+			message = fmt.Sprintf("preferred index %q is invalid", value)
+		case isIndexTaken(indexes, preferred):
+			message = fmt.Sprintf("preferred index %d is taken", preferred)
+		default:
+			return &infrav1.IndexAllocation{
+				Index:  preferred,
+				Reason: infrav1.IndexAllocationPreferred,
+			}, hostKey, nil
+		}
+		m.Log.Info("Not allocating the preferred index", "Metal3Machine", m3mName, "reason", message)
+	}
+
+	if lastIndex, ok := m.DataTemplate.Status.LastIndexes[hostKey]; ok && hostKey != "" &&
+		!isIndexTaken(indexes, lastIndex) {
+		return &infrav1.IndexAllocation{
+			Index:   lastIndex,
+			Reason:  infrav1.IndexAllocationHostLastIndex,
+			Message: message,
+		}, hostKey, nil
+	}
+
+	claimIndex := len(indexes)
+	// The length of the map might be smaller than the highest index stored,
+	// this means we have a gap to find
+	for index := 0; index < len(indexes); index++ {
+		if !isIndexTaken(indexes, index) {
+			claimIndex = index
+			break
+		}
+	}
+	return &infrav1.IndexAllocation{
+		Index:   claimIndex,
+		Reason:  infrav1.IndexAllocationFirstFree,
+		Message: message,
+	}, hostKey, nil
+}
+
+// isIndexTaken returns true if the index is allocated.
+func isIndexTaken(indexes map[int]string, index int) bool {
+	_, ok := indexes[index]
+	return ok
+}
+
 // DeleteDatas deletes old secrets.
 func (m *DataTemplateManager) deleteData(ctx context.Context,
 	dataClaim *infrav1.Metal3DataClaim, indexes map[int]string,
@@ -424,3 +576,107 @@ func (m *DataTemplateManager) deleteData(ctx context.Context,
 	m.updateStatusTimestamp()
 	return indexes, nil
 }
+
+// DeleteOrphanedClaims sets the Metal3MachineFoundCondition of the
+// Metal3DataClaims of the template whose owner Metal3Machine is not found to
+// false, and deletes them once the condition is older than
+// DataClaimOrphanGracePeriod. The condition is set to true if the owner is
+// found again. It returns the time
+// after which the next orphaned claim may be deleted, zero if none.
+func (m *DataTemplateManager) DeleteOrphanedClaims(ctx context.Context) (time.Duration, error) {
+	dataClaims := infrav1.Metal3DataClaimList{}
+	if err := m.client.List(ctx, &dataClaims, client.InNamespace(m.DataTemplate.Namespace)); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var nextDeletion time.Duration
+	requeueIn := func(delay time.Duration) {
+		if nextDeletion == 0 || delay < nextDeletion {
+			nextDeletion = delay
+		}
+	}
+	for i := range dataClaims.Items {
+		dataClaim := &dataClaims.Items[i]
+		if dataClaim.Spec.Template.Name != m.DataTemplate.Name || !dataClaim.DeletionTimestamp.IsZero() {
+			continue
+		}
+		// The claims not created for a Metal3Machine are left alone.
+		m3mName := metal3MachineOwnerName(dataClaim)
+		if m3mName == "" {
+			continue
+		}
+		m3m := &infrav1.Metal3Machine{}
+		err := m.client.Get(ctx, client.ObjectKey{Name: m3mName, Namespace: dataClaim.Namespace}, m3m)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return 0, err
+		}
+		orphaned := apierrors.IsNotFound(err)
+		var missingSince *metav1.Time
+		if conditions.IsFalse(dataClaim, infrav1.Metal3MachineFoundCondition) {
+			missingSince = conditions.GetLastTransitionTime(dataClaim, infrav1.Metal3MachineFoundCondition)
+		}
+
+		switch {
+		case !orphaned && missingSince == nil:
+			continue
+		case !orphaned:
+			m.Log.Info("Owner of the Metal3DataClaim found again", "Metal3DataClaim", dataClaim.Name, "Metal3Machine", m3mName)
+			if err := m.patchDataClaimConditions(ctx, dataClaim, func() {
+				conditions.MarkTrue(dataClaim, infrav1.Metal3MachineFoundCondition)
+			}); err != nil {
+				return 0, err
+			}
+		case missingSince == nil:
+			m.Log.Info("Owner of the Metal3DataClaim not found", "Metal3DataClaim", dataClaim.Name, "Metal3Machine", m3mName)
+			if err := m.patchDataClaimConditions(ctx, dataClaim, func() {
+				conditions.MarkFalse(dataClaim, infrav1.Metal3MachineFoundCondition, infrav1.OwnerNotFoundReason,
+					clusterv1.ConditionSeverityWarning, "Metal3Machine %s not found", m3mName)
+			}); err != nil {
+				return 0, err
+			}
+			requeueIn(DataClaimOrphanGracePeriod)
+		default:
+			if orphanedFor := now.Sub(missingSince.Time); orphanedFor < DataClaimOrphanGracePeriod {
+				requeueIn(DataClaimOrphanGracePeriod - orphanedFor)
+				continue
+			}
+			m.Log.Info("Deleting orphaned Metal3DataClaim", "Metal3DataClaim", dataClaim.Name, "Metal3Machine", m3mName)
+			if err := m.client.Delete(ctx, dataClaim); err != nil && !apierrors.IsNotFound(err) {
+				return 0, errors.Wrapf(err, "failed to delete orphaned Metal3DataClaim %s", dataClaim.Name)
+			}
+			OrphanedDataClaimDeletions.Inc()
+		}
+	}
+	return nextDeletion, nil
+}
+
+// patchDataClaimConditions patches the conditions of the claim changed by
+// update.
+func (m *DataTemplateManager) patchDataClaimConditions(ctx context.Context,
+	dataClaim *infrav1.Metal3DataClaim, update func(),
+) error {
+	helper, err := patch.NewHelper(dataClaim, m.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to init patch helper")
+	}
+	update()
+	return helper.Patch(ctx, dataClaim, patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+		infrav1.Metal3MachineFoundCondition,
+	}})
+}
+
+// metal3MachineOwnerName returns the name of the Metal3Machine owning the
+// claim, empty if none.
+func metal3MachineOwnerName(dataClaim *infrav1.Metal3DataClaim) string {
+	for _, ownerRef := range dataClaim.OwnerReferences {
+		aGV, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+		if err != nil {
+			continue
+		}
+		if ownerRef.Kind == "Metal3Machine" && aGV.Group == infrav1.GroupVersion.Group {
+			return ownerRef.Name
+		}
+	}
+	return ""
+}
@@ -18,17 +18,23 @@ package baremetal
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -234,13 +240,14 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 	}
 
 	type testCaseUpdateDatas struct {
-		template          *infrav1.Metal3DataTemplate
-		dataClaims        []*infrav1.Metal3DataClaim
-		datas             []*infrav1.Metal3Data
-		expectRequeue     bool
-		expectError       bool
-		expectedNbIndexes int
-		expectedIndexes   map[string]int
+		template             *infrav1.Metal3DataTemplate
+		dataClaims           []*infrav1.Metal3DataClaim
+		datas                []*infrav1.Metal3Data
+		expectRequeue        bool
+		expectError          bool
+		expectedNbIndexes    int
+		expectedIndexes      map[string]int
+		expectedIndexSummary *infrav1.Metal3DataTemplateIndexSummary
 	}
 
 	DescribeTable("Test UpdateDatas",
@@ -272,6 +279,7 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 			Expect(nbIndexes).To(Equal(tc.expectedNbIndexes))
 			Expect(tc.template.Status.LastUpdated.IsZero()).To(BeFalse())
 			Expect(tc.template.Status.Indexes).To(Equal(tc.expectedIndexes))
+			Expect(tc.template.Status.IndexSummary).To(Equal(tc.expectedIndexSummary))
 
 			// get list of Metal3Data objects
 			dataObjects := infrav1.Metal3DataClaimList{}
@@ -291,7 +299,8 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 			template: &infrav1.Metal3DataTemplate{
 				ObjectMeta: templateMeta,
 			},
-			expectedIndexes: map[string]int{},
+			expectedIndexes:      map[string]int{},
+			expectedIndexSummary: &infrav1.Metal3DataTemplateIndexSummary{},
 		}),
 		Entry("Claim and IP exist", testCaseUpdateDatas{
 			template: &infrav1.Metal3DataTemplate{
@@ -426,9 +435,74 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 				"abce": 1,
 			},
 			expectedNbIndexes: 2,
+			expectedIndexSummary: &infrav1.Metal3DataTemplateIndexSummary{
+				Allocated:    2,
+				HighestIndex: pointer.Int(1),
+			},
 		}),
 	)
 
+	It("Summarizes the indexes allocated and released out of order", func() {
+		indexes := map[int]string{}
+		allocate := func(index int) {
+			indexes[index] = fmt.Sprintf("claim-%d", index)
+		}
+		release := func(index int) {
+			delete(indexes, index)
+		}
+
+		Expect(indexSummary(indexes)).To(Equal(&infrav1.Metal3DataTemplateIndexSummary{}))
+
+		for _, index := range []int{7, 2, 0, 5, 1, 9} {
+			allocate(index)
+		}
+		Expect(indexSummary(indexes)).To(Equal(&infrav1.Metal3DataTemplateIndexSummary{
+			Allocated:    6,
+			HighestIndex: pointer.Int(9),
+			Free:         "3-4,6,8",
+		}))
+
+		release(9)
+		release(1)
+		allocate(3)
+		Expect(indexSummary(indexes)).To(Equal(&infrav1.Metal3DataTemplateIndexSummary{
+			Allocated:    5,
+			HighestIndex: pointer.Int(7),
+			Free:         "1,4,6",
+		}))
+
+		release(0)
+		release(7)
+		Expect(indexSummary(indexes)).To(Equal(&infrav1.Metal3DataTemplateIndexSummary{
+			Allocated:    3,
+			HighestIndex: pointer.Int(5),
+			Free:         "0-1,4",
+		}))
+	})
+
+	It("Compresses the indexes of large templates in ranges", func() {
+		indexes := map[int]string{}
+		for index := 0; index <= 200; index++ {
+			if index < 100 || index >= 150 {
+				indexes[index] = fmt.Sprintf("claim-%d", index)
+			}
+		}
+		Expect(indexSummary(indexes)).To(Equal(&infrav1.Metal3DataTemplateIndexSummary{
+			Allocated:    151,
+			HighestIndex: pointer.Int(200),
+			Free:         "100-149",
+		}))
+
+		// Every other index is free, only the first ranges are listed.
+		for index := 1; index < 200; index += 2 {
+			delete(indexes, index)
+		}
+		summary := indexSummary(indexes)
+		Expect(summary.Free).To(HavePrefix("1,3,5,"))
+		Expect(summary.Free).To(HaveSuffix(",..."))
+		Expect(strings.Split(summary.Free, ",")).To(HaveLen(maxFreeIndexRanges + 1))
+	})
+
 	type testCaseTemplateReference struct {
 		template1                  *infrav1.Metal3DataTemplate
 		template2                  *infrav1.Metal3DataTemplate
@@ -746,6 +820,242 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 		}),
 	)
 
+	type testCaseAllocateIndex struct {
+		m3mAnnotations      map[string]string
+		lastIndexes         map[string]int
+		indexes             map[int]string
+		expectedAllocation  infrav1.IndexAllocation
+		expectedLastIndexes map[string]int
+	}
+
+	DescribeTable("Test index allocation",
+		func(tc testCaseAllocateIndex) {
+			m3m := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        metal3machineName,
+					Namespace:   namespaceName,
+					Annotations: tc.m3mAnnotations,
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m).Build()
+			indexes := map[string]int{}
+			for index, claim := range tc.indexes {
+				indexes[claim] = index
+			}
+			template := &infrav1.Metal3DataTemplate{
+				ObjectMeta: templateMeta,
+				Status: infrav1.Metal3DataTemplateStatus{
+					Indexes:     indexes,
+					LastIndexes: tc.lastIndexes,
+				},
+			}
+			templateMgr, err := NewDataTemplateManager(fakeClient, template,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			dataClaim := &infrav1.Metal3DataClaim{
+				ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+			}
+
+			allocatedMap, err := templateMgr.createData(context.TODO(), dataClaim,
+				tc.indexes,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dataClaim.Status.IndexAllocation).To(Equal(&tc.expectedAllocation))
+			Expect(allocatedMap).To(HaveKeyWithValue(tc.expectedAllocation.Index, metal3DataClaimName))
+			Expect(template.Status.LastIndexes).To(Equal(tc.expectedLastIndexes))
+			dataObject := infrav1.Metal3Data{}
+			key := client.ObjectKey{
+				Name:      "abc-" + strconv.Itoa(tc.expectedAllocation.Index),
+				Namespace: namespaceName,
+			}
+			Expect(fakeClient.Get(context.TODO(), key, &dataObject)).To(Succeed())
+			Expect(dataObject.Spec.Index).To(Equal(tc.expectedAllocation.Index))
+		},
+		Entry("No preference", testCaseAllocateIndex{
+			indexes: map[int]string{0: "bcd", 2: "cde"},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:  1,
+				Reason: infrav1.IndexAllocationFirstFree,
+			},
+		}),
+		Entry("Preferred index", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "7",
+				HostAnnotation:                       namespaceName + "/" + baremetalhostName,
+			},
+			indexes: map[int]string{0: "bcd"},
+			lastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 3,
+			},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:  7,
+				Reason: infrav1.IndexAllocationPreferred,
+			},
+			expectedLastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 7,
+			},
+		}),
+		Entry("Preferred index taken", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "0",
+			},
+			indexes: map[int]string{0: "bcd"},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:   1,
+				Reason:  infrav1.IndexAllocationFirstFree,
+				Message: "preferred index 0 is taken",
+			},
+		}),
+		Entry("Preferred index out of range", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "-1",
+			},
+			indexes: map[int]string{0: "bcd"},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:   1,
+				Reason:  infrav1.IndexAllocationFirstFree,
+				Message: "preferred index \"-1\" is invalid",
+			},
+		}),
+		Entry("Highest preferred index", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: strconv.Itoa(infrav1.MaxPreferredDataIndex),
+			},
+			indexes: map[int]string{0: "bcd"},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:  infrav1.MaxPreferredDataIndex,
+				Reason: infrav1.IndexAllocationPreferred,
+			},
+		}),
+		Entry("Preferred index above the maximum", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "65536",
+			},
+			indexes: map[int]string{0: "bcd"},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:   1,
+				Reason:  infrav1.IndexAllocationFirstFree,
+				Message: "preferred index \"65536\" is invalid",
+			},
+		}),
+		Entry("Preferred index not a number", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "seven",
+			},
+			indexes: map[int]string{},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:   0,
+				Reason:  infrav1.IndexAllocationFirstFree,
+				Message: "preferred index \"seven\" is invalid",
+			},
+		}),
+		Entry("Host reused", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				HostAnnotation: namespaceName + "/" + baremetalhostName,
+			},
+			indexes: map[int]string{0: "bcd"},
+			lastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 4,
+				namespaceName + "/other":                1,
+			},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:  4,
+				Reason: infrav1.IndexAllocationHostLastIndex,
+			},
+			expectedLastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 4,
+				namespaceName + "/other":                1,
+			},
+		}),
+		Entry("Host reused, preferred index taken", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				infrav1.PreferredDataIndexAnnotation: "0",
+				HostAnnotation:                       namespaceName + "/" + baremetalhostName,
+			},
+			indexes: map[int]string{0: "bcd"},
+			lastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 4,
+			},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:   4,
+				Reason:  infrav1.IndexAllocationHostLastIndex,
+				Message: "preferred index 0 is taken",
+			},
+			expectedLastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 4,
+			},
+		}),
+		Entry("Host reused, last index taken", testCaseAllocateIndex{
+			m3mAnnotations: map[string]string{
+				HostAnnotation: namespaceName + "/" + baremetalhostName,
+			},
+			indexes: map[int]string{0: "bcd"},
+			lastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 0,
+			},
+			expectedAllocation: infrav1.IndexAllocation{
+				Index:  1,
+				Reason: infrav1.IndexAllocationFirstFree,
+			},
+			expectedLastIndexes: map[string]int{
+				namespaceName + "/" + baremetalhostName: 1,
+			},
+		}),
+	)
+
+	It("Keeps the last index of the host when deleting the claim", func() {
+		hostKey := namespaceName + "/" + baremetalhostName
+		template := &infrav1.Metal3DataTemplate{
+			ObjectMeta: templateMeta,
+			Status: infrav1.Metal3DataTemplateStatus{
+				Indexes:     map[string]int{metal3DataClaimName: 3},
+				LastIndexes: map[string]int{hostKey: 3},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		templateMgr, err := NewDataTemplateManager(fakeClient, template,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		dataClaim := &infrav1.Metal3DataClaim{
+			ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+		}
+
+		_, err = templateMgr.deleteData(context.TODO(), dataClaim, map[int]string{3: metal3DataClaimName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Status.Indexes).To(BeEmpty())
+		Expect(template.Status.LastIndexes).To(Equal(map[string]int{hostKey: 3}))
+	})
+
+	It("Prunes the last indexes of the deleted hosts", func() {
+		hostKey := namespaceName + "/" + baremetalhostName
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{Name: baremetalhostName, Namespace: namespaceName},
+		}
+		template := &infrav1.Metal3DataTemplate{
+			ObjectMeta: templateMeta,
+			Status: infrav1.Metal3DataTemplateStatus{
+				LastIndexes: map[string]int{
+					hostKey:                        3,
+					namespaceName + "/gone":        4,
+					"invalid/host/key":             5,
+					"otherns/" + baremetalhostName: 6,
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		templateMgr, err := NewDataTemplateManager(fakeClient, template,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = templateMgr.UpdateDatas(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Status.LastIndexes).To(Equal(map[string]int{hostKey: 3}))
+	})
+
 	type testCaseDeleteDatas struct {
 		template        *infrav1.Metal3DataTemplate
 		dataClaim       *infrav1.Metal3DataClaim
@@ -853,4 +1163,94 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 		}),
 	)
 
+	type testCaseDeleteOrphanedClaims struct {
+		metal3Machine  *infrav1.Metal3Machine
+		missingFor     time.Duration
+		notOwned       bool
+		otherTemplate  bool
+		expectMissing  bool
+		expectDeleted  bool
+		expectDeleteIn time.Duration
+	}
+
+	DescribeTable("Test DeleteOrphanedClaims",
+		func(tc testCaseDeleteOrphanedClaims) {
+			template := &infrav1.Metal3DataTemplate{ObjectMeta: templateMeta}
+			dataClaim := &infrav1.Metal3DataClaim{
+				ObjectMeta: testObjectMetaWithOR(metal3DataClaimName, metal3machineName),
+				Spec: infrav1.Metal3DataClaimSpec{
+					Template: corev1.ObjectReference{Name: template.Name},
+				},
+			}
+			dataClaim.Finalizers = []string{infrav1.DataClaimFinalizer}
+			if tc.notOwned {
+				dataClaim.OwnerReferences = nil
+			}
+			if tc.otherTemplate {
+				dataClaim.Spec.Template.Name = "other-template"
+			}
+			missingSince := metav1.NewTime(time.Now().Add(-tc.missingFor).Truncate(time.Second))
+			if tc.missingFor != 0 {
+				dataClaim.Status.Conditions = clusterv1.Conditions{{
+					Type:               infrav1.Metal3MachineFoundCondition,
+					Status:             corev1.ConditionFalse,
+					Severity:           clusterv1.ConditionSeverityWarning,
+					Reason:             infrav1.OwnerNotFoundReason,
+					LastTransitionTime: missingSince,
+				}}
+			}
+			objects := []client.Object{dataClaim}
+			if tc.metal3Machine != nil {
+				objects = append(objects, tc.metal3Machine)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).
+				WithObjects(objects...).WithStatusSubresource(objects...).Build()
+			templateMgr, err := NewDataTemplateManager(fakeClient, template, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			deleteIn, err := templateMgr.DeleteOrphanedClaims(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleteIn).To(BeNumerically("~", tc.expectDeleteIn, 2*time.Second))
+
+			savedClaim := &infrav1.Metal3DataClaim{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(dataClaim), savedClaim)).To(Succeed())
+			Expect(savedClaim.DeletionTimestamp.IsZero()).To(Equal(!tc.expectDeleted))
+			Expect(conditions.IsFalse(savedClaim, infrav1.Metal3MachineFoundCondition)).To(Equal(tc.expectMissing))
+			if tc.expectMissing && tc.missingFor != 0 {
+				// The grace period is not restarted.
+				Expect(conditions.GetLastTransitionTime(savedClaim, infrav1.Metal3MachineFoundCondition).Time).
+					To(BeTemporally("==", missingSince.Time))
+			}
+			if tc.metal3Machine != nil && tc.missingFor != 0 {
+				Expect(conditions.IsTrue(savedClaim, infrav1.Metal3MachineFoundCondition)).To(BeTrue())
+			}
+		},
+		Entry("Owner found", testCaseDeleteOrphanedClaims{
+			metal3Machine: &infrav1.Metal3Machine{ObjectMeta: testObjectMeta(metal3machineName, namespaceName, "")},
+		}),
+		Entry("Owner not found, claim marked", testCaseDeleteOrphanedClaims{
+			expectMissing:  true,
+			expectDeleteIn: DataClaimOrphanGracePeriod,
+		}),
+		Entry("Owner not found within the grace period", testCaseDeleteOrphanedClaims{
+			missingFor:     time.Minute,
+			expectMissing:  true,
+			expectDeleteIn: DataClaimOrphanGracePeriod - time.Minute,
+		}),
+		Entry("Owner not found after the grace period, claim deleted", testCaseDeleteOrphanedClaims{
+			missingFor:    DataClaimOrphanGracePeriod + time.Minute,
+			expectMissing: true,
+			expectDeleted: true,
+		}),
+		Entry("Owner found again within the grace period", testCaseDeleteOrphanedClaims{
+			metal3Machine: &infrav1.Metal3Machine{ObjectMeta: testObjectMeta(metal3machineName, namespaceName, "")},
+			missingFor:    time.Minute,
+		}),
+		Entry("Claim without Metal3Machine owner", testCaseDeleteOrphanedClaims{
+			notOwned: true,
+		}),
+		Entry("Claim of another template", testCaseDeleteOrphanedClaims{
+			otherTemplate: true,
+		}),
+	)
 })
@@ -17,14 +17,19 @@ limitations under the License.
 package baremetal
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math/big"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	// comment for go-lint.
@@ -32,19 +37,27 @@ import (
 
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	capm3remote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
@@ -72,21 +85,102 @@ const (
 	ProviderIDPrefix = "metal3://"
 	// ProviderLabelPrefix is a label prefix for ProviderID.
 	ProviderLabelPrefix = "metal3.io/uuid"
+	// maxNoAvailableHostRequeueAfter caps the backoff of Metal3Machines
+	// waiting for a host.
+	maxNoAvailableHostRequeueAfter = time.Minute * 5
+	// maxHostClaimAttempts is the number of hosts chosen for a Metal3Machine
+	// in a reconciliation when the chosen ones are claimed concurrently.
+	maxHostClaimAttempts = 3
+	// defaultNodeVolumeDetachTimeout bounds the wait for the volumes of a
+	// drained Node to detach when the Machine does not set
+	// nodeVolumeDetachTimeout.
+	defaultNodeVolumeDetachTimeout = time.Minute * 5
 )
 
+// ProviderIDFormat is the format of the providerID set on the Nodes.
+type ProviderIDFormat string
+
+const (
+	// ProviderIDFormatUID is the metal3://<bmh-uid> format.
+	ProviderIDFormatUID ProviderIDFormat = "uid"
+	// ProviderIDFormatNamespacedName is the
+	// metal3://<namespace>/<bmh-name>/<metal3machine-name> format. It is the
+	// default format.
+	ProviderIDFormatNamespacedName ProviderIDFormat = "namespacedName"
+)
+
+// ParseProviderIDFormat returns the ProviderIDFormat matching the given value.
+func ParseProviderIDFormat(format string) (ProviderIDFormat, error) {
+	switch ProviderIDFormat(format) {
+	case ProviderIDFormatUID, ProviderIDFormatNamespacedName:
+		return ProviderIDFormat(format), nil
+	}
+	return "", errors.Errorf("unsupported providerID format %q, supported formats are %q and %q",
+		format, ProviderIDFormatUID, ProviderIDFormatNamespacedName)
+}
+
 var (
 	// Capm3FastTrack is the variable fetched from the CAPM3_FAST_TRACK environment variable.
-	Capm3FastTrack    = os.Getenv("CAPM3_FAST_TRACK")
-	notFoundErr       *NotFoundError
-	associateBMHMutex sync.Mutex
+	Capm3FastTrack = os.Getenv("CAPM3_FAST_TRACK")
+	notFoundErr    *NotFoundError
+	// ErrNoAvailableHost is returned when no BareMetalHost is available for
+	// the Metal3Machine. This is not a terminal error, the Metal3Machine is
+	// requeued until a host becomes available.
+	ErrNoAvailableHost = errors.New("no available host found")
+	// ErrNoAvailableHostInFailureDomain is the ErrNoAvailableHost returned
+	// when the Machine has a failure domain.
+	ErrNoAvailableHostInFailureDomain = fmt.Errorf("%w in the failure domain", ErrNoAvailableHost)
+	// ErrBlocked is returned when the Metal3Machine can not progress until it
+	// is fixed by the user. The reason is already set on the condition of the
+	// blocked step, the Metal3Machine is requeued.
+	ErrBlocked = errors.New("blocked")
+	// ErrHostDeleted is returned when the BareMetalHost of the Metal3Machine
+	// was deleted. The failure is already set on the Metal3Machine, it is not
+	// requeued.
+	ErrHostDeleted = errors.New("BareMetalHost deleted")
+	// LegacyLabelKeys maps the node reuse and cluster ownership label keys
+	// used by older releases to their current key. The legacy keys are read
+	// along with the current keys, and migrated to the current keys when CAPM3
+	// updates the object. It is empty unless set with SetLegacyLabelKeys.
+	// Deprecated: the support of the legacy keys will be removed in a later
+	// release.
+	LegacyLabelKeys map[string]string
+	// BMHNamespaces are the namespaces, other than their own, in which the
+	// Metal3Machines can consume BareMetalHosts.
+	BMHNamespaces []string
+	// ErrHostNamespaceNotAllowed is returned when the hostNamespace of the
+	// Metal3Machine is not allowed in BMHNamespaces.
+	ErrHostNamespaceNotAllowed = errors.New("host namespace not allowed")
+	// ErrWaitingForPreTerminateHook is returned when the deprovisioning of the
+	// BareMetalHost is held by pre-terminate hooks of the Machine. The reason
+	// is already set on the condition, the Metal3Machine is requeued.
+	ErrWaitingForPreTerminateHook = errors.New("waiting for pre-terminate hooks")
+	// MaxConcurrentProvisioning is the maximum number of BareMetalHosts being
+	// provisioned at the same time, to protect Ironic from provisioning
+	// storms. No limit when 0. The deprovisioning is not limited.
+	MaxConcurrentProvisioning int
+	// bootstrapFormats maps the values of the format key of the bootstrap
+	// data secrets to the userDataFormat of the images.
+	bootstrapFormats = map[string]string{
+		"cloud-config": infrav1.CloudInitUserDataFormat,
+		"ignition":     infrav1.IgnitionUserDataFormat,
+	}
 )
 
 // MachineManagerInterface is an interface for a MachineManager.
 type MachineManagerInterface interface {
 	SetFinalizer()
+	RecordTemplateHash(context.Context) error
 	UnsetFinalizer()
 	IsProvisioned() bool
 	IsBootstrapReady() bool
+	IsBootstrapless() bool
+	IsLiveISO() bool
+	LiveISOProviderID(context.Context) (string, error)
+	IsAdoptingHost() bool
+	AdoptedNodeProviderID(context.Context, ClientGetter) (string, error)
+	IsPoweringOn() bool
+	BootstrapSkipAllowed() bool
 	GetBaremetalHostID(context.Context) (*string, error)
 	Associate(context.Context) error
 	Delete(context.Context) error
@@ -94,6 +188,11 @@ type MachineManagerInterface interface {
 	HasAnnotation() bool
 	GetProviderIDAndBMHID() (string, *string)
 	SetNodeProviderID(context.Context, *string, ClientGetter) error
+	CheckProviderIDFormat()
+	SyncNodeAddresses(context.Context, ClientGetter) error
+	SyncNodeMetadata(context.Context, ClientGetter) error
+	DrainNode(context.Context, ClientGetter) error
+	IsHostDetached(context.Context) (bool, error)
 	SetProviderID(string)
 	SetPauseAnnotation(context.Context) error
 	RemovePauseAnnotation(context.Context) error
@@ -117,6 +216,31 @@ type MachineManager struct {
 	MachineSet            *clusterv1.MachineSet
 	MachineSetList        *clusterv1.MachineSetList
 	Log                   logr.Logger
+	// ProviderIDFormat is the format of the providerID set on the Nodes
+	// without one. Defaults to ProviderIDFormatNamespacedName.
+	ProviderIDFormat ProviderIDFormat
+	// Recorder, when set, records the events of the Metal3Machine.
+	Recorder record.EventRecorder
+	// HostClientGetter, when set, returns the client of the cluster in which
+	// the BareMetalHosts are registered, if not the cluster of the controller.
+	HostClientGetter HostClientGetter
+	// HostLabelSelector, when set, restricts the BareMetalHosts that can be
+	// chosen to those matching it, whatever the hostSelector of the
+	// Metal3Machine.
+	HostLabelSelector labels.Selector
+	// APIReader, when set, reads the annotated BareMetalHost from the API
+	// server when it is missing from the cache, which only holds the hosts
+	// matching the host label selector.
+	APIReader client.Reader
+
+	// hostClient is the client of the BareMetalHosts, set by hosts.
+	hostClient client.Client
+	// remoteHosts is true when hostClient is not the client of the cluster
+	// of the controller.
+	remoteHosts bool
+	// lostHosts are the hosts, as <namespace>/<name>, that changed while
+	// being claimed during this reconciliation, skipped by chooseHost.
+	lostHosts map[string]bool
 }
 
 // NewMachineManager returns a new helper for managing a machine.
@@ -157,6 +281,34 @@ func (m *MachineManager) SetFinalizer() {
 	}
 }
 
+// RecordTemplateHash records the generation and the spec hash of the
+// Metal3MachineTemplate the machine was cloned from, on its first
+// reconciliation, so that it is counted as out of date as soon as the template
+// changes. A machine whose spec already differs from the template is not given
+// the current hash.
+func (m *MachineManager) RecordTemplateHash(ctx context.Context) error {
+	if _, ok := m.Metal3Machine.Annotations[infrav1.TemplateHashAnnotation]; ok {
+		return nil
+	}
+	m3mt := m.getMetal3MachineTemplate(ctx)
+	if m3mt == nil {
+		return nil
+	}
+	if countDriftedMetal3Machines(&m3mt.Spec.Template.Spec, []*infrav1.Metal3Machine{m.Metal3Machine}) > 0 {
+		return nil
+	}
+	hash, err := templateSpecHash(m3mt)
+	if err != nil {
+		return err
+	}
+	if m.Metal3Machine.Annotations == nil {
+		m.Metal3Machine.Annotations = map[string]string{}
+	}
+	m.Metal3Machine.Annotations[infrav1.TemplateGenerationAnnotation] = strconv.FormatInt(m3mt.Generation, 10)
+	m.Metal3Machine.Annotations[infrav1.TemplateHashAnnotation] = hash
+	return nil
+}
+
 // UnsetFinalizer unsets finalizer.
 func (m *MachineManager) UnsetFinalizer() {
 	// Cluster is deleted so remove the finalizer.
@@ -178,6 +330,28 @@ func (m *MachineManager) IsBootstrapReady() bool {
 	return m.Machine.Status.BootstrapReady
 }
 
+// IsBootstrapless checks if the machine is provisioned without bootstrap data.
+func (m *MachineManager) IsBootstrapless() bool {
+	return m.Metal3Machine.Spec.Bootstrapless
+}
+
+// IsLiveISO checks if the machine boots a live-iso image, which is booted
+// without user data and never runs a Kubernetes Node.
+func (m *MachineManager) IsLiveISO() bool {
+	return isLiveISO(m.Metal3Machine)
+}
+
+// isLiveISO returns whether the image of the Metal3Machine is a live-iso.
+func isLiveISO(m3m *infrav1.Metal3Machine) bool {
+	return m3m.Spec.Image.DiskFormat != nil && *m3m.Spec.Image.DiskFormat == infrav1.LiveISODiskFormat
+}
+
+// BootstrapSkipAllowed checks if the Metal3Cluster allows bootstrapless
+// machines.
+func (m *MachineManager) BootstrapSkipAllowed() bool {
+	return m.Metal3Cluster != nil && m.Metal3Cluster.Spec.AllowBootstrapless
+}
+
 // isControlPlane returns true if the machine is a control plane.
 func (m *MachineManager) isControlPlane() bool {
 	return util.IsControlPlaneMachine(m.Machine)
@@ -192,6 +366,8 @@ func (m *MachineManager) role() string {
 }
 
 // RemovePauseAnnotation checks and/or Removes the pause annotations on associated bmh.
+// Only the annotation set by SetPauseAnnotation is removed, a BMH paused by
+// someone else stays paused.
 func (m *MachineManager) RemovePauseAnnotation(ctx context.Context) error {
 	// look for associated BMH
 	host, helper, err := m.getHost(ctx)
@@ -202,27 +378,27 @@ func (m *MachineManager) RemovePauseAnnotation(ctx context.Context) error {
 		return err
 	}
 
-	if host == nil {
+	// The BMH is gone, nothing to resume. A detached BMH is not modified.
+	if host == nil || isHostDetached(host) {
 		return nil
 	}
 
-	annotations := host.GetAnnotations()
-
-	if annotations != nil {
-		if _, ok := annotations[bmov1alpha1.PausedAnnotation]; ok {
-			if m.Cluster.Name == host.Labels[clusterv1.ClusterNameLabel] && annotations[bmov1alpha1.PausedAnnotation] == PausedAnnotationKey {
-				// Removing BMH Paused Annotation Since Owner Cluster is not paused.
-				delete(host.Annotations, bmov1alpha1.PausedAnnotation)
-			} else if m.Cluster.Name == host.Labels[clusterv1.ClusterNameLabel] && annotations[bmov1alpha1.PausedAnnotation] != PausedAnnotationKey {
-				m.Log.Info("BMH is paused by user. Not removing Pause Annotation")
-				return nil
-			}
-		}
+	pausedValue, ok := host.GetAnnotations()[bmov1alpha1.PausedAnnotation]
+	if !ok || m.Cluster.Name != getLabel(host.Labels, clusterv1.ClusterNameLabel) {
+		return nil
+	}
+	if pausedValue != PausedAnnotationKey {
+		m.Log.Info("BMH is paused by user. Not removing Pause Annotation")
+		return nil
 	}
+	// Removing BMH Paused Annotation Since Owner Cluster and Metal3Machine are not paused.
+	m.Log.Info("Removing PausedAnnotation from BareMetalHost")
+	delete(host.Annotations, bmov1alpha1.PausedAnnotation)
 	return helper.Patch(ctx, host)
 }
 
-// SetPauseAnnotation sets the pause annotations on associated bmh.
+// SetPauseAnnotation sets the pause annotations on associated bmh, along with
+// the status annotation needed to restore the BMH status after a move.
 func (m *MachineManager) SetPauseAnnotation(ctx context.Context) error {
 	// look for associated BMH
 	host, helper, err := m.getHost(ctx)
@@ -232,7 +408,8 @@ func (m *MachineManager) SetPauseAnnotation(ctx context.Context) error {
 		)
 		return err
 	}
-	if host == nil {
+	// A detached BMH is not modified.
+	if host == nil || isHostDetached(host) {
 		return nil
 	}
 
@@ -250,11 +427,20 @@ func (m *MachineManager) SetPauseAnnotation(ctx context.Context) error {
 	host.Annotations[bmov1alpha1.PausedAnnotation] = PausedAnnotationKey
 
 	// Setting annotation with BMH status
-	newAnnotation, err := json.Marshal(&host.Status)
-	if err != nil {
+	if err := setHostStatusAnnotation(host); err != nil {
 		m.SetError("Failed to marshal the BareMetalHost status",
 			capierrors.UpdateMachineError,
 		)
+		return err
+	}
+	return helper.Patch(ctx, host)
+}
+
+// setHostStatusAnnotation saves the status of the host, without its hardware
+// details, in its status annotation, to restore it after a move.
+func setHostStatusAnnotation(host *bmov1alpha1.BareMetalHost) error {
+	newAnnotation, err := json.Marshal(&host.Status)
+	if err != nil {
 		return errors.Wrap(err, "failed to marshall status annotation")
 	}
 	obj := map[string]interface{}{}
@@ -264,7 +450,7 @@ func (m *MachineManager) SetPauseAnnotation(ctx context.Context) error {
 	delete(obj, "hardware")
 	newAnnotation, _ = json.Marshal(obj)
 	host.Annotations[bmov1alpha1.StatusAnnotation] = string(newAnnotation)
-	return helper.Patch(ctx, host)
+	return nil
 }
 
 // GetBaremetalHostID return the provider identifier for this machine.
@@ -279,20 +465,275 @@ func (m *MachineManager) GetBaremetalHostID(ctx context.Context) (*string, error
 		m.Log.Info(errMessage)
 		return nil, WithTransientError(errors.New(errMessage), requeueAfter)
 	}
-	if host.Status.Provisioning.State == bmov1alpha1.StateProvisioned {
+	if hostProvisioned(host, m.Metal3Machine) {
+		if err := m.waitForFirmwareSettingsApplied(ctx, host); err != nil {
+			return nil, err
+		}
+		// The provisioning is recorded once, when the host is first seen
+		// provisioned.
+		if !conditions.IsTrue(m.Metal3Machine, infrav1.BareMetalHostProvisionedCondition) {
+			provision := host.Status.OperationHistory.Provision
+			if !provision.Start.IsZero() && !provision.End.IsZero() {
+				ProvisioningMetrics.ObserveProvisioningDuration(m.Cluster, provision.Duration())
+			}
+		}
+		m.SetConditionMetal3MachineToTrue(infrav1.BareMetalHostProvisionedCondition)
 		return pointer.String(string(host.ObjectMeta.UID)), nil
 	}
+	if host.Status.ErrorType == bmov1alpha1.ProvisioningError {
+		// The failure is counted once, until the host is provisioned again.
+		if conditions.GetReason(m.Metal3Machine, infrav1.BareMetalHostProvisionedCondition) != infrav1.BareMetalHostProvisioningFailedReason {
+			ProvisioningMetrics.IncProvisioningFailures(m.Cluster)
+		}
+		m.Log.Info("BaremetalHost failed to provision, requeuing", "error", host.Status.ErrorMessage)
+		m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.BareMetalHostProvisioningFailedReason,
+			clusterv1.ConditionSeverityWarning, "BareMetalHost %s failed to provision: %s", host.Name, host.Status.ErrorMessage,
+		)
+		return nil, nil
+	}
 	m.Log.Info("Provisioning BaremetalHost, requeuing")
+	m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.ProvisioningBareMetalHostReason,
+		clusterv1.ConditionSeverityInfo, "BareMetalHost %s is in provisioning state %q", host.Name, host.Status.Provisioning.State,
+	)
 	// Do not requeue since BMH update will trigger a reconciliation
 	return nil, nil
 }
 
+// hostProvisioned returns true if the host is provisioned, with the custom
+// deploy method of the Metal3Machine if it has one.
+func hostProvisioned(host *bmov1alpha1.BareMetalHost, m3m *infrav1.Metal3Machine) bool {
+	if host.Status.Provisioning.State != bmov1alpha1.StateProvisioned {
+		return false
+	}
+	if m3m.Spec.CustomDeploy == nil {
+		return true
+	}
+	return host.Status.Provisioning.CustomDeploy != nil &&
+		host.Status.Provisioning.CustomDeploy.Method == m3m.Spec.CustomDeploy.Method
+}
+
+// LiveISOProviderID returns the providerID of a live-iso machine once its
+// BareMetalHost is provisioned and powered on, or "" while it is booting. A
+// live-iso machine runs no Kubernetes Node, the providerID is not set on a Node.
+func (m *MachineManager) LiveISOProviderID(ctx context.Context) (string, error) {
+	host, _, err := m.getHost(ctx)
+	if err != nil {
+		return "", err
+	}
+	if host == nil {
+		errMessage := "BareMetalHost not associated, requeuing"
+		m.Log.Info(errMessage)
+		return "", WithTransientError(errors.New(errMessage), requeueAfter)
+	}
+	// A live-iso machine kept powered off is ready once provisioned.
+	poweredOff := m.Metal3Machine.Spec.PowerState == infrav1.PowerStateOff
+	if host.Status.Provisioning.State != bmov1alpha1.StateProvisioned || (!host.Status.PoweredOn && !poweredOff) {
+		// Do not requeue since BMH update will trigger a reconciliation
+		m.Log.Info("Booting the live-iso on the BareMetalHost", "host", host.Name)
+		return "", nil
+	}
+	if m.Metal3Machine.Spec.ProviderID != nil {
+		return *m.Metal3Machine.Spec.ProviderID, nil
+	}
+	return m.nodeProviderID(
+		ProviderIDPrefix+string(host.UID),
+		fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix, host.Namespace, host.Name, m.Metal3Machine.Name),
+	), nil
+}
+
+// IsAdoptingHost checks if the machine adopts an externally provisioned host
+// with the adopt-host annotation.
+func (m *MachineManager) IsAdoptingHost() bool {
+	return isAdoptingHost(m.Metal3Machine)
+}
+
+// isAdoptingHost returns whether the Metal3Machine has the AdoptHostAnnotation.
+func isAdoptingHost(m3m *infrav1.Metal3Machine) bool {
+	return m3m.Annotations[infrav1.AdoptHostAnnotation] != ""
+}
+
+// deprovisionAdoptedHost returns whether the host adopted by the
+// Metal3Machine is deprovisioned when the Metal3Machine is deleted.
+func deprovisionAdoptedHost(m3m *infrav1.Metal3Machine) bool {
+	_, ok := m3m.Annotations[infrav1.DeprovisionAdoptedHostAnnotation]
+	return ok
+}
+
+// adoptHost returns the BareMetalHost named by the AdoptHostAnnotation of the
+// Metal3Machine, in its hostNamespace or its own namespace. Only an externally
+// provisioned host that no other machine consumes is adopted, otherwise the
+// reason is set on the AssociateBMHCondition and a transient ErrBlocked is
+// returned.
+func (m *MachineManager) adoptHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
+	namespaces, err := m.hostNamespaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := client.ObjectKey{
+		Namespace: namespaces[0],
+		Name:      m.Metal3Machine.Annotations[infrav1.AdoptHostAnnotation],
+	}
+	host := &bmov1alpha1.BareMetalHost{}
+	err = hostClient.Get(ctx, key, host)
+	message := ""
+	switch {
+	case apierrors.IsNotFound(err):
+		message = fmt.Sprintf("BareMetalHost %s not found", key)
+	case err != nil:
+		return nil, nil, err
+	case hostConsumedByOther(host, m.Metal3Machine):
+		message = fmt.Sprintf("BareMetalHost %s is consumed by another machine", key)
+	case host.GetDeletionTimestamp() != nil:
+		message = fmt.Sprintf("BareMetalHost %s is being deleted", key)
+	case host.Spec.ConsumerRef == nil && host.Status.Provisioning.State != bmov1alpha1.StateExternallyProvisioned:
+		message = fmt.Sprintf("BareMetalHost %s is in provisioning state %q, only externally provisioned hosts can be adopted",
+			key, host.Status.Provisioning.State)
+	}
+	if message != "" {
+		m.Log.Info("Not adopting the BareMetalHost", "message", message)
+		m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.HostNotAdoptableReason,
+			clusterv1.ConditionSeverityWarning, message)
+		return nil, nil, WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+	}
+	m.Log.Info("Adopting externally provisioned host", "host", host.Name)
+	helper, err := patch.NewHelper(host, hostClient)
+	return host, helper, err
+}
+
+// releaseAdoptedHost releases the host adopted by the Metal3Machine without
+// deprovisioning it, only the consumerRef and the labels and annotations set
+// by the Metal3Machine are removed.
+func (m *MachineManager) releaseAdoptedHost(ctx context.Context, host *bmov1alpha1.BareMetalHost, helper *patch.Helper) error {
+	var err error
+	host.Spec.ConsumerRef = nil
+	host.OwnerReferences, err = m.DeleteOwnerRef(host.OwnerReferences)
+	if err != nil {
+		return err
+	}
+	migrateLegacyLabels(host.Labels)
+	if getLabel(host.Labels, clusterv1.ClusterNameLabel) == m.Machine.Spec.ClusterName {
+		delete(host.Labels, clusterv1.ClusterNameLabel)
+	}
+	removeReverseSyncedLabels(host)
+	if host.Annotations != nil && host.Annotations[bmov1alpha1.PausedAnnotation] == PausedAnnotationKey {
+		delete(host.Annotations, bmov1alpha1.PausedAnnotation)
+	}
+	return patchIfFound(ctx, helper, host)
+}
+
+// releaseDetachedHost removes the references of a detached host to the
+// Metal3Machine and to its secrets, so that the Metal3Data can be deleted. The
+// image and the power state of the host are not changed, the host is not
+// deprovisioned.
+func (m *MachineManager) releaseDetachedHost(ctx context.Context, host *bmov1alpha1.BareMetalHost, helper *patch.Helper) error {
+	var err error
+	if host.Spec.ConsumerRef != nil && consumerRefMatches(host.Spec.ConsumerRef, m.Metal3Machine) {
+		host.Spec.ConsumerRef = nil
+		if m.Metal3Machine.Status.UserData != nil {
+			host.Spec.UserData = nil
+		}
+		if m.Metal3Machine.Status.MetaData != nil {
+			host.Spec.MetaData = nil
+		}
+		if m.Metal3Machine.Status.NetworkData != nil {
+			host.Spec.NetworkData = nil
+		}
+		delete(host.Labels, infrav1.DataSecretsOwnerLabel)
+		if host.Namespace != m.Metal3Machine.Namespace || m.remoteHosts {
+			if err := m.deleteHostSecrets(ctx, host); err != nil {
+				return err
+			}
+		}
+	}
+	host.OwnerReferences, err = m.DeleteOwnerRef(host.OwnerReferences)
+	if err != nil {
+		return err
+	}
+	migrateLegacyLabels(host.Labels)
+	if getLabel(host.Labels, clusterv1.ClusterNameLabel) == m.Machine.Spec.ClusterName {
+		delete(host.Labels, clusterv1.ClusterNameLabel)
+	}
+	removeReverseSyncedLabels(host)
+	return patchIfFound(ctx, helper, host)
+}
+
+// removeReverseSyncedLabels removes the labels copied from the Node by the
+// reverse label sync, listed in the HostReverseSyncedLabelsAnnotation, from
+// the released host.
+func removeReverseSyncedLabels(host *bmov1alpha1.BareMetalHost) {
+	for _, key := range managedKeys(host.Annotations, infrav1.HostReverseSyncedLabelsAnnotation) {
+		delete(host.Labels, key)
+	}
+	delete(host.Annotations, infrav1.HostReverseSyncedLabelsAnnotation)
+}
+
+// AdoptedNodeProviderID returns the providerID of a machine adopting a host
+// once the Node named after the host exists in the workload cluster. The
+// providerID is set on the Node if it has none. The adopted host is already
+// provisioned, the Node is not found from the labels set by the metaData.
+func (m *MachineManager) AdoptedNodeProviderID(ctx context.Context, clientFactory ClientGetter) (string, error) {
+	host, _, err := m.getHost(ctx)
+	if err != nil {
+		return "", err
+	}
+	if host == nil {
+		errMessage := "BareMetalHost not associated, requeuing"
+		m.Log.Info(errMessage)
+		return "", WithTransientError(errors.New(errMessage), requeueAfter)
+	}
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
+	if err != nil {
+		return "", errors.Wrap(err, "Error creating a remote client")
+	}
+	node, err := corev1Remote.Nodes().Get(ctx, host.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		errMessage := fmt.Sprintf("Node %s of the adopted BareMetalHost not found, requeuing", host.Name)
+		m.Log.Info(errMessage)
+		return "", WithTransientError(errors.New(errMessage), requeueAfter)
+	} else if err != nil {
+		errMessage := "error retrieving node, requeuing"
+		m.Log.Info(errMessage)
+		return "", WithTransientError(errors.Wrap(err, errMessage), requeueAfter)
+	}
+
+	providerIDLegacy := ProviderIDPrefix + string(host.UID)
+	providerIDNew := fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix, m.Metal3Machine.Namespace, host.Name, m.Metal3Machine.Name)
+	switch node.Spec.ProviderID {
+	case providerIDLegacy, providerIDNew:
+		return node.Spec.ProviderID, nil
+	case "":
+	default:
+		m.Log.Info("node using unsupported providerID format", "providerID", node.Spec.ProviderID)
+		return "", errors.Errorf("node %s using unsupported providerID %s", node.Name, node.Spec.ProviderID)
+	}
+
+	oldData, err := json.Marshal(node)
+	if err != nil {
+		return "", fmt.Errorf("failed to json.Marshal node: %w", err)
+	}
+	node.Spec.ProviderID = m.nodeProviderID(providerIDLegacy, providerIDNew)
+	newData, err := json.Marshal(node)
+	if err != nil {
+		return "", fmt.Errorf("failed to json.Marshal node: %w", err)
+	}
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, corev1.Node{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create patch for node %q: %w", node.Name, err)
+	}
+	_, err = corev1Remote.Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to update the target node with providerID")
+	}
+	m.Log.Info("ProviderID set on the Node of the adopted host", "node", node.Name)
+	return node.Spec.ProviderID, nil
+}
+
 // Associate associates a machine and is invoked by the Machine Controller.
 func (m *MachineManager) Associate(ctx context.Context) error {
-	// Parallel attempts to associate is problematic since the same BMH
-	// could be selected for multiple M3Ms. Therefore we use a mutex lock here.
-	associateBMHMutex.Lock()
-	defer associateBMHMutex.Unlock()
 	m.Log.Info("Associating machine", "machine", m.Machine.Name)
 
 	// load and validate the config
@@ -301,25 +742,54 @@ func (m *MachineManager) Associate(ctx context.Context) error {
 		return nil
 	}
 
+	// A Metal3Machine whose host was deleted is not associated with another
+	// host, its Machine is replaced.
+	if m.hostDeleted() {
+		return WithTerminalError(ErrHostDeleted)
+	}
+
 	// clear an error if one was previously set
 	m.clearError()
 
+	err := m.validateDataTemplatePolicy()
+	if err != nil {
+		return err
+	}
+
 	// look for associated BMH
 	host, helper, err := m.getHost(ctx)
 	if err != nil {
 		return err
 	}
 
-	// no BMH found, trying to choose from available ones
-	if host == nil {
-		host, helper, err = m.chooseHost(ctx)
+	// The annotation may be left over from an association that never reached
+	// the host, and the host may have been claimed by another machine since.
+	if host != nil && hostConsumedByOther(host, m.Metal3Machine) {
+		m.Log.Info("Annotated host is consumed by another machine, choosing a new host", "host", host.Name)
+		m.removeAnnotation()
+		host = nil
+	}
+
+	// An annotated host that never got the consumerRef is claimed again.
+	if host != nil && host.Spec.ConsumerRef == nil {
+		host, helper, err = m.claimHost(ctx, host)
 		if err != nil {
 			return err
 		}
 		if host == nil {
-			errMessage := "No available host found. Requeuing."
-			m.Log.Info(errMessage)
-			return WithTransientError(errors.New(errMessage), requeueAfter)
+			m.Log.Info("Annotated host was claimed by another consumer, choosing a new host")
+			m.removeAnnotation()
+		}
+	}
+
+	// no BMH found, trying to choose from available ones. This also picks up a
+	// host whose consumerRef was set for this machine by a previous reconcile
+	// that did not get to annotate the machine. A Metal3Machine adopting a host
+	// is only associated with that host.
+	if host == nil {
+		host, helper, err = m.chooseAndClaimHost(ctx)
+		if err != nil {
+			return err
 		}
 		m.Log.Info("Associating machine with host", "host", host.Name)
 	} else {
@@ -333,6 +803,11 @@ func (m *MachineManager) Associate(ctx context.Context) error {
 		return err
 	}
 
+	err = m.validateUserDataFormat(ctx)
+	if err != nil {
+		return err
+	}
+
 	err = m.setHostLabel(ctx, host)
 	if err != nil {
 		return err
@@ -343,6 +818,8 @@ func (m *MachineManager) Associate(ctx context.Context) error {
 		return err
 	}
 
+	m.setHostRootDeviceHints(host)
+
 	// If the user did not provide a DataTemplate, we can directly set the host
 	// specs, nothing to wait for.
 	if m.Metal3Machine.Spec.DataTemplate == nil {
@@ -423,10 +900,21 @@ func (m *MachineManager) getUserDataSecretName(_ context.Context) error {
 		return nil
 	}
 
+	// The user data is ignored when booting a live-iso, and an adopted host is
+	// already provisioned.
+	if isLiveISO(m.Metal3Machine) || isAdoptingHost(m.Metal3Machine) {
+		return nil
+	}
+
 	if m.Metal3Machine.Spec.UserData != nil {
 		m.Metal3Machine.Status.UserData = m.Metal3Machine.Spec.UserData
 	}
 
+	// A bootstrapless machine does not use the bootstrap data of the machine.
+	if m.Metal3Machine.Spec.Bootstrapless {
+		return nil
+	}
+
 	// if datasecretname is set just pass the reference.
 	if m.Machine.Spec.Bootstrap.DataSecretName != nil {
 		m.Metal3Machine.Status.UserData = &corev1.SecretReference{
@@ -444,6 +932,79 @@ func (m *MachineManager) getUserDataSecretName(_ context.Context) error {
 	return nil
 }
 
+// validateUserDataFormat checks that the format of the bootstrap data, given
+// by the format key set by the bootstrap providers, matches the userDataFormat
+// expected by the image, and reflects it in the
+// BootstrapFormatMatchedCondition. A mismatch blocks the host selection, it
+// is returned as a transient ErrBlocked and the BareMetalHost is not
+// provisioned. Nothing is checked if the
+// image has no expectation or if the format of the bootstrap data is unknown.
+func (m *MachineManager) validateUserDataFormat(ctx context.Context) error {
+	expected := m.Metal3Machine.Spec.Image.UserDataFormat
+	userData := m.Metal3Machine.Status.UserData
+	if expected == nil || userData == nil {
+		conditions.Delete(m.Metal3Machine, infrav1.BootstrapFormatMatchedCondition)
+		return nil
+	}
+	namespace := userData.Namespace
+	if namespace == "" {
+		namespace = m.Metal3Machine.Namespace
+	}
+	secret, err := checkSecretExists(ctx, m.client, userData.Name, namespace)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		conditions.Delete(m.Metal3Machine, infrav1.BootstrapFormatMatchedCondition)
+		return nil
+	}
+	format, ok := bootstrapFormats[string(secret.Data["format"])]
+	if !ok {
+		conditions.Delete(m.Metal3Machine, infrav1.BootstrapFormatMatchedCondition)
+		return nil
+	}
+	if format == *expected {
+		conditions.MarkTrue(m.Metal3Machine, infrav1.BootstrapFormatMatchedCondition)
+		return nil
+	}
+
+	message := fmt.Sprintf("bootstrap data in secret %s/%s is in %s format, the image expects %s",
+		namespace, userData.Name, format, *expected,
+	)
+	m.Log.Info("Bootstrap data format mismatch, not provisioning the BareMetalHost", "message", message)
+	conditions.MarkFalse(m.Metal3Machine, infrav1.BootstrapFormatMatchedCondition, infrav1.BootstrapFormatMismatchReason,
+		clusterv1.ConditionSeverityError, "%s", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.BootstrapFormatMismatchReason,
+		clusterv1.ConditionSeverityError, message)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
+// validateDataTemplatePolicy checks that the Metal3Machine renders its
+// metaData and networkData from a Metal3DataTemplate when its Metal3Cluster
+// enforces it, and reflects it in the PolicyCompliantCondition. This catches
+// the Metal3Machines created before the policy was enabled, a violation blocks
+// the host selection, it is returned as a transient ErrBlocked and no
+// BareMetalHost is chosen.
+func (m *MachineManager) validateDataTemplatePolicy() error {
+	if m.Metal3Cluster == nil || !m.Metal3Cluster.Spec.EnforceDataTemplates {
+		conditions.Delete(m.Metal3Machine, infrav1.PolicyCompliantCondition)
+		return nil
+	}
+	violations := m.Metal3Machine.Spec.ValidateDataTemplatesEnforced(field.NewPath("Spec"))
+	if len(violations) == 0 {
+		conditions.MarkTrue(m.Metal3Machine, infrav1.PolicyCompliantCondition)
+		return nil
+	}
+
+	message := violations.ToAggregate().Error()
+	m.Log.Info("Metal3Machine violates the policy of its Metal3Cluster, not provisioning a BareMetalHost", "message", message)
+	conditions.MarkFalse(m.Metal3Machine, infrav1.PolicyCompliantCondition, infrav1.DataTemplateRequiredReason,
+		clusterv1.ConditionSeverityError, "%s", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.DataTemplateRequiredReason,
+		clusterv1.ConditionSeverityError, message)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
 // Delete deletes a metal3 machine and is invoked by the Machine Controller.
 func (m *MachineManager) Delete(ctx context.Context) error {
 	m.Log.Info("Deleting metal3 machine", "metal3machine", m.Metal3Machine.Name)
@@ -460,7 +1021,21 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 		return err
 	}
 	if host == nil {
-		m.Log.Info("host not found for metal3machine", "metal3machine", m.Metal3Machine.Name)
+		// A deleted host is not deprovisioned, only the CAPM3 objects of the
+		// Metal3Machine are cleaned up.
+		m.Log.Info("host not found for metal3machine, skipping its deprovisioning", "metal3machine", m.Metal3Machine.Name)
+		m.removeAnnotation()
+		return nil
+	}
+
+	// A detached host is not deprovisioned, only its references to the
+	// Metal3Machine and its secrets are removed.
+	if isHostDetached(host) {
+		if err := m.releaseDetachedHost(ctx, host, helper); err != nil {
+			return err
+		}
+		m.Metal3Machine.Status.RenderedHost = nil
+		m.Log.Info("released the detached host without deprovisioning it", "host", host.Name)
 		return nil
 	}
 
@@ -475,18 +1050,27 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			if err != nil {
 				return err
 			}
+			m.Metal3Machine.Status.RenderedHost = nil
 			return nil
 		}
 
+		// External systems veto the deprovisioning of the host with
+		// pre-terminate hooks on the Machine, nothing is changed until they
+		// are all removed.
+		if err := m.checkPreTerminateHooks(); err != nil {
+			return err
+		}
+
 		// Remove clusterLabel from BMC secret.
 		tmpBMCSecret, errBMC := m.getBMCSecret(ctx, host)
 		if errBMC != nil && apierrors.IsNotFound(errBMC) {
 			m.Log.Info("BMC credential not found for BareMetalhost", "host", host.Name)
 		} else if errBMC == nil && tmpBMCSecret != nil {
 			m.Log.Info("Deleting cluster label from BMC credential", "bmccredential", host.Spec.BMC.CredentialsName)
-			if tmpBMCSecret.Labels != nil && tmpBMCSecret.Labels[clusterv1.ClusterNameLabel] == m.Machine.Spec.ClusterName {
+			if getLabel(tmpBMCSecret.Labels, clusterv1.ClusterNameLabel) == m.Machine.Spec.ClusterName {
+				migrateLegacyLabels(tmpBMCSecret.Labels)
 				delete(tmpBMCSecret.Labels, clusterv1.ClusterNameLabel)
-				errBMC = updateObject(ctx, m.client, tmpBMCSecret)
+				errBMC = updateObject(ctx, m.hostClient, tmpBMCSecret)
 				if errBMC != nil {
 					var reconcileError ReconcileError
 					if !(errors.As(errBMC, &reconcileError) && reconcileError.IsTransient()) {
@@ -497,12 +1081,27 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			}
 		}
 
+		// An adopted host is only released, unless its deprovisioning is
+		// requested.
+		if isAdoptingHost(m.Metal3Machine) && !deprovisionAdoptedHost(m.Metal3Machine) {
+			if err := m.releaseAdoptedHost(ctx, host, helper); err != nil {
+				return err
+			}
+			m.Metal3Machine.Status.RenderedHost = nil
+			m.Log.Info("released the adopted host without deprovisioning it", "host", host.Name)
+			return nil
+		}
+
 		bmhUpdated := false
 
 		if host.Spec.Image != nil {
 			host.Spec.Image = nil
 			bmhUpdated = true
 		}
+		if host.Spec.CustomDeploy != nil {
+			host.Spec.CustomDeploy = nil
+			bmhUpdated = true
+		}
 		if m.Metal3Machine.Status.UserData != nil && host.Spec.UserData != nil {
 			host.Spec.UserData = nil
 			bmhUpdated = true
@@ -515,6 +1114,12 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			host.Spec.NetworkData = nil
 			bmhUpdated = true
 		}
+		// The Metal3Data waits for its secrets to be released by the host.
+		if _, ok := host.Labels[infrav1.DataSecretsOwnerLabel]; ok &&
+			host.Spec.MetaData == nil && host.Spec.NetworkData == nil {
+			delete(host.Labels, infrav1.DataSecretsOwnerLabel)
+			bmhUpdated = true
+		}
 
 		//	Change bmh's online status to on/off  based on AutomatedCleaningMode and Capm3FastTrack values
 		//	AutomatedCleaningMode |	Capm3FastTrack|   BMH
@@ -541,6 +1146,9 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			bmhUpdated = true
 		}
 
+		// The host is updated below, migrate its legacy labels.
+		migrateLegacyLabels(host.Labels)
+
 		if bmhUpdated {
 			// Update the BMH object, if the errors are NotFound, do not return the
 			// errors.
@@ -577,58 +1185,34 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			// feature is enabled.
 			if m.Cluster.DeletionTimestamp.IsZero() {
 				// Fetch corresponding Metal3MachineTemplate, to see if nodeReuse
-				// feature is enabled. If set to true, check the machine role. In case
-				// machine role is ControlPlane, set nodeReuseLabelName to KubeadmControlPlane
-				// name, otherwise to MachineDeployment name.
-				m.Log.Info("Getting Metal3MachineTemplate")
-				m3mt := &infrav1.Metal3MachineTemplate{}
+				// feature is enabled. If set to true, set nodeReuseLabelName to
+				// the node reuse group of the machine, or to the KubeadmControlPlane
+				// name for a controlplane machine, otherwise to the MachineDeployment
+				// name.
 				if m.Metal3Machine == nil {
 					return errors.New("Metal3Machine associated with Metal3MachineTemplate is not found")
 				}
-				if m.hasTemplateAnnotation() {
-					m3mtKey := client.ObjectKey{
-						Name:      m.Metal3Machine.ObjectMeta.GetAnnotations()[clusterv1.TemplateClonedFromNameAnnotation],
-						Namespace: m.Metal3Machine.Namespace,
+				m3mt := m.getMetal3MachineTemplate(ctx)
+				if m3mt != nil && m3mt.Spec.NodeReuse {
+					if host.Labels == nil {
+						host.Labels = make(map[string]string)
 					}
-					if err := m.client.Get(ctx, m3mtKey, m3mt); err != nil {
-						// we are here, because while normal deprovisioning, Metal3MachineTemplate will be deleted first
-						// and we can't get it even though Metal3Machine has reference to it. We consider it nil and move
-						// forward with normal deprovisioning.
-						m3mt = nil
-						m.Log.Info("Metal3MachineTemplate associated with Metal3Machine is deleted")
-					} else {
-						// in case of upgrading, Metal3MachineTemplate will not be deleted and we can fetch it,
-						// in order to check for node reuse feature in the next step.
-						m.Log.Info("Found Metal3machineTemplate", "metal3machineTemplate", m3mtKey.Name)
+					m.Log.Info("Fetch node reuse label value while deprovisioning host", "host", host.Name)
+					value, err := m.nodeReuseLabelValue(ctx)
+					if err != nil {
+						return err
 					}
-				}
-				if m3mt != nil {
-					if m3mt.Spec.NodeReuse {
-						if host.Labels == nil {
-							host.Labels = make(map[string]string)
-						}
-						// Check if machine is ControlPlane
-						if m.isControlPlane() {
-							// Fetch KubeadmControlPlane name for controlplane machine
-							m.Log.Info("Fetch KubeadmControlPlane name while deprovisioning host", "host", host.Name)
-							kcpName, err := m.getKubeadmControlPlaneName(ctx)
-							if err != nil {
-								return err
-							}
-							// Set nodeReuseLabelName on the host to KubeadmControlPlane name
-							m.Log.Info("Setting nodeReuseLabelName in host to fetched KubeadmControlPlane", "host", host.Name, "kubeadmControlPlane", kcpName)
-							host.Labels[nodeReuseLabelName] = kcpName
-						} else {
-							// Fetch MachineDeployment name for worker machine
-							m.Log.Info("Fetch MachineDeployment name while deprovisioning host", "host", host.Name)
-							mdName, err := m.getMachineDeploymentName(ctx)
-							if err != nil {
-								return err
-							}
-							// Set nodeReuseLabelName on the host to MachineDeployment name
-							m.Log.Info("Setting nodeReuseLabelName in host to fetched MachineDeployment", "host", host.Name, "machinedeployment", mdName)
-							host.Labels[nodeReuseLabelName] = mdName
-						}
+					m.Log.Info("Setting nodeReuseLabelName in host", "host", host.Name, "value", value)
+					host.Labels[nodeReuseLabelName] = value
+					if host.Annotations == nil {
+						host.Annotations = map[string]string{}
+					}
+					host.Annotations[infrav1.HostNodeReuseSinceAnnotation] = time.Now().UTC().Format(time.RFC3339)
+					host.Annotations[infrav1.HostNodeReuseTemplateAnnotation] = m3mt.Name
+					if m3mt.Spec.NodeReuseGracePeriod != nil {
+						host.Annotations[infrav1.HostNodeReuseGracePeriodAnnotation] = m3mt.Spec.NodeReuseGracePeriod.Duration.String()
+					} else {
+						delete(host.Annotations, infrav1.HostNodeReuseGracePeriodAnnotation)
 					}
 				}
 			}
@@ -636,6 +1220,14 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 
 		host.Spec.ConsumerRef = nil
 
+		// Delete the secrets copied in the namespace of a host in another
+		// namespace.
+		if host.Namespace != m.Metal3Machine.Namespace || m.remoteHosts {
+			if err := m.deleteHostSecrets(ctx, host); err != nil {
+				return err
+			}
+		}
+
 		// Delete created secret, if data was set without DataSecretName
 		if m.Machine.Spec.Bootstrap.DataSecretName == nil {
 			m.Log.Info("Deleting User data secret for machine")
@@ -650,6 +1242,27 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 		}
 
 		host.Spec.ConsumerRef = nil
+		// With the OnDemand power management policy, the deprovisioned host
+		// is powered off, whatever the fast track setting.
+		if m.powerOnDemand() {
+			host.Spec.Online = false
+		}
+		if host.Annotations == nil {
+			host.Annotations = map[string]string{}
+		}
+		host.Annotations[infrav1.HostLastReleasedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+		// The rootDeviceHints set from the Metal3Machine are cleared, the host
+		// had none before.
+		if _, ok := host.Annotations[infrav1.HostRootDeviceHintsAnnotation]; ok {
+			host.Spec.RootDeviceHints = nil
+			delete(host.Annotations, infrav1.HostRootDeviceHintsAnnotation)
+		}
+
+		// The firmware settings set from the Metal3Machine are restored.
+		if err := m.restoreFirmwareSettings(ctx, host); err != nil {
+			return err
+		}
 
 		// Remove the ownerreference to this machine.
 		host.OwnerReferences, err = m.DeleteOwnerRef(host.OwnerReferences)
@@ -657,9 +1270,10 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			return err
 		}
 
-		if host.Labels != nil && host.Labels[clusterv1.ClusterNameLabel] == m.Machine.Spec.ClusterName {
-			delete(host.Labels, clusterv1.ClusterNameLabel)
+		if getLabel(host.Labels, clusterv1.ClusterNameLabel) == m.Machine.Spec.ClusterName {
+			deleteLabel(host.Labels, clusterv1.ClusterNameLabel)
 		}
+		removeReverseSyncedLabels(host)
 
 		m.Log.Info("Removing Paused Annotation (if any)")
 		if host.Annotations != nil && host.Annotations[bmov1alpha1.PausedAnnotation] == PausedAnnotationKey {
@@ -672,6 +1286,7 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 			return err
 		}
 	}
+	m.Metal3Machine.Status.RenderedHost = nil
 	m.Log.Info("finished deleting metal3 machine")
 	return nil
 }
@@ -680,8 +1295,14 @@ func (m *MachineManager) Delete(ctx context.Context) error {
 func (m *MachineManager) Update(ctx context.Context) error {
 	m.Log.Info("Updating machine")
 
-	// clear any error message that was previously set. This method doesn't set
-	// error messages yet, so we know that it's incorrect to have one here.
+	// A Metal3Machine whose host was deleted stays failed until its Machine
+	// is deleted.
+	if m.hostDeleted() {
+		return WithTerminalError(ErrHostDeleted)
+	}
+
+	// clear any error message that was previously set. The only error set by
+	// this method is a deleted host, handled above.
 	m.clearError()
 
 	host, helper, err := m.getHost(ctx)
@@ -689,10 +1310,26 @@ func (m *MachineManager) Update(ctx context.Context) error {
 		return err
 	}
 	if host == nil {
+		// getHost only returns no host and no error for an annotated host
+		// that is not found, other errors are returned and retried.
+		if m.HasAnnotation() {
+			return m.setHostDeleted()
+		}
 		errMessage := fmt.Sprintf("BareMetalHost not found for machine %s", m.Machine.Name)
 		return WithTransientError(errors.New(errMessage), requeueAfter)
 	}
 
+	// Never take over a host that another machine consumes. Drop the stale
+	// annotation so that the next reconcile associates the machine again.
+	if hostConsumedByOther(host, m.Metal3Machine) {
+		m.removeAnnotation()
+		errMessage := fmt.Sprintf("BareMetalHost %s is consumed by another machine", host.Name)
+		return WithTransientError(errors.New(errMessage), requeueAfter)
+	}
+	if host.Spec.ConsumerRef == nil {
+		m.Log.Info("Restoring missing consumerRef on annotated host", "host", host.Name)
+	}
+
 	if err := m.WaitForM3Metadata(ctx); err != nil {
 		return err
 	}
@@ -723,6 +1360,10 @@ func (m *MachineManager) Update(ctx context.Context) error {
 		return err
 	}
 
+	if err := m.setDeleteMachineAnnotation(ctx, host); err != nil {
+		return err
+	}
+
 	m.Log.Info("Finished updating machine")
 	return nil
 }
@@ -746,16 +1387,50 @@ func (m *MachineManager) exists(ctx context.Context) (bool, error) {
 // that contains a reference to the host. Returns nil if not found. Assumes the
 // host is in the same namespace as the machine.
 func (m *MachineManager) getHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
-	host, err := getHost(ctx, m.Metal3Machine, m.client, m.Log)
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	host, err := getHost(ctx, m.Metal3Machine, hostClient, m.Log)
+	if err == nil && host == nil && m.APIReader != nil && !m.remoteHosts && m.HasAnnotation() {
+		// A host relabeled since it was consumed is not in the cache, it is
+		// only deleted if not found by the API server either.
+		host, err = getHost(ctx, m.Metal3Machine, m.APIReader, m.Log)
+	}
 	if err != nil || host == nil {
 		return host, nil, err
 	}
-	helper, err := patch.NewHelper(host, m.client)
+	helper, err := patch.NewHelper(host, hostClient)
 	return host, helper, err
 }
 
-func getHost(ctx context.Context, m3Machine *infrav1.Metal3Machine, cl client.Client,
-	mLog logr.Logger,
+// hosts returns the client of the cluster in which the BareMetalHosts are
+// registered, the cluster of the controller unless HostClientGetter returns
+// another one.
+func (m *MachineManager) hosts(ctx context.Context) (client.Client, error) {
+	if m.hostClient != nil {
+		return m.hostClient, nil
+	}
+	if m.HostClientGetter == nil {
+		if m.Metal3Cluster != nil && m.Metal3Cluster.Spec.HostClusterKubeconfigSecret != nil {
+			return nil, errors.New("the Metal3Cluster references a host cluster but host clusters are not enabled")
+		}
+		m.hostClient = m.client
+		return m.hostClient, nil
+	}
+	hostClient, err := m.HostClientGetter(ctx, m.client, m.Metal3Cluster)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the client of the host cluster")
+	}
+	m.hostClient, m.remoteHosts = m.client, false
+	if hostClient != nil {
+		m.hostClient, m.remoteHosts = hostClient, true
+	}
+	return m.hostClient, nil
+}
+
+func getHost(ctx context.Context, m3Machine *infrav1.Metal3Machine, cl client.Reader,
+	mLog logr.Logger,
 ) (*bmov1alpha1.BareMetalHost, error) {
 	annotations := m3Machine.ObjectMeta.GetAnnotations()
 	if annotations == nil {
@@ -790,17 +1465,43 @@ func getHost(ctx context.Context, m3Machine *infrav1.Metal3Machine, cl client.Cl
 // associated with the metal3 machine. It searches all hosts in case one already has an
 // association with this metal3 machine.
 func (m *MachineManager) chooseHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
-	// get list of BMH.
-	hosts := bmov1alpha1.BareMetalHostList{}
-	// without this ListOption, all namespaces would be including in the listing.
-	opts := &client.ListOptions{
-		Namespace: m.Metal3Machine.Namespace,
+	defer LogDuration(m.Log, "host selection", time.Now())
+	namespaces, err := m.hostNamespaces()
+	if err != nil {
+		return nil, nil, err
 	}
-
-	err := m.client.List(ctx, &hosts, opts)
+	hostClient, err := m.hosts(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
+	// The report is only written with the HostSelectionDebugAnnotation.
+	var chosenHost *bmov1alpha1.BareMetalHost
+	report := m.newHostSelectionReport(namespaces)
+	defer func() { m.setHostSelectionReport(report, chosenHost) }()
+
+	// get list of BMH.
+	hosts := bmov1alpha1.BareMetalHostList{}
+	for _, namespace := range namespaces {
+		namespaceHosts := bmov1alpha1.BareMetalHostList{}
+		// without this ListOption, all namespaces would be including in the listing.
+		opts := &client.ListOptions{
+			Namespace: namespace,
+		}
+
+		err := hostClient.List(ctx, &namespaceHosts, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The hosts excluded by the manager-level selector are ignored, and
+		// not counted in the rejected hosts.
+		for i := range namespaceHosts.Items {
+			if m.HostLabelSelector == nil || m.HostLabelSelector.Matches(labels.Set(namespaceHosts.Items[i].Labels)) {
+				hosts.Items = append(hosts.Items, namespaceHosts.Items[i])
+			} else {
+				report.add(&namespaceHosts.Items[i], hostExcludedBySelector, m.HostLabelSelector.String())
+			}
+		}
+	}
 
 	// Using the label selector on ListOptions above doesn't seem to work.
 	// I think it's because we have a local cache of all BareMetalHosts.
@@ -833,25 +1534,57 @@ func (m *MachineManager) chooseHost(ctx context.Context) (*bmov1alpha1.BareMetal
 	}
 	labelSelector = labelSelector.Add(reqs...)
 
+	// The host of a Machine with a failure domain is chosen in the failure
+	// domain.
+	failureDomain := ""
+	var failureDomainReq *labels.Requirement
+	if m.Machine != nil && m.Machine.Spec.FailureDomain != nil && *m.Machine.Spec.FailureDomain != "" {
+		failureDomain = *m.Machine.Spec.FailureDomain
+		failureDomainReq, err = labels.NewRequirement(failureDomainLabel(m.Metal3Cluster), selection.Equals,
+			[]string{failureDomain})
+		if err != nil {
+			m.Log.Error(err, "Failed to create the failure domain requirement, not choosing host")
+			return nil, nil, err
+		}
+	}
+
 	availableHosts := []*bmov1alpha1.BareMetalHost{}
 	availableHostsWithNodeReuse := []*bmov1alpha1.BareMetalHost{}
+	rejected := hostRejections{}
 
 	for i, host := range hosts.Items {
 		host := host
 		if host.Spec.ConsumerRef != nil && consumerRefMatches(host.Spec.ConsumerRef, m.Metal3Machine) {
 			m.Log.Info("Found host with existing ConsumerRef", "host", host.Name)
-			helper, err := patch.NewHelper(&hosts.Items[i], m.client)
-			return &hosts.Items[i], helper, err
+			chosenHost = &hosts.Items[i]
+			helper, err := patch.NewHelper(chosenHost, hostClient)
+			return chosenHost, helper, err
+		}
+		if host.Spec.ConsumerRef != nil {
+			rejected.consumed++
+			report.add(&host, hostConsumed, host.Spec.ConsumerRef.Namespace+"/"+host.Spec.ConsumerRef.Name)
+			continue
+		}
+		// The cache may not show yet the consumer of a host lost while
+		// claiming it.
+		if m.lostHosts[host.Namespace+"/"+host.Name] {
+			rejected.consumed++
+			report.add(&host, hostConsumed, "")
+			continue
 		}
-		if host.Spec.ConsumerRef != nil ||
-			(m.nodeReuseLabelExists(ctx, &host) &&
-				!m.nodeReuseLabelMatches(ctx, &host)) {
+		if m.nodeReuseLabelExists(ctx, &host) && !m.nodeReuseLabelMatches(ctx, &host) {
+			rejected.reserved++
+			report.add(&host, hostReserved, getLabel(host.Labels, nodeReuseLabelName))
 			continue
 		}
 		if host.GetDeletionTimestamp() != nil {
+			rejected.deleting++
+			report.add(&host, hostDeleting, "")
 			continue
 		}
 		if host.Status.ErrorMessage != "" {
+			rejected.inError++
+			report.add(&host, hostInError, string(host.Status.ErrorType))
 			continue
 		}
 
@@ -859,285 +1592,1891 @@ func (m *MachineManager) chooseHost(ctx context.Context) (*bmov1alpha1.BareMetal
 		annotations := host.GetAnnotations()
 		if annotations != nil {
 			if _, ok := annotations[bmov1alpha1.PausedAnnotation]; ok {
+				rejected.paused++
+				report.add(&host, hostPaused, "")
 				continue
 			}
 			if _, ok := annotations[infrav1.UnhealthyAnnotation]; ok {
+				rejected.unhealthy++
+				report.add(&host, hostUnhealthy, "")
+				continue
+			}
+			if _, ok := annotations[bmov1alpha1.DetachedAnnotation]; ok {
+				rejected.detached++
+				report.add(&host, hostDetached, "")
+				continue
+			}
+			if taint, ok := m.untoleratedHostTaint(&host); ok {
+				rejected.tainted++
+				report.add(&host, hostTainted, taint)
 				continue
 			}
 		}
 
 		if labelSelector.Matches(labels.Set(host.ObjectMeta.Labels)) {
+			if failureDomainReq != nil && !failureDomainReq.Matches(labels.Set(host.ObjectMeta.Labels)) {
+				m.Log.Info("Host is not in the failure domain of the Machine", "host", host.Name, "failureDomain", failureDomain)
+				rejected.outOfFailureDomain++
+				report.add(&host, hostOutOfFailureDomain, hostFailureDomain(m.Metal3Cluster, &host))
+				continue
+			}
+			if selector := m.Metal3Machine.Spec.HostSelector.MatchHardware; selector != nil {
+				if host.Status.HardwareDetails == nil {
+					m.Log.Info("Host not inspected, cannot match its hardware", "host", host.Name)
+					rejected.notInspected++
+					report.add(&host, hostNotInspected, "")
+					continue
+				}
+				if criterion, detail := hardwareMismatch(selector, host.Status.HardwareDetails); criterion != "" {
+					m.Log.Info("Host hardware did not match hostSelector for Metal3Machine", "host", host.Name, "criterion", criterion)
+					rejected.addHardwareMismatch(criterion)
+					report.add(&host, hostHardwareMismatch, detail)
+					continue
+				}
+			}
 			if m.nodeReuseLabelExists(ctx, &host) && m.nodeReuseLabelMatches(ctx, &host) {
 				m.Log.Info("Found host with nodeReuseLabelName and it matches, adding it to availableHostsWithNodeReuse list", "host", host.Name)
 				availableHostsWithNodeReuse = append(availableHostsWithNodeReuse, &hosts.Items[i])
+				report.add(&host, hostAvailable, string(host.Status.Provisioning.State))
 			} else if !m.nodeReuseLabelExists(ctx, &host) {
 				switch host.Status.Provisioning.State {
 				case bmov1alpha1.StateReady, bmov1alpha1.StateAvailable:
 				default:
+					rejected.notAvailable++
+					report.add(&host, hostNotAvailable, string(host.Status.Provisioning.State))
 					continue
 				}
 				m.Log.Info("Host matched hostSelector for Metal3Machine, adding it to availableHosts list", "host", host.Name)
 				availableHosts = append(availableHosts, &hosts.Items[i])
+				report.add(&host, hostAvailable, string(host.Status.Provisioning.State))
 			}
 		} else {
 			m.Log.Info("Host did not match hostSelector for Metal3Machine", "host", host.Name)
+			rejected.labelMismatch++
+			report.add(&host, hostLabelMismatch, failingRequirementKey(reqs, host.Labels))
 		}
 	}
 
 	m.Log.Info("Host count available with nodeReuseLabelName while choosing host for Metal3 machine", "hostcount", len(availableHostsWithNodeReuse))
 	m.Log.Info("Host count available while choosing host for Metal3 machine", "hostcount", len(availableHosts))
 	if len(availableHostsWithNodeReuse) == 0 && len(availableHosts) == 0 {
-		return nil, nil, nil
+		m.Log.Info("No available host found. Requeuing.", "rejected", rejected.String())
+		if failureDomain != "" {
+			return nil, nil, WithTransientError(
+				fmt.Errorf("%w %s: %s", ErrNoAvailableHostInFailureDomain, failureDomain, rejected.String()),
+				m.noAvailableHostRequeueAfter(),
+			)
+		}
+		return nil, nil, WithTransientError(
+			fmt.Errorf("%w: %s", ErrNoAvailableHost, rejected.String()),
+			m.noAvailableHostRequeueAfter(),
+		)
 	}
 
-	// choose a host.
-	var chosenHost *bmov1alpha1.BareMetalHost
-
-	// If there are hosts with nodeReuseLabelName:
+	// If there are hosts with nodeReuseLabelName, pick one in Ready/Available
+	// state. If they are all still deprovisioning, wait for them when node
+	// reuse is enabled, otherwise fall back to the other hosts. Without the
+	// Metal3MachineTemplate, keep waiting for them.
 	if len(availableHostsWithNodeReuse) != 0 {
+		hostsInAvailableStateWithNodeReuse := []*bmov1alpha1.BareMetalHost{}
 		for _, host := range availableHostsWithNodeReuse {
-			// Build list of hosts in Ready state with nodeReuseLabelName
-			hostsInAvailableStateWithNodeReuse := []*bmov1alpha1.BareMetalHost{}
-			// Build list of hosts in any other state than Ready state with nodeReuseLabelName
-			hostsInNotAvailableStateWithNodeReuse := []*bmov1alpha1.BareMetalHost{}
 			if host.Status.Provisioning.State == bmov1alpha1.StateReady || host.Status.Provisioning.State == bmov1alpha1.StateAvailable {
 				hostsInAvailableStateWithNodeReuse = append(hostsInAvailableStateWithNodeReuse, host)
-			} else {
-				hostsInNotAvailableStateWithNodeReuse = append(hostsInNotAvailableStateWithNodeReuse, host)
 			}
-
-			// If host is found in `Ready` state, pick it
-			if len(hostsInAvailableStateWithNodeReuse) != 0 {
-				m.Log.Info("Found host(s) with nodeReuseLabelName in Ready/Available state, choosing the host", "availabeHostCount", len(hostsInAvailableStateWithNodeReuse), "host", host.Name)
-				rHost, _ := rand.Int(rand.Reader, big.NewInt(int64(len(hostsInAvailableStateWithNodeReuse))))
-				randomHost := rHost.Int64()
-				chosenHost = hostsInAvailableStateWithNodeReuse[randomHost]
-			} else if len(hostsInNotAvailableStateWithNodeReuse) != 0 {
-				errMessage := fmt.Sprint("Found BareMetalHost(s) with nodeReuseLabelName in not-available state, requeuing the BareMetalHost", "notAvailabeHostCount", len(hostsInNotAvailableStateWithNodeReuse), "hoststate", host.Status.Provisioning.State, "host", host.Name)
-				m.Log.Info(errMessage)
-				return nil, nil, WithTransientError(errors.New(errMessage), requeueAfter)
+		}
+		if len(hostsInAvailableStateWithNodeReuse) != 0 {
+			m.Log.Info("Found host(s) with nodeReuseLabelName in Ready/Available state, choosing the host", "availabeHostCount", len(hostsInAvailableStateWithNodeReuse))
+			chosenHost, err = m.pickSpreadHost(ctx, hostsInAvailableStateWithNodeReuse, hosts.Items)
+			if err != nil {
+				return nil, nil, err
 			}
+		} else if m3mt := m.getMetal3MachineTemplate(ctx); m3mt == nil || m3mt.Spec.NodeReuse || len(availableHosts) == 0 {
+			host := availableHostsWithNodeReuse[0]
+			errMessage := fmt.Sprint("Found BareMetalHost(s) with nodeReuseLabelName in not-available state, requeuing the BareMetalHost", "notAvailabeHostCount", len(availableHostsWithNodeReuse), "hoststate", host.Status.Provisioning.State, "host", host.Name)
+			m.Log.Info(errMessage)
+			return nil, nil, WithTransientError(errors.New(errMessage), requeueAfter)
 		}
-	} else {
+	}
+	if chosenHost == nil {
 		// If there are no hosts with nodeReuseLabelName, fall back
-		// to the current flow and select hosts randomly.
-		m.Log.Info("host(s) count available, choosing a random host", "availabeHostCount", len(availableHosts))
-		rHost, _ := rand.Int(rand.Reader, big.NewInt(int64(len(availableHosts))))
-		randomHost := rHost.Int64()
-		chosenHost = availableHosts[randomHost]
+		// to the current flow and select among all the available hosts.
+		m.Log.Info("host(s) count available, choosing a host", "availabeHostCount", len(availableHosts))
+		chosenHost, err = m.pickSpreadHost(ctx, availableHosts, hosts.Items)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	helper, err := patch.NewHelper(chosenHost, m.client)
+	helper, err := patch.NewHelper(chosenHost, hostClient)
 	return chosenHost, helper, err
 }
 
-// consumerRefMatches returns a boolean based on whether the consumer
-// reference and bare metal machine metadata match.
-func consumerRefMatches(consumer *corev1.ObjectReference, m3machine *infrav1.Metal3Machine) bool {
-	if consumer.Name != m3machine.Name {
-		return false
-	}
-	if consumer.Namespace != m3machine.Namespace {
-		return false
-	}
-	if consumer.Kind != m3machine.Kind {
-		return false
-	}
-	if consumer.GroupVersionKind().Group != m3machine.GroupVersionKind().Group {
-		return false
+// chooseAndClaimHost chooses a host for the Metal3Machine, or the host it
+// adopts, and claims it. A host changed since it was read from the cache may
+// have been claimed by a Metal3Machine reconciled concurrently, another host
+// is chosen, up to maxHostClaimAttempts times.
+func (m *MachineManager) chooseAndClaimHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
+	for attempt := 1; ; attempt++ {
+		var host *bmov1alpha1.BareMetalHost
+		var err error
+		if isAdoptingHost(m.Metal3Machine) {
+			host, _, err = m.adoptHost(ctx)
+		} else {
+			host, _, err = m.chooseHost(ctx)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if host == nil {
+			m.Log.Info("No available host found. Requeuing.")
+			return nil, nil, WithTransientError(ErrNoAvailableHost, m.noAvailableHostRequeueAfter())
+		}
+		chosen := host.Name
+		host, helper, err := m.claimHost(ctx, host)
+		if err != nil || host != nil {
+			return host, helper, err
+		}
+		m.Log.Info("Chosen host was claimed by another consumer", "host", chosen, "attempt", attempt)
+		if isAdoptingHost(m.Metal3Machine) || attempt == maxHostClaimAttempts {
+			return nil, nil, WithTransientError(
+				fmt.Errorf("%w: the chosen BareMetalHost was claimed by another consumer", ErrNoAvailableHost),
+				requeueAfter,
+			)
+		}
 	}
-	return true
 }
 
-// nodeReuseLabelMatches returns true if nodeReuseLabelName matches KubeadmControlPlane or MachineDeployment name on the host.
-func (m *MachineManager) nodeReuseLabelMatches(ctx context.Context, host *bmov1alpha1.BareMetalHost) bool {
-	if host == nil {
-		return false
-	}
-	if host.Labels == nil {
-		return false
+// claimHost sets the consumerRef of the Metal3Machine on the host, unless it
+// is already set, and returns the host with a new patch helper. It returns a
+// nil host if the host changed since it was read, then recorded as lost.
+func (m *MachineManager) claimHost(ctx context.Context, host *bmov1alpha1.BareMetalHost) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
-	if m.isControlPlane() {
-		kcp, err := m.getKubeadmControlPlaneName(ctx)
+	if host.Spec.ConsumerRef == nil || !consumerRefMatches(host.Spec.ConsumerRef, m.Metal3Machine) {
+		claimed, err := claimHost(ctx, hostClient, host, m.hostConsumerRef())
 		if err != nil {
-			return false
-		}
-		if host.Labels[nodeReuseLabelName] == "" {
-			return false
+			return nil, nil, errors.Wrapf(err, "failed to claim BareMetalHost %s", host.Name)
 		}
-		if host.Labels[nodeReuseLabelName] != kcp {
-			return false
+		if !claimed {
+			if m.lostHosts == nil {
+				m.lostHosts = map[string]bool{}
+			}
+			m.lostHosts[host.Namespace+"/"+host.Name] = true
+			return nil, nil, nil
 		}
-		m.Log.Info("nodeReuseLabelName on the host matches KubeadmControlPlane name", "host", host.Name, "kubeadmControlPlane", kcp)
-		return true
 	}
-	md, err := m.getMachineDeploymentName(ctx)
+	helper, err := patch.NewHelper(host, hostClient)
 	if err != nil {
-		return false
-	}
-	if host.Labels[nodeReuseLabelName] == "" {
-		return false
-	}
-	if host.Labels[nodeReuseLabelName] != md {
-		return false
+		return nil, nil, err
 	}
-	m.Log.Info("nodeReuseLabelName on the host matches MachineDeployment", "host", host.Name, "machinedeployment", md)
-	return true
+	return host, helper, nil
 }
 
-// nodeReuseLabelExists returns true if host contains nodeReuseLabelName label.
-func (m *MachineManager) nodeReuseLabelExists(_ context.Context, host *bmov1alpha1.BareMetalHost) bool {
-	if host == nil {
-		return false
-	}
-	if host.Labels == nil {
-		return false
+// claimHost sets the consumerRef of a free host, with an optimistic lock on
+// the resourceVersion it was read with. Hosts are chosen from the cache, so
+// two consumers reconciled concurrently may choose the same host, only the
+// first one claims it. It returns false, leaving the host unchanged, if the
+// host changed or was deleted since it was read.
+func claimHost(ctx context.Context, cl client.Client, host *bmov1alpha1.BareMetalHost,
+	consumerRef *corev1.ObjectReference,
+) (bool, error) {
+	base := host.DeepCopy()
+	host.Spec.ConsumerRef = consumerRef
+	err := cl.Patch(ctx, host, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		base.DeepCopyInto(host)
+		return false, nil
 	}
-	_, ok := host.Labels[nodeReuseLabelName]
-	if ok {
-		m.Log.Info("nodeReuseLabelName exists on the host", "host", host.Name)
+	if err != nil {
+		base.DeepCopyInto(host)
+		return false, err
 	}
-	return ok
+	return true, nil
 }
 
-// getBMCSecret will return the BMCSecret associated with BMH.
-func (m *MachineManager) getBMCSecret(ctx context.Context, host *bmov1alpha1.BareMetalHost) (*corev1.Secret, error) {
-	if host == nil || host.Spec.BMC.CredentialsName == "" {
-		return nil, nil
+// hostNamespaces returns the namespaces in which the hosts of the
+// Metal3Machine are chosen: its hostNamespace if set, otherwise its own
+// namespace and the BMHNamespaces.
+func (m *MachineManager) hostNamespaces() ([]string, error) {
+	hostNamespace := m.Metal3Machine.Spec.HostNamespace
+	if hostNamespace != "" {
+		if !hostNamespaceAllowed(m.Metal3Machine, hostNamespace) {
+			return nil, fmt.Errorf("%w: %s is not in the namespaces allowed with --bmh-namespaces", ErrHostNamespaceNotAllowed, hostNamespace)
+		}
+		return []string{hostNamespace}, nil
 	}
-	tmpBMCSecret := corev1.Secret{}
-	key := host.CredentialsKey()
-	err := m.client.Get(ctx, key, &tmpBMCSecret)
-	if err != nil {
-		m.Log.Error(err, "Cannot retrieve BMC credential for BareMetalhost", "host", host.Name)
-		return nil, err
+	namespaces := []string{m.Metal3Machine.Namespace}
+	for _, namespace := range BMHNamespaces {
+		if namespace != m.Metal3Machine.Namespace {
+			namespaces = append(namespaces, namespace)
+		}
 	}
-	return &tmpBMCSecret, nil
+	return namespaces, nil
 }
 
-// setBMCSecretLabel will set the set cluster.x-k8s.io/cluster-name to BMCSecret.
-func (m *MachineManager) setBMCSecretLabel(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
-	tmpBMCSecret, err := m.getBMCSecret(ctx, host)
-	if err != nil {
-		return err
+// hostNamespaceAllowed returns true if the Metal3Machine can consume the
+// hosts of the namespace.
+func hostNamespaceAllowed(m3m *infrav1.Metal3Machine, namespace string) bool {
+	return namespace == m3m.Namespace || Contains(BMHNamespaces, namespace)
+}
+
+// hostSelectionPolicy returns the host selection policy of the Metal3Cluster,
+// defaulting to random.
+func (m *MachineManager) hostSelectionPolicy() infrav1.HostSelectionPolicy {
+	if m.Metal3Cluster == nil || m.Metal3Cluster.Spec.HostSelectionPolicy == "" {
+		return infrav1.HostSelectionRandom
 	}
+	return m.Metal3Cluster.Spec.HostSelectionPolicy
+}
 
-	if tmpBMCSecret != nil {
-		if tmpBMCSecret.Labels == nil {
-			tmpBMCSecret.Labels = make(map[string]string)
+// pickHost picks one of the given hosts, that all passed the filters of
+// chooseHost, following the host selection policy of the Metal3Cluster.
+func (m *MachineManager) pickHost(hosts []*bmov1alpha1.BareMetalHost) *bmov1alpha1.BareMetalHost {
+	policy := m.hostSelectionPolicy()
+	var chosenHost *bmov1alpha1.BareMetalHost
+	switch policy {
+	case infrav1.HostSelectionLeastRecentlyUsed, infrav1.HostSelectionNewestInspectionFirst,
+		infrav1.HostSelectionLeastProvisioned:
+		sorted := make([]*bmov1alpha1.BareMetalHost, len(hosts))
+		copy(sorted, hosts)
+		less := lessLeastRecentlyUsed
+		switch policy {
+		case infrav1.HostSelectionNewestInspectionFirst:
+			less = lessNewestInspection
+		case infrav1.HostSelectionLeastProvisioned:
+			less = lessLeastProvisioned
 		}
-		tmpBMCSecret.Labels[clusterv1.ClusterNameLabel] = m.Machine.Spec.ClusterName
-		return updateObject(ctx, m.client, tmpBMCSecret)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if less(sorted[i], sorted[j]) {
+				return true
+			}
+			if less(sorted[j], sorted[i]) {
+				return false
+			}
+			return sorted[i].Name < sorted[j].Name
+		})
+		chosenHost = sorted[0]
+	default:
+		rHost, _ := rand.Int(rand.Reader, big.NewInt(int64(len(hosts))))
+		chosenHost = hosts[rHost.Int64()]
 	}
-
-	return nil
+	m.Log.Info("Chose host", "host", chosenHost.Name, "hostSelectionPolicy", policy)
+	return chosenHost
 }
 
-// setHostLabel will set the set cluster.x-k8s.io/cluster-name to bmh.
-func (m *MachineManager) setHostLabel(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
-	if host.Labels == nil {
-		host.Labels = make(map[string]string)
+// preferredHosts returns the hosts with the highest score for the
+// preferences of the hostSelector, or all the hosts without preferences.
+func (m *MachineManager) preferredHosts(hosts []*bmov1alpha1.BareMetalHost) []*bmov1alpha1.BareMetalHost {
+	preferences := m.Metal3Machine.Spec.HostSelector.Preferences
+	if len(preferences) == 0 {
+		return hosts
+	}
+	preferred := []*bmov1alpha1.BareMetalHost{}
+	bestScore := int32(-1)
+	for _, host := range hosts {
+		score := hostScore(preferences, host)
+		switch {
+		case score > bestScore:
+			preferred = []*bmov1alpha1.BareMetalHost{host}
+			bestScore = score
+		case score == bestScore:
+			preferred = append(preferred, host)
+		}
 	}
-	host.Labels[clusterv1.ClusterNameLabel] = m.Machine.Spec.ClusterName
+	m.Log.Info("Preferred host(s) by the hostSelector preferences", "score", bestScore, "hostCount", len(preferred))
+	return preferred
+}
 
-	return nil
+// spreadChoices records the host chosen for each Metal3Machine spread across a
+// topology until the cache shows the host consumed, so that the machines of the
+// same owner reconciled at the same time spread across the topology. The
+// choices are made one at a time.
+var spreadChoices = &recordedSpreadChoices{choices: map[types.NamespacedName]spreadChoice{}}
+
+type recordedSpreadChoices struct {
+	lock    sync.Mutex
+	choices map[types.NamespacedName]spreadChoice
 }
 
-// setHostSpec will ensure the host's Spec is set according to the machine's
-// details. It will then update the host via the kube API. If UserData does not
-// include a Namespace, it will default to the Metal3Machine's namespace.
-func (m *MachineManager) setHostSpec(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
-	// We only want to update the image setting if the host does not
-	// already have an image.
-	//
-	// A host with an existing image is already provisioned and
-	// upgrades are not supported at this time. To re-provision a
-	// host, we must fully deprovision it and then provision it again.
-	// Not provisioning while we do not have the UserData.
-	if host.Spec.Image == nil && m.Metal3Machine.Status.UserData != nil {
-		checksumType := ""
-		if m.Metal3Machine.Spec.Image.ChecksumType != nil {
-			checksumType = *m.Metal3Machine.Spec.Image.ChecksumType
-		}
-		host.Spec.Image = &bmov1alpha1.Image{
-			URL:          m.Metal3Machine.Spec.Image.URL,
-			Checksum:     m.Metal3Machine.Spec.Image.Checksum,
-			ChecksumType: bmov1alpha1.ChecksumType(checksumType),
-			DiskFormat:   m.Metal3Machine.Spec.Image.DiskFormat,
-		}
-		host.Spec.UserData = m.Metal3Machine.Status.UserData
-		if host.Spec.UserData != nil && host.Spec.UserData.Namespace == "" {
-			host.Spec.UserData.Namespace = host.Namespace
+// spreadChoice is the host chosen for a Metal3Machine, with the value of its
+// spreadTopologyKey label.
+type spreadChoice struct {
+	owner   string
+	hostUID types.UID
+	value   string
+	chosen  time.Time
+}
+
+// pickSpreadHost picks one of the candidate hosts, among the hosts spread
+// across the topology of the spreadTopologyKey of the Metal3MachineTemplate.
+func (m *MachineManager) pickSpreadHost(ctx context.Context, candidates []*bmov1alpha1.BareMetalHost,
+	hosts []bmov1alpha1.BareMetalHost,
+) (*bmov1alpha1.BareMetalHost, error) {
+	m3mt := m.getMetal3MachineTemplate(ctx)
+	if m3mt == nil || m3mt.Spec.SpreadTopologyKey == "" {
+		return m.pickHost(m.preferredHosts(candidates)), nil
+	}
+	spreadChoices.lock.Lock()
+	defer spreadChoices.lock.Unlock()
+	spreadHosts, err := m.spreadHosts(ctx, candidates, hosts)
+	if err != nil {
+		return nil, err
+	}
+	chosenHost := m.pickHost(m.preferredHosts(spreadHosts))
+	if owner := m.spreadOwner(); owner != "" {
+		spreadChoices.choices[client.ObjectKeyFromObject(m.Metal3Machine)] = spreadChoice{
+			owner:   owner,
+			hostUID: chosenHost.UID,
+			value:   chosenHost.Labels[m3mt.Spec.SpreadTopologyKey],
+			chosen:  time.Now(),
 		}
+	}
+	return chosenHost, nil
+}
 
-		// Set metadata from gathering from Spec.metadata and from the template.
-		if m.Metal3Machine.Status.MetaData != nil {
-			host.Spec.MetaData = m.Metal3Machine.Status.MetaData
+// spreadHosts returns the candidate hosts whose value of the
+// spreadTopologyKey label of the Metal3MachineTemplate is shared by the fewest
+// hosts of the other machines of the same MachineDeployment or
+// KubeadmControlPlane, or all the candidates without spreadTopologyKey. The
+// hosts without the label share the empty value. The hosts chosen for the
+// other machines that the cache does not show consumed yet are counted too,
+// the caller holds the lock of the spreadChoices.
+func (m *MachineManager) spreadHosts(ctx context.Context, candidates []*bmov1alpha1.BareMetalHost,
+	hosts []bmov1alpha1.BareMetalHost,
+) ([]*bmov1alpha1.BareMetalHost, error) {
+	if len(candidates) < 2 {
+		return candidates, nil
+	}
+	m3mt := m.getMetal3MachineTemplate(ctx)
+	if m3mt == nil || m3mt.Spec.SpreadTopologyKey == "" {
+		return candidates, nil
+	}
+	topologyKey := m3mt.Spec.SpreadTopologyKey
+	siblings, err := m.siblingMetal3MachineNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	machinesPerValue := map[string]int{}
+	consumed := map[types.UID]bool{}
+	for i := range hosts {
+		consumerRef := hosts[i].Spec.ConsumerRef
+		if consumerRef != nil {
+			consumed[hosts[i].UID] = true
 		}
-		if host.Spec.MetaData != nil && host.Spec.MetaData.Namespace == "" {
-			host.Spec.MetaData.Namespace = m.Machine.Namespace
+		if consumerRef == nil || consumerRef.Kind != "Metal3Machine" ||
+			consumerRef.Namespace != m.Metal3Machine.Namespace || !siblings[consumerRef.Name] {
+			continue
 		}
-		if m.Metal3Machine.Status.NetworkData != nil {
-			host.Spec.NetworkData = m.Metal3Machine.Status.NetworkData
+		machinesPerValue[hosts[i].Labels[topologyKey]]++
+	}
+	owner := m.spreadOwner()
+	for key, choice := range spreadChoices.choices {
+		if consumed[choice.hostUID] || time.Since(choice.chosen) > inFlightHostTimeout {
+			delete(spreadChoices.choices, key)
+			continue
 		}
-		if host.Spec.NetworkData != nil && host.Spec.NetworkData.Namespace == "" {
-			host.Spec.NetworkData.Namespace = m.Machine.Namespace
+		if choice.owner == owner && key.Namespace == m.Metal3Machine.Namespace && siblings[key.Name] {
+			machinesPerValue[choice.value]++
 		}
 	}
-	// Set automatedCleaningMode from metal3Machine.spec.automatedCleaningMode.
-	if m.Metal3Machine.Spec.AutomatedCleaningMode != nil {
-		if host.Spec.AutomatedCleaningMode != bmov1alpha1.AutomatedCleaningMode(*m.Metal3Machine.Spec.AutomatedCleaningMode) {
-			host.Spec.AutomatedCleaningMode = bmov1alpha1.AutomatedCleaningMode(*m.Metal3Machine.Spec.AutomatedCleaningMode)
+	spread := []*bmov1alpha1.BareMetalHost{}
+	fewest := -1
+	for _, host := range candidates {
+		count := machinesPerValue[host.Labels[topologyKey]]
+		switch {
+		case fewest == -1 || count < fewest:
+			spread = []*bmov1alpha1.BareMetalHost{host}
+			fewest = count
+		case count == fewest:
+			spread = append(spread, host)
 		}
 	}
-
-	host.Spec.Online = true
-
-	return nil
+	m.Log.Info("Spread host(s) across the topology", "topologyKey", topologyKey,
+		"machinesPerValue", fewest, "hostCount", len(spread))
+	return spread, nil
 }
 
-// setHostConsumerRef will ensure the host's Spec is set to link to this
-// Metal3Machine.
-func (m *MachineManager) setHostConsumerRef(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
-	host.Spec.ConsumerRef = &corev1.ObjectReference{
-		Kind:       "Metal3Machine",
-		Name:       m.Metal3Machine.Name,
-		Namespace:  m.Metal3Machine.Namespace,
-		APIVersion: m.Metal3Machine.APIVersion,
+// spreadOwnerLabels returns the labels set by Cluster API on the Machines of
+// the MachineDeployment or KubeadmControlPlane of the Machine, nil when the
+// Machine belongs to neither.
+func (m *MachineManager) spreadOwnerLabels() client.MatchingLabels {
+	if m.Machine == nil {
+		return nil
+	}
+	matchingLabels := client.MatchingLabels{clusterv1.ClusterNameLabel: m.Machine.Spec.ClusterName}
+	if name, ok := m.Machine.Labels[clusterv1.MachineControlPlaneNameLabel]; ok && m.isControlPlane() {
+		matchingLabels[clusterv1.MachineControlPlaneNameLabel] = name
+	} else if name, ok := m.Machine.Labels[clusterv1.MachineDeploymentNameLabel]; ok {
+		matchingLabels[clusterv1.MachineDeploymentNameLabel] = name
+	} else {
+		return nil
 	}
+	return matchingLabels
+}
 
-	// Set OwnerReferences.
-	hostOwnerReferences, err := m.SetOwnerRef(host.OwnerReferences, true)
-	if err != nil {
-		return err
+// spreadOwner returns a key of the MachineDeployment or KubeadmControlPlane of
+// the Machine, empty when the Machine belongs to neither.
+func (m *MachineManager) spreadOwner() string {
+	matchingLabels := m.spreadOwnerLabels()
+	if matchingLabels == nil {
+		return ""
 	}
-	host.OwnerReferences = hostOwnerReferences
+	return fmt.Sprintf("%s/%v", m.Machine.Namespace, map[string]string(matchingLabels))
+}
 
-	// Delete nodeReuseLabelName from host.
-	m.Log.Info("Deleting nodeReuseLabelName from host, if any")
+// siblingMetal3MachineNames returns the names of the Metal3Machines of the
+// other Machines of the MachineDeployment or KubeadmControlPlane of the
+// Machine, from the labels set by Cluster API on the Machines. The Machines
+// being deleted are left out, their hosts are about to be released.
+func (m *MachineManager) siblingMetal3MachineNames(ctx context.Context) (map[string]bool, error) {
+	names := map[string]bool{}
+	matchingLabels := m.spreadOwnerLabels()
+	if matchingLabels == nil {
+		return names, nil
+	}
+	machines := &clusterv1.MachineList{}
+	if err := m.client.List(ctx, machines, client.InNamespace(m.Machine.Namespace), matchingLabels); err != nil {
+		return nil, err
+	}
+	for _, machine := range machines.Items {
+		if machine.Name == m.Machine.Name || machine.Spec.InfrastructureRef.Kind != "Metal3Machine" ||
+			!machine.DeletionTimestamp.IsZero() {
+			continue
+		}
+		names[machine.Spec.InfrastructureRef.Name] = true
+	}
+	return names, nil
+}
 
-	labels := host.GetLabels()
-	if labels != nil {
-		if _, ok := labels[nodeReuseLabelName]; ok {
-			delete(host.Labels, nodeReuseLabelName)
-			m.Log.Info("Finished deleting nodeReuseLabelName")
+// hostScore returns the sum of the weights of the preferences matched by the
+// host.
+func hostScore(preferences []infrav1.HostSelectorPreference, host *bmov1alpha1.BareMetalHost) int32 {
+	var score int32
+	for _, preference := range preferences {
+		if hostPreferenceMatches(preference, host) {
+			score += preference.Weight
 		}
 	}
+	return score
+}
 
-	return nil
+// hostPreferenceMatches returns true if the host matches all the criteria of
+// the preference. An invalid match expression, rejected by the webhook, does
+// not match.
+func hostPreferenceMatches(preference infrav1.HostSelectorPreference, host *bmov1alpha1.BareMetalHost) bool {
+	for _, req := range preference.MatchExpressions {
+		lowercaseOperator := selection.Operator(strings.ToLower(string(req.Operator)))
+		r, err := labels.NewRequirement(req.Key, lowercaseOperator, req.Values)
+		if err != nil || !r.Matches(labels.Set(host.Labels)) {
+			return false
+		}
+	}
+	if preference.MatchHardware != nil {
+		if host.Status.HardwareDetails == nil {
+			return false
+		}
+		if criterion, _ := hardwareMismatch(preference.MatchHardware, host.Status.HardwareDetails); criterion != "" {
+			return false
+		}
+	}
+	return true
 }
 
-// ensureAnnotation makes sure the machine has an annotation that references the
-// host and uses the API to update the machine if necessary.
-func (m *MachineManager) ensureAnnotation(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
-	annotations := m.Metal3Machine.ObjectMeta.GetAnnotations()
-	if annotations == nil {
-		annotations = make(map[string]string)
+// hostLastReleased returns the time at which the host was last released by
+// a Metal3Machine, and false if it never was or the annotation is invalid.
+func hostLastReleased(host *bmov1alpha1.BareMetalHost) (time.Time, bool) {
+	value, ok := host.Annotations[infrav1.HostLastReleasedAnnotation]
+	if !ok {
+		return time.Time{}, false
 	}
-	hostKey, err := cache.MetaNamespaceKeyFunc(host)
+	released, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		m.Log.Error(err, "Error parsing annotation value", "annotation key", hostKey)
-		return err
+		return time.Time{}, false
+	}
+	return released, true
+}
+
+// lessLeastRecentlyUsed orders the hosts never released first, then by
+// oldest release.
+func lessLeastRecentlyUsed(a, b *bmov1alpha1.BareMetalHost) bool {
+	aReleased, aOk := hostLastReleased(a)
+	bReleased, bOk := hostLastReleased(b)
+	if !aOk || !bOk {
+		return !aOk && bOk
+	}
+	return aReleased.Before(bReleased)
+}
+
+// setDataSecretsOwnerLabel sets the DataSecretsOwnerLabel on the host to the
+// Metal3Data rendered for the Metal3Machine, if the host references its
+// secrets, or removes it.
+func setDataSecretsOwnerLabel(host *bmov1alpha1.BareMetalHost, m3m *infrav1.Metal3Machine) {
+	if m3m.Status.RenderedData == nil || host.Namespace != m3m.Namespace ||
+		(host.Spec.MetaData == nil && host.Spec.NetworkData == nil) {
+		delete(host.Labels, infrav1.DataSecretsOwnerLabel)
+		return
+	}
+	if host.Labels == nil {
+		host.Labels = map[string]string{}
+	}
+	host.Labels[infrav1.DataSecretsOwnerLabel] = m3m.Status.RenderedData.Name
+}
+
+// hostProvisionCount returns the number of times the host was provisioned by
+// a Metal3Machine, 0 if it never was or the annotation is invalid.
+func hostProvisionCount(host *bmov1alpha1.BareMetalHost) int {
+	count, err := strconv.Atoi(host.Annotations[infrav1.HostProvisionCountAnnotation])
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// lessLeastProvisioned orders the hosts by fewest provisionings, then as
+// lessLeastRecentlyUsed.
+func lessLeastProvisioned(a, b *bmov1alpha1.BareMetalHost) bool {
+	aCount, bCount := hostProvisionCount(a), hostProvisionCount(b)
+	if aCount != bCount {
+		return aCount < bCount
+	}
+	return lessLeastRecentlyUsed(a, b)
+}
+
+// lessNewestInspection orders the hosts by most recent end of inspection,
+// the hosts never inspected last.
+func lessNewestInspection(a, b *bmov1alpha1.BareMetalHost) bool {
+	aInspected := a.Status.OperationHistory.Inspect.End
+	bInspected := b.Status.OperationHistory.Inspect.End
+	if aInspected.IsZero() || bInspected.IsZero() {
+		return !aInspected.IsZero() && bInspected.IsZero()
+	}
+	return bInspected.Before(&aInspected)
+}
+
+// hostRejections counts the hosts that chooseHost did not pick, by reason.
+type hostRejections struct {
+	consumed      int
+	reserved      int
+	deleting      int
+	inError       int
+	paused        int
+	unhealthy     int
+	detached      int
+	labelMismatch int
+	notAvailable  int
+	tainted       int
+	notInspected  int
+	// outOfFailureDomain counts the hosts matching the hostSelector outside
+	// the failure domain of the Machine.
+	outOfFailureDomain int
+	// hardwareMismatch counts the hosts by the first hardware criterion of
+	// the hostSelector they did not meet.
+	hardwareMismatch map[string]int
+}
+
+// addHardwareMismatch counts a host not meeting a hardware criterion.
+func (r *hostRejections) addHardwareMismatch(criterion string) {
+	if r.hardwareMismatch == nil {
+		r.hardwareMismatch = map[string]int{}
+	}
+	r.hardwareMismatch[criterion]++
+}
+
+// The hardware criteria of the hostSelector, in the order they are checked.
+const (
+	hardwareMinCPU     = "minCPU"
+	hardwareCPUArch    = "cpuArch"
+	hardwareMinRAMGiB  = "minRAMGiB"
+	hardwareMinDiskGiB = "minDiskGiB"
+	hardwareNICCount   = "nicCount"
+)
+
+var hardwareCriteria = []string{
+	hardwareMinCPU, hardwareCPUArch, hardwareMinRAMGiB, hardwareMinDiskGiB, hardwareNICCount,
+}
+
+// hardwareMismatch returns the first hardware criterion of the selector that
+// the inspected hardware does not meet, with a detail for the host selection
+// report, or an empty criterion if all of them are met.
+func hardwareMismatch(selector *infrav1.HardwareSelector, hw *bmov1alpha1.HardwareDetails) (string, string) {
+	if selector.MinCPU > 0 && hw.CPU.Count < selector.MinCPU {
+		return hardwareMinCPU, fmt.Sprintf("%d CPUs, %d required", hw.CPU.Count, selector.MinCPU)
+	}
+	if selector.CPUArch != "" && hw.CPU.Arch != selector.CPUArch {
+		return hardwareCPUArch, fmt.Sprintf("CPU architecture %q, %q required", hw.CPU.Arch, selector.CPUArch)
+	}
+	if selector.MinRAMGiB > 0 && hw.RAMMebibytes < selector.MinRAMGiB*1024 {
+		return hardwareMinRAMGiB, fmt.Sprintf("%d MiB of RAM, %d GiB required", hw.RAMMebibytes, selector.MinRAMGiB)
+	}
+	if selector.MinDiskGiB > 0 {
+		var largest bmov1alpha1.Capacity
+		for _, disk := range hw.Storage {
+			if disk.SizeBytes > largest {
+				largest = disk.SizeBytes
+			}
+		}
+		if largest < bmov1alpha1.Capacity(selector.MinDiskGiB)*bmov1alpha1.GibiByte {
+			return hardwareMinDiskGiB, fmt.Sprintf("largest disk of %d GiB, %d GiB required", largest/bmov1alpha1.GibiByte, selector.MinDiskGiB)
+		}
+	}
+	if selector.NICCount > 0 && len(hw.NIC) < selector.NICCount {
+		return hardwareNICCount, fmt.Sprintf("%d NICs, %d required", len(hw.NIC), selector.NICCount)
+	}
+	return "", ""
+}
+
+// hostTaints returns the taint keys of the HostTaintsAnnotation of a host.
+func hostTaints(host *bmov1alpha1.BareMetalHost) []string {
+	taints := []string{}
+	for _, taint := range strings.Split(host.GetAnnotations()[infrav1.HostTaintsAnnotation], ",") {
+		taint = strings.TrimSpace(taint)
+		if taint != "" {
+			taints = append(taints, taint)
+		}
+	}
+	return taints
+}
+
+// untoleratedHostTaint returns the first taint of the host that the
+// Metal3Machine does not tolerate, if any.
+func (m *MachineManager) untoleratedHostTaint(host *bmov1alpha1.BareMetalHost) (string, bool) {
+	for _, taint := range hostTaints(host) {
+		if !Contains(m.Metal3Machine.Spec.HostTolerations, taint) {
+			m.Log.V(4).Info("Host taint not tolerated by the Metal3Machine", "host", host.Name, "taint", taint)
+			return taint, true
+		}
+	}
+	return "", false
+}
+
+// String returns a human readable summary of the rejected hosts, meant for
+// the NoAvailableHost condition message.
+func (r hostRejections) String() string {
+	type reason struct {
+		count int
+		text  string
+	}
+	reasons := []reason{
+		{r.consumed, "consumed by another machine"},
+		{r.reserved, "reserved for node reuse"},
+		{r.deleting, "being deleted"},
+		{r.inError, "in error state"},
+		{r.paused, "paused"},
+		{r.unhealthy, "marked unhealthy"},
+		{r.detached, "detached"},
+		{r.tainted, "with taints not tolerated"},
+		{r.labelMismatch, "not matching the hostSelector"},
+		{r.outOfFailureDomain, "in another failure domain"},
+		{r.notInspected, "not inspected"},
+	}
+	for _, criterion := range hardwareCriteria {
+		reasons = append(reasons, reason{r.hardwareMismatch[criterion], "not matching the hardware criterion " + criterion})
+	}
+	reasons = append(reasons, reason{r.notAvailable, "not ready for provisioning"})
+	total := 0
+	details := []string{}
+	for _, reason := range reasons {
+		if reason.count == 0 {
+			continue
+		}
+		total += reason.count
+		details = append(details, fmt.Sprintf("%d %s", reason.count, reason.text))
+	}
+	if total == 0 {
+		return "no BareMetalHost found in the namespace"
+	}
+	return fmt.Sprintf("%d BareMetalHost(s) rejected: %s", total, strings.Join(details, ", "))
+}
+
+// The reasons of the host selection report, in the order they are checked.
+const (
+	hostExcludedBySelector = "ExcludedBySelector"
+	hostConsumed           = "ConsumedByOtherMachine"
+	hostReserved           = "ReservedForNodeReuse"
+	hostDeleting           = "Deleting"
+	hostInError            = "InError"
+	hostPaused             = "Paused"
+	hostUnhealthy          = "Unhealthy"
+	hostDetached           = "Detached"
+	hostTainted            = "TaintNotTolerated"
+	hostLabelMismatch      = "LabelMismatch"
+	hostOutOfFailureDomain = "OutOfFailureDomain"
+	hostNotInspected       = "NotInspected"
+	hostHardwareMismatch   = "HardwareMismatch"
+	hostNotAvailable       = "NotAvailable"
+	hostAvailable          = "Available"
+)
+
+// maxHostSelectionReportHosts caps the number of hosts listed in the host
+// selection report, the others are only counted.
+const maxHostSelectionReportHosts = 50
+
+// hostSelectionReport is the summary of a host selection written in the
+// HostSelectionReportAnnotation. A nil report records nothing.
+type hostSelectionReport struct {
+	// Namespaces are the namespaces searched for hosts.
+	Namespaces []string `json:"namespaces"`
+	// Chosen is the host picked, if any.
+	Chosen string `json:"chosen,omitempty"`
+	// Hosts are the candidate hosts with the first reason they were
+	// rejected, or Available.
+	Hosts []hostSelectionReportEntry `json:"hosts"`
+	// Truncated counts the hosts left out of Hosts.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// hostSelectionReportEntry is a host of the host selection report.
+type hostSelectionReportEntry struct {
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// newHostSelectionReport returns an empty report if the Metal3Machine has
+// the HostSelectionDebugAnnotation set to "true", nil otherwise.
+func (m *MachineManager) newHostSelectionReport(namespaces []string) *hostSelectionReport {
+	if m.Metal3Machine.GetAnnotations()[infrav1.HostSelectionDebugAnnotation] != "true" {
+		return nil
+	}
+	return &hostSelectionReport{
+		Namespaces: namespaces,
+		Hosts:      []hostSelectionReportEntry{},
+	}
+}
+
+// add records the reason a host was rejected, or Available.
+func (r *hostSelectionReport) add(host *bmov1alpha1.BareMetalHost, reason, detail string) {
+	if r == nil {
+		return
+	}
+	if len(r.Hosts) >= maxHostSelectionReportHosts {
+		r.Truncated++
+		return
+	}
+	r.Hosts = append(r.Hosts, hostSelectionReportEntry{
+		Host:   host.Namespace + "/" + host.Name,
+		Reason: reason,
+		Detail: detail,
+	})
+}
+
+// setHostSelectionReport writes the report in the
+// HostSelectionReportAnnotation of the Metal3Machine, or removes a stale
+// report when the HostSelectionDebugAnnotation is not set. The hosts are
+// not modified.
+func (m *MachineManager) setHostSelectionReport(report *hostSelectionReport, chosenHost *bmov1alpha1.BareMetalHost) {
+	annotations := m.Metal3Machine.GetAnnotations()
+	if report == nil {
+		if _, ok := annotations[infrav1.HostSelectionReportAnnotation]; ok {
+			delete(annotations, infrav1.HostSelectionReportAnnotation)
+			m.Metal3Machine.SetAnnotations(annotations)
+		}
+		return
+	}
+	if chosenHost != nil {
+		report.Chosen = chosenHost.Namespace + "/" + chosenHost.Name
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		m.Log.Error(err, "Failed to marshal the host selection report")
+		return
+	}
+	annotations[infrav1.HostSelectionReportAnnotation] = string(data)
+	m.Metal3Machine.SetAnnotations(annotations)
+}
+
+// failingRequirementKey returns the key of the first requirement the host
+// labels do not match.
+func failingRequirementKey(reqs labels.Requirements, hostLabels map[string]string) string {
+	for _, req := range reqs {
+		if !req.Matches(labels.Set(hostLabels)) {
+			return req.Key()
+		}
+	}
+	return ""
+}
+
+// noAvailableHostRequeueAfter returns how long to wait before looking for a
+// host again. The delay doubles for as long as the NoAvailableHost condition
+// stays unchanged, up to maxNoAvailableHostRequeueAfter. A change in the
+// rejected hosts updates the condition and resets the delay.
+func (m *MachineManager) noAvailableHostRequeueAfter() time.Duration {
+	condition := conditions.Get(m.Metal3Machine, infrav1.AssociateBMHCondition)
+	if condition == nil || (condition.Reason != infrav1.NoAvailableHostReason &&
+		condition.Reason != infrav1.NoAvailableHostInFailureDomainReason) {
+		return requeueAfter
+	}
+	delay := time.Since(condition.LastTransitionTime.Time)
+	if delay < requeueAfter {
+		return requeueAfter
+	}
+	if delay > maxNoAvailableHostRequeueAfter {
+		return maxNoAvailableHostRequeueAfter
+	}
+	return delay
+}
+
+// consumerRefMatches returns a boolean based on whether the consumer
+// reference and bare metal machine metadata match.
+func consumerRefMatches(consumer *corev1.ObjectReference, m3machine *infrav1.Metal3Machine) bool {
+	if consumer.Name != m3machine.Name {
+		return false
+	}
+	if consumer.Namespace != m3machine.Namespace {
+		return false
+	}
+	if consumer.Kind != m3machine.Kind {
+		return false
+	}
+	if consumer.GroupVersionKind().Group != m3machine.GroupVersionKind().Group {
+		return false
+	}
+	// consumerRefs written before the UID was recorded match on name only.
+	if consumer.UID != "" && m3machine.UID != "" && consumer.UID != m3machine.UID {
+		return false
+	}
+	return true
+}
+
+// hostConsumedByOther returns true if the host has a consumer reference that
+// does not point at the metal3 machine.
+func hostConsumedByOther(host *bmov1alpha1.BareMetalHost, m3machine *infrav1.Metal3Machine) bool {
+	return host.Spec.ConsumerRef != nil && !consumerRefMatches(host.Spec.ConsumerRef, m3machine)
+}
+
+// nodeReuseLabelMatches returns true if nodeReuseLabelName on the host matches the node reuse group, KubeadmControlPlane or MachineDeployment name.
+func (m *MachineManager) nodeReuseLabelMatches(ctx context.Context, host *bmov1alpha1.BareMetalHost) bool {
+	if host == nil {
+		return false
+	}
+	if host.Labels == nil {
+		return false
+	}
+	value, err := m.nodeReuseLabelValue(ctx)
+	if err != nil {
+		return false
+	}
+	label := getLabel(host.Labels, nodeReuseLabelName)
+	if label == "" {
+		return false
+	}
+	if label != value {
+		return false
+	}
+	m.Log.Info("nodeReuseLabelName on the host matches", "host", host.Name, "value", value)
+	return true
+}
+
+// nodeReuseLabelValue returns the value of nodeReuseLabelName for the hosts of
+// the machine: the node reuse group if set, otherwise the value for the
+// nodeReuseScope of the Metal3MachineTemplate, by default the
+// KubeadmControlPlane name for a controlplane machine and the
+// MachineDeployment name for a worker.
+func (m *MachineManager) nodeReuseLabelValue(ctx context.Context) (string, error) {
+	if m.Metal3Machine != nil && m.Metal3Machine.Spec.NodeReuseGroup != "" {
+		return m.Metal3Machine.Spec.NodeReuseGroup, nil
+	}
+	if m3mt := m.getMetal3MachineTemplate(ctx); m3mt != nil {
+		switch m3mt.Spec.NodeReuseScope {
+		case infrav1.NodeReuseScopeCluster:
+			if m.Machine == nil {
+				return "", errors.New("Could not find corresponding machine object")
+			}
+			return nodeReuseClusterValue(m.Machine.Spec.ClusterName), nil
+		case infrav1.NodeReuseScopeLabels:
+			if m.Machine == nil {
+				return "", errors.New("Could not find corresponding machine object")
+			}
+			return nodeReuseLabelsValue(m.Machine.Labels, m3mt.Spec.NodeReuseLabelKeys), nil
+		}
+	}
+	if m.isControlPlane() {
+		return m.getKubeadmControlPlaneName(ctx)
+	}
+	return m.getMachineDeploymentName(ctx)
+}
+
+// nodeReuseClusterValue returns the value of nodeReuseLabelName for the
+// machines of the cluster. The prefix differentiates the cluster from a
+// KubeadmControlPlane or MachineDeployment with the same name. A name too long
+// to fit in a label value with the prefix is truncated and suffixed with its
+// hash.
+func nodeReuseClusterValue(clusterName string) string {
+	value := "cluster-" + clusterName
+	if len(value) <= validation.LabelValueMaxLength {
+		return value
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(clusterName))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return value[:validation.LabelValueMaxLength-len(suffix)] + suffix
+}
+
+// nodeReuseLabelsValue returns the value of nodeReuseLabelName for the
+// machines with the given values of the label keys. The values are hashed, as
+// they may not fit in a label value.
+func nodeReuseLabelsValue(machineLabels map[string]string, keys []string) string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	hash := fnv.New32a()
+	for _, key := range sorted {
+		_, _ = hash.Write([]byte(key + "=" + machineLabels[key] + "\n"))
+	}
+	return fmt.Sprintf("labels-%08x", hash.Sum32())
+}
+
+// getMetal3MachineTemplate returns the Metal3MachineTemplate the machine was
+// cloned from, or nil if it is unknown or already deleted.
+func (m *MachineManager) getMetal3MachineTemplate(ctx context.Context) *infrav1.Metal3MachineTemplate {
+	if m.Metal3Machine == nil || !m.hasTemplateAnnotation() {
+		return nil
+	}
+	m.Log.Info("Getting Metal3MachineTemplate")
+	m3mt := &infrav1.Metal3MachineTemplate{}
+	m3mtKey := client.ObjectKey{
+		Name:      m.Metal3Machine.ObjectMeta.GetAnnotations()[clusterv1.TemplateClonedFromNameAnnotation],
+		Namespace: m.Metal3Machine.Namespace,
+	}
+	if err := m.client.Get(ctx, m3mtKey, m3mt); err != nil {
+		// While normal deprovisioning, Metal3MachineTemplate is deleted first
+		// and we can't get it even though Metal3Machine has reference to it.
+		m.Log.Info("Metal3MachineTemplate associated with Metal3Machine is deleted")
+		return nil
+	}
+	m.Log.Info("Found Metal3machineTemplate", "metal3machineTemplate", m3mtKey.Name)
+	return m3mt
+}
+
+// nodeReuseLabelExists returns true if host contains nodeReuseLabelName label.
+func (m *MachineManager) nodeReuseLabelExists(_ context.Context, host *bmov1alpha1.BareMetalHost) bool {
+	if host == nil {
+		return false
+	}
+	if host.Labels == nil {
+		return false
+	}
+	_, ok := lookupLabel(host.Labels, nodeReuseLabelName)
+	if ok {
+		m.Log.Info("nodeReuseLabelName exists on the host", "host", host.Name)
+	}
+	return ok
+}
+
+// SetLegacyLabelKeys sets LegacyLabelKeys. The legacy keys can only map to
+// the node reuse or the cluster name label keys.
+func SetLegacyLabelKeys(keys map[string]string) error {
+	for legacyKey, currentKey := range keys {
+		if currentKey != nodeReuseLabelName && currentKey != clusterv1.ClusterNameLabel {
+			return errors.Errorf("legacy label key %s can not map to %s, only %s and %s are supported",
+				legacyKey, currentKey, nodeReuseLabelName, clusterv1.ClusterNameLabel,
+			)
+		}
+		if legacyKey == "" || legacyKey == currentKey {
+			return errors.Errorf("invalid legacy label key %q for %s", legacyKey, currentKey)
+		}
+	}
+	LegacyLabelKeys = keys
+	return nil
+}
+
+// lookupLabel returns the value of the label with the given key, falling back
+// to its legacy keys.
+func lookupLabel(labels map[string]string, key string) (string, bool) {
+	if value, ok := labels[key]; ok {
+		return value, true
+	}
+	for legacyKey, currentKey := range LegacyLabelKeys {
+		if currentKey != key {
+			continue
+		}
+		if value, ok := labels[legacyKey]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// getLabel returns the value of the label with the given key, or of its legacy
+// keys.
+func getLabel(labels map[string]string, key string) string {
+	value, _ := lookupLabel(labels, key)
+	return value
+}
+
+// ClusterNameLabelValue returns the value of the cluster name label, or of its
+// legacy keys.
+func ClusterNameLabelValue(labels map[string]string) string {
+	return getLabel(labels, clusterv1.ClusterNameLabel)
+}
+
+// deleteLabel deletes the label with the given key and its legacy keys.
+func deleteLabel(labels map[string]string, key string) {
+	delete(labels, key)
+	for legacyKey, currentKey := range LegacyLabelKeys {
+		if currentKey == key {
+			delete(labels, legacyKey)
+		}
+	}
+}
+
+// migrateLegacyLabels replaces the legacy label keys with their current key,
+// without overriding a label already set with the current key. It returns
+// whether the labels changed.
+func migrateLegacyLabels(labels map[string]string) bool {
+	changed := false
+	for legacyKey, currentKey := range LegacyLabelKeys {
+		value, ok := labels[legacyKey]
+		if !ok {
+			continue
+		}
+		if _, ok := labels[currentKey]; !ok {
+			labels[currentKey] = value
+		}
+		delete(labels, legacyKey)
+		changed = true
+	}
+	return changed
+}
+
+// legacyLabels returns the legacy label keys set in the labels.
+func legacyLabels(labels map[string]string) []string {
+	keys := []string{}
+	for legacyKey := range LegacyLabelKeys {
+		if _, ok := labels[legacyKey]; ok {
+			keys = append(keys, legacyKey)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AuditLegacyLabels logs the BareMetalHosts that still carry the label keys of
+// older releases, and returns their number. Those labels are migrated when
+// CAPM3 next updates the host.
+func AuditLegacyLabels(ctx context.Context, cl client.Client, log logr.Logger) (int, error) {
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := cl.List(ctx, &hosts); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, host := range hosts.Items {
+		keys := legacyLabels(host.Labels)
+		if len(keys) == 0 {
+			continue
+		}
+		count++
+		log.Info("BareMetalHost uses legacy label keys", "host", host.Name,
+			"namespace", host.Namespace, "labels", keys,
+		)
+	}
+	return count, nil
+}
+
+// getBMCSecret will return the BMCSecret associated with BMH.
+func (m *MachineManager) getBMCSecret(ctx context.Context, host *bmov1alpha1.BareMetalHost) (*corev1.Secret, error) {
+	if host == nil || host.Spec.BMC.CredentialsName == "" {
+		return nil, nil
+	}
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tmpBMCSecret := corev1.Secret{}
+	key := host.CredentialsKey()
+	err = hostClient.Get(ctx, key, &tmpBMCSecret)
+	if err != nil {
+		m.Log.Error(err, "Cannot retrieve BMC credential for BareMetalhost", "host", host.Name)
+		return nil, err
+	}
+	return &tmpBMCSecret, nil
+}
+
+// setBMCSecretLabel will set the set cluster.x-k8s.io/cluster-name to BMCSecret.
+func (m *MachineManager) setBMCSecretLabel(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	tmpBMCSecret, err := m.getBMCSecret(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	if tmpBMCSecret != nil {
+		if tmpBMCSecret.Labels == nil {
+			tmpBMCSecret.Labels = make(map[string]string)
+		}
+		migrateLegacyLabels(tmpBMCSecret.Labels)
+		tmpBMCSecret.Labels[clusterv1.ClusterNameLabel] = m.Machine.Spec.ClusterName
+		return updateObject(ctx, m.hostClient, tmpBMCSecret)
+	}
+
+	return nil
+}
+
+// setHostLabel will set the set cluster.x-k8s.io/cluster-name to bmh.
+func (m *MachineManager) setHostLabel(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
+	if host.Labels == nil {
+		host.Labels = make(map[string]string)
+	}
+	migrateLegacyLabels(host.Labels)
+	host.Labels[clusterv1.ClusterNameLabel] = m.Machine.Spec.ClusterName
+
+	return nil
+}
+
+// setHostRootDeviceHints sets the rootDeviceHints of the Metal3Machine on the
+// host before it is provisioned, and annotates the host so that they are
+// cleared when it is released. The hints already set on the host win, an
+// event is recorded if they differ from the ones of the Metal3Machine.
+func (m *MachineManager) setHostRootDeviceHints(host *bmov1alpha1.BareMetalHost) {
+	hints := m.Metal3Machine.Spec.RootDeviceHints
+	if hints == nil || host.Spec.Image != nil || host.Spec.CustomDeploy != nil || isAdoptingHost(m.Metal3Machine) {
+		return
+	}
+	desired := &bmov1alpha1.RootDeviceHints{
+		DeviceName:         hints.DeviceName,
+		HCTL:               hints.HCTL,
+		Model:              hints.Model,
+		Vendor:             hints.Vendor,
+		SerialNumber:       hints.SerialNumber,
+		MinSizeGigabytes:   hints.MinSizeGigabytes,
+		WWN:                hints.WWN,
+		WWNWithExtension:   hints.WWNWithExtension,
+		WWNVendorExtension: hints.WWNVendorExtension,
+		Rotational:         hints.Rotational,
+	}
+	_, owned := host.Annotations[infrav1.HostRootDeviceHintsAnnotation]
+	if host.Spec.RootDeviceHints != nil && !owned {
+		if !equality.Semantic.DeepEqual(host.Spec.RootDeviceHints, desired) && m.Recorder != nil {
+			m.Recorder.Eventf(m.Metal3Machine, corev1.EventTypeWarning, infrav1.RootDeviceHintsConflictReason,
+				"BareMetalHost %s/%s sets its own rootDeviceHints, the rootDeviceHints of the Metal3Machine are ignored",
+				host.Namespace, host.Name)
+		}
+		return
+	}
+	host.Spec.RootDeviceHints = desired
+	if host.Annotations == nil {
+		host.Annotations = map[string]string{}
+	}
+	host.Annotations[infrav1.HostRootDeviceHintsAnnotation] = ""
+}
+
+// setHostSpec will ensure the host's Spec is set according to the machine's
+// details. It will then update the host via the kube API. If UserData does not
+// include a Namespace, it will default to the Metal3Machine's namespace.
+func (m *MachineManager) setHostSpec(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	// We only want to update the image setting if the host does not
+	// already have an image.
+	//
+	// A host with an existing image is already provisioned and
+	// upgrades are not supported at this time. To re-provision a
+	// host, we must fully deprovision it and then provision it again.
+	// Not provisioning while we do not have the UserData, unless the machine
+	// is bootstrapless or boots a live-iso.
+	// The same applies to a host provisioned with a custom deploy method.
+	// An adopted host is already provisioned, only its cleaning mode is set,
+	// its image, data and power state are left as is.
+	liveISO := isLiveISO(m.Metal3Machine)
+	adopted := isAdoptingHost(m.Metal3Machine)
+	if host.Spec.Image == nil && host.Spec.CustomDeploy == nil && !adopted &&
+		(m.Metal3Machine.Status.UserData != nil || m.Metal3Machine.Spec.Bootstrapless || liveISO) {
+		imageURL, err := m.imageURL(ctx, host)
+		if err != nil {
+			return err
+		}
+		if err := m.setFirmwareSettings(ctx, host); err != nil {
+			return err
+		}
+		if err := m.waitForProvisioningSlot(ctx, host); err != nil {
+			return err
+		}
+		if m.Metal3Machine.Spec.CustomDeploy != nil {
+			host.Spec.CustomDeploy = &bmov1alpha1.CustomDeploy{
+				Method: m.Metal3Machine.Spec.CustomDeploy.Method,
+			}
+		} else {
+			// The checksum type of a checksum value is inferred from its
+			// length when unset.
+			host.Spec.Image = &bmov1alpha1.Image{
+				URL:          imageURL,
+				Checksum:     m.Metal3Machine.Spec.Image.Checksum,
+				ChecksumType: bmov1alpha1.ChecksumType(m.Metal3Machine.Spec.Image.EffectiveChecksumType()),
+				DiskFormat:   m.Metal3Machine.Spec.Image.DiskFormat,
+			}
+		}
+		// A live-iso is booted without user data and metadata, only the
+		// network data is used.
+		if !liveISO {
+			host.Spec.UserData = m.Metal3Machine.Status.UserData
+		}
+		if host.Spec.UserData != nil && host.Spec.UserData.Namespace == "" {
+			host.Spec.UserData.Namespace = m.Metal3Machine.Namespace
+		}
+		userData, err := m.appendUserData(ctx, host, host.Spec.UserData)
+		if err != nil {
+			return err
+		}
+		host.Spec.UserData = userData
+
+		// Set metadata from gathering from Spec.metadata and from the template.
+		if m.Metal3Machine.Status.MetaData != nil && !liveISO {
+			host.Spec.MetaData = m.Metal3Machine.Status.MetaData
+		}
+		if host.Spec.MetaData != nil && host.Spec.MetaData.Namespace == "" {
+			host.Spec.MetaData.Namespace = m.Machine.Namespace
+		}
+		if m.Metal3Machine.Status.NetworkData != nil {
+			host.Spec.NetworkData = m.Metal3Machine.Status.NetworkData
+		}
+		if host.Spec.NetworkData != nil && host.Spec.NetworkData.Namespace == "" {
+			host.Spec.NetworkData.Namespace = m.Machine.Namespace
+		}
+
+		// The baremetal-operator reads the secrets in the namespace of the
+		// host, they are copied there for a host in another namespace or in
+		// a host cluster.
+		if _, err := m.hosts(ctx); err != nil {
+			return err
+		}
+		for _, secretRef := range []**corev1.SecretReference{&host.Spec.UserData, &host.Spec.MetaData, &host.Spec.NetworkData} {
+			if *secretRef == nil || (!m.remoteHosts && (host.Namespace == m.Metal3Machine.Namespace || (*secretRef).Namespace == host.Namespace)) {
+				continue
+			}
+			hostSecretRef, err := m.copySecretToHostNamespace(ctx, host, *secretRef)
+			if err != nil {
+				return err
+			}
+			*secretRef = hostSecretRef
+		}
+
+		// The Metal3Data lists the hosts using its secrets with this label.
+		setDataSecretsOwnerLabel(host, m.Metal3Machine)
+
+		// The count is persisted with the image in the same patch of the
+		// host, it is incremented once per provisioning even if the patch
+		// is retried.
+		if host.Annotations == nil {
+			host.Annotations = map[string]string{}
+		}
+		host.Annotations[infrav1.HostProvisionCountAnnotation] = strconv.Itoa(hostProvisionCount(host) + 1)
+	}
+	// Set automatedCleaningMode from metal3Machine.spec.automatedCleaningMode.
+	if m.Metal3Machine.Spec.AutomatedCleaningMode != nil {
+		if host.Spec.AutomatedCleaningMode != bmov1alpha1.AutomatedCleaningMode(*m.Metal3Machine.Spec.AutomatedCleaningMode) {
+			host.Spec.AutomatedCleaningMode = bmov1alpha1.AutomatedCleaningMode(*m.Metal3Machine.Spec.AutomatedCleaningMode)
+		}
+	}
+	if adopted {
+		return nil
+	}
+
+	// A Metal3Machine kept powered off is powered off once provisioned. With
+	// the OnDemand power management policy, the host is powered on in the
+	// same update that sets its image and bootstrap data.
+	host.Spec.Online = m.Metal3Machine.Spec.PowerState != infrav1.PowerStateOff ||
+		!hostProvisioned(host, m.Metal3Machine)
+	if m.powerOnDemand() && host.Spec.Image == nil && host.Spec.CustomDeploy == nil {
+		host.Spec.Online = false
+	}
+
+	return nil
+}
+
+// inFlightHostTimeout is the time after which a BareMetalHost recorded in
+// memory is forgotten, even if the cache does not show its update yet, e.g.
+// because the update failed.
+const inFlightHostTimeout = time.Minute
+
+// provisioningSlots records the BareMetalHosts given a provisioning slot until
+// the cache shows their image, so that the Metal3Machines reconciled at the
+// same time do not exceed MaxConcurrentProvisioning.
+var provisioningSlots = &grantedProvisioningSlots{hosts: map[types.UID]time.Time{}}
+
+type grantedProvisioningSlots struct {
+	lock  sync.Mutex
+	hosts map[types.UID]time.Time
+}
+
+// waitForProvisioningSlot returns a transient ErrBlocked while
+// MaxConcurrentProvisioning BareMetalHosts or more are being provisioned. The
+// hosts are counted from the cached list of all the watched hosts, with the
+// hosts given a slot whose image is not in the cache yet. The reason is set on
+// the BareMetalHostProvisionedCondition.
+func (m *MachineManager) waitForProvisioningSlot(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	if MaxConcurrentProvisioning <= 0 {
+		return nil
+	}
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return err
+	}
+
+	provisioningSlots.lock.Lock()
+	defer provisioningSlots.lock.Unlock()
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := hostClient.List(ctx, &hosts); err != nil {
+		return errors.Wrap(err, "failed to list the BareMetalHosts")
+	}
+	provisioning := 0
+	cachedHosts := map[types.UID]*bmov1alpha1.BareMetalHost{}
+	for i := range hosts.Items {
+		cachedHosts[hosts.Items[i].UID] = &hosts.Items[i]
+		if hostProvisioning(&hosts.Items[i]) {
+			provisioning++
+		}
+	}
+	for uid, granted := range provisioningSlots.hosts {
+		cachedHost, ok := cachedHosts[uid]
+		if time.Since(granted) > inFlightHostTimeout ||
+			(ok && (cachedHost.Spec.Image != nil || cachedHost.Spec.CustomDeploy != nil)) {
+			delete(provisioningSlots.hosts, uid)
+			continue
+		}
+		if uid != host.UID {
+			provisioning++
+		}
+	}
+	if provisioning < MaxConcurrentProvisioning {
+		provisioningSlots.hosts[host.UID] = time.Now()
+		return nil
+	}
+	message := fmt.Sprintf("%d BareMetalHosts are being provisioned, waiting for one of the %d provisioning slots",
+		provisioning, MaxConcurrentProvisioning,
+	)
+	m.Log.Info("Not provisioning the BareMetalHost", "message", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.WaitingForProvisioningSlotReason,
+		clusterv1.ConditionSeverityInfo, message,
+	)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
+// hostProvisioning returns whether the BareMetalHost is being provisioned,
+// including a host whose image is set but whose provisioning did not start
+// yet.
+func hostProvisioning(host *bmov1alpha1.BareMetalHost) bool {
+	switch host.Status.Provisioning.State {
+	case bmov1alpha1.StateProvisioning:
+		return true
+	case bmov1alpha1.StateReady, bmov1alpha1.StateAvailable, bmov1alpha1.StatePreparing:
+		return host.Spec.Image != nil || host.Spec.CustomDeploy != nil
+	}
+	return false
+}
+
+// setFirmwareSettings sets the firmwareSettings of the Metal3Machine in the
+// HostFirmwareSettings of the BareMetalHost, recording the previous values in
+// an annotation. It returns a transient ErrBlocked until the settings are
+// validated against the FirmwareSchema of the host, invalid settings are
+// reflected in the FirmwareSettingsValidCondition.
+func (m *MachineManager) setFirmwareSettings(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	settings := m.Metal3Machine.Spec.FirmwareSettings
+	if len(settings) == 0 {
+		conditions.Delete(m.Metal3Machine, infrav1.FirmwareSettingsValidCondition)
+		return nil
+	}
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return err
+	}
+	hfs := &bmov1alpha1.HostFirmwareSettings{}
+	err = hostClient.Get(ctx, client.ObjectKeyFromObject(host), hfs)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get the HostFirmwareSettings")
+	}
+	exists := err == nil
+	if !exists {
+		hfs = &bmov1alpha1.HostFirmwareSettings{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      host.Name,
+				Namespace: host.Namespace,
+			},
+		}
+	}
+	previous, err := previousFirmwareSettings(hfs)
+	if err != nil {
+		return err
+	}
+	hfsPatch := client.MergeFrom(hfs.DeepCopy())
+	changed := false
+	for name, value := range settings {
+		current, set := hfs.Spec.Settings[name]
+		if _, recorded := previous[name]; !recorded {
+			previous[name] = nil
+			if set {
+				previous[name] = pointer.String(current.String())
+			}
+			changed = true
+		}
+		if set && current.String() == value {
+			continue
+		}
+		if hfs.Spec.Settings == nil {
+			hfs.Spec.Settings = bmov1alpha1.DesiredSettingsMap{}
+		}
+		hfs.Spec.Settings[name] = intstr.Parse(value)
+		changed = true
+	}
+	if changed {
+		recorded, err := json.Marshal(previous)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the previous firmware settings")
+		}
+		if hfs.Annotations == nil {
+			hfs.Annotations = map[string]string{}
+		}
+		hfs.Annotations[infrav1.FirmwareSettingsAnnotation] = string(recorded)
+		if exists {
+			err = hostClient.Patch(ctx, hfs, hfsPatch)
+		} else {
+			err = hostClient.Create(ctx, hfs)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to set the firmware settings")
+		}
+		m.Log.Info("Set the firmware settings of the BareMetalHost", "host", host.Name)
+	}
+
+	valid := meta.FindStatusCondition(hfs.Status.Conditions, string(bmov1alpha1.FirmwareSettingsValid))
+	if changed || valid == nil || valid.ObservedGeneration != hfs.Generation {
+		conditions.Delete(m.Metal3Machine, infrav1.FirmwareSettingsValidCondition)
+		message := fmt.Sprintf("waiting for the firmware settings of BareMetalHost %s to be validated", host.Name)
+		m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.WaitingForFirmwareSettingsReason,
+			clusterv1.ConditionSeverityInfo, message,
+		)
+		return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+	}
+	if valid.Status != metav1.ConditionTrue {
+		message := fmt.Sprintf("invalid firmware settings for BareMetalHost %s: %s", host.Name, valid.Message)
+		m.Log.Info("Not provisioning the BareMetalHost", "message", message)
+		conditions.MarkFalse(m.Metal3Machine, infrav1.FirmwareSettingsValidCondition, infrav1.InvalidFirmwareSettingsReason,
+			clusterv1.ConditionSeverityError, "%s", message)
+		m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.InvalidFirmwareSettingsReason,
+			clusterv1.ConditionSeverityError, message,
+		)
+		return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+	}
+	conditions.MarkTrue(m.Metal3Machine, infrav1.FirmwareSettingsValidCondition)
+	return nil
+}
+
+// waitForFirmwareSettingsApplied returns a transient ErrBlocked while the
+// firmwareSettings of the Metal3Machine, set in the HostFirmwareSettings of the
+// provisioned BareMetalHost, are not applied yet by the baremetal-operator. The
+// settings of an adopted BareMetalHost, not set from the Metal3Machine, are not
+// waited for.
+func (m *MachineManager) waitForFirmwareSettingsApplied(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	settings := m.Metal3Machine.Spec.FirmwareSettings
+	if len(settings) == 0 {
+		return nil
+	}
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return err
+	}
+	hfs := &bmov1alpha1.HostFirmwareSettings{}
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(host), hfs); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get the HostFirmwareSettings")
+	}
+	for name, value := range settings {
+		current, set := hfs.Spec.Settings[name]
+		if !set || current.String() != value {
+			return nil
+		}
+	}
+	changeDetected := meta.FindStatusCondition(hfs.Status.Conditions, string(bmov1alpha1.FirmwareSettingsChangeDetected))
+	if changeDetected != nil && changeDetected.ObservedGeneration == hfs.Generation &&
+		changeDetected.Status == metav1.ConditionFalse {
+		return nil
+	}
+	message := fmt.Sprintf("waiting for the firmware settings of BareMetalHost %s to be applied", host.Name)
+	m.Log.Info("Not marking the BareMetalHost provisioned", "message", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.BareMetalHostProvisionedCondition, infrav1.WaitingForFirmwareSettingsAppliedReason,
+		clusterv1.ConditionSeverityInfo, message,
+	)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
+// restoreFirmwareSettings restores the previous values of the firmware
+// settings set from the Metal3Machine in the HostFirmwareSettings of the
+// BareMetalHost.
+func (m *MachineManager) restoreFirmwareSettings(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return err
+	}
+	hfs := &bmov1alpha1.HostFirmwareSettings{}
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(host), hfs); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to get the HostFirmwareSettings")
+	}
+	if _, ok := hfs.Annotations[infrav1.FirmwareSettingsAnnotation]; !ok {
+		return nil
+	}
+	previous, err := previousFirmwareSettings(hfs)
+	if err != nil {
+		return err
+	}
+	hfsPatch := client.MergeFrom(hfs.DeepCopy())
+	for name, value := range previous {
+		if value == nil {
+			delete(hfs.Spec.Settings, name)
+			continue
+		}
+		if hfs.Spec.Settings == nil {
+			hfs.Spec.Settings = bmov1alpha1.DesiredSettingsMap{}
+		}
+		hfs.Spec.Settings[name] = intstr.Parse(*value)
+	}
+	delete(hfs.Annotations, infrav1.FirmwareSettingsAnnotation)
+	if err := hostClient.Patch(ctx, hfs, hfsPatch); err != nil {
+		return errors.Wrap(err, "failed to restore the firmware settings")
+	}
+	m.Log.Info("Restored the firmware settings of the BareMetalHost", "host", host.Name)
+	return nil
+}
+
+// previousFirmwareSettings returns the previous values of the firmware
+// settings recorded on the HostFirmwareSettings, nil for a setting that was
+// not set.
+func previousFirmwareSettings(hfs *bmov1alpha1.HostFirmwareSettings) (map[string]*string, error) {
+	previous := map[string]*string{}
+	recorded, ok := hfs.Annotations[infrav1.FirmwareSettingsAnnotation]
+	if !ok {
+		return previous, nil
+	}
+	if err := json.Unmarshal([]byte(recorded), &previous); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the %s annotation", infrav1.FirmwareSettingsAnnotation)
+	}
+	return previous, nil
+}
+
+// powerOnDemand returns whether the BareMetalHost of the Metal3Machine is
+// only powered on while provisioned.
+func (m *MachineManager) powerOnDemand() bool {
+	return m.Metal3Machine.Spec.PowerManagementPolicy == infrav1.PowerManagementPolicyOnDemand
+}
+
+// appendUserData appends the rendered userDataAppend template of the
+// Metal3Machine to its cloud-config bootstrap data, in a secret owned by the
+// Metal3Machine, and returns the reference to this secret. The secret is
+// rendered again with the same content if the association is retried. A
+// failure is reflected in the UserDataAppendedCondition, it is returned as
+// a transient ErrBlocked and the BareMetalHost is not provisioned. The
+// bootstrap data is returned as is when there is nothing to append.
+func (m *MachineManager) appendUserData(ctx context.Context, host *bmov1alpha1.BareMetalHost,
+	userDataRef *corev1.SecretReference,
+) (*corev1.SecretReference, error) {
+	if userDataRef == nil || m.Metal3Machine.Spec.UserDataAppend == nil {
+		conditions.Delete(m.Metal3Machine, infrav1.UserDataAppendedCondition)
+		return userDataRef, nil
+	}
+	secret, err := checkSecretExists(ctx, m.client, userDataRef.Name, userDataRef.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, WithTransientError(fmt.Errorf("secret %s/%s not found, requeuing", userDataRef.Namespace, userDataRef.Name), requeueAfter)
+		}
+		return nil, err
+	}
+	userData := secret.Data["value"]
+	if !isCloudConfig(userData) {
+		return nil, m.setUserDataAppendFailed(infrav1.UserDataNotCloudConfigReason,
+			fmt.Sprintf("bootstrap data in secret %s/%s is not a cloud-config, userDataAppend is not supported",
+				userDataRef.Namespace, userDataRef.Name,
+			),
+		)
+	}
+	appended, err := m.renderUserDataAppend(ctx, host)
+	if err != nil {
+		return nil, m.setUserDataAppendFailed(infrav1.UserDataAppendRenderFailedReason, err.Error())
+	}
+	if len(userData) > 0 && userData[len(userData)-1] != '\n' {
+		userData = append(userData, '\n')
+	}
+	content := map[string][]byte{
+		"value":  append(userData, appended...),
+		"format": []byte("cloud-config"),
+	}
+
+	name := dataSecretName(m.Machine.Spec.ClusterName, m.Metal3Machine.Name, "userdata")
+	labels := map[string]string{
+		clusterv1.ClusterNameLabel: m.Machine.Spec.ClusterName,
+	}
+	ownerRefs := []metav1.OwnerReference{
+		{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       "Metal3Machine",
+			Name:       m.Metal3Machine.Name,
+			UID:        m.Metal3Machine.UID,
+			Controller: pointer.Bool(true),
+		},
+	}
+	if err := createSecret(ctx, m.client, name, m.Metal3Machine.Namespace, labels, ownerRefs, content); err != nil {
+		return nil, err
+	}
+	conditions.MarkTrue(m.Metal3Machine, infrav1.UserDataAppendedCondition)
+	return &corev1.SecretReference{Name: name, Namespace: m.Metal3Machine.Namespace}, nil
+}
+
+// renderUserDataAppend renders the template of the userDataAppend of the
+// Metal3Machine with the same fields as the metadata templates. The index is
+// the one of the Metal3Data of the Metal3Machine, 0 without a
+// Metal3DataTemplate.
+func (m *MachineManager) renderUserDataAppend(ctx context.Context, host *bmov1alpha1.BareMetalHost) ([]byte, error) {
+	userDataAppend := m.Metal3Machine.Spec.UserDataAppend
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Name: userDataAppend.Name, Namespace: m.Metal3Machine.Namespace}
+	if err := m.client.Get(ctx, key, configMap); err != nil {
+		return nil, fmt.Errorf("failed to get the userDataAppend ConfigMap %s: %w", userDataAppend.Name, err)
+	}
+	source, ok := configMap.Data[userDataAppend.ConfigMapKey()]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in the userDataAppend ConfigMap %s",
+			userDataAppend.ConfigMapKey(), userDataAppend.Name,
+		)
+	}
+
+	index, err := m.metal3DataIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(userDataAppend.Name).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the userDataAppend template: %w", err)
+	}
+	var value bytes.Buffer
+	if err := tmpl.Execute(&value, newMetaDataTemplateContext(index, m.Metal3Machine, m.Machine, host)); err != nil {
+		return nil, fmt.Errorf("failed to render the userDataAppend template: %w", err)
+	}
+	return value.Bytes(), nil
+}
+
+// metal3DataIndex returns the index of the Metal3Data of the Metal3Machine, 0
+// without a Metal3DataTemplate.
+func (m *MachineManager) metal3DataIndex(ctx context.Context) (int, error) {
+	if m.Metal3Machine.Status.RenderedData == nil {
+		return 0, nil
+	}
+	metal3Data, err := fetchM3Data(ctx, m.client, m.Log,
+		m.Metal3Machine.Status.RenderedData.Name, m.Metal3Machine.Namespace,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return metal3Data.Spec.Index, nil
+}
+
+// imageURL returns the URL of the image of the Metal3Machine for the host. The
+// template of a live-iso URL is rendered with the same fields as the metadata
+// templates, a failure blocks the provisioning until it is fixed.
+func (m *MachineManager) imageURL(ctx context.Context, host *bmov1alpha1.BareMetalHost) (string, error) {
+	image := &m.Metal3Machine.Spec.Image
+	if m.Metal3Machine.Spec.CustomDeploy != nil || !isLiveISO(m.Metal3Machine) || !image.IsURLTemplate() {
+		return image.URL, nil
+	}
+	index, err := m.metal3DataIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	imageURL, err := renderImageURL(image.URL, newMetaDataTemplateContext(index, m.Metal3Machine, m.Machine, host))
+	if err == nil {
+		return imageURL, nil
+	}
+	message := fmt.Sprintf("BareMetalHost %s: %s", host.Name, err)
+	m.Log.Info("Failed to render the live-iso URL, not provisioning the BareMetalHost", "message", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.LiveISOURLRenderFailedReason,
+		clusterv1.ConditionSeverityError, message)
+	return "", WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
+// renderImageURL renders the template of an image URL. Referencing a missing
+// label or annotation is an error.
+func renderImageURL(source string, data metaDataTemplateContext) (string, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the live-iso URL template: %w", err)
+	}
+	var value strings.Builder
+	if err := tmpl.Execute(&value, data); err != nil {
+		return "", fmt.Errorf("failed to render the live-iso URL template: %w", err)
+	}
+	return value.String(), nil
+}
+
+// setUserDataAppendFailed reflects that the userDataAppend can not be
+// appended to the bootstrap data and returns the transient ErrBlocked.
+func (m *MachineManager) setUserDataAppendFailed(reason, message string) error {
+	m.Log.Info("Failed to append the userDataAppend to the bootstrap data, not provisioning the BareMetalHost", "message", message)
+	conditions.MarkFalse(m.Metal3Machine, infrav1.UserDataAppendedCondition, reason,
+		clusterv1.ConditionSeverityError, "%s", message)
+	m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, reason,
+		clusterv1.ConditionSeverityError, message)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrBlocked, message), requeueAfter)
+}
+
+// setHostDeleted fails the Metal3Machine whose annotated BareMetalHost was
+// deleted, for the Machine to be replaced, by a MachineHealthCheck or by the
+// user. The annotation is kept so that the Metal3Machine is never associated
+// with another host, and the deletion is detected again if the condition is
+// overwritten, e.g. while paused.
+func (m *MachineManager) setHostDeleted() error {
+	message := fmt.Sprintf("BareMetalHost %s of the Metal3Machine was deleted",
+		m.Metal3Machine.Annotations[HostAnnotation])
+	m.Log.Info("Annotated BareMetalHost was deleted, failing the Metal3Machine", "host", m.Metal3Machine.Annotations[HostAnnotation])
+	m.SetError(message, capierrors.UpdateMachineError)
+	m.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.HostDeletedReason,
+		clusterv1.ConditionSeverityError, message)
+	return WithTerminalError(fmt.Errorf("%w: %s", ErrHostDeleted, message))
+}
+
+// hostDeleted returns whether the Metal3Machine was failed by setHostDeleted.
+func (m *MachineManager) hostDeleted() bool {
+	return conditions.GetReason(m.Metal3Machine, infrav1.AssociateBMHCondition) == infrav1.HostDeletedReason
+}
+
+// isCloudConfig returns true if the user data starts with the #cloud-config
+// header. The "## template:" lines put before the header by cloud-init
+// templates, e.g. "## template: jinja" in the bootstrap data of CABPK, are
+// skipped.
+func isCloudConfig(userData []byte) bool {
+	rest := bytes.TrimLeft(userData, " \t\r\n")
+	for bytes.HasPrefix(rest, []byte("## template:")) {
+		_, after, found := bytes.Cut(rest, []byte("\n"))
+		if !found {
+			return false
+		}
+		rest = bytes.TrimLeft(after, " \t\r\n")
+	}
+	return bytes.HasPrefix(rest, []byte("#cloud-config"))
+}
+
+// userDataAppendSecretRef returns the reference to the secret holding the
+// bootstrap data with the userDataAppend, nil without userDataAppend.
+func (m *MachineManager) userDataAppendSecretRef() *corev1.SecretReference {
+	if m.Metal3Machine.Spec.UserDataAppend == nil {
+		return nil
+	}
+	return &corev1.SecretReference{
+		Name:      dataSecretName(m.Machine.Spec.ClusterName, m.Metal3Machine.Name, "userdata"),
+		Namespace: m.Metal3Machine.Namespace,
+	}
+}
+
+// copySecretToHostNamespace copies the secret in the namespace of the host,
+// in the cluster of the host, and returns the reference to the copy.
+func (m *MachineManager) copySecretToHostNamespace(ctx context.Context,
+	host *bmov1alpha1.BareMetalHost, secretRef *corev1.SecretReference,
+) (*corev1.SecretReference, error) {
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := checkSecretExists(ctx, m.client, secretRef.Name, secretRef.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, WithTransientError(fmt.Errorf("secret %s/%s not found, requeuing", secretRef.Namespace, secretRef.Name), requeueAfter)
+		}
+		return nil, err
+	}
+	name := hostSecretName(secretRef)
+	labels := map[string]string{
+		clusterv1.ClusterNameLabel: m.Machine.Spec.ClusterName,
+	}
+	m.Log.Info("Copying secret in the namespace of the host", "secret", secretRef.Name, "host", host.Name)
+	if err := createSecret(ctx, hostClient, name, host.Namespace, labels, nil, secret.Data); err != nil {
+		return nil, err
+	}
+	return &corev1.SecretReference{Name: name, Namespace: host.Namespace}, nil
+}
+
+// deleteHostSecrets deletes the secrets of the Metal3Machine copied in the
+// namespace of the host, in the cluster of the host.
+func (m *MachineManager) deleteHostSecrets(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	hostClient, err := m.hosts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, secretRef := range []*corev1.SecretReference{m.Metal3Machine.Status.UserData, m.userDataAppendSecretRef(), m.Metal3Machine.Status.MetaData, m.Metal3Machine.Status.NetworkData} {
+		if secretRef == nil {
+			continue
+		}
+		if secretRef.Namespace == "" {
+			secretRef = &corev1.SecretReference{Name: secretRef.Name, Namespace: m.Metal3Machine.Namespace}
+		}
+		if secretRef.Namespace == host.Namespace && !m.remoteHosts {
+			continue
+		}
+		if err := deleteSecret(ctx, hostClient, hostSecretName(secretRef), host.Namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostSecretName returns the name of the copy of the secret in the namespace
+// of a host, prefixed with the namespace of the secret to avoid conflicts.
+func hostSecretName(secretRef *corev1.SecretReference) string {
+	return secretRef.Namespace + "-" + secretRef.Name
+}
+
+// setHostConsumerRef will ensure the host's Spec is set to link to this
+// Metal3Machine.
+func (m *MachineManager) setHostConsumerRef(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
+	host.Spec.ConsumerRef = m.hostConsumerRef()
+
+	// Set OwnerReferences. An owner in another namespace or in another
+	// cluster is invalid, the garbage collector would delete the host, the
+	// consumerRef is used alone.
+	if host.Namespace == m.Metal3Machine.Namespace && !m.remoteHosts {
+		hostOwnerReferences, err := m.SetOwnerRef(host.OwnerReferences, true)
+		if err != nil {
+			return err
+		}
+		host.OwnerReferences = hostOwnerReferences
+	}
+
+	// Delete nodeReuseLabelName from host.
+	m.Log.Info("Deleting nodeReuseLabelName from host, if any")
+
+	labels := host.GetLabels()
+	if labels != nil {
+		migrateLegacyLabels(labels)
+		if _, ok := labels[nodeReuseLabelName]; ok {
+			delete(host.Labels, nodeReuseLabelName)
+			m.Log.Info("Finished deleting nodeReuseLabelName")
+		}
+	}
+	deleteNodeReuseAnnotations(host.Annotations)
+
+	return nil
+}
+
+// hostConsumerRef returns the consumerRef of the hosts of the Metal3Machine.
+func (m *MachineManager) hostConsumerRef() *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:       "Metal3Machine",
+		Name:       m.Metal3Machine.Name,
+		Namespace:  m.Metal3Machine.Namespace,
+		APIVersion: m.Metal3Machine.APIVersion,
+		UID:        m.Metal3Machine.UID,
+	}
+}
+
+// ensureAnnotation makes sure the machine has an annotation that references the
+// host and uses the API to update the machine if necessary.
+func (m *MachineManager) ensureAnnotation(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
+	annotations := m.Metal3Machine.ObjectMeta.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	hostKey, err := cache.MetaNamespaceKeyFunc(host)
+	if err != nil {
+		m.Log.Error(err, "Error parsing annotation value", "annotation key", hostKey)
+		return err
 	}
 	existing, ok := annotations[HostAnnotation]
 	if ok {
@@ -1162,6 +3501,18 @@ func (m *MachineManager) HasAnnotation() bool {
 	return ok
 }
 
+// removeAnnotation removes the annotation that references a host from the
+// machine, and the mirror of the host in its status.
+func (m *MachineManager) removeAnnotation() {
+	m.Metal3Machine.Status.RenderedHost = nil
+	annotations := m.Metal3Machine.ObjectMeta.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	delete(annotations, HostAnnotation)
+	m.Metal3Machine.ObjectMeta.SetAnnotations(annotations)
+}
+
 // hasTemplateAnnotation makes sure the metal3 machine has infrastructure machine
 // annotation that stores the name of the infrastructure template resource.
 func (m *MachineManager) hasTemplateAnnotation() bool {
@@ -1202,22 +3553,138 @@ func (m *MachineManager) clearError() {
 }
 
 // updateMachineStatus updates a Metal3Machine object's status.
+// Once the Machine has a Node, the addresses are left to SyncNodeAddresses,
+// not to drop the addresses of the Node when the workload cluster is
+// unreachable.
 func (m *MachineManager) updateMachineStatus(_ context.Context, host *bmov1alpha1.BareMetalHost) error {
-	addrs := m.nodeAddresses(host)
-
 	metal3MachineOld := m.Metal3Machine.DeepCopy()
 
-	m.Metal3Machine.Status.Addresses = addrs
+	if m.Machine == nil || m.Machine.Status.NodeRef == nil {
+		m.Metal3Machine.Status.Addresses = m.nodeAddresses(host)
+	}
+	m.Metal3Machine.Status.RenderedHost = renderedHost(host)
+	m.Metal3Machine.Status.FailureDomain = hostFailureDomain(m.Metal3Cluster, host)
 	conditions.MarkTrue(m.Metal3Machine, infrav1.AssociateBMHCondition)
+	m.setPoweredOffCondition(host)
 
 	if equality.Semantic.DeepEqual(m.Metal3Machine.Status, metal3MachineOld.Status) {
 		// Status did not change
 		return nil
 	}
 
-	now := metav1.Now()
-	m.Metal3Machine.Status.LastUpdated = &now
-	return nil
+	now := metav1.Now()
+	m.Metal3Machine.Status.LastUpdated = &now
+	return nil
+}
+
+// setPoweredOffCondition reflects the power transitions of the host of a
+// Metal3Machine kept powered off in the PoweredOffCondition. Once powered on
+// again, the condition is removed when the Machine has a Node.
+func (m *MachineManager) setPoweredOffCondition(host *bmov1alpha1.BareMetalHost) {
+	switch {
+	case m.Metal3Machine.Spec.PowerState == infrav1.PowerStateOff && !host.Spec.Online:
+		if host.Status.PoweredOn {
+			conditions.MarkFalse(m.Metal3Machine, infrav1.PoweredOffCondition,
+				infrav1.PoweringOffReason, clusterv1.ConditionSeverityInfo, "")
+		} else {
+			conditions.MarkTrue(m.Metal3Machine, infrav1.PoweredOffCondition)
+		}
+	case conditions.Has(m.Metal3Machine, infrav1.PoweredOffCondition) &&
+		(!host.Status.PoweredOn || m.Machine == nil || m.Machine.Status.NodeRef == nil):
+		conditions.MarkFalse(m.Metal3Machine, infrav1.PoweredOffCondition,
+			infrav1.PoweringOnReason, clusterv1.ConditionSeverityInfo, "")
+	default:
+		conditions.Delete(m.Metal3Machine, infrav1.PoweredOffCondition)
+	}
+}
+
+// IsPoweringOn checks if the host of the Metal3Machine is powered on again
+// after its powerState was set back to on, and the Machine has no Node yet.
+func (m *MachineManager) IsPoweringOn() bool {
+	return conditions.GetReason(m.Metal3Machine, infrav1.PoweredOffCondition) == infrav1.PoweringOnReason
+}
+
+// setDeleteMachineAnnotation sets the delete-machine annotation on the
+// Machine of a host in error, powered off while it should be on, or labelled
+// with the PreferDeleteLabel, so that the Machine is deleted first when its
+// MachineSet scales in. The annotation is removed once the host recovers,
+// unless it was set by the user. Control plane Machines are never annotated.
+func (m *MachineManager) setDeleteMachineAnnotation(ctx context.Context, host *bmov1alpha1.BareMetalHost) error {
+	if m.Machine == nil || m.isControlPlane() {
+		return nil
+	}
+	value, annotated := m.Machine.Annotations[clusterv1.DeleteMachineAnnotation]
+	if annotated && !strings.HasPrefix(value, infrav1.DeleteMachineAnnotationPrefix) {
+		return nil
+	}
+	reason := m.deleteMachineReason(host)
+	desired := infrav1.DeleteMachineAnnotationPrefix + reason
+	if (reason == "" && !annotated) || (reason != "" && value == desired) {
+		return nil
+	}
+
+	helper, err := patch.NewHelper(m.Machine, m.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to init patch helper")
+	}
+	if reason == "" {
+		m.Log.Info("BareMetalHost recovered, removing the delete-machine annotation", "host", host.Name)
+		delete(m.Machine.Annotations, clusterv1.DeleteMachineAnnotation)
+	} else {
+		m.Log.Info("Setting the delete-machine annotation", "host", host.Name, "reason", reason)
+		if m.Machine.Annotations == nil {
+			m.Machine.Annotations = map[string]string{}
+		}
+		m.Machine.Annotations[clusterv1.DeleteMachineAnnotation] = desired
+		if m.Recorder != nil {
+			m.Recorder.Eventf(m.Metal3Machine, corev1.EventTypeNormal, infrav1.DeletePreferredReason,
+				"Machine %s is deleted first on scale in, BareMetalHost %s: %s", m.Machine.Name, host.Name, reason)
+		}
+	}
+	return helper.Patch(ctx, m.Machine)
+}
+
+// deleteMachineReason returns why the Machine of the host should be deleted
+// first on scale in, or an empty string. A host rebooted by a remediation or
+// powered on again after its powerState was set back to on is not considered
+// powered off unexpectedly.
+func (m *MachineManager) deleteMachineReason(host *bmov1alpha1.BareMetalHost) string {
+	if _, ok := host.Labels[infrav1.PreferDeleteLabel]; ok {
+		return infrav1.PreferDeleteLabelReason
+	}
+	if host.Status.ErrorType != "" || host.Status.OperationalStatus == bmov1alpha1.OperationalStatusError {
+		return infrav1.HostErrorReason
+	}
+	if host.Status.Provisioning.State != bmov1alpha1.StateProvisioned || host.Status.PoweredOn ||
+		!host.Spec.Online || m.Metal3Machine.Spec.PowerState == infrav1.PowerStateOff || m.IsPoweringOn() {
+		return ""
+	}
+	for annotation := range host.Annotations {
+		if strings.HasPrefix(annotation, bmov1alpha1.RebootAnnotationPrefix) {
+			return ""
+		}
+	}
+	return infrav1.HostPoweredOffReason
+}
+
+// renderedHost returns the summary of the host mirrored in the status of the
+// Metal3Machine.
+func renderedHost(host *bmov1alpha1.BareMetalHost) *infrav1.RenderedHost {
+	rendered := &infrav1.RenderedHost{
+		Name:              host.Name,
+		Namespace:         host.Namespace,
+		HardwareProfile:   host.Status.HardwareProfile,
+		ProvisioningState: string(host.Status.Provisioning.State),
+	}
+	if hw := host.Status.HardwareDetails; hw != nil {
+		rendered.CPUCount = hw.CPU.Count
+		rendered.RAMMebibytes = hw.RAMMebibytes
+		rendered.StorageDevices = len(hw.Storage)
+		for _, nic := range hw.NIC {
+			rendered.MACAddresses = append(rendered.MACAddresses, nic.MAC)
+		}
+	}
+	return rendered
 }
 
 // NodeAddresses returns a slice of corev1.NodeAddress objects for a
@@ -1252,6 +3719,231 @@ func (m *MachineManager) nodeAddresses(host *bmov1alpha1.BareMetalHost) []cluste
 	return addrs
 }
 
+// SyncNodeAddresses sets the addresses of the Metal3Machine to the addresses
+// of the BareMetalHost merged with the addresses of the workload cluster Node
+// of the Machine. The addresses, and LastUpdated, are only updated when the
+// set of addresses changes, whatever their order.
+func (m *MachineManager) SyncNodeAddresses(ctx context.Context, clientFactory ClientGetter) error {
+	defer LogDuration(m.Log, "node addresses sync", time.Now())
+	if m.Machine == nil || m.Machine.Status.NodeRef == nil {
+		return nil
+	}
+	host, _, err := m.getHost(ctx)
+	if err != nil || host == nil {
+		return err
+	}
+
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
+	if err != nil {
+		return WithTransientError(errors.Wrap(err, "Error creating a remote client"), requeueAfter)
+	}
+	// A deleted Node has no address, the addresses of the host are kept.
+	node, err := corev1Remote.Nodes().Get(ctx, m.Machine.Status.NodeRef.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return WithTransientError(errors.Wrap(err, "error while retrieving the node"), requeueAfter)
+	}
+	var nodeAddrs []corev1.NodeAddress
+	if err == nil {
+		nodeAddrs = node.Status.Addresses
+	}
+
+	addrs := mergeMachineAddresses(m.nodeAddresses(host), nodeAddrs)
+	if sameMachineAddresses(m.Metal3Machine.Status.Addresses, addrs) {
+		return nil
+	}
+	m.Log.Info("Updating the addresses from the node", "node", m.Machine.Status.NodeRef.Name)
+	m.Metal3Machine.Status.Addresses = addrs
+	now := metav1.Now()
+	m.Metal3Machine.Status.LastUpdated = &now
+	return nil
+}
+
+// capiNodeLabelDomains are the domains of the Node labels that the Machine
+// controller of Cluster API syncs from the Machine.
+var capiNodeLabelDomains = []string{
+	"node-role.kubernetes.io",
+	"node-restriction.kubernetes.io",
+	"node.cluster.x-k8s.io",
+}
+
+// SyncNodeMetadata sets the labels and annotations of the nodeMetadata of the
+// Metal3Machine on its Node, and removes the ones it set that were removed
+// from the nodeMetadata. The keys set are tracked in annotations of the Node.
+// A label or annotation that another controller set on the Node to a
+// different value, or a label synced by Cluster API from the Machine, is not
+// overwritten, an event is recorded instead.
+func (m *MachineManager) SyncNodeMetadata(ctx context.Context, clientFactory ClientGetter) error {
+	if m.Machine == nil || m.Machine.Status.NodeRef == nil {
+		return nil
+	}
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
+	if err != nil {
+		return WithTransientError(errors.Wrap(err, "Error creating a remote client"), requeueAfter)
+	}
+	node, err := corev1Remote.Nodes().Get(ctx, m.Machine.Status.NodeRef.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return WithTransientError(errors.Wrap(err, "error while retrieving the node"), requeueAfter)
+	}
+
+	var desiredLabels, desiredAnnotations map[string]string
+	if nodeMetadata := m.Metal3Machine.Spec.NodeMetadata; nodeMetadata != nil {
+		desiredLabels, desiredAnnotations = nodeMetadata.Labels, nodeMetadata.Annotations
+	}
+	original := node.DeepCopy()
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	labelKeys, labelConflicts := syncManagedMetadata(node.Labels, desiredLabels,
+		managedKeys(node.Annotations, infrav1.NodeLabelsFromMetal3MachineAnnotation), capiNodeLabel,
+	)
+	annotationKeys, annotationConflicts := syncManagedMetadata(node.Annotations, desiredAnnotations,
+		managedKeys(node.Annotations, infrav1.NodeAnnotationsFromMetal3MachineAnnotation), nodeMetadataTrackingAnnotation,
+	)
+	setManagedKeys(node.Annotations, infrav1.NodeLabelsFromMetal3MachineAnnotation, labelKeys)
+	setManagedKeys(node.Annotations, infrav1.NodeAnnotationsFromMetal3MachineAnnotation, annotationKeys)
+
+	if (len(labelConflicts) != 0 || len(annotationConflicts) != 0) && m.Recorder != nil {
+		m.Recorder.Eventf(m.Metal3Machine, corev1.EventTypeWarning, infrav1.NodeMetadataConflictReason,
+			"Labels %v and annotations %v of Node %s are owned by others, they are not set from the nodeMetadata",
+			labelConflicts, annotationConflicts, node.Name)
+	}
+
+	if equality.Semantic.DeepEqual(original.Labels, node.Labels) && equality.Semantic.DeepEqual(original.Annotations, node.Annotations) {
+		return nil
+	}
+	oldData, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("failed to json.Marshal node: %w", err)
+	}
+	newData, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to json.Marshal node: %w", err)
+	}
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, corev1.Node{})
+	if err != nil {
+		return fmt.Errorf("failed to create patch for node %q: %w", node.Name, err)
+	}
+	m.Log.Info("Updating the labels and annotations of the node", "node", node.Name)
+	_, err = corev1Remote.Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{})
+	if err != nil {
+		return WithTransientError(errors.Wrap(err, "unable to update the labels and annotations of the node"), requeueAfter)
+	}
+	return nil
+}
+
+// syncManagedMetadata sets the desired keys in values and deletes the managed
+// keys that are not desired anymore. A key that is not managed and is already
+// set to another value, or that is owned by others, is skipped and returned
+// in the conflicts. It returns the sorted keys now managed.
+func syncManagedMetadata(values, desired map[string]string, managed []string,
+	owned func(string) bool,
+) ([]string, []string) {
+	for _, key := range managed {
+		if _, ok := desired[key]; !ok {
+			delete(values, key)
+		}
+	}
+	newManaged := []string{}
+	conflicts := []string{}
+	for key, value := range desired {
+		existing, ok := values[key]
+		if owned(key) || (!Contains(managed, key) && ok && existing != value) {
+			conflicts = append(conflicts, key)
+			continue
+		}
+		values[key] = value
+		newManaged = append(newManaged, key)
+	}
+	sort.Strings(newManaged)
+	sort.Strings(conflicts)
+	return newManaged, conflicts
+}
+
+// managedKeys returns the keys listed in the tracking annotation.
+func managedKeys(annotations map[string]string, annotation string) []string {
+	if annotations[annotation] == "" {
+		return nil
+	}
+	return strings.Split(annotations[annotation], ",")
+}
+
+// setManagedKeys lists the keys in the tracking annotation, which is removed
+// when no key is managed.
+func setManagedKeys(annotations map[string]string, annotation string, keys []string) {
+	if len(keys) == 0 {
+		delete(annotations, annotation)
+		return
+	}
+	annotations[annotation] = strings.Join(keys, ",")
+}
+
+// capiNodeLabel returns whether the label is synced by Cluster API from the
+// Machine.
+func capiNodeLabel(key string) bool {
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return false
+	}
+	for _, capiDomain := range capiNodeLabelDomains {
+		if domain == capiDomain || strings.HasSuffix(domain, "."+capiDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeMetadataTrackingAnnotation returns whether the annotation tracks the
+// labels and annotations set from the nodeMetadata.
+func nodeMetadataTrackingAnnotation(key string) bool {
+	return key == infrav1.NodeLabelsFromMetal3MachineAnnotation || key == infrav1.NodeAnnotationsFromMetal3MachineAnnotation
+}
+
+// mergeMachineAddresses returns the addresses of the host followed by the
+// addresses of the Node, without duplicates nor empty addresses. The Node
+// address types are the same as the CAPI MachineAddress types.
+func mergeMachineAddresses(hostAddrs []clusterv1.MachineAddress, nodeAddrs []corev1.NodeAddress) []clusterv1.MachineAddress {
+	addrs := []clusterv1.MachineAddress{}
+	seen := map[clusterv1.MachineAddress]bool{}
+	add := func(addr clusterv1.MachineAddress) {
+		if addr.Address == "" || seen[addr] {
+			return
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range hostAddrs {
+		add(addr)
+	}
+	for _, addr := range nodeAddrs {
+		add(clusterv1.MachineAddress{
+			Type:    clusterv1.MachineAddressType(addr.Type),
+			Address: addr.Address,
+		})
+	}
+	return addrs
+}
+
+// sameMachineAddresses returns true if a and b hold the same set of
+// addresses.
+func sameMachineAddresses(a, b []clusterv1.MachineAddress) bool {
+	set := map[clusterv1.MachineAddress]bool{}
+	for _, addr := range a {
+		set[addr] = true
+	}
+	for _, addr := range b {
+		if !set[addr] {
+			return false
+		}
+		delete(set, addr)
+	}
+	return len(set) == 0
+}
+
 // GetProviderIDAndBMHID returns providerID and bmhID.
 func (m *MachineManager) GetProviderIDAndBMHID() (string, *string) {
 	providerID := m.Metal3Machine.Spec.ProviderID
@@ -1276,9 +3968,35 @@ func (m *MachineManager) GetProviderIDAndBMHID() (string, *string) {
 // ClientGetter prototype.
 type ClientGetter func(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error)
 
+// HostClientGetter returns the client of the cluster in which the
+// BareMetalHosts of the Metal3Cluster are registered, or nil for the cluster
+// of the controller.
+type HostClientGetter func(ctx context.Context, c client.Client, metal3Cluster *infrav1.Metal3Cluster) (client.Client, error)
+
+// remoteClient returns the client of the workload cluster from clientFactory
+// and updates the WorkloadClusterReachableCondition of the Metal3Machine.
+// While the cluster is unreachable, a transient error is returned so that the
+// Metal3Machine is requeued without error.
+func (m *MachineManager) remoteClient(ctx context.Context, clientFactory ClientGetter) (clientcorev1.CoreV1Interface, error) {
+	corev1Remote, err := clientFactory(ctx, m.client, m.Cluster)
+	if unreachableErr, ok := capm3remote.AsClusterUnreachableError(err); ok {
+		conditions.MarkFalse(m.Metal3Machine, infrav1.WorkloadClusterReachableCondition, infrav1.WorkloadClusterUnreachableReason,
+			clusterv1.ConditionSeverityWarning, "%s", unreachableErr.Err.Error())
+		return nil, WithTransientError(err, requeueAfter)
+	}
+	if err != nil {
+		// The other errors do not tell whether the cluster is reachable.
+		conditions.Delete(m.Metal3Machine, infrav1.WorkloadClusterReachableCondition)
+		return nil, err
+	}
+	conditions.MarkTrue(m.Metal3Machine, infrav1.WorkloadClusterReachableCondition)
+	return corev1Remote, nil
+}
+
 // SetNodeProviderID sets the metal3 provider ID on the kubernetes node.
 func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M *string, clientFactory ClientGetter) error {
-	corev1Remote, err := clientFactory(ctx, m.client, m.Cluster)
+	defer LogDuration(m.Log, "node providerID update", time.Now())
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
 	if err != nil {
 		return errors.Wrap(err, "Error creating a remote client")
 	}
@@ -1302,6 +4020,16 @@ func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M
 
 	providerIDNew := fmt.Sprintf("metal3://%s/%s/%s", namespace, bmhName, m3mName)
 
+	// A Metal3Machine kept powered off runs no Node, only the providerID of
+	// the Metal3Machine is set. It is set on the Node once powered on.
+	if m.Metal3Machine.Spec.PowerState == infrav1.PowerStateOff {
+		if *providerIDOnM3M != providerIDLegacy && *providerIDOnM3M != providerIDNew {
+			*providerIDOnM3M = m.nodeProviderID(providerIDLegacy, providerIDNew)
+		}
+		m.Log.Info("Metal3Machine powered off, the providerID is not set on a Node")
+		return nil
+	}
+
 	matchingNodesCount, err := m.getMatchingNodesWithoutLabelCount(ctx, providerIDLegacy, providerIDNew, providerIDOnM3M, clientFactory)
 	if matchingNodesCount > 1 {
 		m.Log.Info("More than one node using the same providerID")
@@ -1310,9 +4038,9 @@ func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M
 	if err != nil {
 		errMessage := "error retrieving node, requeuing"
 		m.Log.Info(errMessage)
-		return WithTransientError(errors.New(errMessage), requeueAfter)
+		return WithTransientError(errors.Wrap(err, errMessage), requeueAfter)
 	}
-	if !m.Metal3Cluster.Spec.NoCloudProvider && matchingNodesCount == 0 {
+	if m.Metal3Cluster.Spec.IsCloudProviderEnabled() && matchingNodesCount == 0 {
 		// The node could either be still running cloud-init or
 		// kubernetes has not set the node.spec.ProviderID field yet.
 		errMessage := "Some target nodes do not have spec.providerID field set yet, requeuing"
@@ -1327,7 +4055,7 @@ func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M
 		errMessage := "error retrieving node, requeuing"
 		m.Log.Info(errMessage)
 
-		return WithTransientError(errors.New(errMessage), requeueAfter)
+		return WithTransientError(errors.Wrap(err, errMessage), requeueAfter)
 	}
 	if countNodesWithLabel == 0 {
 		// The node could either be still running cloud-init or have been
@@ -1344,9 +4072,14 @@ func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M
 		oldData, err := json.Marshal(node)
 		providerIDOnNode := node.Spec.ProviderID
 		if providerIDOnNode == "" {
-			// By default we use the new format, if not set on the node.
-			node.Spec.ProviderID = providerIDNew
-			*providerIDOnM3M = providerIDNew
+			// Keep the providerID already set on the Metal3Machine, otherwise
+			// use the configured format.
+			providerID := m.nodeProviderID(providerIDLegacy, providerIDNew)
+			if *providerIDOnM3M == providerIDLegacy || *providerIDOnM3M == providerIDNew {
+				providerID = *providerIDOnM3M
+			}
+			node.Spec.ProviderID = providerID
+			*providerIDOnM3M = providerID
 		} else if providerIDOnNode == providerIDNew {
 			*providerIDOnM3M = providerIDNew
 		} else if providerIDOnNode == providerIDLegacy {
@@ -1373,6 +4106,239 @@ func (m *MachineManager) SetNodeProviderID(ctx context.Context, providerIDOnM3M
 	return nil
 }
 
+// nodeProviderID returns the providerID in the configured format.
+func (m *MachineManager) nodeProviderID(providerIDLegacy, providerIDNew string) string {
+	if m.ProviderIDFormat == ProviderIDFormatUID {
+		return providerIDLegacy
+	}
+	return providerIDNew
+}
+
+// IsHostDetached returns whether the BareMetalHost of the Metal3Machine is
+// detached with the baremetalhost.metal3.io/detached annotation, and reflects
+// it in the HostAttachedCondition. A detached host must not be modified.
+func (m *MachineManager) IsHostDetached(ctx context.Context) (bool, error) {
+	host, _, err := m.getHost(ctx)
+	if err != nil {
+		return false, err
+	}
+	if host == nil {
+		conditions.Delete(m.Metal3Machine, infrav1.HostAttachedCondition)
+		return false, nil
+	}
+	if !isHostDetached(host) {
+		if conditions.IsFalse(m.Metal3Machine, infrav1.HostAttachedCondition) {
+			m.Log.Info("BareMetalHost attached again, resuming its reconciliation")
+		}
+		conditions.MarkTrue(m.Metal3Machine, infrav1.HostAttachedCondition)
+		return false, nil
+	}
+	if !conditions.IsFalse(m.Metal3Machine, infrav1.HostAttachedCondition) {
+		m.Log.Info("BareMetalHost detached, not modifying it until it is attached again", "host", host.Name)
+	}
+	conditions.MarkFalse(m.Metal3Machine, infrav1.HostAttachedCondition, infrav1.HostDetachedReason, clusterv1.ConditionSeverityWarning,
+		"BareMetalHost %s is detached, it is not modified until the %s annotation is removed", host.Name, bmov1alpha1.DetachedAnnotation)
+	return true, nil
+}
+
+// isHostDetached returns whether the host has the detached annotation.
+func isHostDetached(host *bmov1alpha1.BareMetalHost) bool {
+	if host == nil {
+		return false
+	}
+	_, ok := host.GetAnnotations()[bmov1alpha1.DetachedAnnotation]
+	return ok
+}
+
+// CheckProviderIDFormat reports the Metal3Machines whose Node still uses the
+// legacy providerID format while another format is configured. Such Nodes keep
+// being matched, and the ProviderIDFormatMatched condition of the
+// Metal3Machine is set to false. The providerID of an existing Node can not be
+// changed, the Node only gets the configured format once the Machine is
+// replaced. The condition is not set until the Metal3Machine has a providerID.
+func (m *MachineManager) CheckProviderIDFormat() {
+	providerIDOnM3M := m.Metal3Machine.Spec.ProviderID
+	if providerIDOnM3M == nil {
+		conditions.Delete(m.Metal3Machine, infrav1.ProviderIDFormatMatchedCondition)
+		return
+	}
+	// The legacy format is the expected one when configured.
+	if m.ProviderIDFormat == ProviderIDFormatUID || strings.Contains(strings.TrimPrefix(*providerIDOnM3M, ProviderIDPrefix), "/") {
+		conditions.MarkTrue(m.Metal3Machine, infrav1.ProviderIDFormatMatchedCondition)
+		return
+	}
+	conditions.MarkFalse(m.Metal3Machine, infrav1.ProviderIDFormatMatchedCondition, infrav1.LegacyProviderIDFormatReason,
+		clusterv1.ConditionSeverityWarning, "Node uses the legacy providerID %s, which can not be changed on an existing Node, "+
+			"replace the Machine to use the %s format", *providerIDOnM3M, m.ProviderIDFormat)
+}
+
+// DrainNode cordons the Node of the Machine and evicts its pods before the
+// BareMetalHost is deprovisioned, if metal3DrainTimeout is set. It then waits
+// for the volumes of the Node to detach. It returns a transient error while
+// pods or volumes remain. The deprovisioning proceeds once the drain
+// completes, the timeouts expire or the workload cluster is unreachable, as
+// recorded in the NodeDrainedCondition. The nodeDrainTimeout and
+// nodeVolumeDetachTimeout of the Machine take precedence over the
+// metal3DrainTimeout and the default volume detach timeout.
+func (m *MachineManager) DrainNode(ctx context.Context, clientFactory ClientGetter) error {
+	defer LogDuration(m.Log, "node drain", time.Now())
+	if m.Metal3Machine.Spec.Metal3DrainTimeout == nil || m.Metal3Machine.Spec.Metal3DrainTimeout.Duration <= 0 ||
+		m.Machine == nil || m.Machine.Status.NodeRef == nil {
+		return nil
+	}
+	if _, ok := m.Machine.Annotations[clusterv1.ExcludeNodeDrainingAnnotation]; ok {
+		m.Log.Info("Skipping the drain of the Node, excluded by annotation")
+		return nil
+	}
+	drainTimeout, drainTimeoutSource := nodeDrainTimeout(m.Machine, m.Metal3Machine.Spec.Metal3DrainTimeout.Duration, "metal3DrainTimeout")
+	nodeName := m.Machine.Status.NodeRef.Name
+	drained := conditions.Get(m.Metal3Machine, infrav1.NodeDrainedCondition)
+	if drained != nil && (drained.Status == corev1.ConditionTrue ||
+		(drained.Reason != infrav1.DrainingNodeReason && drained.Reason != infrav1.WaitingForVolumeDetachReason)) {
+		return nil
+	}
+	if drained == nil {
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.DrainingNodeReason, clusterv1.ConditionSeverityInfo,
+			"Draining Node %s with a timeout of %s from the %s", nodeName, drainTimeout, drainTimeoutSource)
+		drained = conditions.Get(m.Metal3Machine, infrav1.NodeDrainedCondition)
+	}
+
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
+	if err != nil {
+		m.Log.Info("Workload cluster unreachable, not draining the Node", "node", nodeName, "error", err.Error())
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.WorkloadClusterUnreachableReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil
+	}
+	node, err := corev1Remote.Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		m.SetConditionMetal3MachineToTrue(infrav1.NodeDrainedCondition)
+		return nil
+	}
+	if err != nil {
+		m.Log.Info("Workload cluster unreachable, not draining the Node", "node", nodeName, "error", err.Error())
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.WorkloadClusterUnreachableReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil
+	}
+
+	if drained.Reason == infrav1.WaitingForVolumeDetachReason {
+		return m.waitForVolumeDetach(node, drained)
+	}
+
+	if time.Since(drained.LastTransitionTime.Time) > drainTimeout {
+		m.Log.Info("Node drain timeout exceeded, proceeding with the deprovisioning", "node", nodeName, "timeout", drainTimeout)
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.DrainTimeoutExceededReason, clusterv1.ConditionSeverityWarning,
+			"Node %s not drained within the %s of %s", nodeName, drainTimeoutSource, drainTimeout)
+		return nil
+	}
+
+	if !node.Spec.Unschedulable {
+		m.Log.Info("Cordoning the Node", "node", nodeName)
+		node.Spec.Unschedulable = true
+		if _, err = corev1Remote.Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+			return WithTransientError(errors.Wrapf(err, "failed to cordon node %s", nodeName), requeueAfter)
+		}
+	}
+
+	pods, err := corev1Remote.Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return WithTransientError(errors.Wrapf(err, "failed to list the pods of node %s", nodeName), requeueAfter)
+	}
+	remaining := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !podNeedsEviction(pod) {
+			continue
+		}
+		remaining++
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		err = corev1Remote.Pods(pod.Namespace).EvictV1(ctx, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		switch {
+		case err == nil:
+			m.Log.Info("Evicted pod", "pod", pod.Name, "namespace", pod.Namespace)
+		case apierrors.IsNotFound(err):
+			remaining--
+		case apierrors.IsTooManyRequests(err):
+			m.Log.Info("Pod eviction blocked by a disruption budget", "pod", pod.Name, "namespace", pod.Namespace)
+		default:
+			m.Log.Info("Failed to evict pod", "pod", pod.Name, "namespace", pod.Namespace, "error", err.Error())
+		}
+	}
+	if remaining > 0 {
+		errMessage := fmt.Sprintf("Draining node %s, %d pod(s) remaining", nodeName, remaining)
+		m.Log.Info(errMessage)
+		return WithTransientError(errors.New(errMessage), requeueAfter)
+	}
+
+	m.Log.Info("Node drained", "node", nodeName)
+	if len(node.Status.VolumesAttached) > 0 {
+		detachTimeout, detachTimeoutSource := nodeVolumeDetachTimeout(m.Machine)
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.WaitingForVolumeDetachReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for the volumes of Node %s to detach with a timeout of %s from the %s", nodeName, detachTimeout, detachTimeoutSource)
+		return WithTransientError(errors.Errorf("waiting for %d volume(s) of node %s to detach", len(node.Status.VolumesAttached), nodeName), requeueAfter)
+	}
+	m.SetConditionMetal3MachineToTrue(infrav1.NodeDrainedCondition)
+	return nil
+}
+
+// waitForVolumeDetach returns a transient error while volumes are attached to
+// the drained Node, until the volume detach timeout expires.
+func (m *MachineManager) waitForVolumeDetach(node *corev1.Node, drained *clusterv1.Condition) error {
+	if len(node.Status.VolumesAttached) == 0 {
+		m.Log.Info("Volumes of the Node detached", "node", node.Name)
+		m.SetConditionMetal3MachineToTrue(infrav1.NodeDrainedCondition)
+		return nil
+	}
+	detachTimeout, detachTimeoutSource := nodeVolumeDetachTimeout(m.Machine)
+	if time.Since(drained.LastTransitionTime.Time) > detachTimeout {
+		m.Log.Info("Volume detach timeout exceeded, proceeding with the deprovisioning", "node", node.Name, "timeout", detachTimeout)
+		m.SetConditionMetal3MachineToFalse(infrav1.NodeDrainedCondition, infrav1.VolumeDetachTimeoutExceededReason, clusterv1.ConditionSeverityWarning,
+			"Volumes of Node %s not detached within the %s of %s", node.Name, detachTimeoutSource, detachTimeout)
+		return nil
+	}
+	return WithTransientError(errors.Errorf("waiting for %d volume(s) of node %s to detach", len(node.Status.VolumesAttached), node.Name), requeueAfter)
+}
+
+// nodeDrainTimeout returns the nodeDrainTimeout of the owner Machine, or the
+// fallback when the Machine does not set it, with the name of the setting the
+// timeout comes from.
+func nodeDrainTimeout(machine *clusterv1.Machine, fallback time.Duration, fallbackSource string) (time.Duration, string) {
+	if machine != nil && machine.Spec.NodeDrainTimeout != nil && machine.Spec.NodeDrainTimeout.Duration > 0 {
+		return machine.Spec.NodeDrainTimeout.Duration, "Machine nodeDrainTimeout"
+	}
+	return fallback, fallbackSource
+}
+
+// nodeVolumeDetachTimeout returns the nodeVolumeDetachTimeout of the owner
+// Machine, or defaultNodeVolumeDetachTimeout when the Machine does not set it,
+// with the name of the setting the timeout comes from.
+func nodeVolumeDetachTimeout(machine *clusterv1.Machine) (time.Duration, string) {
+	if machine != nil && machine.Spec.NodeVolumeDetachTimeout != nil && machine.Spec.NodeVolumeDetachTimeout.Duration > 0 {
+		return machine.Spec.NodeVolumeDetachTimeout.Duration, "Machine nodeVolumeDetachTimeout"
+	}
+	return defaultNodeVolumeDetachTimeout, "default volume detach timeout"
+}
+
+// podNeedsEviction returns false for the pods a drain leaves on the Node:
+// DaemonSet and mirror pods, and the pods that already terminated.
+func podNeedsEviction(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
+
 // SetProviderID sets the metal3 provider ID on the Metal3Machine.
 func (m *MachineManager) SetProviderID(providerID string) {
 	m.Log.Info("ProviderID set on the Metal3Machine", "providerID", providerID)
@@ -1388,6 +4354,33 @@ func (m *MachineManager) SetOwnerRef(refList []metav1.OwnerReference, controller
 	)
 }
 
+// checkPreTerminateHooks returns a transient ErrWaitingForPreTerminateHook
+// while the Machine has pre-terminate delete hooks, and reflects them in the
+// KubernetesNodeReadyCondition. The Metal3Machine is reconciled again when the
+// hooks are removed from the Machine.
+func (m *MachineManager) checkPreTerminateHooks() error {
+	if m.Machine == nil {
+		return nil
+	}
+	hooks := []string{}
+	for key := range m.Machine.Annotations {
+		if strings.HasPrefix(key, clusterv1.PreTerminateDeleteHookAnnotationPrefix) {
+			hooks = append(hooks, key)
+		}
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	sort.Strings(hooks)
+	message := fmt.Sprintf("deprovisioning held by the pre-terminate hooks %s of Machine %s",
+		strings.Join(hooks, ", "), m.Machine.Name,
+	)
+	m.Log.Info("Waiting for the pre-terminate hooks of the Machine", "hooks", hooks)
+	m.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.WaitingForPreTerminateHookReason,
+		clusterv1.ConditionSeverityInfo, message)
+	return WithTransientError(fmt.Errorf("%w: %s", ErrWaitingForPreTerminateHook, message), requeueAfter)
+}
+
 // DeleteOwnerRef removes the ownerreference to this Metal3Machine.
 func (m *MachineManager) DeleteOwnerRef(refList []metav1.OwnerReference) ([]metav1.OwnerReference, error) {
 	return deleteOwnerRefFromList(refList, m.Metal3Machine.TypeMeta,
@@ -1482,13 +4475,25 @@ func findOwnerRefFromList(refList []metav1.OwnerReference, objType metav1.TypeMe
 // AssociateM3Metadata fetches the Metal3DataTemplate object and sets the
 // owner references.
 func (m *MachineManager) AssociateM3Metadata(ctx context.Context) error {
-	// If the secrets were provided by the user, use them.
-	if m.Metal3Machine.Spec.MetaData != nil {
-		m.Metal3Machine.Status.MetaData = m.Metal3Machine.Spec.MetaData
-	}
-	if m.Metal3Machine.Spec.NetworkData != nil {
-		m.Metal3Machine.Status.NetworkData = m.Metal3Machine.Spec.NetworkData
+	// If the secrets were provided by the user, use them once validated.
+	invalid, err := m.validateProvidedData(ctx)
+	if err != nil {
+		return err
 	}
+	if len(invalid) > 0 && m.providedDataValidation() == infrav1.ProvidedDataValidationStrict {
+		// The data rendering step is blocked with the reason of the invalid
+		// secret.
+		invalidCondition := conditions.Get(m.Metal3Machine, infrav1.ProvidedDataValidCondition)
+		m.SetConditionMetal3MachineToFalse(infrav1.Metal3DataReadyCondition, invalidCondition.Reason,
+			clusterv1.ConditionSeverityError, invalidCondition.Message)
+		return WithTransientError(fmt.Errorf("%w: invalid metaData or networkData secret provided, requeuing", ErrBlocked), requeueAfter)
+	}
+	// In warn mode, the invalid secrets are reported but not written to the
+	// BareMetalHost.
+	m.Metal3Machine.Status.MetaData = providedData(m.Metal3Machine.Spec.MetaData,
+		m.Metal3Machine.Status.MetaData, invalid["metaData"])
+	m.Metal3Machine.Status.NetworkData = providedData(m.Metal3Machine.Spec.NetworkData,
+		m.Metal3Machine.Status.NetworkData, invalid["networkData"])
 
 	// If we have RenderedData set already, it means that the owner reference was
 	// already set.
@@ -1496,13 +4501,15 @@ func (m *MachineManager) AssociateM3Metadata(ctx context.Context) error {
 		return nil
 	}
 
-	if m.Metal3Machine.Spec.DataTemplate == nil {
+	// The defaults of the webhook are not written to the spec of the
+	// Metal3Machines stored before it set them.
+	spec := m.Metal3Machine.Spec.WithDefaults(m.Metal3Machine.Namespace)
+	if spec.DataTemplate == nil {
+		// Nothing is rendered, the data rendering step is complete.
+		m.SetConditionMetal3MachineToTrue(infrav1.Metal3DataReadyCondition)
 		return nil
 	}
-	if m.Metal3Machine.Spec.DataTemplate.Namespace == "" {
-		m.Metal3Machine.Spec.DataTemplate.Namespace = m.Metal3Machine.Namespace
-	}
-	_, err := fetchM3DataClaim(ctx, m.client, m.Log,
+	_, err = fetchM3DataClaim(ctx, m.client, m.Log,
 		m.Metal3Machine.Name, m.Metal3Machine.Namespace,
 	)
 	if err != nil {
@@ -1527,10 +4534,10 @@ func (m *MachineManager) AssociateM3Metadata(ctx context.Context) error {
 					Controller: pointer.Bool(true),
 				},
 			},
-			Labels: m.Metal3Machine.Labels,
+			Labels: inheritWatchLabel(m.Metal3Machine.Labels, m.ownerLabels()...),
 		},
 		Spec: infrav1.Metal3DataClaimSpec{
-			Template: *m.Metal3Machine.Spec.DataTemplate,
+			Template: *spec.DataTemplate,
 		},
 	}
 
@@ -1541,6 +4548,116 @@ func (m *MachineManager) AssociateM3Metadata(ctx context.Context) error {
 	return nil
 }
 
+// providedDataValidation returns the handling of invalid provided secrets
+// of the Metal3Cluster, warn by default.
+func (m *MachineManager) providedDataValidation() infrav1.ProvidedDataValidation {
+	if m.Metal3Cluster == nil || m.Metal3Cluster.Spec.ProvidedDataValidation == "" {
+		return infrav1.ProvidedDataValidationWarn
+	}
+	return m.Metal3Cluster.Spec.ProvidedDataValidation
+}
+
+// providedData returns the secret of the status for the secret provided in
+// the spec: the provided secret if valid, else the current secret of the
+// status, without the provided secret if it was set before being invalid.
+func providedData(provided, current *corev1.SecretReference, invalid bool) *corev1.SecretReference {
+	if provided == nil {
+		return current
+	}
+	if !invalid {
+		return provided
+	}
+	if current != nil && *current == *provided {
+		return nil
+	}
+	return current
+}
+
+// validateProvidedData checks that the metaData and networkData secrets
+// provided in the Metal3Machine spec exist and contain a non-empty metaData,
+// respectively networkData, key, and reflects it in the
+// ProvidedDataValidCondition. It returns the keys of the invalid secrets.
+// The secrets are only checked until the Metal3Machine is provisioned.
+func (m *MachineManager) validateProvidedData(ctx context.Context) (map[string]bool, error) {
+	invalid := map[string]bool{}
+	if m.Metal3Machine.Spec.ProviderID != nil {
+		return invalid, nil
+	}
+	reason, messages := "", []string{}
+	for _, provided := range []struct {
+		secret *corev1.SecretReference
+		key    string
+	}{
+		{m.Metal3Machine.Spec.MetaData, "metaData"},
+		{m.Metal3Machine.Spec.NetworkData, "networkData"},
+	} {
+		if provided.secret == nil {
+			continue
+		}
+		namespace := provided.secret.Namespace
+		if namespace == "" {
+			namespace = m.Metal3Machine.Namespace
+		}
+		secretReason, message := "", ""
+		secret, err := checkSecretExists(ctx, m.client, provided.secret.Name, namespace)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			secretReason = infrav1.ProvidedDataSecretNotFoundReason
+			message = fmt.Sprintf("%s secret %s/%s not found", provided.key, namespace, provided.secret.Name)
+		} else if value, ok := secret.Data[provided.key]; !ok {
+			secretReason = infrav1.ProvidedDataKeyMissingReason
+			message = fmt.Sprintf("%s secret %s/%s has no %s key", provided.key, namespace, provided.secret.Name, provided.key)
+		} else if len(value) == 0 {
+			secretReason = infrav1.ProvidedDataEmptyReason
+			message = fmt.Sprintf("%s key of secret %s/%s is empty", provided.key, namespace, provided.secret.Name)
+		}
+		if secretReason == "" {
+			continue
+		}
+		invalid[provided.key] = true
+		// The reason is the one of the first invalid secret.
+		if reason == "" {
+			reason = secretReason
+		}
+		messages = append(messages, message)
+	}
+
+	if reason == "" {
+		if m.Metal3Machine.Spec.MetaData == nil && m.Metal3Machine.Spec.NetworkData == nil {
+			conditions.Delete(m.Metal3Machine, infrav1.ProvidedDataValidCondition)
+		} else {
+			conditions.MarkTrue(m.Metal3Machine, infrav1.ProvidedDataValidCondition)
+		}
+		return invalid, nil
+	}
+	message := strings.Join(messages, ", ")
+	severity := clusterv1.ConditionSeverityWarning
+	if m.providedDataValidation() == infrav1.ProvidedDataValidationStrict {
+		message += ", not provisioning the BareMetalHost"
+		severity = clusterv1.ConditionSeverityError
+	} else {
+		message += ", not used"
+	}
+	m.Log.Info("Invalid secret provided", "reason", reason, "message", message)
+	conditions.MarkFalse(m.Metal3Machine, infrav1.ProvidedDataValidCondition, reason, severity, "%s", message)
+	return invalid, nil
+}
+
+// ownerLabels returns the labels of the Machine and Cluster owning the
+// Metal3Machine, when known.
+func (m *MachineManager) ownerLabels() []map[string]string {
+	labels := []map[string]string{}
+	if m.Machine != nil {
+		labels = append(labels, m.Machine.Labels)
+	}
+	if m.Cluster != nil {
+		labels = append(labels, m.Cluster.Labels)
+	}
+	return labels
+}
+
 // WaitForM3Metadata fetches the Metal3DataTemplate object and sets the
 // owner references.
 func (m *MachineManager) WaitForM3Metadata(ctx context.Context) error {
@@ -1551,9 +4668,6 @@ func (m *MachineManager) WaitForM3Metadata(ctx context.Context) error {
 		if m.Metal3Machine.Spec.DataTemplate == nil {
 			return nil
 		}
-		if m.Metal3Machine.Spec.DataTemplate.Namespace == "" {
-			m.Metal3Machine.Spec.DataTemplate.Namespace = m.Metal3Machine.Namespace
-		}
 		metal3DataClaim, err := fetchM3DataClaim(ctx, m.client, m.Log,
 			m.Metal3Machine.Name, m.Metal3Machine.Namespace,
 		)
@@ -1765,7 +4879,7 @@ func (m *MachineManager) getBmhNameFromM3Machine() (string, error) {
 
 // getBmhUIDFromM3Machine retrieves bmhUID from m3m.
 func (m *MachineManager) getBmhUIDFromM3Machine(ctx context.Context) (string, error) {
-	host, err := getHost(ctx, m.Metal3Machine, m.client, m.Log)
+	host, _, err := m.getHost(ctx)
 	if err != nil || host == nil {
 		errMessage := fmt.Sprintf("Failed to get a BaremetalHost for the metal3machine: %s", m.Metal3Machine.GetName())
 		return "", errors.New(errMessage)
@@ -1778,7 +4892,7 @@ func (m *MachineManager) getBmhUIDFromM3Machine(ctx context.Context) (string, er
 
 // getNodesWithLabel gets kubernetes nodes with a given label.
 func (m *MachineManager) getNodesWithLabel(ctx context.Context, nodeLabel string, clientFactory ClientGetter) (*corev1.NodeList, int, error) {
-	corev1Remote, err := clientFactory(ctx, m.client, m.Cluster)
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "Error creating a remote client")
 	}
@@ -1800,7 +4914,7 @@ func (m *MachineManager) getNodesWithLabel(ctx context.Context, nodeLabel string
 
 // getMatchingNodesWithoutLabelCount tLabel gets kubernetes nodes based on their Spec.providerID field.
 func (m *MachineManager) getMatchingNodesWithoutLabelCount(ctx context.Context, providerIDLegacy, providerIDNew string, providerIDonM3M *string, clientFactory ClientGetter) (int, error) {
-	corev1Remote, err := clientFactory(ctx, m.client, m.Cluster)
+	corev1Remote, err := m.remoteClient(ctx, clientFactory)
 	matchingNodesCount := 0
 	if err != nil {
 		return matchingNodesCount, errors.Wrap(err, "Error creating a remote client")
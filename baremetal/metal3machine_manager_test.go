@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -30,20 +31,31 @@ import (
 
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	capm3remote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientfake "k8s.io/client-go/kubernetes/fake"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 const (
@@ -53,8 +65,17 @@ const (
 	testMetaDataSecretName    = "worker-metadata"
 	testNetworkDataSecretName = "worker-network-data"
 	kcpName                   = "kcp-pool1"
+	// testLegacyNodeReuseLabel and testLegacyClusterNameLabel are the legacy
+	// label keys configured in the tests.
+	testLegacyNodeReuseLabel   = "example.com/node-reuse"
+	testLegacyClusterNameLabel = "example.com/cluster-name"
 )
 
+var testLegacyLabelKeys = map[string]string{
+	testLegacyNodeReuseLabel:   nodeReuseLabelName,
+	testLegacyClusterNameLabel: clusterv1.ClusterNameLabel,
+}
+
 var Bmhuid = types.UID("4d25a2c2-46e4-11ec-81d3-0242ac130003")
 var ProviderID = fmt.Sprintf("metal3://%s", Bmhuid)
 
@@ -183,6 +204,22 @@ func bmhSpecNoImg() *bmov1alpha1.BareMetalHostSpec {
 	}
 }
 
+func liveISOHost(state bmov1alpha1.ProvisioningState, poweredOn bool) *bmov1alpha1.BareMetalHost {
+	return &bmov1alpha1.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      baremetalhostName,
+			Namespace: namespaceName,
+			UID:       bmhuid,
+		},
+		Status: bmov1alpha1.BareMetalHostStatus{
+			Provisioning: bmov1alpha1.ProvisionStatus{
+				State: state,
+			},
+			PoweredOn: poweredOn,
+		},
+	}
+}
+
 func m3mObjectMetaWithValidAnnotations() *metav1.ObjectMeta {
 	return &metav1.ObjectMeta{
 		Name:            metal3machineName,
@@ -443,6 +480,453 @@ var _ = Describe("Metal3Machine manager", func() {
 		}),
 	)
 
+	type testCaseBootstrapless struct {
+		Bootstrapless       bool
+		Metal3Cluster       *infrav1.Metal3Cluster
+		ExpectBootstrapless bool
+		ExpectSkipAllowed   bool
+	}
+
+	DescribeTable("Test Bootstrapless",
+		func(tc testCaseBootstrapless) {
+			m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				Bootstrapless: tc.Bootstrapless,
+			}, nil, nil)
+			machineMgr, err := NewMachineManager(nil, nil, tc.Metal3Cluster, nil, m3Machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.IsBootstrapless()).To(Equal(tc.ExpectBootstrapless))
+			Expect(machineMgr.BootstrapSkipAllowed()).To(Equal(tc.ExpectSkipAllowed))
+		},
+		Entry("not bootstrapless", testCaseBootstrapless{
+			Metal3Cluster: &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{AllowBootstrapless: true},
+			},
+			ExpectSkipAllowed: true,
+		}),
+		Entry("bootstrapless, allowed", testCaseBootstrapless{
+			Bootstrapless: true,
+			Metal3Cluster: &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{AllowBootstrapless: true},
+			},
+			ExpectBootstrapless: true,
+			ExpectSkipAllowed:   true,
+		}),
+		Entry("bootstrapless, not allowed", testCaseBootstrapless{
+			Bootstrapless:       true,
+			Metal3Cluster:       &infrav1.Metal3Cluster{},
+			ExpectBootstrapless: true,
+		}),
+		Entry("bootstrapless, no Metal3Cluster", testCaseBootstrapless{
+			Bootstrapless:       true,
+			ExpectBootstrapless: true,
+		}),
+	)
+
+	It("Provisions a bootstrapless machine without bootstrap secret", func() {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      machineName,
+				Namespace: namespaceName,
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: clusterName,
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: pointer.String(""),
+				},
+			},
+		}
+		m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+			Image: infrav1.Image{
+				URL:      testImageURL,
+				Checksum: testImageChecksumURL,
+			},
+			Bootstrapless: true,
+		}, nil, nil)
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+			},
+			Status: bmov1alpha1.BareMetalHostStatus{
+				Provisioning: bmov1alpha1.ProvisionStatus{
+					State: bmov1alpha1.StateAvailable,
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(machine, m3Machine, host).Build()
+		metal3Cluster := &infrav1.Metal3Cluster{
+			Spec: infrav1.Metal3ClusterSpec{AllowBootstrapless: true},
+		}
+		machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster, machine, m3Machine,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machineMgr.IsBootstrapReady()).To(BeFalse())
+		Expect(machineMgr.IsBootstrapless()).To(BeTrue())
+		Expect(machineMgr.BootstrapSkipAllowed()).To(BeTrue())
+
+		Expect(machineMgr.Associate(context.TODO())).To(Succeed())
+		Expect(m3Machine.Status.UserData).To(BeNil())
+
+		savedHost := bmov1alpha1.BareMetalHost{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), &savedHost)).To(Succeed())
+		Expect(savedHost.Spec.ConsumerRef).NotTo(BeNil())
+		Expect(savedHost.Spec.ConsumerRef.Name).To(Equal(metal3machineName))
+		Expect(savedHost.Spec.Image).NotTo(BeNil())
+		Expect(savedHost.Spec.Image.URL).To(Equal(testImageURL))
+		Expect(savedHost.Spec.UserData).To(BeNil())
+		Expect(savedHost.Spec.Online).To(BeTrue())
+	})
+
+	It("Keeps the user data of the Metal3Machine for a bootstrapless machine", func() {
+		machine := &clusterv1.Machine{
+			Spec: clusterv1.MachineSpec{
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: pointer.String("bootstrap-data"),
+				},
+			},
+		}
+		m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+			UserData: &corev1.SecretReference{
+				Name:      "user-data",
+				Namespace: namespaceName,
+			},
+			Bootstrapless: true,
+		}, nil, nil)
+		machineMgr, err := NewMachineManager(nil, nil, nil, machine, m3Machine,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.getUserDataSecretName(context.TODO())).To(Succeed())
+		Expect(m3Machine.Status.UserData).NotTo(BeNil())
+		Expect(m3Machine.Status.UserData.Name).To(Equal("user-data"))
+	})
+
+	It("Consumes a host in another namespace", func() {
+		secrets := []client.Object{}
+		for name, key := range map[string]string{
+			"bootstrap-data":          "value",
+			testMetaDataSecretName:    "metaData",
+			testNetworkDataSecretName: "networkData",
+		} {
+			secrets = append(secrets, newProvidedSecret(name, map[string][]byte{key: []byte(name)}))
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(secrets...).Build()
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      machineName,
+				Namespace: namespaceName,
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: clusterName,
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: pointer.String("bootstrap-data"),
+				},
+			},
+		}
+		m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+			Image:         infrav1.Image{URL: testImageURL},
+			HostNamespace: "inventory",
+		}, &infrav1.Metal3MachineStatus{
+			MetaData: &corev1.SecretReference{
+				Name:      testMetaDataSecretName,
+				Namespace: namespaceName,
+			},
+			NetworkData: &corev1.SecretReference{
+				Name: testNetworkDataSecretName,
+			},
+		}, nil)
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: "inventory",
+			},
+		}
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3Machine,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The consumerRef references the namespace of the Metal3Machine, the
+		// host is not owned by an object of another namespace.
+		Expect(machineMgr.setHostConsumerRef(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.ConsumerRef.Namespace).To(Equal(namespaceName))
+		Expect(host.OwnerReferences).To(BeEmpty())
+
+		// The secrets are copied in the namespace of the host.
+		Expect(machineMgr.getUserDataSecretName(context.TODO())).To(Succeed())
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		for ref, name := range map[*corev1.SecretReference]string{
+			host.Spec.UserData:    "bootstrap-data",
+			host.Spec.MetaData:    testMetaDataSecretName,
+			host.Spec.NetworkData: testNetworkDataSecretName,
+		} {
+			Expect(ref).To(Equal(&corev1.SecretReference{Name: namespaceName + "-" + name, Namespace: "inventory"}))
+			secret := corev1.Secret{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &secret)).To(Succeed())
+			Expect(secret.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, clusterName))
+			Expect(secret.Data).To(ContainElement([]byte(name)))
+		}
+		Expect(m3Machine.Status.UserData.Namespace).To(Equal(namespaceName))
+
+		// The copies are deleted when the host is released.
+		Expect(machineMgr.deleteHostSecrets(context.TODO(), host)).To(Succeed())
+		namespaceSecrets := corev1.SecretList{}
+		Expect(fakeClient.List(context.TODO(), &namespaceSecrets, client.InNamespace("inventory"))).To(Succeed())
+		Expect(namespaceSecrets.Items).To(BeEmpty())
+		Expect(fakeClient.List(context.TODO(), &namespaceSecrets, client.InNamespace(namespaceName))).To(Succeed())
+		Expect(namespaceSecrets.Items).To(HaveLen(3))
+	})
+
+	It("Consumes a host in the host cluster", func() {
+		secrets := []client.Object{}
+		for name, key := range map[string]string{
+			"bootstrap-data":          "value",
+			testMetaDataSecretName:    "metaData",
+			testNetworkDataSecretName: "networkData",
+		} {
+			secrets = append(secrets, newProvidedSecret(name, map[string][]byte{key: []byte(name)}))
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(secrets...).Build()
+		hostClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      machineName,
+				Namespace: namespaceName,
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: clusterName,
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: pointer.String("bootstrap-data"),
+				},
+			},
+		}
+		m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+			Image: infrav1.Image{URL: testImageURL},
+		}, &infrav1.Metal3MachineStatus{
+			MetaData:    &corev1.SecretReference{Name: testMetaDataSecretName},
+			NetworkData: &corev1.SecretReference{Name: testNetworkDataSecretName},
+		}, nil)
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+			},
+		}
+		machineMgr, err := NewMachineManager(fakeClient, nil, &infrav1.Metal3Cluster{}, machine, m3Machine,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		machineMgr.HostClientGetter = func(_ context.Context, _ client.Client, _ *infrav1.Metal3Cluster) (client.Client, error) {
+			return hostClient, nil
+		}
+		_, err = machineMgr.hosts(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+
+		// The secrets are copied in the host cluster, even in the same
+		// namespace.
+		Expect(machineMgr.getUserDataSecretName(context.TODO())).To(Succeed())
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		for ref, name := range map[*corev1.SecretReference]string{
+			host.Spec.UserData:    "bootstrap-data",
+			host.Spec.MetaData:    testMetaDataSecretName,
+			host.Spec.NetworkData: testNetworkDataSecretName,
+		} {
+			Expect(ref).To(Equal(&corev1.SecretReference{Name: namespaceName + "-" + name, Namespace: namespaceName}))
+			secret := corev1.Secret{}
+			Expect(hostClient.Get(context.TODO(), client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &secret)).To(Succeed())
+			Expect(secret.Data).To(ContainElement([]byte(name)))
+		}
+
+		// The copies are deleted from the host cluster when the host is
+		// released.
+		Expect(machineMgr.deleteHostSecrets(context.TODO(), host)).To(Succeed())
+		namespaceSecrets := corev1.SecretList{}
+		Expect(hostClient.List(context.TODO(), &namespaceSecrets)).To(Succeed())
+		Expect(namespaceSecrets.Items).To(BeEmpty())
+		Expect(fakeClient.List(context.TODO(), &namespaceSecrets)).To(Succeed())
+		Expect(namespaceSecrets.Items).To(HaveLen(3))
+	})
+
+	It("Provisions a live-iso machine with the network data only", func() {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      machineName,
+				Namespace: namespaceName,
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: clusterName,
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: pointer.String("bootstrap-data"),
+				},
+			},
+		}
+		m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+			Image: infrav1.Image{
+				URL:        testImageURL,
+				DiskFormat: pointer.String(infrav1.LiveISODiskFormat),
+			},
+		}, &infrav1.Metal3MachineStatus{
+			MetaData: &corev1.SecretReference{
+				Name:      testMetaDataSecretName,
+				Namespace: namespaceName,
+			},
+			NetworkData: &corev1.SecretReference{
+				Name:      testNetworkDataSecretName,
+				Namespace: namespaceName,
+			},
+		}, nil)
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+			},
+		}
+		machineMgr, err := NewMachineManager(nil, nil, nil, machine, m3Machine,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machineMgr.IsLiveISO()).To(BeTrue())
+
+		Expect(machineMgr.getUserDataSecretName(context.TODO())).To(Succeed())
+		Expect(m3Machine.Status.UserData).To(BeNil())
+
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Image).To(Equal(&bmov1alpha1.Image{
+			URL:        testImageURL,
+			DiskFormat: pointer.String(infrav1.LiveISODiskFormat),
+		}))
+		Expect(host.Spec.UserData).To(BeNil())
+		Expect(host.Spec.MetaData).To(BeNil())
+		Expect(host.Spec.NetworkData).To(Equal(m3Machine.Status.NetworkData))
+		Expect(host.Spec.Online).To(BeTrue())
+	})
+
+	type testCaseLiveISOURL struct {
+		URL           string
+		ExpectedURL   string
+		ExpectBlocked bool
+	}
+
+	DescribeTable("Test live-iso URL template",
+		func(tc testCaseLiveISOURL) {
+			machine := newMachine(machineName, nil)
+			m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{
+					URL:        tc.URL,
+					DiskFormat: pointer.String(infrav1.LiveISODiskFormat),
+				},
+			}, nil, nil)
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels:    map[string]string{"appliance": "edge-1"},
+				},
+			}
+			machineMgr, err := NewMachineManager(nil, nil, nil, machine, m3Machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.setHostSpec(context.TODO(), host)
+			if tc.ExpectBlocked {
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				Expect(host.Spec.Image).To(BeNil())
+				Expect(conditions.GetReason(m3Machine, infrav1.AssociateBMHCondition)).
+					To(Equal(infrav1.LiveISOURLRenderFailedReason))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(host.Spec.Image.URL).To(Equal(tc.ExpectedURL))
+		},
+		Entry("URL without template", testCaseLiveISOURL{
+			URL:         "http://172.22.0.1/isos/appliance.iso",
+			ExpectedURL: "http://172.22.0.1/isos/appliance.iso",
+		}),
+		Entry("URL of the host", testCaseLiveISOURL{
+			URL:         "http://172.22.0.1/isos/{{ .BareMetalHostName }}.iso",
+			ExpectedURL: "http://172.22.0.1/isos/" + baremetalhostName + ".iso",
+		}),
+		Entry("URL from a host label", testCaseLiveISOURL{
+			URL:         "http://172.22.0.1/isos/{{ .BareMetalHostLabels.appliance }}.iso",
+			ExpectedURL: "http://172.22.0.1/isos/edge-1.iso",
+		}),
+		Entry("URL from a missing host label", testCaseLiveISOURL{
+			URL:           "http://172.22.0.1/isos/{{ .BareMetalHostLabels.rack }}.iso",
+			ExpectBlocked: true,
+		}),
+	)
+
+	type testCaseLiveISOProviderID struct {
+		Host               *bmov1alpha1.BareMetalHost
+		ProviderID         *string
+		ExpectedProviderID string
+		PowerState         infrav1.PowerState
+		ExpectError        bool
+	}
+
+	DescribeTable("Test LiveISOProviderID",
+		func(tc testCaseLiveISOProviderID) {
+			objects := []client.Object{}
+			if tc.Host != nil {
+				objects = append(objects, tc.Host)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			m3Machine := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				ProviderID: tc.ProviderID,
+				Image: infrav1.Image{
+					URL:        testImageURL,
+					DiskFormat: pointer.String(infrav1.LiveISODiskFormat),
+				},
+				PowerState: tc.PowerState,
+			}, nil, m3mObjectMetaWithValidAnnotations())
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3Machine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			providerID, err := machineMgr.LiveISOProviderID(context.TODO())
+			if tc.ExpectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(providerID).To(Equal(tc.ExpectedProviderID))
+		},
+		Entry("Host not found", testCaseLiveISOProviderID{
+			ExpectError: true,
+		}),
+		Entry("Host provisioning", testCaseLiveISOProviderID{
+			Host: liveISOHost(bmov1alpha1.StateProvisioning, true),
+		}),
+		Entry("Host provisioned, powered off", testCaseLiveISOProviderID{
+			Host: liveISOHost(bmov1alpha1.StateProvisioned, false),
+		}),
+		Entry("Host provisioned, kept powered off", testCaseLiveISOProviderID{
+			Host:       liveISOHost(bmov1alpha1.StateProvisioned, false),
+			PowerState: infrav1.PowerStateOff,
+			ExpectedProviderID: fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix,
+				namespaceName, baremetalhostName, metal3machineName,
+			),
+		}),
+		Entry("Host provisioned and powered on", testCaseLiveISOProviderID{
+			Host: liveISOHost(bmov1alpha1.StateProvisioned, true),
+			ExpectedProviderID: fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix,
+				namespaceName, baremetalhostName, metal3machineName,
+			),
+		}),
+		Entry("Host provisioned and powered on, providerID already set", testCaseLiveISOProviderID{
+			Host:               liveISOHost(bmov1alpha1.StateProvisioned, true),
+			ProviderID:         pointer.String(ProviderIDPrefix + "abc"),
+			ExpectedProviderID: ProviderIDPrefix + "abc",
+		}),
+	)
+
 	DescribeTable("Test setting and clearing errors",
 		func(bmMachine infrav1.Metal3Machine) {
 			machineMgr, err := NewMachineManager(nil, nil, nil, nil, &bmMachine,
@@ -616,10 +1100,31 @@ var _ = Describe("Metal3Machine manager", func() {
 			},
 		)
 
+		rackAHost := availableHost.DeepCopy()
+		rackAHost.Name = "rackAHost"
+		rackAHost.Labels = map[string]string{infrav1.DefaultFailureDomainLabel: "rack-a", "example.com/rack": "rack-b"}
+		rackBHost := availableHost.DeepCopy()
+		rackBHost.Name = "rackBHost"
+		rackBHost.Labels = map[string]string{infrav1.DefaultFailureDomainLabel: "rack-b", "example.com/rack": "rack-a"}
+		machineInRackA := newMachine(machineName, infrastructureRef)
+		machineInRackA.Spec.FailureDomain = pointer.String("rack-a")
+		m3cRackLabel := &infrav1.Metal3Cluster{Spec: infrav1.Metal3ClusterSpec{FailureDomainLabel: "example.com/rack"}}
+
+		taintedHost := availableHost.DeepCopy()
+		taintedHost.Name = "taintedHost"
+		taintedHost.Annotations = map[string]string{
+			infrav1.HostTaintsAnnotation: "experimental-firmware, gpu",
+		}
+		m3mconfigPartialToleration := m3mconfig.DeepCopy()
+		m3mconfigPartialToleration.Spec.HostTolerations = []string{"gpu"}
+		m3mconfigFullToleration := m3mconfig.DeepCopy()
+		m3mconfigFullToleration.Spec.HostTolerations = []string{"gpu", "experimental-firmware"}
+
 		type testCaseChooseHost struct {
 			Machine          *clusterv1.Machine
 			Hosts            *bmov1alpha1.BareMetalHostList
 			M3Machine        *infrav1.Metal3Machine
+			M3Cluster        *infrav1.Metal3Cluster
 			ExpectedHostName string
 		}
 
@@ -638,7 +1143,7 @@ var _ = Describe("Metal3Machine manager", func() {
 					objects = append(objects, tc.M3Machine)
 				}
 				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
-				machineMgr, err := NewMachineManager(fakeClient, nil, nil, tc.Machine,
+				machineMgr, err := NewMachineManager(fakeClient, nil, tc.M3Cluster, tc.Machine,
 					tc.M3Machine, logr.Discard(),
 				)
 				Expect(err).NotTo(HaveOccurred())
@@ -816,70 +1321,997 @@ var _ = Describe("Metal3Machine manager", func() {
 				M3Machine:        m3mconfig5,
 				ExpectedHostName: "",
 			}),
+			Entry("No host chosen, taints not tolerated", testCaseChooseHost{
+				Machine:          newMachine(machineName, infrastructureRef),
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*taintedHost}},
+				M3Machine:        m3mconfig,
+				ExpectedHostName: "",
+			}),
+			Entry("No host chosen, taints partially tolerated", testCaseChooseHost{
+				Machine:          newMachine(machineName, infrastructureRef),
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*taintedHost}},
+				M3Machine:        m3mconfigPartialToleration,
+				ExpectedHostName: "",
+			}),
+			Entry("Tainted host chosen, all taints tolerated", testCaseChooseHost{
+				Machine:          newMachine(machineName, infrastructureRef),
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*taintedHost}},
+				M3Machine:        m3mconfigFullToleration,
+				ExpectedHostName: taintedHost.Name,
+			}),
+			Entry("Host chosen in the failure domain of the Machine", testCaseChooseHost{
+				Machine:          machineInRackA,
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*availableHost, *rackBHost, *rackAHost}},
+				M3Machine:        m3mconfig,
+				ExpectedHostName: rackAHost.Name,
+			}),
+			Entry("Host chosen in the failure domain of the Machine, label of the Metal3Cluster", testCaseChooseHost{
+				Machine:          machineInRackA,
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*availableHost, *rackAHost, *rackBHost}},
+				M3Machine:        m3mconfig,
+				M3Cluster:        m3cRackLabel,
+				ExpectedHostName: rackBHost.Name,
+			}),
+			Entry("No host chosen, no host in the failure domain of the Machine", testCaseChooseHost{
+				Machine:          machineInRackA,
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*availableHost, *rackBHost}},
+				M3Machine:        m3mconfig,
+				ExpectedHostName: "",
+			}),
+			Entry("Untainted host chosen with tolerations", testCaseChooseHost{
+				Machine:          newMachine(machineName, infrastructureRef),
+				Hosts:            &bmov1alpha1.BareMetalHostList{Items: []bmov1alpha1.BareMetalHost{*availableHost}},
+				M3Machine:        m3mconfigPartialToleration,
+				ExpectedHostName: availableHost.Name,
+			}),
 		)
-	})
 
-	type testCaseSetPauseAnnotation struct {
-		M3Machine           *infrav1.Metal3Machine
-		Host                *bmov1alpha1.BareMetalHost
-		ExpectPausePresent  bool
-		ExpectStatusPresent bool
-		ExpectError         bool
-	}
+		It("Reports why the hosts were rejected", func() {
+			hostNotReady := hostWithLabel.DeepCopy()
+			hostNotReady.Name = "hostNotReady"
+			hostNotReady.Status.Provisioning.State = bmov1alpha1.StateInspecting
+			hostDetached := availableHost.DeepCopy()
+			hostDetached.Name = "hostDetached"
+			hostDetached.Annotations = map[string]string{bmov1alpha1.DetachedAnnotation: ""}
+			hostTainted := hostWithLabel.DeepCopy()
+			hostTainted.Name = "hostTainted"
+			hostTainted.Annotations = map[string]string{infrav1.HostTaintsAnnotation: "gpu"}
+			objects := []client.Object{
+				hostWithOtherConsRef.DeepCopy(),
+				discoveredHost.DeepCopy(),
+				hostWithPausedAnnotation.DeepCopy(),
+				hostWithUnhealthyAnnotation.DeepCopy(),
+				availableHost.DeepCopy(),
+				hostInOtherNS.DeepCopy(),
+				hostNotReady,
+				hostDetached,
+				hostTainted,
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infrastructureRef2), m3mconfig2, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
 
-	DescribeTable("Test Set BMH Pause Annotation",
-		func(tc testCaseSetPauseAnnotation) {
-			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(
-				tc.Host,
-				tc.M3Machine).Build()
+			host, _, err := machineMgr.chooseHost(context.TODO())
+			Expect(host).To(BeNil())
+			Expect(errors.Is(err, ErrNoAvailableHost)).To(BeTrue())
+			var reconcileError ReconcileError
+			Expect(errors.As(err, &reconcileError)).To(BeTrue())
+			Expect(reconcileError.IsTransient()).To(BeTrue())
+			Expect(reconcileError.Unwrap().Error()).To(Equal("no available host found: " +
+				"8 BareMetalHost(s) rejected: 1 consumed by another machine, 1 in error state, " +
+				"1 paused, 1 marked unhealthy, 1 detached, 1 with taints not tolerated, 1 not matching the hostSelector, 1 not ready for provisioning",
+			))
+		})
 
-			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, tc.M3Machine, logr.Discard())
+		It("Reports the hosts in another failure domain", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+				WithObjects(availableHost.DeepCopy(), rackBHost.DeepCopy()).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machineInRackA, m3mconfig, logr.Discard())
 			Expect(err).NotTo(HaveOccurred())
 
-			err = machineMgr.SetPauseAnnotation(context.TODO())
-			if tc.ExpectError {
-				Expect(err).To(HaveOccurred())
-			} else {
-				Expect(err).NotTo(HaveOccurred())
-			}
+			host, _, err := machineMgr.chooseHost(context.TODO())
+			Expect(host).To(BeNil())
+			Expect(errors.Is(err, ErrNoAvailableHostInFailureDomain)).To(BeTrue())
+			Expect(errors.Is(err, ErrNoAvailableHost)).To(BeTrue())
+			var reconcileError ReconcileError
+			Expect(errors.As(err, &reconcileError)).To(BeTrue())
+			Expect(reconcileError.IsTransient()).To(BeTrue())
+			Expect(reconcileError.Unwrap().Error()).To(Equal("no available host found in the failure domain rack-a: " +
+				"2 BareMetalHost(s) rejected: 2 in another failure domain",
+			))
+		})
 
-			savedHost := bmov1alpha1.BareMetalHost{}
-			err = fakeClient.Get(context.TODO(),
-				client.ObjectKey{
-					Name:      tc.Host.Name,
-					Namespace: tc.Host.Namespace,
-				},
-				&savedHost,
+		It("Reports an empty namespace", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infrastructureRef), m3mconfig, logr.Discard(),
 			)
 			Expect(err).NotTo(HaveOccurred())
-			_, pausePresent := savedHost.Annotations[bmov1alpha1.PausedAnnotation]
-			if tc.ExpectPausePresent {
-				Expect(pausePresent).To(BeTrue())
-			} else {
-				Expect(pausePresent).To(BeFalse())
+
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("no available host found: no BareMetalHost found in the namespace")))
+		})
+
+		hardwareHost := func(name string, labels map[string]string, hw *bmov1alpha1.HardwareDetails) bmov1alpha1.BareMetalHost {
+			host := hostWithLabel.DeepCopy()
+			host.Name = name
+			host.Labels = labels
+			host.Status.HardwareDetails = hw
+			return *host
+		}
+		hardware := func(cpus int, arch string, ramGiB int, diskGiB int, nics int) *bmov1alpha1.HardwareDetails {
+			hw := &bmov1alpha1.HardwareDetails{
+				CPU:          bmov1alpha1.CPU{Count: cpus, Arch: arch},
+				RAMMebibytes: ramGiB * 1024,
+				Storage: []bmov1alpha1.Storage{
+					{Name: "/dev/sda", SizeBytes: 100 * bmov1alpha1.GibiByte},
+					{Name: "/dev/sdb", SizeBytes: bmov1alpha1.Capacity(diskGiB) * bmov1alpha1.GibiByte},
+				},
 			}
-			status, statusPresent := savedHost.Annotations[bmov1alpha1.StatusAnnotation]
-			if tc.ExpectStatusPresent {
-				Expect(statusPresent).To(BeTrue())
-				annotation, err := json.Marshal(&tc.Host.Status)
-				Expect(err).To(BeNil())
-				// (Note) manager code marshals the inspection data stored in annotation,
-				// which causes alphabetically reordering of keys. Since we are marshaling
-				// only the annotation, the status value here doesn't match the marshaled
-				// annotation data, because it wasn't re-ordered by the JSON marshaller.
-				// That's why we are marshaling status data as well so that its fields are
-				// also alphabetically reordered to match the annotation keys style..
-				obj := map[string]interface{}{}
-				err = json.Unmarshal(annotation, &obj)
-				Expect(err).To(BeNil())
-				annotation, _ = json.Marshal(obj)
-				Expect(status).To(Equal(string(annotation)))
-			} else {
-				Expect(statusPresent).To(BeFalse())
+			for i := 0; i < nics; i++ {
+				hw.NIC = append(hw.NIC, bmov1alpha1.NIC{Name: fmt.Sprintf("eth%d", i)})
 			}
-		},
-		Entry("Set BMH Pause Annotation, with valid CAPM3 Paused annotations, already paused", testCaseSetPauseAnnotation{
-			Host: &bmov1alpha1.BareMetalHost{
+			return hw
+		}
+		matchHardware := &infrav1.HardwareSelector{
+			MinCPU:     32,
+			CPUArch:    "x86_64",
+			MinRAMGiB:  128,
+			MinDiskGiB: 500,
+			NICCount:   2,
+		}
+
+		type testCaseMatchHardware struct {
+			MatchLabels      map[string]string
+			Hosts            []bmov1alpha1.BareMetalHost
+			ExpectedHostName string
+			ExpectedError    string
+		}
+
+		DescribeTable("Test chooseHost hardware matching",
+			func(tc testCaseMatchHardware) {
+				objects := []client.Object{}
+				for i := range tc.Hosts {
+					objects = append(objects, tc.Hosts[i].DeepCopy())
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				m3m, infraRef := newConfig("", tc.MatchLabels, []infrav1.HostSelectorRequirement{})
+				m3m.Spec.HostSelector.MatchHardware = matchHardware
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+					newMachine(machineName, infraRef), m3m, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				if tc.ExpectedError != "" {
+					Expect(result).To(BeNil())
+					Expect(errors.Is(err, ErrNoAvailableHost)).To(BeTrue())
+					Expect(err).To(MatchError(ContainSubstring(tc.ExpectedError)))
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("Host meeting all the criteria chosen", testCaseMatchHardware{
+				Hosts: []bmov1alpha1.BareMetalHost{
+					hardwareHost("few-cpus", nil, hardware(16, "x86_64", 256, 1000, 4)),
+					hardwareHost("arm", nil, hardware(64, "aarch64", 256, 1000, 4)),
+					hardwareHost("small-ram", nil, hardware(64, "x86_64", 64, 1000, 4)),
+					hardwareHost("small-disk", nil, hardware(64, "x86_64", 256, 200, 4)),
+					hardwareHost("single-nic", nil, hardware(64, "x86_64", 256, 1000, 1)),
+					hardwareHost("matching", nil, hardware(32, "x86_64", 128, 500, 2)),
+				},
+				ExpectedHostName: "matching",
+			}),
+			Entry("Uninspected host skipped", testCaseMatchHardware{
+				Hosts: []bmov1alpha1.BareMetalHost{
+					hardwareHost("uninspected", nil, nil),
+					hardwareHost("matching", nil, hardware(64, "x86_64", 256, 1000, 4)),
+				},
+				ExpectedHostName: "matching",
+			}),
+			Entry("Labels and hardware both matched", testCaseMatchHardware{
+				MatchLabels: map[string]string{"rack": "a"},
+				Hosts: []bmov1alpha1.BareMetalHost{
+					hardwareHost("rack-b", map[string]string{"rack": "b"}, hardware(64, "x86_64", 256, 1000, 4)),
+					hardwareHost("rack-a-small", map[string]string{"rack": "a"}, hardware(8, "x86_64", 256, 1000, 4)),
+					hardwareHost("rack-a", map[string]string{"rack": "a"}, hardware(64, "x86_64", 256, 1000, 4)),
+				},
+				ExpectedHostName: "rack-a",
+			}),
+			Entry("No host chosen, rejections name the criteria", testCaseMatchHardware{
+				MatchLabels: map[string]string{"rack": "a"},
+				Hosts: []bmov1alpha1.BareMetalHost{
+					hardwareHost("rack-b", map[string]string{"rack": "b"}, hardware(64, "x86_64", 256, 1000, 4)),
+					hardwareHost("uninspected", map[string]string{"rack": "a"}, nil),
+					hardwareHost("arm", map[string]string{"rack": "a"}, hardware(64, "aarch64", 256, 1000, 4)),
+					hardwareHost("small-disk", map[string]string{"rack": "a"}, hardware(64, "x86_64", 256, 200, 4)),
+				},
+				ExpectedError: "4 BareMetalHost(s) rejected: 1 not matching the hostSelector, 1 not inspected, " +
+					"1 not matching the hardware criterion cpuArch, 1 not matching the hardware criterion minDiskGiB",
+			}),
+		)
+
+		It("Reports the hardware criterion a host did not meet", func() {
+			host := hardwareHost("small-ram", nil, hardware(64, "x86_64", 64, 1000, 4))
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(host.DeepCopy()).Build()
+			m3m, infraRef := newConfig("", nil, []infrav1.HostSelectorRequirement{})
+			m3m.Spec.HostSelector.MatchHardware = matchHardware
+			m3m.Annotations = map[string]string{infrav1.HostSelectionDebugAnnotation: "true"}
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infraRef), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("1 not matching the hardware criterion minRAMGiB")))
+
+			report := hostSelectionReport{}
+			Expect(json.Unmarshal([]byte(m3m.Annotations[infrav1.HostSelectionReportAnnotation]), &report)).To(Succeed())
+			Expect(report.Hosts).To(ConsistOf(hostSelectionReportEntry{
+				Host:   host.Namespace + "/" + host.Name,
+				Reason: hostHardwareMismatch,
+				Detail: "65536 MiB of RAM, 128 GiB required",
+			}))
+		})
+
+		policyHost := func(name, lastReleased string, inspected time.Time) bmov1alpha1.BareMetalHost {
+			host := availableHost.DeepCopy()
+			host.Name = name
+			if lastReleased != "" {
+				host.Annotations = map[string]string{infrav1.HostLastReleasedAnnotation: lastReleased}
+			}
+			host.Status.OperationHistory.Inspect.End = metav1.NewTime(inspected)
+			return *host
+		}
+		provisionedHost := func(host bmov1alpha1.BareMetalHost, count string) bmov1alpha1.BareMetalHost {
+			if host.Annotations == nil {
+				host.Annotations = map[string]string{}
+			}
+			host.Annotations[infrav1.HostProvisionCountAnnotation] = count
+			return host
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+
+		type testCaseHostSelectionPolicy struct {
+			Policy           infrav1.HostSelectionPolicy
+			Hosts            []bmov1alpha1.BareMetalHost
+			ExpectedHostName string
+		}
+
+		DescribeTable("Test chooseHost host selection policy",
+			func(tc testCaseHostSelectionPolicy) {
+				objects := []client.Object{}
+				for i := range tc.Hosts {
+					objects = append(objects, tc.Hosts[i].DeepCopy())
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				metal3Cluster := &infrav1.Metal3Cluster{
+					Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: tc.Policy},
+				}
+				machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster,
+					newMachine(machineName, infrastructureRef), m3mconfig, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("Random, single host", testCaseHostSelectionPolicy{
+				Policy:           infrav1.HostSelectionRandom,
+				Hosts:            []bmov1alpha1.BareMetalHost{policyHost("host-0", "", now)},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Least recently used, oldest release", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionLeastRecentlyUsed,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					policyHost("host-0", now.Format(time.RFC3339), now),
+					policyHost("host-1", now.Add(-2*time.Hour).Format(time.RFC3339), now),
+					policyHost("host-2", now.Add(-time.Hour).Format(time.RFC3339), now),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Least recently used, never released first", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionLeastRecentlyUsed,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					policyHost("host-0", now.Add(-2*time.Hour).Format(time.RFC3339), now),
+					policyHost("host-1", "", now),
+					policyHost("host-2", "invalid", now),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Newest inspection first", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionNewestInspectionFirst,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					policyHost("host-0", "", now.Add(-2*time.Hour)),
+					policyHost("host-1", "", now),
+					policyHost("host-2", "", now.Add(-time.Hour)),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Newest inspection first, never inspected last", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionNewestInspectionFirst,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					policyHost("host-0", "", time.Time{}),
+					policyHost("host-1", "", now.Add(-time.Hour)),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Newest inspection first, ties broken by name", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionNewestInspectionFirst,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					policyHost("host-1", "", now),
+					policyHost("host-0", "", now),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Least provisioned, fewest provisionings", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionLeastProvisioned,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					provisionedHost(policyHost("host-0", "", now), "3"),
+					provisionedHost(policyHost("host-1", "", now), "1"),
+					provisionedHost(policyHost("host-2", "", now), "2"),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Least provisioned, never provisioned first", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionLeastProvisioned,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					provisionedHost(policyHost("host-0", "", now), "1"),
+					provisionedHost(policyHost("host-1", "", now), "invalid"),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Least provisioned, ties broken by least recently used", testCaseHostSelectionPolicy{
+				Policy: infrav1.HostSelectionLeastProvisioned,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					provisionedHost(policyHost("host-0", now.Format(time.RFC3339), now), "2"),
+					provisionedHost(policyHost("host-1", now.Add(-time.Hour).Format(time.RFC3339), now), "2"),
+					provisionedHost(policyHost("host-2", now.Add(-2*time.Hour).Format(time.RFC3339), now), "5"),
+				},
+				ExpectedHostName: "host-1",
+			}),
+		)
+
+		preferredHost := func(name, generation string, ramMebibytes int) bmov1alpha1.BareMetalHost {
+			host := policyHost(name, "", now)
+			host.Labels = map[string]string{"generation": generation}
+			if ramMebibytes != 0 {
+				host.Status.HardwareDetails = &bmov1alpha1.HardwareDetails{RAMMebibytes: ramMebibytes}
+			}
+			return host
+		}
+		preferences := []infrav1.HostSelectorPreference{
+			{Weight: 50, MatchExpressions: []infrav1.HostSelectorRequirement{
+				{Key: "generation", Operator: "in", Values: []string{"gen11"}},
+			}},
+			{Weight: 20, MatchHardware: &infrav1.HardwareSelector{MinRAMGiB: 512}},
+		}
+
+		type testCaseHostPreferences struct {
+			Preferences      []infrav1.HostSelectorPreference
+			Hosts            []bmov1alpha1.BareMetalHost
+			ExpectedHostName string
+		}
+
+		DescribeTable("Test chooseHost with hostSelector preferences",
+			func(tc testCaseHostPreferences) {
+				objects := []client.Object{}
+				for i := range tc.Hosts {
+					objects = append(objects, tc.Hosts[i].DeepCopy())
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				metal3Cluster := &infrav1.Metal3Cluster{
+					Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: infrav1.HostSelectionLeastRecentlyUsed},
+				}
+				m3m := m3mconfig.DeepCopy()
+				m3m.Spec.HostSelector.Preferences = tc.Preferences
+				machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster,
+					newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("No preferences, the policy picks the host", testCaseHostPreferences{
+				Hosts: []bmov1alpha1.BareMetalHost{
+					preferredHost("host-0", "gen10", 0),
+					preferredHost("host-1", "gen11", 1048576),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Highest score", testCaseHostPreferences{
+				Preferences: preferences,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					preferredHost("host-0", "gen10", 1048576),
+					preferredHost("host-1", "gen11", 1048576),
+					preferredHost("host-2", "gen11", 262144),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Heaviest preference", testCaseHostPreferences{
+				Preferences: preferences,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					preferredHost("host-0", "gen10", 1048576),
+					preferredHost("host-1", "gen11", 262144),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Hardware not inspected, not preferred", testCaseHostPreferences{
+				Preferences: preferences,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					preferredHost("host-0", "gen10", 0),
+					preferredHost("host-1", "gen10", 1048576),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("No preferred host, falls back to any match", testCaseHostPreferences{
+				Preferences: preferences,
+				Hosts: []bmov1alpha1.BareMetalHost{
+					preferredHost("host-1", "gen9", 0),
+					preferredHost("host-0", "gen9", 0),
+				},
+				ExpectedHostName: "host-0",
+			}),
+		)
+
+		rackHost := func(name, rack, consumer string) bmov1alpha1.BareMetalHost {
+			host := policyHost(name, "", now)
+			host.UID = types.UID(name + "-uid")
+			host.Labels = map[string]string{"metal3.io/rack": rack}
+			if consumer != "" {
+				host.Spec.ConsumerRef = &corev1.ObjectReference{
+					Kind:      "Metal3Machine",
+					Name:      consumer,
+					Namespace: namespaceName,
+				}
+			}
+			return host
+		}
+		deploymentMachine := func(name, deployment string) *clusterv1.Machine {
+			machine := newMachine(name, &corev1.ObjectReference{Kind: "Metal3Machine", Name: name + "-m3m"})
+			machine.Labels = map[string]string{
+				clusterv1.ClusterNameLabel:           clusterName,
+				clusterv1.MachineDeploymentNameLabel: deployment,
+			}
+			return machine
+		}
+		deletingMachine := func(name, deployment string) *clusterv1.Machine {
+			machine := deploymentMachine(name, deployment)
+			machine.DeletionTimestamp = &metav1.Time{Time: now}
+			machine.Finalizers = []string{clusterv1.MachineFinalizer}
+			return machine
+		}
+
+		type testCaseSpreadTopology struct {
+			SpreadTopologyKey string
+			Hosts             []bmov1alpha1.BareMetalHost
+			ExpectedHostName  string
+		}
+
+		DescribeTable("Test chooseHost spreading across a topology",
+			func(tc testCaseSpreadTopology) {
+				m3mt := &infrav1.Metal3MachineTemplate{
+					ObjectMeta: metav1.ObjectMeta{Name: "m3mt", Namespace: namespaceName},
+					Spec:       infrav1.Metal3MachineTemplateSpec{SpreadTopologyKey: tc.SpreadTopologyKey},
+				}
+				DeferCleanup(func() { spreadChoices.choices = map[types.NamespacedName]spreadChoice{} })
+				objects := []client.Object{
+					m3mt,
+					deploymentMachine("sibling", "md"),
+					deploymentMachine("other", "other-md"),
+					deletingMachine("deleting", "md"),
+				}
+				for i := range tc.Hosts {
+					objects = append(objects, tc.Hosts[i].DeepCopy())
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				metal3Cluster := &infrav1.Metal3Cluster{
+					Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: infrav1.HostSelectionLeastRecentlyUsed},
+				}
+				m3m := m3mconfig.DeepCopy()
+				m3m.Annotations = map[string]string{clusterv1.TemplateClonedFromNameAnnotation: m3mt.Name}
+				machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster,
+					deploymentMachine("machine", "md"), m3m, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("No spreadTopologyKey", testCaseSpreadTopology{
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-b", ""),
+					rackHost("host-2", "rack-a", "sibling-m3m"),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Avoids the rack of a machine of the MachineDeployment", testCaseSpreadTopology{
+				SpreadTopologyKey: "metal3.io/rack",
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-b", ""),
+					rackHost("host-2", "rack-a", "sibling-m3m"),
+				},
+				ExpectedHostName: "host-1",
+			}),
+			Entry("Ignores the machines of other MachineDeployments", testCaseSpreadTopology{
+				SpreadTopologyKey: "metal3.io/rack",
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-b", ""),
+					rackHost("host-2", "rack-a", "other-m3m"),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Ignores the machines being deleted", testCaseSpreadTopology{
+				SpreadTopologyKey: "metal3.io/rack",
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-b", ""),
+					rackHost("host-2", "rack-a", "deleting-m3m"),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Shares a rack when no other rack is available", testCaseSpreadTopology{
+				SpreadTopologyKey: "metal3.io/rack",
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-a", ""),
+					rackHost("host-2", "rack-a", "sibling-m3m"),
+				},
+				ExpectedHostName: "host-0",
+			}),
+			Entry("Picks the rack with the fewest machines", testCaseSpreadTopology{
+				SpreadTopologyKey: "metal3.io/rack",
+				Hosts: []bmov1alpha1.BareMetalHost{
+					rackHost("host-0", "rack-a", ""),
+					rackHost("host-1", "rack-b", ""),
+					rackHost("host-2", "rack-a", "sibling-m3m"),
+					rackHost("host-3", "rack-b", "sibling-m3m"),
+					rackHost("host-4", "rack-b", "sibling-m3m"),
+				},
+				ExpectedHostName: "host-0",
+			}),
+		)
+
+		It("Spreads the machines chosen before the cache shows their hosts consumed", func() {
+			DeferCleanup(func() { spreadChoices.choices = map[types.NamespacedName]spreadChoice{} })
+			m3mt := &infrav1.Metal3MachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "m3mt", Namespace: namespaceName},
+				Spec:       infrav1.Metal3MachineTemplateSpec{SpreadTopologyKey: "metal3.io/rack"},
+			}
+			objects := []client.Object{m3mt}
+			machines := []*clusterv1.Machine{}
+			for _, name := range []string{"machine-0", "machine-1", "machine-2"} {
+				machine := deploymentMachine(name, "md")
+				machines = append(machines, machine)
+				objects = append(objects, machine)
+			}
+			for _, host := range []bmov1alpha1.BareMetalHost{
+				rackHost("host-0", "rack-a", ""),
+				rackHost("host-1", "rack-a", ""),
+				rackHost("host-2", "rack-b", ""),
+				rackHost("host-3", "rack-b", ""),
+				rackHost("host-4", "rack-c", ""),
+				rackHost("host-5", "rack-c", ""),
+			} {
+				objects = append(objects, host.DeepCopy())
+			}
+			// The hosts are claimed by the machines, but never shown
+			// consumed by the cache.
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			metal3Cluster := &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: infrav1.HostSelectionLeastRecentlyUsed},
+			}
+
+			racks := make(chan string, len(machines))
+			for _, machine := range machines {
+				machine := machine
+				go func() {
+					defer GinkgoRecover()
+					m3m := m3mconfig.DeepCopy()
+					m3m.Name = machine.Spec.InfrastructureRef.Name
+					m3m.Annotations = map[string]string{clusterv1.TemplateClonedFromNameAnnotation: m3mt.Name}
+					machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster, machine, m3m, logr.Discard())
+					Expect(err).NotTo(HaveOccurred())
+					host, _, err := machineMgr.chooseHost(context.TODO())
+					Expect(err).NotTo(HaveOccurred())
+					racks <- host.Labels["metal3.io/rack"]
+				}()
+			}
+			chosenRacks := []string{}
+			for range machines {
+				chosenRacks = append(chosenRacks, <-racks)
+			}
+			Expect(chosenRacks).To(ConsistOf("rack-a", "rack-b", "rack-c"))
+		})
+
+		type testCaseHostNamespaces struct {
+			BMHNamespaces    []string
+			HostNamespace    string
+			ExpectedHostName string
+			ExpectedError    error
+		}
+
+		DescribeTable("Test chooseHost across namespaces",
+			func(tc testCaseHostNamespaces) {
+				DeferCleanup(func(namespaces []string) { BMHNamespaces = namespaces }, BMHNamespaces)
+				BMHNamespaces = tc.BMHNamespaces
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+					hostInOtherNS.DeepCopy(), hostWithLabel.DeepCopy(),
+				).Build()
+				m3m := m3mconfig.DeepCopy()
+				m3m.Spec.HostNamespace = tc.HostNamespace
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+					newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				if tc.ExpectedError != nil {
+					Expect(err).To(MatchError(tc.ExpectedError))
+					Expect(result).To(BeNil())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("Own namespace only", testCaseHostNamespaces{
+				ExpectedHostName: hostWithLabel.Name,
+			}),
+			Entry("Host namespace not allowed", testCaseHostNamespaces{
+				BMHNamespaces: []string{"inventory"},
+				HostNamespace: "someotherns",
+				ExpectedError: ErrHostNamespaceNotAllowed,
+			}),
+			Entry("Host namespace allowed", testCaseHostNamespaces{
+				BMHNamespaces:    []string{"inventory", "someotherns"},
+				HostNamespace:    "someotherns",
+				ExpectedHostName: hostInOtherNS.Name,
+			}),
+			Entry("Own namespace as host namespace", testCaseHostNamespaces{
+				HostNamespace:    namespaceName,
+				ExpectedHostName: hostWithLabel.Name,
+			}),
+			Entry("Host namespace allowed, no host", testCaseHostNamespaces{
+				BMHNamespaces: []string{"inventory"},
+				HostNamespace: "inventory",
+				ExpectedError: ErrNoAvailableHost,
+			}),
+		)
+
+		It("Chooses the hosts of the allowed namespaces", func() {
+			DeferCleanup(func(namespaces []string) { BMHNamespaces = namespaces }, BMHNamespaces)
+			BMHNamespaces = []string{"someotherns"}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+				hostInOtherNS.DeepCopy(), hostWithOtherConsRef.DeepCopy(),
+			).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infrastructureRef), m3mconfig.DeepCopy(), logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, _, err := machineMgr.chooseHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Name).To(Equal(hostInOtherNS.Name))
+			Expect(result.Namespace).To(Equal("someotherns"))
+		})
+
+		It("Chooses the hosts of the host cluster", func() {
+			localHost := policyHost("local-host", "", now)
+			remoteHost := policyHost("remote-host", "", now)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(&localHost).Build()
+			hostClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(&remoteHost).Build()
+			m3m := m3mconfig.DeepCopy()
+			machineMgr, err := NewMachineManager(fakeClient, nil, &infrav1.Metal3Cluster{},
+				newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.HostClientGetter = func(_ context.Context, c client.Client, _ *infrav1.Metal3Cluster) (client.Client, error) {
+				Expect(c).To(BeIdenticalTo(fakeClient))
+				return hostClient, nil
+			}
+
+			result, helper, err := machineMgr.chooseHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Name).To(Equal(remoteHost.Name))
+
+			// The consumerRef is set in the host cluster, without owner
+			// reference to the Metal3Machine of another cluster.
+			Expect(machineMgr.setHostConsumerRef(context.TODO(), result)).To(Succeed())
+			Expect(result.OwnerReferences).To(BeEmpty())
+			Expect(helper.Patch(context.TODO(), result)).To(Succeed())
+			Expect(machineMgr.ensureAnnotation(context.TODO(), result)).To(Succeed())
+			host, _, err := machineMgr.getHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(host.Spec.ConsumerRef.Name).To(Equal(m3m.Name))
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(&localHost), host)).To(Succeed())
+			Expect(host.Spec.ConsumerRef).To(BeNil())
+		})
+
+		It("Fails to choose a host of a host cluster when host clusters are not enabled", func() {
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+			metal3Cluster := &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{
+					HostClusterKubeconfigSecret: &corev1.LocalObjectReference{Name: "hardware-kubeconfig"},
+				},
+			}
+			machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster,
+				newMachine(machineName, infrastructureRef), m3mconfig.DeepCopy(), logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("host clusters are not enabled")))
+		})
+
+		It("Chooses only the hosts matching the manager-level selector", func() {
+			excludedHost := policyHost("excluded-host", "", now)
+			excludedHost.Labels = map[string]string{"pool": "a", "reserved": "other-system"}
+			excludedConsumedHost := policyHost("excluded-consumed-host", "", now)
+			excludedConsumedHost.Labels = map[string]string{"pool": "a", "reserved": "other-system"}
+			excludedConsumedHost.Spec.ConsumerRef = consumerRefSome()
+			allowedHost := policyHost("allowed-host", "", now.Add(-time.Hour))
+			allowedHost.Labels = map[string]string{"pool": "a"}
+			m3m := m3mconfig.DeepCopy()
+			m3m.Spec.HostSelector = infrav1.HostSelector{MatchLabels: map[string]string{"pool": "a"}}
+			selector, err := labels.Parse("!reserved")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+				WithObjects(&excludedHost, &excludedConsumedHost, &allowedHost).Build()
+			metal3Cluster := &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: infrav1.HostSelectionNewestInspectionFirst},
+			}
+			machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster,
+				newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.HostLabelSelector = selector
+
+			// The excluded host matches the hostSelector and was inspected
+			// last, it would be chosen without the manager-level selector.
+			result, _, err := machineMgr.chooseHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Name).To(Equal(allowedHost.Name))
+
+			// The excluded hosts are not counted in the rejected hosts.
+			Expect(fakeClient.Delete(context.TODO(), &allowedHost)).To(Succeed())
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).To(MatchError(ContainSubstring("no BareMetalHost found in the namespace")))
+		})
+
+		It("Reports why the hosts were rejected with the debug annotation", func() {
+			hostWithLabels := func(name string, hostLabels map[string]string) bmov1alpha1.BareMetalHost {
+				host := policyHost(name, "", now)
+				host.Labels = hostLabels
+				return host
+			}
+			excludedHost := hostWithLabels("excluded-host", map[string]string{"pool": "a", "reserved": "other-system"})
+			consumedHost := hostWithLabels("consumed-host", map[string]string{"pool": "a"})
+			consumedHost.Spec.ConsumerRef = consumerRefSome()
+			errorHost := hostWithLabels("error-host", map[string]string{"pool": "a"})
+			errorHost.Status.ErrorMessage = "Registration failed"
+			errorHost.Status.ErrorType = bmov1alpha1.RegistrationError
+			taintedHost := hostWithLabels("tainted-host", map[string]string{"pool": "a"})
+			taintedHost.Annotations = map[string]string{infrav1.HostTaintsAnnotation: "gpu"}
+			mismatchHost := hostWithLabels("mismatch-host", map[string]string{"pool": "b"})
+			inspectingHost := hostWithLabels("inspecting-host", map[string]string{"pool": "a"})
+			inspectingHost.Status.Provisioning.State = bmov1alpha1.StateInspecting
+			allowedHost := hostWithLabels("allowed-host", map[string]string{"pool": "a"})
+			hosts := []*bmov1alpha1.BareMetalHost{
+				&excludedHost, &consumedHost, &errorHost, &taintedHost, &mismatchHost, &inspectingHost, &allowedHost,
+			}
+			objects := []client.Object{}
+			for _, host := range hosts {
+				objects = append(objects, host.DeepCopy())
+			}
+			m3m := m3mconfig.DeepCopy()
+			m3m.Spec.HostSelector = infrav1.HostSelector{MatchLabels: map[string]string{"pool": "a"}}
+			m3m.Annotations = map[string]string{infrav1.HostSelectionDebugAnnotation: "true"}
+			selector, err := labels.Parse("!reserved")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.HostLabelSelector = selector
+
+			result, _, err := machineMgr.chooseHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Name).To(Equal(allowedHost.Name))
+
+			report := hostSelectionReport{}
+			Expect(json.Unmarshal([]byte(m3m.Annotations[infrav1.HostSelectionReportAnnotation]), &report)).To(Succeed())
+			Expect(report.Namespaces).To(Equal([]string{m3m.Namespace}))
+			Expect(report.Chosen).To(Equal(allowedHost.Namespace + "/" + allowedHost.Name))
+			Expect(report.Truncated).To(BeZero())
+			entry := func(host *bmov1alpha1.BareMetalHost, reason, detail string) hostSelectionReportEntry {
+				return hostSelectionReportEntry{Host: host.Namespace + "/" + host.Name, Reason: reason, Detail: detail}
+			}
+			Expect(report.Hosts).To(ConsistOf(
+				entry(&excludedHost, hostExcludedBySelector, "!reserved"),
+				entry(&consumedHost, hostConsumed, consumedHost.Spec.ConsumerRef.Namespace+"/"+consumedHost.Spec.ConsumerRef.Name),
+				entry(&errorHost, hostInError, string(bmov1alpha1.RegistrationError)),
+				entry(&taintedHost, hostTainted, "gpu"),
+				entry(&mismatchHost, hostLabelMismatch, "pool"),
+				entry(&inspectingHost, hostNotAvailable, string(bmov1alpha1.StateInspecting)),
+				entry(&allowedHost, hostAvailable, string(allowedHost.Status.Provisioning.State)),
+			))
+
+			// The hosts are not modified.
+			for _, host := range hosts {
+				savedHost := bmov1alpha1.BareMetalHost{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), &savedHost)).To(Succeed())
+				Expect(savedHost.ResourceVersion).To(Equal("999"))
+			}
+
+			// The stale report is removed with the debug annotation.
+			delete(m3m.Annotations, infrav1.HostSelectionDebugAnnotation)
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m3m.Annotations).NotTo(HaveKey(infrav1.HostSelectionReportAnnotation))
+		})
+
+		It("Truncates the host selection report", func() {
+			objects := []client.Object{}
+			for i := 0; i < maxHostSelectionReportHosts+3; i++ {
+				host := policyHost(fmt.Sprintf("host-%d", i), "", now)
+				host.Spec.ConsumerRef = consumerRefSome()
+				objects = append(objects, &host)
+			}
+			m3m := m3mconfig.DeepCopy()
+			m3m.Annotations = map[string]string{infrav1.HostSelectionDebugAnnotation: "true"}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+				newMachine(machineName, infrastructureRef), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = machineMgr.chooseHost(context.TODO())
+			Expect(err).To(MatchError(ErrNoAvailableHost))
+
+			report := hostSelectionReport{}
+			Expect(json.Unmarshal([]byte(m3m.Annotations[infrav1.HostSelectionReportAnnotation]), &report)).To(Succeed())
+			Expect(report.Chosen).To(BeEmpty())
+			Expect(report.Hosts).To(HaveLen(maxHostSelectionReportHosts))
+			Expect(report.Truncated).To(Equal(3))
+		})
+	})
+
+	type testCaseNoAvailableHostRequeueAfter struct {
+		Condition     *clusterv1.Condition
+		ExpectedDelay time.Duration
+	}
+
+	DescribeTable("Test noAvailableHostRequeueAfter",
+		func(tc testCaseNoAvailableHostRequeueAfter) {
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil)
+			if tc.Condition != nil {
+				m3m.Status.Conditions = clusterv1.Conditions{*tc.Condition}
+			}
+			machineMgr, err := NewMachineManager(nil, nil, nil, nil, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.noAvailableHostRequeueAfter()).To(BeNumerically("~", tc.ExpectedDelay, time.Second))
+		},
+		Entry("No condition", testCaseNoAvailableHostRequeueAfter{
+			ExpectedDelay: requeueAfter,
+		}),
+		Entry("Other reason", testCaseNoAvailableHostRequeueAfter{
+			Condition: &clusterv1.Condition{
+				Type:               infrav1.AssociateBMHCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             infrav1.AssociateBMHFailedReason,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+			ExpectedDelay: requeueAfter,
+		}),
+		Entry("Recently without host", testCaseNoAvailableHostRequeueAfter{
+			Condition: &clusterv1.Condition{
+				Type:               infrav1.AssociateBMHCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             infrav1.NoAvailableHostReason,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-10 * time.Second)),
+			},
+			ExpectedDelay: requeueAfter,
+		}),
+		Entry("Doubles the time without host", testCaseNoAvailableHostRequeueAfter{
+			Condition: &clusterv1.Condition{
+				Type:               infrav1.AssociateBMHCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             infrav1.NoAvailableHostReason,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			},
+			ExpectedDelay: 2 * time.Minute,
+		}),
+		Entry("Capped", testCaseNoAvailableHostRequeueAfter{
+			Condition: &clusterv1.Condition{
+				Type:               infrav1.AssociateBMHCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             infrav1.NoAvailableHostReason,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+			ExpectedDelay: maxNoAvailableHostRequeueAfter,
+		}),
+	)
+
+	type testCaseSetPauseAnnotation struct {
+		M3Machine           *infrav1.Metal3Machine
+		Host                *bmov1alpha1.BareMetalHost
+		ExpectPausePresent  bool
+		ExpectStatusPresent bool
+		ExpectError         bool
+	}
+
+	DescribeTable("Test Set BMH Pause Annotation",
+		func(tc testCaseSetPauseAnnotation) {
+			objects := []client.Object{tc.M3Machine}
+			if tc.Host != nil {
+				objects = append(objects, tc.Host)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, tc.M3Machine, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.SetPauseAnnotation(context.TODO())
+			if tc.ExpectError {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			if tc.Host == nil {
+				return
+			}
+
+			savedHost := bmov1alpha1.BareMetalHost{}
+			err = fakeClient.Get(context.TODO(),
+				client.ObjectKey{
+					Name:      tc.Host.Name,
+					Namespace: tc.Host.Namespace,
+				},
+				&savedHost,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			_, pausePresent := savedHost.Annotations[bmov1alpha1.PausedAnnotation]
+			if tc.ExpectPausePresent {
+				Expect(pausePresent).To(BeTrue())
+			} else {
+				Expect(pausePresent).To(BeFalse())
+			}
+			status, statusPresent := savedHost.Annotations[bmov1alpha1.StatusAnnotation]
+			if tc.ExpectStatusPresent {
+				Expect(statusPresent).To(BeTrue())
+				annotation, err := json.Marshal(&tc.Host.Status)
+				Expect(err).To(BeNil())
+				// (Note) manager code marshals the inspection data stored in annotation,
+				// which causes alphabetically reordering of keys. Since we are marshaling
+				// only the annotation, the status value here doesn't match the marshaled
+				// annotation data, because it wasn't re-ordered by the JSON marshaller.
+				// That's why we are marshaling status data as well so that its fields are
+				// also alphabetically reordered to match the annotation keys style..
+				obj := map[string]interface{}{}
+				err = json.Unmarshal(annotation, &obj)
+				Expect(err).To(BeNil())
+				annotation, _ = json.Marshal(obj)
+				Expect(status).To(Equal(string(annotation)))
+			} else {
+				Expect(statusPresent).To(BeFalse())
+			}
+		},
+		Entry("Set BMH Pause Annotation, with valid CAPM3 Paused annotations, already paused", testCaseSetPauseAnnotation{
+			Host: &bmov1alpha1.BareMetalHost{
 				ObjectMeta: *bmhObjectMetaWithValidCAPM3PausedAnnotations(),
 				Spec: bmov1alpha1.BareMetalHostSpec{
 					ConsumerRef: &corev1.ObjectReference{
@@ -933,6 +2365,27 @@ var _ = Describe("Metal3Machine manager", func() {
 			ExpectStatusPresent: true,
 			ExpectError:         false,
 		}),
+		Entry("Do not set BMH Pause Annotation on a detached BMH", testCaseSetPauseAnnotation{
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Annotations: map[string]string{
+						bmov1alpha1.DetachedAnnotation: "",
+					},
+				},
+			},
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
+				m3mObjectMetaWithValidAnnotations()),
+			ExpectPausePresent: false,
+			ExpectError:        false,
+		}),
+		Entry("Set BMH Pause Annotation, BMH gone, Should Not Error", testCaseSetPauseAnnotation{
+			Host: nil,
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
+				m3mObjectMetaWithValidAnnotations()),
+			ExpectError: false,
+		}),
 	)
 
 	type testCaseRemovePauseAnnotation struct {
@@ -945,10 +2398,11 @@ var _ = Describe("Metal3Machine manager", func() {
 
 	DescribeTable("Test Remove BMH Pause Annotation",
 		func(tc testCaseRemovePauseAnnotation) {
-			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(
-				tc.Host,
-				tc.M3Machine,
-				tc.Cluster).Build()
+			objects := []client.Object{tc.M3Machine, tc.Cluster}
+			if tc.Host != nil {
+				objects = append(objects, tc.Host)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
 			machineMgr, err := NewMachineManager(fakeClient,
 				tc.Cluster,
 				nil,
@@ -964,6 +2418,9 @@ var _ = Describe("Metal3Machine manager", func() {
 			} else {
 				Expect(err).NotTo(HaveOccurred())
 			}
+			if tc.Host == nil {
+				return
+			}
 
 			savedHost := bmov1alpha1.BareMetalHost{}
 			err = fakeClient.Get(context.TODO(),
@@ -1016,6 +2473,52 @@ var _ = Describe("Metal3Machine manager", func() {
 			ExpectPresent: true,
 			ExpectError:   false,
 		}),
+		Entry("Do not Remove Annotation, paused by user", testCaseRemovePauseAnnotation{
+			Cluster: newCluster(clusterName),
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						clusterv1.ClusterNameLabel: clusterName,
+					},
+					Annotations: map[string]string{
+						bmov1alpha1.PausedAnnotation: "user",
+					},
+				},
+			},
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
+				m3mObjectMetaWithValidAnnotations()),
+			ExpectPresent: true,
+			ExpectError:   false,
+		}),
+		Entry("Do not Remove Annotation from a detached BMH", testCaseRemovePauseAnnotation{
+			Cluster: newCluster(clusterName),
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						clusterv1.ClusterNameLabel: clusterName,
+					},
+					Annotations: map[string]string{
+						bmov1alpha1.PausedAnnotation:   PausedAnnotationKey,
+						bmov1alpha1.DetachedAnnotation: "",
+					},
+				},
+			},
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
+				m3mObjectMetaWithValidAnnotations()),
+			ExpectPresent: true,
+			ExpectError:   false,
+		}),
+		Entry("BMH gone, Should Not Error", testCaseRemovePauseAnnotation{
+			Cluster: newCluster(clusterName),
+			Host:    nil,
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
+				m3mObjectMetaWithValidAnnotations()),
+			ExpectError: false,
+		}),
 		Entry("No Annotation, Should Not Error", testCaseRemovePauseAnnotation{
 			Cluster: newCluster(clusterName),
 			Host: &bmov1alpha1.BareMetalHost{
@@ -1036,6 +2539,35 @@ var _ = Describe("Metal3Machine manager", func() {
 		}),
 	)
 
+	It("Keeps a BMH Pause Annotation set by someone else across pause and resume", func() {
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+				Labels: map[string]string{
+					clusterv1.ClusterNameLabel: clusterName,
+				},
+				Annotations: map[string]string{
+					bmov1alpha1.PausedAnnotation: "user",
+				},
+			},
+		}
+		m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil,
+			m3mObjectMetaWithValidAnnotations())
+		cluster := newCluster(clusterName)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host, m3m, cluster).Build()
+		machineMgr, err := NewMachineManager(fakeClient, cluster, nil, nil, m3m, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.SetPauseAnnotation(context.TODO())).To(Succeed())
+		Expect(machineMgr.RemovePauseAnnotation(context.TODO())).To(Succeed())
+
+		savedHost := bmov1alpha1.BareMetalHost{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), &savedHost)).To(Succeed())
+		Expect(savedHost.Annotations).To(HaveKeyWithValue(bmov1alpha1.PausedAnnotation, "user"))
+		Expect(savedHost.Annotations).NotTo(HaveKey(bmov1alpha1.StatusAnnotation))
+	})
+
 	type testCaseSetHostSpec struct {
 		UserDataNamespace           string
 		ExpectedUserDataNamespace   string
@@ -1134,6 +2666,323 @@ var _ = Describe("Metal3Machine manager", func() {
 		),
 	)
 
+	DescribeTable("Test SetHostSpec checksum type",
+		func(checksum string, checksumType *string, expectedChecksumType bmov1alpha1.ChecksumType) {
+			host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+				nil, false, "metadata", false, "",
+			)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+			m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+			m3mconfig.Spec.Image.Checksum = checksum
+			m3mconfig.Spec.Image.ChecksumType = checksumType
+			machine := newMachine(machineName, infrastructureRef)
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+			Expect(host.Spec.Image).NotTo(BeNil())
+			Expect(host.Spec.Image.Checksum).To(Equal(checksum))
+			Expect(host.Spec.Image.ChecksumType).To(Equal(expectedChecksumType))
+			Expect(m3mconfig.Spec.Image.ChecksumType).To(Equal(checksumType))
+		},
+		Entry("md5 sum without checksum type", "9f8e52de5bc4f4d9b6a6a9e4b2e3b8c1", nil,
+			bmov1alpha1.ChecksumType("md5"),
+		),
+		Entry("sha512 sum without checksum type", strings.Repeat("0a1b", 32), nil,
+			bmov1alpha1.ChecksumType("sha512"),
+		),
+		Entry("sha256 sum with checksum type",
+			"f7600f7a274d974a236c4da5161265859c32da93a7c8de6a77d560378a1384ef", pointer.String("sha256"),
+			bmov1alpha1.ChecksumType("sha256"),
+		),
+		Entry("URL without checksum type", testImageChecksumURL, nil,
+			bmov1alpha1.ChecksumType(""),
+		),
+		Entry("URL with checksum type", testImageChecksumURL, pointer.String("sha512"),
+			bmov1alpha1.ChecksumType("sha512"),
+		),
+	)
+
+	It("Counts the provisionings of the host once per provisioning", func() {
+		host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+			nil, false, "metadata", false, "",
+		)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+		machine := newMachine(machineName, infrastructureRef)
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The patch of the host failed, the provisioning is retried from the
+		// host as stored.
+		retried := host.DeepCopy()
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+		Expect(machineMgr.setHostSpec(context.TODO(), retried)).To(Succeed())
+		Expect(retried.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+
+		// The host being provisioned is not counted again.
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+
+		// Once deprovisioned, the next provisioning is counted.
+		host.Spec.Image = nil
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "2"))
+
+		// An invalid count is restarted.
+		host.Spec.Image = nil
+		host.Annotations[infrav1.HostProvisionCountAnnotation] = "invalid"
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+	})
+
+	It("Powers the host off and on with the powerState", func() {
+		host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+			nil, false, "metadata", false, "",
+		)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+		m3mconfig.Spec.PowerState = infrav1.PowerStateOff
+		machine := newMachine(machineName, infrastructureRef)
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The host is powered on until it is provisioned.
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		machineMgr.setPoweredOffCondition(host)
+		Expect(host.Spec.Online).To(BeTrue())
+		Expect(conditions.Has(m3mconfig, infrav1.PoweredOffCondition)).To(BeFalse())
+
+		// Once provisioned, it is powered off.
+		host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+		host.Status.PoweredOn = true
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		machineMgr.setPoweredOffCondition(host)
+		Expect(host.Spec.Online).To(BeFalse())
+		Expect(conditions.GetReason(m3mconfig, infrav1.PoweredOffCondition)).To(Equal(infrav1.PoweringOffReason))
+
+		host.Status.PoweredOn = false
+		machineMgr.setPoweredOffCondition(host)
+		Expect(conditions.IsTrue(m3mconfig, infrav1.PoweredOffCondition)).To(BeTrue())
+		Expect(machineMgr.IsPoweringOn()).To(BeFalse())
+
+		// Set back to on, it is powered on until the Machine has a Node.
+		m3mconfig.Spec.PowerState = infrav1.PowerStateOn
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		machineMgr.setPoweredOffCondition(host)
+		Expect(host.Spec.Online).To(BeTrue())
+		Expect(machineMgr.IsPoweringOn()).To(BeTrue())
+
+		host.Status.PoweredOn = true
+		machineMgr.setPoweredOffCondition(host)
+		Expect(machineMgr.IsPoweringOn()).To(BeTrue())
+
+		machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+		machineMgr.setPoweredOffCondition(host)
+		Expect(conditions.Has(m3mconfig, infrav1.PoweredOffCondition)).To(BeFalse())
+		Expect(machineMgr.IsPoweringOn()).To(BeFalse())
+
+		// Powered off again.
+		m3mconfig.Spec.PowerState = infrav1.PowerStateOff
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		machineMgr.setPoweredOffCondition(host)
+		Expect(host.Spec.Online).To(BeFalse())
+		Expect(conditions.GetReason(m3mconfig, infrav1.PoweredOffCondition)).To(Equal(infrav1.PoweringOffReason))
+	})
+
+	It("Powers the host on on demand once its image and bootstrap data are set", func() {
+		host := newBareMetalHost("host2", nil, bmov1alpha1.StateAvailable,
+			nil, false, "metadata", false, "",
+		)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+		m3mconfig.Spec.PowerManagementPolicy = infrav1.PowerManagementPolicyOnDemand
+		userData := m3mconfig.Status.UserData
+		m3mconfig.Status.UserData = nil
+		machine := newMachine(machineName, infrastructureRef)
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The host is kept powered off while waiting for the bootstrap data.
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Image).To(BeNil())
+		Expect(host.Spec.Online).To(BeFalse())
+
+		// It is powered on in the same update that sets the user data.
+		m3mconfig.Status.UserData = userData
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Image).NotTo(BeNil())
+		Expect(host.Spec.UserData).NotTo(BeNil())
+		Expect(host.Spec.Online).To(BeTrue())
+
+		// The power state only depends on the host, a new manager after a
+		// restart keeps it powered on.
+		machineMgr, err = NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		host.Status.Provisioning.State = bmov1alpha1.StateProvisioning
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Online).To(BeTrue())
+	})
+
+	type testCaseDeleteMachineAnnotation struct {
+		ControlPlane     bool
+		HostLabels       map[string]string
+		HostAnnotations  map[string]string
+		OperationalError bool
+		PoweredOff       bool
+		PowerStateOff    bool
+		Annotation       *string
+		ExpectAnnotation *string
+	}
+
+	DescribeTable("Test setDeleteMachineAnnotation",
+		func(tc testCaseDeleteMachineAnnotation) {
+			host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+				nil, false, "metadata", false, "",
+			)
+			host.Labels = tc.HostLabels
+			host.Annotations = tc.HostAnnotations
+			host.Spec.Online = true
+			host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+			host.Status.PoweredOn = !tc.PoweredOff
+			if tc.OperationalError {
+				host.Status.OperationalStatus = bmov1alpha1.OperationalStatusError
+				host.Status.ErrorType = bmov1alpha1.ProvisionedRegistrationError
+			}
+			m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+			if tc.PowerStateOff {
+				m3mconfig.Spec.PowerState = infrav1.PowerStateOff
+			}
+			machine := newMachine(machineName, infrastructureRef)
+			if tc.ControlPlane {
+				machine.Labels = map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+			}
+			if tc.Annotation != nil {
+				machine.Annotations = map[string]string{clusterv1.DeleteMachineAnnotation: *tc.Annotation}
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(machine).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.setDeleteMachineAnnotation(context.TODO(), host)).To(Succeed())
+
+			savedMachine := &clusterv1.Machine{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(machine), savedMachine)).To(Succeed())
+			if tc.ExpectAnnotation == nil {
+				Expect(savedMachine.Annotations).NotTo(HaveKey(clusterv1.DeleteMachineAnnotation))
+			} else {
+				Expect(savedMachine.Annotations).To(HaveKeyWithValue(clusterv1.DeleteMachineAnnotation, *tc.ExpectAnnotation))
+			}
+		},
+		Entry("Healthy host", testCaseDeleteMachineAnnotation{}),
+		Entry("Host in error", testCaseDeleteMachineAnnotation{
+			OperationalError: true,
+			ExpectAnnotation: pointer.String("capm3:HostError"),
+		}),
+		Entry("Host powered off unexpectedly", testCaseDeleteMachineAnnotation{
+			PoweredOff:       true,
+			ExpectAnnotation: pointer.String("capm3:HostPoweredOff"),
+		}),
+		Entry("Host powered off with the powerState", testCaseDeleteMachineAnnotation{
+			PoweredOff:    true,
+			PowerStateOff: true,
+		}),
+		Entry("Host powered off by a remediation", testCaseDeleteMachineAnnotation{
+			PoweredOff:      true,
+			HostAnnotations: map[string]string{"reboot.metal3.io/metal3-remediation-abc": "{}"},
+		}),
+		Entry("Host labelled with prefer-delete", testCaseDeleteMachineAnnotation{
+			HostLabels:       map[string]string{infrav1.PreferDeleteLabel: ""},
+			ExpectAnnotation: pointer.String("capm3:PreferDeleteLabel"),
+		}),
+		Entry("Host recovered", testCaseDeleteMachineAnnotation{
+			Annotation: pointer.String("capm3:HostError"),
+		}),
+		Entry("Reason changed", testCaseDeleteMachineAnnotation{
+			PoweredOff:       true,
+			Annotation:       pointer.String("capm3:HostError"),
+			ExpectAnnotation: pointer.String("capm3:HostPoweredOff"),
+		}),
+		Entry("Annotation set by the user", testCaseDeleteMachineAnnotation{
+			Annotation:       pointer.String("yes"),
+			ExpectAnnotation: pointer.String("yes"),
+		}),
+		Entry("Control plane Machine, host in error", testCaseDeleteMachineAnnotation{
+			ControlPlane:     true,
+			OperationalError: true,
+		}),
+	)
+
+	It("Provisions the host with the custom deploy method instead of the image", func() {
+		host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+			nil, false, "metadata", false, "",
+		)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+		m3mconfig.Spec.Image = infrav1.Image{}
+		m3mconfig.Spec.CustomDeploy = &infrav1.CustomDeploy{Method: "install_coreos"}
+		machine := newMachine(machineName, infrastructureRef)
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Image).To(BeNil())
+		Expect(host.Spec.CustomDeploy).To(Equal(&bmov1alpha1.CustomDeploy{Method: "install_coreos"}))
+		Expect(host.Spec.UserData).To(Equal(m3mconfig.Status.UserData))
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+
+		// The host being provisioned is left as is.
+		m3mconfig.Spec.CustomDeploy.Method = "other"
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Spec.Image).To(BeNil())
+		Expect(host.Spec.CustomDeploy).To(Equal(&bmov1alpha1.CustomDeploy{Method: "install_coreos"}))
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+	})
+
+	It("Labels the host with the Metal3Data owning its secrets", func() {
+		host := newBareMetalHost("host2", nil, bmov1alpha1.StateNone,
+			nil, false, "metadata", false, "",
+		)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3mconfig, infrastructureRef := newConfig("", map[string]string{}, []infrav1.HostSelectorRequirement{})
+		m3mconfig.Status.MetaData = m3mSecretStatus().MetaData
+		m3mconfig.Status.NetworkData = m3mSecretStatus().NetworkData
+		m3mconfig.Status.RenderedData = &corev1.ObjectReference{Name: metal3DataName, Namespace: namespaceName}
+		machine := newMachine(machineName, infrastructureRef)
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3mconfig,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Labels).To(HaveKeyWithValue(infrav1.DataSecretsOwnerLabel, metal3DataName))
+
+		// A host provisioned without the secrets of a Metal3Data is not
+		// labeled.
+		host.Spec.Image = nil
+		host.Spec.MetaData = nil
+		host.Spec.NetworkData = nil
+		m3mconfig.Status.MetaData = nil
+		m3mconfig.Status.NetworkData = nil
+		Expect(machineMgr.setHostSpec(context.TODO(), host)).To(Succeed())
+		Expect(host.Labels).NotTo(HaveKey(infrav1.DataSecretsOwnerLabel))
+	})
+
 	DescribeTable("Test SetHostConsumerRef",
 		func(tc testCaseSetHostSpec) {
 			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(tc.Host).Build()
@@ -1162,6 +3011,7 @@ var _ = Describe("Metal3Machine manager", func() {
 
 			if tc.expectNodeReuseLabelDeleted {
 				Expect(tc.Host.Labels[nodeReuseLabelName]).To(Equal(""))
+				Expect(tc.Host.Annotations).NotTo(HaveKey(infrav1.HostNodeReuseSinceAnnotation))
 			}
 		},
 		Entry("User data has explicit alternate namespace", testCaseSetHostSpec{
@@ -1320,6 +3170,72 @@ var _ = Describe("Metal3Machine manager", func() {
 		ExpectError   bool
 	}
 
+	It("Records the provisioning metrics of the cluster once", func() {
+		cluster := newCluster(clusterName)
+		cluster.UID = "provisioning-metrics-cluster"
+		DeferCleanup(ProvisioningMetrics.DeleteCluster, cluster)
+		labels := prometheus.Labels{"namespace": namespaceName, "cluster": clusterName}
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+				UID:       Bmhuid,
+			},
+			Status: bmov1alpha1.BareMetalHostStatus{
+				ErrorType:    bmov1alpha1.ProvisioningError,
+				ErrorMessage: "image download failed",
+				Provisioning: bmov1alpha1.ProvisionStatus{
+					State: bmov1alpha1.StateProvisioning,
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+		m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil, m3mObjectMetaWithValidAnnotations())
+		machineMgr, err := NewMachineManager(fakeClient, cluster, nil, newMachine("", nil), m3m, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Counting a provisioning failure once")
+		for i := 0; i < 2; i++ {
+			bmhID, err := machineMgr.GetBaremetalHostID(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bmhID).To(BeNil())
+		}
+		Expect(conditions.GetReason(m3m, infrav1.BareMetalHostProvisionedCondition)).To(Equal(infrav1.BareMetalHostProvisioningFailedReason))
+		Expect(conditions.GetMessage(m3m, infrav1.BareMetalHostProvisionedCondition)).To(ContainSubstring("image download failed"))
+		Expect(testutil.ToFloat64(ProvisioningMetrics.failures.With(labels))).To(Equal(1.0))
+
+		By("Observing the provisioning duration once")
+		start := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+		host.Status.ErrorType = ""
+		host.Status.ErrorMessage = ""
+		host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+		host.Status.OperationHistory.Provision = bmov1alpha1.OperationMetric{
+			Start: start,
+			End:   metav1.NewTime(start.Add(5 * time.Minute)),
+		}
+		Expect(fakeClient.Update(context.TODO(), host)).To(Succeed())
+		for i := 0; i < 2; i++ {
+			bmhID, err := machineMgr.GetBaremetalHostID(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bmhID).NotTo(BeNil())
+		}
+		Expect(testutil.CollectAndCompare(ProvisioningMetrics, strings.NewReader(`
+# HELP capm3_baremetalhost_provisioning_duration_seconds Duration of the provisioning of the BareMetalHosts of the Metal3Machines, per cluster.
+# TYPE capm3_baremetalhost_provisioning_duration_seconds histogram
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="60"} 0
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="120"} 0
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="300"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="600"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="900"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="1200"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="1800"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="3600"} 1
+capm3_baremetalhost_provisioning_duration_seconds_bucket{cluster="`+clusterName+`",namespace="`+namespaceName+`",le="+Inf"} 1
+capm3_baremetalhost_provisioning_duration_seconds_sum{cluster="`+clusterName+`",namespace="`+namespaceName+`"} 300
+capm3_baremetalhost_provisioning_duration_seconds_count{cluster="`+clusterName+`",namespace="`+namespaceName+`"} 1
+`), "capm3_baremetalhost_provisioning_duration_seconds")).To(Succeed())
+	})
+
 	DescribeTable("Test Get and Set Provider ID",
 		func(tc testCaseGetSetProviderID) {
 			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(tc.Host).Build()
@@ -1335,10 +3251,19 @@ var _ = Describe("Metal3Machine manager", func() {
 				Expect(err).NotTo(HaveOccurred())
 			}
 
+			condition := conditions.Get(tc.M3Machine, infrav1.BareMetalHostProvisionedCondition)
 			if tc.ExpectPresent {
 				Expect(bmhID).NotTo(BeNil())
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionTrue))
 			} else {
 				Expect(bmhID).To(BeNil())
+				if !tc.ExpectError {
+					Expect(condition).NotTo(BeNil())
+					Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+					Expect(condition.Reason).To(Equal(infrav1.ProvisioningBareMetalHostReason))
+					Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityInfo))
+				}
 				return
 			}
 
@@ -1379,6 +3304,55 @@ var _ = Describe("Metal3Machine manager", func() {
 			ExpectPresent: true,
 			ExpectError:   false,
 		}),
+		Entry("Set ProviderID, provisioned with the custom deploy method", testCaseGetSetProviderID{
+			Machine: newMachine("", nil),
+			M3Machine: func() *infrav1.Metal3Machine {
+				m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil,
+					m3mObjectMetaWithValidAnnotations(),
+				)
+				m3m.Spec.CustomDeploy = &infrav1.CustomDeploy{Method: "install_coreos"}
+				return m3m
+			}(),
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					UID:       Bmhuid,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State:        bmov1alpha1.StateProvisioned,
+						CustomDeploy: &bmov1alpha1.CustomDeploy{Method: "install_coreos"},
+					},
+				},
+			},
+			ExpectPresent: true,
+			ExpectError:   false,
+		}),
+		Entry("Set ProviderID, not provisioned with the custom deploy method", testCaseGetSetProviderID{
+			Machine: newMachine("", nil),
+			M3Machine: func() *infrav1.Metal3Machine {
+				m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil,
+					m3mObjectMetaWithValidAnnotations(),
+				)
+				m3m.Spec.CustomDeploy = &infrav1.CustomDeploy{Method: "install_coreos"}
+				return m3m
+			}(),
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					UID:       Bmhuid,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateProvisioned,
+					},
+				},
+			},
+			ExpectPresent: false,
+			ExpectError:   false,
+		}),
 		Entry("Set ProviderID, wrong state", testCaseGetSetProviderID{
 			Machine: newMachine("", nil),
 			M3Machine: newMetal3Machine(metal3machineName, m3mSpec(), nil,
@@ -1557,6 +3531,8 @@ var _ = Describe("Metal3Machine manager", func() {
 		MachineIsControlPlane           bool
 		MachineIsNotControlPlane        bool
 		ExpectedBMHOnlineStatus         bool
+		ExpectCustomDeployCleared       bool
+		ExpectRootDeviceHints           *bmov1alpha1.RootDeviceHints
 		capm3fasttrack                  string
 		Cluster                         *clusterv1.Cluster
 		Metal3MachineTemplate           *infrav1.Metal3MachineTemplate
@@ -1620,6 +3596,10 @@ var _ = Describe("Metal3Machine manager", func() {
 					expectedName = tc.ExpectedConsumerRef.Name
 				}
 				Expect(name).To(Equal(expectedName))
+				if tc.Host.Spec.ConsumerRef != nil && host.Spec.ConsumerRef == nil {
+					_, released := hostLastReleased(&host)
+					Expect(released).To(BeTrue())
+				}
 				if machineMgr.Metal3Machine.Status.MetaData == nil {
 					Expect(host.Spec.MetaData).NotTo(BeNil())
 				}
@@ -1629,6 +3609,18 @@ var _ = Describe("Metal3Machine manager", func() {
 				if machineMgr.Metal3Machine.Status.UserData == nil {
 					Expect(host.Spec.UserData).NotTo(BeNil())
 				}
+				if host.Spec.MetaData == nil && host.Spec.NetworkData == nil {
+					Expect(host.Labels).NotTo(HaveKey(infrav1.DataSecretsOwnerLabel))
+				}
+				if tc.ExpectCustomDeployCleared {
+					Expect(host.Spec.CustomDeploy).To(BeNil())
+				}
+				if host.Spec.ConsumerRef == nil {
+					Expect(host.Annotations).NotTo(HaveKey(infrav1.HostRootDeviceHintsAnnotation))
+					Expect(host.Spec.RootDeviceHints).To(Equal(tc.ExpectRootDeviceHints))
+					Expect(host.Annotations).NotTo(HaveKey(infrav1.HostReverseSyncedLabelsAnnotation))
+					Expect(host.Labels).NotTo(HaveKey("topology.kubernetes.io/zone"))
+				}
 			}
 
 			tmpBootstrapSecret := corev1.Secret{}
@@ -1713,6 +3705,17 @@ var _ = Describe("Metal3Machine manager", func() {
 					Expect(Capm3FastTrack).To(Equal("false"))
 				}
 				Expect(savedbmh.Spec.Online).To(Equal(tc.ExpectedBMHOnlineStatus))
+				if tc.Metal3MachineTemplate != nil && tc.Metal3MachineTemplate.Spec.NodeReuse {
+					Expect(savedbmh.Labels).To(HaveKey(nodeReuseLabelName))
+					Expect(savedbmh.Annotations).To(HaveKeyWithValue(infrav1.HostNodeReuseTemplateAnnotation,
+						tc.Metal3MachineTemplate.Name))
+					if gracePeriod := tc.Metal3MachineTemplate.Spec.NodeReuseGracePeriod; gracePeriod != nil {
+						Expect(savedbmh.Annotations).To(HaveKeyWithValue(infrav1.HostNodeReuseGracePeriodAnnotation,
+							gracePeriod.Duration.String()))
+					} else {
+						Expect(savedbmh.Annotations).NotTo(HaveKey(infrav1.HostNodeReuseGracePeriodAnnotation))
+					}
+				}
 			}
 		},
 		Entry("Deprovisioning needed", testCaseDelete{
@@ -1728,6 +3731,42 @@ var _ = Describe("Metal3Machine manager", func() {
 			Secret:                  newSecret(),
 			ExpectedBMHOnlineStatus: false,
 		}),
+		Entry("Deprovisioning needed, secrets of the Metal3Data released", testCaseDelete{
+			Host: func() *bmov1alpha1.BareMetalHost {
+				host := newBareMetalHost(baremetalhostName, bmhSpec(),
+					bmov1alpha1.StateProvisioned, bmhStatus(), false, "metadata", true, "",
+				)
+				host.Spec.MetaData = m3mSecretStatus().MetaData
+				host.Spec.NetworkData = m3mSecretStatus().NetworkData
+				host.Labels[infrav1.DataSecretsOwnerLabel] = metal3DataName
+				return host
+			}(),
+			Machine: newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, nil, m3mSecretStatus(),
+				m3mObjectMetaWithValidAnnotations(),
+			),
+			ExpectedConsumerRef:     consumerRef(),
+			ExpectedResult:          ReconcileError{},
+			Secret:                  newSecret(),
+			ExpectedBMHOnlineStatus: false,
+		}),
+		Entry("Deprovisioning needed, custom deploy method cleared", testCaseDelete{
+			Host: newBareMetalHost(baremetalhostName, &bmov1alpha1.BareMetalHostSpec{
+				ConsumerRef:  consumerRef(),
+				CustomDeploy: &bmov1alpha1.CustomDeploy{Method: "install_coreos"},
+				Online:       true,
+			}, bmov1alpha1.StateProvisioned, bmhStatus(), false, "metadata", true, "",
+			),
+			Machine: newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, nil, m3mSecretStatus(),
+				m3mObjectMetaWithValidAnnotations(),
+			),
+			ExpectedConsumerRef:       consumerRef(),
+			ExpectedResult:            ReconcileError{},
+			Secret:                    newSecret(),
+			ExpectedBMHOnlineStatus:   false,
+			ExpectCustomDeployCleared: true,
+		}),
 		Entry("No Host status, deprovisioning needed", testCaseDelete{
 			Host: newBareMetalHost(baremetalhostName, bmhSpec(), bmov1alpha1.StateNone,
 				nil, false, "metadata", true, "",
@@ -1789,6 +3828,42 @@ var _ = Describe("Metal3Machine manager", func() {
 				ExpectSecretDeleted: true,
 			},
 		),
+		Entry("Consumer ref removed, rootDeviceHints of the Metal3Machine cleared",
+			testCaseDelete{
+				Host: func() *bmov1alpha1.BareMetalHost {
+					host := newBareMetalHost(baremetalhostName, bmhSpecNoImg(),
+						bmov1alpha1.StateExternallyProvisioned, bmhPowerStatus(), false, "metadata", true, "",
+					)
+					host.Spec.RootDeviceHints = &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"}
+					host.Annotations = map[string]string{infrav1.HostRootDeviceHintsAnnotation: ""}
+					return host
+				}(),
+				Machine: newMachine(machineName, nil),
+				M3Machine: newMetal3Machine(metal3machineName, nil, m3mSecretStatus(),
+					m3mObjectMetaWithValidAnnotations(),
+				),
+				Secret:              newSecret(),
+				ExpectSecretDeleted: true,
+			},
+		),
+		Entry("Consumer ref removed, rootDeviceHints of the host kept",
+			testCaseDelete{
+				Host: func() *bmov1alpha1.BareMetalHost {
+					host := newBareMetalHost(baremetalhostName, bmhSpecNoImg(),
+						bmov1alpha1.StateExternallyProvisioned, bmhPowerStatus(), false, "metadata", true, "",
+					)
+					host.Spec.RootDeviceHints = &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"}
+					return host
+				}(),
+				Machine: newMachine(machineName, nil),
+				M3Machine: newMetal3Machine(metal3machineName, nil, m3mSecretStatus(),
+					m3mObjectMetaWithValidAnnotations(),
+				),
+				Secret:                newSecret(),
+				ExpectSecretDeleted:   true,
+				ExpectRootDeviceHints: &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"},
+			},
+		),
 		Entry("Consumer ref should be removed from unmanaged host",
 			testCaseDelete{
 				Host: newBareMetalHost(baremetalhostName, bmhSpecNoImg(),
@@ -1901,6 +3976,22 @@ var _ = Describe("Metal3Machine manager", func() {
 			ExpectSecretDeleted:       true,
 			ExpectClusterLabelDeleted: true,
 		}),
+		Entry("Reverse synced labels should be removed", testCaseDelete{
+			Machine:   newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, m3mSpecAll(), m3mSecretStatus(), m3mObjectMetaWithValidAnnotations()),
+			Host: func() *bmov1alpha1.BareMetalHost {
+				host := newBareMetalHost(baremetalhostName, bmhSpecBMC(), bmov1alpha1.StateNone, nil, false, "metadata", true, "")
+				host.Labels["topology.kubernetes.io/zone"] = "zone-a"
+				if host.Annotations == nil {
+					host.Annotations = map[string]string{}
+				}
+				host.Annotations[infrav1.HostReverseSyncedLabelsAnnotation] = "topology.kubernetes.io/zone"
+				return host
+			}(),
+			BMCSecret:                 newBMCSecret("mycredentials", true),
+			ExpectSecretDeleted:       true,
+			ExpectClusterLabelDeleted: true,
+		}),
 		Entry("PausedAnnotation/CAPM3 should be removed", testCaseDelete{
 			Machine:   newMachine(machineName, nil),
 			M3Machine: newMetal3Machine(metal3machineName, m3mSpecAll(), m3mSecretStatus(), m3mObjectMetaWithValidAnnotations()),
@@ -1965,6 +4056,22 @@ var _ = Describe("Metal3Machine manager", func() {
 			capm3fasttrack:          "true",
 			ExpectedBMHOnlineStatus: true,
 		}),
+		Entry("Capm3FastTrack is set to true, OnDemand power management policy, deprovisioned bmh powered off", testCaseDelete{
+			Host: newBareMetalHost(baremetalhostName, &bmov1alpha1.BareMetalHostSpec{
+				ConsumerRef: consumerRef(),
+				Online:      true,
+			}, bmov1alpha1.StateAvailable, bmhStatus(), false, "metadata", true, ""),
+			Machine: newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				PowerManagementPolicy: infrav1.PowerManagementPolicyOnDemand,
+			}, m3mSecretStatus(),
+				m3mObjectMetaWithValidAnnotations(),
+			),
+			Secret:                  newSecret(),
+			ExpectSecretDeleted:     true,
+			capm3fasttrack:          "true",
+			ExpectedBMHOnlineStatus: false,
+		}),
 		Entry("Capm3FastTrack is set to false, AutomatedCleaning mode is set to disabled, set bmh online field to false", testCaseDelete{
 			Host: newBareMetalHost(baremetalhostName, bmhSpec(),
 				bmov1alpha1.StateDeprovisioning, bmhStatus(), false, "disabled", true, ""),
@@ -2167,34 +4274,874 @@ var _ = Describe("Metal3Machine manager", func() {
 					Labels: map[string]string{
 						clusterv1.ClusterNameLabel: clusterName,
 					},
-					Annotations: map[string]string{
-						HostAnnotation:                           namespaceName + "/" + baremetalhostName,
-						"cluster.x-k8s.io/cloned-from-name":      "abc",
-						"cluster.x-k8s.io/cloned-from-groupkind": infrav1.ClonedFromGroupKind,
+					Annotations: map[string]string{
+						HostAnnotation:                           namespaceName + "/" + baremetalhostName,
+						"cluster.x-k8s.io/cloned-from-name":      "abc",
+						"cluster.x-k8s.io/cloned-from-groupkind": infrav1.ClonedFromGroupKind,
+					},
+				}),
+			Cluster:             newCluster(clusterName),
+			Secret:              newSecret(),
+			ExpectSecretDeleted: false,
+			Metal3MachineTemplate: &infrav1.Metal3MachineTemplate{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineTemplate",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc",
+					Namespace: namespaceName,
+				},
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: infrav1.Metal3MachineSpec{
+							AutomatedCleaningMode: pointer.String(infrav1.CleaningModeDisabled),
+						},
+					},
+					NodeReuse:            true,
+					NodeReuseGracePeriod: &metav1.Duration{Duration: time.Hour},
+				}},
+		}),
+	)
+
+	Describe("Test detached host", func() {
+		consumedHost := func(annotations map[string]string) *bmov1alpha1.BareMetalHost {
+			return &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        baremetalhostName,
+					Namespace:   namespaceName,
+					Annotations: annotations,
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					Online: true,
+					Image:  &bmov1alpha1.Image{URL: testImageURL},
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       metal3machineName,
+						Namespace:  namespaceName,
+						Kind:       "Metal3Machine",
+						APIVersion: infrav1.GroupVersion.String(),
+					},
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateProvisioned,
+					},
+				},
+			}
+		}
+		detached := map[string]string{bmov1alpha1.DetachedAnnotation: ""}
+
+		type testCaseIsHostDetached struct {
+			Host           *bmov1alpha1.BareMetalHost
+			ConditionSet   bool
+			ExpectDetached bool
+			ExpectedStatus corev1.ConditionStatus
+		}
+
+		DescribeTable("Test IsHostDetached",
+			func(tc testCaseIsHostDetached) {
+				objects := []client.Object{}
+				if tc.Host != nil {
+					objects = append(objects, tc.Host)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil, m3mObjectMetaWithValidAnnotations())
+				if tc.ConditionSet {
+					conditions.MarkFalse(m3m, infrav1.HostAttachedCondition, infrav1.HostDetachedReason, clusterv1.ConditionSeverityWarning, "")
+				}
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				isDetached, err := machineMgr.IsHostDetached(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isDetached).To(Equal(tc.ExpectDetached))
+				switch tc.ExpectedStatus {
+				case corev1.ConditionTrue:
+					Expect(conditions.IsTrue(m3m, infrav1.HostAttachedCondition)).To(BeTrue())
+				case corev1.ConditionFalse:
+					Expect(conditions.IsFalse(m3m, infrav1.HostAttachedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(m3m, infrav1.HostAttachedCondition)).To(Equal(infrav1.HostDetachedReason))
+				default:
+					Expect(conditions.Has(m3m, infrav1.HostAttachedCondition)).To(BeFalse())
+				}
+			},
+			Entry("No host", testCaseIsHostDetached{}),
+			Entry("Attached host", testCaseIsHostDetached{
+				Host:           consumedHost(nil),
+				ExpectedStatus: corev1.ConditionTrue,
+			}),
+			Entry("Host detached", testCaseIsHostDetached{
+				Host:           consumedHost(detached),
+				ExpectDetached: true,
+				ExpectedStatus: corev1.ConditionFalse,
+			}),
+			Entry("Host still detached", testCaseIsHostDetached{
+				Host:           consumedHost(detached),
+				ConditionSet:   true,
+				ExpectDetached: true,
+				ExpectedStatus: corev1.ConditionFalse,
+			}),
+			Entry("Host attached again", testCaseIsHostDetached{
+				Host:           consumedHost(nil),
+				ConditionSet:   true,
+				ExpectedStatus: corev1.ConditionTrue,
+			}),
+		)
+
+		It("Releases a detached host without deprovisioning it on deletion", func() {
+			host := consumedHost(detached)
+			host.Spec.ConsumerRef.Kind = "M3Machine"
+			host.Labels = map[string]string{
+				clusterv1.ClusterNameLabel:    clusterName,
+				infrav1.DataSecretsOwnerLabel: "abc-data",
+			}
+			host.Spec.MetaData = &corev1.SecretReference{Name: "abc-metadata", Namespace: namespaceName}
+			host.Spec.NetworkData = &corev1.SecretReference{Name: "abc-networkdata", Namespace: namespaceName}
+			data := &infrav1.Metal3Data{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc-data",
+					Namespace: namespaceName,
+				},
+				Spec: infrav1.Metal3DataSpec{
+					MetaData:    &corev1.SecretReference{Name: "abc-metadata"},
+					NetworkData: &corev1.SecretReference{Name: "abc-networkdata"},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host, data).Build()
+			m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil, m3mObjectMetaWithValidAnnotations())
+			m3m.Status.MetaData = host.Spec.MetaData.DeepCopy()
+			m3m.Status.NetworkData = host.Spec.NetworkData.DeepCopy()
+			machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil,
+				newMachine(machineName, nil), m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			// The Metal3Data waits for the host to release its secrets.
+			dataMgr, err := NewDataManager(fakeClient, data, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataMgr.WaitForHostRelease(context.TODO())).NotTo(Succeed())
+
+			Expect(machineMgr.Delete(context.TODO())).To(Succeed())
+
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+			Expect(savedHost.Spec.MetaData).To(BeNil())
+			Expect(savedHost.Spec.NetworkData).To(BeNil())
+			Expect(savedHost.Labels).NotTo(HaveKey(clusterv1.ClusterNameLabel))
+			Expect(savedHost.Labels).NotTo(HaveKey(infrav1.DataSecretsOwnerLabel))
+			Expect(savedHost.Annotations).To(HaveKey(bmov1alpha1.DetachedAnnotation))
+			Expect(savedHost.Spec.Image).NotTo(BeNil())
+			Expect(savedHost.Spec.Online).To(BeTrue())
+			Expect(m3m.Status.RenderedHost).To(BeNil())
+
+			// The Metal3Data deletion then proceeds.
+			Expect(dataMgr.WaitForHostRelease(context.TODO())).To(Succeed())
+		})
+
+		It("Holds the deprovisioning while the Machine has pre-terminate hooks", func() {
+			host := consumedHost(nil)
+			host.Spec.ConsumerRef.Kind = "M3Machine"
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), host)).To(Succeed())
+			m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil, m3mObjectMetaWithValidAnnotations())
+			machine := newMachine(machineName, nil)
+			machine.Annotations = map[string]string{
+				clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/asset-management": "asset-manager",
+				clusterv1.PreDrainDeleteHookAnnotationPrefix + "/other":                "someone",
+			}
+			machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil,
+				machine, m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			// The deletion is blocked, the host is left untouched.
+			err = machineMgr.Delete(context.TODO())
+			Expect(errors.Is(err, ErrWaitingForPreTerminateHook)).To(BeTrue())
+			var reconcileError ReconcileError
+			Expect(errors.As(err, &reconcileError)).To(BeTrue())
+			Expect(reconcileError.IsTransient()).To(BeTrue())
+			Expect(conditions.GetReason(m3m, infrav1.KubernetesNodeReadyCondition)).To(Equal(infrav1.WaitingForPreTerminateHookReason))
+			Expect(conditions.GetMessage(m3m, infrav1.KubernetesNodeReadyCondition)).To(ContainSubstring("/asset-management"))
+			Expect(conditions.GetMessage(m3m, infrav1.KubernetesNodeReadyCondition)).NotTo(ContainSubstring("/other"))
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.ResourceVersion).To(Equal(host.ResourceVersion))
+			Expect(savedHost.Spec.Image).NotTo(BeNil())
+			Expect(savedHost.Spec.Online).To(BeTrue())
+
+			// Once the hook is cleared, the host is deprovisioned.
+			delete(machine.Annotations, clusterv1.PreTerminateDeleteHookAnnotationPrefix+"/asset-management")
+			err = machineMgr.Delete(context.TODO())
+			Expect(errors.Is(err, ErrWaitingForPreTerminateHook)).To(BeFalse())
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.Spec.Image).To(BeNil())
+			Expect(savedHost.Spec.Online).To(BeFalse())
+		})
+	})
+
+	Describe("Test adopted host", func() {
+		adoptedHost := func(name string, state bmov1alpha1.ProvisioningState) *bmov1alpha1.BareMetalHost {
+			return &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespaceName,
+					UID:       Bmhuid,
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					Online:                true,
+					ExternallyProvisioned: true,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: state,
+					},
+					PoweredOn: true,
+				},
+			}
+		}
+		adoptingMachine := func(extraAnnotations map[string]string) *infrav1.Metal3Machine {
+			objMeta := &metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+				Annotations: map[string]string{
+					infrav1.AdoptHostAnnotation: baremetalhostName,
+				},
+			}
+			for key, value := range extraAnnotations {
+				objMeta.Annotations[key] = value
+			}
+			return newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{
+					URL:      testImageURL,
+					Checksum: testImageChecksumURL,
+				},
+			}, nil, objMeta)
+		}
+		machine := func() *clusterv1.Machine {
+			return &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      machineName,
+					Namespace: namespaceName,
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: clusterName,
+					Bootstrap: clusterv1.Bootstrap{
+						DataSecretName: pointer.String("bootstrap-data"),
+					},
+				},
+			}
+		}
+
+		It("Adopts the named externally provisioned host without provisioning it", func() {
+			// An available host that would otherwise be chosen.
+			otherHost := adoptedHost("other-host", bmov1alpha1.StateAvailable)
+			otherHost.UID = "other-uid"
+			host := adoptedHost(baremetalhostName, bmov1alpha1.StateExternallyProvisioned)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(otherHost, host).Build()
+			m3m := adoptingMachine(nil)
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine(), m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machineMgr.IsAdoptingHost()).To(BeTrue())
+
+			Expect(machineMgr.Associate(context.TODO())).To(Succeed())
+			Expect(m3m.Annotations[HostAnnotation]).To(Equal(namespaceName + "/" + baremetalhostName))
+			Expect(m3m.Status.UserData).To(BeNil())
+
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).NotTo(BeNil())
+			Expect(savedHost.Spec.ConsumerRef.Name).To(Equal(metal3machineName))
+			Expect(savedHost.Spec.Image).To(BeNil())
+			Expect(savedHost.Spec.UserData).To(BeNil())
+			Expect(savedHost.Spec.Online).To(BeTrue())
+			Expect(savedHost.Labels[clusterv1.ClusterNameLabel]).To(Equal(clusterName))
+
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(otherHost), savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+		})
+
+		DescribeTable("Test host not adoptable",
+			func(host *bmov1alpha1.BareMetalHost) {
+				objects := []client.Object{}
+				if host != nil {
+					objects = append(objects, host)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				m3m := adoptingMachine(nil)
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine(), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				err = machineMgr.Associate(context.TODO())
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				Expect(conditions.GetReason(m3m, infrav1.AssociateBMHCondition)).To(Equal(infrav1.HostNotAdoptableReason))
+				Expect(m3m.Annotations).NotTo(HaveKey(HostAnnotation))
+			},
+			Entry("Host not found", nil),
+			Entry("Host not externally provisioned", adoptedHost(baremetalhostName, bmov1alpha1.StateAvailable)),
+			Entry("Host consumed by another machine", func() *bmov1alpha1.BareMetalHost {
+				host := adoptedHost(baremetalhostName, bmov1alpha1.StateExternallyProvisioned)
+				host.Spec.ConsumerRef = &corev1.ObjectReference{
+					Name:       "other-machine",
+					Namespace:  namespaceName,
+					Kind:       "Metal3Machine",
+					APIVersion: infrav1.GroupVersion.String(),
+				}
+				return host
+			}()),
+		)
+
+		type testCaseAdoptedNodeProviderID struct {
+			Node               *corev1.Node
+			ExpectTransient    bool
+			ExpectError        bool
+			ExpectedProviderID string
+		}
+
+		DescribeTable("Test AdoptedNodeProviderID",
+			func(tc testCaseAdoptedNodeProviderID) {
+				host := adoptedHost(baremetalhostName, bmov1alpha1.StateExternallyProvisioned)
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+				objects := []runtime.Object{}
+				if tc.Node != nil {
+					objects = append(objects, tc.Node)
+				}
+				corev1Client := clientfake.NewSimpleClientset(objects...).CoreV1()
+				clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+					return corev1Client, nil
+				}
+				m3m := adoptingMachine(map[string]string{HostAnnotation: namespaceName + "/" + baremetalhostName})
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil, machine(), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				providerID, err := machineMgr.AdoptedNodeProviderID(context.TODO(), clientGetter)
+				if tc.ExpectTransient {
+					var reconcileError ReconcileError
+					Expect(errors.As(err, &reconcileError)).To(BeTrue())
+					Expect(reconcileError.IsTransient()).To(BeTrue())
+					return
+				}
+				if tc.ExpectError {
+					Expect(err).To(HaveOccurred())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(providerID).To(Equal(tc.ExpectedProviderID))
+				node, err := corev1Client.Nodes().Get(context.TODO(), baremetalhostName, metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(node.Spec.ProviderID).To(Equal(tc.ExpectedProviderID))
+			},
+			Entry("Node not found", testCaseAdoptedNodeProviderID{
+				ExpectTransient: true,
+			}),
+			Entry("Node without providerID", testCaseAdoptedNodeProviderID{
+				Node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: baremetalhostName}},
+				ExpectedProviderID: fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix,
+					namespaceName, baremetalhostName, metal3machineName,
+				),
+			}),
+			Entry("Node with the legacy providerID", testCaseAdoptedNodeProviderID{
+				Node: &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: baremetalhostName},
+					Spec:       corev1.NodeSpec{ProviderID: ProviderIDPrefix + string(Bmhuid)},
+				},
+				ExpectedProviderID: ProviderIDPrefix + string(Bmhuid),
+			}),
+			Entry("Node with an unsupported providerID", testCaseAdoptedNodeProviderID{
+				Node: &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: baremetalhostName},
+					Spec:       corev1.NodeSpec{ProviderID: "aws://abc"},
+				},
+				ExpectError: true,
+			}),
+		)
+
+		DescribeTable("Test deleting an adopted host",
+			func(deprovision bool) {
+				host := adoptedHost(baremetalhostName, bmov1alpha1.StateExternallyProvisioned)
+				host.Labels = map[string]string{clusterv1.ClusterNameLabel: clusterName}
+				host.Spec.ConsumerRef = &corev1.ObjectReference{
+					Name:       metal3machineName,
+					Namespace:  namespaceName,
+					Kind:       "M3Machine",
+					APIVersion: infrav1.GroupVersion.String(),
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+				annotations := map[string]string{HostAnnotation: namespaceName + "/" + baremetalhostName}
+				if deprovision {
+					annotations[infrav1.DeprovisionAdoptedHostAnnotation] = ""
+				}
+				m3m := adoptingMachine(annotations)
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil, machine(), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				err = machineMgr.Delete(context.TODO())
+				savedHost := &bmov1alpha1.BareMetalHost{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+				if deprovision {
+					// The host is powered off before it is released.
+					Expect(err).To(HaveOccurred())
+					Expect(savedHost.Spec.Online).To(BeFalse())
+					Expect(savedHost.Spec.ConsumerRef).NotTo(BeNil())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+				Expect(savedHost.Spec.Online).To(BeTrue())
+				Expect(savedHost.Labels).NotTo(HaveKey(clusterv1.ClusterNameLabel))
+				Expect(savedHost.Annotations).NotTo(HaveKey(infrav1.HostLastReleasedAnnotation))
+			},
+			Entry("Released without deprovisioning", false),
+			Entry("Deprovisioned when requested", true),
+		)
+	})
+
+	Describe("Test provisioning slots", func() {
+		BeforeEach(func() {
+			DeferCleanup(func() { provisioningSlots.hosts = map[types.UID]time.Time{} })
+		})
+
+		pendingHost := func(index int) *bmov1alpha1.BareMetalHost {
+			return &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("host-%d", index),
+					Namespace: namespaceName,
+					UID:       types.UID(fmt.Sprintf("host-uid-%d", index)),
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       fmt.Sprintf("m3m-%d", index),
+						Namespace:  namespaceName,
+						Kind:       "M3Machine",
+						APIVersion: infrav1.GroupVersion.String(),
+					},
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateAvailable,
+					},
+				},
+			}
+		}
+		pendingMachine := func(index int) *infrav1.Metal3Machine {
+			return newMetal3Machine(fmt.Sprintf("m3m-%d", index), &infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{
+					URL:      testImageURL,
+					Checksum: testImageChecksumURL,
+				},
+				Bootstrapless: true,
+			}, nil, &metav1.ObjectMeta{
+				Name:      fmt.Sprintf("m3m-%d", index),
+				Namespace: namespaceName,
+				Annotations: map[string]string{
+					HostAnnotation: fmt.Sprintf("%s/host-%d", namespaceName, index),
+				},
+			})
+		}
+		// updateWave updates all the machines once and returns the names of
+		// the hosts whose image was set.
+		updateWave := func(fakeClient client.Client, machines []*infrav1.Metal3Machine) []string {
+			provisioned := []string{}
+			for _, m3m := range machines {
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+				host, _, err := machineMgr.getHost(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				if host.Spec.Image != nil {
+					continue
+				}
+				err = machineMgr.Update(context.TODO())
+				if err != nil {
+					Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+					Expect(conditions.GetReason(m3m, infrav1.BareMetalHostProvisionedCondition)).To(Equal(infrav1.WaitingForProvisioningSlotReason))
+					continue
+				}
+				provisioned = append(provisioned, host.Name)
+			}
+			return provisioned
+		}
+
+		It("Sets the image of at most MaxConcurrentProvisioning hosts per wave", func() {
+			DeferCleanup(func(limit int) { MaxConcurrentProvisioning = limit }, MaxConcurrentProvisioning)
+			MaxConcurrentProvisioning = 3
+
+			objects := []client.Object{}
+			machines := []*infrav1.Metal3Machine{}
+			for i := 0; i < 10; i++ {
+				objects = append(objects, pendingHost(i))
+				machines = append(machines, pendingMachine(i))
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).
+				WithStatusSubresource(&bmov1alpha1.BareMetalHost{}).Build()
+
+			provisionedHosts := 0
+			for _, expected := range []int{3, 3, 3, 1} {
+				provisioned := updateWave(fakeClient, machines)
+				Expect(provisioned).To(HaveLen(expected))
+				provisionedHosts += len(provisioned)
+
+				// Another wave can not start until the hosts are provisioned.
+				Expect(updateWave(fakeClient, machines)).To(BeEmpty())
+
+				for _, name := range provisioned {
+					host := &bmov1alpha1.BareMetalHost{}
+					Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: namespaceName}, host)).To(Succeed())
+					host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+					Expect(fakeClient.Status().Update(context.TODO(), host)).To(Succeed())
+				}
+			}
+			Expect(provisionedHosts).To(Equal(10))
+		})
+
+		It("Does not limit the provisioning when MaxConcurrentProvisioning is 0", func() {
+			objects := []client.Object{}
+			machines := []*infrav1.Metal3Machine{}
+			for i := 0; i < 10; i++ {
+				objects = append(objects, pendingHost(i))
+				machines = append(machines, pendingMachine(i))
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			Expect(updateWave(fakeClient, machines)).To(HaveLen(10))
+		})
+
+		It("Counts the hosts given a slot until the cache shows their image", func() {
+			DeferCleanup(func(limit int) { MaxConcurrentProvisioning = limit }, MaxConcurrentProvisioning)
+			MaxConcurrentProvisioning = 3
+
+			objects := []client.Object{}
+			staleObjects := []client.Object{}
+			machines := []*infrav1.Metal3Machine{}
+			for i := 0; i < 10; i++ {
+				objects = append(objects, pendingHost(i))
+				staleObjects = append(staleObjects, pendingHost(i))
+				machines = append(machines, pendingMachine(i))
+			}
+			// The hosts are listed from a cache that does not show the
+			// updates of the hosts.
+			staleClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(staleObjects...).Build()
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+						if _, ok := list.(*bmov1alpha1.BareMetalHostList); ok {
+							return staleClient.List(ctx, list, opts...)
+						}
+						return c.List(ctx, list, opts...)
+					},
+				}).Build()
+
+			// The machines reconciled at the same time share the slots.
+			done := make(chan string, len(machines))
+			for _, m3m := range machines {
+				m3m := m3m
+				go func() {
+					defer GinkgoRecover()
+					machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3m, logr.Discard())
+					Expect(err).NotTo(HaveOccurred())
+					err = machineMgr.Update(context.TODO())
+					if err != nil {
+						Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+						done <- ""
+						return
+					}
+					done <- m3m.Name
+				}()
+			}
+			provisioned := []string{}
+			for range machines {
+				if name := <-done; name != "" {
+					provisioned = append(provisioned, name)
+				}
+			}
+			Expect(provisioned).To(HaveLen(3))
+
+			// The stale cache does not free the slots.
+			Expect(updateWave(fakeClient, machines)).To(BeEmpty())
+
+			// The slots are counted from the cache once it shows the image of
+			// the hosts, and freed once they are provisioned.
+			for _, name := range provisioned {
+				host := &bmov1alpha1.BareMetalHost{}
+				key := client.ObjectKey{Name: strings.Replace(name, "m3m", "host", 1), Namespace: namespaceName}
+				Expect(fakeClient.Get(context.TODO(), key, host)).To(Succeed())
+				Expect(host.Spec.Image).NotTo(BeNil())
+				staleHost := &bmov1alpha1.BareMetalHost{}
+				Expect(staleClient.Get(context.TODO(), key, staleHost)).To(Succeed())
+				staleHost.Spec = host.Spec
+				Expect(staleClient.Update(context.TODO(), staleHost)).To(Succeed())
+			}
+			Expect(updateWave(fakeClient, machines)).To(BeEmpty())
+			for _, name := range provisioned {
+				host := &bmov1alpha1.BareMetalHost{}
+				key := client.ObjectKey{Name: strings.Replace(name, "m3m", "host", 1), Namespace: namespaceName}
+				Expect(staleClient.Get(context.TODO(), key, host)).To(Succeed())
+				host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+				Expect(staleClient.Update(context.TODO(), host)).To(Succeed())
+			}
+			Expect(updateWave(fakeClient, machines)).To(HaveLen(3))
+		})
+	})
+
+	Describe("Test firmware settings", func() {
+		host := func() *bmov1alpha1.BareMetalHost {
+			return &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					UID:       Bmhuid,
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       metal3machineName,
+						Namespace:  namespaceName,
+						Kind:       "M3Machine",
+						APIVersion: infrav1.GroupVersion.String(),
+					},
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateAvailable,
 					},
-				}),
-			Cluster:             newCluster(clusterName),
-			Secret:              newSecret(),
-			ExpectSecretDeleted: false,
-			Metal3MachineTemplate: &infrav1.Metal3MachineTemplate{
-				TypeMeta: metav1.TypeMeta{
-					APIVersion: infrav1.GroupVersion.String(),
-					Kind:       "Metal3MachineTemplate",
 				},
+			}
+		}
+		hostFirmwareSettings := func(settings bmov1alpha1.DesiredSettingsMap, annotations map[string]string,
+			valid *metav1.Condition) *bmov1alpha1.HostFirmwareSettings {
+			hfs := &bmov1alpha1.HostFirmwareSettings{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      "abc",
-					Namespace: namespaceName,
+					Name:        baremetalhostName,
+					Namespace:   namespaceName,
+					Annotations: annotations,
 				},
-				Spec: infrav1.Metal3MachineTemplateSpec{
-					Template: infrav1.Metal3MachineTemplateResource{
-						Spec: infrav1.Metal3MachineSpec{
-							AutomatedCleaningMode: pointer.String(infrav1.CleaningModeDisabled),
-						},
-					},
-					NodeReuse: true,
-				}},
-		}),
-	)
+				Spec: bmov1alpha1.HostFirmwareSettingsSpec{
+					Settings: settings,
+				},
+			}
+			if valid != nil {
+				hfs.Status.Conditions = []metav1.Condition{*valid}
+			}
+			return hfs
+		}
+		m3mWithSettings := func(settings map[string]string) *infrav1.Metal3Machine {
+			return newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{
+					URL:      testImageURL,
+					Checksum: testImageChecksumURL,
+				},
+				Bootstrapless:    true,
+				FirmwareSettings: settings,
+			}, nil, &metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+				Annotations: map[string]string{
+					HostAnnotation: namespaceName + "/" + baremetalhostName,
+				},
+			})
+		}
+
+		type testCaseFirmwareSettings struct {
+			HFS                 *bmov1alpha1.HostFirmwareSettings
+			ExpectedReason      string
+			ExpectInvalid       bool
+			ExpectedSettings    bmov1alpha1.DesiredSettingsMap
+			ExpectedAnnotation  string
+			ExpectImageSet      bool
+			ExpectedMessagePart string
+		}
+
+		DescribeTable("Test setting the firmware settings before provisioning",
+			func(tc testCaseFirmwareSettings) {
+				objects := []client.Object{host()}
+				if tc.HFS != nil {
+					objects = append(objects, tc.HFS)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				m3m := m3mWithSettings(map[string]string{"SriovEnable": "Enabled", "NumaNodesPerSocket": "2"})
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				err = machineMgr.Update(context.TODO())
+				savedHost := &bmov1alpha1.BareMetalHost{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host()), savedHost)).To(Succeed())
+				if tc.ExpectImageSet {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(savedHost.Spec.Image).NotTo(BeNil())
+					Expect(conditions.IsTrue(m3m, infrav1.FirmwareSettingsValidCondition)).To(BeTrue())
+					return
+				}
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				Expect(savedHost.Spec.Image).To(BeNil())
+				Expect(conditions.GetReason(m3m, infrav1.BareMetalHostProvisionedCondition)).To(Equal(tc.ExpectedReason))
+				Expect(conditions.IsFalse(m3m, infrav1.FirmwareSettingsValidCondition)).To(Equal(tc.ExpectInvalid))
+				if tc.ExpectedMessagePart != "" {
+					Expect(conditions.GetMessage(m3m, infrav1.FirmwareSettingsValidCondition)).To(ContainSubstring(tc.ExpectedMessagePart))
+				}
+
+				savedHFS := &bmov1alpha1.HostFirmwareSettings{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host()), savedHFS)).To(Succeed())
+				Expect(savedHFS.Spec.Settings).To(Equal(tc.ExpectedSettings))
+				Expect(savedHFS.Annotations[infrav1.FirmwareSettingsAnnotation]).To(MatchJSON(tc.ExpectedAnnotation))
+			},
+			Entry("Creates the HostFirmwareSettings", testCaseFirmwareSettings{
+				ExpectedReason: infrav1.WaitingForFirmwareSettingsReason,
+				ExpectedSettings: bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				},
+				ExpectedAnnotation: `{"SriovEnable": null, "NumaNodesPerSocket": null}`,
+			}),
+			Entry("Patches the HostFirmwareSettings and records the previous values", testCaseFirmwareSettings{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable": intstr.FromString("Disabled"),
+					"BootMode":    intstr.FromString("UEFI"),
+				}, nil, nil),
+				ExpectedReason: infrav1.WaitingForFirmwareSettingsReason,
+				ExpectedSettings: bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+					"BootMode":           intstr.FromString("UEFI"),
+				},
+				ExpectedAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+			}),
+			Entry("Waits for the settings to be validated", testCaseFirmwareSettings{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				}, map[string]string{
+					infrav1.FirmwareSettingsAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+				}, nil),
+				ExpectedReason: infrav1.WaitingForFirmwareSettingsReason,
+				ExpectedSettings: bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				},
+				ExpectedAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+			}),
+			Entry("Provisions the host once the settings are valid", testCaseFirmwareSettings{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				}, map[string]string{
+					infrav1.FirmwareSettingsAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+				}, &metav1.Condition{
+					Type:   string(bmov1alpha1.FirmwareSettingsValid),
+					Status: metav1.ConditionTrue,
+					Reason: "Success",
+				}),
+				ExpectImageSet: true,
+			}),
+			Entry("Reports the invalid settings", testCaseFirmwareSettings{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				}, map[string]string{
+					infrav1.FirmwareSettingsAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+				}, &metav1.Condition{
+					Type:    string(bmov1alpha1.FirmwareSettingsValid),
+					Status:  metav1.ConditionFalse,
+					Reason:  "ConfigurationError",
+					Message: "Invalid BIOS setting: Setting NumaNodesPerSocket is invalid, 2 is greater than the upper limit 1",
+				}),
+				ExpectedReason: infrav1.InvalidFirmwareSettingsReason,
+				ExpectInvalid:  true,
+				ExpectedSettings: bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable":        intstr.FromString("Enabled"),
+					"NumaNodesPerSocket": intstr.FromInt(2),
+				},
+				ExpectedAnnotation:  `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+				ExpectedMessagePart: "2 is greater than the upper limit 1",
+			}),
+		)
+
+		type testCaseFirmwareSettingsApplied struct {
+			HFS           *bmov1alpha1.HostFirmwareSettings
+			ExpectBlocked bool
+		}
+
+		DescribeTable("Test waiting for the firmware settings to be applied",
+			func(tc testCaseFirmwareSettingsApplied) {
+				provisionedHost := host()
+				provisionedHost.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+				objects := []client.Object{provisionedHost}
+				if tc.HFS != nil {
+					objects = append(objects, tc.HFS)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				m3m := m3mWithSettings(map[string]string{"SriovEnable": "Enabled"})
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				bmhID, err := machineMgr.GetBaremetalHostID(context.TODO())
+				if tc.ExpectBlocked {
+					Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+					Expect(bmhID).To(BeNil())
+					Expect(conditions.IsFalse(m3m, infrav1.BareMetalHostProvisionedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(m3m, infrav1.BareMetalHostProvisionedCondition)).To(Equal(infrav1.WaitingForFirmwareSettingsAppliedReason))
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bmhID).NotTo(BeNil())
+				Expect(conditions.IsTrue(m3m, infrav1.BareMetalHostProvisionedCondition)).To(BeTrue())
+			},
+			Entry("Waits while a change is detected", testCaseFirmwareSettingsApplied{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable": intstr.FromString("Enabled"),
+				}, nil, &metav1.Condition{
+					Type:   string(bmov1alpha1.FirmwareSettingsChangeDetected),
+					Status: metav1.ConditionTrue,
+					Reason: "Success",
+				}),
+				ExpectBlocked: true,
+			}),
+			Entry("Waits until the change is observed", testCaseFirmwareSettingsApplied{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable": intstr.FromString("Enabled"),
+				}, nil, nil),
+				ExpectBlocked: true,
+			}),
+			Entry("Marks the host provisioned once the settings are applied", testCaseFirmwareSettingsApplied{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable": intstr.FromString("Enabled"),
+				}, nil, &metav1.Condition{
+					Type:   string(bmov1alpha1.FirmwareSettingsChangeDetected),
+					Status: metav1.ConditionFalse,
+					Reason: "Success",
+				}),
+			}),
+			Entry("Does not wait for settings not set from the Metal3Machine", testCaseFirmwareSettingsApplied{
+				HFS: hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+					"SriovEnable": intstr.FromString("Disabled"),
+				}, nil, &metav1.Condition{
+					Type:   string(bmov1alpha1.FirmwareSettingsChangeDetected),
+					Status: metav1.ConditionTrue,
+					Reason: "Success",
+				}),
+			}),
+			Entry("Does not wait without HostFirmwareSettings", testCaseFirmwareSettingsApplied{}),
+		)
+
+		It("Restores the previous firmware settings", func() {
+			hfs := hostFirmwareSettings(bmov1alpha1.DesiredSettingsMap{
+				"SriovEnable":        intstr.FromString("Enabled"),
+				"NumaNodesPerSocket": intstr.FromInt(2),
+				"BootMode":           intstr.FromString("UEFI"),
+			}, map[string]string{
+				infrav1.FirmwareSettingsAnnotation: `{"SriovEnable": "Disabled", "NumaNodesPerSocket": null}`,
+			}, nil)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host(), hfs).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3mWithSettings(nil), logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.restoreFirmwareSettings(context.TODO(), host())).To(Succeed())
+			savedHFS := &bmov1alpha1.HostFirmwareSettings{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(hfs), savedHFS)).To(Succeed())
+			Expect(savedHFS.Spec.Settings).To(Equal(bmov1alpha1.DesiredSettingsMap{
+				"SriovEnable": intstr.FromString("Disabled"),
+				"BootMode":    intstr.FromString("UEFI"),
+			}))
+			Expect(savedHFS.Annotations).NotTo(HaveKey(infrav1.FirmwareSettingsAnnotation))
+		})
+	})
 
 	Describe("Test UpdateMachineStatus", func() {
 		nic1 := bmov1alpha1.NIC{
@@ -2205,6 +5152,99 @@ var _ = Describe("Metal3Machine manager", func() {
 			IP: "172.0.20.2",
 		}
 
+		It("Mirrors the host in the Metal3Machine status", func() {
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					HardwareProfile: "unknown",
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateProvisioning,
+					},
+					HardwareDetails: &bmov1alpha1.HardwareDetails{
+						CPU:          bmov1alpha1.CPU{Count: 32},
+						RAMMebibytes: 65536,
+						Storage:      []bmov1alpha1.Storage{{Name: "/dev/sda"}, {Name: "/dev/sdb"}},
+						NIC: []bmov1alpha1.NIC{
+							{Name: "eth0", MAC: "00:00:00:00:00:01"},
+							{Name: "eth1", MAC: "00:00:00:00:00:02"},
+						},
+					},
+				},
+			}
+			m3m := newMetal3Machine(metal3machineName, m3mSpec(), nil, m3mObjectMetaWithValidAnnotations())
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.updateMachineStatus(context.TODO(), host)).To(Succeed())
+			Expect(m3m.Status.RenderedHost).To(Equal(&infrav1.RenderedHost{
+				Name:              baremetalhostName,
+				Namespace:         namespaceName,
+				HardwareProfile:   "unknown",
+				CPUCount:          32,
+				RAMMebibytes:      65536,
+				StorageDevices:    2,
+				MACAddresses:      []string{"00:00:00:00:00:01", "00:00:00:00:00:02"},
+				ProvisioningState: string(bmov1alpha1.StateProvisioning),
+			}))
+			lastUpdated := m3m.Status.LastUpdated
+			Expect(lastUpdated).NotTo(BeNil())
+			Expect(m3m.Status.FailureDomain).To(BeEmpty())
+
+			// The mirror follows the status of the host.
+			host.Labels = map[string]string{infrav1.DefaultFailureDomainLabel: "rack-a"}
+			host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+			host.Status.HardwareDetails.Storage = host.Status.HardwareDetails.Storage[:1]
+			Expect(machineMgr.updateMachineStatus(context.TODO(), host)).To(Succeed())
+			Expect(m3m.Status.RenderedHost.ProvisioningState).To(Equal(string(bmov1alpha1.StateProvisioned)))
+			Expect(m3m.Status.RenderedHost.StorageDevices).To(Equal(1))
+			Expect(m3m.Status.FailureDomain).To(Equal("rack-a"))
+
+			// It is cleared on disassociation.
+			machineMgr.removeAnnotation()
+			Expect(m3m.Status.RenderedHost).To(BeNil())
+		})
+
+		It("Clears the mirror of the host when the Metal3Machine is deleted", func() {
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						Name:       metal3machineName,
+						Namespace:  namespaceName,
+						Kind:       "M3Machine",
+						APIVersion: infrav1.GroupVersion.String(),
+					},
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateAvailable,
+					},
+				},
+			}
+			m3m := newMetal3Machine(metal3machineName, m3mSpec(), &infrav1.Metal3MachineStatus{
+				RenderedHost: &infrav1.RenderedHost{Name: baremetalhostName, Namespace: namespaceName},
+			}, m3mObjectMetaWithValidAnnotations())
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, newMachine(machineName, nil), m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.Delete(context.TODO())).To(Succeed())
+			Expect(m3m.Status.RenderedHost).To(BeNil())
+
+			// The mirror is never written back to the host.
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+			Expect(savedHost.Status).To(Equal(host.Status))
+		})
+
 		type testCaseUpdateMachineStatus struct {
 			Host            *bmov1alpha1.BareMetalHost
 			Machine         *clusterv1.Machine
@@ -2494,6 +5534,151 @@ var _ = Describe("Metal3Machine manager", func() {
 		)
 	})
 
+	Describe("Test SyncNodeAddresses", func() {
+		hostInternalIP := clusterv1.MachineAddress{Type: clusterv1.MachineInternalIP, Address: "192.168.1.1"}
+		hostHostName := clusterv1.MachineAddress{Type: clusterv1.MachineHostName, Address: "node-0"}
+		hostInternalDNS := clusterv1.MachineAddress{Type: clusterv1.MachineInternalDNS, Address: "node-0"}
+		nodeExternalIP := clusterv1.MachineAddress{Type: clusterv1.MachineExternalIP, Address: "203.0.113.10"}
+
+		newHost := func(ip string) *bmov1alpha1.BareMetalHost {
+			return &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					HardwareDetails: &bmov1alpha1.HardwareDetails{
+						Hostname: "node-0",
+						NIC:      []bmov1alpha1.NIC{{Name: "eth0", IP: ip}},
+					},
+				},
+			}
+		}
+		newNode := func(addrs ...corev1.NodeAddress) *corev1.Node {
+			return &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+				Status:     corev1.NodeStatus{Addresses: addrs},
+			}
+		}
+
+		type testCaseSyncNodeAddresses struct {
+			Host              *bmov1alpha1.BareMetalHost
+			Nodes             []runtime.Object
+			NoNodeRef         bool
+			ClientError       bool
+			Addresses         clusterv1.MachineAddresses
+			ExpectedError     bool
+			ExpectedAddresses clusterv1.MachineAddresses
+			ExpectedUpdate    bool
+		}
+
+		DescribeTable("Test SyncNodeAddresses",
+			func(tc testCaseSyncNodeAddresses) {
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(tc.Host).Build()
+				corev1Client := clientfake.NewSimpleClientset(tc.Nodes...).CoreV1()
+				clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+					if tc.ClientError {
+						return nil, errors.New("connection refused")
+					}
+					return corev1Client, nil
+				}
+				machine := newMachine(machineName, nil)
+				if !tc.NoNodeRef {
+					machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+				}
+				m3m := newMetal3Machine(metal3machineName, m3mSpec(), &infrav1.Metal3MachineStatus{
+					Addresses: tc.Addresses,
+				}, m3mObjectMetaWithValidAnnotations())
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil, machine, m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				err = machineMgr.SyncNodeAddresses(context.TODO(), clientGetter)
+				if tc.ExpectedError {
+					Expect(err).To(HaveOccurred())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(m3m.Status.Addresses).To(Equal(tc.ExpectedAddresses))
+				Expect(m3m.Status.LastUpdated != nil).To(Equal(tc.ExpectedUpdate))
+			},
+			Entry("No Node yet, nothing to do", testCaseSyncNodeAddresses{
+				Host:              newHost("192.168.1.1"),
+				NoNodeRef:         true,
+				Addresses:         clusterv1.MachineAddresses{hostInternalIP},
+				ExpectedAddresses: clusterv1.MachineAddresses{hostInternalIP},
+			}),
+			Entry("Node addresses merged with the host addresses", testCaseSyncNodeAddresses{
+				Host: newHost("192.168.1.1"),
+				Nodes: []runtime.Object{newNode(
+					corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "192.168.1.1"},
+					corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+					corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node-0"},
+				)},
+				Addresses:         clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS},
+				ExpectedAddresses: clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS, nodeExternalIP},
+				ExpectedUpdate:    true,
+			}),
+			Entry("Node address changed", testCaseSyncNodeAddresses{
+				Host: newHost("192.168.1.1"),
+				Nodes: []runtime.Object{newNode(
+					corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.11"},
+				)},
+				Addresses: clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS, nodeExternalIP},
+				ExpectedAddresses: clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS,
+					{Type: clusterv1.MachineExternalIP, Address: "203.0.113.11"},
+				},
+				ExpectedUpdate: true,
+			}),
+			Entry("Same addresses in another order, not updated", testCaseSyncNodeAddresses{
+				Host: newHost("192.168.1.1"),
+				Nodes: []runtime.Object{newNode(
+					corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+				)},
+				Addresses:         clusterv1.MachineAddresses{nodeExternalIP, hostInternalDNS, hostHostName, hostInternalIP},
+				ExpectedAddresses: clusterv1.MachineAddresses{nodeExternalIP, hostInternalDNS, hostHostName, hostInternalIP},
+			}),
+			Entry("Host NIC re-inspected", testCaseSyncNodeAddresses{
+				Host: newHost("192.168.1.2"),
+				Nodes: []runtime.Object{newNode(
+					corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+				)},
+				Addresses: clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS, nodeExternalIP},
+				ExpectedAddresses: clusterv1.MachineAddresses{
+					{Type: clusterv1.MachineInternalIP, Address: "192.168.1.2"},
+					hostHostName, hostInternalDNS, nodeExternalIP,
+				},
+				ExpectedUpdate: true,
+			}),
+			Entry("Node not found, host addresses only", testCaseSyncNodeAddresses{
+				Host:              newHost("192.168.1.1"),
+				Addresses:         clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS, nodeExternalIP},
+				ExpectedAddresses: clusterv1.MachineAddresses{hostInternalIP, hostHostName, hostInternalDNS},
+				ExpectedUpdate:    true,
+			}),
+			Entry("Workload cluster unreachable, addresses kept", testCaseSyncNodeAddresses{
+				Host:              newHost("192.168.1.2"),
+				ClientError:       true,
+				Addresses:         clusterv1.MachineAddresses{hostInternalIP, nodeExternalIP},
+				ExpectedError:     true,
+				ExpectedAddresses: clusterv1.MachineAddresses{hostInternalIP, nodeExternalIP},
+			}),
+		)
+
+		It("Leaves the addresses to SyncNodeAddresses once the Machine has a Node", func() {
+			m3m := newMetal3Machine(metal3machineName, m3mSpec(), &infrav1.Metal3MachineStatus{
+				Addresses: clusterv1.MachineAddresses{hostInternalIP, nodeExternalIP},
+			}, m3mObjectMetaWithValidAnnotations())
+			machine := newMachine(machineName, nil)
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.updateMachineStatus(context.TODO(), newHost("192.168.1.2"))).To(Succeed())
+			Expect(m3m.Status.Addresses).To(Equal(clusterv1.MachineAddresses{hostInternalIP, nodeExternalIP}))
+		})
+	})
+
 	type testCaseGetProviderIDAndBMHID struct {
 		providerID    *string
 		expectedBMHID string
@@ -2528,6 +5713,131 @@ var _ = Describe("Metal3Machine manager", func() {
 		}),
 	)
 
+	Describe("Test SyncNodeMetadata", func() {
+		type testCaseSyncNodeMetadata struct {
+			NodeMetadata        *infrav1.NodeMetadata
+			NodeLabels          map[string]string
+			NodeAnnotations     map[string]string
+			ExpectedLabels      map[string]string
+			ExpectedAnnotations map[string]string
+			ExpectConflict      bool
+		}
+
+		DescribeTable("Test SyncNodeMetadata",
+			func(tc testCaseSyncNodeMetadata) {
+				node := &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "node-0",
+						Labels:      tc.NodeLabels,
+						Annotations: tc.NodeAnnotations,
+					},
+				}
+				corev1Client := clientfake.NewSimpleClientset(node).CoreV1()
+				clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+					return corev1Client, nil
+				}
+				machine := newMachine(machineName, nil)
+				machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+				spec := m3mSpec()
+				spec.NodeMetadata = tc.NodeMetadata
+				m3m := newMetal3Machine(metal3machineName, spec, nil, m3mObjectMetaWithValidAnnotations())
+				machineMgr, err := NewMachineManager(nil, newCluster(clusterName), nil, machine, m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+				recorder := record.NewFakeRecorder(10)
+				machineMgr.Recorder = recorder
+
+				Expect(machineMgr.SyncNodeMetadata(context.TODO(), clientGetter)).To(Succeed())
+
+				savedNode, err := corev1Client.Nodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				if len(tc.ExpectedLabels) == 0 {
+					Expect(savedNode.Labels).To(BeEmpty())
+				} else {
+					Expect(savedNode.Labels).To(Equal(tc.ExpectedLabels))
+				}
+				if len(tc.ExpectedAnnotations) == 0 {
+					Expect(savedNode.Annotations).To(BeEmpty())
+				} else {
+					Expect(savedNode.Annotations).To(Equal(tc.ExpectedAnnotations))
+				}
+				if tc.ExpectConflict {
+					Expect(recorder.Events).To(Receive(ContainSubstring(infrav1.NodeMetadataConflictReason)))
+				} else {
+					Expect(recorder.Events).NotTo(Receive())
+				}
+			},
+			Entry("Labels and annotations added", testCaseSyncNodeMetadata{
+				NodeMetadata: &infrav1.NodeMetadata{
+					Labels:      map[string]string{"example.com/rack": "r1", "example.com/chassis": "c1"},
+					Annotations: map[string]string{"example.com/bmc-vendor": "Dell Inc."},
+				},
+				NodeLabels: map[string]string{"kubernetes.io/hostname": "node-0"},
+				ExpectedLabels: map[string]string{
+					"kubernetes.io/hostname": "node-0",
+					"example.com/rack":       "r1",
+					"example.com/chassis":    "c1",
+				},
+				ExpectedAnnotations: map[string]string{
+					"example.com/bmc-vendor":                           "Dell Inc.",
+					infrav1.NodeLabelsFromMetal3MachineAnnotation:      "example.com/chassis,example.com/rack",
+					infrav1.NodeAnnotationsFromMetal3MachineAnnotation: "example.com/bmc-vendor",
+				},
+			}),
+			Entry("Managed label updated and removed", testCaseSyncNodeMetadata{
+				NodeMetadata: &infrav1.NodeMetadata{
+					Labels: map[string]string{"example.com/rack": "r2"},
+				},
+				NodeLabels: map[string]string{
+					"example.com/rack":    "r1",
+					"example.com/chassis": "c1",
+					"example.com/other":   "o1",
+				},
+				NodeAnnotations: map[string]string{
+					"example.com/bmc-vendor":                           "Dell Inc.",
+					infrav1.NodeLabelsFromMetal3MachineAnnotation:      "example.com/chassis,example.com/rack",
+					infrav1.NodeAnnotationsFromMetal3MachineAnnotation: "example.com/bmc-vendor",
+				},
+				ExpectedLabels: map[string]string{
+					"example.com/rack":  "r2",
+					"example.com/other": "o1",
+				},
+				ExpectedAnnotations: map[string]string{
+					infrav1.NodeLabelsFromMetal3MachineAnnotation: "example.com/rack",
+				},
+			}),
+			Entry("All removed", testCaseSyncNodeMetadata{
+				NodeLabels: map[string]string{"example.com/rack": "r1"},
+				NodeAnnotations: map[string]string{
+					infrav1.NodeLabelsFromMetal3MachineAnnotation: "example.com/rack",
+				},
+			}),
+			Entry("Labels owned by others skipped", testCaseSyncNodeMetadata{
+				NodeMetadata: &infrav1.NodeMetadata{
+					Labels: map[string]string{
+						"example.com/rack":                "r1",
+						"node-role.kubernetes.io/storage": "",
+					},
+				},
+				NodeLabels:     map[string]string{"example.com/rack": "r0"},
+				ExpectedLabels: map[string]string{"example.com/rack": "r0"},
+				ExpectConflict: true,
+			}),
+		)
+
+		It("Does nothing before the Node appears", func() {
+			machine := newMachine(machineName, nil)
+			spec := m3mSpec()
+			spec.NodeMetadata = &infrav1.NodeMetadata{Labels: map[string]string{"example.com/rack": "r1"}}
+			m3m := newMetal3Machine(metal3machineName, spec, nil, m3mObjectMetaWithValidAnnotations())
+			machineMgr, err := NewMachineManager(nil, newCluster(clusterName), nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+				return nil, errors.New("not called")
+			}
+			Expect(machineMgr.SyncNodeMetadata(context.TODO(), clientGetter)).To(Succeed())
+		})
+	})
+
 	Describe("Test SetNodeProviderID", func() {
 		s := runtime.NewScheme()
 		err := clusterv1.AddToScheme(s)
@@ -2543,6 +5853,9 @@ var _ = Describe("Metal3Machine manager", func() {
 			TargetObjects        []runtime.Object
 			M3MHasHostAnnotation bool
 			HostID               string
+			ProviderIDFormat     ProviderIDFormat
+			ProviderIDOnM3M      string
+			PowerState           infrav1.PowerState
 			ExpectedError        bool
 			ExpectedProviderID   string
 		}
@@ -2578,14 +5891,17 @@ var _ = Describe("Metal3Machine manager", func() {
 									HostAnnotation: namespaceName + "/" + baremetalhostName,
 								},
 							},
+							Spec: infrav1.Metal3MachineSpec{PowerState: tc.PowerState},
 						}, logr.Discard(),
 					)
 				}
 
 				Expect(err).NotTo(HaveOccurred())
+				machineMgr.ProviderIDFormat = tc.ProviderIDFormat
 
+				providerID := tc.ProviderIDOnM3M
 				err = machineMgr.SetNodeProviderID(context.TODO(),
-					&tc.ExpectedProviderID, m,
+					&providerID, m,
 				)
 
 				if tc.ExpectedError {
@@ -2593,6 +5909,11 @@ var _ = Describe("Metal3Machine manager", func() {
 					return
 				}
 				Expect(err).NotTo(HaveOccurred())
+				// A powered off Metal3Machine runs no Node.
+				if tc.PowerState == infrav1.PowerStateOff {
+					Expect(providerID).To(Equal(tc.ExpectedProviderID))
+					return
+				}
 
 				ctx := context.Background()
 				// get the node
@@ -2605,17 +5926,96 @@ var _ = Describe("Metal3Machine manager", func() {
 					break
 				}
 				Expect(node.Spec.ProviderID).To(Equal(tc.ExpectedProviderID))
+				Expect(providerID).To(Equal(tc.ExpectedProviderID))
 			},
 			Entry("Set target ProviderID, No matching node", testCaseSetNodePoviderID{
 				TargetObjects: []runtime.Object{
-					&corev1.Node{},
+					&corev1.Node{},
+				},
+				HostID:               string(Bmhuid),
+				ExpectedError:        true,
+				ExpectedProviderID:   ProviderID,
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Set target ProviderID, matching node", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								ProviderLabelPrefix: string(Bmhuid),
+							},
+						},
+					},
+				},
+				HostID:               string(Bmhuid),
+				ExpectedError:        false,
+				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Powered off, no node, providerID set on the Metal3Machine", testCaseSetNodePoviderID{
+				HostID:               string(Bmhuid),
+				PowerState:           infrav1.PowerStateOff,
+				ExpectedError:        false,
+				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Powered off, legacy providerID kept", testCaseSetNodePoviderID{
+				HostID:               string(Bmhuid),
+				PowerState:           infrav1.PowerStateOff,
+				ProviderIDOnM3M:      ProviderIDPrefix + string(Bmhuid),
+				ExpectedError:        false,
+				ExpectedProviderID:   ProviderIDPrefix + string(Bmhuid),
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Fails to set target ProviderID, if no matching node is found", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								ProviderLabelPrefix: string(Bmhuid),
+							},
+						},
+					},
+				},
+				HostID:               "wrong-bmhuid",
+				ExpectedError:        true,
+				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Set target ProviderID, providerID set", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								ProviderLabelPrefix: string(Bmhuid),
+							},
+						},
+						Spec: corev1.NodeSpec{
+							ProviderID: ProviderID,
+						},
+					},
+				},
+				HostID:               string(Bmhuid),
+				ExpectedError:        false,
+				ExpectedProviderID:   ProviderID,
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Set target ProviderID in uid format, matching node", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								ProviderLabelPrefix: string(Bmhuid),
+							},
+						},
+					},
 				},
 				HostID:               string(Bmhuid),
-				ExpectedError:        true,
+				ProviderIDFormat:     ProviderIDFormatUID,
 				ExpectedProviderID:   ProviderID,
 				M3MHasHostAnnotation: true,
 			}),
-			Entry("Set target ProviderID, matching node", testCaseSetNodePoviderID{
+			Entry("Set target ProviderID in namespacedName format, matching node", testCaseSetNodePoviderID{
 				TargetObjects: []runtime.Object{
 					&corev1.Node{
 						ObjectMeta: metav1.ObjectMeta{
@@ -2626,11 +6026,11 @@ var _ = Describe("Metal3Machine manager", func() {
 					},
 				},
 				HostID:               string(Bmhuid),
-				ExpectedError:        false,
+				ProviderIDFormat:     ProviderIDFormatNamespacedName,
 				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
 				M3MHasHostAnnotation: true,
 			}),
-			Entry("Fails to set target ProviderID, if no matching node is found", testCaseSetNodePoviderID{
+			Entry("Keep the namespacedName ProviderID of the Metal3Machine in uid format", testCaseSetNodePoviderID{
 				TargetObjects: []runtime.Object{
 					&corev1.Node{
 						ObjectMeta: metav1.ObjectMeta{
@@ -2640,12 +6040,13 @@ var _ = Describe("Metal3Machine manager", func() {
 						},
 					},
 				},
-				HostID:               "wrong-bmhuid",
-				ExpectedError:        true,
+				HostID:               string(Bmhuid),
+				ProviderIDFormat:     ProviderIDFormatUID,
+				ProviderIDOnM3M:      fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
 				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
 				M3MHasHostAnnotation: true,
 			}),
-			Entry("Set target ProviderID, providerID set", testCaseSetNodePoviderID{
+			Entry("Keep the uid ProviderID of the Metal3Machine in namespacedName format", testCaseSetNodePoviderID{
 				TargetObjects: []runtime.Object{
 					&corev1.Node{
 						ObjectMeta: metav1.ObjectMeta{
@@ -2653,13 +6054,11 @@ var _ = Describe("Metal3Machine manager", func() {
 								ProviderLabelPrefix: string(Bmhuid),
 							},
 						},
-						Spec: corev1.NodeSpec{
-							ProviderID: ProviderID,
-						},
 					},
 				},
 				HostID:               string(Bmhuid),
-				ExpectedError:        false,
+				ProviderIDFormat:     ProviderIDFormatNamespacedName,
+				ProviderIDOnM3M:      ProviderID,
 				ExpectedProviderID:   ProviderID,
 				M3MHasHostAnnotation: true,
 			}),
@@ -2692,9 +6091,11 @@ var _ = Describe("Metal3Machine manager", func() {
 				)
 
 				Expect(err).NotTo(HaveOccurred())
+				machineMgr.ProviderIDFormat = tc.ProviderIDFormat
 
+				providerID := tc.ProviderIDOnM3M
 				err = machineMgr.SetNodeProviderID(context.TODO(),
-					&tc.ExpectedProviderID, m,
+					&providerID, m,
 				)
 
 				if tc.ExpectedError {
@@ -2714,6 +6115,7 @@ var _ = Describe("Metal3Machine manager", func() {
 					break
 				}
 				Expect(node.Spec.ProviderID).To(Equal(tc.ExpectedProviderID))
+				Expect(providerID).To(Equal(tc.ExpectedProviderID))
 			},
 			Entry("Accept providerID when set on a node", testCaseSetNodePoviderID{
 				TargetObjects: []runtime.Object{
@@ -2765,6 +6167,457 @@ var _ = Describe("Metal3Machine manager", func() {
 				ExpectedProviderID:   ProviderID,
 				M3MHasHostAnnotation: true,
 			}),
+			Entry("Match a node in uid format when configured with namespacedName format", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						Spec: corev1.NodeSpec{
+							ProviderID: ProviderID,
+						},
+					},
+				},
+				HostID:               string(Bmhuid),
+				ProviderIDFormat:     ProviderIDFormatNamespacedName,
+				ExpectedProviderID:   ProviderID,
+				M3MHasHostAnnotation: true,
+			}),
+			Entry("Match a node in namespacedName format when configured with uid format", testCaseSetNodePoviderID{
+				TargetObjects: []runtime.Object{
+					&corev1.Node{
+						Spec: corev1.NodeSpec{
+							ProviderID: fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+						},
+					},
+				},
+				HostID:               string(Bmhuid),
+				ProviderIDFormat:     ProviderIDFormatUID,
+				ExpectedProviderID:   fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName),
+				M3MHasHostAnnotation: true,
+			}),
+		)
+
+		It("Requeues with WorkloadClusterUnreachable while the workload cluster is unreachable", func() {
+			BMHHost := newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, string(Bmhuid))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(BMHHost).Build()
+			corev1Client := clientfake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						ProviderLabelPrefix: string(Bmhuid),
+					},
+				},
+			}).CoreV1()
+			unreachable := true
+			m := func(ctx context.Context, client client.Client, cluster *clusterv1.Cluster) (
+				clientcorev1.CoreV1Interface, error,
+			) {
+				if unreachable {
+					return nil, &capm3remote.ClusterUnreachableError{Err: errors.New("connection refused")}
+				}
+				return corev1Client, nil
+			}
+			m3m := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					UID:       m3muid,
+					Annotations: map[string]string{
+						HostAnnotation: namespaceName + "/" + baremetalhostName,
+					},
+				},
+			}
+			machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName),
+				newMetal3Cluster(metal3ClusterName, bmcOwnerRef,
+					&infrav1.Metal3ClusterSpec{NoCloudProvider: true}, nil,
+				),
+				&clusterv1.Machine{}, m3m, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			providerID := ""
+			err = machineMgr.SetNodeProviderID(context.TODO(), &providerID, m)
+			Expect(err).To(HaveOccurred())
+			var reconcileErr ReconcileError
+			Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+			Expect(reconcileErr.IsTransient()).To(BeTrue())
+			Expect(reconcileErr.GetRequeueAfter()).To(Equal(requeueAfter))
+			Expect(conditions.IsFalse(m3m, infrav1.WorkloadClusterReachableCondition)).To(BeTrue())
+			Expect(conditions.GetReason(m3m, infrav1.WorkloadClusterReachableCondition)).To(Equal(infrav1.WorkloadClusterUnreachableReason))
+
+			// The condition is set to true once the cluster is reachable.
+			unreachable = false
+			err = machineMgr.SetNodeProviderID(context.TODO(), &providerID, m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conditions.IsTrue(m3m, infrav1.WorkloadClusterReachableCondition)).To(BeTrue())
+		})
+
+		type testCaseCheckProviderIDFormat struct {
+			ProviderIDOnM3M  string
+			ExpectedReason   string
+			ProviderIDFormat ProviderIDFormat
+		}
+
+		newProviderID := fmt.Sprintf("metal3://%s/%s/%s", namespaceName, baremetalhostName, metal3machineName)
+
+		DescribeTable("Test CheckProviderIDFormat",
+			func(tc testCaseCheckProviderIDFormat) {
+				fakeClient := fake.NewClientBuilder().WithScheme(s).Build()
+				m3m := &infrav1.Metal3Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      metal3machineName,
+						Namespace: namespaceName,
+						Annotations: map[string]string{
+							HostAnnotation: namespaceName + "/" + baremetalhostName,
+						},
+					},
+					Spec: infrav1.Metal3MachineSpec{
+						ProviderID: pointer.String(tc.ProviderIDOnM3M),
+					},
+				}
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef, &infrav1.Metal3ClusterSpec{}, nil),
+					&clusterv1.Machine{}, m3m, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+				machineMgr.ProviderIDFormat = tc.ProviderIDFormat
+
+				machineMgr.CheckProviderIDFormat()
+
+				// The providerID is never rewritten.
+				Expect(*m3m.Spec.ProviderID).To(Equal(tc.ProviderIDOnM3M))
+				if tc.ExpectedReason == "" {
+					Expect(conditions.IsTrue(m3m, infrav1.ProviderIDFormatMatchedCondition)).To(BeTrue())
+				} else {
+					Expect(conditions.IsFalse(m3m, infrav1.ProviderIDFormatMatchedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(m3m, infrav1.ProviderIDFormatMatchedCondition)).To(Equal(tc.ExpectedReason))
+					Expect(conditions.GetMessage(m3m, infrav1.ProviderIDFormatMatchedCondition)).To(ContainSubstring("replace the Machine"))
+				}
+			},
+			Entry("New providerID format, format matched", testCaseCheckProviderIDFormat{
+				ProviderIDOnM3M: newProviderID,
+			}),
+			Entry("Legacy providerID format, keep matching and report the mismatch", testCaseCheckProviderIDFormat{
+				ProviderIDOnM3M:  ProviderID,
+				ProviderIDFormat: ProviderIDFormatNamespacedName,
+				ExpectedReason:   infrav1.LegacyProviderIDFormatReason,
+			}),
+			Entry("Legacy providerID format, expected with the uid format", testCaseCheckProviderIDFormat{
+				ProviderIDOnM3M:  ProviderID,
+				ProviderIDFormat: ProviderIDFormatUID,
+			}),
+		)
+
+		DescribeTable("Test ParseProviderIDFormat",
+			func(format string, expected ProviderIDFormat, expectError bool) {
+				parsed, err := ParseProviderIDFormat(format)
+				if expectError {
+					Expect(err).To(HaveOccurred())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(parsed).To(Equal(expected))
+			},
+			Entry("uid", "uid", ProviderIDFormatUID, false),
+			Entry("namespacedName", "namespacedName", ProviderIDFormatNamespacedName, false),
+			Entry("empty", "", ProviderIDFormat(""), true),
+			Entry("unknown", "name", ProviderIDFormat(""), true),
+		)
+
+		drainPod := func(name, ownerKind string, annotations map[string]string) *corev1.Pod {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   namespaceName,
+					Annotations: annotations,
+				},
+				Spec: corev1.PodSpec{NodeName: "node-0"},
+			}
+			if ownerKind != "" {
+				pod.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       ownerKind,
+					Name:       name,
+					Controller: pointer.Bool(true),
+				}}
+			}
+			return pod
+		}
+
+		type testCaseDrainNode struct {
+			DrainTimeout          *metav1.Duration
+			NoNodeRef             bool
+			Excluded              bool
+			NodeMissing           bool
+			Unreachable           bool
+			Pods                  []runtime.Object
+			StuckPods             []string
+			VolumesAttached       int
+			MachineDrainTimeout   *metav1.Duration
+			MachineDetachTimeout  *metav1.Duration
+			DrainStartedAgo       time.Duration
+			DetachStartedAgo      time.Duration
+			ExpectRequeue         bool
+			ExpectCordoned        bool
+			ExpectedReason        string
+			ExpectedMessage       string
+			ExpectDrained         bool
+			ExpectedRemainingPods []string
+		}
+
+		DescribeTable("Test DrainNode",
+			func(tc testCaseDrainNode) {
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+				objects := tc.Pods
+				if !tc.NodeMissing {
+					node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+					for i := 0; i < tc.VolumesAttached; i++ {
+						node.Status.VolumesAttached = append(node.Status.VolumesAttached, corev1.AttachedVolume{
+							Name: corev1.UniqueVolumeName(fmt.Sprintf("volume-%d", i)),
+						})
+					}
+					if tc.DetachStartedAgo != 0 {
+						node.Spec.Unschedulable = true
+					}
+					objects = append(objects, node)
+				}
+				clientset := clientfake.NewSimpleClientset(objects...)
+				// Evictions delete the pod, unless it is stuck behind a
+				// disruption budget.
+				clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() != "eviction" {
+						return false, nil, nil
+					}
+					eviction, _ := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+					for _, stuck := range tc.StuckPods {
+						if stuck == eviction.Name {
+							return true, nil, apierrors.NewTooManyRequests("disruption budget", 10)
+						}
+					}
+					return true, nil, clientset.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+				})
+				m := func(ctx context.Context, client client.Client, cluster *clusterv1.Cluster) (
+					clientcorev1.CoreV1Interface, error,
+				) {
+					if tc.Unreachable {
+						return nil, errors.New("unreachable")
+					}
+					return clientset.CoreV1(), nil
+				}
+				machine := &clusterv1.Machine{
+					Spec: clusterv1.MachineSpec{
+						NodeDrainTimeout:        tc.MachineDrainTimeout,
+						NodeVolumeDetachTimeout: tc.MachineDetachTimeout,
+					},
+					Status: clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "node-0"}},
+				}
+				if tc.NoNodeRef {
+					machine.Status.NodeRef = nil
+				}
+				if tc.Excluded {
+					machine.Annotations = map[string]string{clusterv1.ExcludeNodeDrainingAnnotation: ""}
+				}
+				m3m := &infrav1.Metal3Machine{
+					ObjectMeta: metav1.ObjectMeta{Name: metal3machineName, Namespace: namespaceName},
+					Spec:       infrav1.Metal3MachineSpec{Metal3DrainTimeout: tc.DrainTimeout},
+				}
+				if tc.DrainStartedAgo != 0 {
+					conditions.MarkFalse(m3m, infrav1.NodeDrainedCondition, infrav1.DrainingNodeReason, clusterv1.ConditionSeverityInfo, "")
+					m3m.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-tc.DrainStartedAgo))
+				}
+				if tc.DetachStartedAgo != 0 {
+					conditions.MarkFalse(m3m, infrav1.NodeDrainedCondition, infrav1.WaitingForVolumeDetachReason, clusterv1.ConditionSeverityInfo, "")
+					m3m.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-tc.DetachStartedAgo))
+				}
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil, machine, m3m, logr.Discard())
+				Expect(err).NotTo(HaveOccurred())
+
+				err = machineMgr.DrainNode(context.TODO(), m)
+				if tc.ExpectRequeue {
+					var reconcileError ReconcileError
+					Expect(errors.As(err, &reconcileError)).To(BeTrue())
+					Expect(reconcileError.IsTransient()).To(BeTrue())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				switch {
+				case tc.ExpectDrained:
+					Expect(conditions.IsTrue(m3m, infrav1.NodeDrainedCondition)).To(BeTrue())
+				case tc.ExpectedReason != "":
+					Expect(conditions.IsFalse(m3m, infrav1.NodeDrainedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(m3m, infrav1.NodeDrainedCondition)).To(Equal(tc.ExpectedReason))
+					Expect(conditions.GetMessage(m3m, infrav1.NodeDrainedCondition)).To(ContainSubstring(tc.ExpectedMessage))
+				default:
+					Expect(conditions.Has(m3m, infrav1.NodeDrainedCondition)).To(BeFalse())
+				}
+
+				if !tc.NodeMissing {
+					node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(node.Spec.Unschedulable).To(Equal(tc.ExpectCordoned))
+				}
+				pods, err := clientset.CoreV1().Pods(namespaceName).List(context.TODO(), metav1.ListOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				remainingPods := []string{}
+				for _, pod := range pods.Items {
+					remainingPods = append(remainingPods, pod.Name)
+				}
+				Expect(remainingPods).To(ConsistOf(tc.ExpectedRemainingPods))
+			},
+			Entry("Drain disabled", testCaseDrainNode{
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil)},
+				ExpectedRemainingPods: []string{"app"},
+			}),
+			Entry("No Node", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				NoNodeRef:             true,
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil)},
+				ExpectedRemainingPods: []string{"app"},
+			}),
+			Entry("Node excluded from draining", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				Excluded:              true,
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil)},
+				ExpectedRemainingPods: []string{"app"},
+			}),
+			Entry("Evict the pods, except DaemonSet and mirror pods", testCaseDrainNode{
+				DrainTimeout: &metav1.Duration{Duration: time.Minute},
+				Pods: []runtime.Object{
+					drainPod("app", "ReplicaSet", nil),
+					drainPod("bare", "", nil),
+					drainPod("daemon", "DaemonSet", nil),
+					drainPod("mirror", "", map[string]string{corev1.MirrorPodAnnotationKey: ""}),
+				},
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.DrainingNodeReason,
+				ExpectedMessage:       "timeout of 1m0s from the metal3DrainTimeout",
+				ExpectedRemainingPods: []string{"daemon", "mirror"},
+			}),
+			Entry("Machine nodeDrainTimeout recorded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				MachineDrainTimeout:   &metav1.Duration{Duration: 10 * time.Minute},
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil)},
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.DrainingNodeReason,
+				ExpectedMessage:       "timeout of 10m0s from the Machine nodeDrainTimeout",
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Node drained", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				Pods:                  []runtime.Object{drainPod("daemon", "DaemonSet", nil)},
+				DrainStartedAgo:       time.Second,
+				ExpectCordoned:        true,
+				ExpectDrained:         true,
+				ExpectedRemainingPods: []string{"daemon"},
+			}),
+			Entry("Stuck eviction", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil), drainPod("stuck", "ReplicaSet", nil)},
+				StuckPods:             []string{"stuck"},
+				DrainStartedAgo:       time.Second,
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.DrainingNodeReason,
+				ExpectedRemainingPods: []string{"stuck"},
+			}),
+			Entry("Stuck eviction, timeout exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				Pods:                  []runtime.Object{drainPod("stuck", "ReplicaSet", nil)},
+				StuckPods:             []string{"stuck"},
+				DrainStartedAgo:       2 * time.Minute,
+				ExpectedReason:        infrav1.DrainTimeoutExceededReason,
+				ExpectedRemainingPods: []string{"stuck"},
+			}),
+			Entry("Stuck eviction, Machine nodeDrainTimeout not exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				MachineDrainTimeout:   &metav1.Duration{Duration: 10 * time.Minute},
+				Pods:                  []runtime.Object{drainPod("stuck", "ReplicaSet", nil)},
+				StuckPods:             []string{"stuck"},
+				DrainStartedAgo:       2 * time.Minute,
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.DrainingNodeReason,
+				ExpectedRemainingPods: []string{"stuck"},
+			}),
+			Entry("Stuck eviction, Machine nodeDrainTimeout exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: 10 * time.Minute},
+				MachineDrainTimeout:   &metav1.Duration{Duration: time.Minute},
+				Pods:                  []runtime.Object{drainPod("stuck", "ReplicaSet", nil)},
+				StuckPods:             []string{"stuck"},
+				DrainStartedAgo:       2 * time.Minute,
+				ExpectedReason:        infrav1.DrainTimeoutExceededReason,
+				ExpectedMessage:       "Machine nodeDrainTimeout of 1m0s",
+				ExpectedRemainingPods: []string{"stuck"},
+			}),
+			Entry("Node drained, waiting for the volumes to detach", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				VolumesAttached:       2,
+				DrainStartedAgo:       time.Second,
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.WaitingForVolumeDetachReason,
+				ExpectedMessage:       "timeout of 5m0s from the default volume detach timeout",
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Node drained, waiting for the volumes to detach with the Machine nodeVolumeDetachTimeout", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				MachineDetachTimeout:  &metav1.Duration{Duration: 20 * time.Minute},
+				VolumesAttached:       1,
+				DrainStartedAgo:       time.Second,
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.WaitingForVolumeDetachReason,
+				ExpectedMessage:       "timeout of 20m0s from the Machine nodeVolumeDetachTimeout",
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Volumes detached", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				DetachStartedAgo:      time.Minute,
+				ExpectCordoned:        true,
+				ExpectDrained:         true,
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Volumes attached, Machine nodeVolumeDetachTimeout not exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				MachineDetachTimeout:  &metav1.Duration{Duration: 20 * time.Minute},
+				VolumesAttached:       1,
+				DetachStartedAgo:      10 * time.Minute,
+				ExpectRequeue:         true,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.WaitingForVolumeDetachReason,
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Volumes attached, default volume detach timeout exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				VolumesAttached:       1,
+				DetachStartedAgo:      10 * time.Minute,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.VolumeDetachTimeoutExceededReason,
+				ExpectedMessage:       "default volume detach timeout of 5m0s",
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Volumes attached, Machine nodeVolumeDetachTimeout exceeded", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				MachineDetachTimeout:  &metav1.Duration{Duration: time.Minute},
+				VolumesAttached:       1,
+				DetachStartedAgo:      2 * time.Minute,
+				ExpectCordoned:        true,
+				ExpectedReason:        infrav1.VolumeDetachTimeoutExceededReason,
+				ExpectedMessage:       "Machine nodeVolumeDetachTimeout of 1m0s",
+				ExpectedRemainingPods: []string{},
+			}),
+			Entry("Workload cluster unreachable", testCaseDrainNode{
+				DrainTimeout:          &metav1.Duration{Duration: time.Minute},
+				Unreachable:           true,
+				Pods:                  []runtime.Object{drainPod("app", "ReplicaSet", nil)},
+				ExpectedReason:        infrav1.WorkloadClusterUnreachableReason,
+				ExpectedRemainingPods: []string{"app"},
+			}),
+			Entry("Node not found", testCaseDrainNode{
+				DrainTimeout:  &metav1.Duration{Duration: time.Minute},
+				NodeMissing:   true,
+				ExpectDrained: true,
+			}),
 		)
 	})
 
@@ -2954,15 +6807,16 @@ var _ = Describe("Metal3Machine manager", func() {
 	)
 
 	type testCaseAssociate struct {
-		Machine            *clusterv1.Machine
-		Host               *bmov1alpha1.BareMetalHost
-		M3Machine          *infrav1.Metal3Machine
-		BMCSecret          *corev1.Secret
-		DataTemplate       *infrav1.Metal3DataTemplate
-		Data               *infrav1.Metal3Data
-		ExpectRequeue      bool
-		ExpectClusterLabel bool
-		ExpectOwnerRef     bool
+		Machine               *clusterv1.Machine
+		Host                  *bmov1alpha1.BareMetalHost
+		M3Machine             *infrav1.Metal3Machine
+		BMCSecret             *corev1.Secret
+		DataTemplate          *infrav1.Metal3DataTemplate
+		Data                  *infrav1.Metal3Data
+		ExpectRequeue         bool
+		ExpectNoAvailableHost bool
+		ExpectClusterLabel    bool
+		ExpectOwnerRef        bool
 	}
 
 	DescribeTable("Test Associate function",
@@ -2996,6 +6850,7 @@ var _ = Describe("Metal3Machine manager", func() {
 				ok := errors.As(err, &reconcileError)
 				fmt.Println(errors.Cause(err))
 				Expect(ok).To(BeTrue())
+				Expect(errors.Is(err, ErrNoAvailableHost)).To(Equal(tc.ExpectNoAvailableHost))
 			} else {
 				Expect(err).NotTo(HaveOccurred())
 			}
@@ -3067,9 +6922,10 @@ var _ = Describe("Metal3Machine manager", func() {
 				M3Machine: newMetal3Machine(metal3machineName, m3mSpecAll(), nil,
 					m3mObjectMetaWithValidAnnotations(),
 				),
-				Host:           newBareMetalHost("", nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
-				ExpectRequeue:  true,
-				ExpectOwnerRef: false,
+				Host:                  newBareMetalHost("", nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
+				ExpectRequeue:         true,
+				ExpectNoAvailableHost: true,
+				ExpectOwnerRef:        false,
 			},
 		),
 		Entry("Associate machine, host nil, Metal3 machine spec set, requeue",
@@ -3078,8 +6934,9 @@ var _ = Describe("Metal3Machine manager", func() {
 				M3Machine: newMetal3Machine(metal3machineName, m3mSpecAll(), nil,
 					m3mObjectMetaWithValidAnnotations(),
 				),
-				Host:          nil,
-				ExpectRequeue: true,
+				Host:                  nil,
+				ExpectRequeue:         true,
+				ExpectNoAvailableHost: true,
 			},
 		),
 		Entry("Associate machine, host set, Metal3 machine spec set, set clusterLabel",
@@ -3161,63 +7018,513 @@ var _ = Describe("Metal3Machine manager", func() {
 						Ready: true,
 					},
 				},
-				ExpectClusterLabel: true,
-				ExpectRequeue:      false,
-				ExpectOwnerRef:     true,
+				ExpectClusterLabel: true,
+				ExpectRequeue:      false,
+				ExpectOwnerRef:     true,
+			},
+		),
+	)
+
+	type testCaseUpdate struct {
+		Machine     *clusterv1.Machine
+		Host        *bmov1alpha1.BareMetalHost
+		M3Machine   *infrav1.Metal3Machine
+		ExpectError bool
+	}
+
+	DescribeTable("Test Update function",
+		func(tc testCaseUpdate) {
+			objects := []client.Object{
+				tc.Host,
+				tc.M3Machine,
+				tc.Machine,
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, tc.Machine,
+				tc.M3Machine, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.Update(context.TODO())
+			if tc.ExpectError {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		},
+		Entry("Update machine", testCaseUpdate{
+			Machine: newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			),
+			Host: newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
+		}),
+		Entry("Update machine, DataTemplate missing", testCaseUpdate{
+			Machine: newMachine(machineName, nil),
+			M3Machine: newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				DataTemplate: &corev1.ObjectReference{
+					Name: "abcd",
+				},
+			}, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			),
+			Host:        newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
+			ExpectError: true,
+		}),
+	)
+
+	Describe("Test a deleted BareMetalHost", func() {
+		It("Fails a running machine whose host was deleted", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			)
+			m3m.Spec.ProviderID = pointer.String(ProviderID)
+			m3m.Status.Ready = true
+			machine := newMachine(machineName, nil)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m, machine).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.Update(context.TODO())
+			Expect(errors.Is(err, ErrHostDeleted)).To(BeTrue())
+			Expect(m3m.Annotations).To(HaveKey(HostAnnotation))
+			Expect(*m3m.Status.FailureReason).To(Equal(capierrors.UpdateMachineError))
+			Expect(*m3m.Status.FailureMessage).To(ContainSubstring(baremetalhostName))
+			Expect(conditions.GetReason(m3m, infrav1.AssociateBMHCondition)).To(Equal(infrav1.HostDeletedReason))
+
+			// The machine stays failed, it is not associated with another host.
+			Expect(errors.Is(machineMgr.Update(context.TODO()), ErrHostDeleted)).To(BeTrue())
+			Expect(errors.Is(machineMgr.Associate(context.TODO()), ErrHostDeleted)).To(BeTrue())
+			Expect(m3m.Status.FailureReason).NotTo(BeNil())
+		})
+
+		It("Deletes a machine whose host was deleted", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			)
+			machine := newMachine(machineName, nil)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m, machine).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.Delete(context.TODO())).To(Succeed())
+			Expect(m3m.Annotations).NotTo(HaveKey(HostAnnotation))
+			Expect(m3m.Status.FailureReason).To(BeNil())
+		})
+
+		It("Reads the host missing from the cache from the API server", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			)
+			machine := newMachine(machineName, nil)
+			host := newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateProvisioned, nil, false, "metadata", false, "")
+			// The cached client does not hold the host, e.g. relabeled so that
+			// it no longer matches the host label selector.
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m, machine).Build()
+			apiReader := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(host).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.APIReader = apiReader
+
+			found, _, err := machineMgr.getHost(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).NotTo(BeNil())
+			Expect(found.Name).To(Equal(baremetalhostName))
+		})
+
+		It("Fails the machine whose host is not found by the API server either", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			)
+			machine := newMachine(machineName, nil)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m, machine).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.APIReader = fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+
+			Expect(errors.Is(machineMgr.Update(context.TODO()), ErrHostDeleted)).To(BeTrue())
+		})
+
+		It("Does not fail the machine on a transient error", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil,
+				m3mObjectMetaWithValidAnnotations(),
+			)
+			machine := newMachine(machineName, nil)
+			host := newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, "")
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m, machine, host).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*bmov1alpha1.BareMetalHost); ok {
+							return apierrors.NewForbidden(bmov1alpha1.GroupVersion.WithResource("baremetalhosts").GroupResource(), key.Name, errors.New("forbidden"))
+						}
+						return c.Get(ctx, key, obj, opts...)
+					},
+				}).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.Update(context.TODO())
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(errors.Is(err, ErrHostDeleted)).To(BeFalse())
+			Expect(m3m.Annotations).To(HaveKey(HostAnnotation))
+			Expect(m3m.Status.FailureReason).To(BeNil())
+		})
+	})
+
+	type testCaseAssociateCrash struct {
+		HostAConsumerRef      *corev1.ObjectReference
+		HostAnnotation        string
+		ExpectedHost          string
+		ExpectedHostAConsumer string
+		ExpectUpdateRequeue   bool
+	}
+
+	type testCaseSetHostRootDeviceHints struct {
+		Hints               *infrav1.RootDeviceHints
+		Host                *bmov1alpha1.BareMetalHost
+		ExpectHints         *bmov1alpha1.RootDeviceHints
+		ExpectAnnotation    bool
+		ExpectConflictEvent bool
+	}
+
+	DescribeTable("Test setHostRootDeviceHints",
+		func(tc testCaseSetHostRootDeviceHints) {
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil)
+			m3m.Spec.RootDeviceHints = tc.Hints
+			recorder := record.NewFakeRecorder(10)
+			machineMgr, err := NewMachineManager(nil, nil, nil, nil, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			machineMgr.Recorder = recorder
+
+			machineMgr.setHostRootDeviceHints(tc.Host)
+
+			Expect(tc.Host.Spec.RootDeviceHints).To(Equal(tc.ExpectHints))
+			if tc.ExpectAnnotation {
+				Expect(tc.Host.Annotations).To(HaveKey(infrav1.HostRootDeviceHintsAnnotation))
+			} else {
+				Expect(tc.Host.Annotations).NotTo(HaveKey(infrav1.HostRootDeviceHintsAnnotation))
+			}
+			if tc.ExpectConflictEvent {
+				Expect(recorder.Events).To(Receive(ContainSubstring(infrav1.RootDeviceHintsConflictReason)))
+			} else {
+				Expect(recorder.Events).NotTo(Receive())
+			}
+		},
+		Entry("No hints in the Metal3Machine", testCaseSetHostRootDeviceHints{
+			Host: &bmov1alpha1.BareMetalHost{},
+		}),
+		Entry("Hints set on the host without hints", testCaseSetHostRootDeviceHints{
+			Hints: &infrav1.RootDeviceHints{DeviceName: "/dev/sda", MinSizeGigabytes: 100},
+			Host:  &bmov1alpha1.BareMetalHost{},
+			ExpectHints: &bmov1alpha1.RootDeviceHints{
+				DeviceName: "/dev/sda", MinSizeGigabytes: 100,
+			},
+			ExpectAnnotation: true,
+		}),
+		Entry("Hints set by CAPM3 updated", testCaseSetHostRootDeviceHints{
+			Hints: &infrav1.RootDeviceHints{SerialNumber: "1234"},
+			Host: &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					infrav1.HostRootDeviceHintsAnnotation: "",
+				}},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					RootDeviceHints: &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"},
+				},
 			},
-		),
+			ExpectHints:      &bmov1alpha1.RootDeviceHints{SerialNumber: "1234"},
+			ExpectAnnotation: true,
+		}),
+		Entry("Conflicting hints of the host kept", testCaseSetHostRootDeviceHints{
+			Hints: &infrav1.RootDeviceHints{DeviceName: "/dev/sda"},
+			Host: &bmov1alpha1.BareMetalHost{
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					RootDeviceHints: &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sdb"},
+				},
+			},
+			ExpectHints:         &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sdb"},
+			ExpectConflictEvent: true,
+		}),
+		Entry("Identical hints of the host kept", testCaseSetHostRootDeviceHints{
+			Hints: &infrav1.RootDeviceHints{DeviceName: "/dev/sda"},
+			Host: &bmov1alpha1.BareMetalHost{
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					RootDeviceHints: &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"},
+				},
+			},
+			ExpectHints: &bmov1alpha1.RootDeviceHints{DeviceName: "/dev/sda"},
+		}),
+		Entry("Host already provisioned", testCaseSetHostRootDeviceHints{
+			Hints: &infrav1.RootDeviceHints{DeviceName: "/dev/sda"},
+			Host: &bmov1alpha1.BareMetalHost{
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					Image: &bmov1alpha1.Image{URL: "http://image"},
+				},
+			},
+		}),
 	)
 
-	type testCaseUpdate struct {
-		Machine     *clusterv1.Machine
-		Host        *bmov1alpha1.BareMetalHost
-		M3Machine   *infrav1.Metal3Machine
-		ExpectError bool
-	}
-
-	DescribeTable("Test Update function",
-		func(tc testCaseUpdate) {
-			objects := []client.Object{
-				tc.Host,
-				tc.M3Machine,
-				tc.Machine,
+	DescribeTable("Test Associate after a crash between the host and machine writes",
+		func(tc testCaseAssociateCrash) {
+			objMeta := &metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+				UID:       "m3m-uid",
 			}
-			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			if tc.HostAnnotation != "" {
+				objMeta.Annotations = map[string]string{
+					HostAnnotation: namespaceName + "/" + tc.HostAnnotation,
+				}
+			}
+			m3m := newMetal3Machine(metal3machineName, nil, nil, objMeta)
+			machine := newMachine(machineName, nil)
+			hostA := newBareMetalHost("host-a", &bmov1alpha1.BareMetalHostSpec{
+				ConsumerRef: tc.HostAConsumerRef,
+			}, bmov1alpha1.StateReady, &bmov1alpha1.BareMetalHostStatus{}, false, "metadata", false, "")
+			hostB := newBareMetalHost("host-b", &bmov1alpha1.BareMetalHostSpec{},
+				bmov1alpha1.StateReady, &bmov1alpha1.BareMetalHostStatus{}, false, "metadata", false, "",
+			)
+			if tc.HostAConsumerRef == nil {
+				// Only host-a can be chosen when it is free.
+				hostB.Status.Provisioning.State = bmov1alpha1.StateProvisioning
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).
+				WithObjects(m3m, machine, hostA, hostB).Build()
 
-			machineMgr, err := NewMachineManager(fakeClient, nil, nil, tc.Machine,
-				tc.M3Machine, logr.Discard(),
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine,
+				m3m, logr.Discard(),
 			)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = machineMgr.Update(context.TODO())
-			if tc.ExpectError {
-				Expect(err).To(HaveOccurred())
-				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
-			} else {
-				Expect(err).NotTo(HaveOccurred())
+			// Replay what the controller does on the next reconcile.
+			if machineMgr.HasAnnotation() {
+				err = machineMgr.Update(context.TODO())
+				if tc.ExpectUpdateRequeue {
+					var reconcileError ReconcileError
+					Expect(errors.As(err, &reconcileError)).To(BeTrue())
+					Expect(machineMgr.HasAnnotation()).To(BeFalse())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+			}
+			if !machineMgr.HasAnnotation() {
+				Expect(machineMgr.Associate(context.TODO())).To(Succeed())
 			}
+
+			Expect(m3m.Annotations[HostAnnotation]).To(Equal(namespaceName + "/" + tc.ExpectedHost))
+			savedHost := bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      tc.ExpectedHost,
+				Namespace: namespaceName,
+			}, &savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).NotTo(BeNil())
+			Expect(savedHost.Spec.ConsumerRef.Name).To(Equal(metal3machineName))
+			Expect(savedHost.Spec.ConsumerRef.UID).To(Equal(m3m.UID))
+
+			savedHostA := bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{
+				Name:      "host-a",
+				Namespace: namespaceName,
+			}, &savedHostA)).To(Succeed())
+			Expect(savedHostA.Spec.ConsumerRef).NotTo(BeNil())
+			Expect(savedHostA.Spec.ConsumerRef.Name).To(Equal(tc.ExpectedHostAConsumer))
 		},
-		Entry("Update machine", testCaseUpdate{
-			Machine: newMachine(machineName, nil),
-			M3Machine: newMetal3Machine(metal3machineName, nil, nil,
-				m3mObjectMetaWithValidAnnotations(),
-			),
-			Host: newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
+		Entry("No write done", testCaseAssociateCrash{
+			ExpectedHost:          "host-a",
+			ExpectedHostAConsumer: metal3machineName,
 		}),
-		Entry("Update machine, DataTemplate missing", testCaseUpdate{
-			Machine: newMachine(machineName, nil),
-			M3Machine: newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
-				DataTemplate: &corev1.ObjectReference{
-					Name: "abcd",
-				},
-			}, nil,
-				m3mObjectMetaWithValidAnnotations(),
-			),
-			Host:        newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateNone, nil, false, "metadata", false, ""),
-			ExpectError: true,
+		Entry("ConsumerRef written, annotation missing", testCaseAssociateCrash{
+			HostAConsumerRef: &corev1.ObjectReference{
+				Name:       metal3machineName,
+				Namespace:  namespaceName,
+				Kind:       "M3Machine",
+				APIVersion: infrav1.GroupVersion.String(),
+				UID:        "m3m-uid",
+			},
+			ExpectedHost:          "host-a",
+			ExpectedHostAConsumer: metal3machineName,
+		}),
+		Entry("ConsumerRef written without UID, annotation missing", testCaseAssociateCrash{
+			HostAConsumerRef: &corev1.ObjectReference{
+				Name:       metal3machineName,
+				Namespace:  namespaceName,
+				Kind:       "M3Machine",
+				APIVersion: infrav1.GroupVersion.String(),
+			},
+			ExpectedHost:          "host-a",
+			ExpectedHostAConsumer: metal3machineName,
+		}),
+		Entry("Annotation written, consumerRef missing", testCaseAssociateCrash{
+			HostAnnotation:        "host-a",
+			ExpectedHost:          "host-a",
+			ExpectedHostAConsumer: metal3machineName,
+		}),
+		Entry("Both written", testCaseAssociateCrash{
+			HostAConsumerRef: &corev1.ObjectReference{
+				Name:       metal3machineName,
+				Namespace:  namespaceName,
+				Kind:       "M3Machine",
+				APIVersion: infrav1.GroupVersion.String(),
+				UID:        "m3m-uid",
+			},
+			HostAnnotation:        "host-a",
+			ExpectedHost:          "host-a",
+			ExpectedHostAConsumer: metal3machineName,
+		}),
+		Entry("Annotation written, host claimed by another machine", testCaseAssociateCrash{
+			HostAConsumerRef: &corev1.ObjectReference{
+				Name:       "othermachine",
+				Namespace:  namespaceName,
+				Kind:       "M3Machine",
+				APIVersion: infrav1.GroupVersion.String(),
+			},
+			HostAnnotation:        "host-a",
+			ExpectUpdateRequeue:   true,
+			ExpectedHost:          "host-b",
+			ExpectedHostAConsumer: "othermachine",
+		}),
+		Entry("ConsumerRef of a previous machine with the same name", testCaseAssociateCrash{
+			HostAConsumerRef: &corev1.ObjectReference{
+				Name:       metal3machineName,
+				Namespace:  namespaceName,
+				Kind:       "M3Machine",
+				APIVersion: infrav1.GroupVersion.String(),
+				UID:        "old-m3m-uid",
+			},
+			ExpectedHost:          "host-b",
+			ExpectedHostAConsumer: metal3machineName,
 		}),
 	)
 
+	Describe("Test Associate with concurrent claims", func() {
+		// claimedBy returns an interceptor claiming the host for another
+		// machine right before the first patches of the hosts, as a machine
+		// reconciled concurrently would, leaving the cache read by chooseHost
+		// stale.
+		claimedBy := func(consumer string, hosts ...string) interceptor.Funcs {
+			claimed := map[string]bool{}
+			return interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					if _, ok := obj.(*bmov1alpha1.BareMetalHost); ok && Contains(hosts, obj.GetName()) && !claimed[obj.GetName()] {
+						claimed[obj.GetName()] = true
+						other := &bmov1alpha1.BareMetalHost{}
+						Expect(c.Get(ctx, client.ObjectKeyFromObject(obj), other)).To(Succeed())
+						other.Spec.ConsumerRef = &corev1.ObjectReference{
+							Name:       consumer,
+							Namespace:  namespaceName,
+							Kind:       "Metal3Machine",
+							APIVersion: infrav1.GroupVersion.String(),
+						}
+						Expect(c.Update(ctx, other)).To(Succeed())
+					}
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}
+		}
+		newHosts := func(names ...string) []client.Object {
+			objects := []client.Object{}
+			for _, name := range names {
+				objects = append(objects, newBareMetalHost(name, &bmov1alpha1.BareMetalHostSpec{},
+					bmov1alpha1.StateReady, &bmov1alpha1.BareMetalHostStatus{}, false, "metadata", false, "",
+				))
+			}
+			return objects
+		}
+		hostConsumer := func(c client.Client, name string) string {
+			host := bmov1alpha1.BareMetalHost{}
+			Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: namespaceName}, &host)).To(Succeed())
+			if host.Spec.ConsumerRef == nil {
+				return ""
+			}
+			return host.Spec.ConsumerRef.Name
+		}
+
+		It("Chooses another host when the chosen one is claimed", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil)
+			machine := newMachine(machineName, nil)
+			objects := append(newHosts("host-a", "host-b"), m3m, machine)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).
+				WithInterceptorFuncs(claimedBy("othermachine", "host-a", "host-b")).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			// The first host chosen is lost, the second one is lost as well
+			// and no host is left.
+			err = machineMgr.Associate(context.TODO())
+			Expect(errors.Is(err, ErrNoAvailableHost)).To(BeTrue())
+			Expect(m3m.Annotations).NotTo(HaveKey(HostAnnotation))
+			Expect(hostConsumer(fakeClient, "host-a")).To(Equal("othermachine"))
+			Expect(hostConsumer(fakeClient, "host-b")).To(Equal("othermachine"))
+		})
+
+		It("Claims the host left by the concurrent machine", func() {
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil)
+			machine := newMachine(machineName, nil)
+			objects := append(newHosts("host-a", "host-b"), m3m, machine)
+			policy := infrav1.HostSelectionLeastRecentlyUsed
+			m3c := &infrav1.Metal3Cluster{Spec: infrav1.Metal3ClusterSpec{HostSelectionPolicy: policy}}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).
+				WithInterceptorFuncs(claimedBy("othermachine", "host-a")).Build()
+			machineMgr, err := NewMachineManager(fakeClient, nil, m3c, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.Associate(context.TODO())).To(Succeed())
+			Expect(m3m.Annotations[HostAnnotation]).To(Equal(namespaceName + "/host-b"))
+			Expect(hostConsumer(fakeClient, "host-a")).To(Equal("othermachine"))
+			Expect(hostConsumer(fakeClient, "host-b")).To(Equal(metal3machineName))
+		})
+
+		It("Associates each host with one machine when reconciled concurrently", func() {
+			const count = 5
+			hostNames := []string{}
+			for i := 0; i < count; i++ {
+				hostNames = append(hostNames, fmt.Sprintf("host-%d", i))
+			}
+			objects := newHosts(hostNames...)
+			machineMgrs := []*MachineManager{}
+			for i := 0; i < count; i++ {
+				m3m := newMetal3Machine(fmt.Sprintf("%s-%d", metal3machineName, i), nil, nil, nil)
+				machine := newMachine(fmt.Sprintf("%s-%d", machineName, i), nil)
+				objects = append(objects, m3m, machine)
+				machineMgrs = append(machineMgrs, &MachineManager{Metal3Machine: m3m, Machine: machine, Log: logr.Discard()})
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			done := make(chan error, count)
+			for _, machineMgr := range machineMgrs {
+				machineMgr := machineMgr
+				machineMgr.client = fakeClient
+				go func() {
+					defer GinkgoRecover()
+					// A machine losing all its chosen hosts is requeued.
+					var err error
+					for attempt := 0; attempt < count; attempt++ {
+						if err = machineMgr.Associate(context.TODO()); err == nil {
+							break
+						}
+						machineMgr.lostHosts = nil
+					}
+					done <- err
+				}()
+			}
+			for i := 0; i < count; i++ {
+				Expect(<-done).To(Succeed())
+			}
+
+			consumers := map[string]bool{}
+			for _, name := range hostNames {
+				consumer := hostConsumer(fakeClient, name)
+				Expect(consumer).NotTo(BeEmpty())
+				Expect(consumers).NotTo(HaveKey(consumer))
+				consumers[consumer] = true
+			}
+			for _, machineMgr := range machineMgrs {
+				Expect(machineMgr.Metal3Machine.Annotations).To(HaveKey(HostAnnotation))
+			}
+		})
+	})
+
 	type testCaseFindOwnerRef struct {
 		M3Machine     infrav1.Metal3Machine
 		OwnerRefs     []metav1.OwnerReference
@@ -3460,22 +7767,549 @@ var _ = Describe("Metal3Machine manager", func() {
 				},
 			},
 		}),
-		Entry("Present 1", testCaseOwnerRef{
-			M3Machine: *newMetal3Machine("myName", nil, nil, nil),
-			OwnerRefs: []metav1.OwnerReference{
-				{
-					APIVersion: "abc.com/v1",
-					Kind:       "def",
-					Name:       "ghi",
-					UID:        "adfasdf",
-				},
-				{
-					Kind:       "M3Machine",
-					APIVersion: infrav1.GroupVersion.String(),
-					Name:       "myName",
-					UID:        "adfasdf",
-				},
+		Entry("Present 1", testCaseOwnerRef{
+			M3Machine: *newMetal3Machine("myName", nil, nil, nil),
+			OwnerRefs: []metav1.OwnerReference{
+				{
+					APIVersion: "abc.com/v1",
+					Kind:       "def",
+					Name:       "ghi",
+					UID:        "adfasdf",
+				},
+				{
+					Kind:       "M3Machine",
+					APIVersion: infrav1.GroupVersion.String(),
+					Name:       "myName",
+					UID:        "adfasdf",
+				},
+			},
+		}),
+	)
+
+	type testCaseProvidedData struct {
+		Secrets         []*corev1.Secret
+		Validation      infrav1.ProvidedDataValidation
+		ProviderID      *string
+		ExpectedReason  string
+		ExpectedMessage string
+		ExpectRequeue   bool
+		ExpectMetaData  bool
+		ExpectNetwork   bool
+	}
+
+	DescribeTable("Test validation of the provided secrets",
+		func(tc testCaseProvidedData) {
+			objects := []client.Object{}
+			for _, secret := range tc.Secrets {
+				objects = append(objects, secret)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			m3m := newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
+				ProviderID:  tc.ProviderID,
+				MetaData:    &corev1.SecretReference{Name: "metadata"},
+				NetworkData: &corev1.SecretReference{Name: "networkdata", Namespace: namespaceName},
+			}, nil, nil)
+			m3m.Status.Conditions = clusterv1.Conditions{{
+				Type:     infrav1.ProvidedDataValidCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Reason:   infrav1.ProvidedDataEmptyReason,
+			}}
+			metal3Cluster := &infrav1.Metal3Cluster{
+				Spec: infrav1.Metal3ClusterSpec{ProvidedDataValidation: tc.Validation},
+			}
+			machineMgr, err := NewMachineManager(fakeClient, nil, metal3Cluster, nil, m3m,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.AssociateM3Metadata(context.TODO())
+			if tc.ExpectRequeue {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+
+				// The data rendering is blocked with the reason of the
+				// invalid secret.
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				step := conditions.Get(m3m, infrav1.Metal3DataReadyCondition)
+				Expect(step).NotTo(BeNil())
+				Expect(step.Status).To(Equal(corev1.ConditionFalse))
+				Expect(step.Reason).To(Equal(tc.ExpectedReason))
+				Expect(step.Severity).To(Equal(clusterv1.ConditionSeverityError))
+				Expect(step.Message).To(Equal(tc.ExpectedMessage))
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conditions.IsTrue(m3m, infrav1.Metal3DataReadyCondition)).To(BeTrue())
+			}
+			// The invalid secrets are not written to the BareMetalHost.
+			if tc.ExpectMetaData {
+				Expect(m3m.Status.MetaData).To(Equal(m3m.Spec.MetaData))
+			} else {
+				Expect(m3m.Status.MetaData).To(BeNil())
+			}
+			if tc.ExpectNetwork {
+				Expect(m3m.Status.NetworkData).To(Equal(m3m.Spec.NetworkData))
+			} else {
+				Expect(m3m.Status.NetworkData).To(BeNil())
+			}
+			condition := conditions.Get(m3m, infrav1.ProvidedDataValidCondition)
+			Expect(condition).NotTo(BeNil())
+			if tc.ExpectedReason == "" {
+				Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+			} else {
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Reason).To(Equal(tc.ExpectedReason))
+				Expect(condition.Message).To(Equal(tc.ExpectedMessage))
+				// Only the strict mode blocks the provisioning.
+				if tc.ExpectRequeue {
+					Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityError))
+				} else {
+					Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+				}
+			}
+		},
+		Entry("Valid secrets", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("metadata", map[string][]byte{"metaData": []byte("abc")}),
+				newProvidedSecret("networkdata", map[string][]byte{"networkData": []byte("abc")}),
+			},
+			Validation:     infrav1.ProvidedDataValidationStrict,
+			ExpectMetaData: true,
+			ExpectNetwork:  true,
+		}),
+		Entry("Missing secret, warn", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("networkdata", map[string][]byte{"networkData": []byte("abc")}),
+			},
+			ExpectedReason:  infrav1.ProvidedDataSecretNotFoundReason,
+			ExpectedMessage: "metaData secret " + namespaceName + "/metadata not found, not used",
+			ExpectNetwork:   true,
+		}),
+		Entry("Missing secret, strict", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("networkdata", map[string][]byte{"networkData": []byte("abc")}),
+			},
+			Validation:      infrav1.ProvidedDataValidationStrict,
+			ExpectedReason:  infrav1.ProvidedDataSecretNotFoundReason,
+			ExpectedMessage: "metaData secret " + namespaceName + "/metadata not found, not provisioning the BareMetalHost",
+			ExpectRequeue:   true,
+		}),
+		Entry("Wrong key, warn", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("metadata", map[string][]byte{"metaData": []byte("abc")}),
+				newProvidedSecret("networkdata", map[string][]byte{"networkdata": []byte("abc")}),
+			},
+			Validation:      infrav1.ProvidedDataValidationWarn,
+			ExpectedReason:  infrav1.ProvidedDataKeyMissingReason,
+			ExpectedMessage: "networkData secret " + namespaceName + "/networkdata has no networkData key, not used",
+			ExpectMetaData:  true,
+		}),
+		Entry("Both secrets invalid, warn", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("networkdata", map[string][]byte{"networkData": {}}),
+			},
+			Validation:     infrav1.ProvidedDataValidationWarn,
+			ExpectedReason: infrav1.ProvidedDataSecretNotFoundReason,
+			ExpectedMessage: "metaData secret " + namespaceName + "/metadata not found, " +
+				"networkData key of secret " + namespaceName + "/networkdata is empty, not used",
+		}),
+		Entry("Wrong key, strict", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("metadata", map[string][]byte{"metaData": []byte("abc")}),
+				newProvidedSecret("networkdata", map[string][]byte{"networkdata": []byte("abc")}),
+			},
+			Validation:      infrav1.ProvidedDataValidationStrict,
+			ExpectedReason:  infrav1.ProvidedDataKeyMissingReason,
+			ExpectedMessage: "networkData secret " + namespaceName + "/networkdata has no networkData key, not provisioning the BareMetalHost",
+			ExpectRequeue:   true,
+		}),
+		Entry("Empty value, strict", testCaseProvidedData{
+			Secrets: []*corev1.Secret{
+				newProvidedSecret("metadata", map[string][]byte{"metaData": {}}),
+				newProvidedSecret("networkdata", map[string][]byte{"networkData": []byte("abc")}),
+			},
+			Validation:      infrav1.ProvidedDataValidationStrict,
+			ExpectedReason:  infrav1.ProvidedDataEmptyReason,
+			ExpectedMessage: "metaData key of secret " + namespaceName + "/metadata is empty, not provisioning the BareMetalHost",
+			ExpectRequeue:   true,
+		}),
+		Entry("Provisioned machine, not validated", testCaseProvidedData{
+			Validation:     infrav1.ProvidedDataValidationStrict,
+			ProviderID:     pointer.String(ProviderID),
+			ExpectedReason: infrav1.ProvidedDataEmptyReason,
+			ExpectMetaData: true,
+			ExpectNetwork:  true,
+		}),
+	)
+
+	It("Stops using a provided secret that became invalid, in warn mode", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		metaData := &corev1.SecretReference{Name: "metadata"}
+		rendered := &corev1.SecretReference{Name: "rendered-networkdata"}
+		m3m := newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
+			MetaData:    metaData,
+			NetworkData: &corev1.SecretReference{Name: "networkdata"},
+		}, &infrav1.Metal3MachineStatus{
+			MetaData:    metaData.DeepCopy(),
+			NetworkData: rendered.DeepCopy(),
+		}, nil)
+		machineMgr, err := NewMachineManager(fakeClient, nil, &infrav1.Metal3Cluster{}, nil, m3m,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.AssociateM3Metadata(context.TODO())).To(Succeed())
+		Expect(m3m.Status.MetaData).To(BeNil())
+		// A secret that is not the provided one is kept.
+		Expect(m3m.Status.NetworkData).To(Equal(rendered))
+		Expect(conditions.IsFalse(m3m, infrav1.ProvidedDataValidCondition)).To(BeTrue())
+	})
+
+	It("Does not set the ProvidedDataValid condition without provided secrets", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		m3m := newMetal3Machine("myName", &infrav1.Metal3MachineSpec{}, nil, nil)
+		machineMgr, err := NewMachineManager(fakeClient, nil, &infrav1.Metal3Cluster{}, nil, m3m,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(machineMgr.AssociateM3Metadata(context.TODO())).To(Succeed())
+		Expect(conditions.Has(m3m, infrav1.ProvidedDataValidCondition)).To(BeFalse())
+	})
+
+	It("Does not write the defaults to the spec of a Metal3Machine stored before the webhook set them", func() {
+		checksumType := ""
+		m3m := newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
+			Image: infrav1.Image{
+				URL:          testImageURL,
+				Checksum:     testImageChecksumURL,
+				ChecksumType: &checksumType,
+			},
+			DataTemplate: &corev1.ObjectReference{Name: "abc"},
+		}, nil, nil)
+		legacySpec := m3m.Spec.DeepCopy()
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3m).Build()
+		machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3m,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		// The second reconcile finds the Metal3DataClaim created by the first.
+		for i := 0; i < 2; i++ {
+			Expect(machineMgr.AssociateM3Metadata(context.TODO())).To(Succeed())
+			Expect(m3m.Spec).To(Equal(*legacySpec))
+		}
+
+		dataClaim := &infrav1.Metal3DataClaim{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(m3m), dataClaim)).To(Succeed())
+		Expect(dataClaim.Spec.Template.Name).To(Equal("abc"))
+		Expect(dataClaim.Spec.Template.Namespace).To(Equal(namespaceName))
+	})
+
+	type testCaseUserDataFormat struct {
+		UserDataFormat  *string
+		Secret          *corev1.Secret
+		ExpectRequeue   bool
+		ExpectMatched   bool
+		ExpectedMessage string
+	}
+
+	DescribeTable("Test validation of the bootstrap data format",
+		func(tc testCaseUserDataFormat) {
+			objects := []client.Object{}
+			if tc.Secret != nil {
+				objects = append(objects, tc.Secret)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			m3m := newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{UserDataFormat: tc.UserDataFormat},
+			}, &infrav1.Metal3MachineStatus{
+				UserData: &corev1.SecretReference{Name: "bootstrap"},
+			}, nil)
+			m3m.Status.Conditions = clusterv1.Conditions{{
+				Type:     infrav1.BootstrapFormatMatchedCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityError,
+				Reason:   infrav1.BootstrapFormatMismatchReason,
+			}}
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3m,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.validateUserDataFormat(context.TODO())
+			condition := conditions.Get(m3m, infrav1.BootstrapFormatMatchedCondition)
+			if tc.ExpectRequeue {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityError))
+				Expect(condition.Reason).To(Equal(infrav1.BootstrapFormatMismatchReason))
+				Expect(condition.Message).To(Equal(tc.ExpectedMessage))
+
+				// The host selection is blocked with the same reason.
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				step := conditions.Get(m3m, infrav1.AssociateBMHCondition)
+				Expect(step).NotTo(BeNil())
+				Expect(step.Status).To(Equal(corev1.ConditionFalse))
+				Expect(step.Reason).To(Equal(infrav1.BootstrapFormatMismatchReason))
+				Expect(step.Severity).To(Equal(clusterv1.ConditionSeverityError))
+				Expect(step.Message).To(Equal(tc.ExpectedMessage))
+			} else if tc.ExpectMatched {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conditions.IsTrue(m3m, infrav1.BootstrapFormatMatchedCondition)).To(BeTrue())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(condition).To(BeNil())
+			}
+		},
+		Entry("No expectation", testCaseUserDataFormat{
+			Secret: newProvidedSecret("bootstrap", map[string][]byte{"format": []byte("ignition")}),
+		}),
+		Entry("Matching format", testCaseUserDataFormat{
+			UserDataFormat: pointer.String(infrav1.CloudInitUserDataFormat),
+			Secret:         newProvidedSecret("bootstrap", map[string][]byte{"format": []byte("cloud-config")}),
+			ExpectMatched:  true,
+		}),
+		Entry("Mismatching format", testCaseUserDataFormat{
+			UserDataFormat:  pointer.String(infrav1.CloudInitUserDataFormat),
+			Secret:          newProvidedSecret("bootstrap", map[string][]byte{"format": []byte("ignition")}),
+			ExpectRequeue:   true,
+			ExpectedMessage: "bootstrap data in secret " + namespaceName + "/bootstrap is in ignition format, the image expects cloud-init",
+		}),
+		Entry("Unknown format", testCaseUserDataFormat{
+			UserDataFormat: pointer.String(infrav1.IgnitionUserDataFormat),
+			Secret:         newProvidedSecret("bootstrap", map[string][]byte{"value": []byte("abc")}),
+		}),
+		Entry("Missing secret", testCaseUserDataFormat{
+			UserDataFormat: pointer.String(infrav1.IgnitionUserDataFormat),
+		}),
+	)
+
+	type testCaseAppendUserData struct {
+		UserDataAppend  *infrav1.UserDataAppend
+		RenderedData    bool
+		Bootstrap       string
+		ConfigMap       map[string]string
+		ExpectedReason  string
+		ExpectedMessage string
+		ExpectedValue   string
+	}
+
+	DescribeTable("Test appendUserData",
+		func(tc testCaseAppendUserData) {
+			objects := []client.Object{
+				newProvidedSecret("bootstrap", map[string][]byte{
+					"value":  []byte(tc.Bootstrap),
+					"format": []byte("cloud-config"),
+				}),
+				&infrav1.Metal3Data{
+					ObjectMeta: testObjectMeta(metal3DataName, namespaceName, ""),
+					Spec:       infrav1.Metal3DataSpec{Index: 3},
+				},
+			}
+			if tc.ConfigMap != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: testObjectMeta("append", namespaceName, ""),
+					Data:       tc.ConfigMap,
+				})
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			m3m := newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{
+				UserDataAppend: tc.UserDataAppend,
+			}, nil, nil)
+			if tc.RenderedData {
+				m3m.Status.RenderedData = &corev1.ObjectReference{Name: metal3DataName}
+			}
+			m3m.Status.Conditions = clusterv1.Conditions{{
+				Type:     infrav1.UserDataAppendedCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityError,
+				Reason:   infrav1.UserDataAppendRenderFailedReason,
+			}}
+			machine := &clusterv1.Machine{
+				ObjectMeta: testObjectMeta(machineName, namespaceName, ""),
+				Spec:       clusterv1.MachineSpec{ClusterName: clusterName},
+			}
+			host := newBareMetalHost(baremetalhostName, nil, bmov1alpha1.StateProvisioning, nil, false, "metadata", false, "")
+			host.Labels = map[string]string{"rack": "r1"}
+			host.Status.HardwareDetails = &bmov1alpha1.HardwareDetails{RAMMebibytes: 65536}
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			bootstrapRef := &corev1.SecretReference{Name: "bootstrap", Namespace: namespaceName}
+			userDataRef, err := machineMgr.appendUserData(context.TODO(), host, bootstrapRef)
+			condition := conditions.Get(m3m, infrav1.UserDataAppendedCondition)
+			if tc.ExpectedReason != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Reason).To(Equal(tc.ExpectedReason))
+				Expect(condition.Message).To(ContainSubstring(tc.ExpectedMessage))
+				step := conditions.Get(m3m, infrav1.AssociateBMHCondition)
+				Expect(step).NotTo(BeNil())
+				Expect(step.Status).To(Equal(corev1.ConditionFalse))
+				Expect(step.Reason).To(Equal(tc.ExpectedReason))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			if tc.UserDataAppend == nil {
+				Expect(condition).To(BeNil())
+				Expect(userDataRef).To(Equal(bootstrapRef))
+				return
+			}
+			Expect(conditions.IsTrue(m3m, infrav1.UserDataAppendedCondition)).To(BeTrue())
+			Expect(userDataRef.Name).To(Equal(dataSecretName(clusterName, metal3machineName, "userdata")))
+			Expect(userDataRef.Namespace).To(Equal(namespaceName))
+			secret := &corev1.Secret{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: userDataRef.Name, Namespace: namespaceName}, secret)).To(Succeed())
+			Expect(string(secret.Data["value"])).To(Equal(tc.ExpectedValue))
+			Expect(string(secret.Data["format"])).To(Equal("cloud-config"))
+			Expect(secret.OwnerReferences).To(HaveLen(1))
+			Expect(secret.OwnerReferences[0].Name).To(Equal(metal3machineName))
+
+			// Rendering again gives the same secret.
+			userDataRefAgain, err := machineMgr.appendUserData(context.TODO(), host, bootstrapRef)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userDataRefAgain).To(Equal(userDataRef))
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: userDataRef.Name, Namespace: namespaceName}, secret)).To(Succeed())
+			Expect(string(secret.Data["value"])).To(Equal(tc.ExpectedValue))
+		},
+		Entry("No userDataAppend", testCaseAppendUserData{
+			Bootstrap: "#cloud-config\n",
+		}),
+		Entry("Appended template", testCaseAppendUserData{
+			UserDataAppend: &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:      "#cloud-config\nruncmd: []",
+			ConfigMap: map[string]string{
+				"userData": "bootcmd:\n- echo {{ .BareMetalHostName }} {{ .BareMetalHostLabels.rack }} {{ .BareMetalHostRAMMebibytes }} {{ .Index }}\n",
+			},
+			ExpectedValue: "#cloud-config\nruncmd: []\nbootcmd:\n- echo " + baremetalhostName + " r1 65536 0\n",
+		}),
+		Entry("Appended template with the index of the Metal3Data and a custom key", testCaseAppendUserData{
+			UserDataAppend: &infrav1.UserDataAppend{Name: "append", Key: "kargs"},
+			RenderedData:   true,
+			Bootstrap:      "\n#cloud-config\n",
+			ConfigMap: map[string]string{
+				"kargs": "# {{ .ClusterName }}-{{ .MachineName }}-{{ .Index }}\n",
+			},
+			ExpectedValue: "\n#cloud-config\n# " + clusterName + "-" + machineName + "-3\n",
+		}),
+		Entry("Appended template to a CABPK cloud-config", testCaseAppendUserData{
+			UserDataAppend: &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:      "## template: jinja\n#cloud-config\n\nwrite_files:\n",
+			ConfigMap:      map[string]string{"userData": "bootcmd: []\n"},
+			ExpectedValue:  "## template: jinja\n#cloud-config\n\nwrite_files:\nbootcmd: []\n",
+		}),
+		Entry("Bootstrap data not a cloud-config", testCaseAppendUserData{
+			UserDataAppend:  &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:       `{"ignition":{"version":"3.0.0"}}`,
+			ConfigMap:       map[string]string{"userData": "bootcmd: []\n"},
+			ExpectedReason:  infrav1.UserDataNotCloudConfigReason,
+			ExpectedMessage: "bootstrap data in secret " + namespaceName + "/bootstrap is not a cloud-config",
+		}),
+		Entry("Missing ConfigMap", testCaseAppendUserData{
+			UserDataAppend:  &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:       "#cloud-config\n",
+			ExpectedReason:  infrav1.UserDataAppendRenderFailedReason,
+			ExpectedMessage: "failed to get the userDataAppend ConfigMap append",
+		}),
+		Entry("Missing key", testCaseAppendUserData{
+			UserDataAppend:  &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:       "#cloud-config\n",
+			ConfigMap:       map[string]string{"kargs": "bootcmd: []\n"},
+			ExpectedReason:  infrav1.UserDataAppendRenderFailedReason,
+			ExpectedMessage: "key userData not found in the userDataAppend ConfigMap append",
+		}),
+		Entry("Missing label", testCaseAppendUserData{
+			UserDataAppend:  &infrav1.UserDataAppend{Name: "append"},
+			Bootstrap:       "#cloud-config\n",
+			ConfigMap:       map[string]string{"userData": "# {{ .BareMetalHostLabels.zone }}\n"},
+			ExpectedReason:  infrav1.UserDataAppendRenderFailedReason,
+			ExpectedMessage: "failed to render the userDataAppend template",
+		}),
+	)
+
+	type testCaseDataTemplatePolicy struct {
+		Enforced        bool
+		Spec            infrav1.Metal3MachineSpec
+		ExpectRequeue   bool
+		ExpectCompliant bool
+		ExpectedMessage string
+	}
+
+	DescribeTable("Test the enforceDataTemplates policy",
+		func(tc testCaseDataTemplatePolicy) {
+			m3m := newMetal3Machine("myName", &tc.Spec, nil, nil)
+			m3m.Status.Conditions = clusterv1.Conditions{{
+				Type:     infrav1.PolicyCompliantCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityError,
+				Reason:   infrav1.DataTemplateRequiredReason,
+			}}
+			m3c := newMetal3Cluster(metal3ClusterName, nil, &infrav1.Metal3ClusterSpec{
+				EnforceDataTemplates: tc.Enforced,
+			}, nil)
+			machineMgr, err := NewMachineManager(fakeClient(), nil, m3c, &clusterv1.Machine{}, m3m,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = machineMgr.validateDataTemplatePolicy()
+			condition := conditions.Get(m3m, infrav1.PolicyCompliantCondition)
+			if tc.ExpectRequeue {
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(ReconcileError{}))
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityError))
+				Expect(condition.Reason).To(Equal(infrav1.DataTemplateRequiredReason))
+				Expect(condition.Message).To(Equal(tc.ExpectedMessage))
+
+				// The host selection is blocked with the same reason.
+				Expect(errors.Is(err, ErrBlocked)).To(BeTrue())
+				step := conditions.Get(m3m, infrav1.AssociateBMHCondition)
+				Expect(step).NotTo(BeNil())
+				Expect(step.Status).To(Equal(corev1.ConditionFalse))
+				Expect(step.Reason).To(Equal(infrav1.DataTemplateRequiredReason))
+				Expect(step.Severity).To(Equal(clusterv1.ConditionSeverityError))
+
+				// No BareMetalHost is chosen for the machine.
+				Expect(machineMgr.Associate(context.TODO())).To(BeAssignableToTypeOf(ReconcileError{}))
+				Expect(m3m.Annotations).NotTo(HaveKey(HostAnnotation))
+			} else if tc.ExpectCompliant {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conditions.IsTrue(m3m, infrav1.PolicyCompliantCondition)).To(BeTrue())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(condition).To(BeNil())
+			}
+		},
+		Entry("Not enforced, secrets", testCaseDataTemplatePolicy{
+			Spec: infrav1.Metal3MachineSpec{
+				MetaData: &corev1.SecretReference{Name: "metadata"},
+			},
+		}),
+		Entry("Enforced, data template", testCaseDataTemplatePolicy{
+			Enforced: true,
+			Spec: infrav1.Metal3MachineSpec{
+				DataTemplate: &corev1.ObjectReference{Name: "abc"},
+			},
+			ExpectCompliant: true,
+		}),
+		Entry("Enforced, secrets", testCaseDataTemplatePolicy{
+			Enforced: true,
+			Spec: infrav1.Metal3MachineSpec{
+				MetaData:    &corev1.SecretReference{Name: "metadata"},
+				NetworkData: &corev1.SecretReference{Name: "networkdata"},
 			},
+			ExpectRequeue: true,
+			ExpectedMessage: "[Spec.MetaData: Forbidden: the Metal3Cluster enforces data templates, use a dataTemplate instead, " +
+				"Spec.NetworkData: Forbidden: the Metal3Cluster enforces data templates, use a dataTemplate instead]",
 		}),
 	)
 
@@ -3491,6 +8325,7 @@ var _ = Describe("Metal3Machine manager", func() {
 		ExpectMetal3DataReadyConditionStatus bool
 		ExpectSecretStatus                   bool
 		expectClaim                          bool
+		expectClaimWatchLabel                string
 	}
 
 	DescribeTable("Test AssociateM3MetaData",
@@ -3499,6 +8334,15 @@ var _ = Describe("Metal3Machine manager", func() {
 			if tc.DataClaim != nil {
 				objects = append(objects, tc.DataClaim)
 			}
+			// The provided secrets are valid, the invalid ones are not used.
+			if tc.M3Machine.Spec.MetaData != nil {
+				objects = append(objects, newProvidedSecret(tc.M3Machine.Spec.MetaData.Name,
+					map[string][]byte{"metaData": []byte("abc")}))
+			}
+			if tc.M3Machine.Spec.NetworkData != nil {
+				objects = append(objects, newProvidedSecret(tc.M3Machine.Spec.NetworkData.Name,
+					map[string][]byte{"networkData": []byte("abc")}))
+			}
 			fakeCleint := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
 			machineMgr, err := NewMachineManager(fakeCleint, nil, nil, tc.Machine, tc.M3Machine,
 				logr.Discard(),
@@ -3532,6 +8376,7 @@ var _ = Describe("Metal3Machine manager", func() {
 					&dataTemplate,
 				)
 				Expect(err).NotTo(HaveOccurred())
+				Expect(dataTemplate.Labels[clusterv1.WatchLabel]).To(Equal(tc.expectClaimWatchLabel))
 			}
 		},
 		Entry("Should return nil if No Spec available", testCaseM3MetaData{
@@ -3555,6 +8400,22 @@ var _ = Describe("Metal3Machine manager", func() {
 			Machine:     newMachine(machineName, nil),
 			expectClaim: true,
 		}),
+		Entry("Should expect DataClaim to inherit the Machine watch-filter label", testCaseM3MetaData{
+			M3Machine: newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
+				DataTemplate: &corev1.ObjectReference{Name: "abcd"},
+			}, nil, nil),
+			Machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      machineName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						clusterv1.WatchLabel: "shard-a",
+					},
+				},
+			},
+			expectClaim:           true,
+			expectClaimWatchLabel: "shard-a",
+		}),
 		Entry("Should not be an error if DataClaim exists", testCaseM3MetaData{
 			M3Machine: newMetal3Machine("myName", &infrav1.Metal3MachineSpec{
 				DataTemplate: &corev1.ObjectReference{Name: "abcd"},
@@ -4088,6 +8949,142 @@ var _ = Describe("Metal3Machine manager", func() {
 		}),
 	)
 
+	type testCaseNodeReuseLabelValue struct {
+		Scope         infrav1.NodeReuseScope
+		LabelKeys     []string
+		MachineLabels map[string]string
+		MachineOwners []metav1.OwnerReference
+		ExpectedValue string
+	}
+	DescribeTable("Test nodeReuseLabelValue",
+		func(tc testCaseNodeReuseLabelValue) {
+			m3mTemplate := &infrav1.Metal3MachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: namespaceName},
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					NodeReuseScope:     tc.Scope,
+					NodeReuseLabelKeys: tc.LabelKeys,
+				},
+			}
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil)
+			m3m.Annotations = map[string]string{clusterv1.TemplateClonedFromNameAnnotation: m3mTemplate.Name}
+			machine := newMachine(machineName, nil)
+			machine.Spec.ClusterName = clusterName
+			machine.Labels = tc.MachineLabels
+			machine.OwnerReferences = tc.MachineOwners
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3mTemplate).Build()
+
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, machine, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			value, err := machineMgr.nodeReuseLabelValue(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(tc.ExpectedValue))
+		},
+		Entry("Owner scope of a controlplane machine", testCaseNodeReuseLabelValue{
+			Scope:         infrav1.NodeReuseScopeOwner,
+			MachineLabels: map[string]string{clusterv1.MachineControlPlaneLabel: ""},
+			MachineOwners: []metav1.OwnerReference{{
+				APIVersion: controlplanev1.GroupVersion.String(),
+				Kind:       "KubeadmControlPlane",
+				Name:       "test1",
+			}},
+			ExpectedValue: "kcp-test1",
+		}),
+		Entry("Cluster scope", testCaseNodeReuseLabelValue{
+			Scope:         infrav1.NodeReuseScopeCluster,
+			MachineLabels: map[string]string{clusterv1.MachineDeploymentNameLabel: "workers"},
+			ExpectedValue: "cluster-" + clusterName,
+		}),
+		Entry("Labels scope", testCaseNodeReuseLabelValue{
+			Scope:         infrav1.NodeReuseScopeLabels,
+			LabelKeys:     []string{"pool", "zone"},
+			MachineLabels: map[string]string{"zone": "a", "pool": "gpu", "other": "x"},
+			ExpectedValue: nodeReuseLabelsValue(map[string]string{"pool": "gpu", "zone": "a"}, []string{"zone", "pool"}),
+		}),
+	)
+
+	type testCaseRecordTemplateHash struct {
+		Annotations      map[string]string
+		TemplateMissing  bool
+		Drifted          bool
+		ExpectedRecorded bool
+	}
+	DescribeTable("Test RecordTemplateHash",
+		func(tc testCaseRecordTemplateHash) {
+			templateSpec := infrav1.Metal3MachineSpec{
+				Image: infrav1.Image{URL: "http://abc.com/image", Checksum: "http://abc.com/image.sha256sum"},
+			}
+			m3mTemplate := &infrav1.Metal3MachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: namespaceName, Generation: 3},
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{Spec: templateSpec},
+				},
+			}
+			m3mSpec := templateSpec.DeepCopy()
+			if tc.Drifted {
+				m3mSpec.Image.URL = "http://abc.com/image-1"
+			}
+			m3m := newMetal3Machine(metal3machineName, m3mSpec, nil, nil)
+			m3m.Annotations = map[string]string{clusterv1.TemplateClonedFromNameAnnotation: m3mTemplate.Name}
+			for key, value := range tc.Annotations {
+				m3m.Annotations[key] = value
+			}
+			expectedAnnotations := map[string]string{}
+			for key, value := range m3m.Annotations {
+				expectedAnnotations[key] = value
+			}
+			objects := []client.Object{}
+			if !tc.TemplateMissing {
+				objects = append(objects, m3mTemplate)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			machineMgr, err := NewMachineManager(fakeClient, nil, nil, nil, m3m, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.RecordTemplateHash(context.TODO())).To(Succeed())
+			if tc.ExpectedRecorded {
+				hash, err := templateSpecHash(m3mTemplate)
+				Expect(err).NotTo(HaveOccurred())
+				expectedAnnotations[infrav1.TemplateGenerationAnnotation] = "3"
+				expectedAnnotations[infrav1.TemplateHashAnnotation] = hash
+			}
+			Expect(m3m.Annotations).To(Equal(expectedAnnotations))
+		},
+		Entry("Hash recorded on the first reconciliation", testCaseRecordTemplateHash{
+			ExpectedRecorded: true,
+		}),
+		Entry("Hash already recorded", testCaseRecordTemplateHash{
+			Annotations: map[string]string{
+				infrav1.TemplateGenerationAnnotation: "1",
+				infrav1.TemplateHashAnnotation:       "old",
+			},
+		}),
+		Entry("Metal3MachineTemplate not found", testCaseRecordTemplateHash{
+			TemplateMissing: true,
+		}),
+		Entry("Metal3Machine differing from the template", testCaseRecordTemplateHash{
+			Drifted: true,
+		}),
+	)
+
+	It("Hashes the values of the node reuse label keys", func() {
+		value := nodeReuseLabelsValue(map[string]string{"pool": "gpu"}, []string{"pool"})
+		Expect(value).To(MatchRegexp("^labels-[0-9a-f]{8}$"))
+		Expect(nodeReuseLabelsValue(map[string]string{"pool": "cpu"}, []string{"pool"})).NotTo(Equal(value))
+		Expect(nodeReuseLabelsValue(map[string]string{"pool": "gpu", "zone": "a"}, []string{"pool"})).To(Equal(value))
+	})
+
+	It("Truncates the node reuse label value of a long cluster name", func() {
+		Expect(nodeReuseClusterValue(clusterName)).To(Equal("cluster-" + clusterName))
+		longName := strings.Repeat("a", 63)
+		value := nodeReuseClusterValue(longName)
+		Expect(value).To(HaveLen(validation.LabelValueMaxLength))
+		Expect(validation.IsValidLabelValue(value)).To(BeEmpty())
+		Expect(value).To(MatchRegexp("^cluster-a+-[0-9a-f]{8}$"))
+		Expect(nodeReuseClusterValue(strings.Repeat("a", 62) + "b")).NotTo(Equal(value))
+	})
+
 	type testCaseNodeReuseLabelExists struct {
 		Host                 *bmov1alpha1.BareMetalHost
 		expectNodeReuseLabel bool
@@ -4146,6 +9143,356 @@ var _ = Describe("Metal3Machine manager", func() {
 		}),
 	)
 
+	Describe("Test node reuse group", func() {
+		const reuseGroup = "workers"
+
+		newReuseTemplate := func(nodeReuse bool) *infrav1.Metal3MachineTemplate {
+			return &infrav1.Metal3MachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "green-template",
+					Namespace: namespaceName,
+				},
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: infrav1.Metal3MachineSpec{
+							NodeReuseGroup: reuseGroup,
+						},
+					},
+					NodeReuse: nodeReuse,
+				},
+			}
+		}
+		newReuseMachineSet := func(mdName string) *clusterv1.MachineSet {
+			return &clusterv1.MachineSet{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       "MachineSet",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      mdName + "-ms",
+					Namespace: namespaceName,
+					UID:       types.UID(mdName + "-ms-uid"),
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "MachineDeployment",
+							Name:       mdName,
+						},
+					},
+				},
+			}
+		}
+		newReuseMachine := func(mdName string) *clusterv1.Machine {
+			return &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      machineName,
+					Namespace: namespaceName,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "MachineSet",
+							Name:       mdName + "-ms",
+							UID:        types.UID(mdName + "-ms-uid"),
+						},
+					},
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						DataSecretName: pointer.String(metal3machineName + "-user-data"),
+					},
+				},
+			}
+		}
+		newReuseM3Machine := func(group string) *infrav1.Metal3Machine {
+			return newMetal3Machine(metal3machineName, &infrav1.Metal3MachineSpec{NodeReuseGroup: group}, m3mSecretStatus(),
+				&metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					Labels: map[string]string{
+						clusterv1.ClusterNameLabel: clusterName,
+					},
+					Annotations: map[string]string{
+						HostAnnotation: namespaceName + "/" + baremetalhostName,
+						clusterv1.TemplateClonedFromNameAnnotation:      "green-template",
+						clusterv1.TemplateClonedFromGroupKindAnnotation: infrav1.ClonedFromGroupKind,
+					},
+				},
+			)
+		}
+		newReuseHost := func(name, label string, state bmov1alpha1.ProvisioningState) *bmov1alpha1.BareMetalHost {
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespaceName,
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: state,
+					},
+				},
+			}
+			if label != "" {
+				host.Labels = map[string]string{nodeReuseLabelName: label}
+			}
+			return host
+		}
+
+		It("Labels the released host with the node reuse group", func() {
+			host := newBareMetalHost(baremetalhostName,
+				&bmov1alpha1.BareMetalHostSpec{
+					ConsumerRef: consumerRef(),
+					Online:      false,
+				},
+				bmov1alpha1.StateNone,
+				&bmov1alpha1.BareMetalHostStatus{}, false, "metadata", true, "")
+			m3Machine := newReuseM3Machine(reuseGroup)
+			objects := []client.Object{
+				host, m3Machine, newReuseTemplate(true), newReuseMachineSet("blue"), newSecret(),
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil,
+				newReuseMachine("blue"), m3Machine, logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(machineMgr.Delete(context.TODO())).To(Succeed())
+
+			savedHost := bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), &savedHost)).To(Succeed())
+			Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+			Expect(savedHost.Labels[nodeReuseLabelName]).To(Equal(reuseGroup))
+			Expect(savedHost.Annotations).To(HaveKey(infrav1.HostNodeReuseSinceAnnotation))
+		})
+
+		type testCaseNodeReuseGroup struct {
+			Group            string
+			NodeReuse        bool
+			Hosts            []*bmov1alpha1.BareMetalHost
+			ExpectedHostName string
+		}
+
+		DescribeTable("Test chooseHost during a rollout across MachineDeployments",
+			func(tc testCaseNodeReuseGroup) {
+				objects := []client.Object{newReuseTemplate(tc.NodeReuse), newReuseMachineSet("green")}
+				for _, host := range tc.Hosts {
+					objects = append(objects, host)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				machineMgr, err := NewMachineManager(fakeClient, nil, nil,
+					newReuseMachine("green"), newReuseM3Machine(tc.Group), logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, _, err := machineMgr.chooseHost(context.TODO())
+				if tc.ExpectedHostName == "" {
+					Expect(result).To(BeNil())
+					Expect(err).To(HaveOccurred())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Name).To(Equal(tc.ExpectedHostName))
+			},
+			Entry("Picks the host released by the other MachineDeployment of the group", testCaseNodeReuseGroup{
+				Group:     reuseGroup,
+				NodeReuse: true,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("released", reuseGroup, bmov1alpha1.StateAvailable),
+					newReuseHost("free", "", bmov1alpha1.StateAvailable),
+					newReuseHost("reserved", "others", bmov1alpha1.StateAvailable),
+				},
+				ExpectedHostName: "released",
+			}),
+			Entry("Waits for the released host while it is deprovisioning", testCaseNodeReuseGroup{
+				Group:     reuseGroup,
+				NodeReuse: true,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("released", reuseGroup, bmov1alpha1.StateDeprovisioning),
+					newReuseHost("free", "", bmov1alpha1.StateAvailable),
+				},
+			}),
+			Entry("Falls back to an unlabeled host when node reuse is disabled", testCaseNodeReuseGroup{
+				Group: reuseGroup,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("released", reuseGroup, bmov1alpha1.StateDeprovisioning),
+					newReuseHost("free", "", bmov1alpha1.StateAvailable),
+				},
+				ExpectedHostName: "free",
+			}),
+			Entry("Uses an unlabeled host when no host was released for the group", testCaseNodeReuseGroup{
+				Group:     reuseGroup,
+				NodeReuse: true,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("free", "", bmov1alpha1.StateAvailable),
+				},
+				ExpectedHostName: "free",
+			}),
+			Entry("Never picks a host labeled for another group", testCaseNodeReuseGroup{
+				Group:     reuseGroup,
+				NodeReuse: true,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("reserved", "others", bmov1alpha1.StateAvailable),
+					newReuseHost("md-green", "md-green", bmov1alpha1.StateAvailable),
+				},
+			}),
+			Entry("Uses the MachineDeployment name without node reuse group", testCaseNodeReuseGroup{
+				NodeReuse: true,
+				Hosts: []*bmov1alpha1.BareMetalHost{
+					newReuseHost("released", reuseGroup, bmov1alpha1.StateAvailable),
+					newReuseHost("md-green", "md-green", bmov1alpha1.StateAvailable),
+				},
+				ExpectedHostName: "md-green",
+			}),
+		)
+
+		type testCaseLegacyLabels struct {
+			Labels           map[string]string
+			NoLegacyKeys     bool
+			ExpectedHostName string
+		}
+
+		DescribeTable("Test a node reuse cycle with legacy label keys",
+			func(tc testCaseLegacyLabels) {
+				if !tc.NoLegacyKeys {
+					LegacyLabelKeys = testLegacyLabelKeys
+					defer func() { LegacyLabelKeys = nil }()
+				}
+
+				released := newReuseHost(baremetalhostName, "", bmov1alpha1.StateAvailable)
+				released.Labels = tc.Labels
+				machine := newReuseMachine("green")
+				machine.Spec.ClusterName = clusterName
+				m3Machine := newReuseM3Machine(reuseGroup)
+				m3Machine.Kind = "Metal3Machine"
+				objects := []client.Object{
+					released, m3Machine, newReuseTemplate(true), newReuseMachineSet("green"),
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+				machineMgr, err := NewMachineManager(fakeClient, newCluster(clusterName), nil,
+					machine, m3Machine, logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				host, _, err := machineMgr.chooseHost(context.TODO())
+				if tc.ExpectedHostName == "" {
+					Expect(err).To(HaveOccurred())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(host.Name).To(Equal(tc.ExpectedHostName))
+				if tc.NoLegacyKeys {
+					return
+				}
+
+				// Association writes the current keys only.
+				Expect(machineMgr.setHostConsumerRef(context.TODO(), host)).To(Succeed())
+				Expect(machineMgr.setHostLabel(context.TODO(), host)).To(Succeed())
+				Expect(host.Labels).To(Equal(map[string]string{clusterv1.ClusterNameLabel: clusterName}))
+				Expect(fakeClient.Update(context.TODO(), host)).To(Succeed())
+
+				// The released host is labeled with the current key for reuse.
+				Expect(machineMgr.Delete(context.TODO())).To(Succeed())
+				savedHost := bmov1alpha1.BareMetalHost{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), &savedHost)).To(Succeed())
+				Expect(savedHost.Spec.ConsumerRef).To(BeNil())
+				Expect(savedHost.Labels).To(Equal(map[string]string{nodeReuseLabelName: reuseGroup}))
+			},
+			Entry("Reuses a host with the current keys", testCaseLegacyLabels{
+				Labels: map[string]string{
+					nodeReuseLabelName:         reuseGroup,
+					clusterv1.ClusterNameLabel: clusterName,
+				},
+				ExpectedHostName: baremetalhostName,
+			}),
+			Entry("Reuses a host with the legacy keys", testCaseLegacyLabels{
+				Labels: map[string]string{
+					testLegacyNodeReuseLabel:   reuseGroup,
+					testLegacyClusterNameLabel: clusterName,
+				},
+				ExpectedHostName: baremetalhostName,
+			}),
+			Entry("Reuses a host with mixed keys", testCaseLegacyLabels{
+				Labels: map[string]string{
+					testLegacyNodeReuseLabel:   reuseGroup,
+					clusterv1.ClusterNameLabel: clusterName,
+					testLegacyClusterNameLabel: "other-cluster",
+				},
+				ExpectedHostName: baremetalhostName,
+			}),
+			Entry("Never picks a host labeled for another group with the legacy key", testCaseLegacyLabels{
+				Labels: map[string]string{
+					testLegacyNodeReuseLabel: "others",
+				},
+			}),
+			Entry("Ignores the legacy keys when none is configured", testCaseLegacyLabels{
+				Labels: map[string]string{
+					testLegacyNodeReuseLabel: "others",
+				},
+				NoLegacyKeys:     true,
+				ExpectedHostName: baremetalhostName,
+			}),
+		)
+
+		DescribeTable("Test migrateLegacyLabels",
+			func(labels map[string]string, expectedChanged bool, expectedLabels map[string]string) {
+				LegacyLabelKeys = testLegacyLabelKeys
+				defer func() { LegacyLabelKeys = nil }()
+				Expect(migrateLegacyLabels(labels)).To(Equal(expectedChanged))
+				Expect(labels).To(Equal(expectedLabels))
+			},
+			Entry("No labels", map[string]string{}, false, map[string]string{}),
+			Entry("Current keys",
+				map[string]string{nodeReuseLabelName: reuseGroup, "foo": "bar"}, false,
+				map[string]string{nodeReuseLabelName: reuseGroup, "foo": "bar"},
+			),
+			Entry("Legacy keys",
+				map[string]string{testLegacyNodeReuseLabel: reuseGroup, testLegacyClusterNameLabel: clusterName}, true,
+				map[string]string{nodeReuseLabelName: reuseGroup, clusterv1.ClusterNameLabel: clusterName},
+			),
+			Entry("The current key wins over the legacy key",
+				map[string]string{nodeReuseLabelName: reuseGroup, testLegacyNodeReuseLabel: "others"}, true,
+				map[string]string{nodeReuseLabelName: reuseGroup},
+			),
+		)
+
+		It("Reports the hosts with legacy label keys", func() {
+			LegacyLabelKeys = testLegacyLabelKeys
+			defer func() { LegacyLabelKeys = nil }()
+			legacy := newReuseHost("legacy", "", bmov1alpha1.StateAvailable)
+			legacy.Labels = map[string]string{testLegacyNodeReuseLabel: reuseGroup}
+			mixed := newReuseHost("mixed", reuseGroup, bmov1alpha1.StateAvailable)
+			mixed.Labels[testLegacyClusterNameLabel] = clusterName
+			objects := []client.Object{
+				legacy, mixed, newReuseHost("current", reuseGroup, bmov1alpha1.StateAvailable),
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+
+			count, err := AuditLegacyLabels(context.TODO(), fakeClient, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(2))
+			Expect(legacyLabels(mixed.Labels)).To(Equal([]string{testLegacyClusterNameLabel}))
+		})
+
+		DescribeTable("Test SetLegacyLabelKeys",
+			func(keys map[string]string, expectError bool) {
+				defer func() { LegacyLabelKeys = nil }()
+				err := SetLegacyLabelKeys(keys)
+				if expectError {
+					Expect(err).To(HaveOccurred())
+					Expect(LegacyLabelKeys).To(BeNil())
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(LegacyLabelKeys).To(Equal(keys))
+			},
+			Entry("No legacy keys", map[string]string{}, false),
+			Entry("Node reuse and cluster name keys", testLegacyLabelKeys, false),
+			Entry("Other current key", map[string]string{testLegacyNodeReuseLabel: "foo"}, true),
+			Entry("Legacy key equal to the current key",
+				map[string]string{nodeReuseLabelName: nodeReuseLabelName}, true,
+			),
+		)
+	})
+
 	type testCaseGetKubeadmControlPlaneName struct {
 		Machine         *clusterv1.Machine
 		expectedKcp     bool
@@ -4892,6 +10239,17 @@ func newSecret() *corev1.Secret {
 	}
 }
 
+func newProvidedSecret(name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespaceName,
+		},
+		Data: data,
+		Type: "Opaque",
+	}
+}
+
 func filterCondition(conditions clusterv1.Conditions, conditionType clusterv1.ConditionType) []clusterv1.Condition {
 	filtered := []clusterv1.Condition{}
 	for i := range conditions {
@@ -0,0 +1,510 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// MachinePoolManagerInterface is an interface for a MachinePoolManager.
+type MachinePoolManagerInterface interface {
+	SetFinalizer()
+	UnsetFinalizer()
+	IsBootstrapReady() bool
+	ReconcileHosts(context.Context) error
+	UpdateProviderIDs(context.Context, ClientGetter) error
+	Delete(context.Context) error
+}
+
+// MachinePoolManager is responsible for performing machine pool reconciliation.
+// Each replica of the MachinePool is a BareMetalHost consumed by the
+// Metal3MachinePool, the pool has no Metal3Machine.
+type MachinePoolManager struct {
+	client client.Client
+
+	Cluster           *clusterv1.Cluster
+	MachinePool       *expv1.MachinePool
+	Metal3MachinePool *infrav1.Metal3MachinePool
+	Log               logr.Logger
+}
+
+// NewMachinePoolManager returns a new helper for managing a machine pool.
+func NewMachinePoolManager(client client.Client,
+	cluster *clusterv1.Cluster, machinePool *expv1.MachinePool,
+	metal3MachinePool *infrav1.Metal3MachinePool,
+	machinePoolLog logr.Logger) (*MachinePoolManager, error) {
+	return &MachinePoolManager{
+		client: client,
+
+		Cluster:           cluster,
+		MachinePool:       machinePool,
+		Metal3MachinePool: metal3MachinePool,
+		Log:               machinePoolLog,
+	}, nil
+}
+
+// SetFinalizer sets finalizer.
+func (m *MachinePoolManager) SetFinalizer() {
+	controllerutil.AddFinalizer(m.Metal3MachinePool, infrav1.MachinePoolFinalizer)
+}
+
+// UnsetFinalizer unsets finalizer.
+func (m *MachinePoolManager) UnsetFinalizer() {
+	controllerutil.RemoveFinalizer(m.Metal3MachinePool, infrav1.MachinePoolFinalizer)
+}
+
+// IsBootstrapReady checks if the bootstrap data of the MachinePool is ready.
+func (m *MachinePoolManager) IsBootstrapReady() bool {
+	return m.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName != nil
+}
+
+// desiredReplicas returns the replicas of the MachinePool, one if unset as
+// defaulted by Cluster API.
+func (m *MachinePoolManager) desiredReplicas() int {
+	if m.MachinePool.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*m.MachinePool.Spec.Replicas)
+}
+
+// ReconcileHosts associates or releases BareMetalHosts until as many of them
+// as the replicas of the MachinePool are provisioned for the pool. The
+// released hosts are deprovisioned before their consumerRef is removed. It
+// returns a transient ErrNoAvailableHost when too few hosts are available.
+func (m *MachinePoolManager) ReconcileHosts(ctx context.Context) error {
+	hosts, err := m.poolHosts(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The hosts whose image was removed are being released.
+	active := []*bmov1alpha1.BareMetalHost{}
+	releasing := 0
+	for _, host := range hosts {
+		if host.Spec.Image != nil || host.Spec.CustomDeploy != nil {
+			active = append(active, host)
+			continue
+		}
+		released, err := m.releaseHost(ctx, host)
+		if err != nil {
+			return err
+		}
+		if !released {
+			releasing++
+		}
+	}
+
+	desired := m.desiredReplicas()
+	if len(active) > desired {
+		sort.SliceStable(active, func(i, j int) bool {
+			return lessScaleDown(active[i], active[j])
+		})
+		for _, host := range active[desired:] {
+			m.Log.Info("Scaling down, releasing host", "host", host.Name)
+			if _, err := m.releaseHost(ctx, host); err != nil {
+				return err
+			}
+			releasing++
+		}
+		active = active[:desired]
+	}
+
+	var scaleUpErr error
+	if missing := desired - len(active); missing > 0 {
+		candidates, err := m.availableHosts(ctx)
+		if err != nil {
+			return err
+		}
+		associated := 0
+		for _, host := range candidates {
+			if associated == missing {
+				break
+			}
+			m.Log.Info("Scaling up, associating host", "host", host.Name)
+			claimed, err := m.associateHost(ctx, host)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				m.Log.Info("Host was claimed by another consumer", "host", host.Name)
+				continue
+			}
+			active = append(active, host)
+			associated++
+		}
+		if associated < missing {
+			scaleUpErr = WithTransientError(fmt.Errorf("%w: %d of %d BareMetalHost(s) associated with the Metal3MachinePool",
+				ErrNoAvailableHost, len(active), desired), requeueAfter)
+		}
+	}
+
+	m.Metal3MachinePool.Status.Hosts = []string{}
+	for _, host := range active {
+		m.Metal3MachinePool.Status.Hosts = append(m.Metal3MachinePool.Status.Hosts, host.Name)
+	}
+	sort.Strings(m.Metal3MachinePool.Status.Hosts)
+	m.Metal3MachinePool.Status.Ready = len(active) == desired
+
+	switch {
+	case scaleUpErr != nil:
+		conditions.MarkFalse(m.Metal3MachinePool, infrav1.HostsAssociatedCondition, infrav1.NoAvailableHostReason,
+			clusterv1.ConditionSeverityWarning, "%d of %d BareMetalHost(s) associated", len(active), desired)
+	case releasing > 0:
+		conditions.MarkFalse(m.Metal3MachinePool, infrav1.HostsAssociatedCondition, infrav1.ScalingDownReason,
+			clusterv1.ConditionSeverityInfo, "%d BareMetalHost(s) deprovisioning", releasing)
+	default:
+		conditions.MarkTrue(m.Metal3MachinePool, infrav1.HostsAssociatedCondition)
+	}
+	if scaleUpErr != nil {
+		return scaleUpErr
+	}
+	if releasing > 0 {
+		return WithTransientError(errors.New("Deprovisioning BareMetalHosts released by the Metal3MachinePool, requeuing"), requeueAfter)
+	}
+	return nil
+}
+
+// Delete releases all the BareMetalHosts of the pool. It returns a transient
+// error until they are all deprovisioned and their consumerRef removed.
+func (m *MachinePoolManager) Delete(ctx context.Context) error {
+	hosts, err := m.poolHosts(ctx)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, host := range hosts {
+		released, err := m.releaseHost(ctx, host)
+		if err != nil {
+			return err
+		}
+		if !released {
+			remaining++
+		}
+	}
+	m.Metal3MachinePool.Spec.ProviderIDList = nil
+	m.Metal3MachinePool.Status.Replicas = 0
+	m.Metal3MachinePool.Status.Ready = false
+	if remaining > 0 {
+		return WithTransientError(fmt.Errorf("waiting for %d BareMetalHost(s) of the Metal3MachinePool to be deprovisioned", remaining), requeueAfter)
+	}
+	m.Metal3MachinePool.Status.Hosts = nil
+	return nil
+}
+
+// UpdateProviderIDs sets the providerID of the Nodes of the provisioned
+// BareMetalHosts of the pool, found by their metal3.io/uuid label, and
+// records them in the providerIDList. The Nodes whose providerID was set by
+// a cloud provider are recorded as is.
+func (m *MachinePoolManager) UpdateProviderIDs(ctx context.Context, clientFactory ClientGetter) error {
+	hosts, err := m.poolHosts(ctx)
+	if err != nil {
+		return err
+	}
+	corev1Remote, err := clientFactory(ctx, m.client, m.Cluster)
+	if err != nil {
+		return errors.Wrap(err, "Error creating a remote client")
+	}
+
+	providerIDs := []string{}
+	for _, host := range hosts {
+		if host.Spec.Image == nil && host.Spec.CustomDeploy == nil {
+			continue
+		}
+		if host.Status.Provisioning.State != bmov1alpha1.StateProvisioned {
+			continue
+		}
+		nodes, err := corev1Remote.Nodes().List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", ProviderLabelPrefix, host.UID),
+		})
+		if err != nil {
+			return WithTransientError(errors.Wrap(err, "error retrieving node, requeuing"), requeueAfter)
+		}
+		if len(nodes.Items) == 0 {
+			// The node is still bootstrapping.
+			m.Log.Info("Node of the host not found yet", "host", host.Name)
+			continue
+		}
+		if len(nodes.Items) > 1 {
+			return fmt.Errorf("found multiple target nodes with the label %s=%s", ProviderLabelPrefix, host.UID)
+		}
+		node := nodes.Items[0]
+		if node.Spec.ProviderID == "" {
+			node.Spec.ProviderID = fmt.Sprintf("%s%s/%s/%s", ProviderIDPrefix, host.Namespace, host.Name, m.Metal3MachinePool.Name)
+			patch := fmt.Sprintf(`{"spec":{"providerID":%q}}`, node.Spec.ProviderID)
+			if _, err := corev1Remote.Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+				return errors.Wrap(err, "unable to update the target node with providerID")
+			}
+			m.Log.Info("ProviderID set on target node", "node", node.Name, "providerID", node.Spec.ProviderID)
+		}
+		providerIDs = append(providerIDs, node.Spec.ProviderID)
+	}
+	sort.Strings(providerIDs)
+	m.Metal3MachinePool.Spec.ProviderIDList = providerIDs
+	m.Metal3MachinePool.Status.Replicas = int32(len(providerIDs))
+	return nil
+}
+
+// poolHosts returns the BareMetalHosts consumed by the Metal3MachinePool,
+// sorted by name.
+func (m *MachinePoolManager) poolHosts(ctx context.Context) ([]*bmov1alpha1.BareMetalHost, error) {
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := m.client.List(ctx, &hosts, client.InNamespace(m.Metal3MachinePool.Namespace)); err != nil {
+		return nil, err
+	}
+	poolHosts := []*bmov1alpha1.BareMetalHost{}
+	for i := range hosts.Items {
+		if poolConsumerRefMatches(hosts.Items[i].Spec.ConsumerRef, m.Metal3MachinePool) {
+			poolHosts = append(poolHosts, &hosts.Items[i])
+		}
+	}
+	sort.Slice(poolHosts, func(i, j int) bool { return poolHosts[i].Name < poolHosts[j].Name })
+	return poolHosts, nil
+}
+
+// availableHosts returns the BareMetalHosts that can be associated with the
+// pool, the preferred ones first then sorted by name: not consumed, ready for
+// provisioning, without error, pause, detached, unhealthy or taint
+// annotation, and matching the hostSelector of the template.
+func (m *MachinePoolManager) availableHosts(ctx context.Context) ([]*bmov1alpha1.BareMetalHost, error) {
+	selector := m.Metal3MachinePool.Spec.Template.HostSelector
+	reqs, err := hostSelectorRequirements(selector)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector := labels.NewSelector().Add(reqs...)
+
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := m.client.List(ctx, &hosts, client.InNamespace(m.Metal3MachinePool.Namespace)); err != nil {
+		return nil, err
+	}
+	available := []*bmov1alpha1.BareMetalHost{}
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		if host.Spec.ConsumerRef != nil || host.GetDeletionTimestamp() != nil || host.Status.ErrorMessage != "" {
+			continue
+		}
+		switch host.Status.Provisioning.State {
+		case bmov1alpha1.StateReady, bmov1alpha1.StateAvailable:
+		default:
+			continue
+		}
+		annotations := host.GetAnnotations()
+		if _, ok := annotations[bmov1alpha1.PausedAnnotation]; ok {
+			continue
+		}
+		if _, ok := annotations[infrav1.UnhealthyAnnotation]; ok {
+			continue
+		}
+		if _, ok := annotations[bmov1alpha1.DetachedAnnotation]; ok {
+			continue
+		}
+		// The pool tolerates no taint, and the hosts reserved for node reuse
+		// belong to a KubeadmControlPlane or MachineDeployment.
+		if len(hostTaints(host)) != 0 {
+			continue
+		}
+		if _, ok := lookupLabel(host.Labels, nodeReuseLabelName); ok {
+			continue
+		}
+		if !labelSelector.Matches(labels.Set(host.Labels)) {
+			continue
+		}
+		if selector.MatchHardware != nil {
+			if host.Status.HardwareDetails == nil {
+				continue
+			}
+			if criterion, _ := hardwareMismatch(selector.MatchHardware, host.Status.HardwareDetails); criterion != "" {
+				continue
+			}
+		}
+		available = append(available, host)
+	}
+	// The hosts preferred by the hostSelector come first.
+	sort.Slice(available, func(i, j int) bool {
+		iScore := hostScore(selector.Preferences, available[i])
+		jScore := hostScore(selector.Preferences, available[j])
+		if iScore != jScore {
+			return iScore > jScore
+		}
+		return available[i].Name < available[j].Name
+	})
+	return available, nil
+}
+
+// associateHost claims the host for the pool and provisions it with the
+// image of the template and the bootstrap data of the MachinePool. It returns
+// false if the host was claimed by another consumer first.
+func (m *MachinePoolManager) associateHost(ctx context.Context, host *bmov1alpha1.BareMetalHost) (bool, error) {
+	claimed, err := claimHost(ctx, m.client, host, &corev1.ObjectReference{
+		Kind:       "Metal3MachinePool",
+		Name:       m.Metal3MachinePool.Name,
+		Namespace:  m.Metal3MachinePool.Namespace,
+		APIVersion: infrav1.GroupVersion.String(),
+		UID:        m.Metal3MachinePool.UID,
+	})
+	if err != nil || !claimed {
+		return false, err
+	}
+	helper, err := patch.NewHelper(host, m.client)
+	if err != nil {
+		return false, err
+	}
+	template := m.Metal3MachinePool.Spec.Template
+	if template.CustomDeploy != nil {
+		host.Spec.CustomDeploy = &bmov1alpha1.CustomDeploy{Method: template.CustomDeploy.Method}
+	} else {
+		host.Spec.Image = &bmov1alpha1.Image{
+			URL:          template.Image.URL,
+			Checksum:     template.Image.Checksum,
+			ChecksumType: bmov1alpha1.ChecksumType(template.Image.EffectiveChecksumType()),
+			DiskFormat:   template.Image.DiskFormat,
+		}
+	}
+	if dataSecretName := m.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName; dataSecretName != nil {
+		host.Spec.UserData = &corev1.SecretReference{
+			Name:      *dataSecretName,
+			Namespace: m.MachinePool.Namespace,
+		}
+	}
+	if template.AutomatedCleaningMode != nil {
+		host.Spec.AutomatedCleaningMode = bmov1alpha1.AutomatedCleaningMode(*template.AutomatedCleaningMode)
+	}
+	host.Spec.Online = true
+	if host.Labels == nil {
+		host.Labels = map[string]string{}
+	}
+	migrateLegacyLabels(host.Labels)
+	host.Labels[clusterv1.ClusterNameLabel] = m.MachinePool.Spec.ClusterName
+	if host.Annotations == nil {
+		host.Annotations = map[string]string{}
+	}
+	host.Annotations[infrav1.HostProvisionCountAnnotation] = strconv.Itoa(hostProvisionCount(host) + 1)
+	return true, helper.Patch(ctx, host)
+}
+
+// releaseHost deprovisions a host of the pool, and removes its consumerRef
+// once it is deprovisioned. It returns whether the host was released.
+func (m *MachinePoolManager) releaseHost(ctx context.Context, host *bmov1alpha1.BareMetalHost) (bool, error) {
+	helper, err := patch.NewHelper(host, m.client)
+	if err != nil {
+		return false, err
+	}
+	released := false
+	switch {
+	case host.Spec.Image != nil || host.Spec.CustomDeploy != nil || host.Spec.UserData != nil:
+		host.Spec.Image = nil
+		host.Spec.CustomDeploy = nil
+		host.Spec.UserData = nil
+		// As for the hosts of the Metal3Machines, the host is kept powered
+		// on after the cleaning only with fast track.
+		host.Spec.Online = host.Spec.AutomatedCleaningMode != bmov1alpha1.CleaningModeDisabled && Capm3FastTrack == "true"
+	case hostDeprovisioned(host):
+		host.Spec.ConsumerRef = nil
+		deleteLabel(host.Labels, clusterv1.ClusterNameLabel)
+		if host.Annotations == nil {
+			host.Annotations = map[string]string{}
+		}
+		host.Annotations[infrav1.HostLastReleasedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		released = true
+	default:
+		return false, nil
+	}
+	if err := patchIfFound(ctx, helper, host); err != nil {
+		return false, err
+	}
+	return released, nil
+}
+
+// hostDeprovisioned returns whether the host is no longer provisioned.
+func hostDeprovisioned(host *bmov1alpha1.BareMetalHost) bool {
+	switch host.Status.Provisioning.State {
+	case bmov1alpha1.StateRegistering,
+		bmov1alpha1.StateMatchProfile, bmov1alpha1.StateInspecting,
+		bmov1alpha1.StateReady, bmov1alpha1.StateAvailable, bmov1alpha1.StateNone,
+		bmov1alpha1.StateUnmanaged:
+		return true
+	}
+	return false
+}
+
+// lessScaleDown orders the hosts of a pool in the order they are kept on a
+// scale down: the provisioned hosts first, then by name.
+func lessScaleDown(a, b *bmov1alpha1.BareMetalHost) bool {
+	aProvisioned := a.Status.Provisioning.State == bmov1alpha1.StateProvisioned
+	bProvisioned := b.Status.Provisioning.State == bmov1alpha1.StateProvisioned
+	if aProvisioned != bProvisioned {
+		return aProvisioned
+	}
+	return a.Name < b.Name
+}
+
+// poolConsumerRefMatches returns whether the consumerRef of a host
+// references the Metal3MachinePool.
+func poolConsumerRefMatches(consumer *corev1.ObjectReference, pool *infrav1.Metal3MachinePool) bool {
+	if consumer == nil || consumer.Kind != "Metal3MachinePool" {
+		return false
+	}
+	if consumer.Name != pool.Name || consumer.Namespace != pool.Namespace {
+		return false
+	}
+	if consumer.GroupVersionKind().Group != infrav1.GroupVersion.Group {
+		return false
+	}
+	return consumer.UID == "" || pool.UID == "" || consumer.UID == pool.UID
+}
+
+// hostSelectorRequirements returns the label requirements of the matchLabels
+// and matchExpressions of a hostSelector.
+func hostSelectorRequirements(selector infrav1.HostSelector) (labels.Requirements, error) {
+	reqs := labels.Requirements{}
+	for key, value := range selector.MatchLabels {
+		r, err := labels.NewRequirement(key, selection.Equals, []string{value})
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *r)
+	}
+	for _, req := range selector.MatchExpressions {
+		r, err := labels.NewRequirement(req.Key, selection.Operator(strings.ToLower(string(req.Operator))), req.Values)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *r)
+	}
+	return reqs, nil
+}
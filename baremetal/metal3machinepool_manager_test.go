@@ -0,0 +1,293 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientfake "k8s.io/client-go/kubernetes/fake"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Metal3MachinePool manager", func() {
+	const poolName = "pool"
+
+	newPool := func() *infrav1.Metal3MachinePool {
+		return &infrav1.Metal3MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      poolName,
+				Namespace: namespaceName,
+				UID:       "pool-uid",
+			},
+			Spec: infrav1.Metal3MachinePoolSpec{
+				Template: infrav1.Metal3MachinePoolHostTemplate{
+					Image: infrav1.Image{
+						URL:        testImageURL,
+						Checksum:   testImageChecksumURL,
+						DiskFormat: testImageDiskFormat,
+					},
+					HostSelector: infrav1.HostSelector{
+						MatchLabels: map[string]string{"pool": "workers"},
+					},
+				},
+			},
+		}
+	}
+	newMachinePool := func(replicas int32) *expv1.MachinePool {
+		return &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      poolName,
+				Namespace: namespaceName,
+			},
+			Spec: expv1.MachinePoolSpec{
+				ClusterName: clusterName,
+				Replicas:    pointer.Int32(replicas),
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						Bootstrap: clusterv1.Bootstrap{DataSecretName: pointer.String("pool-bootstrap")},
+					},
+				},
+			},
+		}
+	}
+	poolHost := func(name string, state bmov1alpha1.ProvisioningState) *bmov1alpha1.BareMetalHost {
+		return &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespaceName,
+				UID:       types.UID(name + "-uid"),
+				Labels:    map[string]string{"pool": "workers"},
+			},
+			Status: bmov1alpha1.BareMetalHostStatus{
+				Provisioning: bmov1alpha1.ProvisionStatus{State: state},
+			},
+		}
+	}
+	consumedHost := func(name string, state bmov1alpha1.ProvisioningState) *bmov1alpha1.BareMetalHost {
+		host := poolHost(name, state)
+		host.Spec.ConsumerRef = &corev1.ObjectReference{
+			Kind:       "Metal3MachinePool",
+			Name:       poolName,
+			Namespace:  namespaceName,
+			APIVersion: infrav1.GroupVersion.String(),
+		}
+		host.Spec.Image = &bmov1alpha1.Image{URL: testImageURL}
+		host.Spec.UserData = &corev1.SecretReference{Name: "pool-bootstrap", Namespace: namespaceName}
+		return host
+	}
+	getHost := func(cl client.Client, name string) *bmov1alpha1.BareMetalHost {
+		host := &bmov1alpha1.BareMetalHost{}
+		Expect(cl.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: namespaceName}, host)).To(Succeed())
+		return host
+	}
+
+	It("sets and unsets the finalizer", func() {
+		pool := newPool()
+		poolMgr, err := NewMachinePoolManager(nil, nil, newMachinePool(1), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(poolMgr.IsBootstrapReady()).To(BeTrue())
+
+		poolMgr.SetFinalizer()
+		Expect(pool.Finalizers).To(ContainElement(infrav1.MachinePoolFinalizer))
+		poolMgr.UnsetFinalizer()
+		Expect(pool.Finalizers).NotTo(ContainElement(infrav1.MachinePoolFinalizer))
+	})
+
+	It("associates hosts on scale up", func() {
+		otherLabel := poolHost("host-other", bmov1alpha1.StateAvailable)
+		otherLabel.Labels = map[string]string{"pool": "other"}
+		paused := poolHost("host-paused", bmov1alpha1.StateAvailable)
+		paused.Annotations = map[string]string{bmov1alpha1.PausedAnnotation: ""}
+		inspecting := poolHost("host-inspecting", bmov1alpha1.StateInspecting)
+		objects := []client.Object{
+			consumedHost("host-0", bmov1alpha1.StateProvisioned),
+			poolHost("host-1", bmov1alpha1.StateAvailable),
+			poolHost("host-2", bmov1alpha1.StateReady),
+			poolHost("host-3", bmov1alpha1.StateAvailable),
+			otherLabel, paused, inspecting,
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+		pool := newPool()
+		poolMgr, err := NewMachinePoolManager(fakeClient, nil, newMachinePool(3), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(poolMgr.ReconcileHosts(context.TODO())).To(Succeed())
+		Expect(pool.Status.Hosts).To(Equal([]string{"host-0", "host-1", "host-2"}))
+		Expect(pool.Status.Ready).To(BeTrue())
+		Expect(conditions.IsTrue(pool, infrav1.HostsAssociatedCondition)).To(BeTrue())
+
+		host := getHost(fakeClient, "host-1")
+		Expect(poolConsumerRefMatches(host.Spec.ConsumerRef, pool)).To(BeTrue())
+		Expect(host.Spec.Image.URL).To(Equal(testImageURL))
+		Expect(host.Spec.UserData).To(Equal(&corev1.SecretReference{Name: "pool-bootstrap", Namespace: namespaceName}))
+		Expect(host.Spec.Online).To(BeTrue())
+		Expect(host.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, clusterName))
+		Expect(host.Annotations).To(HaveKeyWithValue(infrav1.HostProvisionCountAnnotation, "1"))
+		Expect(getHost(fakeClient, "host-3").Spec.ConsumerRef).To(BeNil())
+		for _, name := range []string{"host-other", "host-paused", "host-inspecting"} {
+			Expect(getHost(fakeClient, name).Spec.ConsumerRef).To(BeNil())
+		}
+	})
+
+	It("associates the hosts matching the hardware criteria", func() {
+		small := poolHost("host-small", bmov1alpha1.StateAvailable)
+		small.Status.HardwareDetails = &bmov1alpha1.HardwareDetails{CPU: bmov1alpha1.CPU{Count: 4}}
+		large := poolHost("host-large", bmov1alpha1.StateAvailable)
+		large.Status.HardwareDetails = &bmov1alpha1.HardwareDetails{CPU: bmov1alpha1.CPU{Count: 64}}
+		uninspected := poolHost("host-uninspected", bmov1alpha1.StateAvailable)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(small, large, uninspected).Build()
+		pool := newPool()
+		pool.Spec.Template.HostSelector.MatchHardware = &infrav1.HardwareSelector{MinCPU: 32}
+		poolMgr, err := NewMachinePoolManager(fakeClient, nil, newMachinePool(1), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(poolMgr.ReconcileHosts(context.TODO())).To(Succeed())
+		Expect(pool.Status.Hosts).To(Equal([]string{"host-large"}))
+	})
+
+	It("reports too few available hosts", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+			poolHost("host-0", bmov1alpha1.StateAvailable),
+		).Build()
+		pool := newPool()
+		poolMgr, err := NewMachinePoolManager(fakeClient, nil, newMachinePool(3), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = poolMgr.ReconcileHosts(context.TODO())
+		Expect(errors.Is(err, ErrNoAvailableHost)).To(BeTrue())
+		var reconcileError ReconcileError
+		Expect(errors.As(err, &reconcileError)).To(BeTrue())
+		Expect(reconcileError.IsTransient()).To(BeTrue())
+		Expect(pool.Status.Hosts).To(Equal([]string{"host-0"}))
+		Expect(pool.Status.Ready).To(BeFalse())
+		condition := conditions.Get(pool, infrav1.HostsAssociatedCondition)
+		Expect(condition.Reason).To(Equal(infrav1.NoAvailableHostReason))
+		Expect(condition.Message).To(Equal("1 of 3 BareMetalHost(s) associated"))
+	})
+
+	It("releases hosts on scale down", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+			consumedHost("host-0", bmov1alpha1.StateProvisioned),
+			consumedHost("host-1", bmov1alpha1.StateProvisioning),
+			consumedHost("host-2", bmov1alpha1.StateProvisioned),
+		).Build()
+		pool := newPool()
+		poolMgr, err := NewMachinePoolManager(fakeClient, nil, newMachinePool(1), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		// The provisioned hosts are kept first.
+		err = poolMgr.ReconcileHosts(context.TODO())
+		var reconcileError ReconcileError
+		Expect(errors.As(err, &reconcileError)).To(BeTrue())
+		Expect(reconcileError.IsTransient()).To(BeTrue())
+		Expect(pool.Status.Hosts).To(Equal([]string{"host-0"}))
+		Expect(conditions.Get(pool, infrav1.HostsAssociatedCondition).Reason).To(Equal(infrav1.ScalingDownReason))
+		for _, name := range []string{"host-1", "host-2"} {
+			host := getHost(fakeClient, name)
+			Expect(host.Spec.Image).To(BeNil())
+			Expect(host.Spec.UserData).To(BeNil())
+			Expect(host.Spec.ConsumerRef).NotTo(BeNil())
+		}
+
+		// The consumerRef is removed once the host is deprovisioned.
+		for _, name := range []string{"host-1", "host-2"} {
+			host := getHost(fakeClient, name)
+			host.Status.Provisioning.State = bmov1alpha1.StateAvailable
+			Expect(fakeClient.Update(context.TODO(), host)).To(Succeed())
+		}
+		Expect(poolMgr.ReconcileHosts(context.TODO())).To(Succeed())
+		Expect(conditions.IsTrue(pool, infrav1.HostsAssociatedCondition)).To(BeTrue())
+		for _, name := range []string{"host-1", "host-2"} {
+			host := getHost(fakeClient, name)
+			Expect(host.Spec.ConsumerRef).To(BeNil())
+			Expect(host.Annotations).To(HaveKey(infrav1.HostLastReleasedAnnotation))
+		}
+	})
+
+	It("releases all the hosts on deletion", func() {
+		otherPool := consumedHost("host-other", bmov1alpha1.StateProvisioned)
+		otherPool.Spec.ConsumerRef.Name = "other-pool"
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+			consumedHost("host-0", bmov1alpha1.StateProvisioned), otherPool,
+		).Build()
+		pool := newPool()
+		pool.Spec.ProviderIDList = []string{"metal3://" + namespaceName + "/host-0/" + poolName}
+		poolMgr, err := NewMachinePoolManager(fakeClient, nil, newMachinePool(1), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = poolMgr.Delete(context.TODO())
+		var reconcileError ReconcileError
+		Expect(errors.As(err, &reconcileError)).To(BeTrue())
+		Expect(reconcileError.IsTransient()).To(BeTrue())
+		Expect(pool.Spec.ProviderIDList).To(BeEmpty())
+		Expect(getHost(fakeClient, "host-0").Spec.Image).To(BeNil())
+		Expect(getHost(fakeClient, "host-other").Spec.Image).NotTo(BeNil())
+
+		host := getHost(fakeClient, "host-0")
+		host.Status.Provisioning.State = bmov1alpha1.StateAvailable
+		Expect(fakeClient.Update(context.TODO(), host)).To(Succeed())
+		Expect(poolMgr.Delete(context.TODO())).To(Succeed())
+		Expect(getHost(fakeClient, "host-0").Spec.ConsumerRef).To(BeNil())
+		Expect(pool.Status.Hosts).To(BeNil())
+	})
+
+	It("sets the providerIDs of the Nodes", func() {
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+			consumedHost("host-0", bmov1alpha1.StateProvisioned),
+			consumedHost("host-1", bmov1alpha1.StateProvisioned),
+			consumedHost("host-2", bmov1alpha1.StateProvisioned),
+			consumedHost("host-3", bmov1alpha1.StateProvisioning),
+		).Build()
+		nodes := []*corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-0", Labels: map[string]string{ProviderLabelPrefix: "host-0-uid"}}},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{ProviderLabelPrefix: "host-1-uid"}},
+				Spec:       corev1.NodeSpec{ProviderID: "metal3://host-1-uid"},
+			},
+		}
+		clientset := clientfake.NewSimpleClientset(nodes[0], nodes[1])
+		clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+			return clientset.CoreV1(), nil
+		}
+		pool := newPool()
+		poolMgr, err := NewMachinePoolManager(fakeClient, newCluster(clusterName), newMachinePool(4), pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(poolMgr.UpdateProviderIDs(context.TODO(), clientGetter)).To(Succeed())
+		providerID := "metal3://" + namespaceName + "/host-0/" + poolName
+		Expect(pool.Spec.ProviderIDList).To(Equal([]string{providerID, "metal3://host-1-uid"}))
+		Expect(pool.Status.Replicas).To(Equal(int32(2)))
+		node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Spec.ProviderID).To(Equal(providerID))
+	})
+})
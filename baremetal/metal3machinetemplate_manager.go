@@ -15,23 +15,43 @@ package baremetal
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	clonedFromName      = clusterv1.TemplateClonedFromNameAnnotation
 	clonedFromGroupKind = clusterv1.TemplateClonedFromGroupKindAnnotation
+
+	// maxOutOfDateMachinesInEvent is the maximum number of out-of-date
+	// metal3Machines listed in an event.
+	maxOutOfDateMachinesInEvent = 10
 )
 
 // TemplateManagerInterface is an interface for a TemplateManager.
 type TemplateManagerInterface interface {
 	UpdateAutomatedCleaningMode(context.Context) error
+	UpdateTemplateDrift(context.Context) error
+	UpdateOutOfDateReplicas(context.Context) error
+	UpdateNodeReuseReservations(context.Context) error
 }
 
 // MachineTemplateManager is responsible for performing metal3MachineTemplate reconciliation.
@@ -41,6 +61,8 @@ type MachineTemplateManager struct {
 	Metal3MachineList     *infrav1.Metal3MachineList
 	Metal3MachineTemplate *infrav1.Metal3MachineTemplate
 	Log                   logr.Logger
+	// Recorder, when set, records the events of the Metal3MachineTemplate.
+	Recorder record.EventRecorder
 }
 
 // NewMachineTemplateManager returns a new helper for managing a metal3MachineTemplate.
@@ -60,7 +82,179 @@ func NewMachineTemplateManager(client client.Client,
 
 // UpdateAutomatedCleaningMode synchronizes automatedCleaningMode field value between metal3MachineTemplate
 // and all the metal3Machines cloned from this metal3MachineTemplate.
+// Nothing is done when the template only applies automatedCleaningMode on creation.
+// Templates created before the updateAutomatedCleaningMode field was added have it
+// unset, which keeps the former behaviour of always synchronizing.
 func (m *MachineTemplateManager) UpdateAutomatedCleaningMode(ctx context.Context) error {
+	if m.Metal3MachineTemplate.Spec.UpdateAutomatedCleaningMode == infrav1.UpdateAutomatedCleaningModeOnCreate {
+		m.Log.V(4).Info("AutomatedCleaningMode is only applied on creation, not synchronizing metal3Machines")
+		return nil
+	}
+
+	matchedM3Machines, err := m.clonedMetal3Machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(matchedM3Machines) > 0 {
+		for _, m3m := range matchedM3Machines {
+			// don't synchronize AutomatedCleaningMode between metal3MachineTemplate
+			// and metal3Machine if unset in metal3MachineTemplate, and don't
+			// update the metal3Machines already in sync.
+			automatedCleaningMode := m.Metal3MachineTemplate.Spec.Template.Spec.AutomatedCleaningMode
+			if automatedCleaningMode != nil && !pointer.StringEqual(m3m.Spec.AutomatedCleaningMode, automatedCleaningMode) {
+				m3m.Spec.AutomatedCleaningMode = automatedCleaningMode
+
+				if err := m.client.Update(ctx, m3m); err != nil {
+					return errors.Wrapf(err, "failed to update metal3Machine: %s", m3m.Name)
+				}
+
+				m.Log.Info("Synchronized automatedCleaningMode between ", "Metal3MachineTemplate", fmt.Sprintf("%v/%v", m.Metal3MachineTemplate.Namespace, m.Metal3MachineTemplate.Name), "Metal3Machine", fmt.Sprintf("%v/%v", m3m.Namespace, m3m.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateTemplateDrift sets the TemplateInSyncCondition of the
+// metal3MachineTemplate to false with the number of metal3Machines cloned from
+// it whose image, customDeploy, hostSelector or dataTemplate differ from the
+// template, e.g. after an update allowed with the
+// AllowTemplateUpdateAnnotation. The condition is true when no metal3Machine
+// differs.
+func (m *MachineTemplateManager) UpdateTemplateDrift(ctx context.Context) error {
+	matchedM3Machines, err := m.clonedMetal3Machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	drifted := countDriftedMetal3Machines(&m.Metal3MachineTemplate.Spec.Template.Spec, matchedM3Machines)
+	if drifted == 0 {
+		conditions.MarkTrue(m.Metal3MachineTemplate, infrav1.TemplateInSyncCondition)
+		return nil
+	}
+	conditions.MarkFalse(m.Metal3MachineTemplate, infrav1.TemplateInSyncCondition, infrav1.Metal3MachinesDriftedReason,
+		clusterv1.ConditionSeverityInfo, "%d of %d Metal3Machines differ from the template", drifted, len(matchedM3Machines))
+	return nil
+}
+
+// UpdateOutOfDateReplicas sets the outOfDateReplicas of the status of the
+// metal3MachineTemplate to the number of metal3Machines cloned from it whose
+// recorded spec hash differs from the current one. An event lists the
+// out-of-date metal3Machines when their number changes.
+//
+// The hash is recorded by the metal3Machine controller. A metal3Machine
+// without recorded hash whose image, customDeploy, hostSelector or
+// dataTemplate differ from the template, e.g. one created before the hashes
+// were recorded, is out of date.
+func (m *MachineTemplateManager) UpdateOutOfDateReplicas(ctx context.Context) error {
+	hash, err := templateSpecHash(m.Metal3MachineTemplate)
+	if err != nil {
+		return err
+	}
+	matchedM3Machines, err := m.clonedMetal3Machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	outOfDate := []string{}
+	for _, m3m := range matchedM3Machines {
+		if !m3m.DeletionTimestamp.IsZero() {
+			continue
+		}
+		recordedHash, ok := m3m.Annotations[infrav1.TemplateHashAnnotation]
+		if !ok {
+			if countDriftedMetal3Machines(&m.Metal3MachineTemplate.Spec.Template.Spec, []*infrav1.Metal3Machine{m3m}) > 0 {
+				outOfDate = append(outOfDate, m3m.Name)
+			}
+			continue
+		}
+		if recordedHash != hash {
+			outOfDate = append(outOfDate, m3m.Name)
+		}
+	}
+
+	previous := m.Metal3MachineTemplate.Status.OutOfDateReplicas
+	m.Metal3MachineTemplate.Status.OutOfDateReplicas = int32(len(outOfDate))
+	if len(outOfDate) == 0 || int32(len(outOfDate)) == previous || m.Recorder == nil {
+		return nil
+	}
+	sort.Strings(outOfDate)
+	listed := outOfDate
+	if len(listed) > maxOutOfDateMachinesInEvent {
+		listed = listed[:maxOutOfDateMachinesInEvent]
+	}
+	message := fmt.Sprintf("%d Metal3Machines cloned from an older revision of the template: %s",
+		len(outOfDate), strings.Join(listed, ", "),
+	)
+	if len(outOfDate) > len(listed) {
+		message += fmt.Sprintf(" and %d more", len(outOfDate)-len(listed))
+	}
+	m.Recorder.Event(m.Metal3MachineTemplate, corev1.EventTypeWarning, infrav1.Metal3MachinesOutOfDateReason, message)
+	return nil
+}
+
+// UpdateNodeReuseReservations sets the nodeReuseReservations of the status of
+// the metal3MachineTemplate to the available BareMetalHosts of its namespace
+// released by its metal3Machines and still carrying the node reuse label,
+// with the time after which their reservation may expire.
+func (m *MachineTemplateManager) UpdateNodeReuseReservations(ctx context.Context) error {
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := m.client.List(ctx, &hosts, client.InNamespace(m.Metal3MachineTemplate.Namespace)); err != nil {
+		return errors.Wrap(err, "failed to list the BareMetalHosts")
+	}
+
+	var reservations []infrav1.NodeReuseReservation
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		if host.Annotations[infrav1.HostNodeReuseTemplateAnnotation] != m.Metal3MachineTemplate.Name ||
+			host.Spec.ConsumerRef != nil {
+			continue
+		}
+		value, ok := lookupLabel(host.Labels, nodeReuseLabelName)
+		if !ok {
+			continue
+		}
+		reservation := infrav1.NodeReuseReservation{Host: host.Name, Value: value}
+		if since, err := time.Parse(time.RFC3339, host.Annotations[infrav1.HostNodeReuseSinceAnnotation]); err == nil {
+			reservation.Since = &metav1.Time{Time: since}
+			if ttl := nodeReuseLabelTTLOf(host); ttl > 0 {
+				reservation.ExpiresAt = &metav1.Time{Time: since.Add(ttl)}
+			}
+		}
+		reservations = append(reservations, reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].Host < reservations[j].Host
+	})
+	m.Metal3MachineTemplate.Status.NodeReuseReservations = reservations
+	return nil
+}
+
+// templateSpecHash returns the hash of the Metal3Machine spec of the
+// metal3MachineTemplate. It only depends on the values of the spec, not on
+// the order of its fields or map keys in the manifest. The
+// automatedCleaningMode is left out when it is synchronized to the existing
+// metal3Machines, since changing it does not make them out of date.
+func templateSpecHash(m3mt *infrav1.Metal3MachineTemplate) (string, error) {
+	spec := m3mt.Spec.Template.Spec.DeepCopy()
+	if m3mt.Spec.UpdateAutomatedCleaningMode != infrav1.UpdateAutomatedCleaningModeOnCreate {
+		spec.AutomatedCleaningMode = nil
+	}
+	// The JSON encoding of a struct follows the order of its fields and sorts
+	// the map keys.
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode the template spec")
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write(data)
+	return strconv.FormatUint(hash.Sum64(), 16), nil
+}
+
+// clonedMetal3Machines returns the metal3Machines cloned from the
+// metal3MachineTemplate.
+func (m *MachineTemplateManager) clonedMetal3Machines(ctx context.Context) ([]*infrav1.Metal3Machine, error) {
 	m.Log.Info("Fetching metal3Machine objects")
 
 	// get list of metal3Machine objects
@@ -71,7 +265,7 @@ func (m *MachineTemplateManager) UpdateAutomatedCleaningMode(ctx context.Context
 	}
 
 	if err := m.client.List(ctx, m3ms, opts); err != nil {
-		return errors.Wrap(err, "failed to list metal3Machines")
+		return nil, errors.Wrap(err, "failed to list metal3Machines")
 	}
 
 	matchedM3Machines := []*infrav1.Metal3Machine{}
@@ -84,21 +278,24 @@ func (m *MachineTemplateManager) UpdateAutomatedCleaningMode(ctx context.Context
 			matchedM3Machines = append(matchedM3Machines, m3m)
 		}
 	}
+	return matchedM3Machines, nil
+}
 
-	if len(matchedM3Machines) > 0 {
-		for _, m3m := range matchedM3Machines {
-			// don't synchronize AutomatedCleaningMode between metal3MachineTemplate
-			// and metal3Machine if unset in metal3MachineTemplate.
-			if m.Metal3MachineTemplate.Spec.Template.Spec.AutomatedCleaningMode != nil {
-				m3m.Spec.AutomatedCleaningMode = m.Metal3MachineTemplate.Spec.Template.Spec.AutomatedCleaningMode
-
-				if err := m.client.Update(ctx, m3m); err != nil {
-					return errors.Wrapf(err, "failed to update metal3Machine: %s", m3m.Name)
-				}
-
-				m.Log.Info("Synchronized automatedCleaningMode between ", "Metal3MachineTemplate", fmt.Sprintf("%v/%v", m.Metal3MachineTemplate.Namespace, m.Metal3MachineTemplate.Name), "Metal3Machine", fmt.Sprintf("%v/%v", m3m.Namespace, m3m.Name))
-			}
+// countDriftedMetal3Machines returns the number of metal3Machines whose image,
+// customDeploy, hostSelector or dataTemplate differ from the template spec.
+// The specs are compared with the defaults of the Metal3Machine webhook, which
+// are only set on the metal3Machines created since it sets them.
+func countDriftedMetal3Machines(templateSpec *infrav1.Metal3MachineSpec, m3ms []*infrav1.Metal3Machine) int {
+	drifted := 0
+	for _, m3m := range m3ms {
+		m3mSpec := m3m.Spec.WithDefaults(m3m.Namespace)
+		expectedSpec := templateSpec.WithDefaults(m3m.Namespace)
+		if !apiequality.Semantic.DeepEqual(m3mSpec.Image, expectedSpec.Image) ||
+			!apiequality.Semantic.DeepEqual(m3mSpec.CustomDeploy, expectedSpec.CustomDeploy) ||
+			!apiequality.Semantic.DeepEqual(m3mSpec.HostSelector, expectedSpec.HostSelector) ||
+			!apiequality.Semantic.DeepEqual(m3mSpec.DataTemplate, expectedSpec.DataTemplate) {
+			drifted++
 		}
 	}
-	return nil
+	return drifted
 }
@@ -18,17 +18,26 @@ package baremetal
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	utils "k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 var _ = Describe("Metal3MachineTemplate manager", func() {
@@ -134,6 +143,88 @@ var _ = Describe("Metal3MachineTemplate manager", func() {
 				},
 			},
 		}),
+		Entry("Disk cleaning disabled, always updated", testCaseUpdate{
+			ExpectedValue: utils.String(infrav1.CleaningModeDisabled),
+			M3MachineTemplate: &infrav1.Metal3MachineTemplate{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineTemplate",
+				},
+				ObjectMeta: testObjectMeta("abc", "foo", ""),
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: infrav1.Metal3MachineSpec{
+							AutomatedCleaningMode: utils.String(infrav1.CleaningModeDisabled),
+						},
+					},
+					UpdateAutomatedCleaningMode: infrav1.UpdateAutomatedCleaningModeAlways,
+				},
+			},
+			M3MachineList: &infrav1.Metal3MachineList{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineList",
+				},
+				ListMeta: metav1.ListMeta{},
+				Items: []infrav1.Metal3Machine{
+					{
+						TypeMeta: metav1.TypeMeta{},
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "machine-1",
+							Namespace: "foo",
+							Annotations: map[string]string{
+								"cluster.x-k8s.io/cloned-from-name":      "abc",
+								"cluster.x-k8s.io/cloned-from-groupkind": infrav1.ClonedFromGroupKind,
+							},
+						},
+						Spec: infrav1.Metal3MachineSpec{
+							AutomatedCleaningMode: utils.String(infrav1.CleaningModeMetadata),
+						},
+					},
+				},
+			},
+		}),
+		Entry("Disk cleaning disabled, only on create", testCaseUpdate{
+			ExpectedValue: utils.String(infrav1.CleaningModeMetadata),
+			M3MachineTemplate: &infrav1.Metal3MachineTemplate{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineTemplate",
+				},
+				ObjectMeta: testObjectMeta("abc", "foo", ""),
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: infrav1.Metal3MachineSpec{
+							AutomatedCleaningMode: utils.String(infrav1.CleaningModeDisabled),
+						},
+					},
+					UpdateAutomatedCleaningMode: infrav1.UpdateAutomatedCleaningModeOnCreate,
+				},
+			},
+			M3MachineList: &infrav1.Metal3MachineList{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineList",
+				},
+				ListMeta: metav1.ListMeta{},
+				Items: []infrav1.Metal3Machine{
+					{
+						TypeMeta: metav1.TypeMeta{},
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "machine-1",
+							Namespace: "foo",
+							Annotations: map[string]string{
+								"cluster.x-k8s.io/cloned-from-name":      "abc",
+								"cluster.x-k8s.io/cloned-from-groupkind": infrav1.ClonedFromGroupKind,
+							},
+						},
+						Spec: infrav1.Metal3MachineSpec{
+							AutomatedCleaningMode: utils.String(infrav1.CleaningModeMetadata),
+						},
+					},
+				},
+			},
+		}),
 		Entry("Disk cleaning enabled", testCaseUpdate{
 			ExpectedValue: utils.String("metadata"),
 			M3MachineTemplate: &infrav1.Metal3MachineTemplate{
@@ -243,4 +334,453 @@ var _ = Describe("Metal3MachineTemplate manager", func() {
 			},
 		}),
 	)
+
+	driftTemplateSpec := func() infrav1.Metal3MachineSpec {
+		return infrav1.Metal3MachineSpec{
+			Image: infrav1.Image{
+				URL:      "http://abc.com/image",
+				Checksum: "http://abc.com/image.sha256sum",
+			},
+			HostSelector: infrav1.HostSelector{
+				MatchLabels: map[string]string{"role": "worker"},
+			},
+			DataTemplate: &corev1.ObjectReference{Name: "data-template"},
+		}
+	}
+	driftM3M := func(name, template string, update func(*infrav1.Metal3MachineSpec)) *infrav1.Metal3Machine {
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "foo",
+				Annotations: map[string]string{
+					"cluster.x-k8s.io/cloned-from-name":      template,
+					"cluster.x-k8s.io/cloned-from-groupkind": infrav1.ClonedFromGroupKind,
+				},
+			},
+			Spec: driftTemplateSpec(),
+		}
+		if update != nil {
+			update(&m3m.Spec)
+		}
+		return m3m
+	}
+
+	type testCaseCountDrift struct {
+		M3Machines    []*infrav1.Metal3Machine
+		ExpectedCount int
+	}
+
+	DescribeTable("Test countDriftedMetal3Machines",
+		func(tc testCaseCountDrift) {
+			templateSpec := driftTemplateSpec()
+			Expect(countDriftedMetal3Machines(&templateSpec, tc.M3Machines)).To(Equal(tc.ExpectedCount))
+		},
+		Entry("No Metal3Machines", testCaseCountDrift{}),
+		Entry("Metal3Machines up to date", testCaseCountDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+				driftM3M("machine-2", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+				}),
+			},
+		}),
+		Entry("Image, customDeploy, hostSelector and dataTemplate differ", testCaseCountDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+				driftM3M("machine-2", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.Image.Checksum = "http://abc.com/image-1.sha256sum"
+				}),
+				driftM3M("machine-3", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.HostSelector.MatchLabels = map[string]string{"role": "storage"}
+				}),
+				driftM3M("machine-4", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.DataTemplate = nil
+				}),
+				driftM3M("machine-5", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.CustomDeploy = &infrav1.CustomDeploy{Method: "install_coreos"}
+				}),
+			},
+			ExpectedCount: 4,
+		}),
+		Entry("Defaults set by the webhook", testCaseCountDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.DataTemplate.Namespace = "foo"
+				}),
+				driftM3M("machine-2", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.Image.ChecksumType = utils.String("")
+				}),
+			},
+		}),
+	)
+
+	It("Does not update Metal3Machines in sync with the template", func() {
+		m3mt := &infrav1.Metal3MachineTemplate{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "Metal3MachineTemplate",
+			},
+			ObjectMeta: testObjectMeta("abc", "foo", ""),
+			Spec: infrav1.Metal3MachineTemplateSpec{
+				Template: infrav1.Metal3MachineTemplateResource{
+					Spec: driftTemplateSpec(),
+				},
+			},
+		}
+		m3mt.Spec.Template.Spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+		// A Metal3Machine stored before the webhook defaulted its spec, and one
+		// defaulted by the webhook.
+		legacy := driftM3M("machine-1", "abc", func(spec *infrav1.Metal3MachineSpec) {
+			spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+		})
+		defaulted := driftM3M("machine-2", "abc", func(spec *infrav1.Metal3MachineSpec) {
+			spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+			spec.DataTemplate.Namespace = "foo"
+		})
+		writes := 0
+		fakeClient := fakeclient.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3mt, legacy, defaulted).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+					writes++
+					return c.Update(ctx, obj, opts...)
+				},
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					writes++
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).Build()
+
+		for i := 0; i < 2; i++ {
+			templateMgr, err := NewMachineTemplateManager(fakeClient, m3mt, nil, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(templateMgr.UpdateAutomatedCleaningMode(context.TODO())).To(Succeed())
+			Expect(templateMgr.UpdateTemplateDrift(context.TODO())).To(Succeed())
+		}
+		Expect(writes).To(BeZero())
+		Expect(conditions.IsTrue(m3mt, infrav1.TemplateInSyncCondition)).To(BeTrue())
+	})
+
+	type testCaseUpdateTemplateDrift struct {
+		M3Machines      []*infrav1.Metal3Machine
+		ExistingDrift   bool
+		ExpectedMessage string
+	}
+
+	DescribeTable("Test UpdateTemplateDrift",
+		func(tc testCaseUpdateTemplateDrift) {
+			m3mt := &infrav1.Metal3MachineTemplate{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineTemplate",
+				},
+				ObjectMeta: testObjectMeta("abc", "foo", ""),
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: driftTemplateSpec(),
+					},
+				},
+			}
+			if tc.ExistingDrift {
+				conditions.MarkFalse(m3mt, infrav1.TemplateInSyncCondition, infrav1.Metal3MachinesDriftedReason, clusterv1.ConditionSeverityInfo, "")
+			}
+			objects := []client.Object{m3mt}
+			for _, m3m := range tc.M3Machines {
+				objects = append(objects, m3m)
+			}
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			templateMgr, err := NewMachineTemplateManager(fakeClient, m3mt, nil, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(templateMgr.UpdateTemplateDrift(context.TODO())).To(Succeed())
+
+			if tc.ExpectedMessage == "" {
+				Expect(conditions.IsTrue(m3mt, infrav1.TemplateInSyncCondition)).To(BeTrue())
+				return
+			}
+			Expect(conditions.IsFalse(m3mt, infrav1.TemplateInSyncCondition)).To(BeTrue())
+			Expect(conditions.GetReason(m3mt, infrav1.TemplateInSyncCondition)).To(Equal(infrav1.Metal3MachinesDriftedReason))
+			Expect(conditions.GetMessage(m3mt, infrav1.TemplateInSyncCondition)).To(Equal(tc.ExpectedMessage))
+		},
+		Entry("No drift", testCaseUpdateTemplateDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+			},
+		}),
+		Entry("Drift resolved", testCaseUpdateTemplateDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+			},
+			ExistingDrift: true,
+		}),
+		Entry("Drifted Metal3Machines", testCaseUpdateTemplateDrift{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+				driftM3M("machine-2", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.Image.URL = "http://abc.com/image-1"
+				}),
+				driftM3M("machine-3", "xyz", func(spec *infrav1.Metal3MachineSpec) {
+					spec.Image.URL = "http://abc.com/image-1"
+				}),
+			},
+			ExpectedMessage: "1 of 2 Metal3Machines differ from the template",
+		}),
+	)
+
+	hashTemplate := func(spec infrav1.Metal3MachineSpec, policy infrav1.AutomatedCleaningModeUpdatePolicy) *infrav1.Metal3MachineTemplate {
+		return &infrav1.Metal3MachineTemplate{
+			Spec: infrav1.Metal3MachineTemplateSpec{
+				Template:                    infrav1.Metal3MachineTemplateResource{Spec: spec},
+				UpdateAutomatedCleaningMode: policy,
+			},
+		}
+	}
+	decodedSpec := func(manifest string) infrav1.Metal3MachineSpec {
+		spec := infrav1.Metal3MachineSpec{}
+		Expect(json.Unmarshal([]byte(manifest), &spec)).To(Succeed())
+		return spec
+	}
+
+	type testCaseTemplateSpecHash struct {
+		Template      *infrav1.Metal3MachineTemplate
+		OtherTemplate *infrav1.Metal3MachineTemplate
+		ExpectEqual   bool
+	}
+
+	DescribeTable("Test templateSpecHash",
+		func(tc testCaseTemplateSpecHash) {
+			hash, err := templateSpecHash(tc.Template)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).NotTo(BeEmpty())
+			otherHash, err := templateSpecHash(tc.OtherTemplate)
+			Expect(err).NotTo(HaveOccurred())
+			if tc.ExpectEqual {
+				Expect(hash).To(Equal(otherHash))
+			} else {
+				Expect(hash).NotTo(Equal(otherHash))
+			}
+		},
+		Entry("Same spec with another field and key ordering", testCaseTemplateSpecHash{
+			Template: hashTemplate(decodedSpec(`{
+				"image": {"url": "http://abc.com/image", "checksum": "http://abc.com/image.sha256sum"},
+				"hostSelector": {"matchLabels": {"role": "worker", "rack": "a"}},
+				"dataTemplate": {"name": "data-template"}
+			}`), ""),
+			OtherTemplate: hashTemplate(decodedSpec(`{
+				"dataTemplate": {"name": "data-template"},
+				"hostSelector": {"matchLabels": {"rack": "a", "role": "worker"}},
+				"image": {"checksum": "http://abc.com/image.sha256sum", "url": "http://abc.com/image"}
+			}`), ""),
+			ExpectEqual: true,
+		}),
+		Entry("Empty and unset hostSelector requirements", testCaseTemplateSpecHash{
+			Template: hashTemplate(driftTemplateSpec(), ""),
+			OtherTemplate: hashTemplate(func() infrav1.Metal3MachineSpec {
+				spec := driftTemplateSpec()
+				spec.HostSelector.MatchExpressions = []infrav1.HostSelectorRequirement{}
+				return spec
+			}(), ""),
+			ExpectEqual: true,
+		}),
+		Entry("Different image", testCaseTemplateSpecHash{
+			Template: hashTemplate(driftTemplateSpec(), ""),
+			OtherTemplate: hashTemplate(func() infrav1.Metal3MachineSpec {
+				spec := driftTemplateSpec()
+				spec.Image.URL = "http://abc.com/image-1"
+				return spec
+			}(), ""),
+		}),
+		Entry("Different synchronized automatedCleaningMode", testCaseTemplateSpecHash{
+			Template: hashTemplate(driftTemplateSpec(), infrav1.UpdateAutomatedCleaningModeAlways),
+			OtherTemplate: hashTemplate(func() infrav1.Metal3MachineSpec {
+				spec := driftTemplateSpec()
+				spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+				return spec
+			}(), infrav1.UpdateAutomatedCleaningModeAlways),
+			ExpectEqual: true,
+		}),
+		Entry("Different automatedCleaningMode applied on creation", testCaseTemplateSpecHash{
+			Template: hashTemplate(driftTemplateSpec(), infrav1.UpdateAutomatedCleaningModeOnCreate),
+			OtherTemplate: hashTemplate(func() infrav1.Metal3MachineSpec {
+				spec := driftTemplateSpec()
+				spec.AutomatedCleaningMode = utils.String(infrav1.CleaningModeDisabled)
+				return spec
+			}(), infrav1.UpdateAutomatedCleaningModeOnCreate),
+		}),
+	)
+
+	type testCaseUpdateOutOfDateReplicas struct {
+		M3Machines        []*infrav1.Metal3Machine
+		PreviousOutOfDate int32
+		ExpectedOutOfDate int32
+		ExpectedEvent     string
+	}
+
+	withHash := func(m3m *infrav1.Metal3Machine, hash string) *infrav1.Metal3Machine {
+		m3m.Annotations[infrav1.TemplateGenerationAnnotation] = "1"
+		m3m.Annotations[infrav1.TemplateHashAnnotation] = hash
+		return m3m
+	}
+	currentHash, _ := templateSpecHash(hashTemplate(driftTemplateSpec(), ""))
+	manyOutOfDate := []*infrav1.Metal3Machine{}
+	for i := 0; i < 12; i++ {
+		manyOutOfDate = append(manyOutOfDate, withHash(driftM3M(fmt.Sprintf("machine-%02d", i), "abc", nil), "old"))
+	}
+
+	DescribeTable("Test UpdateOutOfDateReplicas",
+		func(tc testCaseUpdateOutOfDateReplicas) {
+			m3mt := &infrav1.Metal3MachineTemplate{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3MachineTemplate",
+				},
+				ObjectMeta: testObjectMeta("abc", "foo", ""),
+				Spec: infrav1.Metal3MachineTemplateSpec{
+					Template: infrav1.Metal3MachineTemplateResource{
+						Spec: driftTemplateSpec(),
+					},
+				},
+				Status: infrav1.Metal3MachineTemplateStatus{OutOfDateReplicas: tc.PreviousOutOfDate},
+			}
+			m3mt.Generation = 2
+			objects := []client.Object{m3mt}
+			for _, m3m := range tc.M3Machines {
+				objects = append(objects, m3m.DeepCopy())
+			}
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(objects...).Build()
+			recorder := record.NewFakeRecorder(10)
+			templateMgr, err := NewMachineTemplateManager(fakeClient, m3mt, nil, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			templateMgr.Recorder = recorder
+
+			Expect(templateMgr.UpdateOutOfDateReplicas(context.TODO())).To(Succeed())
+			Expect(m3mt.Status.OutOfDateReplicas).To(Equal(tc.ExpectedOutOfDate))
+
+			for _, m3m := range tc.M3Machines {
+				updated := &infrav1.Metal3Machine{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(m3m), updated)).To(Succeed())
+				Expect(updated.Annotations).To(Equal(m3m.Annotations))
+			}
+
+			if tc.ExpectedEvent == "" {
+				Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			Expect(recorder.Events).To(Receive(Equal("Warning " + infrav1.Metal3MachinesOutOfDateReason + " " + tc.ExpectedEvent)))
+		},
+		Entry("Metal3Machines without recorded revision matching the template", testCaseUpdateOutOfDateReplicas{
+			M3Machines: []*infrav1.Metal3Machine{
+				driftM3M("machine-1", "abc", nil),
+				withHash(driftM3M("machine-2", "abc", nil), currentHash),
+				driftM3M("machine-3", "xyz", nil),
+			},
+		}),
+		Entry("Out-of-date Metal3Machines", testCaseUpdateOutOfDateReplicas{
+			M3Machines: []*infrav1.Metal3Machine{
+				withHash(driftM3M("machine-1", "abc", nil), currentHash),
+				withHash(driftM3M("machine-2", "abc", nil), "old"),
+				driftM3M("machine-3", "abc", func(spec *infrav1.Metal3MachineSpec) {
+					spec.Image.URL = "http://abc.com/image-1"
+				}),
+				withHash(driftM3M("machine-4", "xyz", nil), "old"),
+			},
+			ExpectedOutOfDate: 2,
+			ExpectedEvent:     "2 Metal3Machines cloned from an older revision of the template: machine-2, machine-3",
+		}),
+		Entry("No event when the number of out-of-date Metal3Machines is unchanged", testCaseUpdateOutOfDateReplicas{
+			M3Machines: []*infrav1.Metal3Machine{
+				withHash(driftM3M("machine-1", "abc", nil), "old"),
+			},
+			PreviousOutOfDate: 1,
+			ExpectedOutOfDate: 1,
+		}),
+		Entry("Event listing at most 10 Metal3Machines", testCaseUpdateOutOfDateReplicas{
+			M3Machines:        manyOutOfDate,
+			ExpectedOutOfDate: 12,
+			ExpectedEvent: "12 Metal3Machines cloned from an older revision of the template: " +
+				"machine-00, machine-01, machine-02, machine-03, machine-04, machine-05, " +
+				"machine-06, machine-07, machine-08, machine-09 and 2 more",
+		}),
+	)
+
+	type testCaseUpdateNodeReuseReservations struct {
+		TTL                  time.Duration
+		Annotations          map[string]string
+		Labels               map[string]string
+		Consumed             bool
+		ExpectedReservations []infrav1.NodeReuseReservation
+	}
+
+	since := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	sinceAnnotation := since.Format(time.RFC3339)
+
+	DescribeTable("Test UpdateNodeReuseReservations",
+		func(tc testCaseUpdateNodeReuseReservations) {
+			DeferCleanup(func(ttl time.Duration) { NodeReuseLabelTTL = ttl }, NodeReuseLabelTTL)
+			NodeReuseLabelTTL = tc.TTL
+
+			m3mt := &infrav1.Metal3MachineTemplate{ObjectMeta: testObjectMeta("abc", "foo", "")}
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "host-1", Namespace: "foo",
+					Labels: tc.Labels, Annotations: tc.Annotations,
+				},
+			}
+			if tc.Consumed {
+				host.Spec.ConsumerRef = &corev1.ObjectReference{Name: "machine-1"}
+			}
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(m3mt, host).Build()
+			templateMgr, err := NewMachineTemplateManager(fakeClient, m3mt, nil, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(templateMgr.UpdateNodeReuseReservations(context.TODO())).To(Succeed())
+			Expect(m3mt.Status.NodeReuseReservations).To(Equal(tc.ExpectedReservations))
+		},
+		Entry("Host reserved without TTL", testCaseUpdateNodeReuseReservations{
+			Labels: map[string]string{nodeReuseLabelName: "md-workers"},
+			Annotations: map[string]string{
+				infrav1.HostNodeReuseTemplateAnnotation: "abc",
+				infrav1.HostNodeReuseSinceAnnotation:    sinceAnnotation,
+			},
+			ExpectedReservations: []infrav1.NodeReuseReservation{{
+				Host: "host-1", Value: "md-workers", Since: &metav1.Time{Time: since},
+			}},
+		}),
+		Entry("Host reserved with the node reuse label TTL", testCaseUpdateNodeReuseReservations{
+			TTL:    time.Hour,
+			Labels: map[string]string{nodeReuseLabelName: "md-workers"},
+			Annotations: map[string]string{
+				infrav1.HostNodeReuseTemplateAnnotation: "abc",
+				infrav1.HostNodeReuseSinceAnnotation:    sinceAnnotation,
+			},
+			ExpectedReservations: []infrav1.NodeReuseReservation{{
+				Host: "host-1", Value: "md-workers", Since: &metav1.Time{Time: since},
+				ExpiresAt: &metav1.Time{Time: since.Add(time.Hour)},
+			}},
+		}),
+		Entry("Host reserved with the grace period of the template", testCaseUpdateNodeReuseReservations{
+			TTL:    time.Hour,
+			Labels: map[string]string{nodeReuseLabelName: "md-workers"},
+			Annotations: map[string]string{
+				infrav1.HostNodeReuseTemplateAnnotation:    "abc",
+				infrav1.HostNodeReuseSinceAnnotation:       sinceAnnotation,
+				infrav1.HostNodeReuseGracePeriodAnnotation: "30m0s",
+			},
+			ExpectedReservations: []infrav1.NodeReuseReservation{{
+				Host: "host-1", Value: "md-workers", Since: &metav1.Time{Time: since},
+				ExpiresAt: &metav1.Time{Time: since.Add(30 * time.Minute)},
+			}},
+		}),
+		Entry("Host released by another template", testCaseUpdateNodeReuseReservations{
+			Labels:      map[string]string{nodeReuseLabelName: "md-workers"},
+			Annotations: map[string]string{infrav1.HostNodeReuseTemplateAnnotation: "xyz"},
+		}),
+		Entry("Host consumed again", testCaseUpdateNodeReuseReservations{
+			Labels:      map[string]string{nodeReuseLabelName: "md-workers"},
+			Annotations: map[string]string{infrav1.HostNodeReuseTemplateAnnotation: "abc"},
+			Consumed:    true,
+		}),
+		Entry("Host whose node reuse label was removed", testCaseUpdateNodeReuseReservations{
+			Annotations: map[string]string{infrav1.HostNodeReuseTemplateAnnotation: "abc"},
+		}),
+	)
 })
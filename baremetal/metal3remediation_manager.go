@@ -30,7 +30,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
@@ -44,6 +48,21 @@ const (
 	powerOffAnnotation              = "reboot.metal3.io/metal3-remediation-%s"
 	nodeAnnotationsBackupAnnotation = "remediation.metal3.io/node-annotations-backup"
 	nodeLabelsBackupAnnotation      = "remediation.metal3.io/node-labels-backup"
+	// defaultRemediationDrainTimeout bounds the wait for the pods of the node
+	// tainted out of service when the Machine does not set nodeDrainTimeout.
+	defaultRemediationDrainTimeout = 5 * time.Minute
+)
+
+var (
+	// outOfServiceTaint is set on the node while the host is powered off.
+	outOfServiceTaint = &corev1.Taint{
+		Key:    corev1.TaintNodeOutOfService,
+		Value:  "nodeshutdown",
+		Effect: corev1.TaintEffectNoExecute,
+	}
+	// outOfServiceTaintMinVersion is the first Kubernetes version where the
+	// out-of-service taint is generally available.
+	outOfServiceTaintMinVersion = utilversion.MustParseGeneric("1.28")
 )
 
 // RemediationManagerInterface is an interface for a RemediationManager.
@@ -58,6 +77,7 @@ type RemediationManagerInterface interface {
 	IsPoweredOn(ctx context.Context) (bool, error)
 	SetUnhealthyAnnotation(ctx context.Context) error
 	GetUnhealthyHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error)
+	SetRemediatedHost(host *bmov1alpha1.BareMetalHost)
 	OnlineStatus(host *bmov1alpha1.BareMetalHost) bool
 	GetRemediationType() infrav1.RemediationType
 	RetryLimitIsSet() bool
@@ -68,15 +88,29 @@ type RemediationManagerInterface interface {
 	SetLastRemediationTime(remediationTime *metav1.Time)
 	GetTimeout() *metav1.Duration
 	IncreaseRetryCount()
+	StartRemediationAttempt(startTime *metav1.Time)
+	RecordPowerState(poweredOn bool)
+	RecordNodeAction(action infrav1.NodeRemediationMechanism)
+	EndRemediationAttempt(outcome string)
 	SetOwnerRemediatedConditionNew(ctx context.Context) error
 	GetCapiMachine(ctx context.Context) (*clusterv1.Machine, error)
+	DeleteCapiMachine(ctx context.Context) error
+	SetCapiMachineDeleteHook(ctx context.Context) error
+	RemoveCapiMachineDeleteHook(ctx context.Context) error
 	GetNode(ctx context.Context, clusterClient v1.CoreV1Interface) (*corev1.Node, error)
 	UpdateNode(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error
 	DeleteNode(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error
+	SupportsOutOfServiceTaint(ctx context.Context, clusterClient v1.CoreV1Interface) (bool, error)
+	SetOutOfServiceTaint(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error
+	RemoveOutOfServiceTaint(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error
+	WaitForNodeDrain(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) (bool, error)
+	GetNodeRemediationMechanism() infrav1.NodeRemediationMechanism
+	SetNodeRemediationMechanism(mechanism infrav1.NodeRemediationMechanism)
 	GetClusterClient(ctx context.Context) (v1.CoreV1Interface, error)
 	SetNodeBackupAnnotations(annotations string, labels string) bool
 	GetNodeBackupAnnotations() (annotations, labels string)
 	RemoveNodeBackupAnnotations()
+	DeleteRemediation(ctx context.Context) error
 }
 
 // RemediationManager is responsible for performing remediation reconciliation.
@@ -229,9 +263,16 @@ func (r *RemediationManager) SetUnhealthyAnnotation(ctx context.Context) error {
 }
 
 // GetUnhealthyHost gets the associated host for unhealthy machine. Returns nil if not found. Assumes the
-// host is in the same namespace as the unhealthy machine.
+// host is in the same namespace as the unhealthy machine. Without Metal3Machine, the host recorded in the
+// remediation status is returned, unless it was replaced or consumed by another Metal3Machine since.
 func (r *RemediationManager) GetUnhealthyHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, *patch.Helper, error) {
-	host, err := getUnhealthyHost(ctx, r.Metal3Machine, r.Client, r.Log)
+	var host *bmov1alpha1.BareMetalHost
+	var err error
+	if r.Metal3Machine == nil {
+		host, err = r.getRemediatedHost(ctx)
+	} else {
+		host, err = getUnhealthyHost(ctx, r.Metal3Machine, r.Client, r.Log)
+	}
 	if err != nil || host == nil {
 		return host, nil, err
 	}
@@ -273,6 +314,75 @@ func getUnhealthyHost(ctx context.Context, m3Machine *infrav1.Metal3Machine, cl
 	return &host, nil
 }
 
+// SetRemediatedHost records the host, its consumer, the failure domain of the
+// Machine and the zone of the host in the remediation status, if not done yet.
+func (r *RemediationManager) SetRemediatedHost(host *bmov1alpha1.BareMetalHost) {
+	if host == nil || r.Metal3Remediation.Status.HostRef != nil {
+		return
+	}
+	r.Metal3Remediation.Status.HostRef = &corev1.ObjectReference{
+		Kind:       "BareMetalHost",
+		APIVersion: bmov1alpha1.GroupVersion.String(),
+		Name:       host.Name,
+		Namespace:  host.Namespace,
+		UID:        host.UID,
+	}
+	if r.Metal3Machine != nil {
+		r.Metal3Remediation.Status.ConsumerRef = &corev1.ObjectReference{
+			Kind:       "Metal3Machine",
+			APIVersion: infrav1.GroupVersion.String(),
+			Name:       r.Metal3Machine.Name,
+			Namespace:  r.Metal3Machine.Namespace,
+			UID:        r.Metal3Machine.UID,
+		}
+	}
+	// The failure domain of the host, given by the failureDomainLabel of the
+	// Metal3Cluster, is the one of a Machine without failure domain.
+	switch {
+	case r.Machine != nil && r.Machine.Spec.FailureDomain != nil && *r.Machine.Spec.FailureDomain != "":
+		r.Metal3Remediation.Status.FailureDomain = *r.Machine.Spec.FailureDomain
+	case r.Metal3Machine != nil:
+		r.Metal3Remediation.Status.FailureDomain = r.Metal3Machine.Status.FailureDomain
+	}
+	r.Metal3Remediation.Status.HostZone = host.Labels[corev1.LabelTopologyZone]
+}
+
+// getRemediatedHost returns the host recorded in the remediation status, or
+// nil if it is gone, was recreated, or is consumed by another Metal3Machine.
+func (r *RemediationManager) getRemediatedHost(ctx context.Context) (*bmov1alpha1.BareMetalHost, error) {
+	hostRef := r.Metal3Remediation.Status.HostRef
+	if hostRef == nil {
+		return nil, errors.New("no host recorded for the remediation")
+	}
+
+	host := &bmov1alpha1.BareMetalHost{}
+	key := client.ObjectKey{
+		Name:      hostRef.Name,
+		Namespace: hostRef.Namespace,
+	}
+	if err := r.Client.Get(ctx, key, host); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Log.Info("Remediated host not found", "host", hostRef.Name)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if hostRef.UID != "" && host.UID != hostRef.UID {
+		r.Log.Info("Remediated host was recreated, ignoring it", "host", host.Name)
+		return nil, nil
+	}
+
+	consumerRef := r.Metal3Remediation.Status.ConsumerRef
+	if host.Spec.ConsumerRef != nil && consumerRef != nil {
+		if host.Spec.ConsumerRef.Name != consumerRef.Name ||
+			(host.Spec.ConsumerRef.UID != "" && consumerRef.UID != "" && host.Spec.ConsumerRef.UID != consumerRef.UID) {
+			r.Log.Info("Remediated host is consumed by another machine, ignoring it", "host", host.Name)
+			return nil, nil
+		}
+	}
+	return host, nil
+}
+
 // OnlineStatus returns hosts Online field value.
 func (r *RemediationManager) OnlineStatus(host *bmov1alpha1.BareMetalHost) bool {
 	return host.Spec.Online
@@ -302,9 +412,14 @@ func (r *RemediationManager) HasReachRetryLimit() bool {
 	return r.Metal3Remediation.Spec.Strategy.RetryLimit == r.Metal3Remediation.Status.RetryCount
 }
 
-// SetRemediationPhase setting the state of the remediation.
+// SetRemediationPhase setting the state of the remediation and the time of the
+// phase transition.
 func (r *RemediationManager) SetRemediationPhase(phase string) {
 	r.Log.Info("Switching remediation phase", "remediationPhase", phase)
+	if r.Metal3Remediation.Status.Phase != phase {
+		now := metav1.Now()
+		r.Metal3Remediation.Status.LastPhaseTransition = &now
+	}
 	r.Metal3Remediation.Status.Phase = phase
 }
 
@@ -334,6 +449,70 @@ func (r *RemediationManager) IncreaseRetryCount() {
 	r.Metal3Remediation.Status.RetryCount++
 }
 
+// StartRemediationAttempt appends an attempt for the current retry count to
+// the history on Status, unless it was already recorded, dropping the oldest
+// attempts beyond the limit.
+func (r *RemediationManager) StartRemediationAttempt(startTime *metav1.Time) {
+	status := &r.Metal3Remediation.Status
+	if attempt := r.currentRemediationAttempt(); attempt != nil && attempt.RetryCount == status.RetryCount {
+		return
+	}
+	status.History = append(status.History, infrav1.RemediationAttempt{
+		RetryCount: status.RetryCount,
+		StartTime:  *startTime,
+		Strategy:   r.GetRemediationType(),
+	})
+	if len(status.History) > infrav1.RemediationHistoryLimit {
+		status.History = status.History[len(status.History)-infrav1.RemediationHistoryLimit:]
+	}
+}
+
+// RecordPowerState records the observed power state of the host on the
+// current remediation attempt, when it differs from the last one recorded.
+func (r *RemediationManager) RecordPowerState(poweredOn bool) {
+	attempt := r.currentRemediationAttempt()
+	if attempt == nil {
+		return
+	}
+	if n := len(attempt.PowerTransitions); n > 0 && attempt.PowerTransitions[n-1].PoweredOn == poweredOn {
+		return
+	}
+	attempt.PowerTransitions = append(attempt.PowerTransitions, infrav1.PowerTransition{
+		PoweredOn: poweredOn,
+		Time:      metav1.Now(),
+	})
+}
+
+// RecordNodeAction records how the Node was handled on the current
+// remediation attempt.
+func (r *RemediationManager) RecordNodeAction(action infrav1.NodeRemediationMechanism) {
+	if attempt := r.currentRemediationAttempt(); attempt != nil {
+		attempt.NodeAction = action
+	}
+}
+
+// EndRemediationAttempt records the outcome of the current remediation
+// attempt, unless it was already recorded.
+func (r *RemediationManager) EndRemediationAttempt(outcome string) {
+	attempt := r.currentRemediationAttempt()
+	if attempt == nil || attempt.Outcome != "" {
+		return
+	}
+	now := metav1.Now()
+	attempt.Outcome = outcome
+	attempt.EndTime = &now
+}
+
+// currentRemediationAttempt returns the latest attempt of the history on
+// Status, nil if there is none.
+func (r *RemediationManager) currentRemediationAttempt() *infrav1.RemediationAttempt {
+	history := r.Metal3Remediation.Status.History
+	if len(history) == 0 {
+		return nil
+	}
+	return &history[len(history)-1]
+}
+
 // SetOwnerRemediatedConditionNew sets MachineOwnerRemediatedCondition on CAPI machine object
 // that have failed a healthcheck.
 func (r *RemediationManager) SetOwnerRemediatedConditionNew(ctx context.Context) error {
@@ -367,8 +546,79 @@ func (r *RemediationManager) GetCapiMachine(ctx context.Context) (*clusterv1.Mac
 	return capiMachine, nil
 }
 
+// DeleteCapiMachine deletes the CAPI machine owning the current resource, so
+// that it gets replaced by its owner and the host is deprovisioned.
+func (r *RemediationManager) DeleteCapiMachine(ctx context.Context) error {
+	capiMachine, err := r.GetCapiMachine(ctx)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if capiMachine == nil || !capiMachine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	r.Log.Info("Deleting unhealthy Machine", "machine", capiMachine.Name)
+	err = r.Client.Delete(ctx, capiMachine)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete unhealthy Machine")
+	}
+	return nil
+}
+
+// SetCapiMachineDeleteHook sets the RemediationDeleteHookAnnotation on the
+// CAPI machine, the deletion of the Machine is held until the remediation is
+// over. A Machine already being deleted is left alone.
+func (r *RemediationManager) SetCapiMachineDeleteHook(ctx context.Context) error {
+	capiMachine := r.Machine
+	if capiMachine == nil || !capiMachine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if _, ok := capiMachine.Annotations[infrav1.RemediationDeleteHookAnnotation]; ok {
+		return nil
+	}
+	machineHelper, err := patch.NewHelper(capiMachine, r.Client)
+	if err != nil {
+		return errors.Wrap(err, "failed to create patch helper for Machine")
+	}
+	if capiMachine.Annotations == nil {
+		capiMachine.Annotations = map[string]string{}
+	}
+	capiMachine.Annotations[infrav1.RemediationDeleteHookAnnotation] = r.Metal3Remediation.Name
+	r.Log.Info("Setting the remediation delete hook on the Machine", "machine", capiMachine.Name)
+	if err := machineHelper.Patch(ctx, capiMachine); err != nil {
+		return errors.Wrap(err, "failed to set the remediation delete hook on the Machine")
+	}
+	return nil
+}
+
+// RemoveCapiMachineDeleteHook removes the RemediationDeleteHookAnnotation
+// from the CAPI machine, once the remediation is over or escalates to the
+// deletion of the Machine.
+func (r *RemediationManager) RemoveCapiMachineDeleteHook(ctx context.Context) error {
+	capiMachine := r.Machine
+	if capiMachine == nil {
+		return nil
+	}
+	if _, ok := capiMachine.Annotations[infrav1.RemediationDeleteHookAnnotation]; !ok {
+		return nil
+	}
+	machineHelper, err := patch.NewHelper(capiMachine, r.Client)
+	if err != nil {
+		return errors.Wrap(err, "failed to create patch helper for Machine")
+	}
+	delete(capiMachine.Annotations, infrav1.RemediationDeleteHookAnnotation)
+	r.Log.Info("Removing the remediation delete hook from the Machine", "machine", capiMachine.Name)
+	if err := machineHelper.Patch(ctx, capiMachine); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to remove the remediation delete hook from the Machine")
+	}
+	return nil
+}
+
 // GetNode returns the Node associated with the machine in the current context.
 func (r *RemediationManager) GetNode(ctx context.Context, clusterClient v1.CoreV1Interface) (*corev1.Node, error) {
+	defer LogDuration(r.Log, "node get", time.Now())
 	capiMachine, err := r.GetCapiMachine(ctx)
 	if err != nil {
 		r.Log.Error(err, "metal3Remediation's node could not be retrieved")
@@ -391,6 +641,7 @@ func (r *RemediationManager) GetNode(ctx context.Context, clusterClient v1.CoreV
 
 // UpdateNode updates the given node.
 func (r *RemediationManager) UpdateNode(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error {
+	defer LogDuration(r.Log, "node update", time.Now())
 	_, err := clusterClient.Nodes().Update(ctx, node, metav1.UpdateOptions{})
 	if err != nil {
 		r.Log.Error(err, "Could not update cluster node")
@@ -401,6 +652,7 @@ func (r *RemediationManager) UpdateNode(ctx context.Context, clusterClient v1.Co
 
 // DeleteNode deletes the given node.
 func (r *RemediationManager) DeleteNode(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error {
+	defer LogDuration(r.Log, "node deletion", time.Now())
 	if !node.DeletionTimestamp.IsZero() {
 		return nil
 	}
@@ -413,6 +665,138 @@ func (r *RemediationManager) DeleteNode(ctx context.Context, clusterClient v1.Co
 	return nil
 }
 
+// SupportsOutOfServiceTaint returns whether the workload cluster handles the
+// out-of-service taint, i.e. runs Kubernetes 1.28 or later.
+func (r *RemediationManager) SupportsOutOfServiceTaint(_ context.Context, clusterClient v1.CoreV1Interface) (bool, error) {
+	restClient := clusterClient.RESTClient()
+	if rc, ok := restClient.(*rest.RESTClient); restClient == nil || (ok && rc == nil) {
+		return false, errors.New("no REST client to get the version of the cluster")
+	}
+	info, err := discovery.NewDiscoveryClient(restClient).ServerVersion()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get the version of the cluster")
+	}
+	serverVersion, err := utilversion.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse the version %q of the cluster", info.GitVersion)
+	}
+	return serverVersion.AtLeast(outOfServiceTaintMinVersion), nil
+}
+
+// SetOutOfServiceTaint adds the out-of-service NoExecute taint to the node,
+// so that its pods are deleted and their volumes detached while the host is
+// powered off. The wait for the drain of the node starts over.
+func (r *RemediationManager) SetOutOfServiceTaint(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error {
+	conditions.Delete(r.Metal3Remediation, infrav1.NodeDrainedCondition)
+	for _, taint := range node.Spec.Taints {
+		if taint.MatchTaint(outOfServiceTaint) {
+			return nil
+		}
+	}
+	node.Spec.Taints = append(node.Spec.Taints, *outOfServiceTaint)
+	return r.UpdateNode(ctx, clusterClient, node)
+}
+
+// RemoveOutOfServiceTaint removes the out-of-service taint from the node.
+func (r *RemediationManager) RemoveOutOfServiceTaint(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) error {
+	taints := []corev1.Taint{}
+	for _, taint := range node.Spec.Taints {
+		if !taint.MatchTaint(outOfServiceTaint) {
+			taints = append(taints, taint)
+		}
+	}
+	if len(taints) == len(node.Spec.Taints) {
+		return nil
+	}
+	node.Spec.Taints = taints
+	return r.UpdateNode(ctx, clusterClient, node)
+}
+
+// WaitForNodeDrain returns whether the pods of the node tainted out of service
+// are deleted and its volumes detached, or the nodeDrainTimeout and
+// nodeVolumeDetachTimeout of the Machine, or the CAPM3 defaults when unset,
+// expired. The wait and the effective timeouts are recorded in the
+// NodeDrainedCondition.
+func (r *RemediationManager) WaitForNodeDrain(ctx context.Context, clusterClient v1.CoreV1Interface, node *corev1.Node) (bool, error) {
+	drained := conditions.Get(r.Metal3Remediation, infrav1.NodeDrainedCondition)
+	if drained != nil && (drained.Status == corev1.ConditionTrue ||
+		(drained.Reason != infrav1.DrainingNodeReason && drained.Reason != infrav1.WaitingForVolumeDetachReason)) {
+		return true, nil
+	}
+	capiMachine, err := r.GetCapiMachine(ctx)
+	if err != nil {
+		return false, err
+	}
+	if drained == nil {
+		drainTimeout, drainTimeoutSource := nodeDrainTimeout(capiMachine, defaultRemediationDrainTimeout, "default remediation drain timeout")
+		conditions.MarkFalse(r.Metal3Remediation, infrav1.NodeDrainedCondition, infrav1.DrainingNodeReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for the pods of Node %s to be deleted with a timeout of %s from the %s", node.Name, drainTimeout, drainTimeoutSource)
+		drained = conditions.Get(r.Metal3Remediation, infrav1.NodeDrainedCondition)
+	}
+
+	if drained.Reason == infrav1.DrainingNodeReason {
+		pods, err := clusterClient.Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to list the pods of node %s", node.Name)
+		}
+		remaining := 0
+		for i := range pods.Items {
+			if podNeedsEviction(&pods.Items[i]) {
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			drainTimeout, drainTimeoutSource := nodeDrainTimeout(capiMachine, defaultRemediationDrainTimeout, "default remediation drain timeout")
+			if time.Since(drained.LastTransitionTime.Time) <= drainTimeout {
+				r.Log.Info("Waiting for the pods of the node to be deleted", "node", node.Name, "remaining", remaining)
+				return false, nil
+			}
+			r.Log.Info("Node drain timeout exceeded, proceeding with the remediation", "node", node.Name, "timeout", drainTimeout)
+			conditions.MarkFalse(r.Metal3Remediation, infrav1.NodeDrainedCondition, infrav1.DrainTimeoutExceededReason, clusterv1.ConditionSeverityWarning,
+				"Pods of Node %s not deleted within the %s of %s", node.Name, drainTimeoutSource, drainTimeout)
+			return true, nil
+		}
+		if len(node.Status.VolumesAttached) > 0 {
+			detachTimeout, detachTimeoutSource := nodeVolumeDetachTimeout(capiMachine)
+			r.Log.Info("Waiting for the volumes of the node to detach", "node", node.Name, "volumes", len(node.Status.VolumesAttached))
+			conditions.MarkFalse(r.Metal3Remediation, infrav1.NodeDrainedCondition, infrav1.WaitingForVolumeDetachReason, clusterv1.ConditionSeverityInfo,
+				"Waiting for the volumes of Node %s to detach with a timeout of %s from the %s", node.Name, detachTimeout, detachTimeoutSource)
+			return false, nil
+		}
+		conditions.MarkTrue(r.Metal3Remediation, infrav1.NodeDrainedCondition)
+		return true, nil
+	}
+
+	if len(node.Status.VolumesAttached) == 0 {
+		conditions.MarkTrue(r.Metal3Remediation, infrav1.NodeDrainedCondition)
+		return true, nil
+	}
+	detachTimeout, detachTimeoutSource := nodeVolumeDetachTimeout(capiMachine)
+	if time.Since(drained.LastTransitionTime.Time) <= detachTimeout {
+		r.Log.Info("Waiting for the volumes of the node to detach", "node", node.Name, "volumes", len(node.Status.VolumesAttached))
+		return false, nil
+	}
+	r.Log.Info("Volume detach timeout exceeded, proceeding with the remediation", "node", node.Name, "timeout", detachTimeout)
+	conditions.MarkFalse(r.Metal3Remediation, infrav1.NodeDrainedCondition, infrav1.VolumeDetachTimeoutExceededReason, clusterv1.ConditionSeverityWarning,
+		"Volumes of Node %s not detached within the %s of %s", node.Name, detachTimeoutSource, detachTimeout)
+	return true, nil
+}
+
+// GetNodeRemediationMechanism returns how the node is handled during the
+// remediation, empty until it is chosen.
+func (r *RemediationManager) GetNodeRemediationMechanism() infrav1.NodeRemediationMechanism {
+	return r.Metal3Remediation.Status.NodeRemediationMechanism
+}
+
+// SetNodeRemediationMechanism records how the node is handled during the
+// remediation.
+func (r *RemediationManager) SetNodeRemediationMechanism(mechanism infrav1.NodeRemediationMechanism) {
+	r.Log.Info("Node remediation mechanism", "mechanism", mechanism)
+	r.Metal3Remediation.Status.NodeRemediationMechanism = mechanism
+}
+
 // GetClusterClient returns the client for interacting with the target cluster.
 func (r *RemediationManager) GetClusterClient(ctx context.Context) (v1.CoreV1Interface, error) {
 	capiMachine, err := r.GetCapiMachine(ctx)
@@ -473,6 +857,19 @@ func (r *RemediationManager) RemoveNodeBackupAnnotations() {
 	delete(rem.Annotations, nodeLabelsBackupAnnotation)
 }
 
+// DeleteRemediation deletes the Metal3Remediation itself.
+func (r *RemediationManager) DeleteRemediation(ctx context.Context) error {
+	if !r.Metal3Remediation.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	r.Log.Info("Deleting remediation", "remediation", r.Metal3Remediation.Name)
+	err := r.Client.Delete(ctx, r.Metal3Remediation)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete remediation")
+	}
+	return nil
+}
+
 // getPowerOffAnnotationKey returns the key of the power off annotation.
 func (r *RemediationManager) getPowerOffAnnotationKey() string {
 	return fmt.Sprintf(powerOffAnnotation, r.Metal3Remediation.UID)
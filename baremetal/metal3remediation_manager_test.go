@@ -14,7 +14,10 @@ limitations under the License.
 package baremetal
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net/http"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -28,13 +31,50 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	clientfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
+// versionedCoreV1 is a fake client of the workload cluster that also serves
+// the version of the cluster.
+type versionedCoreV1 struct {
+	clientcorev1.CoreV1Interface
+	restClient rest.Interface
+}
+
+func (c versionedCoreV1) RESTClient() rest.Interface {
+	return c.restClient
+}
+
+// newVersionedCoreV1 returns a fake client of a workload cluster running the
+// given version, or failing to return its version if empty.
+func newVersionedCoreV1(gitVersion string, objects ...runtime.Object) versionedCoreV1 {
+	return versionedCoreV1{
+		CoreV1Interface: clientfake.NewSimpleClientset(objects...).CoreV1(),
+		restClient: &restfake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				if gitVersion == "" || req.URL.Path != "/version" {
+					return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(&bytes.Buffer{})}, nil
+				}
+				body := `{"major": "1", "gitVersion": "` + gitVersion + `"}`
+				header := http.Header{}
+				header.Set("Content-Type", "application/json")
+				return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			}),
+		},
+	}
+}
+
 type testCaseRemediationManager struct {
 	Metal3Remediation *infrav1.Metal3Remediation
 	Metal3Machine     *infrav1.Metal3Machine
@@ -664,10 +704,16 @@ var _ = Describe("Metal3Remediation manager", func() {
 				logr.Discard(),
 			)
 			Expect(err).NotTo(HaveOccurred())
+			phaseChanged := tc.Metal3Remediation.Status.Phase != infrav1.PhaseRunning
 
 			remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
 
 			Expect(tc.Metal3Remediation.Status.Phase).To(Equal("Running"))
+			if phaseChanged {
+				Expect(tc.Metal3Remediation.Status.LastPhaseTransition).NotTo(BeNil())
+			} else {
+				Expect(tc.Metal3Remediation.Status.LastPhaseTransition).To(BeNil())
+			}
 		},
 		Entry("No phase", testCaseRemediationManager{
 			Metal3Remediation: &infrav1.Metal3Remediation{},
@@ -679,6 +725,13 @@ var _ = Describe("Metal3Remediation manager", func() {
 				},
 			},
 		}),
+		Entry("Same phase", testCaseRemediationManager{
+			Metal3Remediation: &infrav1.Metal3Remediation{
+				Status: infrav1.Metal3RemediationStatus{
+					Phase: "Running",
+				},
+			},
+		}),
 	)
 
 	DescribeTable("Test SetLastRemediationTime",
@@ -736,6 +789,88 @@ var _ = Describe("Metal3Remediation manager", func() {
 		}),
 	)
 
+	DescribeTable("Test remediation history",
+		func(previousAttempts int, expectedLength int) {
+			metal3Remediation := &infrav1.Metal3Remediation{
+				Spec: infrav1.Metal3RemediationSpec{
+					Strategy: &infrav1.RemediationStrategy{
+						Type:       infrav1.RebootRemediationStrategy,
+						RetryLimit: 3,
+					},
+				},
+			}
+			for i := 0; i < previousAttempts; i++ {
+				metal3Remediation.Status.History = append(metal3Remediation.Status.History, infrav1.RemediationAttempt{
+					RetryCount: -previousAttempts + i,
+					Outcome:    infrav1.RemediationOutcomeTimedOut,
+				})
+			}
+			remediationMgr, err := NewRemediationManager(nil, nil, metal3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			// simulate three reboot attempts, the first two timing out
+			for attempt := 0; attempt < 3; attempt++ {
+				if attempt > 0 {
+					remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeTimedOut)
+					remediationMgr.IncreaseRetryCount()
+				}
+				now := metav1.Now()
+				remediationMgr.StartRemediationAttempt(&now)
+				// a reconcile of the same attempt does not add another entry
+				remediationMgr.StartRemediationAttempt(&now)
+				remediationMgr.RecordPowerState(true)
+				remediationMgr.RecordPowerState(false)
+				remediationMgr.RecordPowerState(false)
+				remediationMgr.RecordNodeAction(infrav1.NodeRemediationDeletion)
+				remediationMgr.RecordPowerState(true)
+			}
+			remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
+			remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeTimedOut)
+
+			history := metal3Remediation.Status.History
+			Expect(history).To(HaveLen(expectedLength))
+			if previousAttempts > 0 {
+				// the oldest attempts are dropped
+				Expect(history[0].RetryCount).To(Equal(-expectedLength + 3))
+			}
+			for i, attempt := range history[expectedLength-3:] {
+				Expect(attempt.RetryCount).To(Equal(i))
+				Expect(attempt.Strategy).To(Equal(infrav1.RebootRemediationStrategy))
+				Expect(attempt.StartTime.IsZero()).To(BeFalse())
+				Expect(attempt.NodeAction).To(Equal(infrav1.NodeRemediationDeletion))
+				Expect(attempt.PowerTransitions).To(HaveLen(3))
+				Expect(attempt.PowerTransitions[0].PoweredOn).To(BeTrue())
+				Expect(attempt.PowerTransitions[1].PoweredOn).To(BeFalse())
+				Expect(attempt.PowerTransitions[2].PoweredOn).To(BeTrue())
+				Expect(attempt.EndTime).NotTo(BeNil())
+				if i < 2 {
+					Expect(attempt.Outcome).To(Equal(infrav1.RemediationOutcomeTimedOut))
+				} else {
+					Expect(attempt.Outcome).To(Equal(infrav1.RemediationOutcomeSucceeded))
+				}
+			}
+		},
+		Entry("No previous attempt", 0, 3),
+		Entry("Previous attempts below the limit", 5, 8),
+		Entry("Previous attempts reaching the limit", 9, infrav1.RemediationHistoryLimit),
+		Entry("Previous attempts at the limit", infrav1.RemediationHistoryLimit, infrav1.RemediationHistoryLimit),
+	)
+
+	It("Does not record anything without a remediation attempt", func() {
+		metal3Remediation := &infrav1.Metal3Remediation{}
+		remediationMgr, err := NewRemediationManager(nil, nil, metal3Remediation, nil, nil,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		remediationMgr.RecordPowerState(false)
+		remediationMgr.RecordNodeAction(infrav1.NodeRemediationOutOfServiceTaint)
+		remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
+		Expect(metal3Remediation.Status.History).To(BeEmpty())
+	})
+
 	type testCaseGetRemediationPhase struct {
 		Metal3Remediation *infrav1.Metal3Remediation
 		Succeed           bool
@@ -990,4 +1125,613 @@ var _ = Describe("Metal3Remediation manager", func() {
 		})
 
 	})
+
+	DescribeTable("Test SupportsOutOfServiceTaint",
+		func(gitVersion string, expectSupported bool, expectError bool) {
+			remediationMgr, err := NewRemediationManager(nil, nil, &infrav1.Metal3Remediation{}, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			supported, err := remediationMgr.SupportsOutOfServiceTaint(context.TODO(), newVersionedCoreV1(gitVersion))
+			if expectError {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(supported).To(Equal(expectSupported))
+		},
+		Entry("Kubernetes 1.28", "v1.28.0", true, false),
+		Entry("Kubernetes 1.29 with build metadata", "v1.29.1+k3s1", true, false),
+		Entry("Kubernetes 1.27", "v1.27.6", false, false),
+		Entry("Invalid version", "latest", false, true),
+		Entry("Version unavailable", "", false, true),
+	)
+
+	It("Does not check the version without REST client", func() {
+		remediationMgr, err := NewRemediationManager(nil, nil, &infrav1.Metal3Remediation{}, nil, nil,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		supported, err := remediationMgr.SupportsOutOfServiceTaint(context.TODO(), clientfake.NewSimpleClientset().CoreV1())
+		Expect(err).To(HaveOccurred())
+		Expect(supported).To(BeFalse())
+	})
+
+	It("Sets and removes the out-of-service taint", func() {
+		otherTaint := corev1.Taint{Key: "foo", Effect: corev1.TaintEffectNoSchedule}
+		clusterClient := newVersionedCoreV1("v1.28.0", &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "mynode"},
+			Spec:       corev1.NodeSpec{Taints: []corev1.Taint{otherTaint}},
+		})
+		m3Remediation := &infrav1.Metal3Remediation{}
+		remediationMgr, err := NewRemediationManager(nil, nil, m3Remediation, nil, nil,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		remediationMgr.SetNodeRemediationMechanism(infrav1.NodeRemediationOutOfServiceTaint)
+		Expect(m3Remediation.Status.NodeRemediationMechanism).To(Equal(infrav1.NodeRemediationOutOfServiceTaint))
+		Expect(remediationMgr.GetNodeRemediationMechanism()).To(Equal(infrav1.NodeRemediationOutOfServiceTaint))
+
+		getNode := func() *corev1.Node {
+			node, err := clusterClient.Nodes().Get(context.TODO(), "mynode", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			return node
+		}
+
+		By("Setting the taint, once")
+		Expect(remediationMgr.SetOutOfServiceTaint(context.TODO(), clusterClient, getNode())).To(Succeed())
+		Expect(remediationMgr.SetOutOfServiceTaint(context.TODO(), clusterClient, getNode())).To(Succeed())
+		Expect(getNode().Spec.Taints).To(Equal([]corev1.Taint{otherTaint, {
+			Key:    corev1.TaintNodeOutOfService,
+			Value:  "nodeshutdown",
+			Effect: corev1.TaintEffectNoExecute,
+		}}))
+
+		By("Removing the taint")
+		Expect(remediationMgr.RemoveOutOfServiceTaint(context.TODO(), clusterClient, getNode())).To(Succeed())
+		Expect(getNode().Spec.Taints).To(Equal([]corev1.Taint{otherTaint}))
+		Expect(remediationMgr.RemoveOutOfServiceTaint(context.TODO(), clusterClient, getNode())).To(Succeed())
+
+		By("Failing to set the taint on a deleted node")
+		Expect(clusterClient.Nodes().Delete(context.TODO(), "mynode", metav1.DeleteOptions{})).To(Succeed())
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mynode"}}
+		Expect(remediationMgr.SetOutOfServiceTaint(context.TODO(), clusterClient, node)).NotTo(Succeed())
+	})
+
+	type testCaseWaitForNodeDrain struct {
+		MachineDrainTimeout  *metav1.Duration
+		MachineDetachTimeout *metav1.Duration
+		Pods                 []string
+		VolumesAttached      bool
+		StartedReason        string
+		StartedAgo           time.Duration
+		ExpectDrained        bool
+		ExpectedStatus       corev1.ConditionStatus
+		ExpectedReason       string
+		ExpectedMessage      string
+	}
+
+	DescribeTable("Test WaitForNodeDrain",
+		func(tc testCaseWaitForNodeDrain) {
+			m3Remediation := &infrav1.Metal3Remediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myremediation",
+					Namespace: namespaceName,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Machine",
+							Name:       "mymachine",
+						},
+					},
+				},
+			}
+			if tc.StartedReason != "" {
+				conditions.MarkFalse(m3Remediation, infrav1.NodeDrainedCondition, tc.StartedReason, clusterv1.ConditionSeverityInfo, "")
+				m3Remediation.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-tc.StartedAgo))
+			}
+			capiMachine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "mymachine", Namespace: namespaceName},
+				Spec: clusterv1.MachineSpec{
+					NodeDrainTimeout:        tc.MachineDrainTimeout,
+					NodeVolumeDetachTimeout: tc.MachineDetachTimeout,
+				},
+			}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mynode"}}
+			if tc.VolumesAttached {
+				node.Status.VolumesAttached = []corev1.AttachedVolume{{Name: "volume"}}
+			}
+			objects := []runtime.Object{node}
+			for _, name := range tc.Pods {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName},
+					Spec:       corev1.PodSpec{NodeName: node.Name},
+				})
+			}
+			clusterClient := clientfake.NewSimpleClientset(objects...).CoreV1()
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(capiMachine).Build()
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			drained, err := remediationMgr.WaitForNodeDrain(context.TODO(), clusterClient, node)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drained).To(Equal(tc.ExpectDrained))
+			condition := conditions.Get(m3Remediation, infrav1.NodeDrainedCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(tc.ExpectedStatus))
+			Expect(condition.Reason).To(Equal(tc.ExpectedReason))
+			Expect(condition.Message).To(ContainSubstring(tc.ExpectedMessage))
+		},
+		Entry("Pods remaining, default drain timeout", testCaseWaitForNodeDrain{
+			Pods:            []string{"app"},
+			ExpectedStatus:  corev1.ConditionFalse,
+			ExpectedReason:  infrav1.DrainingNodeReason,
+			ExpectedMessage: "timeout of 5m0s from the default remediation drain timeout",
+		}),
+		Entry("Pods remaining, Machine nodeDrainTimeout", testCaseWaitForNodeDrain{
+			MachineDrainTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			Pods:                []string{"app"},
+			ExpectedStatus:      corev1.ConditionFalse,
+			ExpectedReason:      infrav1.DrainingNodeReason,
+			ExpectedMessage:     "timeout of 10m0s from the Machine nodeDrainTimeout",
+		}),
+		Entry("Pods remaining, Machine nodeDrainTimeout not exceeded", testCaseWaitForNodeDrain{
+			MachineDrainTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			Pods:                []string{"app"},
+			StartedReason:       infrav1.DrainingNodeReason,
+			StartedAgo:          6 * time.Minute,
+			ExpectedStatus:      corev1.ConditionFalse,
+			ExpectedReason:      infrav1.DrainingNodeReason,
+		}),
+		Entry("Pods remaining, default drain timeout exceeded", testCaseWaitForNodeDrain{
+			Pods:            []string{"app"},
+			StartedReason:   infrav1.DrainingNodeReason,
+			StartedAgo:      6 * time.Minute,
+			ExpectDrained:   true,
+			ExpectedStatus:  corev1.ConditionFalse,
+			ExpectedReason:  infrav1.DrainTimeoutExceededReason,
+			ExpectedMessage: "default remediation drain timeout of 5m0s",
+		}),
+		Entry("Pods remaining, Machine nodeDrainTimeout exceeded", testCaseWaitForNodeDrain{
+			MachineDrainTimeout: &metav1.Duration{Duration: time.Minute},
+			Pods:                []string{"app"},
+			StartedReason:       infrav1.DrainingNodeReason,
+			StartedAgo:          2 * time.Minute,
+			ExpectDrained:       true,
+			ExpectedStatus:      corev1.ConditionFalse,
+			ExpectedReason:      infrav1.DrainTimeoutExceededReason,
+			ExpectedMessage:     "Machine nodeDrainTimeout of 1m0s",
+		}),
+		Entry("Pods deleted, volumes attached", testCaseWaitForNodeDrain{
+			VolumesAttached: true,
+			StartedReason:   infrav1.DrainingNodeReason,
+			StartedAgo:      time.Minute,
+			ExpectedStatus:  corev1.ConditionFalse,
+			ExpectedReason:  infrav1.WaitingForVolumeDetachReason,
+			ExpectedMessage: "timeout of 5m0s from the default volume detach timeout",
+		}),
+		Entry("Pods deleted, volumes attached, Machine nodeVolumeDetachTimeout", testCaseWaitForNodeDrain{
+			MachineDetachTimeout: &metav1.Duration{Duration: 20 * time.Minute},
+			VolumesAttached:      true,
+			ExpectedStatus:       corev1.ConditionFalse,
+			ExpectedReason:       infrav1.WaitingForVolumeDetachReason,
+			ExpectedMessage:      "timeout of 20m0s from the Machine nodeVolumeDetachTimeout",
+		}),
+		Entry("Volumes attached, Machine nodeVolumeDetachTimeout not exceeded", testCaseWaitForNodeDrain{
+			MachineDetachTimeout: &metav1.Duration{Duration: 20 * time.Minute},
+			VolumesAttached:      true,
+			StartedReason:        infrav1.WaitingForVolumeDetachReason,
+			StartedAgo:           10 * time.Minute,
+			ExpectedStatus:       corev1.ConditionFalse,
+			ExpectedReason:       infrav1.WaitingForVolumeDetachReason,
+		}),
+		Entry("Volumes attached, default volume detach timeout exceeded", testCaseWaitForNodeDrain{
+			VolumesAttached: true,
+			StartedReason:   infrav1.WaitingForVolumeDetachReason,
+			StartedAgo:      10 * time.Minute,
+			ExpectDrained:   true,
+			ExpectedStatus:  corev1.ConditionFalse,
+			ExpectedReason:  infrav1.VolumeDetachTimeoutExceededReason,
+			ExpectedMessage: "default volume detach timeout of 5m0s",
+		}),
+		Entry("Volumes attached, Machine nodeVolumeDetachTimeout exceeded", testCaseWaitForNodeDrain{
+			MachineDetachTimeout: &metav1.Duration{Duration: time.Minute},
+			VolumesAttached:      true,
+			StartedReason:        infrav1.WaitingForVolumeDetachReason,
+			StartedAgo:           2 * time.Minute,
+			ExpectDrained:        true,
+			ExpectedStatus:       corev1.ConditionFalse,
+			ExpectedReason:       infrav1.VolumeDetachTimeoutExceededReason,
+			ExpectedMessage:      "Machine nodeVolumeDetachTimeout of 1m0s",
+		}),
+		Entry("Volumes detached", testCaseWaitForNodeDrain{
+			StartedReason:  infrav1.WaitingForVolumeDetachReason,
+			StartedAgo:     time.Minute,
+			ExpectDrained:  true,
+			ExpectedStatus: corev1.ConditionTrue,
+		}),
+		Entry("Pods deleted and no volumes", testCaseWaitForNodeDrain{
+			ExpectDrained:  true,
+			ExpectedStatus: corev1.ConditionTrue,
+		}),
+	)
+
+	It("Restarts the wait for the drain when the node is tainted", func() {
+		m3Remediation := &infrav1.Metal3Remediation{}
+		conditions.MarkTrue(m3Remediation, infrav1.NodeDrainedCondition)
+		clusterClient := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mynode"}}).CoreV1()
+		remediationMgr, err := NewRemediationManager(nil, nil, m3Remediation, nil, nil,
+			logr.Discard(),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		node, err := clusterClient.Nodes().Get(context.TODO(), "mynode", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remediationMgr.SetOutOfServiceTaint(context.TODO(), clusterClient, node)).To(Succeed())
+		Expect(conditions.Has(m3Remediation, infrav1.NodeDrainedCondition)).To(BeFalse())
+	})
+
+	Describe("Test DeleteCapiMachine", func() {
+		m3Remediation := &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myremediation",
+				Namespace: namespaceName,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "mymachine",
+					},
+				},
+			},
+		}
+
+		It("Should delete the owner machine", func() {
+			capiMachine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: namespaceName,
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(capiMachine).Build()
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.DeleteCapiMachine(context.TODO())).To(Succeed())
+
+			err = fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(capiMachine), &clusterv1.Machine{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected NotFound error")
+		})
+
+		It("Should succeed if the owner machine is already gone", func() {
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.DeleteCapiMachine(context.TODO())).To(Succeed())
+		})
+	})
+
+	Describe("Test CapiMachineDeleteHook", func() {
+		m3Remediation := &infrav1.Metal3Remediation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myremediation",
+				Namespace: namespaceName,
+			},
+		}
+
+		It("Should set and remove the remediation delete hook", func() {
+			capiMachine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mymachine",
+					Namespace: namespaceName,
+					Annotations: map[string]string{
+						clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/other": "someone",
+					},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(capiMachine).Build()
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, capiMachine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			getAnnotations := func() map[string]string {
+				savedMachine := &clusterv1.Machine{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(capiMachine), savedMachine)).To(Succeed())
+				return savedMachine.Annotations
+			}
+
+			Expect(remediationMgr.SetCapiMachineDeleteHook(context.TODO())).To(Succeed())
+			Expect(getAnnotations()).To(HaveKeyWithValue(infrav1.RemediationDeleteHookAnnotation, "myremediation"))
+			// Setting the hook again does nothing.
+			Expect(remediationMgr.SetCapiMachineDeleteHook(context.TODO())).To(Succeed())
+
+			Expect(remediationMgr.RemoveCapiMachineDeleteHook(context.TODO())).To(Succeed())
+			Expect(getAnnotations()).To(Equal(map[string]string{
+				clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/other": "someone",
+			}))
+			Expect(remediationMgr.RemoveCapiMachineDeleteHook(context.TODO())).To(Succeed())
+		})
+
+		It("Should not set the hook on a Machine being deleted", func() {
+			capiMachine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "mymachine",
+					Namespace:         namespaceName,
+					DeletionTimestamp: &metav1.Time{Time: time.Now()},
+					Finalizers:        []string{clusterv1.MachineFinalizer},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(capiMachine).Build()
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, capiMachine,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.SetCapiMachineDeleteHook(context.TODO())).To(Succeed())
+			savedMachine := &clusterv1.Machine{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(capiMachine), savedMachine)).To(Succeed())
+			Expect(savedMachine.Annotations).NotTo(HaveKey(infrav1.RemediationDeleteHookAnnotation))
+		})
+
+		It("Should do nothing without Machine", func() {
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.SetCapiMachineDeleteHook(context.TODO())).To(Succeed())
+			Expect(remediationMgr.RemoveCapiMachineDeleteHook(context.TODO())).To(Succeed())
+		})
+	})
+
+	Describe("Test remediated host tracking", func() {
+		remediatedHostRef := &corev1.ObjectReference{
+			Name:      baremetalhostName,
+			Namespace: namespaceName,
+			UID:       "bmh-uid",
+		}
+		remediatedConsumerRef := &corev1.ObjectReference{
+			Name:      metal3machineName,
+			Namespace: namespaceName,
+			UID:       "m3m-uid",
+		}
+
+		It("Should record the host and its consumer once", func() {
+			m3Remediation := &infrav1.Metal3Remediation{}
+			m3Machine := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+					UID:       "m3m-uid",
+				},
+			}
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+					UID:       "bmh-uid",
+				},
+			}
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, m3Machine, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			remediationMgr.SetRemediatedHost(host)
+			Expect(m3Remediation.Status.HostRef.Name).To(Equal(baremetalhostName))
+			Expect(m3Remediation.Status.HostRef.UID).To(Equal(host.UID))
+			Expect(m3Remediation.Status.ConsumerRef.Name).To(Equal(metal3machineName))
+			Expect(m3Remediation.Status.ConsumerRef.UID).To(Equal(m3Machine.UID))
+
+			other := host.DeepCopy()
+			other.Name = "otherhost"
+			remediationMgr.SetRemediatedHost(other)
+			Expect(m3Remediation.Status.HostRef.Name).To(Equal(baremetalhostName))
+		})
+
+		type testCaseRemediatedFailureDomain struct {
+			FailureDomain         *string
+			HostFailureDomain     string
+			HostLabels            map[string]string
+			ExpectedFailureDomain string
+			ExpectedHostZone      string
+		}
+
+		DescribeTable("Test SetRemediatedHost failure domain",
+			func(tc testCaseRemediatedFailureDomain) {
+				m3Remediation := &infrav1.Metal3Remediation{}
+				machine := &clusterv1.Machine{
+					Spec: clusterv1.MachineSpec{FailureDomain: tc.FailureDomain},
+				}
+				host := &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						Labels:    tc.HostLabels,
+					},
+				}
+				m3m := &infrav1.Metal3Machine{
+					Status: infrav1.Metal3MachineStatus{FailureDomain: tc.HostFailureDomain},
+				}
+				remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, m3m, machine,
+					logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				remediationMgr.SetRemediatedHost(host)
+				Expect(m3Remediation.Status.FailureDomain).To(Equal(tc.ExpectedFailureDomain))
+				Expect(m3Remediation.Status.HostZone).To(Equal(tc.ExpectedHostZone))
+			},
+			Entry("Failure domain and host zone recorded", testCaseRemediatedFailureDomain{
+				FailureDomain:         pointer.String("fd-1"),
+				HostLabels:            map[string]string{corev1.LabelTopologyZone: "zone-a"},
+				ExpectedFailureDomain: "fd-1",
+				ExpectedHostZone:      "zone-a",
+			}),
+			Entry("Host without zone label", testCaseRemediatedFailureDomain{
+				FailureDomain:         pointer.String("fd-1"),
+				HostLabels:            map[string]string{"other": "zone-a"},
+				ExpectedFailureDomain: "fd-1",
+			}),
+			Entry("Machine without failure domain", testCaseRemediatedFailureDomain{
+				HostLabels:       map[string]string{corev1.LabelTopologyZone: "zone-a"},
+				ExpectedHostZone: "zone-a",
+			}),
+			Entry("Machine without failure domain, host in a failure domain", testCaseRemediatedFailureDomain{
+				HostFailureDomain:     "rack-a",
+				ExpectedFailureDomain: "rack-a",
+			}),
+			Entry("Failure domain of the Machine preferred", testCaseRemediatedFailureDomain{
+				FailureDomain:         pointer.String("fd-1"),
+				HostFailureDomain:     "rack-a",
+				ExpectedFailureDomain: "fd-1",
+			}),
+		)
+
+		type testCaseGetRemediatedHost struct {
+			HostRef       *corev1.ObjectReference
+			Host          *bmov1alpha1.BareMetalHost
+			ExpectPresent bool
+			ExpectError   bool
+		}
+
+		DescribeTable("Test GetUnhealthyHost without Metal3Machine",
+			func(tc testCaseGetRemediatedHost) {
+				objects := []client.Object{}
+				if tc.Host != nil {
+					objects = append(objects, tc.Host)
+				}
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				m3Remediation := &infrav1.Metal3Remediation{
+					Status: infrav1.Metal3RemediationStatus{
+						HostRef:     tc.HostRef,
+						ConsumerRef: remediatedConsumerRef,
+					},
+				}
+				remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+					logr.Discard(),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, helper, err := remediationMgr.GetUnhealthyHost(context.TODO())
+				if tc.ExpectError {
+					Expect(err).To(HaveOccurred())
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+				if tc.ExpectPresent {
+					Expect(result).NotTo(BeNil())
+					Expect(result.Name).To(Equal(baremetalhostName))
+					Expect(helper).NotTo(BeNil())
+				} else {
+					Expect(result).To(BeNil())
+					Expect(helper).To(BeNil())
+				}
+			},
+			Entry("No host recorded", testCaseGetRemediatedHost{
+				ExpectError: true,
+			}),
+			Entry("Host is gone", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+			}),
+			Entry("Host was recreated", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+				Host: &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "new-bmh-uid",
+					},
+				},
+			}),
+			Entry("Host is consumed by another machine", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+				Host: &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "bmh-uid",
+					},
+					Spec: bmov1alpha1.BareMetalHostSpec{
+						ConsumerRef: &corev1.ObjectReference{
+							Name:      "othermachine",
+							Namespace: namespaceName,
+						},
+					},
+				},
+			}),
+			Entry("Host is consumed by a new machine with the same name", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+				Host: &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "bmh-uid",
+					},
+					Spec: bmov1alpha1.BareMetalHostSpec{
+						ConsumerRef: &corev1.ObjectReference{
+							Name:      metal3machineName,
+							Namespace: namespaceName,
+							UID:       "new-m3m-uid",
+						},
+					},
+				},
+			}),
+			Entry("Host is still consumed by the remediated machine", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+				Host: &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "bmh-uid",
+					},
+					Spec: bmov1alpha1.BareMetalHostSpec{
+						ConsumerRef: remediatedConsumerRef,
+					},
+				},
+				ExpectPresent: true,
+			}),
+			Entry("Host was released", testCaseGetRemediatedHost{
+				HostRef: remediatedHostRef,
+				Host: &bmov1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "bmh-uid",
+					},
+				},
+				ExpectPresent: true,
+			}),
+		)
+
+		It("Should delete the remediation", func() {
+			m3Remediation := &infrav1.Metal3Remediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myremediation",
+					Namespace: namespaceName,
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(m3Remediation).Build()
+			remediationMgr, err := NewRemediationManager(fakeClient, nil, m3Remediation, nil, nil,
+				logr.Discard(),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remediationMgr.DeleteRemediation(context.TODO())).To(Succeed())
+			err = fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(m3Remediation), &infrav1.Metal3Remediation{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected NotFound error")
+
+			// Deleting again is a no-op.
+			Expect(remediationMgr.DeleteRemediation(context.TODO())).To(Succeed())
+		})
+	})
 })
@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hostProvisionCountBuckets are the upper bounds of the buckets of the
+// provision count histogram.
+var hostProvisionCountBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100}
+
+// HostProvisionCountCollector exports the histogram of the provision counts of
+// the BareMetalHosts, from their HostProvisionCountAnnotation. The hosts are
+// listed when the metrics are scraped.
+type HostProvisionCountCollector struct {
+	client  client.Reader
+	log     logr.Logger
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewHostProvisionCountCollector returns a collector listing the
+// BareMetalHosts with the given reader, usually the cached client of the
+// manager.
+func NewHostProvisionCountCollector(reader client.Reader, log logr.Logger) *HostProvisionCountCollector {
+	return &HostProvisionCountCollector{
+		client:  reader,
+		log:     log,
+		timeout: 10 * time.Second,
+		desc: prometheus.NewDesc(
+			"capm3_baremetalhost_provision_count",
+			"Number of times the BareMetalHosts were provisioned by CAPM3.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *HostProvisionCountCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector. Nothing is exported if the hosts
+// cannot be listed.
+func (c *HostProvisionCountCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	hosts := bmov1alpha1.BareMetalHostList{}
+	if err := c.client.List(ctx, &hosts); err != nil {
+		c.log.Error(err, "Failed to list the BareMetalHosts for the provision count metric")
+		return
+	}
+
+	buckets := make(map[float64]uint64, len(hostProvisionCountBuckets))
+	sum := 0.0
+	for i := range hosts.Items {
+		count := float64(hostProvisionCount(&hosts.Items[i]))
+		sum += count
+		for _, bound := range hostProvisionCountBuckets {
+			if count <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.desc, uint64(len(hosts.Items)), sum, buckets)
+}
+
+// NodeReuseLabelExpirations counts the node reuse labels removed from the
+// available BareMetalHosts after the node reuse label TTL, their owner no
+// longer needing the hosts.
+var NodeReuseLabelExpirations = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capm3_node_reuse_label_expirations_total",
+	Help: "Number of node reuse labels removed from the BareMetalHosts after the node reuse label TTL.",
+})
+
+// OrphanedDataClaimDeletions counts the Metal3DataClaims deleted after their
+// owner Metal3Machine was not found for the orphan grace period.
+var OrphanedDataClaimDeletions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capm3_orphaned_dataclaim_deletions_total",
+	Help: "Number of Metal3DataClaims deleted after their Metal3Machine was not found for the orphan grace period.",
+})
+
+// OrphanedDataClaimCollector exports the number of Metal3DataClaims whose
+// owner Metal3Machine is not found, from their Metal3MachineFoundCondition.
+// The claims are listed when the metrics are scraped.
+type OrphanedDataClaimCollector struct {
+	client  client.Reader
+	log     logr.Logger
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewOrphanedDataClaimCollector returns a collector listing the
+// Metal3DataClaims with the given reader, usually the cached client of the
+// manager.
+func NewOrphanedDataClaimCollector(reader client.Reader, log logr.Logger) *OrphanedDataClaimCollector {
+	return &OrphanedDataClaimCollector{
+		client:  reader,
+		log:     log,
+		timeout: 10 * time.Second,
+		desc: prometheus.NewDesc(
+			"capm3_orphaned_dataclaims",
+			"Number of Metal3DataClaims whose Metal3Machine is not found.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *OrphanedDataClaimCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector. Nothing is exported if the claims
+// cannot be listed.
+func (c *OrphanedDataClaimCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	dataClaims := infrav1.Metal3DataClaimList{}
+	if err := c.client.List(ctx, &dataClaims); err != nil {
+		c.log.Error(err, "Failed to list the Metal3DataClaims for the orphaned claims metric")
+		return
+	}
+
+	orphaned := 0
+	for i := range dataClaims.Items {
+		if conditions.IsFalse(&dataClaims.Items[i], infrav1.Metal3MachineFoundCondition) {
+			orphaned++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(orphaned))
+}
+
+// provisioningDurationBuckets are the upper bounds, in seconds, of the buckets
+// of the provisioning duration histogram.
+var provisioningDurationBuckets = []float64{60, 120, 300, 600, 900, 1200, 1800, 3600}
+
+// ClusterProvisioningMetrics exports the provisioning durations and failures
+// of the BareMetalHosts per workload cluster, labeled with the namespace and
+// the name of the Cluster. The clusters are tracked by UID, their series are
+// deleted when the Cluster is deleted, so that only existing clusters have
+// series.
+type ClusterProvisioningMetrics struct {
+	lock     sync.Mutex
+	clusters map[types.UID]types.NamespacedName
+	duration *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+}
+
+// ProvisioningMetrics holds the per cluster provisioning metrics of the
+// Metal3Machines.
+var ProvisioningMetrics = NewClusterProvisioningMetrics()
+
+// NewClusterProvisioningMetrics returns the collector of the per cluster
+// provisioning metrics, tracking no cluster.
+func NewClusterProvisioningMetrics() *ClusterProvisioningMetrics {
+	return &ClusterProvisioningMetrics{
+		clusters: map[types.UID]types.NamespacedName{},
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "capm3_baremetalhost_provisioning_duration_seconds",
+			Help:    "Duration of the provisioning of the BareMetalHosts of the Metal3Machines, per cluster.",
+			Buckets: provisioningDurationBuckets,
+		}, []string{"namespace", "cluster"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "capm3_baremetalhost_provisioning_failures_total",
+			Help: "Number of failed provisionings of the BareMetalHosts of the Metal3Machines, per cluster.",
+		}, []string{"namespace", "cluster"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ClusterProvisioningMetrics) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.failures.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *ClusterProvisioningMetrics) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.failures.Collect(ch)
+}
+
+// ObserveProvisioningDuration records the provisioning duration of a
+// BareMetalHost of the cluster.
+func (c *ClusterProvisioningMetrics) ObserveProvisioningDuration(cluster *clusterv1.Cluster, duration time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if labels := c.track(cluster); labels != nil {
+		c.duration.With(labels).Observe(duration.Seconds())
+	}
+}
+
+// IncProvisioningFailures counts a failed provisioning of a BareMetalHost of
+// the cluster.
+func (c *ClusterProvisioningMetrics) IncProvisioningFailures(cluster *clusterv1.Cluster) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if labels := c.track(cluster); labels != nil {
+		c.failures.With(labels).Inc()
+	}
+}
+
+// DeleteCluster deletes the series of the deleted cluster.
+func (c *ClusterProvisioningMetrics) DeleteCluster(cluster *clusterv1.Cluster) {
+	if cluster == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.forget(cluster.UID)
+}
+
+// track returns the labels of the series of the cluster, and starts tracking
+// it. A cluster recreated with the name of a previous one replaces its
+// series. It returns nil for a cluster without UID, which is not tracked.
+func (c *ClusterProvisioningMetrics) track(cluster *clusterv1.Cluster) prometheus.Labels {
+	if cluster == nil || cluster.UID == "" {
+		return nil
+	}
+	name := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}
+	if _, ok := c.clusters[cluster.UID]; !ok {
+		for uid, tracked := range c.clusters {
+			if tracked == name {
+				c.forget(uid)
+			}
+		}
+		c.clusters[cluster.UID] = name
+	}
+	return prometheus.Labels{"namespace": name.Namespace, "cluster": name.Name}
+}
+
+// forget deletes the series of the cluster with the given UID and stops
+// tracking it.
+func (c *ClusterProvisioningMetrics) forget(uid types.UID) {
+	name, ok := c.clusters[uid]
+	if !ok {
+		return
+	}
+	labels := prometheus.Labels{"namespace": name.Namespace, "cluster": name.Name}
+	c.duration.Delete(labels)
+	c.failures.Delete(labels)
+	delete(c.clusters, uid)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Host provision count metric", func() {
+	It("Exports the histogram of the provision counts", func() {
+		host := func(name, count string) *bmov1alpha1.BareMetalHost {
+			h := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName},
+			}
+			if count != "" {
+				h.Annotations = map[string]string{infrav1.HostProvisionCountAnnotation: count}
+			}
+			return h
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(
+			host("host-0", ""),
+			host("host-1", "1"),
+			host("host-2", "3"),
+			host("host-3", "30"),
+		).Build()
+
+		collector := NewHostProvisionCountCollector(fakeClient, logr.Discard())
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP capm3_baremetalhost_provision_count Number of times the BareMetalHosts were provisioned by CAPM3.
+# TYPE capm3_baremetalhost_provision_count histogram
+capm3_baremetalhost_provision_count_bucket{le="0"} 1
+capm3_baremetalhost_provision_count_bucket{le="1"} 2
+capm3_baremetalhost_provision_count_bucket{le="2"} 2
+capm3_baremetalhost_provision_count_bucket{le="5"} 3
+capm3_baremetalhost_provision_count_bucket{le="10"} 3
+capm3_baremetalhost_provision_count_bucket{le="20"} 3
+capm3_baremetalhost_provision_count_bucket{le="50"} 4
+capm3_baremetalhost_provision_count_bucket{le="100"} 4
+capm3_baremetalhost_provision_count_bucket{le="+Inf"} 4
+capm3_baremetalhost_provision_count_sum 34
+capm3_baremetalhost_provision_count_count 4
+`))).To(Succeed())
+	})
+})
+
+var _ = Describe("Orphaned Metal3DataClaims metric", func() {
+	It("Exports the number of orphaned claims", func() {
+		dataClaim := func(name string, missing bool) *infrav1.Metal3DataClaim {
+			c := &infrav1.Metal3DataClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName},
+			}
+			status := corev1.ConditionTrue
+			if missing {
+				status = corev1.ConditionFalse
+			}
+			c.Status.Conditions = clusterv1.Conditions{{
+				Type:   infrav1.Metal3MachineFoundCondition,
+				Status: status,
+			}}
+			return c
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(
+			dataClaim("claim-0", false),
+			dataClaim("claim-1", true),
+			dataClaim("claim-2", true),
+		).Build()
+
+		collector := NewOrphanedDataClaimCollector(fakeClient, logr.Discard())
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP capm3_orphaned_dataclaims Number of Metal3DataClaims whose Metal3Machine is not found.
+# TYPE capm3_orphaned_dataclaims gauge
+capm3_orphaned_dataclaims 2
+`))).To(Succeed())
+	})
+})
+
+var _ = Describe("Per cluster provisioning metrics", func() {
+	cluster := func(name, uid string) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaceName, UID: types.UID(uid)},
+		}
+	}
+
+	It("Exports the series of the existing clusters only", func() {
+		provisioningMetrics := NewClusterProvisioningMetrics()
+		provisioningMetrics.ObserveProvisioningDuration(cluster("cluster-a", "uid-a"), 90*time.Second)
+		provisioningMetrics.ObserveProvisioningDuration(cluster("cluster-b", "uid-b"), 10*time.Minute)
+		provisioningMetrics.IncProvisioningFailures(cluster("cluster-a", "uid-a"))
+		provisioningMetrics.IncProvisioningFailures(cluster("cluster-b", "uid-b"))
+		provisioningMetrics.IncProvisioningFailures(cluster("cluster-b", "uid-b"))
+		// Clusters without UID are not tracked.
+		provisioningMetrics.IncProvisioningFailures(cluster("cluster-c", ""))
+		provisioningMetrics.IncProvisioningFailures(nil)
+
+		Expect(testutil.CollectAndCount(provisioningMetrics, "capm3_baremetalhost_provisioning_duration_seconds")).To(Equal(2))
+		Expect(testutil.CollectAndCompare(provisioningMetrics, strings.NewReader(`
+# HELP capm3_baremetalhost_provisioning_failures_total Number of failed provisionings of the BareMetalHosts of the Metal3Machines, per cluster.
+# TYPE capm3_baremetalhost_provisioning_failures_total counter
+capm3_baremetalhost_provisioning_failures_total{cluster="cluster-a",namespace="`+namespaceName+`"} 1
+capm3_baremetalhost_provisioning_failures_total{cluster="cluster-b",namespace="`+namespaceName+`"} 2
+`), "capm3_baremetalhost_provisioning_failures_total")).To(Succeed())
+
+		By("Deleting the series of a deleted cluster")
+		provisioningMetrics.DeleteCluster(cluster("cluster-a", "uid-a"))
+		Expect(testutil.CollectAndCount(provisioningMetrics, "capm3_baremetalhost_provisioning_duration_seconds")).To(Equal(1))
+		Expect(testutil.CollectAndCompare(provisioningMetrics, strings.NewReader(`
+# HELP capm3_baremetalhost_provisioning_failures_total Number of failed provisionings of the BareMetalHosts of the Metal3Machines, per cluster.
+# TYPE capm3_baremetalhost_provisioning_failures_total counter
+capm3_baremetalhost_provisioning_failures_total{cluster="cluster-b",namespace="`+namespaceName+`"} 2
+`), "capm3_baremetalhost_provisioning_failures_total")).To(Succeed())
+		Expect(provisioningMetrics.clusters).To(HaveLen(1))
+
+		By("Replacing the series of a cluster recreated with the same name")
+		provisioningMetrics.IncProvisioningFailures(cluster("cluster-b", "uid-b2"))
+		Expect(testutil.CollectAndCompare(provisioningMetrics, strings.NewReader(`
+# HELP capm3_baremetalhost_provisioning_failures_total Number of failed provisionings of the BareMetalHosts of the Metal3Machines, per cluster.
+# TYPE capm3_baremetalhost_provisioning_failures_total counter
+capm3_baremetalhost_provisioning_failures_total{cluster="cluster-b",namespace="`+namespaceName+`"} 1
+`), "capm3_baremetalhost_provisioning_failures_total")).To(Succeed())
+		Expect(testutil.CollectAndCount(provisioningMetrics, "capm3_baremetalhost_provisioning_duration_seconds")).To(Equal(0))
+
+		By("Keeping the series of the recreated cluster when the previous one is deleted again")
+		provisioningMetrics.DeleteCluster(cluster("cluster-b", "uid-b"))
+		Expect(testutil.CollectAndCount(provisioningMetrics, "capm3_baremetalhost_provisioning_failures_total")).To(Equal(1))
+
+		By("Deleting all the series once the clusters are deleted")
+		provisioningMetrics.DeleteCluster(cluster("cluster-b", "uid-b2"))
+		Expect(testutil.CollectAndCount(provisioningMetrics)).To(Equal(0))
+		Expect(provisioningMetrics.clusters).To(BeEmpty())
+	})
+})
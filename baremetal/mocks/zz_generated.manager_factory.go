@@ -30,6 +30,7 @@ import (
 	v1beta1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	baremetal "github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	v1beta10 "sigs.k8s.io/cluster-api/api/v1beta1"
+	v1beta11 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 )
 
 // MockManagerFactoryInterface is a mock of ManagerFactoryInterface interface.
@@ -115,6 +116,21 @@ func (mr *MockManagerFactoryInterfaceMockRecorder) NewMachineManager(arg0, arg1,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewMachineManager", reflect.TypeOf((*MockManagerFactoryInterface)(nil).NewMachineManager), arg0, arg1, arg2, arg3, arg4)
 }
 
+// NewMachinePoolManager mocks base method.
+func (m *MockManagerFactoryInterface) NewMachinePoolManager(arg0 *v1beta10.Cluster, arg1 *v1beta11.MachinePool, arg2 *v1beta1.Metal3MachinePool, arg3 logr.Logger) (baremetal.MachinePoolManagerInterface, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewMachinePoolManager", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(baremetal.MachinePoolManagerInterface)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewMachinePoolManager indicates an expected call of NewMachinePoolManager.
+func (mr *MockManagerFactoryInterfaceMockRecorder) NewMachinePoolManager(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewMachinePoolManager", reflect.TypeOf((*MockManagerFactoryInterface)(nil).NewMachinePoolManager), arg0, arg1, arg2, arg3)
+}
+
 // NewMachineTemplateManager mocks base method.
 func (m *MockManagerFactoryInterface) NewMachineTemplateManager(capm3Template *v1beta1.Metal3MachineTemplate, capm3MachineList *v1beta1.Metal3MachineList, metadataLog logr.Logger) (baremetal.TemplateManagerInterface, error) {
 	m.ctrl.T.Helper()
@@ -25,6 +25,7 @@ package baremetal_mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -52,6 +53,20 @@ func (m *MockClusterManagerInterface) EXPECT() *MockClusterManagerInterfaceMockR
 	return m.recorder
 }
 
+// AllocateControlPlaneEndpoint mocks base method.
+func (m *MockClusterManagerInterface) AllocateControlPlaneEndpoint(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllocateControlPlaneEndpoint", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AllocateControlPlaneEndpoint indicates an expected call of AllocateControlPlaneEndpoint.
+func (mr *MockClusterManagerInterfaceMockRecorder) AllocateControlPlaneEndpoint(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocateControlPlaneEndpoint", reflect.TypeOf((*MockClusterManagerInterface)(nil).AllocateControlPlaneEndpoint), arg0)
+}
+
 // CountDescendants mocks base method.
 func (m *MockClusterManagerInterface) CountDescendants(arg0 context.Context) (int, error) {
 	m.ctrl.T.Helper()
@@ -82,17 +97,46 @@ func (mr *MockClusterManagerInterfaceMockRecorder) Create(arg0 interface{}) *gom
 }
 
 // Delete mocks base method.
-func (m *MockClusterManagerInterface) Delete() error {
+func (m *MockClusterManagerInterface) Delete(arg0 context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete")
+	ret := m.ctrl.Call(m, "Delete", arg0)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockClusterManagerInterfaceMockRecorder) Delete() *gomock.Call {
+func (mr *MockClusterManagerInterfaceMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClusterManagerInterface)(nil).Delete), arg0)
+}
+
+// ExpireNodeReuseLabels mocks base method.
+func (m *MockClusterManagerInterface) ExpireNodeReuseLabels(arg0 context.Context) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireNodeReuseLabels", arg0)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireNodeReuseLabels indicates an expected call of ExpireNodeReuseLabels.
+func (mr *MockClusterManagerInterfaceMockRecorder) ExpireNodeReuseLabels(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireNodeReuseLabels", reflect.TypeOf((*MockClusterManagerInterface)(nil).ExpireNodeReuseLabels), arg0)
+}
+
+// RemovePauseAnnotations mocks base method.
+func (m *MockClusterManagerInterface) RemovePauseAnnotations(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePauseAnnotations", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePauseAnnotations indicates an expected call of RemovePauseAnnotations.
+func (mr *MockClusterManagerInterfaceMockRecorder) RemovePauseAnnotations(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClusterManagerInterface)(nil).Delete))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePauseAnnotations", reflect.TypeOf((*MockClusterManagerInterface)(nil).RemovePauseAnnotations), arg0)
 }
 
 // SetFinalizer mocks base method.
@@ -107,6 +151,20 @@ func (mr *MockClusterManagerInterfaceMockRecorder) SetFinalizer() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFinalizer", reflect.TypeOf((*MockClusterManagerInterface)(nil).SetFinalizer))
 }
 
+// SetPauseAnnotations mocks base method.
+func (m *MockClusterManagerInterface) SetPauseAnnotations(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPauseAnnotations", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPauseAnnotations indicates an expected call of SetPauseAnnotations.
+func (mr *MockClusterManagerInterfaceMockRecorder) SetPauseAnnotations(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPauseAnnotations", reflect.TypeOf((*MockClusterManagerInterface)(nil).SetPauseAnnotations), arg0)
+}
+
 // UnsetFinalizer mocks base method.
 func (m *MockClusterManagerInterface) UnsetFinalizer() {
 	m.ctrl.T.Helper()
@@ -132,3 +190,17 @@ func (mr *MockClusterManagerInterfaceMockRecorder) UpdateClusterStatus() *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateClusterStatus", reflect.TypeOf((*MockClusterManagerInterface)(nil).UpdateClusterStatus))
 }
+
+// UpdateMachinesStatus mocks base method.
+func (m *MockClusterManagerInterface) UpdateMachinesStatus(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMachinesStatus", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMachinesStatus indicates an expected call of UpdateMachinesStatus.
+func (mr *MockClusterManagerInterfaceMockRecorder) UpdateMachinesStatus(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMachinesStatus", reflect.TypeOf((*MockClusterManagerInterface)(nil).UpdateMachinesStatus), arg0)
+}
@@ -25,6 +25,7 @@ package baremetal_mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	v1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -53,6 +54,21 @@ func (m *MockDataTemplateManagerInterface) EXPECT() *MockDataTemplateManagerInte
 	return m.recorder
 }
 
+// DeleteOrphanedClaims mocks base method.
+func (m *MockDataTemplateManagerInterface) DeleteOrphanedClaims(arg0 context.Context) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrphanedClaims", arg0)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOrphanedClaims indicates an expected call of DeleteOrphanedClaims.
+func (mr *MockDataTemplateManagerInterfaceMockRecorder) DeleteOrphanedClaims(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrphanedClaims", reflect.TypeOf((*MockDataTemplateManagerInterface)(nil).DeleteOrphanedClaims), arg0)
+}
+
 // SetClusterOwnerRef mocks base method.
 func (m *MockDataTemplateManagerInterface) SetClusterOwnerRef(arg0 *v1beta1.Cluster) error {
 	m.ctrl.T.Helper()
@@ -55,6 +55,21 @@ func (m *MockMachineManagerInterface) EXPECT() *MockMachineManagerInterfaceMockR
 	return m.recorder
 }
 
+// AdoptedNodeProviderID mocks base method.
+func (m *MockMachineManagerInterface) AdoptedNodeProviderID(arg0 context.Context, arg1 baremetal.ClientGetter) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdoptedNodeProviderID", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdoptedNodeProviderID indicates an expected call of AdoptedNodeProviderID.
+func (mr *MockMachineManagerInterfaceMockRecorder) AdoptedNodeProviderID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdoptedNodeProviderID", reflect.TypeOf((*MockMachineManagerInterface)(nil).AdoptedNodeProviderID), arg0, arg1)
+}
+
 // Associate mocks base method.
 func (m *MockMachineManagerInterface) Associate(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -83,6 +98,32 @@ func (mr *MockMachineManagerInterfaceMockRecorder) AssociateM3Metadata(arg0 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssociateM3Metadata", reflect.TypeOf((*MockMachineManagerInterface)(nil).AssociateM3Metadata), arg0)
 }
 
+// BootstrapSkipAllowed mocks base method.
+func (m *MockMachineManagerInterface) BootstrapSkipAllowed() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BootstrapSkipAllowed")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// BootstrapSkipAllowed indicates an expected call of BootstrapSkipAllowed.
+func (mr *MockMachineManagerInterfaceMockRecorder) BootstrapSkipAllowed() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BootstrapSkipAllowed", reflect.TypeOf((*MockMachineManagerInterface)(nil).BootstrapSkipAllowed))
+}
+
+// CheckProviderIDFormat mocks base method.
+func (m *MockMachineManagerInterface) CheckProviderIDFormat() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CheckProviderIDFormat")
+}
+
+// CheckProviderIDFormat indicates an expected call of CheckProviderIDFormat.
+func (mr *MockMachineManagerInterfaceMockRecorder) CheckProviderIDFormat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckProviderIDFormat", reflect.TypeOf((*MockMachineManagerInterface)(nil).CheckProviderIDFormat))
+}
+
 // Delete mocks base method.
 func (m *MockMachineManagerInterface) Delete(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -111,6 +152,20 @@ func (mr *MockMachineManagerInterfaceMockRecorder) DissociateM3Metadata(arg0 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DissociateM3Metadata", reflect.TypeOf((*MockMachineManagerInterface)(nil).DissociateM3Metadata), arg0)
 }
 
+// DrainNode mocks base method.
+func (m *MockMachineManagerInterface) DrainNode(arg0 context.Context, arg1 baremetal.ClientGetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DrainNode", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DrainNode indicates an expected call of DrainNode.
+func (mr *MockMachineManagerInterfaceMockRecorder) DrainNode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrainNode", reflect.TypeOf((*MockMachineManagerInterface)(nil).DrainNode), arg0, arg1)
+}
+
 // GetBaremetalHostID mocks base method.
 func (m *MockMachineManagerInterface) GetBaremetalHostID(arg0 context.Context) (*string, error) {
 	m.ctrl.T.Helper()
@@ -155,6 +210,20 @@ func (mr *MockMachineManagerInterfaceMockRecorder) HasAnnotation() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasAnnotation", reflect.TypeOf((*MockMachineManagerInterface)(nil).HasAnnotation))
 }
 
+// IsAdoptingHost mocks base method.
+func (m *MockMachineManagerInterface) IsAdoptingHost() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAdoptingHost")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAdoptingHost indicates an expected call of IsAdoptingHost.
+func (mr *MockMachineManagerInterfaceMockRecorder) IsAdoptingHost() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAdoptingHost", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsAdoptingHost))
+}
+
 // IsBootstrapReady mocks base method.
 func (m *MockMachineManagerInterface) IsBootstrapReady() bool {
 	m.ctrl.T.Helper()
@@ -169,6 +238,63 @@ func (mr *MockMachineManagerInterfaceMockRecorder) IsBootstrapReady() *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBootstrapReady", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsBootstrapReady))
 }
 
+// IsBootstrapless mocks base method.
+func (m *MockMachineManagerInterface) IsBootstrapless() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBootstrapless")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsBootstrapless indicates an expected call of IsBootstrapless.
+func (mr *MockMachineManagerInterfaceMockRecorder) IsBootstrapless() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBootstrapless", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsBootstrapless))
+}
+
+// IsHostDetached mocks base method.
+func (m *MockMachineManagerInterface) IsHostDetached(arg0 context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsHostDetached", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsHostDetached indicates an expected call of IsHostDetached.
+func (mr *MockMachineManagerInterfaceMockRecorder) IsHostDetached(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsHostDetached", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsHostDetached), arg0)
+}
+
+// IsLiveISO mocks base method.
+func (m *MockMachineManagerInterface) IsLiveISO() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsLiveISO")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsLiveISO indicates an expected call of IsLiveISO.
+func (mr *MockMachineManagerInterfaceMockRecorder) IsLiveISO() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLiveISO", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsLiveISO))
+}
+
+// IsPoweringOn mocks base method.
+func (m *MockMachineManagerInterface) IsPoweringOn() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsPoweringOn")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsPoweringOn indicates an expected call of IsPoweringOn.
+func (mr *MockMachineManagerInterfaceMockRecorder) IsPoweringOn() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsPoweringOn", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsPoweringOn))
+}
+
 // IsProvisioned mocks base method.
 func (m *MockMachineManagerInterface) IsProvisioned() bool {
 	m.ctrl.T.Helper()
@@ -183,6 +309,35 @@ func (mr *MockMachineManagerInterfaceMockRecorder) IsProvisioned() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsProvisioned", reflect.TypeOf((*MockMachineManagerInterface)(nil).IsProvisioned))
 }
 
+// LiveISOProviderID mocks base method.
+func (m *MockMachineManagerInterface) LiveISOProviderID(arg0 context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LiveISOProviderID", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LiveISOProviderID indicates an expected call of LiveISOProviderID.
+func (mr *MockMachineManagerInterfaceMockRecorder) LiveISOProviderID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LiveISOProviderID", reflect.TypeOf((*MockMachineManagerInterface)(nil).LiveISOProviderID), arg0)
+}
+
+// RecordTemplateHash mocks base method.
+func (m *MockMachineManagerInterface) RecordTemplateHash(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordTemplateHash", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordTemplateHash indicates an expected call of RecordTemplateHash.
+func (mr *MockMachineManagerInterfaceMockRecorder) RecordTemplateHash(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordTemplateHash", reflect.TypeOf((*MockMachineManagerInterface)(nil).RecordTemplateHash), arg0)
+}
+
 // RemovePauseAnnotation mocks base method.
 func (m *MockMachineManagerInterface) RemovePauseAnnotation(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -290,6 +445,34 @@ func (mr *MockMachineManagerInterfaceMockRecorder) SetProviderID(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProviderID", reflect.TypeOf((*MockMachineManagerInterface)(nil).SetProviderID), arg0)
 }
 
+// SyncNodeAddresses mocks base method.
+func (m *MockMachineManagerInterface) SyncNodeAddresses(arg0 context.Context, arg1 baremetal.ClientGetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncNodeAddresses", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SyncNodeAddresses indicates an expected call of SyncNodeAddresses.
+func (mr *MockMachineManagerInterfaceMockRecorder) SyncNodeAddresses(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncNodeAddresses", reflect.TypeOf((*MockMachineManagerInterface)(nil).SyncNodeAddresses), arg0, arg1)
+}
+
+// SyncNodeMetadata mocks base method.
+func (m *MockMachineManagerInterface) SyncNodeMetadata(arg0 context.Context, arg1 baremetal.ClientGetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncNodeMetadata", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SyncNodeMetadata indicates an expected call of SyncNodeMetadata.
+func (mr *MockMachineManagerInterfaceMockRecorder) SyncNodeMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncNodeMetadata", reflect.TypeOf((*MockMachineManagerInterface)(nil).SyncNodeMetadata), arg0, arg1)
+}
+
 // UnsetFinalizer mocks base method.
 func (m *MockMachineManagerInterface) UnsetFinalizer() {
 	m.ctrl.T.Helper()
@@ -0,0 +1,134 @@
+// /*
+// Copyright The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// */
+//
+//
+
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./baremetal/metal3machinepool_manager.go
+
+// Package baremetal_mocks is a generated GoMock package.
+package baremetal_mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	baremetal "github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+)
+
+// MockMachinePoolManagerInterface is a mock of MachinePoolManagerInterface interface.
+type MockMachinePoolManagerInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockMachinePoolManagerInterfaceMockRecorder
+}
+
+// MockMachinePoolManagerInterfaceMockRecorder is the mock recorder for MockMachinePoolManagerInterface.
+type MockMachinePoolManagerInterfaceMockRecorder struct {
+	mock *MockMachinePoolManagerInterface
+}
+
+// NewMockMachinePoolManagerInterface creates a new mock instance.
+func NewMockMachinePoolManagerInterface(ctrl *gomock.Controller) *MockMachinePoolManagerInterface {
+	mock := &MockMachinePoolManagerInterface{ctrl: ctrl}
+	mock.recorder = &MockMachinePoolManagerInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMachinePoolManagerInterface) EXPECT() *MockMachinePoolManagerInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockMachinePoolManagerInterface) Delete(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).Delete), arg0)
+}
+
+// IsBootstrapReady mocks base method.
+func (m *MockMachinePoolManagerInterface) IsBootstrapReady() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBootstrapReady")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsBootstrapReady indicates an expected call of IsBootstrapReady.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) IsBootstrapReady() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBootstrapReady", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).IsBootstrapReady))
+}
+
+// ReconcileHosts mocks base method.
+func (m *MockMachinePoolManagerInterface) ReconcileHosts(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileHosts", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcileHosts indicates an expected call of ReconcileHosts.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) ReconcileHosts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileHosts", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).ReconcileHosts), arg0)
+}
+
+// SetFinalizer mocks base method.
+func (m *MockMachinePoolManagerInterface) SetFinalizer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetFinalizer")
+}
+
+// SetFinalizer indicates an expected call of SetFinalizer.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) SetFinalizer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFinalizer", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).SetFinalizer))
+}
+
+// UnsetFinalizer mocks base method.
+func (m *MockMachinePoolManagerInterface) UnsetFinalizer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UnsetFinalizer")
+}
+
+// UnsetFinalizer indicates an expected call of UnsetFinalizer.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) UnsetFinalizer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsetFinalizer", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).UnsetFinalizer))
+}
+
+// UpdateProviderIDs mocks base method.
+func (m *MockMachinePoolManagerInterface) UpdateProviderIDs(arg0 context.Context, arg1 baremetal.ClientGetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProviderIDs", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProviderIDs indicates an expected call of UpdateProviderIDs.
+func (mr *MockMachinePoolManagerInterfaceMockRecorder) UpdateProviderIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProviderIDs", reflect.TypeOf((*MockMachinePoolManagerInterface)(nil).UpdateProviderIDs), arg0, arg1)
+}
@@ -65,3 +65,45 @@ func (mr *MockTemplateManagerInterfaceMockRecorder) UpdateAutomatedCleaningMode(
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAutomatedCleaningMode", reflect.TypeOf((*MockTemplateManagerInterface)(nil).UpdateAutomatedCleaningMode), arg0)
 }
+
+// UpdateNodeReuseReservations mocks base method.
+func (m *MockTemplateManagerInterface) UpdateNodeReuseReservations(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateNodeReuseReservations", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateNodeReuseReservations indicates an expected call of UpdateNodeReuseReservations.
+func (mr *MockTemplateManagerInterfaceMockRecorder) UpdateNodeReuseReservations(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNodeReuseReservations", reflect.TypeOf((*MockTemplateManagerInterface)(nil).UpdateNodeReuseReservations), arg0)
+}
+
+// UpdateOutOfDateReplicas mocks base method.
+func (m *MockTemplateManagerInterface) UpdateOutOfDateReplicas(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOutOfDateReplicas", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOutOfDateReplicas indicates an expected call of UpdateOutOfDateReplicas.
+func (mr *MockTemplateManagerInterfaceMockRecorder) UpdateOutOfDateReplicas(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOutOfDateReplicas", reflect.TypeOf((*MockTemplateManagerInterface)(nil).UpdateOutOfDateReplicas), arg0)
+}
+
+// UpdateTemplateDrift mocks base method.
+func (m *MockTemplateManagerInterface) UpdateTemplateDrift(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTemplateDrift", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTemplateDrift indicates an expected call of UpdateTemplateDrift.
+func (mr *MockTemplateManagerInterfaceMockRecorder) UpdateTemplateDrift(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTemplateDrift", reflect.TypeOf((*MockTemplateManagerInterface)(nil).UpdateTemplateDrift), arg0)
+}
@@ -60,6 +60,20 @@ func (m *MockRemediationManagerInterface) EXPECT() *MockRemediationManagerInterf
 	return m.recorder
 }
 
+// DeleteCapiMachine mocks base method.
+func (m *MockRemediationManagerInterface) DeleteCapiMachine(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCapiMachine", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCapiMachine indicates an expected call of DeleteCapiMachine.
+func (mr *MockRemediationManagerInterfaceMockRecorder) DeleteCapiMachine(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCapiMachine", reflect.TypeOf((*MockRemediationManagerInterface)(nil).DeleteCapiMachine), ctx)
+}
+
 // DeleteNode mocks base method.
 func (m *MockRemediationManagerInterface) DeleteNode(ctx context.Context, clusterClient v11.CoreV1Interface, node *v1.Node) error {
 	m.ctrl.T.Helper()
@@ -74,6 +88,32 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) DeleteNode(ctx, clusterCl
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNode", reflect.TypeOf((*MockRemediationManagerInterface)(nil).DeleteNode), ctx, clusterClient, node)
 }
 
+// DeleteRemediation mocks base method.
+func (m *MockRemediationManagerInterface) DeleteRemediation(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRemediation", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRemediation indicates an expected call of DeleteRemediation.
+func (mr *MockRemediationManagerInterfaceMockRecorder) DeleteRemediation(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRemediation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).DeleteRemediation), ctx)
+}
+
+// EndRemediationAttempt mocks base method.
+func (m *MockRemediationManagerInterface) EndRemediationAttempt(outcome string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "EndRemediationAttempt", outcome)
+}
+
+// EndRemediationAttempt indicates an expected call of EndRemediationAttempt.
+func (mr *MockRemediationManagerInterfaceMockRecorder) EndRemediationAttempt(outcome interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndRemediationAttempt", reflect.TypeOf((*MockRemediationManagerInterface)(nil).EndRemediationAttempt), outcome)
+}
+
 // GetCapiMachine mocks base method.
 func (m *MockRemediationManagerInterface) GetCapiMachine(ctx context.Context) (*v1beta10.Machine, error) {
 	m.ctrl.T.Helper()
@@ -148,6 +188,20 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) GetNodeBackupAnnotations(
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeBackupAnnotations", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetNodeBackupAnnotations))
 }
 
+// GetNodeRemediationMechanism mocks base method.
+func (m *MockRemediationManagerInterface) GetNodeRemediationMechanism() v1beta1.NodeRemediationMechanism {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNodeRemediationMechanism")
+	ret0, _ := ret[0].(v1beta1.NodeRemediationMechanism)
+	return ret0
+}
+
+// GetNodeRemediationMechanism indicates an expected call of GetNodeRemediationMechanism.
+func (mr *MockRemediationManagerInterfaceMockRecorder) GetNodeRemediationMechanism() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeRemediationMechanism", reflect.TypeOf((*MockRemediationManagerInterface)(nil).GetNodeRemediationMechanism))
+}
+
 // GetRemediationPhase mocks base method.
 func (m *MockRemediationManagerInterface) GetRemediationPhase() string {
 	m.ctrl.T.Helper()
@@ -290,6 +344,44 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) OnlineStatus(host interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnlineStatus", reflect.TypeOf((*MockRemediationManagerInterface)(nil).OnlineStatus), host)
 }
 
+// RecordNodeAction mocks base method.
+func (m *MockRemediationManagerInterface) RecordNodeAction(action v1beta1.NodeRemediationMechanism) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RecordNodeAction", action)
+}
+
+// RecordNodeAction indicates an expected call of RecordNodeAction.
+func (mr *MockRemediationManagerInterfaceMockRecorder) RecordNodeAction(action interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordNodeAction", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RecordNodeAction), action)
+}
+
+// RecordPowerState mocks base method.
+func (m *MockRemediationManagerInterface) RecordPowerState(poweredOn bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RecordPowerState", poweredOn)
+}
+
+// RecordPowerState indicates an expected call of RecordPowerState.
+func (mr *MockRemediationManagerInterfaceMockRecorder) RecordPowerState(poweredOn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPowerState", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RecordPowerState), poweredOn)
+}
+
+// RemoveCapiMachineDeleteHook mocks base method.
+func (m *MockRemediationManagerInterface) RemoveCapiMachineDeleteHook(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveCapiMachineDeleteHook", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveCapiMachineDeleteHook indicates an expected call of RemoveCapiMachineDeleteHook.
+func (mr *MockRemediationManagerInterfaceMockRecorder) RemoveCapiMachineDeleteHook(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveCapiMachineDeleteHook", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RemoveCapiMachineDeleteHook), ctx)
+}
+
 // RemoveNodeBackupAnnotations mocks base method.
 func (m *MockRemediationManagerInterface) RemoveNodeBackupAnnotations() {
 	m.ctrl.T.Helper()
@@ -302,6 +394,20 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) RemoveNodeBackupAnnotatio
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveNodeBackupAnnotations", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RemoveNodeBackupAnnotations))
 }
 
+// RemoveOutOfServiceTaint mocks base method.
+func (m *MockRemediationManagerInterface) RemoveOutOfServiceTaint(ctx context.Context, clusterClient v11.CoreV1Interface, node *v1.Node) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveOutOfServiceTaint", ctx, clusterClient, node)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveOutOfServiceTaint indicates an expected call of RemoveOutOfServiceTaint.
+func (mr *MockRemediationManagerInterfaceMockRecorder) RemoveOutOfServiceTaint(ctx, clusterClient, node interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveOutOfServiceTaint", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RemoveOutOfServiceTaint), ctx, clusterClient, node)
+}
+
 // RemovePowerOffAnnotation mocks base method.
 func (m *MockRemediationManagerInterface) RemovePowerOffAnnotation(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -330,6 +436,20 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) RetryLimitIsSet() *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryLimitIsSet", reflect.TypeOf((*MockRemediationManagerInterface)(nil).RetryLimitIsSet))
 }
 
+// SetCapiMachineDeleteHook mocks base method.
+func (m *MockRemediationManagerInterface) SetCapiMachineDeleteHook(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCapiMachineDeleteHook", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCapiMachineDeleteHook indicates an expected call of SetCapiMachineDeleteHook.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetCapiMachineDeleteHook(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCapiMachineDeleteHook", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetCapiMachineDeleteHook), ctx)
+}
+
 // SetFinalizer mocks base method.
 func (m *MockRemediationManagerInterface) SetFinalizer() {
 	m.ctrl.T.Helper()
@@ -368,6 +488,32 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) SetNodeBackupAnnotations(
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNodeBackupAnnotations", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetNodeBackupAnnotations), annotations, labels)
 }
 
+// SetNodeRemediationMechanism mocks base method.
+func (m *MockRemediationManagerInterface) SetNodeRemediationMechanism(mechanism v1beta1.NodeRemediationMechanism) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetNodeRemediationMechanism", mechanism)
+}
+
+// SetNodeRemediationMechanism indicates an expected call of SetNodeRemediationMechanism.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetNodeRemediationMechanism(mechanism interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNodeRemediationMechanism", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetNodeRemediationMechanism), mechanism)
+}
+
+// SetOutOfServiceTaint mocks base method.
+func (m *MockRemediationManagerInterface) SetOutOfServiceTaint(ctx context.Context, clusterClient v11.CoreV1Interface, node *v1.Node) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOutOfServiceTaint", ctx, clusterClient, node)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOutOfServiceTaint indicates an expected call of SetOutOfServiceTaint.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetOutOfServiceTaint(ctx, clusterClient, node interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOutOfServiceTaint", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetOutOfServiceTaint), ctx, clusterClient, node)
+}
+
 // SetOwnerRemediatedConditionNew mocks base method.
 func (m *MockRemediationManagerInterface) SetOwnerRemediatedConditionNew(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -396,6 +542,18 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) SetPowerOffAnnotation(ctx
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPowerOffAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetPowerOffAnnotation), ctx)
 }
 
+// SetRemediatedHost mocks base method.
+func (m *MockRemediationManagerInterface) SetRemediatedHost(host *v1alpha1.BareMetalHost) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRemediatedHost", host)
+}
+
+// SetRemediatedHost indicates an expected call of SetRemediatedHost.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SetRemediatedHost(host interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRemediatedHost", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetRemediatedHost), host)
+}
+
 // SetRemediationPhase mocks base method.
 func (m *MockRemediationManagerInterface) SetRemediationPhase(phase string) {
 	m.ctrl.T.Helper()
@@ -422,6 +580,33 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) SetUnhealthyAnnotation(ct
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUnhealthyAnnotation", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SetUnhealthyAnnotation), ctx)
 }
 
+// StartRemediationAttempt mocks base method.
+func (m *MockRemediationManagerInterface) StartRemediationAttempt(startTime *v10.Time) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StartRemediationAttempt", startTime)
+}
+
+// StartRemediationAttempt indicates an expected call of StartRemediationAttempt.
+func (mr *MockRemediationManagerInterfaceMockRecorder) StartRemediationAttempt(startTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartRemediationAttempt", reflect.TypeOf((*MockRemediationManagerInterface)(nil).StartRemediationAttempt), startTime)
+}
+
+// SupportsOutOfServiceTaint mocks base method.
+func (m *MockRemediationManagerInterface) SupportsOutOfServiceTaint(ctx context.Context, clusterClient v11.CoreV1Interface) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SupportsOutOfServiceTaint", ctx, clusterClient)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SupportsOutOfServiceTaint indicates an expected call of SupportsOutOfServiceTaint.
+func (mr *MockRemediationManagerInterfaceMockRecorder) SupportsOutOfServiceTaint(ctx, clusterClient interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportsOutOfServiceTaint", reflect.TypeOf((*MockRemediationManagerInterface)(nil).SupportsOutOfServiceTaint), ctx, clusterClient)
+}
+
 // TimeToRemediate mocks base method.
 func (m *MockRemediationManagerInterface) TimeToRemediate(timeout time.Duration) (bool, time.Duration) {
 	m.ctrl.T.Helper()
@@ -462,3 +647,18 @@ func (mr *MockRemediationManagerInterfaceMockRecorder) UpdateNode(ctx, clusterCl
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNode", reflect.TypeOf((*MockRemediationManagerInterface)(nil).UpdateNode), ctx, clusterClient, node)
 }
+
+// WaitForNodeDrain mocks base method.
+func (m *MockRemediationManagerInterface) WaitForNodeDrain(ctx context.Context, clusterClient v11.CoreV1Interface, node *v1.Node) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitForNodeDrain", ctx, clusterClient, node)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WaitForNodeDrain indicates an expected call of WaitForNodeDrain.
+func (mr *MockRemediationManagerInterfaceMockRecorder) WaitForNodeDrain(ctx, clusterClient, node interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForNodeDrain", reflect.TypeOf((*MockRemediationManagerInterface)(nil).WaitForNodeDrain), ctx, clusterClient, node)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ProvisioningEstimatesConfigMapName is the name of the ConfigMap, in the
+	// namespace of the controller, where the provisioning estimates are kept.
+	ProvisioningEstimatesConfigMapName = "capm3-provisioning-estimates"
+	// provisioningEstimateWeight is the weight of the latest provisioning
+	// duration in the moving average.
+	provisioningEstimateWeight = 0.3
+	// provisioningEstimateRefreshInterval is the minimum interval between two
+	// updates of the estimatedReadyTime of a Metal3Machine.
+	provisioningEstimateRefreshInterval = 5 * time.Minute
+)
+
+// ProvisioningEstimator keeps, per cluster, an exponentially weighted moving
+// average of the time taken by the Metal3Machines to become ready, and uses it
+// to estimate when the Metal3Machines being provisioned will be ready. The
+// averages are persisted in a ConfigMap to survive restarts.
+type ProvisioningEstimator struct {
+	client    client.Client
+	reader    client.Reader
+	configMap types.NamespacedName
+	now       func() time.Time
+
+	// mu guards the fields below, it is not held during the API calls.
+	mu          sync.Mutex
+	loaded      bool
+	estimates   map[string]time.Duration
+	lastRefresh map[types.NamespacedName]time.Time
+}
+
+// NewProvisioningEstimator returns a new estimator persisting the estimates in
+// the given ConfigMap, or keeping them in memory only if its namespace is
+// empty. The reader is used to read the ConfigMap without caching the
+// ConfigMaps.
+func NewProvisioningEstimator(c client.Client, reader client.Reader, configMap types.NamespacedName) *ProvisioningEstimator {
+	return &ProvisioningEstimator{
+		client:      c,
+		reader:      reader,
+		configMap:   configMap,
+		now:         time.Now,
+		estimates:   map[string]time.Duration{},
+		lastRefresh: map[types.NamespacedName]time.Time{},
+	}
+}
+
+// UpdateEstimatedReadyTime records the provisioning duration of the
+// Metal3Machine when it became ready, and sets the estimatedReadyTime of the
+// Metal3Machine while it is provisioning. The estimatedReadyTime is refreshed
+// at most every provisioningEstimateRefreshInterval, and left empty until a
+// provisioning duration was recorded for the cluster. The lock is only held
+// while the estimates are updated in memory, not during the API calls, so that
+// the concurrent reconciles are not serialized.
+func (e *ProvisioningEstimator) UpdateEstimatedReadyTime(ctx context.Context,
+	cluster types.NamespacedName, m3m *infrav1.Metal3Machine, wasReady bool,
+) error {
+	if err := e.load(ctx); err != nil {
+		return err
+	}
+
+	key := client.ObjectKeyFromObject(m3m)
+	if !m3m.DeletionTimestamp.IsZero() || m3m.Status.Ready {
+		m3m.Status.EstimatedReadyTime = nil
+		e.mu.Lock()
+		delete(e.lastRefresh, key)
+		e.mu.Unlock()
+		if m3m.Status.Ready && !wasReady && m3m.DeletionTimestamp.IsZero() {
+			return e.observe(ctx, cluster, e.now().Sub(m3m.CreationTimestamp.Time))
+		}
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if last, ok := e.lastRefresh[key]; ok && m3m.Status.EstimatedReadyTime != nil &&
+		e.now().Sub(last) < provisioningEstimateRefreshInterval {
+		return nil
+	}
+	estimate, ok := e.estimates[estimateKey(cluster)]
+	if !ok {
+		return nil
+	}
+	m3m.Status.EstimatedReadyTime = &metav1.Time{Time: m3m.CreationTimestamp.Add(estimate)}
+	e.lastRefresh[key] = e.now()
+	return nil
+}
+
+// observe adds a provisioning duration of the cluster to its moving average,
+// and persists it.
+func (e *ProvisioningEstimator) observe(ctx context.Context, cluster types.NamespacedName, duration time.Duration) error {
+	key := estimateKey(cluster)
+	e.mu.Lock()
+	estimate, ok := e.estimates[key]
+	e.estimates[key] = movingAverage(estimate, ok, duration)
+	e.mu.Unlock()
+	return e.persist(ctx, key)
+}
+
+// movingAverage returns the exponentially weighted moving average updated with
+// the sample, or the sample if there is no average yet.
+func movingAverage(average time.Duration, ok bool, sample time.Duration) time.Duration {
+	if !ok {
+		return sample
+	}
+	return average + time.Duration(provisioningEstimateWeight*float64(sample-average))
+}
+
+// load reads the persisted estimates, once. The estimates recorded while the
+// ConfigMap was read are kept.
+func (e *ProvisioningEstimator) load(ctx context.Context) error {
+	e.mu.Lock()
+	loaded := e.loaded
+	e.mu.Unlock()
+	if loaded || e.configMap.Namespace == "" {
+		return nil
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := e.reader.Get(ctx, e.configMap, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loaded {
+		return nil
+	}
+	for key, value := range configMap.Data {
+		if _, ok := e.estimates[key]; ok {
+			continue
+		}
+		// Invalid estimates are dropped, they are overwritten by the next
+		// provisioning of the cluster.
+		if estimate, err := time.ParseDuration(value); err == nil {
+			e.estimates[key] = estimate
+		}
+	}
+	e.loaded = true
+	return nil
+}
+
+// persist writes the estimate of the cluster in the ConfigMap. The ConfigMap
+// may be written concurrently for other clusters, the write is retried on
+// conflict with the latest estimate in memory.
+func (e *ProvisioningEstimator) persist(ctx context.Context, key string) error {
+	if e.configMap.Namespace == "" {
+		return nil
+	}
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := e.reader.Get(ctx, e.configMap, configMap)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		e.mu.Lock()
+		value := e.estimates[key].String()
+		e.mu.Unlock()
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      e.configMap.Name,
+					Namespace: e.configMap.Namespace,
+				},
+				Data: map[string]string{key: value},
+			}
+			return e.client.Create(ctx, configMap)
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = value
+		return e.client.Update(ctx, configMap)
+	})
+}
+
+// estimateKey returns the ConfigMap key of the estimate of the cluster. The
+// underscore can not be part of a namespace or a cluster name.
+func estimateKey(cluster types.NamespacedName) string {
+	return cluster.Namespace + "_" + cluster.Name
+}
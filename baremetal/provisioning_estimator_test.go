@@ -0,0 +1,206 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package baremetal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Provisioning estimator", func() {
+	var (
+		start     = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		cluster   = types.NamespacedName{Namespace: namespaceName, Name: clusterName}
+		configMap = types.NamespacedName{Namespace: "capm3-system", Name: ProvisioningEstimatesConfigMapName}
+	)
+
+	newEstimator := func(c client.Client, now *time.Time) *ProvisioningEstimator {
+		e := NewProvisioningEstimator(c, c, configMap)
+		e.now = func() time.Time { return *now }
+		return e
+	}
+	newProvisioningM3Machine := func(name string) *infrav1.Metal3Machine {
+		return &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespaceName,
+				CreationTimestamp: metav1.Time{Time: start},
+			},
+		}
+	}
+
+	DescribeTable("Test movingAverage",
+		func(average time.Duration, ok bool, sample time.Duration, expected time.Duration) {
+			Expect(movingAverage(average, ok, sample)).To(Equal(expected))
+		},
+		Entry("No average yet", time.Duration(0), false, 10*time.Minute, 10*time.Minute),
+		Entry("Longer sample", 10*time.Minute, true, 20*time.Minute, 13*time.Minute),
+		Entry("Shorter sample", 10*time.Minute, true, 5*time.Minute, 8*time.Minute+30*time.Second),
+		Entry("Same sample", 10*time.Minute, true, 10*time.Minute, 10*time.Minute),
+	)
+
+	It("Leaves the estimate empty without history", func() {
+		now := start.Add(time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		e := newEstimator(fakeClient, &now)
+
+		m3m := newProvisioningM3Machine("provisioning")
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(BeNil())
+	})
+
+	It("Estimates the ready time from the recorded provisioning durations", func() {
+		now := start.Add(20 * time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		e := newEstimator(fakeClient, &now)
+
+		// A machine became ready after 20 minutes.
+		ready := newProvisioningM3Machine("ready")
+		ready.Status.Ready = true
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, ready, false)).To(Succeed())
+		Expect(ready.Status.EstimatedReadyTime).To(BeNil())
+
+		// It is not recorded twice.
+		now = start.Add(time.Hour)
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, ready, true)).To(Succeed())
+
+		m3m := newProvisioningM3Machine("provisioning")
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(Equal(&metav1.Time{Time: start.Add(20 * time.Minute)}))
+
+		// Machines of other clusters are not estimated.
+		other := newProvisioningM3Machine("other")
+		otherCluster := types.NamespacedName{Namespace: namespaceName, Name: "other"}
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), otherCluster, other, false)).To(Succeed())
+		Expect(other.Status.EstimatedReadyTime).To(BeNil())
+
+		// The estimate is cleared once the machine is ready.
+		now = start.Add(30 * time.Minute)
+		m3m.Status.Ready = true
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(BeNil())
+		Expect(e.estimates[estimateKey(cluster)]).To(Equal(23 * time.Minute))
+	})
+
+	It("Refreshes the estimated ready time at most every refresh interval", func() {
+		now := start.Add(10 * time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		e := newEstimator(fakeClient, &now)
+		e.estimates[estimateKey(cluster)] = 10 * time.Minute
+		e.loaded = true
+
+		m3m := newProvisioningM3Machine("provisioning")
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(Equal(&metav1.Time{Time: start.Add(10 * time.Minute)}))
+
+		// The estimate changes, but the machine was refreshed recently.
+		e.estimates[estimateKey(cluster)] = 20 * time.Minute
+		now = now.Add(provisioningEstimateRefreshInterval - time.Second)
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(Equal(&metav1.Time{Time: start.Add(10 * time.Minute)}))
+
+		now = now.Add(time.Second)
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(Equal(&metav1.Time{Time: start.Add(20 * time.Minute)}))
+
+		// A deleted machine is not estimated.
+		m3m.DeletionTimestamp = &metav1.Time{Time: now}
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(BeNil())
+		Expect(e.lastRefresh).To(BeEmpty())
+	})
+
+	It("Keeps the estimates across restarts", func() {
+		now := start.Add(15 * time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMap.Name,
+				Namespace: configMap.Namespace,
+			},
+			Data: map[string]string{"invalid": "abc"},
+		}).Build()
+		e := newEstimator(fakeClient, &now)
+
+		ready := newProvisioningM3Machine("ready")
+		ready.Status.Ready = true
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, ready, false)).To(Succeed())
+
+		savedConfigMap := corev1.ConfigMap{}
+		Expect(fakeClient.Get(context.TODO(), configMap, &savedConfigMap)).To(Succeed())
+		Expect(savedConfigMap.Data).To(HaveKeyWithValue(estimateKey(cluster), "15m0s"))
+
+		// A new estimator loads the persisted estimates.
+		restarted := newEstimator(fakeClient, &now)
+		m3m := newProvisioningM3Machine("provisioning")
+		Expect(restarted.UpdateEstimatedReadyTime(context.TODO(), cluster, m3m, false)).To(Succeed())
+		Expect(m3m.Status.EstimatedReadyTime).To(Equal(&metav1.Time{Time: start.Add(15 * time.Minute)}))
+		Expect(restarted.estimates).NotTo(HaveKey("invalid"))
+	})
+
+	It("Creates the ConfigMap of the estimates", func() {
+		now := start.Add(15 * time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		e := newEstimator(fakeClient, &now)
+
+		ready := newProvisioningM3Machine("ready")
+		ready.Status.Ready = true
+		Expect(e.UpdateEstimatedReadyTime(context.TODO(), cluster, ready, false)).To(Succeed())
+
+		savedConfigMap := corev1.ConfigMap{}
+		Expect(fakeClient.Get(context.TODO(), configMap, &savedConfigMap)).To(Succeed())
+		Expect(savedConfigMap.Data).To(Equal(map[string]string{estimateKey(cluster): "15m0s"}))
+	})
+
+	It("Persists the estimates of the clusters provisioned concurrently", func() {
+		now := start.Add(15 * time.Minute)
+		fakeClient := fake.NewClientBuilder().WithScheme(setupSchemeMm()).Build()
+		e := newEstimator(fakeClient, &now)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				ready := newProvisioningM3Machine("ready")
+				ready.Status.Ready = true
+				otherCluster := types.NamespacedName{Namespace: namespaceName, Name: fmt.Sprintf("cluster-%d", i)}
+				Expect(e.UpdateEstimatedReadyTime(context.TODO(), otherCluster, ready, false)).To(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		savedConfigMap := corev1.ConfigMap{}
+		Expect(fakeClient.Get(context.TODO(), configMap, &savedConfigMap)).To(Succeed())
+		Expect(savedConfigMap.Data).To(HaveLen(5))
+		for i := 0; i < 5; i++ {
+			otherCluster := types.NamespacedName{Namespace: namespaceName, Name: fmt.Sprintf("cluster-%d", i)}
+			Expect(savedConfigMap.Data).To(HaveKeyWithValue(estimateKey(otherCluster), "15m0s"))
+		}
+	})
+})
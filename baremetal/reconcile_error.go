@@ -58,6 +58,11 @@ func (e ReconcileError) Error() string {
 	}
 }
 
+// Unwrap returns the underlying error of a ReconcileError.
+func (e ReconcileError) Unwrap() error {
+	return e.error
+}
+
 // GetRequeueAfter gets the duration to wait until the managed object is
 // requeued for further processing.
 func (e ReconcileError) GetRequeueAfter() time.Duration {
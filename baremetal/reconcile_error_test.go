@@ -47,6 +47,13 @@ var _ = Describe("Reconcile Error testing", func() {
 		Expect(err.Error()).To(Equal(fmt.Sprintf("reconcile error that cannot be recovered occurred: %s. Object will not be requeued", "Terminal Error")))
 	})
 
+	It("Unwraps the underlying error", func() {
+		baseErr := errors.New("Base Error")
+		err := WithTransientError(baseErr, duration)
+		Expect(errors.Is(err, baseErr)).To(BeTrue())
+		Expect(errors.Is(WithTerminalError(baseErr), baseErr)).To(BeTrue())
+	})
+
 	It("Returns correct values for Unknown ReconcileError type", func() {
 		err := ReconcileError{errors.New("Unknown Error"), "unknownErrorType", 0 * time.Second}
 		Expect(err.IsTerminal()).To(BeFalse())
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// probeTimeout is the time given to the API server of a workload cluster to
+// answer the probe.
+const probeTimeout = 5 * time.Second
+
+// ClusterClientCache creates the clients of the workload clusters from REST
+// configurations cached per Cluster. A configuration is only parsed again
+// when the resourceVersion of the kubeconfig secret changes, e.g. when the
+// secret is rotated, so that the connections to the cluster are reused.
+type ClusterClientCache struct {
+	lock    sync.Mutex
+	configs map[client.ObjectKey]cachedRESTConfig
+
+	// probe checks that the API server of a workload cluster answers.
+	probe func(ctx context.Context, restConfig *rest.Config) error
+}
+
+// cachedRESTConfig is the REST configuration of a workload cluster and the
+// resourceVersion of the kubeconfig secret it comes from.
+type cachedRESTConfig struct {
+	resourceVersion string
+	restConfig      *rest.Config
+}
+
+// NewClusterClientCache returns an empty ClusterClientCache.
+func NewClusterClientCache() *ClusterClientCache {
+	return &ClusterClientCache{
+		configs: map[client.ObjectKey]cachedRESTConfig{},
+		probe:   probeVersion,
+	}
+}
+
+// NewClusterClient creates a client for the workload cluster, once its API
+// server answered a /version request. A ClusterUnreachableError is returned
+// while the API server can not be reached or is not serving yet, and a
+// KubeconfigError if the kubeconfig secret is missing or invalid, or if its
+// credentials are rejected.
+func (c *ClusterClientCache) NewClusterClient(ctx context.Context, cl client.Client, cluster *clusterv1.Cluster) (corev1.CoreV1Interface, error) {
+	restConfig, err := c.restConfig(ctx, cl, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.probe(ctx, restConfig); err != nil {
+		err = errors.Wrapf(err, "failed to reach the API server of Cluster %q in namespace %q",
+			cluster.Name, cluster.Namespace)
+		if apierrors.IsUnauthorized(err) {
+			return nil, &KubeconfigError{Reason: infrav1.KubeconfigUnauthorizedReason, Err: err}
+		}
+		if isUnreachable(err) {
+			return nil, &ClusterUnreachableError{Err: err}
+		}
+		return nil, err
+	}
+	return corev1.NewForConfig(restConfig)
+}
+
+// restConfig returns the REST configuration of the workload cluster, from the
+// cache if the kubeconfig secret did not change.
+func (c *ClusterClientCache) restConfig(ctx context.Context, cl client.Client, cluster *clusterv1.Cluster) (*rest.Config, error) {
+	key := util.ObjectKey(cluster)
+	kubeconfigSecret, err := getKubeconfigSecret(ctx, cl, cluster)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		delete(c.configs, key)
+		return nil, err
+	}
+	if cached, ok := c.configs[key]; ok && cached.resourceVersion == kubeconfigSecret.ResourceVersion {
+		return cached.restConfig, nil
+	}
+	restConfig, err := restConfigFromSecret(kubeconfigSecret, cluster)
+	if err != nil {
+		delete(c.configs, key)
+		return nil, err
+	}
+	c.configs[key] = cachedRESTConfig{
+		resourceVersion: kubeconfigSecret.ResourceVersion,
+		restConfig:      restConfig,
+	}
+	return restConfig, nil
+}
+
+// probeVersion requests the version of the API server of a workload cluster.
+func probeVersion(ctx context.Context, restConfig *rest.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	clientset, err := corev1.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	return clientset.RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+}
+
+// isUnreachable returns true if the error of a request to an API server means
+// that it could not be reached, e.g. the connection was refused or timed out,
+// or that it is not serving yet.
+func isUnreachable(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return true
+	}
+	return apierrors.IsServiceUnavailable(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err)
+}
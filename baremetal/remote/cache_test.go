@@ -0,0 +1,212 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ClusterClientCache", func() {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test1",
+			Namespace: "test",
+		},
+	}
+	kubeconfig := func(server string) []byte {
+		return []byte(fmt.Sprintf(`
+clusters:
+- cluster:
+    server: %s
+  name: test-cluster-api
+contexts:
+- context:
+    cluster: test-cluster-api
+    user: kubernetes-admin
+  name: kubernetes-admin@test-cluster-api
+current-context: kubernetes-admin@test-cluster-api
+kind: Config
+preferences: {}
+users:
+- name: kubernetes-admin
+`, server))
+	}
+	kubeconfigSecret := func(server string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test1-kubeconfig",
+				Namespace: "test",
+			},
+			Data: map[string][]byte{
+				secret.KubeconfigDataName: kubeconfig(server),
+			},
+		}
+	}
+	connectionRefused := &url.Error{Op: "Get", URL: "https://test-cluster-api:6443/version", Err: syscall.ECONNREFUSED}
+
+	It("caches the REST configuration until the kubeconfig secret is rotated", func() {
+		kubeconfigSecret := kubeconfigSecret("https://test-cluster-api:6443")
+		fakeClient := fake.NewClientBuilder().WithObjects(kubeconfigSecret).Build()
+		probed := []string{}
+		cache := NewClusterClientCache()
+		cache.probe = func(_ context.Context, restConfig *rest.Config) error {
+			probed = append(probed, restConfig.Host)
+			return nil
+		}
+
+		_, err := cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		Expect(err).NotTo(HaveOccurred())
+		cached := cache.configs[client.ObjectKeyFromObject(cluster)].restConfig
+		_, err = cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.configs[client.ObjectKeyFromObject(cluster)].restConfig).To(BeIdenticalTo(cached))
+
+		// The rotated secret has another resourceVersion.
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(kubeconfigSecret), kubeconfigSecret)).To(Succeed())
+		kubeconfigSecret.Data[secret.KubeconfigDataName] = kubeconfig("https://test-cluster-api-rotated:6443")
+		Expect(fakeClient.Update(context.TODO(), kubeconfigSecret)).To(Succeed())
+		_, err = cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.configs[client.ObjectKeyFromObject(cluster)].restConfig).NotTo(BeIdenticalTo(cached))
+		Expect(probed).To(Equal([]string{
+			"https://test-cluster-api:6443",
+			"https://test-cluster-api:6443",
+			"https://test-cluster-api-rotated:6443",
+		}))
+
+		// The configuration is dropped with the secret.
+		Expect(fakeClient.Delete(context.TODO(), kubeconfigSecret)).To(Succeed())
+		_, err = cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		kubeconfigErr, ok := AsKubeconfigError(err)
+		Expect(ok).To(BeTrue())
+		Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigNotFoundReason))
+		Expect(cache.configs).To(BeEmpty())
+	})
+
+	It("returns a ClusterUnreachableError while the connection is refused", func() {
+		fakeClient := fake.NewClientBuilder().WithObjects(kubeconfigSecret("https://test-cluster-api:6443")).Build()
+		refused := true
+		cache := NewClusterClientCache()
+		cache.probe = func(_ context.Context, _ *rest.Config) error {
+			if refused {
+				return connectionRefused
+			}
+			return nil
+		}
+
+		_, err := cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		Expect(err).To(HaveOccurred())
+		_, ok := AsClusterUnreachableError(err)
+		Expect(ok).To(BeTrue())
+		_, ok = AsKubeconfigError(err)
+		Expect(ok).To(BeFalse())
+
+		refused = false
+		c, err := cache.NewClusterClient(context.TODO(), fakeClient, cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).NotTo(BeNil())
+	})
+
+	type testCaseProbe struct {
+		Status            int
+		Closed            bool
+		ExpectUnreachable bool
+		ExpectReason      string
+		ExpectError       bool
+	}
+
+	DescribeTable("Probe of the API server",
+		func(tc testCaseProbe) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.Status)
+				_, _ = w.Write([]byte(`{"major": "1", "minor": "27"}`))
+			}))
+			if tc.Closed {
+				server.Close()
+			} else {
+				defer server.Close()
+			}
+			fakeClient := fake.NewClientBuilder().WithObjects(kubeconfigSecret(server.URL)).Build()
+
+			_, err := NewClusterClientCache().NewClusterClient(context.TODO(), fakeClient, cluster)
+			_, unreachable := AsClusterUnreachableError(err)
+			Expect(unreachable).To(Equal(tc.ExpectUnreachable))
+			kubeconfigErr, ok := AsKubeconfigError(err)
+			if tc.ExpectReason != "" {
+				Expect(ok).To(BeTrue())
+				Expect(kubeconfigErr.Reason).To(Equal(tc.ExpectReason))
+			} else {
+				Expect(ok).To(BeFalse())
+			}
+			if tc.ExpectError {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		},
+		Entry("API server serving", testCaseProbe{
+			Status: http.StatusOK,
+		}),
+		Entry("Connection refused", testCaseProbe{
+			Closed:            true,
+			ExpectUnreachable: true,
+			ExpectError:       true,
+		}),
+		Entry("API server not serving yet", testCaseProbe{
+			Status:            http.StatusServiceUnavailable,
+			ExpectUnreachable: true,
+			ExpectError:       true,
+		}),
+		Entry("Credentials rejected", testCaseProbe{
+			Status:       http.StatusUnauthorized,
+			ExpectReason: infrav1.KubeconfigUnauthorizedReason,
+			ExpectError:  true,
+		}),
+		Entry("Other error", testCaseProbe{
+			Status:      http.StatusForbidden,
+			ExpectError: true,
+		}),
+	)
+
+	DescribeTable("AsClusterUnreachableError",
+		func(err error, expected bool) {
+			_, ok := AsClusterUnreachableError(err)
+			Expect(ok).To(Equal(expected))
+		},
+		Entry("No error", nil, false),
+		Entry("Other error", errors.New("failed"), false),
+		Entry("Cluster unreachable error", errors.Wrap(&ClusterUnreachableError{Err: connectionRefused}, "failed"), true),
+	)
+})
@@ -0,0 +1,254 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/util/secret"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// HostClusterRESTConfig returns the REST configuration of a host cluster, in
+// which BareMetalHosts are registered, from the kubeconfig held under the
+// value key of the given secret. A KubeconfigError is returned if the secret
+// does not exist or does not hold a valid kubeconfig.
+func HostClusterRESTConfig(ctx context.Context, c client.Reader, key client.ObjectKey) (*rest.Config, error) {
+	kubeconfigSecret, err := getHostKubeconfigSecret(ctx, c, key)
+	if err != nil {
+		return nil, err
+	}
+	return hostRESTConfigFromSecret(kubeconfigSecret)
+}
+
+// getHostKubeconfigSecret returns the kubeconfig secret of a host cluster. A
+// KubeconfigError is returned if the secret does not exist.
+func getHostKubeconfigSecret(ctx context.Context, c client.Reader, key client.ObjectKey) (*corev1.Secret, error) {
+	kubeconfigSecret := &corev1.Secret{}
+	if err := c.Get(ctx, key, kubeconfigSecret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &KubeconfigError{
+				Reason: infrav1.KubeconfigNotFoundReason,
+				Err:    errors.Wrapf(err, "failed to retrieve host cluster kubeconfig secret %s", key),
+			}
+		}
+		return nil, errors.Wrapf(err, "failed to retrieve host cluster kubeconfig secret %s", key)
+	}
+	return kubeconfigSecret, nil
+}
+
+// hostRESTConfigFromSecret returns the REST configuration held by the
+// kubeconfig secret of a host cluster. A KubeconfigError is returned if the
+// secret does not hold a valid kubeconfig.
+func hostRESTConfigFromSecret(kubeconfigSecret *corev1.Secret) (*rest.Config, error) {
+	kubeconfig, ok := kubeconfigSecret.Data[secret.KubeconfigDataName]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, &KubeconfigError{
+			Reason: infrav1.KubeconfigInvalidReason,
+			Err: errors.Errorf("missing key %q in host cluster kubeconfig secret %s/%s",
+				secret.KubeconfigDataName, kubeconfigSecret.Namespace, kubeconfigSecret.Name),
+		}
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, &KubeconfigError{
+			Reason: infrav1.KubeconfigInvalidReason,
+			Err: errors.Wrapf(err, "failed to create client configuration for host cluster kubeconfig secret %s/%s",
+				kubeconfigSecret.Namespace, kubeconfigSecret.Name),
+		}
+	}
+	return restConfig, nil
+}
+
+// hostCacheSyncTimeout is the maximum time waited for the cache of a host
+// cluster to be synced when it is created.
+const hostCacheSyncTimeout = 30 * time.Second
+
+// hostCluster is the subset of cluster.Cluster used for a host cluster.
+type hostCluster interface {
+	GetClient() client.Client
+	GetCache() cache.Cache
+	Start(ctx context.Context) error
+}
+
+// HostWatcher registers the watches of a controller on the cache of a host
+// cluster, e.g. of its BareMetalHosts.
+type HostWatcher func(hostCache cache.Cache) error
+
+// HostClusterClients returns the clients of the clusters in which the
+// BareMetalHosts of the Metal3Clusters are registered. A cached cluster is
+// created per kubeconfig secret referenced by a Metal3Cluster, with the
+// watches of the registered HostWatchers, and only created again when the
+// resourceVersion of the secret changes. The cache of a cluster is stopped
+// when its kubeconfig secret changes or is deleted.
+type HostClusterClients struct {
+	// ctx bounds the lifetime of the caches of the host clusters.
+	ctx      context.Context
+	lock     sync.Mutex
+	clusters map[client.ObjectKey]cachedHostCluster
+	watchers []HostWatcher
+
+	// defaultClient is the client of the host cluster of the Metal3Clusters
+	// not referencing one, nil for the cluster of the controller.
+	defaultClient client.Client
+	// newCluster creates the cluster of a host cluster.
+	newCluster func(restConfig *rest.Config) (hostCluster, error)
+}
+
+// cachedHostCluster is a host cluster, the resourceVersion of the kubeconfig
+// secret it was created from and the function stopping its cache.
+type cachedHostCluster struct {
+	resourceVersion string
+	cluster         hostCluster
+	stop            context.CancelFunc
+}
+
+// NewHostClusterClients returns a HostClusterClients creating the clusters of
+// the host clusters with the given scheme and cache options, whose caches are
+// stopped with ctx. The Metal3Clusters not referencing a host cluster use
+// defaultClient, or the cluster of the controller if nil.
+func NewHostClusterClients(ctx context.Context, defaultClient client.Client, scheme *runtime.Scheme,
+	byObject map[client.Object]cache.ByObject,
+) *HostClusterClients {
+	return &HostClusterClients{
+		ctx:           ctx,
+		clusters:      map[client.ObjectKey]cachedHostCluster{},
+		defaultClient: defaultClient,
+		newCluster: func(restConfig *rest.Config) (hostCluster, error) {
+			return cluster.New(restConfig, func(o *cluster.Options) {
+				o.Scheme = scheme
+				o.Cache.ByObject = byObject
+			})
+		},
+	}
+}
+
+// AddWatcher registers a HostWatcher, called for the cache of every host
+// cluster, including those already created.
+func (h *HostClusterClients) AddWatcher(watcher HostWatcher) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.watchers = append(h.watchers, watcher)
+	for key, cached := range h.clusters {
+		if err := watcher(cached.cluster.GetCache()); err != nil {
+			return errors.Wrapf(err, "failed to watch host cluster of kubeconfig secret %s", key)
+		}
+	}
+	return nil
+}
+
+// HostClient returns the client of the host cluster of the Metal3Cluster, or
+// nil if its BareMetalHosts are registered in the cluster of the controller.
+// The kubeconfig secret referenced by the Metal3Cluster is read with c.
+func (h *HostClusterClients) HostClient(ctx context.Context, c client.Client, metal3Cluster *infrav1.Metal3Cluster) (client.Client, error) {
+	if metal3Cluster == nil || metal3Cluster.Spec.HostClusterKubeconfigSecret == nil {
+		return h.defaultClient, nil
+	}
+	key := client.ObjectKey{
+		Name:      metal3Cluster.Spec.HostClusterKubeconfigSecret.Name,
+		Namespace: metal3Cluster.Namespace,
+	}
+	kubeconfigSecret, err := getHostKubeconfigSecret(ctx, c, key)
+	if err != nil {
+		if kubeconfigErr, ok := AsKubeconfigError(err); ok && kubeconfigErr.Reason == infrav1.KubeconfigNotFoundReason {
+			h.deleteCluster(key)
+		}
+		return nil, err
+	}
+	h.lock.Lock()
+	cached, ok := h.clusters[key]
+	h.lock.Unlock()
+	if ok && cached.resourceVersion == kubeconfigSecret.ResourceVersion {
+		return cached.cluster.GetClient(), nil
+	}
+
+	// The cluster is created and its cache synced without holding the lock.
+	restConfig, err := hostRESTConfigFromSecret(kubeconfigSecret)
+	if err != nil {
+		return nil, err
+	}
+	created, err := h.startCluster(ctx, key, restConfig)
+	if err != nil {
+		return nil, err
+	}
+	created.resourceVersion = kubeconfigSecret.ResourceVersion
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if cached, ok := h.clusters[key]; ok {
+		if cached.resourceVersion == created.resourceVersion {
+			// Created concurrently by another reconcile.
+			created.stop()
+			return cached.cluster.GetClient(), nil
+		}
+		cached.stop()
+	}
+	for _, watcher := range h.watchers {
+		if err := watcher(created.cluster.GetCache()); err != nil {
+			created.stop()
+			delete(h.clusters, key)
+			return nil, errors.Wrapf(err, "failed to watch host cluster of kubeconfig secret %s", key)
+		}
+	}
+	h.clusters[key] = created
+	return created.cluster.GetClient(), nil
+}
+
+// startCluster creates the cluster of a host cluster and starts its cache,
+// returning once the cache is synced.
+func (h *HostClusterClients) startCluster(ctx context.Context, key client.ObjectKey, restConfig *rest.Config) (cachedHostCluster, error) {
+	hostCluster, err := h.newCluster(restConfig)
+	if err != nil {
+		return cachedHostCluster{}, errors.Wrapf(err, "failed to create the client of host cluster kubeconfig secret %s", key)
+	}
+	clusterCtx, stop := context.WithCancel(h.ctx)
+	go func() {
+		if err := hostCluster.Start(clusterCtx); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "host cluster cache stopped", "secret", key)
+		}
+	}()
+	syncCtx, cancel := context.WithTimeout(ctx, hostCacheSyncTimeout)
+	defer cancel()
+	if !hostCluster.GetCache().WaitForCacheSync(syncCtx) {
+		stop()
+		return cachedHostCluster{}, errors.Errorf("failed to sync the cache of host cluster kubeconfig secret %s", key)
+	}
+	return cachedHostCluster{cluster: hostCluster, stop: stop}, nil
+}
+
+// deleteCluster stops the cache of the host cluster of the kubeconfig secret
+// and forgets it.
+func (h *HostClusterClients) deleteCluster(key client.ObjectKey) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if cached, ok := h.clusters[key]; ok {
+		cached.stop()
+		delete(h.clusters, key)
+	}
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+var _ = Describe("HostClusterClients", func() {
+	kubeconfig := func(server string) []byte {
+		return []byte(`
+clusters:
+- cluster:
+    server: ` + server + `
+  name: hardware
+contexts:
+- context:
+    cluster: hardware
+    user: capm3
+  name: capm3@hardware
+current-context: capm3@hardware
+kind: Config
+users:
+- name: capm3
+`)
+	}
+	metal3Cluster := &infrav1.Metal3Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test1",
+			Namespace: "test",
+		},
+		Spec: infrav1.Metal3ClusterSpec{
+			HostClusterKubeconfigSecret: &corev1.LocalObjectReference{Name: "hardware-kubeconfig"},
+		},
+	}
+	secretKey := client.ObjectKey{Name: "hardware-kubeconfig", Namespace: "test"}
+
+	newHostClusterClients := func(defaultClient client.Client) (*HostClusterClients, *[]*fakeHostCluster) {
+		created := []*fakeHostCluster{}
+		h := NewHostClusterClients(context.Background(), defaultClient, nil, nil)
+		h.newCluster = func(restConfig *rest.Config) (hostCluster, error) {
+			hostCluster := &fakeHostCluster{
+				host:    restConfig.Host,
+				client:  fake.NewClientBuilder().Build(),
+				cache:   &informertest.FakeInformers{},
+				stopped: make(chan struct{}),
+			}
+			created = append(created, hostCluster)
+			return hostCluster, nil
+		}
+		return h, &created
+	}
+	hosts := func(created []*fakeHostCluster) []string {
+		hosts := []string{}
+		for _, hostCluster := range created {
+			hosts = append(hosts, hostCluster.host)
+		}
+		return hosts
+	}
+
+	It("returns the default client for a Metal3Cluster without host cluster", func() {
+		defaultClient := fake.NewClientBuilder().Build()
+		h, created := newHostClusterClients(defaultClient)
+		hostClient, err := h.HostClient(context.TODO(), fake.NewClientBuilder().Build(), &infrav1.Metal3Cluster{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostClient).To(BeIdenticalTo(defaultClient))
+		Expect(hosts(*created)).To(BeEmpty())
+
+		h, _ = newHostClusterClients(nil)
+		hostClient, err = h.HostClient(context.TODO(), fake.NewClientBuilder().Build(), &infrav1.Metal3Cluster{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostClient).To(BeNil())
+	})
+
+	It("caches the client of a host cluster until the kubeconfig secret is rotated", func() {
+		kubeconfigSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+			},
+			Data: map[string][]byte{
+				secret.KubeconfigDataName: kubeconfig("https://hardware:6443"),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithObjects(kubeconfigSecret).Build()
+		h, created := newHostClusterClients(fake.NewClientBuilder().Build())
+
+		hostClient, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+		cachedClient, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cachedClient).To(BeIdenticalTo(hostClient))
+
+		// The rotated secret has another resourceVersion.
+		Expect(fakeClient.Get(context.TODO(), secretKey, kubeconfigSecret)).To(Succeed())
+		kubeconfigSecret.Data[secret.KubeconfigDataName] = kubeconfig("https://hardware-rotated:6443")
+		Expect(fakeClient.Update(context.TODO(), kubeconfigSecret)).To(Succeed())
+		rotatedClient, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rotatedClient).NotTo(BeIdenticalTo(hostClient))
+		Expect(hosts(*created)).To(Equal([]string{"https://hardware:6443", "https://hardware-rotated:6443"}))
+		// The cache of the previous cluster is stopped.
+		Eventually((*created)[0].stopped).Should(BeClosed())
+		Consistently((*created)[1].stopped).ShouldNot(BeClosed())
+
+		// The cluster is dropped, and its cache stopped, with the secret.
+		Expect(fakeClient.Delete(context.TODO(), kubeconfigSecret)).To(Succeed())
+		_, err = h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		kubeconfigErr, ok := AsKubeconfigError(err)
+		Expect(ok).To(BeTrue())
+		Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigNotFoundReason))
+		Expect(h.clusters).To(BeEmpty())
+		Eventually((*created)[1].stopped).Should(BeClosed())
+	})
+
+	It("registers the watchers on the cache of every host cluster", func() {
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+			},
+			Data: map[string][]byte{
+				secret.KubeconfigDataName: kubeconfig("https://hardware:6443"),
+			},
+		}).Build()
+		h, created := newHostClusterClients(nil)
+		watched := map[string][]cache.Cache{}
+		watcher := func(name string) HostWatcher {
+			return func(hostCache cache.Cache) error {
+				watched[name] = append(watched[name], hostCache)
+				return nil
+			}
+		}
+
+		Expect(h.AddWatcher(watcher("before"))).To(Succeed())
+		_, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.AddWatcher(watcher("after"))).To(Succeed())
+		_, err = h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(*created).To(HaveLen(1))
+		Expect(watched["before"]).To(ConsistOf(BeIdenticalTo((*created)[0].cache)))
+		Expect(watched["after"]).To(ConsistOf(BeIdenticalTo((*created)[0].cache)))
+	})
+
+	It("does not keep a cluster whose cache does not sync", func() {
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+			},
+			Data: map[string][]byte{
+				secret.KubeconfigDataName: kubeconfig("https://hardware:6443"),
+			},
+		}).Build()
+		h, created := newHostClusterClients(nil)
+		newCluster := h.newCluster
+		h.newCluster = func(restConfig *rest.Config) (hostCluster, error) {
+			hostCluster, err := newCluster(restConfig)
+			hostCluster.(*fakeHostCluster).cache.Synced = pointer.Bool(false)
+			return hostCluster, err
+		}
+
+		_, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		Expect(err).To(MatchError(ContainSubstring("failed to sync the cache")))
+		Expect(h.clusters).To(BeEmpty())
+		Eventually((*created)[0].stopped).Should(BeClosed())
+	})
+
+	It("returns a KubeconfigError for a secret without kubeconfig", func() {
+		fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+			},
+		}).Build()
+		h, created := newHostClusterClients(nil)
+		_, err := h.HostClient(context.TODO(), fakeClient, metal3Cluster)
+		kubeconfigErr, ok := AsKubeconfigError(err)
+		Expect(ok).To(BeTrue())
+		Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigInvalidReason))
+		Expect(hosts(*created)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("HostClusterClients with a management and a hardware cluster", Ordered, func() {
+	var (
+		ctx            context.Context
+		cancel         context.CancelFunc
+		testScheme     *runtime.Scheme
+		managementEnv  *envtest.Environment
+		hardwareEnv    *envtest.Environment
+		managementClnt client.Client
+		hardwareClnt   client.Client
+		metal3Cluster  *infrav1.Metal3Cluster
+	)
+
+	BeforeAll(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(bmov1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		// The BareMetalHosts are registered in the hardware cluster only.
+		managementEnv = &envtest.Environment{}
+		managementCfg, err := managementEnv.Start()
+		Expect(err).NotTo(HaveOccurred())
+		hardwareEnv = &envtest.Environment{
+			CRDDirectoryPaths: []string{filepath.Join("..", "..", "examples", "metal3crds")},
+		}
+		hardwareCfg, err := hardwareEnv.Start()
+		Expect(err).NotTo(HaveOccurred())
+
+		managementClnt, err = client.New(managementCfg, client.Options{Scheme: testScheme})
+		Expect(err).NotTo(HaveOccurred())
+		hardwareClnt, err = client.New(hardwareCfg, client.Options{Scheme: testScheme})
+		Expect(err).NotTo(HaveOccurred())
+		for _, c := range []client.Client{managementClnt, hardwareClnt} {
+			Expect(c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})).To(Succeed())
+		}
+
+		user, err := hardwareEnv.AddUser(envtest.User{Name: "capm3", Groups: []string{"system:masters"}}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		kubeconfig, err := user.KubeConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(managementClnt.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hardware-kubeconfig",
+				Namespace: "test",
+			},
+			Data: map[string][]byte{secret.KubeconfigDataName: kubeconfig},
+		})).To(Succeed())
+		metal3Cluster = &infrav1.Metal3Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test1",
+				Namespace: "test",
+			},
+			Spec: infrav1.Metal3ClusterSpec{
+				HostClusterKubeconfigSecret: &corev1.LocalObjectReference{Name: "hardware-kubeconfig"},
+			},
+		}
+	})
+
+	AfterAll(func() {
+		cancel()
+		Expect(managementEnv.Stop()).To(Succeed())
+		Expect(hardwareEnv.Stop()).To(Succeed())
+	})
+
+	It("reads and watches the BareMetalHosts of the hardware cluster", func() {
+		h := NewHostClusterClients(ctx, nil, testScheme, nil)
+		events := make(chan string, 10)
+		Expect(h.AddWatcher(func(hostCache cache.Cache) error {
+			informer, err := hostCache.GetInformer(ctx, &bmov1alpha1.BareMetalHost{})
+			if err != nil {
+				return err
+			}
+			_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					events <- obj.(*bmov1alpha1.BareMetalHost).Name
+				},
+			})
+			return err
+		})).To(Succeed())
+
+		hostClient, err := h.HostClient(ctx, managementClnt, metal3Cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		host := &bmov1alpha1.BareMetalHost{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "host-0",
+				Namespace: "test",
+			},
+		}
+		Expect(hardwareClnt.Create(ctx, host)).To(Succeed())
+
+		// The watch of the hardware cluster is triggered, and the host is read
+		// from its cache.
+		Eventually(events, 30*time.Second).Should(Receive(Equal("host-0")))
+		Eventually(func() error {
+			return hostClient.Get(ctx, client.ObjectKeyFromObject(host), &bmov1alpha1.BareMetalHost{})
+		}).Should(Succeed())
+
+		// The cluster is dropped with its kubeconfig secret.
+		Expect(managementClnt.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "hardware-kubeconfig",
+			Namespace: "test",
+		}})).To(Succeed())
+		_, err = h.HostClient(ctx, managementClnt, metal3Cluster)
+		kubeconfigErr, ok := AsKubeconfigError(err)
+		Expect(ok).To(BeTrue())
+		Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigNotFoundReason))
+		Expect(h.clusters).To(BeEmpty())
+	})
+})
+
+// fakeHostCluster is a host cluster with a fake client and cache, whose
+// stopped channel is closed when its cache is stopped.
+type fakeHostCluster struct {
+	host    string
+	client  client.Client
+	cache   *informertest.FakeInformers
+	stopped chan struct{}
+}
+
+func (f *fakeHostCluster) GetClient() client.Client {
+	return f.client
+}
+
+func (f *fakeHostCluster) GetCache() cache.Cache {
+	return f.cache
+}
+
+func (f *fakeHostCluster) Start(ctx context.Context) error {
+	<-ctx.Done()
+	close(f.stopped)
+	return nil
+}
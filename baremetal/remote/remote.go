@@ -15,32 +15,163 @@ package remote
 
 import (
 	"context"
+	"fmt"
 
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/types"
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
-	kcfg "sigs.k8s.io/cluster-api/util/kubeconfig"
+	capiremote "sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/secret"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// NewClusterClient creates a new ClusterClient.
-func NewClusterClient(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (corev1.CoreV1Interface, error) {
-	kubeconfig, err := kcfg.FromSecret(ctx, c, types.NamespacedName{
-		Name:      cluster.Name,
-		Namespace: cluster.Namespace,
-	})
+// KubeconfigError is returned when the kubeconfig secret of a workload cluster
+// can not be used. Reason is one of the KubeconfigNotFoundReason,
+// KubeconfigInvalidReason and KubeconfigUnauthorizedReason.
+type KubeconfigError struct {
+	Reason string
+	Err    error
+}
+
+func (e *KubeconfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *KubeconfigError) Unwrap() error {
+	return e.Err
+}
+
+// ClusterUnreachableError is returned when the API server of a workload
+// cluster can not be reached or is not serving yet, e.g. while it restarts
+// during an upgrade. Unlike a KubeconfigError, it is expected to resolve by
+// itself.
+type ClusterUnreachableError struct {
+	Err error
+}
+
+func (e *ClusterUnreachableError) Error() string {
+	return fmt.Sprintf("workload cluster unreachable: %v", e.Err)
+}
+
+func (e *ClusterUnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// AsClusterUnreachableError returns the ClusterUnreachableError wrapped in err.
+func AsClusterUnreachableError(err error) (*ClusterUnreachableError, bool) {
+	var unreachableErr *ClusterUnreachableError
+	if err != nil && errors.As(err, &unreachableErr) {
+		return unreachableErr, true
+	}
+	return nil, false
+}
+
+// AsKubeconfigError returns the KubeconfigError wrapped in err. An
+// Unauthorized error of the workload cluster is returned as a KubeconfigError
+// with the KubeconfigUnauthorizedReason, the credentials of the kubeconfig
+// being rejected.
+func AsKubeconfigError(err error) (*KubeconfigError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var kubeconfigErr *KubeconfigError
+	if errors.As(err, &kubeconfigErr) {
+		return kubeconfigErr, true
+	}
+	if apierrors.IsUnauthorized(err) {
+		return &KubeconfigError{Reason: infrav1.KubeconfigUnauthorizedReason, Err: err}, true
+	}
+	return nil, false
+}
+
+// RESTConfig returns the REST configuration of the workload cluster from its
+// kubeconfig secret. A KubeconfigError is returned if the secret does not
+// exist or does not hold a valid kubeconfig.
+func RESTConfig(ctx context.Context, c client.Reader, cluster *clusterv1.Cluster) (*rest.Config, error) {
+	kubeconfigSecret, err := getKubeconfigSecret(ctx, c, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return restConfigFromSecret(kubeconfigSecret, cluster)
+}
+
+// getKubeconfigSecret returns the kubeconfig secret of the workload cluster.
+// A KubeconfigError is returned if the secret does not exist.
+func getKubeconfigSecret(ctx context.Context, c client.Reader, cluster *clusterv1.Cluster) (*corev1api.Secret, error) {
+	kubeconfigSecret, err := secret.GetFromNamespacedName(ctx, c, util.ObjectKey(cluster), secret.Kubeconfig)
+	if apierrors.IsNotFound(err) {
+		return nil, &KubeconfigError{
+			Reason: infrav1.KubeconfigNotFoundReason,
+			Err: errors.Wrapf(err, "failed to retrieve kubeconfig secret for Cluster %q in namespace %q",
+				cluster.Name, cluster.Namespace),
+		}
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to retrieve kubeconfig secret for Cluster %q in namespace %q",
 			cluster.Name, cluster.Namespace)
 	}
+	return kubeconfigSecret, nil
+}
 
+// restConfigFromSecret returns the REST configuration held by the kubeconfig
+// secret of the workload cluster. A KubeconfigError is returned if the secret
+// does not hold a valid kubeconfig.
+func restConfigFromSecret(kubeconfigSecret *corev1api.Secret, cluster *clusterv1.Cluster) (*rest.Config, error) {
+	kubeconfig, ok := kubeconfigSecret.Data[secret.KubeconfigDataName]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, &KubeconfigError{
+			Reason: infrav1.KubeconfigInvalidReason,
+			Err: errors.Errorf("missing key %q in kubeconfig secret for Cluster %q in namespace %q",
+				secret.KubeconfigDataName, cluster.Name, cluster.Namespace),
+		}
+	}
 	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create client configuration for Cluster %q in namespace %q",
-			cluster.Name, cluster.Namespace)
+		return nil, &KubeconfigError{
+			Reason: infrav1.KubeconfigInvalidReason,
+			Err: errors.Wrapf(err, "failed to create client configuration for Cluster %q in namespace %q",
+				cluster.Name, cluster.Namespace),
+		}
 	}
+	return restConfig, nil
+}
 
+// NewClusterClient creates a new ClusterClient.
+func NewClusterClient(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (corev1.CoreV1Interface, error) {
+	restConfig, err := RESTConfig(ctx, c, cluster)
+	if err != nil {
+		return nil, err
+	}
 	return corev1.NewForConfig(restConfig)
 }
+
+// NewClusterClientFromTracker returns a function creating clients for the
+// workload clusters from the REST configuration held by the
+// ClusterCacheTracker, so that the clusters are health checked and the
+// connections are torn down when the clusters are deleted. The tracker does
+// not tell why the configuration is not available, the kubeconfig secret is
+// checked with the given client to return a KubeconfigError when it is the
+// cause.
+func NewClusterClientFromTracker(tracker *capiremote.ClusterCacheTracker) func(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (corev1.CoreV1Interface, error) {
+	return func(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (corev1.CoreV1Interface, error) {
+		restConfig, err := tracker.GetRESTConfig(ctx, util.ObjectKey(cluster))
+		if err != nil {
+			if c != nil && !errors.Is(err, capiremote.ErrClusterLocked) {
+				if _, kubeconfigErr := RESTConfig(ctx, c, cluster); kubeconfigErr != nil {
+					if _, ok := AsKubeconfigError(kubeconfigErr); ok {
+						return nil, kubeconfigErr
+					}
+				}
+			}
+			return nil, errors.Wrapf(err, "failed to get client configuration for Cluster %q in namespace %q",
+				cluster.Name, cluster.Namespace)
+		}
+		return corev1.NewForConfig(restConfig)
+	}
+}
@@ -16,11 +16,14 @@ package remote
 import (
 	"context"
 
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/secret"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -103,12 +106,51 @@ users:
 			_, err := NewClusterClient(context.TODO(), client, clusterWithNoKubeConfig)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("not found"))
+			kubeconfigErr, ok := AsKubeconfigError(err)
+			Expect(ok).To(BeTrue())
+			Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigNotFoundReason))
 		})
 		It("should error with some other error with invalid kubeconfig", func() {
 			client := fake.NewClientBuilder().WithRuntimeObjects(invalidSecret).Build()
 			_, err := NewClusterClient(context.TODO(), client, clusterWithInvalidKubeConfig)
 			Expect(err).To(HaveOccurred())
 			Expect(apierrors.IsNotFound(err)).To(BeFalse())
+			kubeconfigErr, ok := AsKubeconfigError(err)
+			Expect(ok).To(BeTrue())
+			Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigInvalidReason))
+		})
+		It("should error with invalid kubeconfig when the secret misses its value", func() {
+			invalidSecret.Data = map[string][]byte{"foo": []byte(validKubeConfig)}
+			client := fake.NewClientBuilder().WithRuntimeObjects(invalidSecret).Build()
+			_, err := NewClusterClient(context.TODO(), client, clusterWithInvalidKubeConfig)
+			Expect(err).To(HaveOccurred())
+			kubeconfigErr, ok := AsKubeconfigError(err)
+			Expect(ok).To(BeTrue())
+			Expect(kubeconfigErr.Reason).To(Equal(infrav1.KubeconfigInvalidReason))
 		})
 	})
+
+	DescribeTable("AsKubeconfigError",
+		func(err error, expectedReason string) {
+			kubeconfigErr, ok := AsKubeconfigError(err)
+			if expectedReason == "" {
+				Expect(ok).To(BeFalse())
+				return
+			}
+			Expect(ok).To(BeTrue())
+			Expect(kubeconfigErr.Reason).To(Equal(expectedReason))
+		},
+		Entry("No error", nil, ""),
+		Entry("Other error", errors.New("connection refused"), ""),
+		Entry("Other API error", apierrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, "node"), ""),
+		Entry("Kubeconfig error",
+			errors.Wrap(&KubeconfigError{Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("not found")}, "failed"),
+			infrav1.KubeconfigNotFoundReason,
+		),
+		Entry("Unauthorized", apierrors.NewUnauthorized("invalid credentials"), infrav1.KubeconfigUnauthorizedReason),
+		Entry("Wrapped unauthorized",
+			errors.Wrap(apierrors.NewUnauthorized("invalid credentials"), "error while retrieving nodes"),
+			infrav1.KubeconfigUnauthorizedReason,
+		),
+	)
 })
@@ -18,6 +18,8 @@ package baremetal
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"strings"
 	"time"
 
@@ -31,6 +33,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -61,6 +64,13 @@ func Contains(list []string, strToSearch string) bool {
 	return false
 }
 
+// LogDuration logs at V(4) the duration of a reconcile phase started at
+// start. It is meant to be deferred when the phase starts, for example
+// defer LogDuration(log, "host selection", time.Now()).
+func LogDuration(log logr.Logger, phase string, start time.Time) {
+	log.V(4).Info("Phase completed", "phase", phase, "duration", time.Since(start).String())
+}
+
 // NotFoundError represents that an object was not found.
 type NotFoundError struct {
 }
@@ -118,26 +128,34 @@ func deleteObject(ctx context.Context, cl client.Client, obj client.Object, opts
 	return err
 }
 
+// inheritWatchLabel returns the labels with the watch-filter label of the first
+// source carrying one, unless the labels already have it, so that objects
+// created by CAPM3 are reconciled by the same instance as their owner. The
+// labels given are not modified.
+func inheritWatchLabel(labels map[string]string, sources ...map[string]string) map[string]string {
+	if _, ok := labels[clusterv1.WatchLabel]; ok {
+		return labels
+	}
+	for _, source := range sources {
+		value, ok := source[clusterv1.WatchLabel]
+		if !ok {
+			continue
+		}
+		newLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			newLabels[k] = v
+		}
+		newLabels[clusterv1.WatchLabel] = value
+		return newLabels
+	}
+	return labels
+}
+
 func createSecret(ctx context.Context, cl client.Client, name string,
-	namespace string, clusterName string,
+	namespace string, labels map[string]string,
 	ownerRefs []metav1.OwnerReference, content map[string][]byte,
 ) error {
-	bootstrapSecret := &corev1.Secret{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Secret",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Labels: map[string]string{
-				clusterv1.ClusterNameLabel: clusterName,
-			},
-			OwnerReferences: ownerRefs,
-		},
-		Data: content,
-		Type: metal3SecretType,
-	}
+	bootstrapSecret := newMetal3Secret(name, namespace, labels, ownerRefs, content)
 
 	secret, err := checkSecretExists(ctx, cl, name, namespace)
 	if err == nil {
@@ -153,6 +171,45 @@ func createSecret(ctx context.Context, cl client.Client, name string,
 	return err
 }
 
+// newMetal3Secret returns a secret of the metal3SecretType with the given content.
+func newMetal3Secret(name string, namespace string, labels map[string]string,
+	ownerRefs []metav1.OwnerReference, content map[string][]byte,
+) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			Labels:          labels,
+			OwnerReferences: ownerRefs,
+		},
+		Data: content,
+		Type: metal3SecretType,
+	}
+}
+
+// dataSecretName returns the name of a secret rendered for a machine of a
+// cluster, ending with the given suffix. The name contains the cluster name
+// and a hash of the cluster and machine names, so that the secrets of machines
+// with the same name in different clusters do not collide, even once the name
+// is truncated to the maximum length.
+func dataSecretName(clusterName, machineName, suffix string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(clusterName + "/" + machineName))
+	end := fmt.Sprintf("-%08x-%s", hash.Sum32(), suffix)
+	name := machineName
+	if clusterName != "" {
+		name = clusterName + "-" + machineName
+	}
+	if len(name)+len(end) > validation.DNS1123SubdomainMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-len(end)], "-.")
+	}
+	return name + end
+}
+
 func checkSecretExists(ctx context.Context, cl client.Client, name string,
 	namespace string,
 ) (corev1.Secret, error) {
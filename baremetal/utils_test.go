@@ -20,6 +20,8 @@ import (
 	"context"
 
 	"fmt"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -31,8 +33,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/patch"
 )
@@ -104,6 +108,73 @@ var _ = Describe("Metal3 manager utils", func() {
 		}),
 	)
 
+	type testCaseInheritWatchLabel struct {
+		Labels         map[string]string
+		Sources        []map[string]string
+		ExpectedOutput map[string]string
+	}
+
+	DescribeTable("Test inheritWatchLabel",
+		func(tc testCaseInheritWatchLabel) {
+			var original map[string]string
+			if tc.Labels != nil {
+				original = map[string]string{}
+				for k, v := range tc.Labels {
+					original[k] = v
+				}
+			}
+			Expect(inheritWatchLabel(tc.Labels, tc.Sources...)).To(Equal(tc.ExpectedOutput))
+			Expect(tc.Labels).To(Equal(original))
+		},
+		Entry("No sources", testCaseInheritWatchLabel{
+			Labels:         map[string]string{"foo": "bar"},
+			ExpectedOutput: map[string]string{"foo": "bar"},
+		}),
+		Entry("Sources without label", testCaseInheritWatchLabel{
+			Sources:        []map[string]string{nil, {"foo": "bar"}},
+			ExpectedOutput: nil,
+		}),
+		Entry("Inherited from first source with label", testCaseInheritWatchLabel{
+			Labels: map[string]string{"foo": "bar"},
+			Sources: []map[string]string{
+				nil,
+				{clusterv1.WatchLabel: "shard-a"},
+				{clusterv1.WatchLabel: "shard-b"},
+			},
+			ExpectedOutput: map[string]string{"foo": "bar", clusterv1.WatchLabel: "shard-a"},
+		}),
+		Entry("Nil labels", testCaseInheritWatchLabel{
+			Sources:        []map[string]string{{clusterv1.WatchLabel: "shard-a"}},
+			ExpectedOutput: map[string]string{clusterv1.WatchLabel: "shard-a"},
+		}),
+		Entry("Already labeled", testCaseInheritWatchLabel{
+			Labels:         map[string]string{clusterv1.WatchLabel: "shard-b"},
+			Sources:        []map[string]string{{clusterv1.WatchLabel: "shard-a"}},
+			ExpectedOutput: map[string]string{clusterv1.WatchLabel: "shard-b"},
+		}),
+	)
+
+	DescribeTable("Test LogDuration",
+		func(verbosity int, expectLog bool) {
+			lines := []string{}
+			log := funcr.New(func(prefix, args string) {
+				lines = append(lines, args)
+			}, funcr.Options{Verbosity: verbosity})
+
+			LogDuration(log, "host selection", time.Now().Add(-time.Second))
+
+			if !expectLog {
+				Expect(lines).To(BeEmpty())
+				return
+			}
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(ContainSubstring(`"phase"="host selection"`))
+			Expect(lines[0]).To(MatchRegexp(`"duration"="1\.[0-9]+s"`))
+		},
+		Entry("Logged at V(4)", 4, true),
+		Entry("Not logged below V(4)", 3, false),
+	)
+
 	Describe("NotFoundError", func() {
 		It("should return proper message", func() {
 			err := &NotFoundError{}
@@ -363,6 +434,24 @@ var _ = Describe("Metal3 manager utils", func() {
 		Entry("Object exists", true),
 	)
 
+	It("Generates distinct data secret names per cluster", func() {
+		nameA := dataSecretName("cluster-a", "machine", "metadata")
+		nameB := dataSecretName("cluster-b", "machine", "metadata")
+		Expect(nameA).To(HavePrefix("cluster-a-machine-"))
+		Expect(nameA).To(HaveSuffix("-metadata"))
+		Expect(nameA).NotTo(Equal(nameB))
+		Expect(dataSecretName("cluster-a", "machine", "metadata")).To(Equal(nameA))
+
+		// The names stay distinct once truncated to the maximum length.
+		longName := strings.Repeat("m", 300)
+		longA := dataSecretName("cluster-a", longName, "networkdata")
+		longB := dataSecretName("cluster-b", longName, "networkdata")
+		Expect(longA).To(HaveLen(validation.DNS1123SubdomainMaxLength))
+		Expect(longA).To(HaveSuffix("-networkdata"))
+		Expect(longA).NotTo(Equal(longB))
+		Expect(validation.IsDNS1123Subdomain(longA)).To(BeEmpty())
+	})
+
 	DescribeTable("Test createSecret",
 		func(secretExists bool) {
 			if secretExists {
@@ -395,7 +484,8 @@ var _ = Describe("Metal3 manager utils", func() {
 			content := map[string][]byte{
 				"abc": []byte("def"),
 			}
-			err := createSecret(context.TODO(), k8sClient, "abc", namespaceName, "ghi",
+			err := createSecret(context.TODO(), k8sClient, "abc", namespaceName,
+				map[string]string{clusterv1.ClusterNameLabel: "ghi"},
 				ownerRef, content,
 			)
 			Expect(err).NotTo(HaveOccurred())
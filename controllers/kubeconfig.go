@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// setKubeconfigCondition sets the WorkloadClusterKubeconfigAvailableCondition
+// of obj to false with the reason of the KubeconfigError wrapped in err, and
+// returns true. The condition is set to true if err is not caused by the
+// kubeconfig of the workload cluster.
+func setKubeconfigCondition(obj conditions.Setter, err error) bool {
+	kubeconfigErr, ok := infraremote.AsKubeconfigError(err)
+	if !ok {
+		conditions.MarkTrue(obj, infrav1.WorkloadClusterKubeconfigAvailableCondition)
+		return false
+	}
+	conditions.MarkFalse(obj, infrav1.WorkloadClusterKubeconfigAvailableCondition, kubeconfigErr.Reason,
+		clusterv1.ConditionSeverityWarning, "%s", kubeconfigErr.Err.Error())
+	return true
+}
+
+// kubeconfigCondition records the use of the kubeconfig of the workload
+// cluster through a ClientGetter during a reconciliation, so that the
+// WorkloadClusterKubeconfigAvailableCondition of obj is only updated when
+// the kubeconfig was used.
+type kubeconfigCondition struct {
+	obj    conditions.Setter
+	getter baremetal.ClientGetter
+	used   bool
+	err    error
+}
+
+// clientGetter is the ClientGetter recording the errors of the wrapped one.
+func (k *kubeconfigCondition) clientGetter(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+	clientset, err := k.getter(ctx, c, cluster)
+	k.used, k.err = true, err
+	return clientset, err
+}
+
+// update updates the condition from err, or from the error of the
+// ClientGetter if err does not come from the kubeconfig, and returns true if
+// the kubeconfig is unavailable.
+func (k *kubeconfigCondition) update(err error) bool {
+	if !k.used {
+		return false
+	}
+	if _, ok := infraremote.AsKubeconfigError(err); !ok {
+		err = k.err
+	}
+	return setKubeconfigCondition(k.obj, err)
+}
+
+// kubeconfigSecretCluster returns the Cluster of a kubeconfig secret, named
+// after the Cluster with the kubeconfig suffix.
+func kubeconfigSecretCluster(o client.Object) (*clusterv1.Cluster, bool) {
+	if _, ok := o.(*corev1.Secret); !ok {
+		return nil, false
+	}
+	clusterName, purpose, err := secret.ParseSecretName(o.GetName())
+	if err != nil || purpose != secret.Kubeconfig {
+		return nil, false
+	}
+	return &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterName,
+			Namespace: o.GetNamespace(),
+		},
+	}, true
+}
+
+// watchedKubeconfigSecretCluster returns the Cluster of a kubeconfig secret if
+// it is reconciled by this instance. The kubeconfig secrets created by Cluster
+// API do not carry the watch-filter label, the label and the shard of their
+// Cluster are checked instead.
+func watchedKubeconfigSecretCluster(ctx context.Context, c client.Client, log logr.Logger, o client.Object, labelValue string, shard ShardOptions) (*clusterv1.Cluster, bool) {
+	key, ok := kubeconfigSecretCluster(o)
+	if !ok {
+		return nil, false
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(key), cluster); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get the Cluster of the kubeconfig secret", "cluster", key.Name)
+		}
+		return nil, false
+	}
+	if !processIfLabelMatchOrShard(log, cluster, labelValue, shard) {
+		return nil, false
+	}
+	return cluster, true
+}
+
+// kubeconfigSecretPredicate only lets the events of the kubeconfig secrets
+// through, labeled by Cluster API with the name of their Cluster, so that the
+// other Secrets are not mapped to the reconciled objects.
+func kubeconfigSecretPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		cluster, ok := kubeconfigSecretCluster(o)
+		return ok && o.GetLabels()[clusterv1.ClusterNameLabel] == cluster.Name
+	})
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Workload cluster kubeconfig", func() {
+	notFound := &infraremote.KubeconfigError{
+		Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("secret not found"),
+	}
+
+	type testCaseKubeconfigSecretToObjects struct {
+		Secret         client.Object
+		WatchFilter    string
+		ExpectRequests bool
+		// ExpectFiltered is set when the Cluster belongs to another
+		// watch-filter instance, the BareMetalHosts are still mapped and
+		// filtered by the labelsync Reconcile.
+		ExpectFiltered bool
+	}
+
+	DescribeTable("Kubeconfig secret to objects tests",
+		func(tc testCaseKubeconfigSecretToObjects) {
+			cluster := newCluster(clusterName, nil, nil)
+			cluster.Labels = map[string]string{clusterv1.WatchLabel: "instance-a"}
+			objects := []client.Object{
+				cluster,
+				newMachine(clusterName, machineName, metal3machineName, ""),
+				newMetal3Machine(metal3machineName, m3mObjectMeta(), nil, nil, false),
+				&infrav1.Metal3Remediation{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      machineName,
+						Namespace: namespaceName,
+						Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+					},
+				},
+				&infrav1.Metal3Remediation{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "other",
+						Namespace: namespaceName,
+						Labels:    map[string]string{clusterv1.ClusterNameLabel: "other"},
+					},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+
+			machineReconciler := Metal3MachineReconciler{Client: fakeClient, Log: logr.Discard(), WatchFilterValue: tc.WatchFilter}
+			labelSyncReconciler := Metal3LabelSyncReconciler{Client: fakeClient, Log: logr.Discard(), WatchFilterValue: tc.WatchFilter}
+			remediationReconciler := Metal3RemediationReconciler{Client: fakeClient, Log: logr.Discard(), WatchFilterValue: tc.WatchFilter}
+			machineReqs := machineReconciler.KubeconfigSecretToMetal3Machines(context.Background(), tc.Secret)
+			hostReqs := labelSyncReconciler.KubeconfigSecretToBareMetalHosts(context.Background(), tc.Secret)
+			remediationReqs := remediationReconciler.KubeconfigSecretToMetal3Remediations(context.Background(), tc.Secret)
+			if !tc.ExpectRequests {
+				Expect(machineReqs).To(BeEmpty())
+				Expect(hostReqs).To(BeEmpty())
+				Expect(remediationReqs).To(BeEmpty())
+				return
+			}
+			Expect(hostReqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+				Name:      baremetalhostName,
+				Namespace: namespaceName,
+			}}}))
+			if tc.ExpectFiltered {
+				Expect(machineReqs).To(BeEmpty())
+				Expect(remediationReqs).To(BeEmpty())
+				return
+			}
+			Expect(machineReqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+			}}}))
+			Expect(remediationReqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+				Name:      machineName,
+				Namespace: namespaceName,
+			}}}))
+		},
+		Entry("Kubeconfig secret of the cluster", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-kubeconfig",
+				Namespace: namespaceName,
+			}},
+			ExpectRequests: true,
+		}),
+		Entry("Kubeconfig secret of a cluster of this instance", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-kubeconfig",
+				Namespace: namespaceName,
+			}},
+			WatchFilter:    "instance-a",
+			ExpectRequests: true,
+		}),
+		Entry("Kubeconfig secret of a cluster of another instance", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-kubeconfig",
+				Namespace: namespaceName,
+			}},
+			WatchFilter:    "instance-b",
+			ExpectRequests: true,
+			ExpectFiltered: true,
+		}),
+		Entry("Other secret of the cluster", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-ca",
+				Namespace: namespaceName,
+			}},
+		}),
+		Entry("Kubeconfig secret of another namespace", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-kubeconfig",
+				Namespace: "other",
+			}},
+		}),
+		Entry("Not a secret", testCaseKubeconfigSecretToObjects{
+			Secret: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterName + "-kubeconfig",
+				Namespace: namespaceName,
+			}},
+		}),
+	)
+
+	DescribeTable("Kubeconfig secret predicate tests",
+		func(name string, labels map[string]string, expected bool) {
+			obj := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespaceName,
+				Labels:    labels,
+			}}
+			Expect(kubeconfigSecretPredicate().Generic(event.GenericEvent{Object: obj})).To(Equal(expected))
+		},
+		Entry("Kubeconfig secret of the cluster", clusterName+"-kubeconfig",
+			map[string]string{clusterv1.ClusterNameLabel: clusterName}, true),
+		Entry("Kubeconfig secret without the cluster name label", clusterName+"-kubeconfig", nil, false),
+		Entry("Kubeconfig secret labeled with another cluster", clusterName+"-kubeconfig",
+			map[string]string{clusterv1.ClusterNameLabel: "other"}, false),
+		Entry("Other secret of the cluster", clusterName+"-ca",
+			map[string]string{clusterv1.ClusterNameLabel: clusterName}, false),
+		Entry("Unrelated secret", "bmc-credentials", nil, false),
+	)
+
+	type testCaseKubeconfigCondition struct {
+		Existing          bool
+		Used              bool
+		GetterError       error
+		Error             error
+		ExpectUnavailable bool
+	}
+
+	DescribeTable("Kubeconfig condition tests",
+		func(tc testCaseKubeconfigCondition) {
+			m3m := &infrav1.Metal3Machine{}
+			if tc.Existing {
+				setKubeconfigCondition(m3m, notFound)
+			}
+			kubeconfig := &kubeconfigCondition{
+				obj: m3m,
+				getter: func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+					return nil, tc.GetterError
+				},
+			}
+			if tc.Used {
+				_, _ = kubeconfig.clientGetter(context.Background(), nil, nil)
+			}
+			Expect(kubeconfig.update(tc.Error)).To(Equal(tc.ExpectUnavailable && tc.Used))
+			Expect(conditions.IsFalse(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(Equal(tc.ExpectUnavailable))
+			Expect(conditions.IsTrue(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(Equal(tc.Used && !tc.ExpectUnavailable))
+		},
+		Entry("Not used, condition kept", testCaseKubeconfigCondition{
+			Existing:          true,
+			ExpectUnavailable: true,
+		}),
+		Entry("Used, condition set to true", testCaseKubeconfigCondition{
+			Existing: true,
+			Used:     true,
+		}),
+		Entry("Used, other error, condition set to true", testCaseKubeconfigCondition{
+			Existing: true,
+			Used:     true,
+			Error:    errors.New("connection refused"),
+		}),
+		Entry("Getter error swallowed", testCaseKubeconfigCondition{
+			Used:              true,
+			GetterError:       notFound,
+			ExpectUnavailable: true,
+		}),
+		Entry("Error returned", testCaseKubeconfigCondition{
+			Used:              true,
+			Error:             errors.Wrap(notFound, "failed"),
+			ExpectUnavailable: true,
+		}),
+	)
+})
@@ -24,6 +24,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -57,16 +58,23 @@ type Metal3ClusterReconciler struct {
 	ManagerFactory   baremetal.ManagerFactoryInterface
 	Log              logr.Logger
 	WatchFilterValue string
+	Shard            ShardOptions
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3clusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3clusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinedeployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 
 // Reconcile reads that state of the cluster for a Metal3Cluster object and makes changes based on the state read
 // and what is in the Metal3Cluster.Spec.
 func (r *Metal3ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	clusterLog := log.Log.WithName(clusterControllerName).WithValues("metal3-cluster", req.NamespacedName)
+	defer baremetal.LogDuration(clusterLog, "reconcile", time.Now())
 
 	// Fetch the Metal3Cluster instance
 	metal3Cluster := &infrav1.Metal3Cluster{}
@@ -79,6 +87,13 @@ func (r *Metal3ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(metal3Cluster, r.WatchFilterValue, r.Shard) {
+		clusterLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
 	patchHelper, err := patch.NewHelper(metal3Cluster, r.Client)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
@@ -90,6 +105,11 @@ func (r *Metal3ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 	}()
 
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(metal3Cluster, r.WatchFilterValue, r.Shard) {
+		clusterLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
 	// Fetch the Cluster.
 	cluster, err := util.GetOwnerCluster(ctx, r.Client, metal3Cluster.ObjectMeta)
 	if err != nil {
@@ -106,14 +126,6 @@ func (r *Metal3ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	clusterLog = clusterLog.WithValues("cluster", cluster.Name)
 
-	// Return early if BMCluster or Cluster is paused.
-	if annotations.IsPaused(cluster, metal3Cluster) {
-		clusterLog.Info("reconciliation is paused for this object")
-		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
-	}
-
-	clusterLog.Info("Reconciling metal3Cluster")
-
 	// Create a helper for managing a Metal3 cluster.
 	clusterMgr, err := r.ManagerFactory.NewClusterManager(cluster, metal3Cluster, clusterLog)
 	if err != nil {
@@ -123,6 +135,29 @@ func (r *Metal3ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, nil
 	}
 
+	// Return early if BMCluster or Cluster is paused. The cluster-level
+	// pause is propagated to the BareMetalHosts of the cluster, e.g. for
+	// clusterctl move, unlike the CAPM3 annotation of a single object.
+	clusterPaused := annotations.IsPaused(cluster, metal3Cluster)
+	isPaused := clusterPaused || hasPausedAnnotation(metal3Cluster)
+	setPausedCondition(metal3Cluster, isPaused)
+	if isPaused {
+		clusterLog.Info("reconciliation is paused for this object")
+		if clusterPaused {
+			if err := clusterMgr.SetPauseAnnotations(ctx); err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "failed to pause the BareMetalHosts of the cluster")
+			}
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+	}
+
+	clusterLog.Info("Reconciling metal3Cluster")
+
+	// Resume the BareMetalHosts paused with the cluster.
+	if err := clusterMgr.RemovePauseAnnotations(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to resume the BareMetalHosts of the cluster")
+	}
+
 	// Handle deleted clusters
 	if !metal3Cluster.DeletionTimestamp.IsZero() {
 		return reconcileDelete(ctx, clusterMgr)
@@ -145,6 +180,7 @@ func patchMetal3Cluster(ctx context.Context, patchHelper *patch.Helper, metal3Cl
 		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
 			clusterv1.ReadyCondition,
 			infrav1.BaremetalInfrastructureReadyCondition,
+			infrav1.PausedCondition,
 		}},
 		patch.WithStatusObservedGeneration{},
 	)
@@ -160,12 +196,32 @@ func reconcileNormal(ctx context.Context, clusterMgr baremetal.ClusterManagerInt
 		return ctrl.Result{}, err
 	}
 
+	// Allocate the ControlPlaneEndpoint from the pool, if any, before the
+	// cluster infrastructure is marked ready.
+	if err := clusterMgr.AllocateControlPlaneEndpoint(ctx); err != nil {
+		var reconcileError baremetal.ReconcileError
+		if errors.As(err, &reconcileError) && reconcileError.IsTransient() {
+			return ctrl.Result{Requeue: true, RequeueAfter: reconcileError.GetRequeueAfter()}, nil
+		}
+		return ctrl.Result{}, errors.Wrap(err, "failed to allocate the control plane endpoint")
+	}
+
 	// Set APIEndpoints so the Cluster API Cluster Controller can pull it
 	if err := clusterMgr.UpdateClusterStatus(); err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to get ip for the API endpoint")
 	}
 
-	return ctrl.Result{}, nil
+	if err := clusterMgr.UpdateMachinesStatus(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Come back when the next node reuse label may expire.
+	expiresIn, err := clusterMgr.ExpireNodeReuseLabels(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to expire the node reuse labels")
+	}
+
+	return ctrl.Result{RequeueAfter: expiresIn}, nil
 }
 
 func reconcileDelete(ctx context.Context,
@@ -181,7 +237,7 @@ func reconcileDelete(ctx context.Context,
 		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
 	}
 
-	if err := clusterMgr.Delete(); err != nil {
+	if err := clusterMgr.Delete(ctx); err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to delete Metal3Cluster")
 	}
 
@@ -194,6 +250,13 @@ func reconcileDelete(ctx context.Context,
 // SetupWithManager will add watches for this controller.
 func (r *Metal3ClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
 	clusterToInfraFn := util.ClusterToInfrastructureMapFunc(ctx, infrav1.GroupVersion.WithKind("Metal3Cluster"), mgr.GetClient(), &infrav1.Metal3Cluster{})
+	// The filters are set on each watch rather than with WithEventFilter, the
+	// BareMetalHosts do not carry the watch-filter label.
+	filters := []predicate.Predicate{
+		predicates.ResourceIsNotExternallyManaged(mgr.GetLogger()),
+		ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard),
+		ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx)),
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(
 			&infrav1.Metal3Cluster{},
@@ -212,6 +275,7 @@ func (r *Metal3ClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl
 					},
 				},
 			),
+			builder.WithPredicates(filters...),
 		).
 		WithOptions(options).
 		// Watches can be defined with predicates in the builder directly now, no need to do `Build()` and then add the watch to the returned controller: https://github.com/kubernetes-sigs/cluster-api/blob/b00bd08d02311919645a4868861d0f9ca0df35ea/util/predicates/cluster_predicates.go#L147-L164
@@ -235,10 +299,106 @@ func (r *Metal3ClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl
 				}
 				return requests
 			}),
-			// predicates.ClusterUnpaused will handle cluster unpaused logic
-			builder.WithPredicates(predicates.ClusterUnpaused(ctrl.LoggerFrom(ctx))),
+			// The pause and resume of the Cluster are propagated to its
+			// BareMetalHosts.
+			builder.WithPredicates(clusterPausedChanged(ctrl.LoggerFrom(ctx))),
+			builder.WithPredicates(filters...),
+		).
+		// The status counts the Metal3Machines of the cluster.
+		Watches(
+			&infrav1.Metal3Machine{},
+			handler.EnqueueRequestsFromMapFunc(r.Metal3MachineToMetal3Cluster),
+			builder.WithPredicates(filters...),
+		).
+		// The status reports the failure domains of the BareMetalHosts. Any
+		// host may be in the failure domains of the Metal3Clusters of this
+		// instance, BareMetalHostToMetal3Clusters filters the Metal3Clusters
+		// on the watch-filter label and the shard instead.
+		Watches(
+			&bmov1alpha1.BareMetalHost{},
+			handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3Clusters),
+			builder.WithPredicates(bareMetalHostLabelsChanged(), predicates.ResourceNotPaused(ctrl.LoggerFrom(ctx))),
 		).
-		WithEventFilter(predicates.ResourceIsNotExternallyManaged(mgr.GetLogger())).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
 		Complete(r)
 }
+
+// Metal3MachineToMetal3Cluster is a handler.ToRequestsFunc to be used to
+// enqueue a request for reconciliation of the Metal3Cluster of the Cluster of
+// a Metal3Machine.
+func (r *Metal3ClusterReconciler) Metal3MachineToMetal3Cluster(ctx context.Context, o client.Object) []ctrl.Request {
+	clusterName, ok := o.GetLabels()[clusterv1.ClusterNameLabel]
+	if !ok {
+		return nil
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: o.GetNamespace(), Name: clusterName}, cluster); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to get the Cluster of the Metal3Machine")
+		}
+		return nil
+	}
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil || ref.Kind != "Metal3Cluster" {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: cluster.Namespace, Name: ref.Name}}}
+}
+
+// BareMetalHostToMetal3Clusters is a handler.ToRequestsFunc to be used to
+// enqueue requests for reconciliation of the Metal3Clusters whose failure
+// domains may include the BareMetalHost: the Metal3Clusters of its namespace,
+// or all of them for a host in a namespace allowed with --bmh-namespaces.
+// Only the Metal3Clusters with the watch-filter label, or of the shard, are
+// enqueued.
+func (r *Metal3ClusterReconciler) BareMetalHostToMetal3Clusters(ctx context.Context, o client.Object) []ctrl.Request {
+	opts := []client.ListOption{}
+	if !baremetal.Contains(baremetal.BMHNamespaces, o.GetNamespace()) {
+		opts = append(opts, client.InNamespace(o.GetNamespace()))
+	}
+	m3cs := &infrav1.Metal3ClusterList{}
+	if err := r.Client.List(ctx, m3cs, opts...); err != nil {
+		r.Log.Error(err, "failed to list Metal3Clusters")
+		return nil
+	}
+	requests := []ctrl.Request{}
+	for i := range m3cs.Items {
+		if !processIfLabelMatchOrShard(r.Log, &m3cs.Items[i], r.WatchFilterValue, r.Shard) {
+			continue
+		}
+		requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&m3cs.Items[i])})
+	}
+	return requests
+}
+
+// bareMetalHostLabelsChanged returns a predicate that filters out the
+// BareMetalHost updates that do not change its labels, which give its failure
+// domain. Other events are not filtered.
+func bareMetalHostLabelsChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+	}
+}
+
+// clusterPausedChanged returns a predicate that accepts the Clusters created
+// unpaused and the Cluster updates that pause or resume the Cluster, unlike
+// predicates.ClusterUnpaused that filters out the pause.
+func clusterPausedChanged(logger logr.Logger) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: predicates.ClusterCreateNotPaused(logger).CreateFunc,
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCluster, ok := e.ObjectOld.(*clusterv1.Cluster)
+			if !ok {
+				return false
+			}
+			newCluster, ok := e.ObjectNew.(*clusterv1.Cluster)
+			if !ok {
+				return false
+			}
+			return oldCluster.Spec.Paused != newCluster.Spec.Paused
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
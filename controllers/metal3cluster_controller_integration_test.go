@@ -20,6 +20,7 @@ import (
 
 	"github.com/go-logr/logr"
 
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
@@ -34,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -47,6 +49,10 @@ var _ = Describe("Reconcile metal3Cluster", func() {
 		ErrorReasonExpected bool
 		ErrorReason         capierrors.ClusterStatusError
 		ConditionsExpected  clusterv1.Conditions
+		ConditionsAbsent    []clusterv1.ConditionType
+		CheckHostPaused     bool
+		HostPausedExpected  bool
+		HostPauseValue      string
 	}
 
 	DescribeTable("Reconcile tests metal3Cluster",
@@ -98,6 +104,16 @@ var _ = Describe("Reconcile metal3Cluster", func() {
 					Expect(condGot.Reason).To(Equal(condExp.Reason))
 				}
 			}
+			for _, condType := range tc.ConditionsAbsent {
+				Expect(conditions.Has(testclstr, condType)).To(BeFalse())
+			}
+			if tc.CheckHostPaused {
+				host := &bmov1alpha1.BareMetalHost{}
+				Expect(fakeClient.Get(ctx, *getKey(baremetalhostName), host)).To(Succeed())
+				value, ok := host.Annotations[bmov1alpha1.PausedAnnotation]
+				Expect(ok).To(Equal(tc.HostPausedExpected))
+				Expect(value).To(Equal(tc.HostPauseValue))
+			}
 		},
 		// Given cluster, but no metal3cluster resource
 		Entry("Should not return an error when metal3cluster is not found",
@@ -182,6 +198,59 @@ var _ = Describe("Reconcile metal3Cluster", func() {
 			},
 		),
 
+		// Given: Cluster, Metal3Cluster, Metal3Machine, BareMetalHost.
+		// Cluster.Spec.Paused=true
+		// Expected: Requeue Expected, BareMetalHost paused
+		Entry("Should pause the BareMetalHosts of the cluster when owner Cluster is paused",
+			TestCaseReconcileBMC{
+				Objects: []client.Object{
+					newCluster(clusterName, clusterPauseSpec(), nil),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, nil, false),
+					clusterMetal3Machine(),
+					newBareMetalHost(baremetalhostName, nil, nil, nil, false),
+				},
+				ErrorExpected:      false,
+				RequeueExpected:    true,
+				CheckHostPaused:    true,
+				HostPausedExpected: true,
+				HostPauseValue:     baremetal.PausedAnnotationKey,
+			},
+		),
+		// Given: Cluster, Metal3Cluster, Metal3Machine, BareMetalHost paused
+		// by CAPM3.
+		// Expected: BareMetalHost resumed
+		Entry("Should resume the BareMetalHosts of the cluster when owner Cluster is resumed",
+			TestCaseReconcileBMC{
+				Objects: []client.Object{
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, nil, false),
+					clusterMetal3Machine(),
+					capm3PausedBareMetalHost(),
+				},
+				ErrorExpected:      false,
+				RequeueExpected:    false,
+				CheckHostPaused:    true,
+				HostPausedExpected: false,
+			},
+		),
+		// Given: Cluster, Metal3Cluster, Metal3Machine, BareMetalHost paused
+		// by the user.
+		// Expected: BareMetalHost still paused
+		Entry("Should not resume the BareMetalHosts paused by the user",
+			TestCaseReconcileBMC{
+				Objects: []client.Object{
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, nil, false),
+					clusterMetal3Machine(),
+					newBareMetalHost(baremetalhostName, nil, nil, nil, true),
+				},
+				ErrorExpected:      false,
+				RequeueExpected:    false,
+				CheckHostPaused:    true,
+				HostPausedExpected: true,
+			},
+		),
+
 		//Given: Cluster, Metal3Cluster.
 		// Metal3Cluster has cluster.x-k8s.io/paused annotation
 		//Expected: Requeue Expected
@@ -195,6 +264,44 @@ var _ = Describe("Reconcile metal3Cluster", func() {
 				RequeueExpected: true,
 			},
 		),
+		//Given: Cluster, Metal3Cluster.
+		// Metal3Cluster has capm3.metal3.io/paused annotation
+		//Expected: Requeue Expected, Paused condition set
+		Entry("Should requeue when Metal3Cluster has CAPM3 paused annotation",
+			TestCaseReconcileBMC{
+				Objects: []client.Object{
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil,
+						map[string]string{infrav1.PausedAnnotation: ""}, false),
+				},
+				ErrorExpected:   false,
+				RequeueExpected: true,
+				ConditionsExpected: clusterv1.Conditions{
+					clusterv1.Condition{
+						Type:   infrav1.PausedCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		),
+		//Given: Cluster, Metal3Cluster with Paused condition, no paused annotation.
+		//Expected: Paused condition removed
+		Entry("Should remove the Paused condition when CAPM3 paused annotation is removed",
+			TestCaseReconcileBMC{
+				Objects: []client.Object{
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(),
+						&infrav1.Metal3ClusterStatus{
+							Conditions: clusterv1.Conditions{
+								*conditions.TrueCondition(infrav1.PausedCondition),
+							},
+						}, nil, false),
+				},
+				ErrorExpected:    false,
+				RequeueExpected:  false,
+				ConditionsAbsent: []clusterv1.ConditionType{infrav1.PausedCondition},
+			},
+		),
 		// Reconcile Deletion
 		Entry("Should reconcileDelete when deletion timestamp is set.",
 			TestCaseReconcileBMC{
@@ -244,4 +351,42 @@ var _ = Describe("Reconcile metal3Cluster", func() {
 		),
 	)
 
+	DescribeTable("Cluster paused changed tests",
+		func(oldPaused, newPaused bool, expected bool) {
+			oldCluster := newCluster(clusterName, &clusterv1.ClusterSpec{Paused: oldPaused}, nil)
+			newCluster := newCluster(clusterName, &clusterv1.ClusterSpec{Paused: newPaused}, nil)
+			Expect(clusterPausedChanged(logr.Discard()).Update(event.UpdateEvent{
+				ObjectOld: oldCluster, ObjectNew: newCluster,
+			})).To(Equal(expected))
+		},
+		Entry("Cluster paused", false, true, true),
+		Entry("Cluster resumed", true, false, true),
+		Entry("Cluster still paused", true, true, false),
+		Entry("Cluster still running", false, false, false),
+	)
 })
+
+// clusterMetal3Machine returns a Metal3Machine of the cluster consuming the
+// BareMetalHost.
+func clusterMetal3Machine() *infrav1.Metal3Machine {
+	return newMetal3Machine(metal3machineName, &metav1.ObjectMeta{
+		Namespace: namespaceName,
+		Labels: map[string]string{
+			clusterv1.ClusterNameLabel: clusterName,
+		},
+		Annotations: map[string]string{
+			baremetal.HostAnnotation: namespaceName + "/" + baremetalhostName,
+		},
+	}, nil, nil, false)
+}
+
+// capm3PausedBareMetalHost returns a BareMetalHost of the cluster paused by
+// CAPM3.
+func capm3PausedBareMetalHost() *bmov1alpha1.BareMetalHost {
+	host := newBareMetalHost(baremetalhostName, nil, nil,
+		map[string]string{clusterv1.ClusterNameLabel: clusterName}, false)
+	host.Annotations = map[string]string{
+		bmov1alpha1.PausedAnnotation: baremetal.PausedAnnotationKey,
+	}
+	return host
+}
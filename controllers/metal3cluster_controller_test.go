@@ -16,22 +16,40 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("Metal3Cluster controller", func() {
 
 	type testCaseClusterNormal struct {
-		CreateError   bool
-		UpdateError   bool
-		ExpectError   bool
-		ExpectRequeue bool
+		CreateError     bool
+		AllocateError   bool
+		AllocateRequeue bool
+		UpdateError     bool
+		MachinesError   bool
+		ExpiresIn       time.Duration
+		ExpireError     bool
+		ExpectError     bool
+		ExpectRequeue   bool
 	}
 
 	type testCaseClusterDelete struct {
@@ -61,14 +79,35 @@ var _ = Describe("Metal3Cluster controller", func() {
 
 			if tc.CreateError {
 				returnedError = errors.New("Error")
+				m.EXPECT().AllocateControlPlaneEndpoint(context.TODO()).MaxTimes(0)
+				m.EXPECT().UpdateClusterStatus().MaxTimes(0)
+			} else if tc.AllocateError || tc.AllocateRequeue {
+				allocateError := errors.New("Error")
+				if tc.AllocateRequeue {
+					allocateError = baremetal.WithTransientError(nil, requeueAfter)
+				}
+				m.EXPECT().AllocateControlPlaneEndpoint(context.TODO()).Return(allocateError)
 				m.EXPECT().UpdateClusterStatus().MaxTimes(0)
 			} else {
+				m.EXPECT().AllocateControlPlaneEndpoint(context.TODO()).Return(nil)
 				if tc.UpdateError {
 					returnedError = errors.New("Error")
 				} else {
 					returnedError = nil
 				}
 				m.EXPECT().UpdateClusterStatus().Return(returnedError)
+				if tc.UpdateError {
+					m.EXPECT().UpdateMachinesStatus(context.TODO()).MaxTimes(0)
+				} else if tc.MachinesError {
+					m.EXPECT().UpdateMachinesStatus(context.TODO()).Return(errors.New("Error"))
+				} else {
+					m.EXPECT().UpdateMachinesStatus(context.TODO()).Return(nil)
+					if tc.ExpireError {
+						m.EXPECT().ExpireNodeReuseLabels(context.TODO()).Return(time.Duration(0), errors.New("Error"))
+					} else {
+						m.EXPECT().ExpireNodeReuseLabels(context.TODO()).Return(tc.ExpiresIn, nil)
+					}
+				}
 				returnedError = nil
 			}
 			m.EXPECT().
@@ -86,6 +125,9 @@ var _ = Describe("Metal3Cluster controller", func() {
 			} else {
 				Expect(res.Requeue).To(BeFalse())
 			}
+			if !tc.ExpectError && !tc.AllocateRequeue {
+				Expect(res.RequeueAfter).To(Equal(tc.ExpiresIn))
+			}
 		},
 		Entry("No errors", testCaseClusterNormal{
 			CreateError:   false,
@@ -99,12 +141,37 @@ var _ = Describe("Metal3Cluster controller", func() {
 			ExpectError:   true,
 			ExpectRequeue: false,
 		}),
+		Entry("Allocate error", testCaseClusterNormal{
+			AllocateError: true,
+			ExpectError:   true,
+			ExpectRequeue: false,
+		}),
+		Entry("Allocate requeue", testCaseClusterNormal{
+			AllocateRequeue: true,
+			ExpectError:     false,
+			ExpectRequeue:   true,
+		}),
 		Entry("Update error", testCaseClusterNormal{
 			CreateError:   false,
 			UpdateError:   true,
 			ExpectError:   true,
 			ExpectRequeue: false,
 		}),
+		Entry("Machines status error", testCaseClusterNormal{
+			MachinesError: true,
+			ExpectError:   true,
+			ExpectRequeue: false,
+		}),
+		Entry("Node reuse label expiring", testCaseClusterNormal{
+			ExpiresIn:     time.Hour,
+			ExpectError:   false,
+			ExpectRequeue: false,
+		}),
+		Entry("Node reuse label expiration error", testCaseClusterNormal{
+			ExpireError:   true,
+			ExpectError:   true,
+			ExpectRequeue: false,
+		}),
 	)
 
 	DescribeTable("Test ClusterReconcileDelete",
@@ -115,7 +182,7 @@ var _ = Describe("Metal3Cluster controller", func() {
 			// If we get an error while listing descendants or some still exists,
 			// we will exit with error or requeue.
 			if tc.DescendantsError || tc.DescendantsCount != 0 {
-				m.EXPECT().Delete().MaxTimes(0)
+				m.EXPECT().Delete(context.TODO()).MaxTimes(0)
 				m.EXPECT().UnsetFinalizer().MaxTimes(0)
 			} else {
 				// if no descendants are left, but we hit an error during delete,
@@ -127,7 +194,7 @@ var _ = Describe("Metal3Cluster controller", func() {
 					m.EXPECT().UnsetFinalizer()
 					returnedError = nil
 				}
-				m.EXPECT().Delete().Return(returnedError)
+				m.EXPECT().Delete(context.TODO()).Return(returnedError)
 			}
 
 			if tc.DescendantsError {
@@ -182,4 +249,113 @@ var _ = Describe("Metal3Cluster controller", func() {
 			ExpectRequeue:    false,
 		}),
 	)
+
+	type testCaseMetal3MachineToMetal3Cluster struct {
+		Labels          map[string]string
+		Cluster         *clusterv1.Cluster
+		ExpectedRequest bool
+	}
+
+	DescribeTable("Metal3Machine To Metal3Cluster tests",
+		func(tc testCaseMetal3MachineToMetal3Cluster) {
+			objects := []client.Object{}
+			if tc.Cluster != nil {
+				objects = append(objects, tc.Cluster)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			r := Metal3ClusterReconciler{Client: fakeClient, Log: logr.Discard()}
+			m3m := &infrav1.Metal3Machine{ObjectMeta: metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+				Labels:    tc.Labels,
+			}}
+
+			requests := r.Metal3MachineToMetal3Cluster(context.TODO(), m3m)
+			if !tc.ExpectedRequest {
+				Expect(requests).To(BeEmpty())
+				return
+			}
+			Expect(requests).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+				Name:      metal3ClusterName,
+				Namespace: namespaceName,
+			}}}))
+		},
+		Entry("Metal3Machine of a cluster", testCaseMetal3MachineToMetal3Cluster{
+			Labels:          map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			Cluster:         newCluster(clusterName, nil, nil),
+			ExpectedRequest: true,
+		}),
+		Entry("Metal3Machine without cluster label", testCaseMetal3MachineToMetal3Cluster{
+			Cluster: newCluster(clusterName, nil, nil),
+		}),
+		Entry("Cluster not found", testCaseMetal3MachineToMetal3Cluster{
+			Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		}),
+		Entry("Cluster of another infrastructure provider", testCaseMetal3MachineToMetal3Cluster{
+			Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			Cluster: newCluster(clusterName, &clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Name: "other", Kind: "OtherCluster"},
+			}, nil),
+		}),
+	)
+
+	type testCaseBareMetalHostToMetal3Clusters struct {
+		HostNamespace    string
+		BMHNamespaces    []string
+		WatchFilterValue string
+		ExpectedClusters []string
+	}
+
+	DescribeTable("BareMetalHost To Metal3Clusters tests",
+		func(tc testCaseBareMetalHostToMetal3Clusters) {
+			m3c := newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false)
+			m3c.Labels = map[string]string{clusterv1.WatchLabel: "capm3"}
+			otherM3c := newMetal3Cluster("other", nil, nil, nil, nil, false)
+			otherM3c.Namespace = "other"
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(m3c, otherM3c).Build()
+			r := Metal3ClusterReconciler{Client: fakeClient, Log: logr.Discard(), WatchFilterValue: tc.WatchFilterValue}
+			baremetal.BMHNamespaces = tc.BMHNamespaces
+			defer func() { baremetal.BMHNamespaces = nil }()
+			host := newBareMetalHost(baremetalhostName, nil, nil, nil, false)
+			host.Namespace = tc.HostNamespace
+
+			names := []string{}
+			for _, request := range r.BareMetalHostToMetal3Clusters(context.TODO(), host) {
+				names = append(names, request.Name)
+			}
+			Expect(names).To(ConsistOf(tc.ExpectedClusters))
+		},
+		Entry("Metal3Clusters of the namespace", testCaseBareMetalHostToMetal3Clusters{
+			HostNamespace:    namespaceName,
+			ExpectedClusters: []string{metal3ClusterName},
+		}),
+		Entry("No Metal3Cluster in the namespace", testCaseBareMetalHostToMetal3Clusters{
+			HostNamespace:    "inventory",
+			ExpectedClusters: []string{},
+		}),
+		Entry("Host in an allowed namespace", testCaseBareMetalHostToMetal3Clusters{
+			HostNamespace:    "inventory",
+			BMHNamespaces:    []string{"inventory"},
+			ExpectedClusters: []string{metal3ClusterName, "other"},
+		}),
+		Entry("Unlabeled host with a watch filter", testCaseBareMetalHostToMetal3Clusters{
+			HostNamespace:    "inventory",
+			BMHNamespaces:    []string{"inventory"},
+			WatchFilterValue: "capm3",
+			ExpectedClusters: []string{metal3ClusterName},
+		}),
+	)
+
+	DescribeTable("BareMetalHost labels changed tests",
+		func(oldLabels, newLabels map[string]string, expected bool) {
+			oldHost := &bmov1alpha1.BareMetalHost{ObjectMeta: metav1.ObjectMeta{Labels: oldLabels}}
+			newHost := &bmov1alpha1.BareMetalHost{ObjectMeta: metav1.ObjectMeta{Labels: newLabels}}
+			Expect(bareMetalHostLabelsChanged().Update(event.UpdateEvent{
+				ObjectOld: oldHost, ObjectNew: newHost,
+			})).To(Equal(expected))
+		},
+		Entry("Labels unchanged", map[string]string{"rack": "a"}, map[string]string{"rack": "a"}, false),
+		Entry("Label changed", map[string]string{"rack": "a"}, map[string]string{"rack": "b"}, true),
+		Entry("Label added", nil, map[string]string{"rack": "a"}, true),
+	)
 })
@@ -18,24 +18,30 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
-	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 const (
@@ -48,6 +54,9 @@ type Metal3DataReconciler struct {
 	ManagerFactory   baremetal.ManagerFactoryInterface
 	Log              logr.Logger
 	WatchFilterValue string
+	Shard            ShardOptions
+	// Recorder, when set, records the events of the Metal3Data.
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datas,verbs=get;list;watch;create;update;patch;delete
@@ -58,6 +67,7 @@ type Metal3DataReconciler struct {
 // Reconcile handles Metal3Data events.
 func (r *Metal3DataReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	metadataLog := r.Log.WithName(dataControllerName).WithValues("metal3-data", req.NamespacedName)
+	defer baremetal.LogDuration(metadataLog, "reconcile", time.Now())
 
 	// Fetch the Metal3Data instance.
 	capm3Metadata := &infrav1.Metal3Data{}
@@ -68,18 +78,37 @@ func (r *Metal3DataReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 		return ctrl.Result{}, err
 	}
+
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(capm3Metadata, r.WatchFilterValue, r.Shard) {
+		metadataLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
 	helper, err := patch.NewHelper(capm3Metadata, r.Client)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
 	}
 	// Always patch capm3Data exiting this function so we can persist any Metal3Data changes.
 	defer func() {
-		err := helper.Patch(ctx, capm3Metadata)
+		err := patchMetal3Data(ctx, helper, capm3Metadata)
 		if err != nil {
 			metadataLog.Info("failed to Patch Metal3Data")
 		}
 	}()
 
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(capm3Metadata, r.WatchFilterValue, r.Shard) {
+		metadataLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
+	// Return early if the Metadata is paused.
+	if hasPausedAnnotation(capm3Metadata) {
+		setPausedCondition(capm3Metadata, true)
+		metadataLog.Info("reconciliation is paused for this object")
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+	}
+
 	// Fetch the Cluster.
 	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, capm3Metadata.ObjectMeta)
 	if capm3Metadata.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -98,10 +127,12 @@ func (r *Metal3DataReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 		// Return early if the Metadata or Cluster is paused.
 		if annotations.IsPaused(cluster, capm3Metadata) {
+			setPausedCondition(capm3Metadata, true)
 			metadataLog.Info("reconciliation is paused for this object")
 			return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
 		}
 	}
+	setPausedCondition(capm3Metadata, false)
 
 	// Create a helper for managing the metadata object.
 	metadataMgr, err := r.ManagerFactory.NewDataManager(capm3Metadata, metadataLog)
@@ -111,32 +142,115 @@ func (r *Metal3DataReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Handle deleted metadata
 	if !capm3Metadata.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, metadataMgr)
+		return r.reconcileDelete(ctx, capm3Metadata, metadataMgr)
 	}
 
 	// Handle non-deleted machines
-	return r.reconcileNormal(ctx, metadataMgr)
+	wasTooLarge := conditions.IsFalse(capm3Metadata, infrav1.RenderedDataSizeValidCondition)
+	res, err := r.reconcileNormal(ctx, capm3Metadata, metadataMgr)
+	r.recordRenderedDataTooLarge(capm3Metadata, wasTooLarge)
+	return res, err
+}
+
+// recordRenderedDataTooLarge emits a warning event when the
+// RenderedDataSizeValidCondition is set to false on the Metal3Data, once
+// rather than at each reconciliation while the rendered data is too large.
+func (r *Metal3DataReconciler) recordRenderedDataTooLarge(capm3Metadata *infrav1.Metal3Data, wasTooLarge bool) {
+	condition := conditions.Get(capm3Metadata, infrav1.RenderedDataSizeValidCondition)
+	if r.Recorder == nil || wasTooLarge || condition == nil || condition.Status != corev1.ConditionFalse {
+		return
+	}
+	r.Recorder.Event(capm3Metadata, corev1.EventTypeWarning, condition.Reason, condition.Message)
+}
+
+func patchMetal3Data(ctx context.Context, patchHelper *patch.Helper, capm3Metadata *infrav1.Metal3Data) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	conditions.SetSummary(capm3Metadata,
+		conditions.WithConditions(
+			infrav1.SecretsRenderedCondition,
+		),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(ctx, capm3Metadata,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrav1.SecretsRenderedCondition,
+			infrav1.HostDataInUseCondition,
+			infrav1.RenderedDataSizeValidCondition,
+			infrav1.PausedCondition,
+		}},
+	)
 }
 
 func (r *Metal3DataReconciler) reconcileNormal(ctx context.Context,
-	metadataMgr baremetal.DataManagerInterface,
+	capm3Metadata *infrav1.Metal3Data, metadataMgr baremetal.DataManagerInterface,
 ) (ctrl.Result, error) {
 	// If the Metal3Data doesn't have finalizer, add it.
 	metadataMgr.SetFinalizer()
 
 	err := metadataMgr.Reconcile(ctx)
+	setSecretsRenderedCondition(capm3Metadata, err)
 	if err != nil {
 		return checkReconcileError(err, "Failed to create secrets")
 	}
 	return ctrl.Result{}, nil
 }
 
+// setSecretsRenderedCondition sets the SecretsRenderedCondition of the
+// Metal3Data from the result of the rendering of its secrets.
+func setSecretsRenderedCondition(capm3Metadata *infrav1.Metal3Data, err error) {
+	var reconcileError baremetal.ReconcileError
+	switch {
+	case err == nil && capm3Metadata.Status.Ready:
+		conditions.MarkTrue(capm3Metadata, infrav1.SecretsRenderedCondition)
+	case err == nil && capm3Metadata.Spec.Template.Name == "":
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			infrav1.DataTemplateNotSetReason, clusterv1.ConditionSeverityError,
+			"no Metal3DataTemplate referenced")
+	case err == nil:
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			infrav1.WaitingForMetal3DataTemplateReason, clusterv1.ConditionSeverityInfo,
+			"Metal3DataTemplate %s not found", capm3Metadata.Spec.Template.Name)
+	case conditions.IsFalse(capm3Metadata, infrav1.RenderedDataSizeValidCondition):
+		tooLarge := conditions.Get(capm3Metadata, infrav1.RenderedDataSizeValidCondition)
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			tooLarge.Reason, clusterv1.ConditionSeverityError, tooLarge.Message)
+	case errors.As(err, &reconcileError) && reconcileError.IsTransient():
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			infrav1.WaitingForRenderingInputsReason, clusterv1.ConditionSeverityInfo, err.Error())
+	case errors.As(err, &reconcileError) && reconcileError.IsTerminal():
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			infrav1.SecretsRenderingFailedReason, clusterv1.ConditionSeverityError, err.Error())
+	default:
+		conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+			infrav1.SecretsRenderingFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+	}
+}
+
 func (r *Metal3DataReconciler) reconcileDelete(ctx context.Context,
-	metadataMgr baremetal.DataManagerInterface,
+	capm3Metadata *infrav1.Metal3Data, metadataMgr baremetal.DataManagerInterface,
 ) (ctrl.Result, error) {
-	err := metadataMgr.ReleaseLeases(ctx)
+	conditions.MarkFalse(capm3Metadata, infrav1.SecretsRenderedCondition,
+		infrav1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+
+	// Keep the data and its secrets while a BareMetalHost references them.
+	err := metadataMgr.WaitForHostRelease(ctx)
 	if err != nil {
-		return checkReconcileError(err, "Failed to release IP address leases")
+		return checkDeletionError(capm3Metadata, infrav1.SecretsRenderedCondition,
+			err, "Failed to check the BareMetalHost of the data")
+	}
+
+	err = metadataMgr.ReleaseSecrets(ctx)
+	if err != nil {
+		return checkDeletionError(capm3Metadata, infrav1.SecretsRenderedCondition,
+			err, "Failed to release the secrets")
+	}
+
+	err = metadataMgr.ReleaseLeases(ctx)
+	if err != nil {
+		return checkDeletionError(capm3Metadata, infrav1.SecretsRenderedCondition,
+			err, "Failed to release IP address leases")
 	}
 
 	metadataMgr.UnsetFinalizer()
@@ -153,34 +267,116 @@ func (r *Metal3DataReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 			&ipamv1.IPClaim{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3IPClaimToMetal3Data),
 		).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&caipamv1.IPAddressClaim{},
+			handler.EnqueueRequestsFromMapFunc(r.IPAddressClaimToMetal3Data),
+		).
+		Watches(
+			&infrav1.Metal3Machine{},
+			handler.EnqueueRequestsFromMapFunc(r.Metal3MachineToMetal3Data),
+		).
+		Watches(
+			&infrav1.Metal3DataTemplate{},
+			handler.EnqueueRequestsFromMapFunc(r.Metal3DataTemplateToMetal3Data),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		WithEventFilter(ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)).
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
 		Complete(r)
 }
 
 // Metal3IPClaimToMetal3Data will return a reconcile request for a Metal3Data if the event is for a
 // Metal3IPClaim and that Metal3IPClaim references a Metal3Data.
 func (r *Metal3DataReconciler) Metal3IPClaimToMetal3Data(_ context.Context, obj client.Object) []ctrl.Request {
-	requests := []ctrl.Request{}
 	if m3dc, ok := obj.(*ipamv1.IPClaim); ok {
-		for _, ownerRef := range m3dc.OwnerReferences {
-			if ownerRef.Kind != "Metal3Data" {
-				continue
-			}
-			aGV, err := schema.ParseGroupVersion(ownerRef.APIVersion)
-			if err != nil {
-				r.Log.Error(err, "failed to parse the API version")
-				continue
-			}
-			if aGV.Group != infrav1.GroupVersion.Group {
-				continue
-			}
-			requests = append(requests, ctrl.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      ownerRef.Name,
-					Namespace: m3dc.Namespace,
-				},
-			})
+		return r.ownerMetal3DataRequests(m3dc)
+	}
+	return []ctrl.Request{}
+}
+
+// IPAddressClaimToMetal3Data will return a reconcile request for a Metal3Data if the event is for a
+// CAPI IPAddressClaim and that IPAddressClaim references a Metal3Data.
+func (r *Metal3DataReconciler) IPAddressClaimToMetal3Data(_ context.Context, obj client.Object) []ctrl.Request {
+	if claim, ok := obj.(*caipamv1.IPAddressClaim); ok {
+		return r.ownerMetal3DataRequests(claim)
+	}
+	return []ctrl.Request{}
+}
+
+// Metal3MachineToMetal3Data will return a reconcile request for the Metal3Data
+// rendered for a Metal3Machine, so that it follows the host of the machine.
+func (r *Metal3DataReconciler) Metal3MachineToMetal3Data(_ context.Context, obj client.Object) []ctrl.Request {
+	m3m, ok := obj.(*infrav1.Metal3Machine)
+	if !ok || m3m.Status.RenderedData == nil {
+		return []ctrl.Request{}
+	}
+	namespace := m3m.Status.RenderedData.Namespace
+	if namespace == "" {
+		namespace = m3m.Namespace
+	}
+	return []ctrl.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      m3m.Status.RenderedData.Name,
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+// Metal3DataTemplateToMetal3Data will return a reconcile request for each
+// Metal3Data rendered from a Metal3DataTemplate with rerenderOnTemplateChange
+// set, so that their secrets are re-rendered when the template changes.
+func (r *Metal3DataReconciler) Metal3DataTemplateToMetal3Data(ctx context.Context, obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
+	m3dt, ok := obj.(*infrav1.Metal3DataTemplate)
+	if !ok || !m3dt.Spec.RerenderOnTemplateChange {
+		return requests
+	}
+	dataList := &infrav1.Metal3DataList{}
+	if err := r.Client.List(ctx, dataList, client.InNamespace(m3dt.Namespace)); err != nil {
+		r.Log.Error(err, "failed to list Metal3Data", "metal3datatemplate", m3dt.Name)
+		return requests
+	}
+	for _, m3d := range dataList.Items {
+		if m3d.Spec.Template.Name != m3dt.Name {
+			continue
+		}
+		if m3d.Spec.Template.Namespace != "" && m3d.Spec.Template.Namespace != m3dt.Namespace {
+			continue
+		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      m3d.Name,
+				Namespace: m3d.Namespace,
+			},
+		})
+	}
+	return requests
+}
+
+// ownerMetal3DataRequests returns a reconcile request for each Metal3Data
+// owning the object.
+func (r *Metal3DataReconciler) ownerMetal3DataRequests(obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.Kind != "Metal3Data" {
+			continue
+		}
+		aGV, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+		if err != nil {
+			r.Log.Error(err, "failed to parse the API version")
+			continue
+		}
+		if aGV.Group != infrav1.GroupVersion.Group {
+			continue
 		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      ownerRef.Name,
+				Namespace: obj.GetNamespace(),
+			},
+		})
 	}
 	return requests
 }
@@ -30,9 +30,13 @@ import (
 	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -54,6 +58,7 @@ var _ = Describe("Metal3Data manager", func() {
 			reconcileNormalError bool
 			releaseLeasesRequeue bool
 			releaseLeasesError   bool
+			expectPaused         bool
 		}
 
 		DescribeTable("Test Reconcile",
@@ -69,7 +74,7 @@ var _ = Describe("Metal3Data manager", func() {
 				if tc.cluster != nil {
 					objects = append(objects, tc.cluster)
 				}
-				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
 
 				if tc.managerError {
 					mf.EXPECT().NewDataManager(gomock.Any(), gomock.Any()).Return(nil, errors.New(""))
@@ -79,6 +84,8 @@ var _ = Describe("Metal3Data manager", func() {
 					mf.EXPECT().NewDataManager(gomock.Any(), gomock.Any()).MaxTimes(0)
 				}
 				if tc.m3d != nil && !tc.m3d.DeletionTimestamp.IsZero() {
+					m.EXPECT().WaitForHostRelease(context.TODO()).Return(nil)
+					m.EXPECT().ReleaseSecrets(context.TODO()).Return(nil)
 					if tc.releaseLeasesRequeue {
 						m.EXPECT().ReleaseLeases(context.TODO()).Return(baremetal.WithTransientError(errors.New(""), requeueAfter))
 					} else if tc.releaseLeasesError {
@@ -125,6 +132,11 @@ var _ = Describe("Metal3Data manager", func() {
 				} else {
 					Expect(result.Requeue).To(BeFalse())
 				}
+				if tc.expectPaused {
+					m3d := &infrav1.Metal3Data{}
+					Expect(fakeClient.Get(ctx, req.NamespacedName, m3d)).To(Succeed())
+					Expect(conditions.IsTrue(m3d, infrav1.PausedCondition)).To(BeTrue())
+				}
 				gomockCtrl.Finish()
 			},
 			Entry("Metal3Data not found", testCaseReconcile{}),
@@ -213,6 +225,29 @@ var _ = Describe("Metal3Data manager", func() {
 					},
 				},
 				expectRequeue: true,
+				expectPaused:  true,
+			}),
+			Entry("Paused with the CAPM3 annotation", testCaseReconcile{
+				m3d: &infrav1.Metal3Data{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      metal3DataName,
+						Namespace: namespaceName,
+						Labels: map[string]string{
+							clusterv1.ClusterNameLabel: metal3DataName,
+						},
+						Annotations: map[string]string{
+							infrav1.PausedAnnotation: "",
+						},
+					},
+				},
+				cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      metal3DataName,
+						Namespace: namespaceName,
+					},
+				},
+				expectRequeue: true,
+				expectPaused:  true,
 			}),
 			Entry("Error in manager", testCaseReconcile{
 				m3d: &infrav1.Metal3Data{
@@ -278,6 +313,11 @@ var _ = Describe("Metal3Data manager", func() {
 			ExpectRequeue        bool
 			createSecretsRequeue bool
 			createSecretsError   bool
+			createSecretsTooBig  bool
+			templateNotFound     bool
+			templateNotSet       bool
+			expectedReason       string
+			expectedSeverity     clusterv1.ConditionSeverity
 		}
 
 		DescribeTable("ReconcileNormal tests",
@@ -293,6 +333,15 @@ var _ = Describe("Metal3Data manager", func() {
 					WatchFilterValue: "",
 				}
 				m := baremetal_mocks.NewMockDataManagerInterface(gomockCtrl)
+				m3d := &infrav1.Metal3Data{
+					Spec: infrav1.Metal3DataSpec{
+						Template: corev1.ObjectReference{Name: "abc"},
+					},
+					Status: infrav1.Metal3DataStatus{Ready: !tc.templateNotFound && !tc.templateNotSet},
+				}
+				if tc.templateNotSet {
+					m3d.Spec.Template.Name = ""
+				}
 
 				m.EXPECT().SetFinalizer()
 
@@ -300,11 +349,21 @@ var _ = Describe("Metal3Data manager", func() {
 					m.EXPECT().Reconcile(context.TODO()).Return(baremetal.WithTransientError(errors.New(""), requeueAfter))
 				} else if tc.createSecretsError {
 					m.EXPECT().Reconcile(context.TODO()).Return(errors.New(""))
+				} else if tc.createSecretsTooBig {
+					m.EXPECT().Reconcile(context.TODO()).DoAndReturn(func(_ context.Context) error {
+						conditions.Set(m3d, &clusterv1.Condition{
+							Type:    infrav1.RenderedDataSizeValidCondition,
+							Status:  corev1.ConditionFalse,
+							Reason:  infrav1.SecretSizeLimitExceededReason,
+							Message: "secret abc-metadata of 2097152 bytes exceeds the limit of 1048576 bytes",
+						})
+						return baremetal.WithTerminalError(errors.New(""))
+					})
 				} else {
 					m.EXPECT().Reconcile(context.TODO()).Return(nil)
 				}
 
-				res, err := dataReconcile.reconcileNormal(context.TODO(), m)
+				res, err := dataReconcile.reconcileNormal(context.TODO(), m3d, m)
 				gomockCtrl.Finish()
 
 				if tc.ExpectError {
@@ -317,6 +376,13 @@ var _ = Describe("Metal3Data manager", func() {
 				} else {
 					Expect(res.Requeue).To(BeFalse())
 				}
+				if tc.expectedReason == "" {
+					Expect(conditions.IsTrue(m3d, infrav1.SecretsRenderedCondition)).To(BeTrue())
+				} else {
+					Expect(conditions.IsFalse(m3d, infrav1.SecretsRenderedCondition)).To(BeTrue())
+					Expect(conditions.GetReason(m3d, infrav1.SecretsRenderedCondition)).To(Equal(tc.expectedReason))
+					Expect(conditions.GetSeverity(m3d, infrav1.SecretsRenderedCondition)).To(Equal(&tc.expectedSeverity))
+				}
 			},
 			Entry("Reconcile Succeeds", reconcileNormalTestCase{
 				ExpectError:   false,
@@ -326,20 +392,76 @@ var _ = Describe("Metal3Data manager", func() {
 				ExpectError:        true,
 				ExpectRequeue:      false,
 				createSecretsError: true,
+				expectedReason:     infrav1.SecretsRenderingFailedReason,
+				expectedSeverity:   clusterv1.ConditionSeverityWarning,
 			}),
 			Entry("Reconcile fails", reconcileNormalTestCase{
 				ExpectError:          false,
 				ExpectRequeue:        true,
 				createSecretsRequeue: true,
+				expectedReason:       infrav1.WaitingForRenderingInputsReason,
+				expectedSeverity:     clusterv1.ConditionSeverityInfo,
+			}),
+			Entry("Reconcile stops when the rendered data is too large", reconcileNormalTestCase{
+				ExpectError:         false,
+				ExpectRequeue:       false,
+				createSecretsTooBig: true,
+				expectedReason:      infrav1.SecretSizeLimitExceededReason,
+				expectedSeverity:    clusterv1.ConditionSeverityError,
+			}),
+			Entry("Metal3DataTemplate not found", reconcileNormalTestCase{
+				ExpectError:      false,
+				ExpectRequeue:    false,
+				templateNotFound: true,
+				expectedReason:   infrav1.WaitingForMetal3DataTemplateReason,
+				expectedSeverity: clusterv1.ConditionSeverityInfo,
+			}),
+			Entry("Metal3DataTemplate not set", reconcileNormalTestCase{
+				ExpectError:      false,
+				ExpectRequeue:    false,
+				templateNotSet:   true,
+				expectedReason:   infrav1.DataTemplateNotSetReason,
+				expectedSeverity: clusterv1.ConditionSeverityError,
 			}),
 		)
 	})
 
+	DescribeTable("Test recordRenderedDataTooLarge",
+		func(wasTooLarge bool, isTooLarge bool, expectEvent bool) {
+			m3d := &infrav1.Metal3Data{}
+			if isTooLarge {
+				conditions.Set(m3d, &clusterv1.Condition{
+					Type:    infrav1.RenderedDataSizeValidCondition,
+					Status:  corev1.ConditionFalse,
+					Reason:  infrav1.SecretSizeLimitExceededReason,
+					Message: "secret abc-metadata of 2097152 bytes exceeds the limit of 1048576 bytes",
+				})
+			}
+			recorder := record.NewFakeRecorder(1)
+			dataReconcile := &Metal3DataReconciler{Recorder: recorder}
+
+			dataReconcile.recordRenderedDataTooLarge(m3d, wasTooLarge)
+
+			if !expectEvent {
+				Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			Expect(recorder.Events).To(Receive(Equal("Warning SecretSizeLimitExceeded " +
+				"secret abc-metadata of 2097152 bytes exceeds the limit of 1048576 bytes")))
+		},
+		Entry("Event when the data becomes too large", false, true, true),
+		Entry("No event while the data stays too large", true, true, false),
+		Entry("No event when the data is not too large", false, false, false),
+	)
+
 	type reconcileDeleteTestCase struct {
 		ExpectError          bool
 		ExpectRequeue        bool
 		ReleaseLeasesRequeue bool
 		ReleaseLeasesError   bool
+		HostInUse            bool
+		ReleaseSecretsError  bool
+		expectedReason       string
 	}
 
 	DescribeTable("ReconcileDelete tests",
@@ -356,16 +478,31 @@ var _ = Describe("Metal3Data manager", func() {
 			}
 			m := baremetal_mocks.NewMockDataManagerInterface(gomockCtrl)
 
-			if tc.ReleaseLeasesRequeue {
+			if tc.HostInUse {
+				m.EXPECT().WaitForHostRelease(context.TODO()).Return(baremetal.WithTransientError(errors.New(""), requeueAfter))
+			} else {
+				m.EXPECT().WaitForHostRelease(context.TODO()).Return(nil)
+			}
+			if tc.HostInUse {
+				m.EXPECT().ReleaseSecrets(context.TODO()).MaxTimes(0)
+				m.EXPECT().ReleaseLeases(context.TODO()).MaxTimes(0)
+			} else if tc.ReleaseSecretsError {
+				m.EXPECT().ReleaseSecrets(context.TODO()).Return(errors.New(""))
+				m.EXPECT().ReleaseLeases(context.TODO()).MaxTimes(0)
+			} else if tc.ReleaseLeasesRequeue {
+				m.EXPECT().ReleaseSecrets(context.TODO()).Return(nil)
 				m.EXPECT().ReleaseLeases(context.TODO()).Return(baremetal.WithTransientError(errors.New(""), requeueAfter))
 			} else if tc.ReleaseLeasesError {
+				m.EXPECT().ReleaseSecrets(context.TODO()).Return(nil)
 				m.EXPECT().ReleaseLeases(context.TODO()).Return(errors.New(""))
 			} else {
+				m.EXPECT().ReleaseSecrets(context.TODO()).Return(nil)
 				m.EXPECT().ReleaseLeases(context.TODO()).Return(nil)
 				m.EXPECT().UnsetFinalizer()
 			}
 
-			res, err := dataReconcile.reconcileDelete(context.TODO(), m)
+			m3d := &infrav1.Metal3Data{}
+			res, err := dataReconcile.reconcileDelete(context.TODO(), m3d, m)
 			gomockCtrl.Finish()
 
 			if tc.ExpectError {
@@ -378,20 +515,37 @@ var _ = Describe("Metal3Data manager", func() {
 			} else {
 				Expect(res.Requeue).To(BeFalse())
 			}
+			Expect(conditions.IsFalse(m3d, infrav1.SecretsRenderedCondition)).To(BeTrue())
+			Expect(conditions.GetReason(m3d, infrav1.SecretsRenderedCondition)).To(Equal(tc.expectedReason))
 		},
 		Entry("Reconcile Succeeds", reconcileDeleteTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
+			ExpectError:    false,
+			ExpectRequeue:  false,
+			expectedReason: infrav1.DeletingReason,
 		}),
 		Entry("Reconcile requeues", reconcileDeleteTestCase{
 			ExpectError:        true,
 			ExpectRequeue:      false,
 			ReleaseLeasesError: true,
+			expectedReason:     infrav1.DeletionFailedReason,
 		}),
 		Entry("Reconcile fails", reconcileDeleteTestCase{
 			ExpectError:          false,
 			ExpectRequeue:        true,
 			ReleaseLeasesRequeue: true,
+			expectedReason:       infrav1.DeletingReason,
+		}),
+		Entry("Host still uses the data", reconcileDeleteTestCase{
+			ExpectError:    false,
+			ExpectRequeue:  true,
+			HostInUse:      true,
+			expectedReason: infrav1.DeletingReason,
+		}),
+		Entry("Releasing the secrets fails", reconcileDeleteTestCase{
+			ExpectError:         true,
+			ExpectRequeue:       false,
+			ReleaseSecretsError: true,
+			expectedReason:      infrav1.DeletionFailedReason,
 		}),
 	)
 
@@ -448,4 +602,113 @@ var _ = Describe("Metal3Data manager", func() {
 		}),
 	)
 
+	DescribeTable("test IPAddressClaimToMetal3Data",
+		func(tc testCaseMetal3IPClaimToMetal3Data) {
+			claim := &caipamv1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       namespaceName,
+					OwnerReferences: tc.ownerRefs,
+				},
+			}
+			m3DataReconciler := Metal3DataReconciler{}
+			reqs := m3DataReconciler.IPAddressClaimToMetal3Data(context.Background(), claim)
+			Expect(reqs).To(Equal(tc.expectedRequests))
+		},
+		Entry("No OwnerRefs", testCaseMetal3IPClaimToMetal3Data{
+			expectedRequests: []ctrl.Request{},
+		}),
+		Entry("OwnerRefs", testCaseMetal3IPClaimToMetal3Data{
+			ownerRefs: []metav1.OwnerReference{
+				{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3Data",
+					Name:       "abc",
+				},
+				{
+					APIVersion: "foo.bar/v1",
+					Kind:       "Metal3Data",
+					Name:       "cde",
+				},
+			},
+			expectedRequests: []ctrl.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      "abc",
+						Namespace: namespaceName,
+					},
+				},
+			},
+		}),
+	)
+
+	DescribeTable("test Metal3MachineToMetal3Data",
+		func(renderedData *corev1.ObjectReference, expectedRequests []ctrl.Request) {
+			m3m := &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc",
+					Namespace: namespaceName,
+				},
+				Status: infrav1.Metal3MachineStatus{
+					RenderedData: renderedData,
+				},
+			}
+			m3DataReconciler := Metal3DataReconciler{}
+			reqs := m3DataReconciler.Metal3MachineToMetal3Data(context.Background(), m3m)
+			Expect(reqs).To(Equal(expectedRequests))
+		},
+		Entry("No rendered data", nil, []ctrl.Request{}),
+		Entry("Rendered data", &corev1.ObjectReference{Name: "abc-0"},
+			[]ctrl.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      "abc-0",
+						Namespace: namespaceName,
+					},
+				},
+			},
+		),
+	)
+	DescribeTable("test Metal3DataTemplateToMetal3Data",
+		func(rerender bool, expectedRequests []ctrl.Request) {
+			newData := func(name, namespace, template string) *infrav1.Metal3Data {
+				return &infrav1.Metal3Data{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+					Spec: infrav1.Metal3DataSpec{
+						Template: corev1.ObjectReference{Name: template},
+					},
+				}
+			}
+			m3dt := &infrav1.Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc",
+					Namespace: namespaceName,
+				},
+				Spec: infrav1.Metal3DataTemplateSpec{
+					RerenderOnTemplateChange: rerender,
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+				newData("abc-0", namespaceName, "abc"),
+				newData("def-0", namespaceName, "def"),
+				newData("abc-0", "other", "abc"),
+			).Build()
+			m3DataReconciler := Metal3DataReconciler{Client: fakeClient, Log: logr.Discard()}
+			reqs := m3DataReconciler.Metal3DataTemplateToMetal3Data(context.Background(), m3dt)
+			Expect(reqs).To(Equal(expectedRequests))
+		},
+		Entry("Re-render disabled", false, []ctrl.Request{}),
+		Entry("Re-render enabled", true,
+			[]ctrl.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      "abc-0",
+						Namespace: namespaceName,
+					},
+				},
+			},
+		),
+	)
 })
@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
@@ -27,12 +28,15 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
-	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -46,6 +50,7 @@ type Metal3DataTemplateReconciler struct {
 	ManagerFactory   baremetal.ManagerFactoryInterface
 	Log              logr.Logger
 	WatchFilterValue string
+	Shard            ShardOptions
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datatemplates,verbs=get;list;watch;create;update;patch;delete
@@ -54,6 +59,8 @@ type Metal3DataTemplateReconciler struct {
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datas/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3dataclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3dataclaims/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipclaims/status,verbs=get;watch
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipaddresses,verbs=get;list;watch
@@ -70,6 +77,7 @@ type Metal3DataTemplateReconciler struct {
 // Reconcile handles Metal3Machine events.
 func (r *Metal3DataTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	metadataLog := r.Log.WithName(dataTemplateControllerName).WithValues("metal3-datatemplate", req.NamespacedName)
+	defer baremetal.LogDuration(metadataLog, "reconcile", time.Now())
 
 	// Fetch the Metal3DataTemplate instance.
 	capm3DataTemplate := &infrav1.Metal3DataTemplate{}
@@ -80,18 +88,37 @@ func (r *Metal3DataTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 		}
 		return ctrl.Result{}, err
 	}
+
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(capm3DataTemplate, r.WatchFilterValue, r.Shard) {
+		metadataLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
 	helper, err := patch.NewHelper(capm3DataTemplate, r.Client)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
 	}
 	// Always patch capm3Machine exiting this function so we can persist any Metal3Machine changes.
 	defer func() {
-		err := helper.Patch(ctx, capm3DataTemplate)
+		err := patchMetal3DataTemplate(ctx, helper, capm3DataTemplate)
 		if err != nil {
 			metadataLog.Info("failed to Patch capm3DataTemplate")
 		}
 	}()
 
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(capm3DataTemplate, r.WatchFilterValue, r.Shard) {
+		metadataLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
+	// Return early if the DataTemplate is paused.
+	if hasPausedAnnotation(capm3DataTemplate) {
+		setPausedCondition(capm3DataTemplate, true)
+		metadataLog.Info("reconciliation is paused for this object")
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+	}
+
 	cluster := &clusterv1.Cluster{}
 	key := client.ObjectKey{
 		Name:      capm3DataTemplate.Spec.ClusterName,
@@ -120,45 +147,86 @@ func (r *Metal3DataTemplateReconciler) Reconcile(ctx context.Context, req ctrl.R
 		}
 		// Return early if the Metadata or Cluster is paused.
 		if annotations.IsPaused(cluster, capm3DataTemplate) {
+			setPausedCondition(capm3DataTemplate, true)
 			metadataLog.Info("reconciliation is paused for this object")
 			return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
 		}
 	}
+	setPausedCondition(capm3DataTemplate, false)
 
 	// Handle deleted metadata
 	if !capm3DataTemplate.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, metadataMgr)
+		return r.reconcileDelete(ctx, capm3DataTemplate, metadataMgr)
 	}
 
 	// Handle non-deleted machines
-	return r.reconcileNormal(ctx, metadataMgr)
+	return r.reconcileNormal(ctx, capm3DataTemplate, metadataMgr)
+}
+
+func patchMetal3DataTemplate(ctx context.Context, patchHelper *patch.Helper, capm3DataTemplate *infrav1.Metal3DataTemplate) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	conditions.SetSummary(capm3DataTemplate,
+		conditions.WithConditions(
+			infrav1.DataClaimsReconciledCondition,
+		),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(ctx, capm3DataTemplate,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrav1.DataClaimsReconciledCondition,
+			infrav1.PausedCondition,
+		}},
+	)
 }
 
 func (r *Metal3DataTemplateReconciler) reconcileNormal(ctx context.Context,
-	metadataMgr baremetal.DataTemplateManagerInterface,
+	capm3DataTemplate *infrav1.Metal3DataTemplate, metadataMgr baremetal.DataTemplateManagerInterface,
 ) (ctrl.Result, error) {
 	// If the Metal3DataTemplate doesn't have finalizer, add it.
 	metadataMgr.SetFinalizer()
 
 	_, err := metadataMgr.UpdateDatas(ctx)
 	if err != nil {
+		severity := clusterv1.ConditionSeverityWarning
+		if isTransientError(err) {
+			severity = clusterv1.ConditionSeverityInfo
+		}
+		conditions.MarkFalse(capm3DataTemplate, infrav1.DataClaimsReconciledCondition,
+			infrav1.DataClaimsReconcileFailedReason, severity, err.Error())
 		return checkReconcileError(err, "Failed to recreate the status")
 	}
-	return ctrl.Result{}, nil
+	conditions.MarkTrue(capm3DataTemplate, infrav1.DataClaimsReconciledCondition)
+
+	// Come back when the next orphaned claim may be deleted.
+	deleteIn, err := metadataMgr.DeleteOrphanedClaims(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to delete the orphaned Metal3DataClaims")
+	}
+	return ctrl.Result{RequeueAfter: deleteIn}, nil
 }
 
 func (r *Metal3DataTemplateReconciler) reconcileDelete(ctx context.Context,
-	metadataMgr baremetal.DataTemplateManagerInterface,
+	capm3DataTemplate *infrav1.Metal3DataTemplate, metadataMgr baremetal.DataTemplateManagerInterface,
 ) (ctrl.Result, error) {
+	conditions.MarkFalse(capm3DataTemplate, infrav1.DataClaimsReconciledCondition,
+		infrav1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+
 	allocationsNb, err := metadataMgr.UpdateDatas(ctx)
 	if err != nil {
-		return checkReconcileError(err, "Failed to recreate the status")
+		return checkDeletionError(capm3DataTemplate, infrav1.DataClaimsReconciledCondition,
+			err, "Failed to recreate the status")
 	}
 
 	if allocationsNb == 0 {
 		// metal3datatemplate is marked for deletion and ready to be deleted,
 		// so remove the finalizer.
 		metadataMgr.UnsetFinalizer()
+	} else {
+		conditions.MarkFalse(capm3DataTemplate, infrav1.DataClaimsReconciledCondition,
+			infrav1.DeletingReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for %d Metal3Data to be deleted", allocationsNb)
 	}
 
 	return ctrl.Result{}, nil
@@ -173,7 +241,18 @@ func (r *Metal3DataTemplateReconciler) SetupWithManager(ctx context.Context, mgr
 			&infrav1.Metal3DataClaim{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3DataClaimToMetal3DataTemplate),
 		).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		// The claims of a deleted Metal3Machine may be orphaned.
+		Watches(
+			&infrav1.Metal3Machine{},
+			handler.EnqueueRequestsFromMapFunc(r.Metal3MachineToMetal3DataTemplate),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			}),
+		).
+		WithEventFilter(ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)).
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
 		Complete(r)
 }
 
@@ -200,6 +279,26 @@ func (r *Metal3DataTemplateReconciler) Metal3DataClaimToMetal3DataTemplate(_ con
 	return []ctrl.Request{}
 }
 
+// Metal3MachineToMetal3DataTemplate will return a reconcile request for the
+// Metal3DataTemplate referenced by a Metal3Machine.
+func (r *Metal3DataTemplateReconciler) Metal3MachineToMetal3DataTemplate(_ context.Context, obj client.Object) []ctrl.Request {
+	if m3m, ok := obj.(*infrav1.Metal3Machine); ok && m3m.Spec.DataTemplate != nil && m3m.Spec.DataTemplate.Name != "" {
+		namespace := m3m.Spec.DataTemplate.Namespace
+		if namespace == "" {
+			namespace = m3m.Namespace
+		}
+		return []ctrl.Request{
+			{
+				NamespacedName: types.NamespacedName{
+					Name:      m3m.Spec.DataTemplate.Name,
+					Namespace: namespace,
+				},
+			},
+		}
+	}
+	return []ctrl.Request{}
+}
+
 func checkReconcileError(err error, errMessage string) (ctrl.Result, error) {
 	if err == nil {
 		return ctrl.Result{}, nil
@@ -215,3 +314,16 @@ func checkReconcileError(err error, errMessage string) (ctrl.Result, error) {
 	}
 	return ctrl.Result{}, errors.Wrap(err, errMessage)
 }
+
+// checkDeletionError marks the condition of obj false with the
+// DeletionFailedReason unless the deletion is requeued, and returns the
+// result of checkReconcileError.
+func checkDeletionError(obj conditions.Setter, conditionType clusterv1.ConditionType,
+	err error, errMessage string,
+) (ctrl.Result, error) {
+	if !isTransientError(err) {
+		conditions.MarkFalse(obj, conditionType, infrav1.DeletionFailedReason,
+			clusterv1.ConditionSeverityWarning, err.Error())
+	}
+	return checkReconcileError(err, errMessage)
+}
@@ -34,6 +34,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -98,6 +99,7 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 					m.EXPECT().UpdateDatas(context.TODO()).Return(0, errors.New(""))
 				} else {
 					m.EXPECT().UpdateDatas(context.TODO()).Return(1, nil)
+					m.EXPECT().DeleteOrphanedClaims(context.TODO()).Return(time.Duration(0), nil)
 				}
 			}
 
@@ -176,6 +178,22 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 			expectRequeue: true,
 			expectManager: true,
 		}),
+		Entry("Paused with the CAPM3 annotation", testCaseReconcile{
+			m3dt: &infrav1.Metal3DataTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3DataTemplateName,
+					Namespace: namespaceName,
+					Annotations: map[string]string{
+						infrav1.PausedAnnotation: "",
+					},
+				},
+				Spec: infrav1.Metal3DataTemplateSpec{ClusterName: clusterName},
+			},
+			cluster: &clusterv1.Cluster{
+				ObjectMeta: testObjectMeta(clusterName, namespaceName, ""),
+			},
+			expectRequeue: true,
+		}),
 		Entry("Error in manager", testCaseReconcile{
 			m3dt: &infrav1.Metal3DataTemplate{
 				ObjectMeta: testObjectMeta(metal3DataTemplateName, namespaceName, ""),
@@ -212,9 +230,13 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 	)
 
 	type reconcileNormalTestCase struct {
-		ExpectError   bool
-		ExpectRequeue bool
-		UpdateError   bool
+		ExpectError      bool
+		ExpectRequeue    bool
+		UpdateError      bool
+		UpdateRequeue    bool
+		OrphanDeleteIn   time.Duration
+		OrphanError      bool
+		expectedSeverity clusterv1.ConditionSeverity
 	}
 
 	DescribeTable("ReconcileNormal tests",
@@ -233,13 +255,21 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 
 			m.EXPECT().SetFinalizer()
 
-			if !tc.UpdateError {
-				m.EXPECT().UpdateDatas(context.TODO()).Return(1, nil)
-			} else {
+			if tc.UpdateError {
 				m.EXPECT().UpdateDatas(context.TODO()).Return(0, errors.New(""))
+			} else if tc.UpdateRequeue {
+				m.EXPECT().UpdateDatas(context.TODO()).Return(0, baremetal.WithTransientError(errors.New(""), requeueAfter))
+			} else {
+				m.EXPECT().UpdateDatas(context.TODO()).Return(1, nil)
+				if tc.OrphanError {
+					m.EXPECT().DeleteOrphanedClaims(context.TODO()).Return(time.Duration(0), errors.New(""))
+				} else {
+					m.EXPECT().DeleteOrphanedClaims(context.TODO()).Return(tc.OrphanDeleteIn, nil)
+				}
 			}
 
-			res, err := r.reconcileNormal(context.TODO(), m)
+			m3dt := &infrav1.Metal3DataTemplate{}
+			res, err := r.reconcileNormal(context.TODO(), m3dt, m)
 			gomockCtrl.Finish()
 
 			if tc.ExpectError {
@@ -252,23 +282,49 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 			} else {
 				Expect(res.Requeue).To(BeFalse())
 			}
+			if !tc.UpdateError && !tc.UpdateRequeue {
+				Expect(res.RequeueAfter).To(Equal(tc.OrphanDeleteIn))
+				Expect(conditions.IsTrue(m3dt, infrav1.DataClaimsReconciledCondition)).To(BeTrue())
+				return
+			}
+			Expect(conditions.IsFalse(m3dt, infrav1.DataClaimsReconciledCondition)).To(BeTrue())
+			Expect(conditions.GetReason(m3dt, infrav1.DataClaimsReconciledCondition)).To(Equal(infrav1.DataClaimsReconcileFailedReason))
+			Expect(conditions.GetSeverity(m3dt, infrav1.DataClaimsReconciledCondition)).To(Equal(&tc.expectedSeverity))
 		},
 		Entry("No error", reconcileNormalTestCase{
 			ExpectError:   false,
 			ExpectRequeue: false,
 		}),
-		Entry("Update error", reconcileNormalTestCase{
-			UpdateError:   true,
+		Entry("Orphaned claims to delete later", reconcileNormalTestCase{
+			OrphanDeleteIn: 5 * time.Minute,
+			ExpectError:    false,
+			ExpectRequeue:  false,
+		}),
+		Entry("Orphaned claims error", reconcileNormalTestCase{
+			OrphanError:   true,
 			ExpectError:   true,
 			ExpectRequeue: false,
 		}),
+		Entry("Update error", reconcileNormalTestCase{
+			UpdateError:      true,
+			ExpectError:      true,
+			ExpectRequeue:    false,
+			expectedSeverity: clusterv1.ConditionSeverityWarning,
+		}),
+		Entry("Update requeue", reconcileNormalTestCase{
+			UpdateRequeue:    true,
+			ExpectError:      false,
+			ExpectRequeue:    true,
+			expectedSeverity: clusterv1.ConditionSeverityInfo,
+		}),
 	)
 
 	type reconcileDeleteTestCase struct {
-		ExpectError   bool
-		ExpectRequeue bool
-		DeleteReady   bool
-		DeleteError   bool
+		ExpectError    bool
+		ExpectRequeue  bool
+		DeleteReady    bool
+		DeleteError    bool
+		expectedReason string
 	}
 
 	DescribeTable("ReconcileDelete tests",
@@ -294,7 +350,8 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 				m.EXPECT().UpdateDatas(context.TODO()).Return(0, errors.New(""))
 			}
 
-			res, err := r.reconcileDelete(context.TODO(), m)
+			m3dt := &infrav1.Metal3DataTemplate{}
+			res, err := r.reconcileDelete(context.TODO(), m3dt, m)
 			gomockCtrl.Finish()
 
 			if tc.ExpectError {
@@ -307,21 +364,25 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 			} else {
 				Expect(res.Requeue).To(BeFalse())
 			}
-
+			Expect(conditions.IsFalse(m3dt, infrav1.DataClaimsReconciledCondition)).To(BeTrue())
+			Expect(conditions.GetReason(m3dt, infrav1.DataClaimsReconciledCondition)).To(Equal(tc.expectedReason))
 		},
 		Entry("No error", reconcileDeleteTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
+			ExpectError:    false,
+			ExpectRequeue:  false,
+			expectedReason: infrav1.DeletingReason,
 		}),
 		Entry("Delete error", reconcileDeleteTestCase{
-			DeleteError:   true,
-			ExpectError:   true,
-			ExpectRequeue: false,
+			DeleteError:    true,
+			ExpectError:    true,
+			ExpectRequeue:  false,
+			expectedReason: infrav1.DeletionFailedReason,
 		}),
 		Entry("Delete ready", reconcileDeleteTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
-			DeleteReady:   true,
+			ExpectError:    false,
+			ExpectRequeue:  false,
+			DeleteReady:    true,
+			expectedReason: infrav1.DeletingReason,
 		}),
 	)
 
@@ -403,6 +464,22 @@ var _ = Describe("Metal3DataTemplate manager", func() {
 		),
 	)
 
+	It("Maps a Metal3Machine to its Metal3DataTemplate", func() {
+		r := Metal3DataTemplateReconciler{}
+		m3m := &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      metal3machineName,
+				Namespace: namespaceName,
+			},
+		}
+		Expect(r.Metal3MachineToMetal3DataTemplate(context.Background(), m3m)).To(BeEmpty())
+
+		m3m.Spec.DataTemplate = &corev1.ObjectReference{Name: metal3DataTemplateName}
+		Expect(r.Metal3MachineToMetal3DataTemplate(context.Background(), m3m)).To(Equal([]ctrl.Request{
+			{NamespacedName: types.NamespacedName{Name: metal3DataTemplateName, Namespace: namespaceName}},
+		}))
+	})
+
 	It("Test checkReconcileError", func() {
 		result, err := checkReconcileError(nil, "")
 		Expect(err).NotTo(HaveOccurred())
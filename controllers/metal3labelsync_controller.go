@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,19 +28,22 @@ import (
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/cache"
 	k8strings "k8s.io/utils/strings"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/patch"
-	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -47,12 +51,33 @@ import (
 
 var (
 	bmhSyncInterval = 60 * time.Second
+
+	// reverseSyncDeniedPrefixes are the prefixes of the labels managed by
+	// Kubernetes on the Node. They are never synchronized to the BareMetalHost,
+	// so that they can not loop back to the Node.
+	reverseSyncDeniedPrefixes = map[string]struct{}{
+		"kubernetes.io":                  {},
+		"k8s.io":                         {},
+		"beta.kubernetes.io":             {},
+		"node.kubernetes.io":             {},
+		"node-role.kubernetes.io":        {},
+		"node-restriction.kubernetes.io": {},
+	}
 )
 
 const (
 	labelSyncControllerName = "metal3-label-sync-controller"
 	// PrefixAnnotationKey is prefix for annotation key.
 	PrefixAnnotationKey = "metal3.io/metal3-label-sync-prefixes"
+	// ReversePrefixAnnotationKey is the annotation key for the prefixes of the
+	// labels synchronized from the Node to the BareMetalHost.
+	ReversePrefixAnnotationKey = "metal3.io/metal3-label-reverse-sync-prefixes"
+	// AnnotationSyncAnnotationKey is the annotation key for the rules
+	// synchronizing annotations of the BareMetalHost to labels of the Node.
+	AnnotationSyncAnnotationKey = "metal3.io/metal3-annotation-sync"
+	// SanitizeTransformation is the transformation of an annotation sync rule
+	// turning any annotation value into a valid label value.
+	SanitizeTransformation = "sanitize"
 	// Metal3Machine is name of the Metal3 CRD.
 	Metal3Machine = "Metal3Machine"
 )
@@ -64,6 +89,12 @@ type Metal3LabelSyncReconciler struct {
 	Log              logr.Logger
 	CapiClientGetter baremetal.ClientGetter
 	WatchFilterValue string
+	Shard            ShardOptions
+	// Tracker, when set, is used to watch the Nodes of the workload clusters
+	// instead of periodically synchronizing the labels.
+	Tracker *remote.ClusterCacheTracker
+
+	controller controller.Controller
 }
 
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;create;update;patch;delete
@@ -77,6 +108,7 @@ type Metal3LabelSyncReconciler struct {
 // Reconcile handles label sync events.
 func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	controllerLog := r.Log.WithName(labelSyncControllerName).WithValues("metal3-label-sync", req.NamespacedName)
+	defer baremetal.LogDuration(controllerLog, "reconcile", time.Now())
 
 	// We need to get the NodeRef from the CAPI Machine object:
 	// BareMetalHost.ConsumerRef --> Metal3Machine.OwnerRef --> Machine.NodeRef
@@ -88,6 +120,14 @@ func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 		return ctrl.Result{}, err
 	}
+
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard. With a watch-filter
+	// value, the hosts are filtered on their Metal3Machine below.
+	if r.WatchFilterValue == "" && ownedByOtherShard(host, "", r.Shard) {
+		controllerLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
 	if host.Annotations != nil {
 		if _, ok := host.Annotations[bmov1alpha1.PausedAnnotation]; ok {
 			controllerLog.Info("BaremetalHost is currently paused. Remove pause to continue reconciliation.")
@@ -133,6 +173,12 @@ func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 	controllerLog.V(5).Info(fmt.Sprintf("Found Metal3Machine %v", capm3MachineKey))
 
+	// The BareMetalHosts do not carry the watch-filter label, the host is
+	// left to the instance reconciling its Metal3Machine.
+	if r.WatchFilterValue != "" && !processIfLabelMatchOrShard(controllerLog, capm3Machine, r.WatchFilterValue, r.Shard) {
+		return ctrl.Result{}, nil
+	}
+
 	// Fetch the Machine.
 	capiMachine, err := util.GetOwnerMachine(ctx, r.Client, capm3Machine.ObjectMeta)
 	if err != nil {
@@ -161,6 +207,30 @@ func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 	controllerLog.V(5).Info(fmt.Sprintf("Found Cluster %v/%v", cluster.Name, cluster.Namespace))
 
+	if err := watchClusterNodes(ctx, r.Tracker, r.controller, "metal3labelsync-watchNodes", cluster, r.NodeToBareMetalHosts); err != nil {
+		if errors.Is(err, remote.ErrClusterLocked) {
+			controllerLog.V(5).Info("Requeuing because another worker has the lock on the ClusterCacheTracker")
+			return ctrl.Result{Requeue: true}, nil
+		}
+		// The tracker does not tell why the workload cluster is unreachable,
+		// the kubeconfig secret is checked to report it.
+		if _, kubeconfigErr := infraremote.RESTConfig(ctx, r.Client, cluster); kubeconfigErr != nil {
+			if _, ok := infraremote.AsKubeconfigError(kubeconfigErr); ok {
+				err = kubeconfigErr
+			}
+		}
+		unavailable, patchErr := r.setMachineKubeconfigCondition(ctx, capm3Machine, err)
+		if patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		if unavailable {
+			controllerLog.Info(fmt.Sprintf("Kubeconfig of the workload cluster unavailable, will retry: %v", err))
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+		controllerLog.Info(fmt.Sprintf("Error watching Nodes of the workload cluster, will retry: %v", err))
+		return ctrl.Result{RequeueAfter: requeueAfter}, err
+	}
+
 	// Fetch the Metal3 cluster.
 	metal3Cluster := &infrav1.Metal3Cluster{}
 	metal3ClusterName := types.NamespacedName{
@@ -184,7 +254,9 @@ func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, nil
 	}
 	prefixStr, ok := annotations[PrefixAnnotationKey]
-	if !ok {
+	reversePrefixStr, reverseOk := annotations[ReversePrefixAnnotationKey]
+	annotationSyncStr, annotationSyncOk := annotations[AnnotationSyncAnnotationKey]
+	if !ok && !reverseOk && !annotationSyncOk {
 		controllerLog.V(5).Info("No annotation for prefixes found on Metal3Cluster")
 		return ctrl.Result{}, nil
 	}
@@ -193,18 +265,45 @@ func (r *Metal3LabelSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	err = r.reconcileBMHLabels(ctx, host, capiMachine, cluster, prefixSet)
+	reversePrefixSet, err := parsePrefixAnnotation(reversePrefixStr)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	annotationSyncRules, err := parseAnnotationSyncAnnotation(annotationSyncStr)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for prefix := range reversePrefixSet {
+		if _, denied := reverseSyncDeniedPrefixes[prefix]; denied {
+			controllerLog.Info("Ignoring prefix managed by Kubernetes for reverse label sync", "prefix", prefix)
+			delete(reversePrefixSet, prefix)
+		}
+	}
+	err = r.reconcileBMHLabels(ctx, host, capiMachine, cluster, prefixSet, reversePrefixSet, annotationSyncRules)
+	// A missing or invalid kubeconfig is not a failure, the host is requeued,
+	// or reconciled when the kubeconfig secret changes.
+	unavailable, patchErr := r.setMachineKubeconfigCondition(ctx, capm3Machine, err)
+	if patchErr != nil {
+		return ctrl.Result{}, patchErr
+	}
+	if unavailable {
+		controllerLog.Info(fmt.Sprintf("Kubeconfig of the workload cluster unavailable, will retry: %v", err))
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
 	if err != nil {
 		controllerLog.Info(fmt.Sprintf("Error reconciling BMH labels to Node, will retry: %v", err))
 		return ctrl.Result{RequeueAfter: requeueAfter}, err
 	}
 	controllerLog.Info("Finished synchronizing labels between BaremetalHost and Node")
+	// The updates of the Node trigger a reconciliation when it is watched.
+	if r.Tracker != nil {
+		return ctrl.Result{}, nil
+	}
 	// Always requeue to ensure label sync runs periodically for each BareMetalHost. This is necessary to catch any label updates to the Node that are synchronized through the BareMetalHost.
 	return ctrl.Result{RequeueAfter: bmhSyncInterval}, nil
 }
 
-func (r *Metal3LabelSyncReconciler) reconcileBMHLabels(ctx context.Context, host *bmov1alpha1.BareMetalHost, machine *clusterv1.Machine, cluster *clusterv1.Cluster, prefixSet map[string]struct{}) error {
-	hostLabelSyncSet := buildLabelSyncSet(prefixSet, host.Labels)
+func (r *Metal3LabelSyncReconciler) reconcileBMHLabels(ctx context.Context, host *bmov1alpha1.BareMetalHost, machine *clusterv1.Machine, cluster *clusterv1.Cluster, prefixSet, reversePrefixSet map[string]struct{}, annotationSyncRules map[string]annotationSyncRule) error {
 	// Get the Node from the workload cluster
 	corev1Remote, err := r.CapiClientGetter(ctx, r.Client, cluster)
 	if err != nil {
@@ -214,8 +313,20 @@ func (r *Metal3LabelSyncReconciler) reconcileBMHLabels(ctx context.Context, host
 	if err != nil {
 		return err
 	}
+
+	// Synchronize the Node labels to the BareMetalHost first, the BareMetalHost
+	// is patched when the reconciliation ends.
+	nodeReverseSyncSet := buildLabelSyncSet(reversePrefixSet, node.Labels)
+	hostReverseSyncSet := buildLabelSyncSet(reversePrefixSet, host.Labels)
+	synchronizeLabelSyncSetsOnHost(r.Log.WithName(labelSyncControllerName), nodeReverseSyncSet, hostReverseSyncSet, prefixSet, host)
+
+	if len(prefixSet) == 0 && len(annotationSyncRules) == 0 {
+		return nil
+	}
+	hostLabelSyncSet := buildLabelSyncSet(prefixSet, host.Labels)
 	nodeLabelSyncSet := buildLabelSyncSet(prefixSet, node.Labels)
 	synchronizeLabelSyncSetsOnNode(hostLabelSyncSet, nodeLabelSyncSet, node)
+	synchronizeAnnotationsOnNode(r.Log.WithName(labelSyncControllerName), annotationSyncRules, host, node)
 	_, err = corev1Remote.Nodes().Update(ctx, node, metav1.UpdateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "unable to update the target node")
@@ -223,6 +334,25 @@ func (r *Metal3LabelSyncReconciler) reconcileBMHLabels(ctx context.Context, host
 	return nil
 }
 
+// setMachineKubeconfigCondition updates the
+// WorkloadClusterKubeconfigAvailableCondition of the Metal3Machine consuming
+// the host from err, the BareMetalHost having no conditions, and returns true
+// if the kubeconfig is unavailable.
+func (r *Metal3LabelSyncReconciler) setMachineKubeconfigCondition(ctx context.Context, capm3Machine *infrav1.Metal3Machine, err error) (bool, error) {
+	helper, patchErr := patch.NewHelper(capm3Machine, r.Client)
+	if patchErr != nil {
+		return false, errors.Wrap(patchErr, "failed to init patch helper")
+	}
+	unavailable := setKubeconfigCondition(capm3Machine, err)
+	patchErr = helper.Patch(ctx, capm3Machine, patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+		infrav1.WorkloadClusterKubeconfigAvailableCondition,
+	}})
+	if patchErr != nil {
+		return unavailable, errors.Wrap(patchErr, "failed to patch the Metal3Machine")
+	}
+	return unavailable, nil
+}
+
 func buildLabelSyncSet(prefixSet map[string]struct{}, labels map[string]string) map[string]string {
 	labelSyncSet := make(map[string]string)
 	for labelKey, labelVal := range labels {
@@ -256,23 +386,171 @@ func synchronizeLabelSyncSetsOnNode(hostLabelSyncSet, nodeLabelSyncSet map[strin
 	}
 }
 
+// synchronizeAnnotationsOnNode sets the labels of the Node from the
+// annotations of the BareMetalHost following the annotation sync rules. The
+// label of a rule is removed from the Node when the annotation is missing, or
+// when its value is not a valid label value and the rule does not sanitize it.
+func synchronizeAnnotationsOnNode(log logr.Logger, annotationSyncRules map[string]annotationSyncRule, host *bmov1alpha1.BareMetalHost, node *corev1.Node) {
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	for annotationKey, rule := range annotationSyncRules {
+		value, ok := host.Annotations[annotationKey]
+		if !ok {
+			delete(node.Labels, rule.labelKey)
+			continue
+		}
+		if rule.sanitize {
+			value = sanitizeLabelValue(value)
+		} else if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			log.Info("Annotation value is not a valid label value, removing the label from the Node",
+				"annotation", annotationKey, "label", rule.labelKey, "host", host.Name, "errors", errs)
+			delete(node.Labels, rule.labelKey)
+			continue
+		}
+		node.Labels[rule.labelKey] = value
+	}
+}
+
+// sanitizeLabelValue turns value into a valid label value: the characters
+// that are not alphanumeric, '-', '_' or '.' are replaced with '-', the value
+// is truncated to 63 characters and must start and end with an alphanumeric
+// character.
+func sanitizeLabelValue(value string) string {
+	value = invalidLabelValueCharsRegexp.ReplaceAllString(value, "-")
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(value, "-_.")
+}
+
+// synchronizeLabelSyncSetsOnHost copies the reverse synchronized labels of the
+// Node to the BareMetalHost. The labels of a prefix that is synchronized in both
+// directions are never removed from the BareMetalHost, and the value of the
+// BareMetalHost wins when both sides hold a different value. The other labels
+// are listed in the HostReverseSyncedLabelsAnnotation of the BareMetalHost, to
+// be removed when it is released.
+func synchronizeLabelSyncSetsOnHost(log logr.Logger, nodeLabelSyncSet, hostLabelSyncSet map[string]string, prefixSet map[string]struct{}, host *bmov1alpha1.BareMetalHost) {
+	if host.Labels == nil {
+		host.Labels = map[string]string{}
+	}
+	isForwardSynced := func(labelKey string) bool {
+		p, _ := k8strings.SplitQualifiedName(labelKey)
+		_, ok := prefixSet[p]
+		return ok
+	}
+	for labelKey := range hostLabelSyncSet {
+		if _, ok := nodeLabelSyncSet[labelKey]; !ok && !isForwardSynced(labelKey) {
+			delete(host.Labels, labelKey)
+		}
+	}
+	for labelKey, labelVal := range nodeLabelSyncSet {
+		val, ok := hostLabelSyncSet[labelKey]
+		if !ok {
+			host.Labels[labelKey] = labelVal
+			continue
+		}
+		if val == labelVal {
+			continue
+		}
+		if isForwardSynced(labelKey) {
+			log.Info("Conflicting label values on BareMetalHost and Node, keeping the value of the BareMetalHost",
+				"label", labelKey, "host", host.Name, "hostValue", val, "nodeValue", labelVal)
+			continue
+		}
+		host.Labels[labelKey] = labelVal
+	}
+
+	synced := []string{}
+	for labelKey := range nodeLabelSyncSet {
+		if !isForwardSynced(labelKey) {
+			synced = append(synced, labelKey)
+		}
+	}
+	if len(synced) == 0 {
+		delete(host.Annotations, infrav1.HostReverseSyncedLabelsAnnotation)
+		return
+	}
+	sort.Strings(synced)
+	if host.Annotations == nil {
+		host.Annotations = map[string]string{}
+	}
+	host.Annotations[infrav1.HostReverseSyncedLabelsAnnotation] = strings.Join(synced, ",")
+}
+
 // SetupWithManager will add watches for this controller.
 func (r *Metal3LabelSyncReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&bmov1alpha1.BareMetalHost{}).
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(
+			&bmov1alpha1.BareMetalHost{},
+			builder.WithPredicates(ResourceNotPausedAndInShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)),
+		).
 		WithOptions(options).
 		Watches(
 			&infrav1.Metal3Cluster{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3ClusterToBareMetalHosts),
+			builder.WithPredicates(ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.KubeconfigSecretToBareMetalHosts),
+			builder.WithPredicates(
+				kubeconfigSecretPredicate(),
+				ResourceNotPausedAndInShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard),
+			),
 		).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
+}
+
+// NodeToBareMetalHosts is a handler.ToRequestsFunc to be used to enqeue
+// requests for reconciliation of the BareMetalHost of a workload cluster Node.
+func (r *Metal3LabelSyncReconciler) NodeToBareMetalHosts(ctx context.Context, o client.Object) []ctrl.Request {
+	machineKey, ok := nodeToMachineKey(o)
+	if !ok {
+		return nil
+	}
+	log := r.Log.WithValues("NodeToBareMetalHosts", o.GetName(), "machine", machineKey)
+	capiMachine := &clusterv1.Machine{}
+	if err := r.Client.Get(ctx, machineKey, capiMachine); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get Machine")
+		}
+		return nil
+	}
+	if capiMachine.Spec.InfrastructureRef.Name == "" {
+		return nil
+	}
+	name := client.ObjectKey{Namespace: capiMachine.Namespace, Name: capiMachine.Spec.InfrastructureRef.Name}
+	if capiMachine.Spec.InfrastructureRef.Namespace != "" {
+		name.Namespace = capiMachine.Spec.InfrastructureRef.Namespace
+	}
+	capm3Machine := &infrav1.Metal3Machine{}
+	if err := r.Client.Get(ctx, name, capm3Machine); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get Metal3Machine")
+		}
+		return nil
+	}
+	hostKey, ok := capm3Machine.GetAnnotations()[baremetal.HostAnnotation]
+	if !ok {
+		return nil
+	}
+	hostNamespace, hostName, err := cache.SplitMetaNamespaceKey(hostKey)
+	if err != nil {
+		log.Error(err, "could not parse host annotation")
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: hostNamespace, Name: hostName}}}
 }
 
 // Metal3ClusterToBareMetalHosts is a handler.ToRequestsFunc to be used to enqeue
 // requests for reconciliation of BareMetalHosts' label updates.
 func (r *Metal3LabelSyncReconciler) Metal3ClusterToBareMetalHosts(ctx context.Context, o client.Object) []ctrl.Request {
-	result := []ctrl.Request{}
 	c, ok := o.(*infrav1.Metal3Cluster)
 	if !ok {
 		r.Log.Error(errors.Errorf("expected a Metal3Cluster but got a %T", o),
@@ -289,10 +567,29 @@ func (r *Metal3LabelSyncReconciler) Metal3ClusterToBareMetalHosts(ctx context.Co
 		log.Error(err, "failed to get owning cluster")
 		return nil
 	}
+	return r.clusterToBareMetalHosts(ctx, log, cluster)
+}
+
+// KubeconfigSecretToBareMetalHosts is a handler.ToRequestsFunc to be used to
+// enqeue requests for reconciliation of the BareMetalHosts of a Cluster when
+// its kubeconfig secret changes, so that they are retried promptly once the
+// kubeconfig is fixed.
+func (r *Metal3LabelSyncReconciler) KubeconfigSecretToBareMetalHosts(ctx context.Context, o client.Object) []ctrl.Request {
+	cluster, ok := kubeconfigSecretCluster(o)
+	if !ok {
+		return nil
+	}
+	log := r.Log.WithValues("KubeconfigSecretToBareMetalHosts", o.GetName(), "Namespace", o.GetNamespace())
+	return r.clusterToBareMetalHosts(ctx, log, cluster)
+}
 
+// clusterToBareMetalHosts returns the requests for the BareMetalHosts consumed
+// by the Metal3Machines of the Cluster.
+func (r *Metal3LabelSyncReconciler) clusterToBareMetalHosts(ctx context.Context, log logr.Logger, cluster *clusterv1.Cluster) []ctrl.Request {
+	result := []ctrl.Request{}
 	labels := map[string]string{clusterv1.ClusterNameLabel: cluster.Name}
 	capiMachineList := &clusterv1.MachineList{}
-	if err := r.Client.List(ctx, capiMachineList, client.InNamespace(c.Namespace), client.MatchingLabels(labels)); err != nil {
+	if err := r.Client.List(ctx, capiMachineList, client.InNamespace(cluster.Namespace), client.MatchingLabels(labels)); err != nil {
 		log.Error(err, "failed to list Machines")
 		return nil
 	}
@@ -352,6 +649,62 @@ func parsePrefixAnnotation(prefixStr string) (map[string]struct{}, error) {
 	return prefixSet, nil
 }
 
+// annotationSyncRule maps an annotation of the BareMetalHost to a label of the
+// Node.
+type annotationSyncRule struct {
+	labelKey string
+	sanitize bool
+}
+
+// parseAnnotationSyncAnnotation parses a string for annotation sync rules. The
+// string must be in the format:
+// `annotation-1=label-1[:sanitize],annotation-2=label-2[:sanitize],...`
+// and each annotation and label key must be a valid qualified name. A label can
+// be the target of a single annotation.
+func parseAnnotationSyncAnnotation(rulesStr string) (map[string]annotationSyncRule, error) {
+	rules := make(map[string]annotationSyncRule)
+	labelKeys := make(map[string]struct{})
+	for _, entry := range strings.Split(rulesStr, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			// ignore empty rule string (e.g. `, ,`)
+			continue
+		}
+		annotationKey, target, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid annotation sync rule (%v): expected <annotation>=<label>", entry)
+		}
+		annotationKey = strings.TrimSpace(annotationKey)
+		labelKey, transformation, _ := strings.Cut(target, ":")
+		labelKey = strings.TrimSpace(labelKey)
+		rule := annotationSyncRule{labelKey: labelKey}
+		switch strings.TrimSpace(transformation) {
+		case "":
+		case SanitizeTransformation:
+			rule.sanitize = true
+		default:
+			return nil, fmt.Errorf("invalid annotation sync rule (%v): unknown transformation %q", entry, transformation)
+		}
+		if errs := validation.IsQualifiedName(annotationKey); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid annotation (%v): %v", annotationKey, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsQualifiedName(labelKey); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label (%v): %v", labelKey, strings.Join(errs, "; "))
+		}
+		if _, ok := rules[annotationKey]; ok {
+			return nil, fmt.Errorf("duplicate annotation (%v) in annotation sync rules", annotationKey)
+		}
+		if _, ok := labelKeys[labelKey]; ok {
+			return nil, fmt.Errorf("duplicate label (%v) in annotation sync rules", labelKey)
+		}
+		rules[annotationKey] = rule
+		labelKeys[labelKey] = struct{}{}
+	}
+	return rules, nil
+}
+
+var invalidLabelValueCharsRegexp = regexp.MustCompile("[^-A-Za-z0-9_.]")
+
 // The following code is also used by kubectl for label and prefix validation.
 // Reference: https://github.com/kubernetes/apimachinery/blob/master/pkg/util/validation/validation.go
 const dns1123LabelFmt string = "[a-z0-9]([-a-z0-9]*[a-z0-9])?"
@@ -19,20 +19,28 @@ package controllers
 import (
 	"context"
 	"reflect"
+	"strings"
 
 	"github.com/go-logr/logr"
 
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientfake "k8s.io/client-go/kubernetes/fake"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	clienttesting "k8s.io/client-go/testing"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -166,6 +174,69 @@ var _ = Describe("Metal3LabelSync controller", func() {
 		}),
 	)
 
+	type TestCaseParseAnnotationSyncAnnotation struct {
+		RulesStr       string
+		ExpectedErr    bool
+		ExpectedResult map[string]annotationSyncRule
+	}
+
+	DescribeTable("Parse Annotation Sync Annotation",
+		func(tc TestCaseParseAnnotationSyncAnnotation) {
+			rules, err := parseAnnotationSyncAnnotation(tc.RulesStr)
+			if tc.ExpectedErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rules).To(Equal(tc.ExpectedResult))
+			}
+		},
+		Entry("Parse single rule", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr: "inventory.example.com/serial=example.com/serial",
+			ExpectedResult: map[string]annotationSyncRule{
+				"inventory.example.com/serial": {labelKey: "example.com/serial"},
+			},
+		}),
+		Entry("Parse multiple rules with transformation", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr: "inventory.example.com/serial = example.com/serial : sanitize, ,rack=example.com/rack",
+			ExpectedResult: map[string]annotationSyncRule{
+				"inventory.example.com/serial": {labelKey: "example.com/serial", sanitize: true},
+				"rack":                         {labelKey: "example.com/rack"},
+			},
+		}),
+		Entry("Parse empty rules string with commas", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr:       ",, ,,",
+			ExpectedResult: map[string]annotationSyncRule{},
+		}),
+		Entry("Missing label", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr:    "inventory.example.com/serial",
+			ExpectedErr: true,
+		}),
+		Entry("Unknown transformation", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr:    "inventory.example.com/serial=example.com/serial:uppercase",
+			ExpectedErr: true,
+		}),
+		Entry("Invalid label", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr:    "inventory.example.com/serial=example.com/serial number",
+			ExpectedErr: true,
+		}),
+		Entry("Duplicate label", TestCaseParseAnnotationSyncAnnotation{
+			RulesStr:    "serial=example.com/serial,sn=example.com/serial",
+			ExpectedErr: true,
+		}),
+	)
+
+	DescribeTable("Sanitize Label Value",
+		func(value, expected string) {
+			Expect(sanitizeLabelValue(value)).To(Equal(expected))
+			Expect(validation.IsValidLabelValue(sanitizeLabelValue(value))).To(BeEmpty())
+		},
+		Entry("Valid value", "SN-1234_a.b", "SN-1234_a.b"),
+		Entry("Spaces and slashes", "Rack 12/RU 3", "Rack-12-RU-3"),
+		Entry("Leading and trailing invalid characters", " /dc1/ ", "dc1"),
+		Entry("Too long", strings.Repeat("a", 62)+" b c", strings.Repeat("a", 62)),
+		Entry("Empty", "", ""),
+	)
+
 	type TestCaseSynchronizeLabelSyncSetsOnNode struct {
 		PrefixSet      map[string]struct{}
 		Host           *bmov1alpha1.BareMetalHost
@@ -383,6 +454,10 @@ var _ = Describe("Metal3LabelSync controller", func() {
 			expectRequeue   bool
 			expectLabelsync map[string]string
 			debug           bool
+			// kubeconfigError is returned by the ClientGetter.
+			kubeconfigError        error
+			nodeUnauthorized       bool
+			expectKubeconfigReason string
 		}
 		DescribeTable("Test reconcile",
 
@@ -405,10 +480,16 @@ var _ = Describe("Metal3LabelSync controller", func() {
 					objects = append(objects, tc.metal3Machine)
 				}
 
-				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
-				corev1Client := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
+				clientset := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
 					Name: nodeName,
-				}}).CoreV1()
+				}})
+				if tc.nodeUnauthorized {
+					clientset.PrependReactor("get", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+						return true, nil, apierrors.NewUnauthorized("invalid credentials")
+					})
+				}
+				corev1Client := clientset.CoreV1()
 				r := &Metal3LabelSyncReconciler{
 					Client:         fakeClient,
 					ManagerFactory: baremetal.NewManagerFactory(fakeClient),
@@ -416,6 +497,9 @@ var _ = Describe("Metal3LabelSync controller", func() {
 					CapiClientGetter: func(ctx context.Context, client client.Client, cluster *clusterv1.Cluster) (
 						clientcorev1.CoreV1Interface, error,
 					) {
+						if tc.kubeconfigError != nil {
+							return nil, tc.kubeconfigError
+						}
 						return corev1Client, nil
 					},
 					WatchFilterValue: "",
@@ -439,8 +523,25 @@ var _ = Describe("Metal3LabelSync controller", func() {
 					Expect(result.Requeue || result.RequeueAfter > 0).To(BeFalse())
 				}
 
-				node, _ := corev1Client.Nodes().Get(context.TODO(), "testNode", metav1.GetOptions{})
-				Expect(node.Labels).To(Equal(tc.expectLabelsync))
+				if !tc.nodeUnauthorized {
+					node, _ := corev1Client.Nodes().Get(context.TODO(), "testNode", metav1.GetOptions{})
+					Expect(node.Labels).To(Equal(tc.expectLabelsync))
+				}
+
+				// The condition is set on the Metal3Machine, the BareMetalHost
+				// has no conditions.
+				if tc.metal3Machine != nil {
+					m3m := &infrav1.Metal3Machine{}
+					Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(tc.metal3Machine), m3m)).To(Succeed())
+					if tc.expectKubeconfigReason != "" {
+						condition := conditions.Get(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)
+						Expect(condition).NotTo(BeNil())
+						Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+						Expect(condition.Reason).To(Equal(tc.expectKubeconfigReason))
+					} else {
+						Expect(conditions.IsFalse(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(BeFalse())
+					}
+				}
 			},
 			Entry("Baremetal host not found", testCaseReconcile{
 				expectError:   false,
@@ -520,6 +621,57 @@ var _ = Describe("Metal3LabelSync controller", func() {
 					"foo.metal3.io/bar": "blue",
 				},
 			}),
+			Entry("Annotation sync only", testCaseReconcile{
+				host: func() *bmov1alpha1.BareMetalHost {
+					host := newBareMetalHost(baremetalhostName, &metal3MachineSpec, nil, Labels, false)
+					host.Annotations = map[string]string{"inventory.example.com/location": "DC 1/Rack 12"}
+					return host
+				}(),
+				machine:       newMachine(clusterName, machineName, metal3machineName, nodeName),
+				metal3Machine: newMetal3Machine(metal3machineName, m3mObjectMetaWithOwnerRef(), nil, nil, false),
+				cluster:       newCluster(clusterName, nil, nil),
+				metal3Cluster: newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, map[string]string{
+					AnnotationSyncAnnotationKey: "inventory.example.com/location=example.com/location:sanitize",
+				}, false),
+				expectRequeue: true,
+				expectLabelsync: map[string]string{
+					"example.com/location": "DC-1-Rack-12",
+				},
+			}),
+			Entry("Kubeconfig not found", testCaseReconcile{
+				host:          newBareMetalHost(baremetalhostName, &metal3MachineSpec, nil, Labels, false),
+				machine:       newMachine(clusterName, machineName, metal3machineName, nodeName),
+				metal3Machine: newMetal3Machine(metal3machineName, m3mObjectMetaWithOwnerRef(), nil, nil, false),
+				cluster:       newCluster(clusterName, nil, nil),
+				metal3Cluster: newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, annotation, false),
+				kubeconfigError: &infraremote.KubeconfigError{
+					Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("secret not found"),
+				},
+				expectRequeue:          true,
+				expectKubeconfigReason: infrav1.KubeconfigNotFoundReason,
+			}),
+			Entry("Kubeconfig invalid", testCaseReconcile{
+				host:          newBareMetalHost(baremetalhostName, &metal3MachineSpec, nil, Labels, false),
+				machine:       newMachine(clusterName, machineName, metal3machineName, nodeName),
+				metal3Machine: newMetal3Machine(metal3machineName, m3mObjectMetaWithOwnerRef(), nil, nil, false),
+				cluster:       newCluster(clusterName, nil, nil),
+				metal3Cluster: newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, annotation, false),
+				kubeconfigError: &infraremote.KubeconfigError{
+					Reason: infrav1.KubeconfigInvalidReason, Err: errors.New("invalid kubeconfig"),
+				},
+				expectRequeue:          true,
+				expectKubeconfigReason: infrav1.KubeconfigInvalidReason,
+			}),
+			Entry("Kubeconfig unauthorized", testCaseReconcile{
+				host:                   newBareMetalHost(baremetalhostName, &metal3MachineSpec, nil, Labels, false),
+				machine:                newMachine(clusterName, machineName, metal3machineName, nodeName),
+				metal3Machine:          newMetal3Machine(metal3machineName, m3mObjectMetaWithOwnerRef(), nil, nil, false),
+				cluster:                newCluster(clusterName, nil, nil),
+				metal3Cluster:          newMetal3Cluster(metal3ClusterName, bmcOwnerRef(), bmcSpec(), nil, annotation, false),
+				nodeUnauthorized:       true,
+				expectRequeue:          true,
+				expectKubeconfigReason: infrav1.KubeconfigUnauthorizedReason,
+			}),
 		)
 		type TestCaseReconcileBMHLabels struct {
 			PrefixSet   map[string]struct{}
@@ -552,7 +704,7 @@ var _ = Describe("Metal3LabelSync controller", func() {
 					WatchFilterValue: "",
 				}
 				err := r.reconcileBMHLabels(context.TODO(),
-					tc.Host, tc.Machine, tc.Cluster, tc.PrefixSet)
+					tc.Host, tc.Machine, tc.Cluster, tc.PrefixSet, nil, nil)
 
 				if tc.ExpectError {
 					Expect(err).To(HaveOccurred())
@@ -569,6 +721,239 @@ var _ = Describe("Metal3LabelSync controller", func() {
 				Cluster: newCluster(clusterName, nil, nil),
 			}),
 		)
+
+		type TestCaseReverseLabelSync struct {
+			PrefixSet          map[string]struct{}
+			ReversePrefixSet   map[string]struct{}
+			HostLabels         map[string]string
+			NodeLabels         map[string]string
+			ExpectedHostLabels map[string]string
+			ExpectedNodeLabels map[string]string
+			// ExpectedSynced is the list of the labels copied from the Node
+			// recorded on the BareMetalHost.
+			ExpectedSynced string
+		}
+
+		DescribeTable("Test reverse label sync",
+			func(tc TestCaseReverseLabelSync) {
+				host := newBareMetalHost(baremetalhostName, nil, nil, tc.HostLabels, false)
+				machine := newMachine(clusterName, machineName, metal3machineName, nodeName)
+				cluster := newCluster(clusterName, nil, nil)
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(host, machine, cluster).Build()
+				corev1Client := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   nodeName,
+					Labels: tc.NodeLabels,
+				}}).CoreV1()
+				r := &Metal3LabelSyncReconciler{
+					Client:         fakeClient,
+					ManagerFactory: baremetal.NewManagerFactory(fakeClient),
+					Log:            logr.Discard(),
+					CapiClientGetter: func(ctx context.Context, client client.Client, cluster *clusterv1.Cluster) (
+						clientcorev1.CoreV1Interface, error,
+					) {
+						return corev1Client, nil
+					},
+				}
+				err := r.reconcileBMHLabels(context.TODO(), host, machine, cluster, tc.PrefixSet, tc.ReversePrefixSet, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(host.Labels).To(Equal(tc.ExpectedHostLabels))
+				Expect(host.Annotations[infrav1.HostReverseSyncedLabelsAnnotation]).To(Equal(tc.ExpectedSynced))
+				node, err := corev1Client.Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(node.Labels).To(Equal(tc.ExpectedNodeLabels))
+			},
+			Entry("Add label on BareMetalHost", TestCaseReverseLabelSync{
+				ReversePrefixSet: map[string]struct{}{
+					"topology.kubernetes.io": {},
+				},
+				HostLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+				},
+				NodeLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-a",
+					"kubernetes.io/hostname":      "node-0",
+				},
+				ExpectedHostLabels: map[string]string{
+					"foo.metal3.io/bar":           "blue",
+					"topology.kubernetes.io/zone": "zone-a",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-a",
+					"kubernetes.io/hostname":      "node-0",
+				},
+				ExpectedSynced: "topology.kubernetes.io/zone",
+			}),
+			Entry("Update label on BareMetalHost", TestCaseReverseLabelSync{
+				ReversePrefixSet: map[string]struct{}{
+					"topology.kubernetes.io": {},
+				},
+				HostLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-a",
+				},
+				NodeLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-b",
+				},
+				ExpectedHostLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-b",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-b",
+				},
+				ExpectedSynced: "topology.kubernetes.io/zone",
+			}),
+			Entry("Delete label from BareMetalHost", TestCaseReverseLabelSync{
+				ReversePrefixSet: map[string]struct{}{
+					"topology.kubernetes.io": {},
+				},
+				HostLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-a",
+					"foo.metal3.io/bar":           "blue",
+				},
+				NodeLabels: map[string]string{},
+				ExpectedHostLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+				},
+				ExpectedNodeLabels: map[string]string{},
+			}),
+			Entry("Sync both directions", TestCaseReverseLabelSync{
+				PrefixSet: map[string]struct{}{
+					"foo.metal3.io": {},
+				},
+				ReversePrefixSet: map[string]struct{}{
+					"topology.kubernetes.io": {},
+				},
+				HostLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+				},
+				NodeLabels: map[string]string{
+					"topology.kubernetes.io/zone": "zone-a",
+				},
+				ExpectedHostLabels: map[string]string{
+					"foo.metal3.io/bar":           "blue",
+					"topology.kubernetes.io/zone": "zone-a",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"foo.metal3.io/bar":           "blue",
+					"topology.kubernetes.io/zone": "zone-a",
+				},
+				ExpectedSynced: "topology.kubernetes.io/zone",
+			}),
+			Entry("Conflict on a prefix synced both ways, BareMetalHost wins", TestCaseReverseLabelSync{
+				PrefixSet: map[string]struct{}{
+					"foo.metal3.io": {},
+				},
+				ReversePrefixSet: map[string]struct{}{
+					"foo.metal3.io": {},
+				},
+				HostLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+				},
+				NodeLabels: map[string]string{
+					"foo.metal3.io/bar": "red",
+					"foo.metal3.io/car": "green",
+				},
+				ExpectedHostLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+					"foo.metal3.io/car": "green",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"foo.metal3.io/bar": "blue",
+					"foo.metal3.io/car": "green",
+				},
+			}),
+		)
+
+		type TestCaseAnnotationSync struct {
+			Rules              map[string]annotationSyncRule
+			HostAnnotations    map[string]string
+			NodeLabels         map[string]string
+			ExpectedNodeLabels map[string]string
+		}
+
+		DescribeTable("Test annotation sync",
+			func(tc TestCaseAnnotationSync) {
+				host := newBareMetalHost(baremetalhostName, nil, nil, nil, false)
+				host.Annotations = tc.HostAnnotations
+				machine := newMachine(clusterName, machineName, metal3machineName, nodeName)
+				cluster := newCluster(clusterName, nil, nil)
+				fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(host, machine, cluster).Build()
+				corev1Client := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   nodeName,
+					Labels: tc.NodeLabels,
+				}}).CoreV1()
+				r := &Metal3LabelSyncReconciler{
+					Client:         fakeClient,
+					ManagerFactory: baremetal.NewManagerFactory(fakeClient),
+					Log:            logr.Discard(),
+					CapiClientGetter: func(ctx context.Context, client client.Client, cluster *clusterv1.Cluster) (
+						clientcorev1.CoreV1Interface, error,
+					) {
+						return corev1Client, nil
+					},
+				}
+				err := r.reconcileBMHLabels(context.TODO(), host, machine, cluster, nil, nil, tc.Rules)
+				Expect(err).NotTo(HaveOccurred())
+
+				node, err := corev1Client.Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(node.Labels).To(Equal(tc.ExpectedNodeLabels))
+			},
+			Entry("Add sanitized label on Node", TestCaseAnnotationSync{
+				Rules: map[string]annotationSyncRule{
+					"inventory.example.com/location": {labelKey: "example.com/location", sanitize: true},
+					"inventory.example.com/serial":   {labelKey: "example.com/serial"},
+				},
+				HostAnnotations: map[string]string{
+					"inventory.example.com/location": "DC 1/Rack 12/RU 3",
+					"inventory.example.com/serial":   "SN1234",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"example.com/location": "DC-1-Rack-12-RU-3",
+					"example.com/serial":   "SN1234",
+				},
+			}),
+			Entry("Update label on Node", TestCaseAnnotationSync{
+				Rules: map[string]annotationSyncRule{
+					"inventory.example.com/serial": {labelKey: "example.com/serial"},
+				},
+				HostAnnotations: map[string]string{
+					"inventory.example.com/serial": "SN5678",
+				},
+				NodeLabels: map[string]string{
+					"example.com/serial":     "SN1234",
+					"kubernetes.io/hostname": "node-0",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"example.com/serial":     "SN5678",
+					"kubernetes.io/hostname": "node-0",
+				},
+			}),
+			Entry("Remove label from Node when the annotation is removed", TestCaseAnnotationSync{
+				Rules: map[string]annotationSyncRule{
+					"inventory.example.com/serial": {labelKey: "example.com/serial"},
+				},
+				NodeLabels: map[string]string{
+					"example.com/serial":     "SN1234",
+					"kubernetes.io/hostname": "node-0",
+				},
+				ExpectedNodeLabels: map[string]string{
+					"kubernetes.io/hostname": "node-0",
+				},
+			}),
+			Entry("Remove label from Node when the value is invalid", TestCaseAnnotationSync{
+				Rules: map[string]annotationSyncRule{
+					"inventory.example.com/location": {labelKey: "example.com/location"},
+				},
+				HostAnnotations: map[string]string{
+					"inventory.example.com/location": "DC 1/Rack 12",
+				},
+				NodeLabels: map[string]string{
+					"example.com/location": "DC-1-Rack-11",
+				},
+				ExpectedNodeLabels: map[string]string{},
+			}),
+		)
 	})
 })
 
@@ -18,28 +18,38 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
-	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
@@ -53,6 +63,21 @@ type Metal3MachineReconciler struct {
 	Log              logr.Logger
 	CapiClientGetter baremetal.ClientGetter
 	WatchFilterValue string
+	Shard            ShardOptions
+	// Tracker, when set, is used to watch the Nodes of the workload clusters.
+	Tracker *remote.ClusterCacheTracker
+	// ProvisioningEstimator, when set, is used to estimate the time at which
+	// the provisioning Metal3Machines will be ready.
+	ProvisioningEstimator *baremetal.ProvisioningEstimator
+	// HostCluster, when set, is the cluster in which the BareMetalHosts are
+	// registered by default, whose BareMetalHosts are watched as well.
+	HostCluster cluster.Cluster
+	// HostClusters, when set, are the clusters in which the BareMetalHosts of
+	// the Metal3Clusters referencing a kubeconfig secret are registered, whose
+	// BareMetalHosts are watched as well.
+	HostClusters *infraremote.HostClusterClients
+
+	controller controller.Controller
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machines,verbs=get;list;watch;create;update;patch;delete
@@ -62,20 +87,23 @@ type Metal3MachineReconciler struct {
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datas,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3datas/status,verbs=get
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machinetemplates,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinedeployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
 // Add RBAC rules to access cluster-api resources
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings,verbs=get;list;watch;create;update;patch
 
 // Reconcile handles Metal3Machine events.
 func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	machineLog := r.Log.WithName(machineControllerName).WithValues("metal3-machine", req.NamespacedName)
+	defer baremetal.LogDuration(machineLog, "reconcile", time.Now())
 
 	// Fetch the Metal3Machine instance.
 	capm3Machine := &infrav1.Metal3Machine{}
@@ -86,6 +114,13 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 		return ctrl.Result{}, err
 	}
+
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(capm3Machine, r.WatchFilterValue, r.Shard) {
+		machineLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
 	// Always patch capm3Machine exiting this function so we can persist any Metal3Machine changes.
 	patchHelper, err := patch.NewHelper(capm3Machine, r.Client)
 	if err != nil {
@@ -96,8 +131,18 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			machineLog.Error(err, "failed to Patch metal3Machine")
 		}
 	}()
-	// clear an error if one was previously set
-	clearErrorM3Machine(capm3Machine)
+
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(capm3Machine, r.WatchFilterValue, r.Shard) {
+		machineLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+	// clear an error if one was previously set, unless the BareMetalHost of
+	// the Metal3Machine was deleted: it then stays failed until its Machine
+	// is deleted.
+	hostDeleted := conditions.GetReason(capm3Machine, infrav1.AssociateBMHCondition) == infrav1.HostDeletedReason
+	if !hostDeleted {
+		clearErrorM3Machine(capm3Machine)
+	}
 
 	// Fetch the Machine.
 	capiMachine, err := util.GetOwnerMachine(ctx, r.Client, capm3Machine.ObjectMeta)
@@ -113,6 +158,15 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	machineLog = machineLog.WithValues("machine", capiMachine.Name)
 
+	// Clear the failure fields set by older versions if requested by the user.
+	if _, ok := capm3Machine.Annotations[infrav1.ClearFailureAnnotation]; ok {
+		if err := r.clearMachineFailure(ctx, capiMachine); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to clear failure on Machine")
+		}
+		delete(capm3Machine.Annotations, infrav1.ClearFailureAnnotation)
+		machineLog.Info("Cleared failure fields on Metal3Machine and Machine")
+	}
+
 	// Fetch the Cluster.
 	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, capiMachine.ObjectMeta)
 	if err != nil {
@@ -123,6 +177,16 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	machineLog = machineLog.WithValues("cluster", cluster.Name)
 
+	// Watch the Nodes of the workload cluster, so that the providerID is set
+	// as soon as the Node is registered.
+	if err := watchClusterNodes(ctx, r.Tracker, r.controller, "metal3machine-watchNodes", cluster, r.NodeToMetal3Machines); err != nil {
+		if errors.Is(err, remote.ErrClusterLocked) {
+			machineLog.V(5).Info("Requeuing because another worker has the lock on the ClusterCacheTracker")
+			return ctrl.Result{Requeue: true}, nil
+		}
+		machineLog.Error(err, "failed to watch Nodes of the workload cluster")
+	}
+
 	// Make sure infrastructure is ready
 	if !cluster.Status.InfrastructureReady {
 		machineLog.Info("Waiting for Metal3Cluster Controller to create cluster infrastructure")
@@ -150,12 +214,14 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the machineMgr")
 	}
 
-	// Check pause annotation on associated bmh (if any)
-	if !cluster.Spec.Paused {
+	// Propagate the paused state of the Cluster or the M3Machine to the
+	// associated bmh (if any), so that it is not mutated during a move.
+	isPaused := annotations.IsPaused(cluster, capm3Machine)
+	if !isPaused {
 		err := machineMgr.RemovePauseAnnotation(ctx)
 		if err != nil {
 			machineLog.Info("failed to check pause annotation on associated bmh")
-			conditions.MarkFalse(capm3Machine, infrav1.AssociateBMHCondition, infrav1.PauseAnnotationRemoveFailedReason, clusterv1.ConditionSeverityInfo, "")
+			conditions.MarkFalse(capm3Machine, infrav1.AssociateBMHCondition, infrav1.PauseAnnotationRemoveFailedReason, clusterv1.ConditionSeverityWarning, "")
 			return ctrl.Result{}, nil
 		}
 	} else {
@@ -163,36 +229,70 @@ func (r *Metal3MachineReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		err := machineMgr.SetPauseAnnotation(ctx)
 		if err != nil {
 			machineLog.Info("failed to set pause annotation on associated bmh")
-			conditions.MarkFalse(capm3Machine, infrav1.AssociateBMHCondition, infrav1.PauseAnnotationSetFailedReason, clusterv1.ConditionSeverityInfo, "")
+			conditions.MarkFalse(capm3Machine, infrav1.AssociateBMHCondition, infrav1.PauseAnnotationSetFailedReason, clusterv1.ConditionSeverityWarning, "")
 			return ctrl.Result{}, nil
 		}
 	}
 
-	// Return early if the M3Machine or Cluster is paused.
-	if annotations.IsPaused(cluster, capm3Machine) {
+	// Return early if the M3Machine or Cluster is paused. The CAPM3 paused
+	// annotation freezes the M3Machine only, it is not propagated to the bmh.
+	isPaused = isPaused || hasPausedAnnotation(capm3Machine)
+	setPausedCondition(capm3Machine, isPaused)
+	if isPaused {
 		machineLog.Info("reconciliation is paused for this object")
 		conditions.MarkFalse(capm3Machine, infrav1.AssociateBMHCondition, infrav1.Metal3MachinePausedReason, clusterv1.ConditionSeverityInfo, "")
 		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
 	}
 
+	// Estimate when the machine will be ready, and record the provisioning
+	// duration once it is, before patching the Metal3Machine.
+	if r.ProvisioningEstimator != nil {
+		wasReady := capm3Machine.Status.Ready
+		defer func() {
+			if err := r.ProvisioningEstimator.UpdateEstimatedReadyTime(ctx,
+				client.ObjectKeyFromObject(cluster), capm3Machine, wasReady,
+			); err != nil {
+				machineLog.Error(err, "failed to update the estimated ready time")
+			}
+		}()
+	}
+
+	// Track the use of the kubeconfig of the workload cluster.
+	kubeconfig := &kubeconfigCondition{obj: capm3Machine, getter: r.CapiClientGetter}
+
 	// Handle deleted machines
 	if !capm3Machine.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, machineMgr)
+		return r.reconcileDelete(ctx, machineMgr, kubeconfig)
+	}
+
+	// A Metal3Machine whose BareMetalHost was deleted is left failed.
+	if hostDeleted {
+		machineLog.Info("BareMetalHost of the Metal3Machine was deleted, waiting for the Machine to be deleted")
+		return ctrl.Result{}, nil
 	}
 
 	// Handle non-deleted machines
-	return r.reconcileNormal(ctx, machineMgr)
+	return r.reconcileNormal(ctx, machineMgr, kubeconfig)
 }
 
-func patchMetal3Machine(ctx context.Context, patchHelper *patch.Helper, metal3Machine *infrav1.Metal3Machine, options ...patch.Option) error {
-	// Always update the readyCondition by summarizing the state of other conditions.
+// setReadyCondition sets the Ready condition of the Metal3Machine, mirrored
+// by the InfrastructureReady condition of its Machine, to the summary of the
+// conditions of the provisioning steps. While not ready, it gives the reason
+// and message of the most severe blocking step, the earliest one on a tie.
+func setReadyCondition(metal3Machine *infrav1.Metal3Machine) {
 	conditions.SetSummary(metal3Machine,
 		conditions.WithConditions(
 			infrav1.AssociateBMHCondition,
 			infrav1.Metal3DataReadyCondition,
+			infrav1.BareMetalHostProvisionedCondition,
 			infrav1.KubernetesNodeReadyCondition,
 		),
 	)
+}
+
+func patchMetal3Machine(ctx context.Context, patchHelper *patch.Helper, metal3Machine *infrav1.Metal3Machine, options ...patch.Option) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	setReadyCondition(metal3Machine)
 
 	// Patch the object, ignoring conflicts on the conditions owned by this controller.
 	options = append(options,
@@ -200,7 +300,21 @@ func patchMetal3Machine(ctx context.Context, patchHelper *patch.Helper, metal3Ma
 			clusterv1.ReadyCondition,
 			infrav1.AssociateBMHCondition,
 			infrav1.Metal3DataReadyCondition,
+			infrav1.BareMetalHostProvisionedCondition,
 			infrav1.KubernetesNodeReadyCondition,
+			infrav1.PausedCondition,
+			infrav1.ProviderIDFormatMatchedCondition,
+			infrav1.BootstrapSkippedCondition,
+			infrav1.NodeDrainedCondition,
+			infrav1.PoweredOffCondition,
+			infrav1.HostAttachedCondition,
+			infrav1.ProvidedDataValidCondition,
+			infrav1.BootstrapFormatMatchedCondition,
+			infrav1.PolicyCompliantCondition,
+			infrav1.UserDataAppendedCondition,
+			infrav1.FirmwareSettingsValidCondition,
+			infrav1.WorkloadClusterKubeconfigAvailableCondition,
+			infrav1.WorkloadClusterReachableCondition,
 		}},
 		patch.WithStatusObservedGeneration{},
 	)
@@ -208,22 +322,70 @@ func patchMetal3Machine(ctx context.Context, patchHelper *patch.Helper, metal3Ma
 }
 
 func (r *Metal3MachineReconciler) reconcileNormal(ctx context.Context,
-	machineMgr baremetal.MachineManagerInterface,
+	machineMgr baremetal.MachineManagerInterface, kubeconfig *kubeconfigCondition,
 ) (ctrl.Result, error) {
 	// If the Metal3Machine doesn't have finalizer, add it.
 	machineMgr.SetFinalizer()
 
+	// Record the revision of the template the Metal3Machine was cloned from.
+	if err := machineMgr.RecordTemplateHash(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to record the template hash")
+	}
+
+	// A detached host is not modified, the Metal3Machine is left as is until
+	// the host is attached again.
+	detached, err := machineMgr.IsHostDetached(ctx)
+	if err != nil {
+		return checkMachineError(machineMgr, err,
+			"failed to check if the BareMetalHost is detached", capierrors.UpdateMachineError)
+	}
+	if detached {
+		return ctrl.Result{}, nil
+	}
+
 	// if the machine is already provisioned, update and return
 	if machineMgr.IsProvisioned() {
 		errType := capierrors.UpdateMachineError
 		err := machineMgr.Update(ctx)
+		// A Metal3Machine ready while powered off gets its Node once powered
+		// on, the providerID is set on the Node.
+		if err == nil && machineMgr.IsPoweringOn() {
+			providerID, _ := machineMgr.GetProviderIDAndBMHID()
+			err = machineMgr.SetNodeProviderID(ctx, &providerID, kubeconfig.clientGetter)
+		}
+		if err == nil {
+			machineMgr.CheckProviderIDFormat()
+			err = machineMgr.SyncNodeAddresses(ctx, kubeconfig.clientGetter)
+		}
+		if err == nil {
+			err = machineMgr.SyncNodeMetadata(ctx, kubeconfig.clientGetter)
+		}
+		// A missing or invalid kubeconfig is not a failure, the Metal3Machine
+		// is requeued, or reconciled when the kubeconfig secret changes.
+		if kubeconfig.update(err) {
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
 		return checkMachineError(machineMgr, err,
 			"Failed to update the Metal3Machine", errType)
 	}
 
-	// Make sure bootstrap data is available and populated. If not, return, we
-	// will get an event from the machine update when the flag is set to true.
-	if !machineMgr.IsBootstrapReady() {
+	// Make sure bootstrap data is available and populated, unless the machine
+	// is bootstrapless, boots a live-iso or adopts a provisioned host. If not,
+	// return, we will get an event from the machine update when the flag is set
+	// to true, or from the Metal3Cluster update when bootstrapless machines are
+	// allowed.
+	liveISO := machineMgr.IsLiveISO()
+	adopting := machineMgr.IsAdoptingHost()
+	if liveISO || adopting {
+		machineMgr.SetConditionMetal3MachineToTrue(infrav1.BootstrapSkippedCondition)
+	} else if machineMgr.IsBootstrapless() {
+		if !machineMgr.BootstrapSkipAllowed() {
+			machineMgr.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.BootstrapSkipNotAllowedReason, clusterv1.ConditionSeverityError,
+				"bootstrapless Metal3Machines are not allowed by the Metal3Cluster, set spec.allowBootstrapless")
+			return ctrl.Result{}, nil
+		}
+		machineMgr.SetConditionMetal3MachineToTrue(infrav1.BootstrapSkippedCondition)
+	} else if !machineMgr.IsBootstrapReady() {
 		machineMgr.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.WaitingForBootstrapReadyReason, clusterv1.ConditionSeverityInfo, "")
 		return ctrl.Result{}, nil
 	}
@@ -235,7 +397,26 @@ func (r *Metal3MachineReconciler) reconcileNormal(ctx context.Context,
 		// Associate the baremetalhost hosting the machine
 		err := machineMgr.Associate(ctx)
 		if err != nil {
-			machineMgr.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.AssociateBMHFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			switch {
+			case errors.Is(err, baremetal.ErrBlocked), errors.Is(err, baremetal.ErrHostDeleted):
+				// The blocking reason is already set on the condition.
+			case errors.Is(err, baremetal.ErrNoAvailableHost):
+				// Not a failure, the Metal3Machine is requeued when the host pool changes.
+				// The requeue delay is left out of the message to keep the condition
+				// stable while backing off.
+				message := err.Error()
+				var reconcileError baremetal.ReconcileError
+				if errors.As(err, &reconcileError) && reconcileError.Unwrap() != nil {
+					message = reconcileError.Unwrap().Error()
+				}
+				reason := infrav1.NoAvailableHostReason
+				if errors.Is(err, baremetal.ErrNoAvailableHostInFailureDomain) {
+					reason = infrav1.NoAvailableHostInFailureDomainReason
+				}
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, reason, clusterv1.ConditionSeverityWarning, message)
+			default:
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.AssociateBMHFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			}
 			return checkMachineError(machineMgr, err,
 				"failed to associate the Metal3Machine to a BareMetalHost", errType)
 		}
@@ -244,9 +425,16 @@ func (r *Metal3MachineReconciler) reconcileNormal(ctx context.Context,
 	machineMgr.SetConditionMetal3MachineToTrue(infrav1.AssociateBMHCondition)
 
 	// Make sure that the metadata is ready if any
-	err := machineMgr.AssociateM3Metadata(ctx)
+	err = machineMgr.AssociateM3Metadata(ctx)
 	if err != nil {
-		machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.AssociateM3MetaDataFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		switch {
+		case errors.Is(err, baremetal.ErrBlocked):
+			// The blocking reason is already set on the condition.
+		case isTransientError(err):
+			machineMgr.SetConditionMetal3MachineToFalse(infrav1.Metal3DataReadyCondition, infrav1.WaitingForMetal3DataReason, clusterv1.ConditionSeverityInfo, err.Error())
+		default:
+			machineMgr.SetConditionMetal3MachineToFalse(infrav1.Metal3DataReadyCondition, infrav1.AssociateM3MetaDataFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		}
 		return checkMachineError(machineMgr, err,
 			"Failed to get the Metal3Metadata", errType)
 	}
@@ -257,22 +445,81 @@ func (r *Metal3MachineReconciler) reconcileNormal(ctx context.Context,
 			"failed to update BareMetalHost", errType)
 	}
 
+	// A live-iso machine runs no Kubernetes Node, it is ready once the
+	// BareMetalHost is provisioned and powered on.
+	if liveISO {
+		providerID, err := machineMgr.LiveISOProviderID(ctx)
+		if err != nil {
+			return checkMachineError(machineMgr, err,
+				"failed to get the providerID for the Metal3Machine", errType)
+		}
+		if providerID == "" {
+			machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.WaitingForLiveISOBootReason, clusterv1.ConditionSeverityInfo, "")
+			return ctrl.Result{}, nil
+		}
+		machineMgr.SetProviderID(providerID)
+		return ctrl.Result{}, nil
+	}
+
+	// An adopted host is already provisioned, the machine is ready once the
+	// Node of the host is found in the workload cluster.
+	if adopting {
+		providerID, err := machineMgr.AdoptedNodeProviderID(ctx, kubeconfig.clientGetter)
+		kubeconfigUnavailable := kubeconfig.update(err)
+		if err != nil {
+			switch {
+			case kubeconfigUnavailable:
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+				return ctrl.Result{RequeueAfter: requeueAfter}, nil
+			case isTransientError(err):
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.WaitingForNodeReason, clusterv1.ConditionSeverityInfo, err.Error())
+			default:
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			}
+			return checkMachineError(machineMgr, err,
+				"failed to get the providerID for the Metal3Machine", errType)
+		}
+		machineMgr.SetProviderID(providerID)
+		return ctrl.Result{}, nil
+	}
+
 	providerID, bmhID := machineMgr.GetProviderIDAndBMHID()
 	if bmhID == nil {
 		bmhID, err = machineMgr.GetBaremetalHostID(ctx)
 		if err != nil {
-			r.Log.Error(err, "Failed to get the providerID for the Metal3Machine", "providerID", providerID)
-			machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.MissingBMHReason, clusterv1.ConditionSeverityError, err.Error())
+			// The blocking reason is already set on the
+			// BareMetalHostProvisionedCondition.
+			if !errors.Is(err, baremetal.ErrBlocked) {
+				r.Log.Error(err, "Failed to get the providerID for the Metal3Machine", "providerID", providerID)
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.MissingBMHReason, clusterv1.ConditionSeverityError, err.Error())
+			}
 			return checkMachineError(machineMgr, err,
 				"failed to get the providerID for the Metal3Machine", errType)
 		}
 	}
 	if providerID != "" || bmhID != nil {
 		// Set the providerID on the node if no Cloud provider
-		err = machineMgr.SetNodeProviderID(ctx, &providerID, r.CapiClientGetter)
+		err = machineMgr.SetNodeProviderID(ctx, &providerID, kubeconfig.clientGetter)
+		kubeconfigUnavailable := kubeconfig.update(err)
 		if err != nil {
-			r.Log.Error(err, "Failed to set the target node providerID", "providerID", providerID)
-			machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			// An unreachable workload cluster, e.g. during an upgrade of the
+			// control plane, is not an error, the Metal3Machine is requeued.
+			if _, unreachable := infraremote.AsClusterUnreachableError(err); !unreachable {
+				r.Log.Error(err, "Failed to set the target node providerID", "providerID", providerID)
+			}
+			switch {
+			case kubeconfigUnavailable:
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			case isTransientError(err):
+				// The Node is not found or the workload cluster is not
+				// reachable yet.
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.WaitingForNodeReason, clusterv1.ConditionSeverityInfo, err.Error())
+			default:
+				machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			}
+			if kubeconfigUnavailable {
+				return ctrl.Result{RequeueAfter: requeueAfter}, nil
+			}
 			return checkMachineError(machineMgr, err,
 				"failed to set the target node providerID", errType)
 		}
@@ -284,16 +531,29 @@ func (r *Metal3MachineReconciler) reconcileNormal(ctx context.Context,
 }
 
 func (r *Metal3MachineReconciler) reconcileDelete(ctx context.Context,
-	machineMgr baremetal.MachineManagerInterface,
+	machineMgr baremetal.MachineManagerInterface, kubeconfig *kubeconfigCondition,
 ) (ctrl.Result, error) {
 	// set machine condition to Deleting
 	machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
 
 	errType := capierrors.DeleteMachineError
 
+	// drain the node before the host is deprovisioned, if enabled. The drain
+	// is skipped if the kubeconfig is unavailable, not to block the deletion.
+	err := machineMgr.DrainNode(ctx, kubeconfig.clientGetter)
+	kubeconfig.update(err)
+	if err != nil {
+		return checkMachineError(machineMgr, err,
+			"failed to drain the Node", errType)
+	}
+
 	// delete the machine
 	if err := machineMgr.Delete(ctx); err != nil {
-		machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		// The pre-terminate hooks holding the deletion are already set on the
+		// condition.
+		if !errors.Is(err, baremetal.ErrWaitingForPreTerminateHook) {
+			machineMgr.SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		}
 		return checkMachineError(machineMgr, err,
 			"failed to delete Metal3Machine", errType)
 	}
@@ -313,35 +573,94 @@ func (r *Metal3MachineReconciler) reconcileDelete(ctx context.Context,
 
 // SetupWithManager will add watches for this controller.
 func (r *Metal3MachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrav1.Metal3Machine{}).
+	// The watch-filter label and shard filter is set on the watches of the
+	// Cluster API and CAPM3 objects only, the BareMetalHosts, e.g. of an
+	// inventory namespace, do not carry the watch-filter label and are mapped
+	// to the Metal3Machines of every instance. The requests of the
+	// Metal3Machines of other instances are dropped by Reconcile. The
+	// kubeconfig secrets do not carry the label either, they are filtered on
+	// their Cluster by KubeconfigSecretToMetal3Machines. Paused
+	// objects are not filtered out, the pause annotation needs to be
+	// propagated to the BareMetalHost.
+	filter := builder.WithPredicates(ResourceHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard))
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.Metal3Machine{}, filter).
 		WithOptions(options).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
 		Watches(
 			&clusterv1.Machine{},
 			handler.EnqueueRequestsFromMapFunc(util.MachineToInfrastructureMapFunc(infrav1.GroupVersion.WithKind("Metal3Machine"))),
+			filter,
 		).
 		Watches(
 			&clusterv1.Cluster{},
 			handler.EnqueueRequestsFromMapFunc(r.ClusterToMetal3Machines),
+			filter,
 		).
 		Watches(
 			&infrav1.Metal3Cluster{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3ClusterToMetal3Machines),
+			filter,
 		).
 		Watches(
 			&infrav1.Metal3DataClaim{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3DataClaimToMetal3Machines),
+			filter,
 		).
 		Watches(
 			&infrav1.Metal3Data{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3DataToMetal3Machines),
+			filter,
 		).
 		Watches(
 			&bmov1alpha1.BareMetalHost{},
 			handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3Machines),
+			builder.WithPredicates(BareMetalHostChanged(ctrl.LoggerFrom(ctx))),
 		).
-		Complete(r)
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.KubeconfigSecretToMetal3Machines),
+			builder.WithPredicates(kubeconfigSecretPredicate()),
+		)
+	if r.HostCluster != nil {
+		b = b.WatchesRawSource(
+			source.Kind(r.HostCluster.GetCache(), &bmov1alpha1.BareMetalHost{}),
+			handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3Machines),
+			builder.WithPredicates(BareMetalHostChanged(ctrl.LoggerFrom(ctx))),
+		)
+	}
+	c, err := b.Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	if r.HostClusters != nil {
+		return r.HostClusters.AddWatcher(func(hostCache ctrlcache.Cache) error {
+			return c.Watch(
+				source.Kind(hostCache, &bmov1alpha1.BareMetalHost{}),
+				handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3Machines),
+				BareMetalHostChanged(ctrl.LoggerFrom(ctx)),
+			)
+		})
+	}
+	return nil
+}
+
+// NodeToMetal3Machines is a handler.ToRequestsFunc to be used to enqeue
+// requests for reconciliation of the Metal3Machine of a workload cluster Node.
+func (r *Metal3MachineReconciler) NodeToMetal3Machines(ctx context.Context, o client.Object) []ctrl.Request {
+	machineKey, ok := nodeToMachineKey(o)
+	if !ok {
+		return nil
+	}
+	capiMachine := &clusterv1.Machine{}
+	if err := r.Client.Get(ctx, machineKey, capiMachine); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to get Machine of Node", "machine", machineKey)
+		}
+		return nil
+	}
+	return util.MachineToInfrastructureMapFunc(infrav1.GroupVersion.WithKind("Metal3Machine"))(ctx, capiMachine)
 }
 
 // ClusterToMetal3Machines is a handler.ToRequestsFunc to be used to enqeue
@@ -379,6 +698,19 @@ func (r *Metal3MachineReconciler) ClusterToMetal3Machines(ctx context.Context, o
 	return result
 }
 
+// KubeconfigSecretToMetal3Machines is a handler.ToRequestsFunc to be used to
+// enqeue requests for reconciliation of the Metal3Machines of a Cluster when
+// its kubeconfig secret changes, so that they are retried promptly once the
+// kubeconfig is fixed. The secrets of the Clusters of other instances are
+// ignored.
+func (r *Metal3MachineReconciler) KubeconfigSecretToMetal3Machines(ctx context.Context, o client.Object) []ctrl.Request {
+	cluster, ok := watchedKubeconfigSecretCluster(ctx, r.Client, r.Log, o, r.WatchFilterValue, r.Shard)
+	if !ok {
+		return nil
+	}
+	return r.ClusterToMetal3Machines(ctx, cluster)
+}
+
 // Metal3ClusterToMetal3Machines is a handler.ToRequestsFunc to be used to enqeue
 // requests for reconciliation of Metal3Machines.
 func (r *Metal3MachineReconciler) Metal3ClusterToMetal3Machines(ctx context.Context, o client.Object) []ctrl.Request {
@@ -421,8 +753,9 @@ func (r *Metal3MachineReconciler) Metal3ClusterToMetal3Machines(ctx context.Cont
 }
 
 // BareMetalHostToMetal3Machines will return a reconcile request for a Metal3Machine if the event is for a
-// BareMetalHost and that BareMetalHost references a Metal3Machine.
-func (r *Metal3MachineReconciler) BareMetalHostToMetal3Machines(_ context.Context, obj client.Object) []ctrl.Request {
+// BareMetalHost and that BareMetalHost references a Metal3Machine. If the BareMetalHost is available,
+// a reconcile request is returned for every Metal3Machine in the namespace waiting for a host.
+func (r *Metal3MachineReconciler) BareMetalHostToMetal3Machines(ctx context.Context, obj client.Object) []ctrl.Request {
 	if host, ok := obj.(*bmov1alpha1.BareMetalHost); ok {
 		if host.Spec.ConsumerRef != nil &&
 			host.Spec.ConsumerRef.Kind == Metal3Machine &&
@@ -436,6 +769,14 @@ func (r *Metal3MachineReconciler) BareMetalHostToMetal3Machines(_ context.Contex
 				},
 			}
 		}
+		if hostIsAvailable(host) {
+			// A host in an allowed namespace can be consumed by the
+			// Metal3Machines of any namespace.
+			if baremetal.Contains(baremetal.BMHNamespaces, host.Namespace) {
+				return r.waitingMetal3Machines(ctx, "")
+			}
+			return r.waitingMetal3Machines(ctx, host.Namespace)
+		}
 	} else {
 		r.Log.Error(errors.Errorf("expected a BareMetalHost but got a %T", obj),
 			"failed to get Metal3Machine for BareMetalHost",
@@ -444,6 +785,106 @@ func (r *Metal3MachineReconciler) BareMetalHostToMetal3Machines(_ context.Contex
 	return []ctrl.Request{}
 }
 
+// BareMetalHostChanged returns a predicate that filters out the BareMetalHost
+// updates that are not relevant to the Metal3Machines. An update is relevant
+// when the provisioning state, the power state, the error message, the
+// consumer or the deletion timestamp of the host changed, or when the labels
+// or annotations of a host without consumer changed, since they decide whether
+// a waiting Metal3Machine can pick it. Other events are not filtered.
+func BareMetalHostChanged(logger logr.Logger) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldHost, okOld := e.ObjectOld.(*bmov1alpha1.BareMetalHost)
+			newHost, okNew := e.ObjectNew.(*bmov1alpha1.BareMetalHost)
+			if !okOld || !okNew {
+				return true
+			}
+			if bareMetalHostChanged(oldHost, newHost) {
+				return true
+			}
+			logger.V(6).Info("BareMetalHost update not relevant, will not attempt to map resource",
+				"namespace", newHost.Namespace, "baremetalhost", newHost.Name)
+			return false
+		},
+	}
+}
+
+func bareMetalHostChanged(oldHost, newHost *bmov1alpha1.BareMetalHost) bool {
+	if oldHost.Status.Provisioning.State != newHost.Status.Provisioning.State ||
+		oldHost.Status.PoweredOn != newHost.Status.PoweredOn ||
+		oldHost.Status.ErrorMessage != newHost.Status.ErrorMessage ||
+		(oldHost.Status.OperationalStatus == bmov1alpha1.OperationalStatusError) !=
+			(newHost.Status.OperationalStatus == bmov1alpha1.OperationalStatusError) ||
+		oldHost.Status.HardwareProfile != newHost.Status.HardwareProfile ||
+		!equality.Semantic.DeepEqual(oldHost.Status.HardwareDetails, newHost.Status.HardwareDetails) {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(oldHost.Spec.ConsumerRef, newHost.Spec.ConsumerRef) ||
+		oldHost.DeletionTimestamp.IsZero() != newHost.DeletionTimestamp.IsZero() {
+		return true
+	}
+	_, oldPreferDelete := oldHost.Labels[infrav1.PreferDeleteLabel]
+	_, newPreferDelete := newHost.Labels[infrav1.PreferDeleteLabel]
+	if oldPreferDelete != newPreferDelete {
+		return true
+	}
+	_, oldDetached := oldHost.Annotations[bmov1alpha1.DetachedAnnotation]
+	_, newDetached := newHost.Annotations[bmov1alpha1.DetachedAnnotation]
+	if oldDetached != newDetached {
+		return true
+	}
+	if newHost.Spec.ConsumerRef == nil {
+		return !equality.Semantic.DeepEqual(oldHost.Labels, newHost.Labels) ||
+			!equality.Semantic.DeepEqual(oldHost.Annotations, newHost.Annotations)
+	}
+	return false
+}
+
+// hostIsAvailable returns true if the BareMetalHost could be chosen by a
+// Metal3Machine waiting for a host.
+func hostIsAvailable(host *bmov1alpha1.BareMetalHost) bool {
+	if host.Spec.ConsumerRef != nil || !host.DeletionTimestamp.IsZero() || host.Status.ErrorMessage != "" {
+		return false
+	}
+	switch host.Status.Provisioning.State {
+	case bmov1alpha1.StateReady, bmov1alpha1.StateAvailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitingMetal3Machines returns a reconcile request for every Metal3Machine
+// of this instance in the namespace that is waiting for an available
+// BareMetalHost. The
+// requests are deduplicated and rate limited by the controller workqueue.
+func (r *Metal3MachineReconciler) waitingMetal3Machines(ctx context.Context, namespace string) []ctrl.Request {
+	requests := []ctrl.Request{}
+	m3mList := &infrav1.Metal3MachineList{}
+	if err := r.Client.List(ctx, m3mList, client.InNamespace(namespace)); err != nil {
+		r.Log.Error(err, "failed to list Metal3Machines")
+		return requests
+	}
+	for i := range m3mList.Items {
+		m3m := &m3mList.Items[i]
+		if !processIfLabelMatchOrShard(r.Log, m3m, r.WatchFilterValue, r.Shard) {
+			continue
+		}
+		switch conditions.GetReason(m3m, infrav1.AssociateBMHCondition) {
+		case infrav1.NoAvailableHostReason, infrav1.NoAvailableHostInFailureDomainReason:
+		default:
+			continue
+		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      m3m.Name,
+				Namespace: m3m.Namespace,
+			},
+		})
+	}
+	return requests
+}
+
 // Metal3DataClaimToMetal3Machines will return a reconcile request for a Metal3Machine if the event is for a
 // Metal3Data and that Metal3Data references a Metal3Machine.
 func (r *Metal3MachineReconciler) Metal3DataClaimToMetal3Machines(_ context.Context, obj client.Object) []ctrl.Request {
@@ -525,12 +966,31 @@ func clearErrorM3Machine(m3m *infrav1.Metal3Machine) {
 	}
 }
 
+// clearMachineFailure removes the failure fields from the owner Machine's Status if set.
+func (r *Metal3MachineReconciler) clearMachineFailure(ctx context.Context, capiMachine *clusterv1.Machine) error {
+	if capiMachine.Status.FailureMessage == nil && capiMachine.Status.FailureReason == nil {
+		return nil
+	}
+	helper, err := patch.NewHelper(capiMachine, r.Client)
+	if err != nil {
+		return err
+	}
+	capiMachine.Status.FailureMessage = nil
+	capiMachine.Status.FailureReason = nil
+	return helper.Patch(ctx, capiMachine)
+}
+
 func checkMachineError(machineMgr baremetal.MachineManagerInterface, err error,
 	errMessage string, errType capierrors.MachineStatusError) (ctrl.Result, error) {
 	if err == nil {
 		return ctrl.Result{}, nil
 	}
 
+	// The failure of a Metal3Machine whose host was deleted is already set.
+	if errors.Is(err, baremetal.ErrHostDeleted) {
+		return ctrl.Result{}, nil
+	}
+
 	var reconcileError baremetal.ReconcileError
 	if errors.As(err, &reconcileError) {
 		if reconcileError.IsTransient() {
@@ -543,3 +1003,10 @@ func checkMachineError(machineMgr baremetal.MachineManagerInterface, err error,
 	}
 	return ctrl.Result{}, errors.Wrap(err, errMessage)
 }
+
+// isTransientError returns whether the error is a transient ReconcileError,
+// the Metal3Machine is requeued.
+func isTransientError(err error) bool {
+	var reconcileError baremetal.ReconcileError
+	return errors.As(err, &reconcileError) && reconcileError.IsTransient()
+}
@@ -95,6 +95,26 @@ func m3mMetaWithAnnotation() *metav1.ObjectMeta {
 	}
 }
 
+func m3mMetaWithPausedAnnotation() *metav1.ObjectMeta {
+	meta := m3mMetaWithAnnotation()
+	meta.Annotations[clusterv1.PausedAnnotation] = "true"
+	return meta
+}
+
+func m3mMetaWithCAPM3PausedAnnotation() *metav1.ObjectMeta {
+	meta := m3mMetaWithAnnotation()
+	meta.Annotations[infrav1.PausedAnnotation] = ""
+	return meta
+}
+
+func bmhPausedByCAPM3() *bmov1alpha1.BareMetalHost {
+	host := newBareMetalHost(baremetalhostName, nil, nil, map[string]string{clusterv1.ClusterNameLabel: clusterName}, false)
+	host.Annotations = map[string]string{
+		bmov1alpha1.PausedAnnotation: baremetal.PausedAnnotationKey,
+	}
+	return host
+}
+
 func m3mMetaWithIncorrectAnnotation() *metav1.ObjectMeta {
 	return &metav1.ObjectMeta{
 		Name:            metal3machineName,
@@ -189,6 +209,9 @@ var _ = Describe("Reconcile metal3machine", func() {
 		CheckBMHostCleaned         bool
 		CheckBMHostProvisioned     bool
 		ExpectedOnlineStatus       bool
+		CheckBMHPaused             bool
+		ExpectBMHPaused            bool
+		ConditionsAbsent           []clusterv1.ConditionType
 	}
 
 	DescribeTable("Reconcile tests",
@@ -255,6 +278,9 @@ var _ = Describe("Reconcile metal3machine", func() {
 					Expect(condGot.Reason).To(Equal(condExp.Reason))
 				}
 			}
+			for _, condType := range tc.ConditionsAbsent {
+				Expect(conditions.Has(testBMmachine, condType)).To(BeFalse())
+			}
 			if tc.LabelExpected {
 				Expect(objMeta.Labels[clusterv1.ClusterNameLabel]).NotTo(BeNil())
 			}
@@ -299,6 +325,13 @@ var _ = Describe("Reconcile metal3machine", func() {
 				Expect(testBMHost.Spec.UserData).NotTo(BeNil())
 				Expect(testBMHost.Spec.ConsumerRef.Name).To(Equal(testBMmachine.Name))
 			}
+			if tc.CheckBMHPaused {
+				if tc.ExpectBMHPaused {
+					Expect(testBMHost.Annotations).To(HaveKeyWithValue(bmov1alpha1.PausedAnnotation, baremetal.PausedAnnotationKey))
+				} else {
+					Expect(testBMHost.Annotations).NotTo(HaveKey(bmov1alpha1.PausedAnnotation))
+				}
+			}
 			if tc.ClusterInfraReady {
 				Expect(testcluster.Status.InfrastructureReady).To(BeTrue())
 			} else {
@@ -484,6 +517,92 @@ var _ = Describe("Reconcile metal3machine", func() {
 				ClusterInfraReady:       true,
 			},
 		),
+		//Given: Machine, Metal3Machine with host annotation, Cluster, Metal3Cluster, BMHost.
+		// Metal3Machine has cluster.x-k8s.io/paused annotation
+		//Expected: Requeue Expected, BMHost is paused
+		Entry("Should pause the BareMetalHost when Metal3Machine has paused annotation",
+			TestCaseReconcile{
+				Objects: []client.Object{
+					newMetal3Machine(metal3machineName, m3mMetaWithPausedAnnotation(), nil, nil, false),
+					machineWithInfra(),
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false),
+					newBareMetalHost(baremetalhostName, nil, nil, map[string]string{clusterv1.ClusterNameLabel: clusterName}, false),
+				},
+				ErrorExpected:           false,
+				RequeueExpected:         true,
+				ExpectedRequeueDuration: requeueAfter,
+				ClusterInfraReady:       true,
+				CheckBMHPaused:          true,
+				ExpectBMHPaused:         true,
+			},
+		),
+		//Given: Machine, Metal3Machine with host annotation, Cluster, Metal3Cluster, BMHost paused by CAPM3.
+		//Expected: BMHost is not paused anymore
+		Entry("Should resume the BareMetalHost when Metal3Machine and Cluster are not paused",
+			TestCaseReconcile{
+				Objects: []client.Object{
+					newMetal3Machine(metal3machineName, m3mMetaWithAnnotation(), nil, nil, false),
+					machineWithInfra(),
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false),
+					bmhPausedByCAPM3(),
+				},
+				ErrorExpected:     false,
+				RequeueExpected:   false,
+				ClusterInfraReady: true,
+				CheckBMHPaused:    true,
+				ExpectBMHPaused:   false,
+			},
+		),
+		//Given: Machine, Metal3Machine with host annotation, Cluster, Metal3Cluster, BMHost.
+		// Metal3Machine has capm3.metal3.io/paused annotation
+		//Expected: Requeue Expected, Paused condition set, BMHost is not paused
+		Entry("Should requeue without pausing the BareMetalHost when Metal3Machine has CAPM3 paused annotation",
+			TestCaseReconcile{
+				Objects: []client.Object{
+					newMetal3Machine(metal3machineName, m3mMetaWithCAPM3PausedAnnotation(), nil, nil, false),
+					machineWithInfra(),
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false),
+					newBareMetalHost(baremetalhostName, nil, nil, map[string]string{clusterv1.ClusterNameLabel: clusterName}, false),
+				},
+				ErrorExpected:           false,
+				RequeueExpected:         true,
+				ExpectedRequeueDuration: requeueAfter,
+				ClusterInfraReady:       true,
+				CheckBMHPaused:          true,
+				ExpectBMHPaused:         false,
+				ConditionsExpected: clusterv1.Conditions{
+					clusterv1.Condition{
+						Type:   infrav1.PausedCondition,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		),
+		//Given: Machine, Metal3Machine with host annotation and Paused condition, Cluster, Metal3Cluster, BMHost.
+		//Expected: Paused condition removed
+		Entry("Should remove the Paused condition when CAPM3 paused annotation is removed",
+			TestCaseReconcile{
+				Objects: []client.Object{
+					newMetal3Machine(metal3machineName, m3mMetaWithAnnotation(), nil,
+						&infrav1.Metal3MachineStatus{
+							Conditions: clusterv1.Conditions{
+								*conditions.TrueCondition(infrav1.PausedCondition),
+							},
+						}, false),
+					machineWithInfra(),
+					newCluster(clusterName, nil, nil),
+					newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false),
+					newBareMetalHost(baremetalhostName, nil, nil, map[string]string{clusterv1.ClusterNameLabel: clusterName}, false),
+				},
+				ErrorExpected:     false,
+				RequeueExpected:   false,
+				ClusterInfraReady: true,
+				ConditionsAbsent:  []clusterv1.ConditionType{infrav1.PausedCondition},
+			},
+		),
 		//Given: M3Machine (Spec: Provider ID, Status: Ready), BMHost(Provisioned).
 		//Expected: Since BMH is in provisioned state, nothing will happen since machine. bootstrapReady is false.
 		Entry("Should not return an error when metal3machine is deployed",
@@ -728,6 +847,18 @@ var _ = Describe("Reconcile metal3machine", func() {
 				ClusterInfraReady:       true,
 				CheckBMFinalizer:        true,
 				CheckBootStrapReady:     true,
+				ConditionsExpected: clusterv1.Conditions{
+					clusterv1.Condition{
+						Type:   infrav1.BareMetalHostProvisionedCondition,
+						Status: corev1.ConditionFalse,
+						Reason: infrav1.ProvisioningBareMetalHostReason,
+					},
+					clusterv1.Condition{
+						Type:   clusterv1.ReadyCondition,
+						Status: corev1.ConditionFalse,
+						Reason: infrav1.ProvisioningBareMetalHostReason,
+					},
+				},
 			},
 		),
 		//Given: metal3machine with annotation to a BMH provisioned, machine with
@@ -754,14 +885,19 @@ var _ = Describe("Reconcile metal3machine", func() {
 						Type:   infrav1.AssociateBMHCondition,
 						Status: corev1.ConditionTrue,
 					},
+					clusterv1.Condition{
+						Type:   infrav1.BareMetalHostProvisionedCondition,
+						Status: corev1.ConditionTrue,
+					},
 					clusterv1.Condition{
 						Type:   infrav1.KubernetesNodeReadyCondition,
 						Status: corev1.ConditionFalse,
-						Reason: infrav1.SettingProviderIDOnNodeFailedReason,
+						Reason: infrav1.WaitingForNodeReason,
 					},
 					clusterv1.Condition{
 						Type:   clusterv1.ReadyCondition,
 						Status: corev1.ConditionFalse,
+						Reason: infrav1.WaitingForNodeReason,
 					},
 				},
 			},
@@ -906,4 +1042,41 @@ var _ = Describe("Reconcile metal3machine", func() {
 			},
 		),
 	)
+
+	It("Keeps a Metal3Machine whose BareMetalHost was deleted failed", func() {
+		freeHost := newBareMetalHost("free-host", nil, nil, nil, false)
+		objects := []client.Object{
+			newMetal3Machine(metal3machineName, m3mMetaWithAnnotation(), nil, nil, false),
+			machineWithBootstrap(),
+			newCluster(clusterName, nil, nil),
+			newMetal3Cluster(metal3ClusterName, nil, nil, nil, nil, false),
+			freeHost,
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
+		r := &Metal3MachineReconciler{
+			Client:         fakeClient,
+			ManagerFactory: baremetal.NewManagerFactory(fakeClient),
+			Log:            logr.Discard(),
+		}
+		req := reconcile.Request{NamespacedName: *getKey(metal3machineName)}
+
+		// The first reconciliation fails the Metal3Machine, the second one
+		// neither clears the failure nor associates another host.
+		for i := 0; i < 2; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).NotTo(HaveOccurred())
+
+			testBMmachine := &infrav1.Metal3Machine{}
+			Expect(fakeClient.Get(context.TODO(), *getKey(metal3machineName), testBMmachine)).To(Succeed())
+			Expect(testBMmachine.Status.FailureReason).NotTo(BeNil())
+			Expect(*testBMmachine.Status.FailureReason).To(Equal(capierrors.UpdateMachineError))
+			Expect(testBMmachine.Status.FailureMessage).NotTo(BeNil())
+			Expect(conditions.GetReason(testBMmachine, infrav1.AssociateBMHCondition)).To(Equal(infrav1.HostDeletedReason))
+			Expect(testBMmachine.Annotations).To(HaveKeyWithValue(baremetal.HostAnnotation, namespaceName+"/"+baremetalhostName))
+
+			testHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(freeHost), testHost)).To(Succeed())
+			Expect(testHost.Spec.ConsumerRef).To(BeNil())
+		}
+	})
 })
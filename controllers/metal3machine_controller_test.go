@@ -29,15 +29,23 @@ import (
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 type reconcileNormalTestCase struct {
@@ -45,12 +53,30 @@ type reconcileNormalTestCase struct {
 	ExpectRequeue          bool
 	Provisioned            bool
 	BootstrapNotReady      bool
+	Bootstrapless          bool
+	BootstrapSkipForbidden bool
 	Annotated              bool
 	AssociateFails         bool
+	AssociateBlocked       bool
+	NoAvailableHost        bool
+	M3MetadataWaiting      bool
+	M3MetadataFails        bool
 	GetProviderIDFails     bool
 	GetBMHIDFails          bool
 	BMHIDSet               bool
 	SetNodeProviderIDFails bool
+	NodeNotFound           bool
+	HostDetached           bool
+	HostDetachedFails      bool
+	LiveISO                bool
+	LiveISOBooting         bool
+	Adopting               bool
+	AdoptedNodeNotFound    bool
+	PoweringOn             bool
+	PoweringOnNodeMissing  bool
+	KubeconfigError        error
+	NodeUnauthorized       bool
+	ExpectKubeconfigReason string
 }
 
 func setReconcileNormalExpectations(ctrl *gomock.Controller,
@@ -59,21 +85,99 @@ func setReconcileNormalExpectations(ctrl *gomock.Controller,
 	m := baremetal_mocks.NewMockMachineManagerInterface(ctrl)
 
 	m.EXPECT().SetFinalizer()
+	m.EXPECT().RecordTemplateHash(context.TODO()).Return(nil)
+
+	// detached host, we do not modify it, nothing else is called
+	if tc.HostDetachedFails {
+		m.EXPECT().IsHostDetached(context.TODO()).Return(false, errors.New("Failed"))
+	} else {
+		m.EXPECT().IsHostDetached(context.TODO()).Return(tc.HostDetached, nil)
+	}
+	if tc.HostDetached || tc.HostDetachedFails {
+		m.EXPECT().IsProvisioned().MaxTimes(0)
+		m.EXPECT().Update(context.TODO()).MaxTimes(0)
+		m.EXPECT().CheckProviderIDFormat().MaxTimes(0)
+		m.EXPECT().SyncNodeAddresses(context.TODO(), gomock.Any()).MaxTimes(0)
+		m.EXPECT().HasAnnotation().MaxTimes(0)
+		m.EXPECT().Associate(context.TODO()).MaxTimes(0)
+		m.EXPECT().AssociateM3Metadata(context.TODO()).MaxTimes(0)
+		m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+		return m
+	}
 
 	// provisioned, we should only call Update, nothing else
 	m.EXPECT().IsProvisioned().Return(tc.Provisioned)
 	if tc.Provisioned {
 		m.EXPECT().Update(context.TODO()).Return(nil)
+		m.EXPECT().IsPoweringOn().Return(tc.PoweringOn || tc.PoweringOnNodeMissing)
+		if tc.PoweringOn || tc.PoweringOnNodeMissing {
+			m.EXPECT().GetProviderIDAndBMHID().Return(providerID, nil)
+			provID := providerID
+			if tc.PoweringOnNodeMissing {
+				m.EXPECT().SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).
+					Return(baremetal.WithTransientError(errors.New("node not found"), requeueAfter))
+				m.EXPECT().CheckProviderIDFormat().MaxTimes(0)
+				m.EXPECT().SyncNodeAddresses(context.TODO(), gomock.Any()).MaxTimes(0)
+				m.EXPECT().SyncNodeMetadata(context.TODO(), gomock.Any()).MaxTimes(0)
+				m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+				return m
+			}
+			m.EXPECT().SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).Return(nil)
+		} else {
+			m.EXPECT().GetProviderIDAndBMHID().MaxTimes(0)
+			m.EXPECT().SetNodeProviderID(context.TODO(), gomock.Any(), gomock.Any()).MaxTimes(0)
+		}
+		if tc.KubeconfigError != nil {
+			m.EXPECT().CheckProviderIDFormat()
+			m.EXPECT().SyncNodeAddresses(context.TODO(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, clientGetter baremetal.ClientGetter) error {
+					_, err := clientGetter(ctx, nil, nil)
+					return baremetal.WithTransientError(err, requeueAfter)
+				},
+			)
+			m.EXPECT().SyncNodeMetadata(context.TODO(), gomock.Any()).MaxTimes(0)
+		} else {
+			m.EXPECT().CheckProviderIDFormat()
+			m.EXPECT().SyncNodeAddresses(context.TODO(), gomock.Any()).Return(nil)
+			m.EXPECT().SyncNodeMetadata(context.TODO(), gomock.Any()).Return(nil)
+		}
+		m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+		m.EXPECT().IsBootstrapless().MaxTimes(0)
 		m.EXPECT().IsBootstrapReady().MaxTimes(0)
 		m.EXPECT().AssociateM3Metadata(context.TODO()).MaxTimes(0)
 		m.EXPECT().HasAnnotation().MaxTimes(0)
-		m.EXPECT().GetProviderIDAndBMHID().MaxTimes(0)
 		m.EXPECT().GetBaremetalHostID(context.TODO()).MaxTimes(0)
 		return m
 	}
 
-	// Bootstrap data not ready, we'll requeue, not call anything else
-	m.EXPECT().IsBootstrapReady().Return(!tc.BootstrapNotReady)
+	// live-iso machine or adopted host, we never wait for the bootstrap data
+	m.EXPECT().IsLiveISO().Return(tc.LiveISO)
+	m.EXPECT().IsAdoptingHost().Return(tc.Adopting)
+	if tc.LiveISO || tc.Adopting {
+		m.EXPECT().SetConditionMetal3MachineToTrue(infrav1.BootstrapSkippedCondition)
+		m.EXPECT().IsBootstrapless().MaxTimes(0)
+		m.EXPECT().IsBootstrapReady().MaxTimes(0)
+	} else {
+		// Bootstrapless machine, we do not wait for the bootstrap data if the
+		// Metal3Cluster allows it, otherwise we do not call anything else
+		m.EXPECT().IsBootstrapless().Return(tc.Bootstrapless)
+	}
+	if tc.Bootstrapless {
+		m.EXPECT().IsBootstrapReady().MaxTimes(0)
+		m.EXPECT().BootstrapSkipAllowed().Return(!tc.BootstrapSkipForbidden)
+		if tc.BootstrapSkipForbidden {
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition,
+				infrav1.BootstrapSkipNotAllowedReason, clusterv1.ConditionSeverityError, gomock.Any())
+			m.EXPECT().HasAnnotation().MaxTimes(0)
+			m.EXPECT().Associate(context.TODO()).MaxTimes(0)
+			m.EXPECT().Update(context.TODO()).MaxTimes(0)
+			return m
+		}
+		m.EXPECT().SetConditionMetal3MachineToTrue(infrav1.BootstrapSkippedCondition)
+	} else if !tc.LiveISO && !tc.Adopting {
+		// Bootstrap data not ready, we'll requeue, not call anything else
+		m.EXPECT().IsBootstrapReady().Return(!tc.BootstrapNotReady)
+	}
 	if tc.BootstrapNotReady {
 		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition,
 			infrav1.WaitingForBootstrapReadyReason, clusterv1.ConditionSeverityInfo, "")
@@ -98,13 +202,85 @@ func setReconcileNormalExpectations(ctrl *gomock.Controller,
 			m.EXPECT().GetBaremetalHostID(context.TODO()).MaxTimes(0)
 			return m
 		}
+		// if the Metal3Machine is blocked, the manager already set the
+		// condition, we requeue without failing
+		if tc.AssociateBlocked {
+			m.EXPECT().Associate(context.TODO()).Return(baremetal.WithTransientError(
+				errors.Wrap(baremetal.ErrBlocked, "policy violated"), requeueAfter))
+			m.EXPECT().SetConditionMetal3MachineToFalse(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().AssociateM3Metadata(context.TODO()).MaxTimes(0)
+			m.EXPECT().Update(context.TODO()).MaxTimes(0)
+			return m
+		}
+		// if no host is available, we requeue without failing
+		if tc.NoAvailableHost {
+			m.EXPECT().Associate(context.TODO()).Return(baremetal.WithTransientError(baremetal.ErrNoAvailableHost, requeueAfter))
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, baremetal.ErrNoAvailableHost.Error())
+			m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().AssociateM3Metadata(context.TODO()).MaxTimes(0)
+			m.EXPECT().Update(context.TODO()).MaxTimes(0)
+			return m
+		}
 		m.EXPECT().Associate(context.TODO()).Return(nil)
 	}
 
 	m.EXPECT().SetConditionMetal3MachineToTrue(infrav1.AssociateBMHCondition)
+
+	// if the data is not rendered yet, we requeue without failing
+	if tc.M3MetadataWaiting {
+		m.EXPECT().AssociateM3Metadata(context.TODO()).Return(
+			baremetal.WithTransientError(errors.New("Waiting for Metal3DataTemplate to be available"), requeueAfter))
+		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.Metal3DataReadyCondition,
+			infrav1.WaitingForMetal3DataReason, clusterv1.ConditionSeverityInfo, gomock.Any())
+		m.EXPECT().Update(context.TODO()).MaxTimes(0)
+		return m
+	}
+	if tc.M3MetadataFails {
+		m.EXPECT().AssociateM3Metadata(context.TODO()).Return(errors.New("Failed"))
+		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.Metal3DataReadyCondition,
+			infrav1.AssociateM3MetaDataFailedReason, clusterv1.ConditionSeverityWarning, gomock.Any())
+		m.EXPECT().Update(context.TODO()).MaxTimes(0)
+		return m
+	}
 	m.EXPECT().AssociateM3Metadata(context.TODO()).Return(nil)
 	m.EXPECT().Update(context.TODO())
 
+	// live-iso machine, ready once the host is booted, without a Node
+	if tc.LiveISO {
+		m.EXPECT().GetProviderIDAndBMHID().MaxTimes(0)
+		m.EXPECT().SetNodeProviderID(context.TODO(), gomock.Any(), gomock.Any()).MaxTimes(0)
+		if tc.LiveISOBooting {
+			m.EXPECT().LiveISOProviderID(context.TODO()).Return("", nil)
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition,
+				infrav1.WaitingForLiveISOBootReason, clusterv1.ConditionSeverityInfo, "")
+			m.EXPECT().SetProviderID(gomock.Any()).MaxTimes(0)
+			return m
+		}
+		m.EXPECT().LiveISOProviderID(context.TODO()).Return(providerID, nil)
+		m.EXPECT().SetProviderID(providerID)
+		return m
+	}
+
+	// adopted host, ready once its Node is found
+	if tc.Adopting {
+		m.EXPECT().GetProviderIDAndBMHID().MaxTimes(0)
+		m.EXPECT().GetBaremetalHostID(context.TODO()).MaxTimes(0)
+		m.EXPECT().SetNodeProviderID(context.TODO(), gomock.Any(), gomock.Any()).MaxTimes(0)
+		if tc.AdoptedNodeNotFound {
+			m.EXPECT().AdoptedNodeProviderID(context.TODO(), gomock.Any()).
+				Return("", baremetal.WithTransientError(errors.New("node not found"), requeueAfter))
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition,
+				infrav1.WaitingForNodeReason, clusterv1.ConditionSeverityInfo, gomock.Any())
+			m.EXPECT().SetProviderID(gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+			return m
+		}
+		m.EXPECT().AdoptedNodeProviderID(context.TODO(), gomock.Any()).Return(providerID, nil)
+		m.EXPECT().SetProviderID(providerID)
+		return m
+	}
+
 	// if node is now associated, if getting the ID fails, we do not go further
 	if tc.GetBMHIDFails {
 		m.EXPECT().GetProviderIDAndBMHID().Return("", nil)
@@ -132,10 +308,41 @@ func setReconcileNormalExpectations(ctrl *gomock.Controller,
 			m.EXPECT().GetBaremetalHostID(context.TODO()).MaxTimes(0)
 		}
 
+		// if the kubeconfig is unavailable, we requeue without failing
+		if tc.KubeconfigError != nil || tc.NodeUnauthorized {
+			m.EXPECT().
+				SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ *string, clientGetter baremetal.ClientGetter) error {
+					if _, err := clientGetter(ctx, nil, nil); err != nil {
+						return errors.Wrap(err, "Error creating a remote client")
+					}
+					return baremetal.WithTransientError(
+						errors.Wrap(apierrors.NewUnauthorized("invalid credentials"), "error retrieving node, requeuing"),
+						requeueAfter)
+				})
+			m.EXPECT().SetProviderID(gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition,
+				infrav1.SettingProviderIDOnNodeFailedReason, clusterv1.ConditionSeverityWarning, gomock.Any())
+			return m
+		}
+
+		// if the node is not found yet, we requeue without failing
+		if tc.NodeNotFound {
+			m.EXPECT().
+				SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).
+				Return(baremetal.WithTransientError(errors.New("node not found"), requeueAfter))
+			m.EXPECT().SetProviderID(gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetError(gomock.Any(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition,
+				infrav1.WaitingForNodeReason, clusterv1.ConditionSeverityInfo, gomock.Any())
+			return m
+		}
+
 		// if we fail to set it on the node, we do not go further
 		if tc.SetNodeProviderIDFails {
 			m.EXPECT().
-				SetNodeProviderID(context.TODO(), gomock.Eq(&provID), nil).
+				SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).
 				Return(errors.New("Failed"))
 			m.EXPECT().SetProviderID(string(bmhuid)).MaxTimes(0)
 			m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition,
@@ -145,7 +352,7 @@ func setReconcileNormalExpectations(ctrl *gomock.Controller,
 
 		// we successfully set it on the node
 		m.EXPECT().
-			SetNodeProviderID(context.TODO(), gomock.Eq(&provID), nil).
+			SetNodeProviderID(context.TODO(), gomock.Eq(&provID), gomock.Any()).
 			Return(nil)
 		m.EXPECT().SetProviderID(provID)
 
@@ -155,7 +362,7 @@ func setReconcileNormalExpectations(ctrl *gomock.Controller,
 		m.EXPECT().GetBaremetalHostID(context.TODO()).Return(nil, nil)
 
 		m.EXPECT().
-			SetNodeProviderID(context.TODO(), gomock.Eq(&providerID), nil).
+			SetNodeProviderID(context.TODO(), gomock.Eq(&providerID), gomock.Any()).
 			MaxTimes(0)
 	}
 
@@ -167,6 +374,11 @@ type reconcileDeleteTestCase struct {
 	ExpectRequeue bool
 	DeleteFails   bool
 	DeleteRequeue bool
+	DrainRequeue  bool
+	// WaitingForHook makes the deletion wait for pre-terminate hooks.
+	WaitingForHook bool
+	// KubeconfigError is returned by the ClientGetter used by the drain.
+	KubeconfigError error
 }
 
 func setReconcileDeleteExpectations(ctrl *gomock.Controller,
@@ -175,12 +387,36 @@ func setReconcileDeleteExpectations(ctrl *gomock.Controller,
 	m := baremetal_mocks.NewMockMachineManagerInterface(ctrl)
 	m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
 
+	if tc.DrainRequeue {
+		m.EXPECT().DrainNode(context.TODO(), gomock.Any()).Return(baremetal.WithTransientError(errors.New("draining"), requeueAfter))
+		m.EXPECT().Delete(context.TODO()).MaxTimes(0)
+		m.EXPECT().UnsetFinalizer().MaxTimes(0)
+		return m
+	}
+	if tc.KubeconfigError != nil {
+		m.EXPECT().DrainNode(context.TODO(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, clientGetter baremetal.ClientGetter) error {
+				_, err := clientGetter(ctx, nil, nil)
+				Expect(err).To(HaveOccurred())
+				return nil
+			},
+		)
+	} else {
+		m.EXPECT().DrainNode(context.TODO(), gomock.Any()).Return(nil)
+	}
+
 	if tc.DeleteFails {
 		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, gomock.Any())
 		m.EXPECT().Delete(context.TODO()).Return(errors.New("failed"))
 		m.EXPECT().UnsetFinalizer().MaxTimes(0)
 		m.EXPECT().DissociateM3Metadata(context.TODO()).MaxTimes(0)
 		return m
+	} else if tc.WaitingForHook {
+		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, gomock.Any()).MaxTimes(0)
+		m.EXPECT().Delete(context.TODO()).Return(baremetal.WithTransientError(baremetal.ErrWaitingForPreTerminateHook, requeueAfter))
+		m.EXPECT().UnsetFinalizer().MaxTimes(0)
+		m.EXPECT().DissociateM3Metadata(context.TODO()).MaxTimes(0)
+		return m
 	} else if tc.DeleteRequeue {
 		m.EXPECT().SetConditionMetal3MachineToFalse(infrav1.KubernetesNodeReadyCondition, infrav1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, gomock.Any())
 		m.EXPECT().Delete(context.TODO()).Return(baremetal.WithTransientError(errors.New("failed"), requeueAfter))
@@ -222,7 +458,14 @@ var _ = Describe("Metal3Machine manager", func() {
 		DescribeTable("ReconcileNormal tests",
 			func(tc reconcileNormalTestCase) {
 				m := setReconcileNormalExpectations(gomockCtrl, tc)
-				res, err := bmReconcile.reconcileNormal(context.TODO(), m)
+				m3m := &infrav1.Metal3Machine{}
+				kubeconfig := &kubeconfigCondition{
+					obj: m3m,
+					getter: func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+						return nil, tc.KubeconfigError
+					},
+				}
+				res, err := bmReconcile.reconcileNormal(context.TODO(), m, kubeconfig)
 
 				if tc.ExpectError {
 					Expect(err).To(HaveOccurred())
@@ -234,28 +477,128 @@ var _ = Describe("Metal3Machine manager", func() {
 				} else {
 					Expect(res.Requeue).To(BeFalse())
 				}
+				if tc.ExpectKubeconfigReason != "" {
+					Expect(res.RequeueAfter).To(Equal(requeueAfter))
+					condition := conditions.Get(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)
+					Expect(condition).NotTo(BeNil())
+					Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+					Expect(condition.Reason).To(Equal(tc.ExpectKubeconfigReason))
+				} else {
+					Expect(conditions.IsFalse(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(BeFalse())
+				}
 			},
 			Entry("Provisioned", reconcileNormalTestCase{
 				ExpectError:   false,
 				ExpectRequeue: false,
 				Provisioned:   true,
 			}),
+			Entry("Provisioned, powering on", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Provisioned:   true,
+				PoweringOn:    true,
+			}),
+			Entry("Provisioned, powering on, Node not joined yet", reconcileNormalTestCase{
+				ExpectError:           false,
+				ExpectRequeue:         true,
+				Provisioned:           true,
+				PoweringOnNodeMissing: true,
+			}),
+			Entry("Provisioned, host detached", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Provisioned:   true,
+				HostDetached:  true,
+			}),
+			Entry("Annotated, host detached", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Annotated:     true,
+				HostDetached:  true,
+			}),
+			Entry("Host detached check fails", reconcileNormalTestCase{
+				ExpectError:       true,
+				ExpectRequeue:     false,
+				HostDetachedFails: true,
+			}),
 			Entry("Bootstrap not ready", reconcileNormalTestCase{
 				ExpectError:       false,
 				ExpectRequeue:     false,
 				BootstrapNotReady: true,
 			}),
+			Entry("Bootstrapless, not allowed by the Metal3Cluster", reconcileNormalTestCase{
+				ExpectError:            false,
+				ExpectRequeue:          false,
+				Bootstrapless:          true,
+				BootstrapSkipForbidden: true,
+			}),
+			Entry("Bootstrapless, not annotated", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Bootstrapless: true,
+				Annotated:     false,
+			}),
 			Entry("Not Annotated", reconcileNormalTestCase{
 				ExpectError:   false,
 				ExpectRequeue: false,
 				Annotated:     false,
 			}),
+			Entry("Live-iso, not annotated, host booting", reconcileNormalTestCase{
+				ExpectError:    false,
+				ExpectRequeue:  false,
+				Annotated:      false,
+				LiveISO:        true,
+				LiveISOBooting: true,
+			}),
+			Entry("Live-iso, host provisioned and powered on", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Annotated:     true,
+				LiveISO:       true,
+			}),
+			Entry("Adopted host, Node not found", reconcileNormalTestCase{
+				ExpectError:         false,
+				ExpectRequeue:       true,
+				Annotated:           false,
+				Adopting:            true,
+				AdoptedNodeNotFound: true,
+			}),
+			Entry("Adopted host, Node found", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: false,
+				Annotated:     true,
+				Adopting:      true,
+			}),
 			Entry("Not Annotated, Associate fails", reconcileNormalTestCase{
 				ExpectError:    true,
 				ExpectRequeue:  false,
 				Annotated:      false,
 				AssociateFails: true,
 			}),
+			Entry("Not Annotated, no available host", reconcileNormalTestCase{
+				ExpectError:     false,
+				ExpectRequeue:   true,
+				Annotated:       false,
+				NoAvailableHost: true,
+			}),
+			Entry("Not Annotated, Associate blocked", reconcileNormalTestCase{
+				ExpectError:      false,
+				ExpectRequeue:    true,
+				Annotated:        false,
+				AssociateBlocked: true,
+			}),
+			Entry("Annotated, waiting for the Metal3Data", reconcileNormalTestCase{
+				ExpectError:       false,
+				ExpectRequeue:     true,
+				Annotated:         true,
+				M3MetadataWaiting: true,
+			}),
+			Entry("Annotated, AssociateM3Metadata fails", reconcileNormalTestCase{
+				ExpectError:     true,
+				ExpectRequeue:   false,
+				Annotated:       true,
+				M3MetadataFails: true,
+			}),
 			Entry("Annotated", reconcileNormalTestCase{
 				ExpectError:   false,
 				ExpectRequeue: false,
@@ -283,9 +626,151 @@ var _ = Describe("Metal3Machine manager", func() {
 				BMHIDSet:               true,
 				SetNodeProviderIDFails: true,
 			}),
+			Entry("BMH ID set, Node not found yet", reconcileNormalTestCase{
+				ExpectError:   false,
+				ExpectRequeue: true,
+				BMHIDSet:      true,
+				NodeNotFound:  true,
+			}),
+			Entry("Provisioned, kubeconfig not found", reconcileNormalTestCase{
+				Provisioned: true,
+				KubeconfigError: &infraremote.KubeconfigError{
+					Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("secret not found"),
+				},
+				ExpectKubeconfigReason: infrav1.KubeconfigNotFoundReason,
+			}),
+			Entry("BMH ID set, kubeconfig invalid", reconcileNormalTestCase{
+				BMHIDSet: true,
+				KubeconfigError: &infraremote.KubeconfigError{
+					Reason: infrav1.KubeconfigInvalidReason, Err: errors.New("invalid kubeconfig"),
+				},
+				ExpectKubeconfigReason: infrav1.KubeconfigInvalidReason,
+			}),
+			Entry("BMH ID set, kubeconfig unauthorized", reconcileNormalTestCase{
+				BMHIDSet:               true,
+				NodeUnauthorized:       true,
+				ExpectKubeconfigReason: infrav1.KubeconfigUnauthorizedReason,
+			}),
 		)
 	})
 
+	type readyConditionTestCase struct {
+		Conditions       clusterv1.Conditions
+		ExpectedStatus   corev1.ConditionStatus
+		ExpectedReason   string
+		ExpectedSeverity clusterv1.ConditionSeverity
+	}
+
+	DescribeTable("Mirrors the blocking step in the InfrastructureReady condition of the Machine",
+		func(tc readyConditionTestCase) {
+			m3m := &infrav1.Metal3Machine{
+				Status: infrav1.Metal3MachineStatus{Conditions: tc.Conditions},
+			}
+			setReadyCondition(m3m)
+
+			// The Machine controller mirrors the Ready condition of the
+			// unstructured infrastructure machine.
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m3m)
+			Expect(err).NotTo(HaveOccurred())
+			machine := &clusterv1.Machine{}
+			conditions.SetMirror(machine, clusterv1.InfrastructureReadyCondition,
+				conditions.UnstructuredGetter(&unstructured.Unstructured{Object: content}),
+			)
+
+			condition := conditions.Get(machine, clusterv1.InfrastructureReadyCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(tc.ExpectedStatus))
+			Expect(condition.Reason).To(Equal(tc.ExpectedReason))
+			Expect(condition.Severity).To(Equal(tc.ExpectedSeverity))
+		},
+		Entry("Host selection", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason,
+					clusterv1.ConditionSeverityWarning, "no available host found"),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.NoAvailableHostReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityWarning,
+		}),
+		Entry("Host selection blocked by a policy violation", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.FalseCondition(infrav1.PolicyCompliantCondition, infrav1.DataTemplateRequiredReason,
+					clusterv1.ConditionSeverityError, "Spec.MetaData: Forbidden"),
+				*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.DataTemplateRequiredReason,
+					clusterv1.ConditionSeverityError, "Spec.MetaData: Forbidden"),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.DataTemplateRequiredReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityError,
+		}),
+		Entry("Data rendering", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.FalseCondition(infrav1.Metal3DataReadyCondition, infrav1.WaitingForMetal3DataReason,
+					clusterv1.ConditionSeverityInfo, ""),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.WaitingForMetal3DataReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityInfo,
+		}),
+		Entry("Data rendering blocked by an invalid secret", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.FalseCondition(infrav1.Metal3DataReadyCondition, infrav1.ProvidedDataSecretNotFoundReason,
+					clusterv1.ConditionSeverityError, ""),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.ProvidedDataSecretNotFoundReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityError,
+		}),
+		Entry("Provisioning", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.TrueCondition(infrav1.Metal3DataReadyCondition),
+				*conditions.FalseCondition(infrav1.BareMetalHostProvisionedCondition, infrav1.ProvisioningBareMetalHostReason,
+					clusterv1.ConditionSeverityInfo, ""),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.ProvisioningBareMetalHostReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityInfo,
+		}),
+		Entry("Node matching", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.TrueCondition(infrav1.Metal3DataReadyCondition),
+				*conditions.TrueCondition(infrav1.BareMetalHostProvisionedCondition),
+				*conditions.FalseCondition(infrav1.KubernetesNodeReadyCondition, infrav1.WaitingForNodeReason,
+					clusterv1.ConditionSeverityInfo, ""),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.WaitingForNodeReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityInfo,
+		}),
+		Entry("Most severe step", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.FalseCondition(infrav1.Metal3DataReadyCondition, infrav1.WaitingForMetal3DataReason,
+					clusterv1.ConditionSeverityInfo, ""),
+				*conditions.FalseCondition(infrav1.KubernetesNodeReadyCondition, infrav1.SettingProviderIDOnNodeFailedReason,
+					clusterv1.ConditionSeverityError, ""),
+			},
+			ExpectedStatus:   corev1.ConditionFalse,
+			ExpectedReason:   infrav1.SettingProviderIDOnNodeFailedReason,
+			ExpectedSeverity: clusterv1.ConditionSeverityError,
+		}),
+		Entry("Conditions outside of the provisioning steps are not summarized", readyConditionTestCase{
+			Conditions: clusterv1.Conditions{
+				*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+				*conditions.TrueCondition(infrav1.Metal3DataReadyCondition),
+				*conditions.TrueCondition(infrav1.BareMetalHostProvisionedCondition),
+				*conditions.TrueCondition(infrav1.KubernetesNodeReadyCondition),
+				*conditions.FalseCondition(infrav1.ProviderIDFormatMatchedCondition, infrav1.LegacyProviderIDFormatReason,
+					clusterv1.ConditionSeverityWarning, ""),
+			},
+			ExpectedStatus: corev1.ConditionTrue,
+		}),
+	)
+
 	Describe("Test MachineReconcileDelete", func() {
 
 		var gomockCtrl *gomock.Controller
@@ -312,7 +797,14 @@ var _ = Describe("Metal3Machine manager", func() {
 		DescribeTable("Deletion tests",
 			func(tc reconcileDeleteTestCase) {
 				m := setReconcileDeleteExpectations(gomockCtrl, tc)
-				res, err := bmReconcile.reconcileDelete(context.TODO(), m)
+				m3m := &infrav1.Metal3Machine{}
+				kubeconfig := &kubeconfigCondition{
+					obj: m3m,
+					getter: func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+						return nil, tc.KubeconfigError
+					},
+				}
+				res, err := bmReconcile.reconcileDelete(context.TODO(), m, kubeconfig)
 				if tc.ExpectError {
 					Expect(err).To(HaveOccurred())
 				} else {
@@ -323,6 +815,8 @@ var _ = Describe("Metal3Machine manager", func() {
 				} else {
 					Expect(res.Requeue).To(BeFalse())
 				}
+				// The deletion is not blocked by an unavailable kubeconfig.
+				Expect(conditions.IsFalse(m3m, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(Equal(tc.KubeconfigError != nil))
 			},
 			Entry("Deletion success", reconcileDeleteTestCase{
 				ExpectError:   false,
@@ -338,9 +832,86 @@ var _ = Describe("Metal3Machine manager", func() {
 				ExpectRequeue: true,
 				DeleteRequeue: true,
 			}),
+			Entry("Deletion waiting for pre-terminate hooks", reconcileDeleteTestCase{
+				ExpectError:    false,
+				ExpectRequeue:  true,
+				WaitingForHook: true,
+			}),
+			Entry("Drain requeue", reconcileDeleteTestCase{
+				ExpectError:   false,
+				ExpectRequeue: true,
+				DrainRequeue:  true,
+			}),
+			Entry("Drain skipped, kubeconfig not found", reconcileDeleteTestCase{
+				KubeconfigError: &infraremote.KubeconfigError{
+					Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("secret not found"),
+				},
+			}),
 		)
 	})
 
+	type TestCaseClearFailure struct {
+		Annotated            bool
+		ExpectFailureCleared bool
+	}
+
+	DescribeTable("Clear failure annotation tests",
+		func(tc TestCaseClearFailure) {
+			reason := capierrors.CreateMachineError
+			m3mMeta := m3mObjectMetaWithOwnerRef()
+			if tc.Annotated {
+				m3mMeta.Annotations = map[string]string{
+					infrav1.ClearFailureAnnotation: "",
+				}
+			}
+			m3m := newMetal3Machine(metal3machineName, m3mMeta, nil, &infrav1.Metal3MachineStatus{
+				FailureReason:  &reason,
+				FailureMessage: pointer.String("No available host found"),
+			}, false)
+			machine := newMachine(clusterName, machineName, metal3machineName, "")
+			machine.Status.FailureReason = &reason
+			machine.Status.FailureMessage = pointer.String("No available host found")
+			// The cluster infrastructure is not ready, the reconciliation stops
+			// right after the failure fields are handled.
+			cluster := newCluster(clusterName, nil, &clusterv1.ClusterStatus{})
+
+			objects := []client.Object{m3m, machine, cluster}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
+			r := &Metal3MachineReconciler{
+				Client:         fakeClient,
+				ManagerFactory: baremetal.NewManagerFactory(fakeClient),
+				Log:            logr.Discard(),
+			}
+
+			_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: *getKey(metal3machineName)})
+			Expect(err).NotTo(HaveOccurred())
+
+			testMachine := &clusterv1.Machine{}
+			Expect(fakeClient.Get(context.TODO(), *getKey(machineName), testMachine)).To(Succeed())
+			testM3Machine := &infrav1.Metal3Machine{}
+			Expect(fakeClient.Get(context.TODO(), *getKey(metal3machineName), testM3Machine)).To(Succeed())
+
+			Expect(testM3Machine.Status.FailureReason).To(BeNil())
+			Expect(testM3Machine.Status.FailureMessage).To(BeNil())
+			Expect(testM3Machine.Annotations).NotTo(HaveKey(infrav1.ClearFailureAnnotation))
+			if tc.ExpectFailureCleared {
+				Expect(testMachine.Status.FailureReason).To(BeNil())
+				Expect(testMachine.Status.FailureMessage).To(BeNil())
+			} else {
+				Expect(testMachine.Status.FailureReason).NotTo(BeNil())
+				Expect(testMachine.Status.FailureMessage).NotTo(BeNil())
+			}
+		},
+		Entry("Annotation set, failure cleared on Machine", TestCaseClearFailure{
+			Annotated:            true,
+			ExpectFailureCleared: true,
+		}),
+		Entry("Annotation not set, failure kept on Machine", TestCaseClearFailure{
+			Annotated:            false,
+			ExpectFailureCleared: false,
+		}),
+	)
+
 	type TestCaseMetal3ClusterToM3M struct {
 		Cluster       *clusterv1.Cluster
 		M3Cluster     *infrav1.Metal3Cluster
@@ -479,6 +1050,335 @@ var _ = Describe("Metal3Machine manager", func() {
 		),
 	)
 
+	type TestCaseBMHToWaitingM3M struct {
+		Host          *bmov1alpha1.BareMetalHost
+		HostNamespace string
+		BMHNamespaces []string
+		M3Machines    []client.Object
+		WatchFilter   string
+		ExpectedNames []string
+	}
+
+	DescribeTable("BareMetalHost To waiting Metal3Machines tests",
+		func(tc TestCaseBMHToWaitingM3M) {
+			DeferCleanup(func(namespaces []string) { baremetal.BMHNamespaces = namespaces }, baremetal.BMHNamespaces)
+			baremetal.BMHNamespaces = tc.BMHNamespaces
+			if tc.HostNamespace != "" {
+				tc.Host.Namespace = tc.HostNamespace
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(tc.M3Machines...).Build()
+			r := Metal3MachineReconciler{
+				Client:           fakeClient,
+				Log:              logr.Discard(),
+				WatchFilterValue: tc.WatchFilter,
+			}
+			reqs := r.BareMetalHostToMetal3Machines(context.Background(), tc.Host)
+
+			names := []string{}
+			for _, req := range reqs {
+				names = append(names, req.Name)
+			}
+			Expect(names).To(ConsistOf(tc.ExpectedNames))
+		},
+		Entry("Available host, Metal3Machine waiting for a host",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+				}, nil, false),
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+					newMetal3Machine("associated-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.TrueCondition(infrav1.AssociateBMHCondition),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{"waiting-m3m"},
+			},
+		),
+		Entry("Available host, Metal3Machine waiting for a host in its failure domain",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+				}, nil, false),
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostInFailureDomainReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{"waiting-m3m"},
+			},
+		),
+		Entry("Available host, Metal3Machines of several watch-filter instances waiting for a host",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+				}, nil, false),
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m-a", &metav1.ObjectMeta{
+						Name:      "waiting-m3m-a",
+						Namespace: namespaceName,
+						Labels:    map[string]string{clusterv1.WatchLabel: "instance-a"},
+					}, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+					newMetal3Machine("waiting-m3m-b", &metav1.ObjectMeta{
+						Name:      "waiting-m3m-b",
+						Namespace: namespaceName,
+						Labels:    map[string]string{clusterv1.WatchLabel: "instance-b"},
+					}, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				WatchFilter:   "instance-a",
+				ExpectedNames: []string{"waiting-m3m-a"},
+			},
+		),
+		Entry("Available host in an allowed namespace",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+				}, nil, false),
+				HostNamespace: "inventory",
+				BMHNamespaces: []string{"inventory"},
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{"waiting-m3m"},
+			},
+		),
+		Entry("Available host in another namespace, not allowed",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+				}, nil, false),
+				HostNamespace: "inventory",
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{},
+			},
+		),
+		Entry("Host not available, no reconciliation",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateInspecting},
+				}, nil, false),
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{},
+			},
+		),
+		Entry("Available host in error, no reconciliation",
+			TestCaseBMHToWaitingM3M{
+				Host: newBareMetalHost("host1", nil, &bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{State: bmov1alpha1.StateAvailable},
+					ErrorMessage: "error",
+				}, nil, false),
+				M3Machines: []client.Object{
+					newMetal3Machine("waiting-m3m", nil, nil, &infrav1.Metal3MachineStatus{
+						Conditions: clusterv1.Conditions{
+							*conditions.FalseCondition(infrav1.AssociateBMHCondition, infrav1.NoAvailableHostReason, clusterv1.ConditionSeverityWarning, ""),
+						},
+					}, false),
+				},
+				ExpectedNames: []string{},
+			},
+		),
+	)
+
+	type TestCaseBMHChanged struct {
+		Update         func(host *bmov1alpha1.BareMetalHost)
+		Consumed       bool
+		Detached       bool
+		ExpectedResult bool
+	}
+
+	DescribeTable("BareMetalHostChanged tests",
+		func(tc TestCaseBMHChanged) {
+			oldHost := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "host1",
+					Namespace:   namespaceName,
+					Labels:      map[string]string{"foo": "bar"},
+					Annotations: map[string]string{"foo": "bar"},
+				},
+				Status: bmov1alpha1.BareMetalHostStatus{
+					Provisioning: bmov1alpha1.ProvisionStatus{
+						State: bmov1alpha1.StateProvisioning,
+					},
+				},
+			}
+			if tc.Consumed {
+				oldHost.Spec.ConsumerRef = &corev1.ObjectReference{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+				}
+			}
+			if tc.Detached {
+				oldHost.Annotations[bmov1alpha1.DetachedAnnotation] = ""
+			}
+			newHost := oldHost.DeepCopy()
+			tc.Update(newHost)
+
+			p := BareMetalHostChanged(logr.Discard())
+			Expect(p.Update(event.UpdateEvent{ObjectOld: oldHost, ObjectNew: newHost})).To(Equal(tc.ExpectedResult))
+			Expect(p.Create(event.CreateEvent{Object: newHost})).To(BeTrue())
+			Expect(p.Delete(event.DeleteEvent{Object: newHost})).To(BeTrue())
+			Expect(p.Generic(event.GenericEvent{Object: newHost})).To(BeTrue())
+		},
+		Entry("Provisioning state changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.Provisioning.State = bmov1alpha1.StateProvisioned
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Power state changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.PoweredOn = true
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Error message changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.ErrorMessage = "error"
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Operational status changed to error", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.OperationalStatus = bmov1alpha1.OperationalStatusError
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Consumed host labelled with prefer-delete", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Labels[infrav1.PreferDeleteLabel] = ""
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Hardware details changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.HardwareDetails = &bmov1alpha1.HardwareDetails{RAMMebibytes: 1024}
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Hardware profile changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.HardwareProfile = "unknown"
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Consumer removed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Spec.ConsumerRef = nil
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Deletion started", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				now := metav1.Now()
+				host.DeletionTimestamp = &now
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Labels of a host without consumer changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Labels["foo"] = "baz"
+			},
+			ExpectedResult: true,
+		}),
+		Entry("Annotations of a host without consumer changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				delete(host.Annotations, "foo")
+			},
+			ExpectedResult: true,
+		}),
+		Entry("Consumed host detached", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Annotations[bmov1alpha1.DetachedAnnotation] = ""
+			},
+			Consumed:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Consumed host attached again", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				delete(host.Annotations, bmov1alpha1.DetachedAnnotation)
+			},
+			Consumed:       true,
+			Detached:       true,
+			ExpectedResult: true,
+		}),
+		Entry("Other annotations of a consumed host changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				delete(host.Annotations, "foo")
+			},
+			Consumed: true,
+		}),
+		Entry("Labels of a consumed host changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Labels["foo"] = "baz"
+			},
+			Consumed: true,
+		}),
+		Entry("Resource version changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.ResourceVersion = "2"
+			},
+		}),
+		Entry("Last update timestamp changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				now := metav1.Now()
+				host.Status.LastUpdated = &now
+			},
+			Consumed: true,
+		}),
+		Entry("Operational status changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Status.OperationalStatus = bmov1alpha1.OperationalStatusDiscovered
+			},
+			Consumed: true,
+		}),
+		Entry("Image changed", TestCaseBMHChanged{
+			Update: func(host *bmov1alpha1.BareMetalHost) {
+				host.Spec.Image = &bmov1alpha1.Image{URL: "http://example.com/image"}
+			},
+			Consumed: true,
+		}),
+	)
+
 	type TestCaseM3DToM3M struct {
 		OwnerRef      *metav1.OwnerReference
 		ExpectRequest bool
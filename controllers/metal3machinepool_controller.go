@@ -0,0 +1,287 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const (
+	machinePoolControllerName = "Metal3MachinePool-controller"
+	// Metal3MachinePool is the kind of the consumerRef of the BareMetalHosts
+	// of a Metal3MachinePool.
+	Metal3MachinePool = "Metal3MachinePool"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machinepools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machinepools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;update;patch
+
+// Metal3MachinePoolReconciler reconciles a Metal3MachinePool object.
+type Metal3MachinePoolReconciler struct {
+	Client           client.Client
+	ManagerFactory   baremetal.ManagerFactoryInterface
+	Log              logr.Logger
+	CapiClientGetter baremetal.ClientGetter
+	WatchFilterValue string
+	Shard            ShardOptions
+}
+
+// Reconcile handles Metal3MachinePool events.
+func (r *Metal3MachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
+	machinePoolLog := r.Log.WithName(machinePoolControllerName).WithValues("metal3-machine-pool", req.NamespacedName)
+	defer baremetal.LogDuration(machinePoolLog, "reconcile", time.Now())
+
+	// Fetch the Metal3MachinePool instance.
+	metal3MachinePool := &infrav1.Metal3MachinePool{}
+
+	if err := r.Client.Get(ctx, req.NamespacedName, metal3MachinePool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, errors.Wrap(err, "unable to fetch Metal3MachinePool")
+	}
+
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(metal3MachinePool, r.WatchFilterValue, r.Shard) {
+		machinePoolLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	// Always patch metal3MachinePool exiting this function so we can persist any Metal3MachinePool changes.
+	patchHelper, err := patch.NewHelper(metal3MachinePool, r.Client)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
+	}
+	defer func() {
+		if err := patchMetal3MachinePool(ctx, patchHelper, metal3MachinePool); err != nil {
+			machinePoolLog.Error(err, "failed to Patch metal3MachinePool")
+			rerr = err
+		}
+	}()
+
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(metal3MachinePool, r.WatchFilterValue, r.Shard) {
+		machinePoolLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
+	// Fetch the MachinePool.
+	machinePool, err := exputil.GetOwnerMachinePool(ctx, r.Client, metal3MachinePool.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "Metal3MachinePool's owner MachinePool could not be retrieved")
+	}
+	if machinePool == nil {
+		machinePoolLog.Info("Waiting for MachinePool Controller to set OwnerRef on Metal3MachinePool")
+		return ctrl.Result{}, nil
+	}
+
+	machinePoolLog = machinePoolLog.WithValues("machine-pool", machinePool.Name)
+
+	// Fetch the Cluster.
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machinePool.ObjectMeta)
+	if err != nil {
+		machinePoolLog.Info("MachinePool is missing cluster label or cluster does not exist")
+		return ctrl.Result{}, nil
+	}
+
+	machinePoolLog = machinePoolLog.WithValues("cluster", cluster.Name)
+
+	// Return early if the Metal3MachinePool or Cluster is paused.
+	isPaused := annotations.IsPaused(cluster, metal3MachinePool) || hasPausedAnnotation(metal3MachinePool)
+	setPausedCondition(metal3MachinePool, isPaused)
+	if isPaused {
+		machinePoolLog.Info("reconciliation is paused for this object")
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+	}
+
+	// Create a helper for managing the BareMetalHosts of the pool.
+	machinePoolMgr, err := r.ManagerFactory.NewMachinePoolManager(cluster, machinePool, metal3MachinePool, machinePoolLog)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the machinePoolMgr")
+	}
+
+	// Handle deleted machine pools
+	if !metal3MachinePool.ObjectMeta.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, machinePoolMgr)
+	}
+
+	// Make sure infrastructure is ready
+	if !cluster.Status.InfrastructureReady {
+		machinePoolLog.Info("Waiting for Metal3Cluster Controller to create cluster infrastructure")
+		conditions.MarkFalse(metal3MachinePool, infrav1.HostsAssociatedCondition, infrav1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	// Handle non-deleted machine pools
+	kubeconfig := &kubeconfigCondition{obj: metal3MachinePool, getter: r.CapiClientGetter}
+	return r.reconcileNormal(ctx, machinePoolMgr, metal3MachinePool, cluster, kubeconfig)
+}
+
+// patchMetal3MachinePool summarizes the conditions of the Metal3MachinePool
+// in its Ready condition and patches it.
+func patchMetal3MachinePool(ctx context.Context, patchHelper *patch.Helper, metal3MachinePool *infrav1.Metal3MachinePool) error {
+	conditions.SetSummary(metal3MachinePool,
+		conditions.WithConditions(infrav1.HostsAssociatedCondition),
+	)
+	now := metav1.Now()
+	metal3MachinePool.Status.LastUpdated = &now
+
+	return patchHelper.Patch(ctx, metal3MachinePool,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrav1.HostsAssociatedCondition,
+			infrav1.WorkloadClusterKubeconfigAvailableCondition,
+			infrav1.PausedCondition,
+		}},
+		patch.WithStatusObservedGeneration{},
+	)
+}
+
+func (r *Metal3MachinePoolReconciler) reconcileNormal(ctx context.Context,
+	machinePoolMgr baremetal.MachinePoolManagerInterface, metal3MachinePool *infrav1.Metal3MachinePool,
+	cluster *clusterv1.Cluster, kubeconfig *kubeconfigCondition,
+) (ctrl.Result, error) {
+	// If the Metal3MachinePool doesn't have finalizer, add it.
+	machinePoolMgr.SetFinalizer()
+
+	// Make sure bootstrap data is available and populated. If not, return,
+	// we will get an event from the MachinePool update when it is.
+	if !machinePoolMgr.IsBootstrapReady() {
+		conditions.MarkFalse(metal3MachinePool, infrav1.HostsAssociatedCondition, infrav1.WaitingForBootstrapReadyReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	// Associate or release hosts until the pool has as many as the replicas
+	// of the MachinePool. The hosts may still be short or releasing, the
+	// providerIDs of the provisioned ones are set regardless.
+	hostsErr := machinePoolMgr.ReconcileHosts(ctx)
+	if hostsErr != nil && !isTransientError(hostsErr) {
+		return ctrl.Result{}, errors.Wrap(hostsErr, "failed to reconcile the BareMetalHosts of the Metal3MachinePool")
+	}
+
+	// The Nodes are only registered once the control plane is initialized.
+	if conditions.IsTrue(cluster, clusterv1.ControlPlaneInitializedCondition) {
+		err := machinePoolMgr.UpdateProviderIDs(ctx, kubeconfig.clientGetter)
+		// A missing or invalid kubeconfig is not a failure, the
+		// Metal3MachinePool is requeued.
+		if kubeconfig.update(err) {
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+		if err != nil {
+			return checkMachinePoolError(err, "failed to update the providerIDs of the Metal3MachinePool")
+		}
+	}
+
+	return checkMachinePoolError(hostsErr, "failed to reconcile the BareMetalHosts of the Metal3MachinePool")
+}
+
+func (r *Metal3MachinePoolReconciler) reconcileDelete(ctx context.Context,
+	machinePoolMgr baremetal.MachinePoolManagerInterface,
+) (ctrl.Result, error) {
+	// Release the hosts, the Nodes are not drained.
+	if err := machinePoolMgr.Delete(ctx); err != nil {
+		return checkMachinePoolError(err, "failed to delete Metal3MachinePool")
+	}
+
+	// metal3MachinePool is marked for deletion and ready to be deleted,
+	// so remove the finalizer.
+	machinePoolMgr.UnsetFinalizer()
+
+	return ctrl.Result{}, nil
+}
+
+// checkMachinePoolError requeues the Metal3MachinePool on a transient error
+// and wraps any other error.
+func checkMachinePoolError(err error, errMessage string) (ctrl.Result, error) {
+	if err == nil {
+		return ctrl.Result{}, nil
+	}
+	var reconcileError baremetal.ReconcileError
+	if errors.As(err, &reconcileError) && reconcileError.IsTransient() {
+		return ctrl.Result{Requeue: true, RequeueAfter: reconcileError.GetRequeueAfter()}, nil
+	}
+	return ctrl.Result{}, errors.Wrap(err, errMessage)
+}
+
+// SetupWithManager will add watches for this controller.
+func (r *Metal3MachinePoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.Metal3MachinePool{}).
+		WithOptions(options).
+		WithEventFilter(ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)).
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
+		Watches(
+			&expv1.MachinePool{},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(
+				infrav1.GroupVersion.WithKind(Metal3MachinePool), ctrl.LoggerFrom(ctx),
+			)),
+		).
+		Watches(
+			&bmov1alpha1.BareMetalHost{},
+			handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3MachinePool),
+		).
+		Complete(r)
+}
+
+// BareMetalHostToMetal3MachinePool will return a reconcile request for a
+// Metal3MachinePool if the event is for a BareMetalHost consumed by the
+// Metal3MachinePool.
+func (r *Metal3MachinePoolReconciler) BareMetalHostToMetal3MachinePool(_ context.Context, obj client.Object) []ctrl.Request {
+	host, ok := obj.(*bmov1alpha1.BareMetalHost)
+	if !ok {
+		r.Log.Error(errors.Errorf("expected a BareMetalHost but got a %T", obj),
+			"failed to get Metal3MachinePool for BareMetalHost",
+		)
+		return []ctrl.Request{}
+	}
+	if host.Spec.ConsumerRef == nil ||
+		host.Spec.ConsumerRef.Kind != Metal3MachinePool ||
+		host.Spec.ConsumerRef.GroupVersionKind().Group != infrav1.GroupVersion.Group {
+		return []ctrl.Request{}
+	}
+	return []ctrl.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      host.Spec.ConsumerRef.Name,
+				Namespace: host.Spec.ConsumerRef.Namespace,
+			},
+		},
+	}
+}
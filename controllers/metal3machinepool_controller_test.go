@@ -0,0 +1,224 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type reconcileMachinePoolTestCase struct {
+	noOwner            bool
+	paused             bool
+	deleted            bool
+	bootstrapNotReady  bool
+	controlPlaneNotUp  bool
+	reconcileHostsErr  error
+	updateProviderErr  error
+	deleteErr          error
+	expectedResult     ctrl.Result
+	expectError        bool
+	expectedReason     string
+	expectProviderIDs  bool
+	expectUnsetFinal   bool
+	expectReconcileRun bool
+}
+
+var _ = Describe("Metal3MachinePool controller", func() {
+	const poolName = "pool"
+	poolRequest := ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: poolName, Namespace: namespaceName},
+	}
+
+	DescribeTable("Metal3MachinePool Reconcile test",
+		func(tc reconcileMachinePoolTestCase) {
+			mockController := gomock.NewController(GinkgoT())
+			m := baremetal_mocks.NewMockMachinePoolManagerInterface(mockController)
+			mf := baremetal_mocks.NewMockManagerFactoryInterface(mockController)
+
+			pool := &infrav1.Metal3MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       poolName,
+					Namespace:  namespaceName,
+					Finalizers: []string{infrav1.MachinePoolFinalizer},
+				},
+			}
+			if !tc.noOwner {
+				pool.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: expv1.GroupVersion.String(),
+					Kind:       "MachinePool",
+					Name:       poolName,
+				}}
+			}
+			if tc.paused {
+				pool.Annotations = map[string]string{clusterv1.PausedAnnotation: ""}
+			}
+			if tc.deleted {
+				pool.DeletionTimestamp = &timestampNow
+			}
+			machinePool := &expv1.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespaceName,
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+				},
+				Spec: expv1.MachinePoolSpec{ClusterName: clusterName},
+			}
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: namespaceName},
+				Status:     clusterv1.ClusterStatus{InfrastructureReady: true},
+			}
+			if !tc.controlPlaneNotUp {
+				conditions.MarkTrue(cluster, clusterv1.ControlPlaneInitializedCondition)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).
+				WithObjects(pool, machinePool, cluster).
+				WithStatusSubresource(pool).Build()
+
+			r := &Metal3MachinePoolReconciler{
+				Client:         fakeClient,
+				ManagerFactory: mf,
+				Log:            logr.Discard(),
+			}
+
+			if !tc.noOwner && !tc.paused {
+				mf.EXPECT().NewMachinePoolManager(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(m, nil)
+			}
+			if tc.deleted {
+				m.EXPECT().Delete(gomock.Any()).Return(tc.deleteErr)
+			} else if !tc.noOwner && !tc.paused {
+				m.EXPECT().SetFinalizer()
+				m.EXPECT().IsBootstrapReady().Return(!tc.bootstrapNotReady)
+			}
+			if tc.expectReconcileRun {
+				m.EXPECT().ReconcileHosts(gomock.Any()).Return(tc.reconcileHostsErr)
+			}
+			if tc.expectProviderIDs {
+				m.EXPECT().UpdateProviderIDs(gomock.Any(), gomock.Any()).Return(tc.updateProviderErr)
+			}
+			if tc.expectUnsetFinal {
+				m.EXPECT().UnsetFinalizer()
+			}
+
+			result, err := r.Reconcile(context.TODO(), poolRequest)
+			if tc.expectError {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(result).To(Equal(tc.expectedResult))
+
+			if tc.expectedReason != "" {
+				updated := &infrav1.Metal3MachinePool{}
+				Expect(fakeClient.Get(context.TODO(), poolRequest.NamespacedName, updated)).To(Succeed())
+				Expect(conditions.GetReason(updated, infrav1.HostsAssociatedCondition)).To(Equal(tc.expectedReason))
+			}
+			mockController.Finish()
+		},
+		Entry("waits for the owner MachinePool", reconcileMachinePoolTestCase{
+			noOwner: true,
+		}),
+		Entry("requeues a paused pool", reconcileMachinePoolTestCase{
+			paused:         true,
+			expectedResult: ctrl.Result{Requeue: true, RequeueAfter: requeueAfter},
+		}),
+		Entry("waits for the bootstrap data", reconcileMachinePoolTestCase{
+			bootstrapNotReady: true,
+			expectedReason:    infrav1.WaitingForBootstrapReadyReason,
+		}),
+		Entry("reconciles the hosts and the providerIDs", reconcileMachinePoolTestCase{
+			expectReconcileRun: true,
+			expectProviderIDs:  true,
+		}),
+		Entry("does not set the providerIDs before the control plane is initialized", reconcileMachinePoolTestCase{
+			controlPlaneNotUp:  true,
+			expectReconcileRun: true,
+		}),
+		Entry("sets the providerIDs while hosts are missing", reconcileMachinePoolTestCase{
+			reconcileHostsErr:  baremetal.WithTransientError(baremetal.ErrNoAvailableHost, requeueAfter),
+			expectReconcileRun: true,
+			expectProviderIDs:  true,
+			expectedResult:     ctrl.Result{Requeue: true, RequeueAfter: requeueAfter},
+		}),
+		Entry("fails on a host reconciliation error", reconcileMachinePoolTestCase{
+			reconcileHostsErr:  errors.New("failed"),
+			expectReconcileRun: true,
+			expectError:        true,
+		}),
+		Entry("fails on a providerID error", reconcileMachinePoolTestCase{
+			updateProviderErr:  errors.New("failed"),
+			expectReconcileRun: true,
+			expectProviderIDs:  true,
+			expectError:        true,
+		}),
+		Entry("requeues while the hosts are released", reconcileMachinePoolTestCase{
+			deleted:        true,
+			deleteErr:      baremetal.WithTransientError(errors.New("releasing"), requeueAfter),
+			expectedResult: ctrl.Result{Requeue: true, RequeueAfter: requeueAfter},
+		}),
+		Entry("removes the finalizer once the hosts are released", reconcileMachinePoolTestCase{
+			deleted:          true,
+			expectUnsetFinal: true,
+		}),
+	)
+
+	DescribeTable("BareMetalHost To Metal3MachinePool tests",
+		func(consumerRef *corev1.ObjectReference, expectRequest bool) {
+			r := Metal3MachinePoolReconciler{}
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: "host", Namespace: namespaceName},
+				Spec:       bmov1alpha1.BareMetalHostSpec{ConsumerRef: consumerRef},
+			}
+			reqs := r.BareMetalHostToMetal3MachinePool(context.TODO(), host)
+			if expectRequest {
+				Expect(reqs).To(Equal([]ctrl.Request{poolRequest}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+		},
+		Entry("host of the pool", &corev1.ObjectReference{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       Metal3MachinePool,
+			Name:       poolName,
+			Namespace:  namespaceName,
+		}, true),
+		Entry("host of a Metal3Machine", &corev1.ObjectReference{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       Metal3Machine,
+			Name:       poolName,
+			Namespace:  namespaceName,
+		}, false),
+		Entry("host without consumer", nil, false),
+	)
+})
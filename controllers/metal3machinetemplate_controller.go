@@ -15,8 +15,10 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	"github.com/pkg/errors"
@@ -25,7 +27,6 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/patch"
-	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -39,8 +40,11 @@ const (
 )
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machinetemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machinetemplates/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machines,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3machines/status,verbs=get
+// +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 
 // Metal3MachineTemplateReconciler reconciles a Metal3MachineTemplate object.
 type Metal3MachineTemplateReconciler struct {
@@ -48,11 +52,13 @@ type Metal3MachineTemplateReconciler struct {
 	ManagerFactory   baremetal.ManagerFactoryInterface
 	Log              logr.Logger
 	WatchFilterValue string
+	Shard            ShardOptions
 }
 
 // Reconcile handles Metal3MachineTemplate events.
 func (r *Metal3MachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	m3templateLog := r.Log.WithName(templateControllerName).WithValues("metal3-machine-template", req.NamespacedName)
+	defer baremetal.LogDuration(m3templateLog, "reconcile", time.Now())
 
 	// Fetch the Metal3MachineTemplate instance.
 	metal3MachineTemplate := &infrav1.Metal3MachineTemplate{}
@@ -65,6 +71,13 @@ func (r *Metal3MachineTemplateReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{}, errors.Wrap(err, "unable to fetch Metal3MachineTemplate")
 	}
 
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(metal3MachineTemplate, r.WatchFilterValue, r.Shard) {
+		m3templateLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
 	helper, err := patch.NewHelper(metal3MachineTemplate, r.Client)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to init patch helper")
@@ -78,6 +91,11 @@ func (r *Metal3MachineTemplateReconciler) Reconcile(ctx context.Context, req ctr
 		}
 	}()
 
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(metal3MachineTemplate, r.WatchFilterValue, r.Shard) {
+		m3templateLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
 	// Fetch the Metal3MachineList
 	m3machinelist := &infrav1.Metal3MachineList{}
 
@@ -92,7 +110,9 @@ func (r *Metal3MachineTemplateReconciler) Reconcile(ctx context.Context, req ctr
 	}
 
 	// Return early if the Metal3MachineTemplate is paused.
-	if annotations.HasPaused(metal3MachineTemplate) {
+	isPaused := annotations.HasPaused(metal3MachineTemplate) || hasPausedAnnotation(metal3MachineTemplate)
+	setPausedCondition(metal3MachineTemplate, isPaused)
+	if isPaused {
 		m3templateLog.Info("Metal3MachineTemplate is currently paused. Remove pause annotation to continue reconciliation.")
 		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
 	}
@@ -111,6 +131,24 @@ func (r *Metal3MachineTemplateReconciler) reconcileNormal(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
+	// Report the Metal3Machines that differ from the template, e.g. after an
+	// update allowed with the allow-template-update annotation.
+	if err := templateMgr.UpdateTemplateDrift(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the template drift")
+	}
+
+	// Record the template revision on the new Metal3Machines and count the
+	// ones cloned from an older revision.
+	if err := templateMgr.UpdateOutOfDateReplicas(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the out-of-date replicas")
+	}
+
+	// Report the hosts released by the Metal3Machines that are still reserved
+	// for node reuse.
+	if err := templateMgr.UpdateNodeReuseReservations(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the node reuse reservations")
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -123,7 +161,12 @@ func (r *Metal3MachineTemplateReconciler) SetupWithManager(ctx context.Context,
 			&infrav1.Metal3Machine{},
 			handler.EnqueueRequestsFromMapFunc(r.Metal3MachinesToMetal3MachineTemplate),
 		).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&bmov1alpha1.BareMetalHost{},
+			handler.EnqueueRequestsFromMapFunc(r.BareMetalHostToMetal3MachineTemplate),
+		).
+		WithEventFilter(ResourceNotPausedAndHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)).
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
 		Complete(r)
 }
 
@@ -148,3 +191,23 @@ func (r *Metal3MachineTemplateReconciler) Metal3MachinesToMetal3MachineTemplate(
 	}
 	return result
 }
+
+// BareMetalHostToMetal3MachineTemplate is a handler.ToRequestsFunc to be used
+// to enqueue requests for the Metal3MachineTemplate whose Metal3Machine
+// released a BareMetalHost with the node reuse label.
+func (r *Metal3MachineTemplateReconciler) BareMetalHostToMetal3MachineTemplate(_ context.Context, o client.Object) []ctrl.Request {
+	host, ok := o.(*bmov1alpha1.BareMetalHost)
+	if !ok {
+		r.Log.Error(errors.Errorf("expected a BareMetalHost but got a %T", o),
+			"failed to get Metal3MachineTemplate for BareMetalHost",
+		)
+		return nil
+	}
+	name := host.Annotations[infrav1.HostNodeReuseTemplateAnnotation]
+	if name == "" {
+		return nil
+	}
+	return []ctrl.Request{{
+		NamespacedName: types.NamespacedName{Name: name, Namespace: host.Namespace},
+	}}
+}
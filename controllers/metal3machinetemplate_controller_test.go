@@ -25,6 +25,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/golang/mock/gomock"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
@@ -32,6 +33,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	utils "k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -55,6 +57,9 @@ type reconcileTemplateTestCase struct {
 type reconcileTemplateNormalTestCase struct {
 	common                            commonTestCase
 	failedUpdateAutomatedCleaningMode bool
+	failedUpdateTemplateDrift         bool
+	failedUpdateOutOfDateReplicas     bool
+	failedUpdateReservations          bool
 }
 
 var _ = Describe("Metal3MachineTemplate controller", func() {
@@ -166,6 +171,25 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 			},
 		),
 	)
+	DescribeTable("BareMetalHost To Metal3MachineTemplate tests",
+		func(annotations map[string]string, expectRequest bool) {
+			r := Metal3MachineTemplateReconciler{}
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{Name: "host", Namespace: namespaceName, Annotations: annotations},
+			}
+			reqs := r.BareMetalHostToMetal3MachineTemplate(context.TODO(), host)
+			if expectRequest {
+				Expect(reqs).To(Equal([]ctrl.Request{{
+					NamespacedName: types.NamespacedName{Name: name, Namespace: namespaceName},
+				}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+		},
+		Entry("host released by a template", map[string]string{infrav1.HostNodeReuseTemplateAnnotation: name}, true),
+		Entry("host without node reuse reservation", nil, false),
+	)
+
 	DescribeTable("Metal3MachineTemplate Reconcile test",
 		func(tc reconcileTemplateTestCase) {
 			mockController = gomock.NewController(GinkgoT())
@@ -180,7 +204,7 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 				if tc.common.m3mTemplate != nil {
 					objects = append(objects, tc.common.m3mTemplate)
 				}
-				fakeClient = fakeClientBuilder.WithScheme(setupScheme()).WithObjects(objects...).Build()
+				fakeClient = fakeClientBuilder.WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
 			}
 
 			testReconciler = &Metal3MachineTemplateReconciler{
@@ -201,11 +225,19 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 					gomock.Any()).Return(m, nil)
 				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(
 					nil)
+				m.EXPECT().UpdateTemplateDrift(context.TODO()).Return(nil)
+				m.EXPECT().UpdateOutOfDateReplicas(context.TODO()).Return(nil)
+				m.EXPECT().UpdateNodeReuseReservations(context.TODO()).Return(nil)
 			}
 
 			result, err := testReconciler.Reconcile(context.TODO(), tc.common.testRequest)
 			Expect(result).To(Equal(tc.common.expectedResult))
 			evaluateTestError(tc.common.expectedError, err)
+			if tc.m3mTemplateIsPaused {
+				m3mTemplate := &infrav1.Metal3MachineTemplate{}
+				Expect(fakeClient.Get(context.TODO(), tc.common.testRequest.NamespacedName, m3mTemplate)).To(Succeed())
+				Expect(conditions.IsTrue(m3mTemplate, infrav1.PausedCondition)).To(BeTrue())
+			}
 			mockController.Finish()
 		},
 		Entry("M3MTemplate haven't been found",
@@ -245,6 +277,21 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 				},
 				m3mTemplateIsPaused: true,
 			}),
+		Entry("Metal3MachineTemplate is paused with the CAPM3 annotation",
+			reconcileTemplateTestCase{
+				common: commonTestCase{
+					testRequest:    defaultTestRequest,
+					expectedResult: ctrl.Result{Requeue: true, RequeueAfter: requeueAfter},
+					expectedError:  nil,
+					m3mTemplate: newMetal3MachineTemplate(
+						metal3DataTemplateName,
+						namespaceName,
+						map[string]string{
+							infrav1.PausedAnnotation: "",
+						}),
+				},
+				m3mTemplateIsPaused: true,
+			}),
 		Entry("updateAutomatedCleaningMode should Succeed through normalReconcile call",
 			reconcileTemplateTestCase{
 				common: commonTestCase{
@@ -273,9 +320,24 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 			if tc.failedUpdateAutomatedCleaningMode {
 				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(
 					errors.New(""))
+			} else if tc.failedUpdateTemplateDrift {
+				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(nil)
+				m.EXPECT().UpdateTemplateDrift(context.TODO()).Return(errors.New(""))
+			} else if tc.failedUpdateOutOfDateReplicas {
+				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(nil)
+				m.EXPECT().UpdateTemplateDrift(context.TODO()).Return(nil)
+				m.EXPECT().UpdateOutOfDateReplicas(context.TODO()).Return(errors.New(""))
+			} else if tc.failedUpdateReservations {
+				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(nil)
+				m.EXPECT().UpdateTemplateDrift(context.TODO()).Return(nil)
+				m.EXPECT().UpdateOutOfDateReplicas(context.TODO()).Return(nil)
+				m.EXPECT().UpdateNodeReuseReservations(context.TODO()).Return(errors.New(""))
 			} else if tc.common.shouldUpdateAutomatedCleaningMode {
 				m.EXPECT().UpdateAutomatedCleaningMode(context.TODO()).Return(
 					nil)
+				m.EXPECT().UpdateTemplateDrift(context.TODO()).Return(nil)
+				m.EXPECT().UpdateOutOfDateReplicas(context.TODO()).Return(nil)
+				m.EXPECT().UpdateNodeReuseReservations(context.TODO()).Return(nil)
 			}
 
 			testReconciler = &Metal3MachineTemplateReconciler{
@@ -302,6 +364,42 @@ var _ = Describe("Metal3MachineTemplate controller", func() {
 				},
 				failedUpdateAutomatedCleaningMode: true,
 			}),
+		Entry("updateTemplateDrift should Fail",
+			reconcileTemplateNormalTestCase{
+				common: commonTestCase{
+					testRequest:    defaultTestRequest,
+					expectedResult: ctrl.Result{},
+					expectedError:  utils.String("failed to update the template drift"),
+					m3mTemplate: newMetal3MachineTemplate(metal3DataTemplateName,
+						namespaceName,
+						map[string]string{}),
+				},
+				failedUpdateTemplateDrift: true,
+			}),
+		Entry("updateOutOfDateReplicas should Fail",
+			reconcileTemplateNormalTestCase{
+				common: commonTestCase{
+					testRequest:    defaultTestRequest,
+					expectedResult: ctrl.Result{},
+					expectedError:  utils.String("failed to update the out-of-date replicas"),
+					m3mTemplate: newMetal3MachineTemplate(metal3DataTemplateName,
+						namespaceName,
+						map[string]string{}),
+				},
+				failedUpdateOutOfDateReplicas: true,
+			}),
+		Entry("updateNodeReuseReservations should Fail",
+			reconcileTemplateNormalTestCase{
+				common: commonTestCase{
+					testRequest:    defaultTestRequest,
+					expectedResult: ctrl.Result{},
+					expectedError:  utils.String("failed to update the node reuse reservations"),
+					m3mTemplate: newMetal3MachineTemplate(metal3DataTemplateName,
+						namespaceName,
+						map[string]string{}),
+				},
+				failedUpdateReservations: true,
+			}),
 		Entry("updateAutomatedCleaningMode should Succeed",
 			reconcileTemplateNormalTestCase{
 				common: commonTestCase{
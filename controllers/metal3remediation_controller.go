@@ -26,33 +26,50 @@ import (
 
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 )
 
 // Metal3RemediationReconciler reconciles a Metal3Remediation object.
 type Metal3RemediationReconciler struct {
 	client.Client
-	ManagerFactory baremetal.ManagerFactoryInterface
-	Log            logr.Logger
+	ManagerFactory   baremetal.ManagerFactoryInterface
+	Log              logr.Logger
+	WatchFilterValue string
+	Shard            ShardOptions
+	// Tracker, when set, is used to watch the Nodes of the workload clusters.
+	Tracker *remote.ClusterCacheTracker
+	// Recorder, when set, records the events of the remediations.
+	Recorder record.EventRecorder
+
+	controller controller.Controller
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3remediations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metal3remediations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 
 // Reconcile handles Metal3Remediation events.
 func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
 	remediationLog := r.Log.WithValues("metal3remediation", req.NamespacedName)
+	defer baremetal.LogDuration(remediationLog, "reconcile", time.Now())
 
 	// Fetch the Metal3Remediation instance.
 	metal3Remediation := &infrav1.Metal3Remediation{}
@@ -64,6 +81,13 @@ func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, err
 	}
 
+	// Requests for objects of another shard, e.g. mapped from a watched
+	// object, are left to the instance of that shard.
+	if ownedByOtherShard(metal3Remediation, r.WatchFilterValue, r.Shard) {
+		remediationLog.V(4).Info("Object belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
 	helper, err := patch.NewHelper(metal3Remediation, r.Client)
 	if err != nil {
 		remediationLog.Error(err, "failed to init patch helper")
@@ -72,11 +96,7 @@ func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	defer func() {
 		// Always attempt to Patch the Remediation object and status after each reconciliation.
-		// Patch ObservedGeneration only if the reconciliation completed successfully
-		patchOpts := []patch.Option{}
-		patchOpts = append(patchOpts, patch.WithStatusObservedGeneration{})
-
-		patchErr := helper.Patch(ctx, metal3Remediation, patchOpts...)
+		patchErr := patchMetal3Remediation(ctx, helper, metal3Remediation)
 		if patchErr != nil {
 			remediationLog.Error(patchErr, "failed to Patch metal3Remediation")
 			// trigger requeue!
@@ -84,13 +104,32 @@ func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 	}()
 
+	// Label objects without watch-filter label that belong to this shard.
+	if adoptObject(metal3Remediation, r.WatchFilterValue, r.Shard) {
+		remediationLog.Info("Adopted object without watch-filter label", "label", clusterv1.WatchLabel, "value", r.WatchFilterValue)
+	}
+
+	// Return early if the Metal3Remediation is paused.
+	isPaused := hasPausedAnnotation(metal3Remediation)
+	setPausedCondition(metal3Remediation, isPaused)
+	if isPaused {
+		remediationLog.Info("reconciliation is paused for this object")
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+	}
+
 	// Fetch the Machine.
 	capiMachine, err := util.GetOwnerMachine(ctx, r.Client, metal3Remediation.ObjectMeta)
 	if err != nil {
+		if apierrors.IsNotFound(err) && metal3Remediation.Status.HostRef != nil {
+			return r.reconcileOrphaned(ctx, metal3Remediation, nil, remediationLog)
+		}
 		remediationLog.Error(err, "metal3Remediation's owner Machine could not be retrieved")
 		return ctrl.Result{}, errors.Wrapf(err, "metal3Remediation's owner Machine could not be retrieved")
 	}
 	if capiMachine == nil {
+		if metal3Remediation.Status.HostRef != nil {
+			return r.reconcileOrphaned(ctx, metal3Remediation, nil, remediationLog)
+		}
 		remediationLog.Info("metal3Remediation's owner Machine not set")
 		return ctrl.Result{}, errors.New("metal3Remediation's owner Machine not set")
 	}
@@ -104,12 +143,31 @@ func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	err = r.Get(ctx, key, &metal3Machine)
 	if err != nil {
+		if apierrors.IsNotFound(err) && metal3Remediation.Status.HostRef != nil {
+			return r.reconcileOrphaned(ctx, metal3Remediation, capiMachine, remediationLog)
+		}
 		remediationLog.Error(err, "metal3machine not found")
 		return ctrl.Result{}, errors.Wrapf(err, "metal3machine not found")
 	}
 
 	remediationLog = remediationLog.WithValues("metal3machine", metal3Machine.Name)
 
+	// Watch the Nodes of the workload cluster, so that the recreation of the
+	// Node is noticed without waiting for the next requeue.
+	if r.Tracker != nil {
+		cluster, err := util.GetClusterFromMetadata(ctx, r.Client, capiMachine.ObjectMeta)
+		if err == nil {
+			err = watchClusterNodes(ctx, r.Tracker, r.controller, "metal3remediation-watchNodes", cluster, r.NodeToMetal3Remediation)
+		}
+		if errors.Is(err, remote.ErrClusterLocked) {
+			remediationLog.V(5).Info("Requeuing because another worker has the lock on the ClusterCacheTracker")
+			return ctrl.Result{Requeue: true}, nil
+		}
+		if err != nil {
+			remediationLog.Error(err, "failed to watch Nodes of the workload cluster")
+		}
+	}
+
 	// Create a helper for managing the remediation object.
 	remediationMgr, err := r.ManagerFactory.NewRemediationManager(metal3Remediation, &metal3Machine, capiMachine, remediationLog)
 	if err != nil {
@@ -118,11 +176,64 @@ func (r *Metal3RemediationReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 
 	// Handle both deleted and non-deleted remediations
-	return r.reconcileNormal(ctx, remediationMgr)
+	return r.reconcileNormal(ctx, metal3Remediation, remediationMgr)
+}
+
+func patchMetal3Remediation(ctx context.Context, patchHelper *patch.Helper, metal3Remediation *infrav1.Metal3Remediation) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	setHostRemediatedCondition(metal3Remediation)
+	conditions.SetSummary(metal3Remediation,
+		conditions.WithConditions(
+			infrav1.HostRemediatedCondition,
+		),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(ctx, metal3Remediation,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrav1.HostRemediatedCondition,
+			infrav1.NodeDrainedCondition,
+			infrav1.WorkloadClusterKubeconfigAvailableCondition,
+			infrav1.PausedCondition,
+		}},
+		patch.WithStatusObservedGeneration{},
+	)
+}
+
+// setHostRemediatedCondition sets the HostRemediatedCondition from the phase
+// of the remediation and the outcome of its last attempt. It is not set
+// before the remediation starts.
+func setHostRemediatedCondition(metal3Remediation *infrav1.Metal3Remediation) {
+	status := metal3Remediation.Status
+	switch status.Phase {
+	case infrav1.PhaseRunning, infrav1.PhaseWaiting:
+		if n := len(status.History); n > 0 && status.History[n-1].Outcome == infrav1.RemediationOutcomeSucceeded {
+			conditions.MarkTrue(metal3Remediation, infrav1.HostRemediatedCondition)
+			return
+		}
+		conditions.MarkFalse(metal3Remediation, infrav1.HostRemediatedCondition,
+			infrav1.RemediationInProgressReason, clusterv1.ConditionSeverityInfo,
+			"Remediation in phase %s, retry count %d", status.Phase, status.RetryCount)
+	case infrav1.PhaseDeprovisioning:
+		conditions.MarkFalse(metal3Remediation, infrav1.HostRemediatedCondition,
+			infrav1.RemediationEscalatedReason, clusterv1.ConditionSeverityWarning,
+			"Rebooting did not remediate the host, deprovisioning it")
+	case infrav1.PhaseDone:
+		conditions.MarkTrue(metal3Remediation, infrav1.HostRemediatedCondition)
+	case infrav1.PhaseDeleting:
+		conditions.MarkFalse(metal3Remediation, infrav1.HostRemediatedCondition,
+			infrav1.RemediationFailedReason, clusterv1.ConditionSeverityError,
+			"Rebooting did not remediate the host, the Machine is deleted")
+	case infrav1.PhaseFailed:
+		conditions.MarkFalse(metal3Remediation, infrav1.HostRemediatedCondition,
+			infrav1.RemediationFailedReason, clusterv1.ConditionSeverityError,
+			"The host is powered off (spec.online is false)")
+	}
 }
 
 func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
-	remediationMgr baremetal.RemediationManagerInterface,
+	metal3Remediation *infrav1.Metal3Remediation, remediationMgr baremetal.RemediationManagerInterface,
 ) (ctrl.Result, error) {
 	// If host is gone, exit early
 	host, _, err := remediationMgr.GetUnhealthyHost(ctx)
@@ -135,29 +246,48 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 	// do not try to remediate the host
 	if !remediationMgr.OnlineStatus(host) {
 		r.Log.Info("Unable to remediate, Host is powered off (spec.Online is false)")
+		if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+			r.Log.Error(err, "error removing the remediation delete hook")
+			return ctrl.Result{}, err
+		}
 		remediationMgr.SetRemediationPhase(infrav1.PhaseFailed)
 		return ctrl.Result{}, nil
 	}
 
+	// Remember the host, in case the Machine and the Metal3Machine are deleted
+	// before the remediation is done.
+	remediationMgr.SetRemediatedHost(host)
+
 	remediationType := remediationMgr.GetRemediationType()
 
-	if remediationType != infrav1.RebootRemediationStrategy {
+	if remediationType != infrav1.RebootRemediationStrategy && remediationType != infrav1.EscalateRemediationStrategy {
 		r.Log.Info("unsupported remediation strategy")
 		return ctrl.Result{}, nil
 	}
 
-	if remediationType == infrav1.RebootRemediationStrategy {
+	// The escalate strategy reboots the host like the reboot strategy until the
+	// retry limit is reached, and then deprovisions it.
+	if remediationType == infrav1.RebootRemediationStrategy || remediationType == infrav1.EscalateRemediationStrategy {
 		// If no phase set, default to running and set time and retry count
 		if remediationMgr.GetRemediationPhase() == "" {
 			remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
 			now := metav1.Now()
 			remediationMgr.SetLastRemediationTime(&now)
+			remediationMgr.StartRemediationAttempt(&now)
 			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 		}
 
 		// try to get node
 		clusterClient, err := remediationMgr.GetClusterClient(ctx)
 		if err != nil {
+			// A missing or invalid kubeconfig is not a failure, the remediation
+			// is requeued, or reconciled when the kubeconfig secret changes.
+			// The other errors do not tell whether the kubeconfig is available.
+			if _, ok := infraremote.AsKubeconfigError(err); ok {
+				setKubeconfigCondition(metal3Remediation, err)
+				r.Log.Info("Kubeconfig of the workload cluster unavailable, will retry", "error", err.Error())
+				return ctrl.Result{RequeueAfter: requeueAfter}, nil
+			}
 			r.Log.Error(err, "error getting cluster client")
 			return ctrl.Result{}, errors.Wrap(err, "error getting cluster client")
 		}
@@ -165,6 +295,10 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 		// handle old clusters which were not setup with RBAC for accessing nodes
 		isNodeForbidden := false
 		node, err := remediationMgr.GetNode(ctx, clusterClient)
+		if setKubeconfigCondition(metal3Remediation, err) {
+			r.Log.Info("Kubeconfig of the workload cluster rejected, will retry", "error", err.Error())
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
 		if err != nil {
 			if apierrors.IsForbidden(err) {
 				r.Log.Info("Node access is forbidden, will skip node deletion")
@@ -188,6 +322,18 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 				r.Log.Error(err, "error getting poweroff annotation status")
 				return ctrl.Result{}, errors.Wrap(err, "error getting poweroff annotation status")
 			} else if ok {
+				// The pods of the node tainted out of service are deleted and
+				// their volumes detached before the host is powered on.
+				if node != nil && remediationMgr.GetNodeRemediationMechanism() == infrav1.NodeRemediationOutOfServiceTaint {
+					drained, err := remediationMgr.WaitForNodeDrain(ctx, clusterClient, node)
+					if err != nil {
+						r.Log.Error(err, "error waiting for the node drain")
+						return ctrl.Result{}, errors.Wrap(err, "error waiting for the node drain")
+					}
+					if !drained {
+						return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+					}
+				}
 				r.Log.Info("Powering on the host")
 				err := remediationMgr.RemovePowerOffAnnotation(ctx)
 				if err != nil {
@@ -197,17 +343,36 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 			}
 
 			// Wait until powered on
-			if on, err := remediationMgr.IsPoweredOn(ctx); err != nil {
+			on, err := remediationMgr.IsPoweredOn(ctx)
+			if err != nil {
 				r.Log.Error(err, "error getting power status")
 				return ctrl.Result{}, errors.Wrap(err, "error getting power status")
-			} else if !on {
+			}
+			remediationMgr.RecordPowerState(on)
+			if !on {
 				// wait a bit before checking again if we are powered on
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 			}
 
 			// Restore node if available and not done yet
 			if remediationMgr.HasFinalizer() {
-				if node != nil {
+				if node != nil && remediationMgr.GetNodeRemediationMechanism() == infrav1.NodeRemediationOutOfServiceTaint {
+					// Node was kept, remove the out-of-service taint
+					if err := r.removeOutOfServiceTaint(ctx, remediationMgr, clusterClient, node); err != nil {
+						return ctrl.Result{}, err
+					}
+
+					// clean up
+					r.Log.Info("Remediation done, cleaning up remediation CR")
+					if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+						r.Log.Error(err, "error removing the remediation delete hook")
+						return ctrl.Result{}, err
+					}
+					remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
+					remediationMgr.RemoveNodeBackupAnnotations()
+					remediationMgr.UnsetFinalizer()
+					return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+				} else if node != nil {
 					// Node was recreated, restore annotations and labels
 					r.Log.Info("Restoring the node")
 					if err := r.restoreNode(ctx, remediationMgr, clusterClient, node); err != nil {
@@ -216,11 +381,21 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 
 					// clean up
 					r.Log.Info("Remediation done, cleaning up remediation CR")
+					if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+						r.Log.Error(err, "error removing the remediation delete hook")
+						return ctrl.Result{}, err
+					}
+					remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
 					remediationMgr.RemoveNodeBackupAnnotations()
 					remediationMgr.UnsetFinalizer()
 					return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 				} else if isNodeForbidden {
 					// we don't have a node, just remove finalizer
+					if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+						r.Log.Error(err, "error removing the remediation delete hook")
+						return ctrl.Result{}, err
+					}
+					remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
 					remediationMgr.UnsetFinalizer()
 
 					r.Log.Info("Skipping node restore, remediation done, CR should be deleted soon")
@@ -239,14 +414,33 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 			// Try again if limit not reached
 			if remediationMgr.RetryLimitIsSet() && !remediationMgr.HasReachRetryLimit() {
 				r.Log.Info("Remediation timed out, will retry")
+				remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeTimedOut)
 				remediationMgr.SetRemediationPhase(infrav1.PhaseRunning)
 				now := metav1.Now()
 				remediationMgr.SetLastRemediationTime(&now)
 				remediationMgr.IncreaseRetryCount()
+				remediationMgr.StartRemediationAttempt(&now)
 				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 			}
 
 			r.Log.Info("Remediation timed out and retry limit reached")
+			remediationMgr.EndRemediationAttempt(infrav1.RemediationOutcomeTimedOut)
+
+			if remediationType == infrav1.EscalateRemediationStrategy {
+				// Rebooting did not help, escalate to the deprovisioning of the host
+				// instead of leaving it unhealthy.
+				r.Log.Info("Escalating remediation to deprovisioning")
+				r.recordVacatedFailureDomain(metal3Remediation)
+				remediationMgr.SetRemediationPhase(infrav1.PhaseDeprovisioning)
+				return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+			}
+
+			// The Machine is deleted by its owner, it must not be held by the
+			// remediation anymore.
+			if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+				r.Log.Error(err, "error removing the remediation delete hook")
+				return ctrl.Result{}, err
+			}
 
 			// When machine is still unhealthy after remediation, setting of OwnerRemediatedCondition
 			// moves control to CAPI machine controller. The owning controller will do
@@ -265,11 +459,31 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 				return ctrl.Result{}, errors.Wrapf(err, "error setting unhealthy annotation")
 			}
 
+			r.recordVacatedFailureDomain(metal3Remediation)
 			remediationMgr.SetRemediationPhase(infrav1.PhaseDeleting)
 			// no requeue, we are done
 			return ctrl.Result{}, nil
 
+		case infrav1.PhaseDeprovisioning:
+
+			// The node must not stay out of service if it outlives the host.
+			if node != nil && remediationMgr.GetNodeRemediationMechanism() == infrav1.NodeRemediationOutOfServiceTaint {
+				if err := r.removeOutOfServiceTaint(ctx, remediationMgr, clusterClient, node); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return r.remediateEscalateStrategy(ctx, remediationMgr)
+
 		case infrav1.PhaseDeleting:
+			// The Machine is deleted by its owner, only the out-of-service
+			// taint may be left.
+			if node != nil && remediationMgr.GetNodeRemediationMechanism() == infrav1.NodeRemediationOutOfServiceTaint {
+				if err := r.removeOutOfServiceTaint(ctx, remediationMgr, clusterClient, node); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+
+		case infrav1.PhaseDone:
 			// nothing to do anymore
 			break
 
@@ -284,6 +498,122 @@ func (r *Metal3RemediationReconciler) reconcileNormal(ctx context.Context,
 	return ctrl.Result{}, nil
 }
 
+// reconcileOrphaned completes a remediation whose Machine or Metal3Machine
+// is gone, using the host recorded in its status. capiMachine is the Machine
+// when only the Metal3Machine is gone, nil otherwise.
+func (r *Metal3RemediationReconciler) reconcileOrphaned(ctx context.Context,
+	metal3Remediation *infrav1.Metal3Remediation, capiMachine *clusterv1.Machine, remediationLog logr.Logger,
+) (ctrl.Result, error) {
+	remediationLog.Info("Owner of the remediation is gone, cleaning up the host")
+	remediationMgr, err := r.ManagerFactory.NewRemediationManager(metal3Remediation, nil, capiMachine, remediationLog)
+	if err != nil {
+		remediationLog.Error(err, "failed to create helper for managing the metal3remediation")
+		return ctrl.Result{}, errors.Wrapf(err, "failed to create helper for managing the metal3remediation")
+	}
+	return r.cleanupOrphanedRemediation(ctx, remediationMgr)
+}
+
+// cleanupOrphanedRemediation powers the host back on, unless it was consumed
+// by another machine since, removes the remediation delete hook from the
+// Machine if it still exists, and then deletes the remediation. The finalizer
+// is removed first, the deletion happens on the next reconcile.
+func (r *Metal3RemediationReconciler) cleanupOrphanedRemediation(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface,
+) (ctrl.Result, error) {
+	host, _, err := remediationMgr.GetUnhealthyHost(ctx)
+	if err != nil {
+		r.Log.Error(err, "unable to get the remediated host")
+		return ctrl.Result{}, errors.Wrapf(err, "unable to get the remediated host")
+	}
+	if host != nil {
+		ok, err := remediationMgr.IsPowerOffRequested(ctx)
+		if err != nil {
+			r.Log.Error(err, "error getting poweroff annotation status")
+			return ctrl.Result{}, errors.Wrap(err, "error getting poweroff annotation status")
+		}
+		if ok {
+			r.Log.Info("Removing the poweroff annotation from the remediated host")
+			if err := remediationMgr.RemovePowerOffAnnotation(ctx); err != nil {
+				r.Log.Error(err, "error removing poweroff annotation")
+				return ctrl.Result{}, errors.Wrap(err, "error removing poweroff annotation")
+			}
+		}
+	} else {
+		r.Log.Info("Remediated host is gone or reused, leaving it alone")
+	}
+
+	// The hook would otherwise hold the deletion of the Machine forever.
+	if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+		r.Log.Error(err, "error removing the remediation delete hook")
+		return ctrl.Result{}, err
+	}
+
+	if remediationMgr.HasFinalizer() {
+		if remediationMgr.GetNodeRemediationMechanism() == infrav1.NodeRemediationOutOfServiceTaint {
+			if err := r.removeOrphanedOutOfServiceTaint(ctx, remediationMgr); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		remediationMgr.RemoveNodeBackupAnnotations()
+		remediationMgr.UnsetFinalizer()
+		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+	}
+
+	if err := remediationMgr.DeleteRemediation(ctx); err != nil {
+		r.Log.Error(err, "error deleting remediation")
+		return ctrl.Result{}, errors.Wrap(err, "error deleting remediation")
+	}
+	return ctrl.Result{}, nil
+}
+
+// removeOrphanedOutOfServiceTaint removes the out-of-service taint from the
+// node of a remediation whose Metal3Machine is gone. The node is deleted with
+// the Machine, so nothing is left to do when the Machine is gone too.
+func (r *Metal3RemediationReconciler) removeOrphanedOutOfServiceTaint(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface,
+) error {
+	capiMachine, err := remediationMgr.GetCapiMachine(ctx)
+	if err != nil && !apierrors.IsNotFound(err) {
+		r.Log.Error(err, "error getting the remediated machine")
+		return errors.Wrap(err, "error getting the remediated machine")
+	}
+	if capiMachine == nil || capiMachine.Status.NodeRef == nil {
+		return nil
+	}
+
+	clusterClient, err := remediationMgr.GetClusterClient(ctx)
+	if err != nil {
+		r.Log.Error(err, "error getting cluster client")
+		return errors.Wrap(err, "error getting cluster client")
+	}
+	node, err := remediationMgr.GetNode(ctx, clusterClient)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			r.Log.Info("Node access is forbidden, will skip the removal of the out-of-service taint")
+			return nil
+		}
+		r.Log.Error(err, "error getting node for remediation")
+		return errors.Wrap(err, "error getting node for remediation")
+	}
+	if node == nil {
+		return nil
+	}
+	return r.removeOutOfServiceTaint(ctx, remediationMgr, clusterClient, node)
+}
+
+// removeOutOfServiceTaint removes the out-of-service taint from the node kept
+// during the remediation.
+func (r *Metal3RemediationReconciler) removeOutOfServiceTaint(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface, clusterClient v1.CoreV1Interface, node *corev1.Node,
+) error {
+	r.Log.Info("Removing the out-of-service taint from the node")
+	if err := remediationMgr.RemoveOutOfServiceTaint(ctx, clusterClient, node); err != nil {
+		r.Log.Error(err, "error removing the out-of-service taint")
+		return errors.Wrap(err, "error removing the out-of-service taint")
+	}
+	return nil
+}
+
 // remediateRebootStrategy executes the remediation using the reboot strategy.
 // Returns nil, nil when reconcile can continue.
 // Return a Result and optionally an error when reconcile should return.
@@ -296,6 +626,12 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 		return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 	}
 
+	// hold the deletion of the Machine until the remediation is over
+	if err := remediationMgr.SetCapiMachineDeleteHook(ctx); err != nil {
+		r.Log.Error(err, "error setting the remediation delete hook")
+		return ctrl.Result{}, err
+	}
+
 	// power off if needed
 	if ok, err := remediationMgr.IsPowerOffRequested(ctx); err != nil {
 		r.Log.Error(err, "error getting poweroff annotation status")
@@ -313,16 +649,34 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 	}
 
 	// wait until powered off
-	if on, err := remediationMgr.IsPoweredOn(ctx); err != nil {
+	on, err := remediationMgr.IsPoweredOn(ctx)
+	if err != nil {
 		r.Log.Error(err, "error getting power status")
 		return ctrl.Result{}, errors.Wrap(err, "error getting power status")
-	} else if on {
+	}
+	remediationMgr.RecordPowerState(on)
+	if on {
 		// wait a bit before checking again if we are powered off already
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
-	// if we have a node, store annotations and labels, and delete it
+	// if we have a node, taint it as out of service if supported, otherwise
+	// store annotations and labels, and delete it
 	if node != nil {
+		mechanism := r.nodeRemediationMechanism(ctx, remediationMgr, clusterClient)
+		if mechanism == infrav1.NodeRemediationOutOfServiceTaint {
+			r.Log.Info("Tainting node as out of service")
+			err := remediationMgr.SetOutOfServiceTaint(ctx, clusterClient, node)
+			if err == nil {
+				remediationMgr.RecordNodeAction(infrav1.NodeRemediationOutOfServiceTaint)
+				remediationMgr.SetRemediationPhase(infrav1.PhaseWaiting)
+				r.Log.Info("Switch to waiting phase for power on and node untaint")
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			r.Log.Error(err, "error tainting node, falling back to node deletion")
+			remediationMgr.SetNodeRemediationMechanism(infrav1.NodeRemediationDeletion)
+			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
+		}
 		/*
 			Delete the node only after the host is powered off. Otherwise, if we would delete the node
 			when the host is powered on, the scheduler would assign the workload to other nodes, with the
@@ -342,6 +696,7 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 			r.Log.Error(err, "error deleting node")
 			return ctrl.Result{}, errors.Wrap(err, "error deleting node")
 		}
+		remediationMgr.RecordNodeAction(infrav1.NodeRemediationDeletion)
 		// wait until node is gone
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
@@ -352,6 +707,66 @@ func (r *Metal3RemediationReconciler) remediateRebootStrategy(ctx context.Contex
 	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 }
 
+// nodeRemediationMechanism returns how the node is handled while the host is
+// powered off, choosing the out-of-service taint when the workload cluster
+// supports it and the node deletion otherwise. The choice is recorded in the
+// status.
+func (r *Metal3RemediationReconciler) nodeRemediationMechanism(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface, clusterClient v1.CoreV1Interface,
+) infrav1.NodeRemediationMechanism {
+	if mechanism := remediationMgr.GetNodeRemediationMechanism(); mechanism != "" {
+		return mechanism
+	}
+	mechanism := infrav1.NodeRemediationDeletion
+	supported, err := remediationMgr.SupportsOutOfServiceTaint(ctx, clusterClient)
+	if err != nil {
+		r.Log.Error(err, "unable to check the support of the out-of-service taint, falling back to node deletion")
+	} else if supported {
+		mechanism = infrav1.NodeRemediationOutOfServiceTaint
+	}
+	remediationMgr.SetNodeRemediationMechanism(mechanism)
+	return mechanism
+}
+
+// remediateEscalateStrategy deletes the unhealthy Machine once the reboots of the
+// escalate strategy are exhausted. The deletion of the Metal3Machine then
+// deprovisions the host, and the owner of the Machine replaces it.
+func (r *Metal3RemediationReconciler) remediateEscalateStrategy(ctx context.Context,
+	remediationMgr baremetal.RemediationManagerInterface) (ctrl.Result, error) {
+	// the node will not come back, and the remediation must not block the
+	// deletion of the Machine
+	remediationMgr.RemoveNodeBackupAnnotations()
+	remediationMgr.UnsetFinalizer()
+	if err := remediationMgr.RemoveCapiMachineDeleteHook(ctx); err != nil {
+		r.Log.Error(err, "error removing the remediation delete hook")
+		return ctrl.Result{}, err
+	}
+
+	r.Log.Info("Deleting unhealthy machine to deprovision the host")
+	err := remediationMgr.DeleteCapiMachine(ctx)
+	if err != nil {
+		r.Log.Error(err, "error deleting unhealthy machine")
+		return ctrl.Result{}, errors.Wrap(err, "error deleting unhealthy machine")
+	}
+
+	remediationMgr.SetRemediationPhase(infrav1.PhaseDone)
+	// no requeue, we are done
+	return ctrl.Result{}, nil
+}
+
+// recordVacatedFailureDomain emits an event documenting the failure domain
+// and the host zone vacated by the unhealthy Machine, once the remediation
+// escalates to the deletion of the Machine, so that the replacement can be
+// placed in the same failure domain.
+func (r *Metal3RemediationReconciler) recordVacatedFailureDomain(metal3Remediation *infrav1.Metal3Remediation) {
+	status := metal3Remediation.Status
+	if r.Recorder == nil || (status.FailureDomain == "" && status.HostZone == "") {
+		return
+	}
+	r.Recorder.Eventf(metal3Remediation, corev1.EventTypeNormal, infrav1.FailureDomainVacatedReason,
+		"Unhealthy machine is deleted, vacating failure domain %q and host zone %q", status.FailureDomain, status.HostZone)
+}
+
 // Returns whether annotations or labels were set / updated.
 func (r *Metal3RemediationReconciler) backupNode(remediationMgr baremetal.RemediationManagerInterface,
 	node *corev1.Node) bool {
@@ -457,9 +872,62 @@ func mergeMaps(prioritizedMap map[string]string, mapToMerge map[string]string) m
 }
 
 // SetupWithManager will add watches for Metal3Remediation controller.
-func (r *Metal3RemediationReconciler) SetupWithManager(_ context.Context, mgr ctrl.Manager, options controller.Options) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrav1.Metal3Remediation{}).
+func (r *Metal3RemediationReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	// The watch-filter label and shard filter is not set on the kubeconfig
+	// secrets, which do not carry the label, they are filtered on their
+	// Cluster by KubeconfigSecretToMetal3Remediations.
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(
+			&infrav1.Metal3Remediation{},
+			builder.WithPredicates(ResourceHasFilterLabelOrShard(ctrl.LoggerFrom(ctx), r.WatchFilterValue, r.Shard)),
+		).
 		WithOptions(options).
-		Complete(r)
+		WithEventFilter(ResourceNotPausedByAnnotation(ctrl.LoggerFrom(ctx))).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.KubeconfigSecretToMetal3Remediations),
+			builder.WithPredicates(kubeconfigSecretPredicate()),
+		).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
+}
+
+// NodeToMetal3Remediation is a handler.ToRequestsFunc to be used to enqeue
+// requests for reconciliation of the Metal3Remediation of a workload cluster
+// Node. The Metal3Remediation has the same name as the Machine.
+func (r *Metal3RemediationReconciler) NodeToMetal3Remediation(_ context.Context, o client.Object) []ctrl.Request {
+	machineKey, ok := nodeToMachineKey(o)
+	if !ok {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: machineKey}}
+}
+
+// KubeconfigSecretToMetal3Remediations is a handler.ToRequestsFunc to be used
+// to enqeue requests for reconciliation of the Metal3Remediations of a Cluster
+// when its kubeconfig secret changes, so that they are retried promptly once
+// the kubeconfig is fixed. The Metal3Remediations created by CAPI carry the
+// cluster name label. The secrets of the Clusters of other instances are
+// ignored.
+func (r *Metal3RemediationReconciler) KubeconfigSecretToMetal3Remediations(ctx context.Context, o client.Object) []ctrl.Request {
+	cluster, ok := watchedKubeconfigSecretCluster(ctx, r.Client, r.Log, o, r.WatchFilterValue, r.Shard)
+	if !ok {
+		return nil
+	}
+	remediations := &infrav1.Metal3RemediationList{}
+	if err := r.Client.List(ctx, remediations, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name},
+	); err != nil {
+		r.Log.Error(err, "failed to list Metal3Remediations")
+		return nil
+	}
+	result := []ctrl.Request{}
+	for i := range remediations.Items {
+		result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&remediations.Items[i])})
+	}
+	return result
 }
@@ -17,8 +17,11 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -31,11 +34,22 @@ import (
 	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	baremetal_mocks "github.com/metal3-io/cluster-api-provider-metal3/baremetal/mocks"
+	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -52,6 +66,8 @@ type reconcileNormalRemediationTestCase struct {
 	ExpectRequeue           bool
 	GetUnhealthyHostFails   bool
 	GetRemediationTypeFails bool
+	RemediationType         infrav1.RemediationType
+	DeleteMachineFails      bool
 	HostStatusOffline       bool
 	RemediationPhase        string
 	IsFinalizerSet          bool
@@ -62,6 +78,43 @@ type reconcileNormalRemediationTestCase struct {
 	IsNodeDeleted           bool
 	IsTimedOut              bool
 	IsRetryLimitReached     bool
+
+	NodeRemediationMechanism   infrav1.NodeRemediationMechanism
+	OutOfServiceTaintSupported bool
+	OutOfServiceTaintFails     bool
+	IsNodeDraining             bool
+
+	KubeconfigError        error
+	NodeUnauthorized       bool
+	ExpectKubeconfigReason string
+
+	ExpectVacatedEvent bool
+}
+
+// workloadCoreV1 is a fake client of the workload cluster that also serves
+// the version of the cluster.
+type workloadCoreV1 struct {
+	clientcorev1.CoreV1Interface
+	restClient rest.Interface
+}
+
+func (c workloadCoreV1) RESTClient() rest.Interface {
+	return c.restClient
+}
+
+func newWorkloadCoreV1(clientset *clientfake.Clientset, gitVersion string) workloadCoreV1 {
+	return workloadCoreV1{
+		CoreV1Interface: clientset.CoreV1(),
+		restClient: &restfake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				header := http.Header{}
+				header.Set("Content-Type", "application/json")
+				body := `{"major": "1", "gitVersion": "` + gitVersion + `"}`
+				return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			}),
+		},
+	}
 }
 
 type reconcileRemediationTestCase struct {
@@ -71,6 +124,7 @@ type reconcileRemediationTestCase struct {
 	FailedToCreateRemediationManager bool
 	Metal3Remediation                *infrav1.Metal3Remediation
 	Machine                          *clusterv1.Machine
+	ExpectPaused                     bool
 }
 type marshallRemediationTestCase struct {
 	Map map[string]string
@@ -94,10 +148,12 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 	// If user has set bmh.Spec.Online to false, do not try to remediate the host and set remediation phase to failed
 	if tc.HostStatusOffline {
 		m.EXPECT().OnlineStatus(bmh).Return(false)
+		m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
 		m.EXPECT().SetRemediationPhase(infrav1.PhaseFailed)
 		return m
 	}
 	m.EXPECT().OnlineStatus(bmh).Return(true)
+	m.EXPECT().SetRemediatedHost(bmh)
 
 	node := &corev1.Node{
 		TypeMeta: metav1.TypeMeta{},
@@ -120,22 +176,59 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 		}
 	}
 
+	// expectRemoveOutOfServiceTaint returns whether the remediation goes on
+	// after the removal of the out-of-service taint from the node.
+	expectRemoveOutOfServiceTaint := func() bool {
+		if tc.IsNodeDeleted || tc.IsNodeForbidden {
+			return true
+		}
+		m.EXPECT().GetNodeRemediationMechanism().Return(tc.NodeRemediationMechanism)
+		if tc.NodeRemediationMechanism != infrav1.NodeRemediationOutOfServiceTaint {
+			return true
+		}
+		if tc.OutOfServiceTaintFails {
+			m.EXPECT().RemoveOutOfServiceTaint(context.TODO(), gomock.Any(), node).Return(fmt.Errorf("can't untaint node"))
+			return false
+		}
+		m.EXPECT().RemoveOutOfServiceTaint(context.TODO(), gomock.Any(), node)
+		return true
+	}
+
 	if tc.GetRemediationTypeFails {
 		const wrongRemediationStrategy infrav1.RemediationType = "wrongRemediationStrategy"
 		m.EXPECT().GetRemediationType().Return(wrongRemediationStrategy)
 		return m
 	}
 
-	m.EXPECT().GetRemediationType().Return(infrav1.RebootRemediationStrategy)
+	remediationType := tc.RemediationType
+	if remediationType == "" {
+		remediationType = infrav1.RebootRemediationStrategy
+	}
+	m.EXPECT().GetRemediationType().Return(remediationType)
 	m.EXPECT().GetRemediationPhase().Return(tc.RemediationPhase).MinTimes(1)
 
 	switch tc.RemediationPhase {
 	case "":
 		m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
 		m.EXPECT().SetLastRemediationTime(gomock.Any())
+		m.EXPECT().StartRemediationAttempt(gomock.Any())
 
 	case infrav1.PhaseRunning:
 
+		// the kubeconfig is unavailable, we requeue and do not go further
+		if tc.KubeconfigError != nil {
+			m.EXPECT().GetClusterClient(context.TODO()).Return(nil, tc.KubeconfigError)
+			m.EXPECT().GetNode(context.TODO(), gomock.Any()).MaxTimes(0)
+			m.EXPECT().HasFinalizer().MaxTimes(0)
+			return m
+		}
+		if tc.NodeUnauthorized {
+			m.EXPECT().GetClusterClient(context.TODO())
+			m.EXPECT().GetNode(context.TODO(), gomock.Any()).Return(nil, apierrors.NewUnauthorized("invalid credentials"))
+			m.EXPECT().HasFinalizer().MaxTimes(0)
+			return m
+		}
+
 		expectGetNode()
 
 		m.EXPECT().HasFinalizer().Return(tc.IsFinalizerSet)
@@ -144,6 +237,7 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 			return m
 		}
 
+		m.EXPECT().SetCapiMachineDeleteHook(context.TODO())
 		m.EXPECT().IsPowerOffRequested(context.TODO()).Return(tc.IsPowerOffRequested, nil)
 		if !tc.IsPowerOffRequested {
 			m.EXPECT().SetPowerOffAnnotation(context.TODO())
@@ -151,16 +245,39 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 		}
 
 		m.EXPECT().IsPoweredOn(context.TODO()).Return(tc.IsPoweredOn, nil)
+		m.EXPECT().RecordPowerState(tc.IsPoweredOn)
 		if tc.IsPoweredOn {
 			return m
 		}
 
 		if !tc.IsNodeForbidden && !tc.IsNodeDeleted {
+			mechanism := tc.NodeRemediationMechanism
+			m.EXPECT().GetNodeRemediationMechanism().Return(mechanism)
+			if mechanism == "" {
+				m.EXPECT().SupportsOutOfServiceTaint(context.TODO(), gomock.Any()).Return(tc.OutOfServiceTaintSupported, nil)
+				mechanism = infrav1.NodeRemediationDeletion
+				if tc.OutOfServiceTaintSupported {
+					mechanism = infrav1.NodeRemediationOutOfServiceTaint
+				}
+				m.EXPECT().SetNodeRemediationMechanism(mechanism)
+			}
+			if mechanism == infrav1.NodeRemediationOutOfServiceTaint {
+				if tc.OutOfServiceTaintFails {
+					m.EXPECT().SetOutOfServiceTaint(context.TODO(), gomock.Any(), node).Return(fmt.Errorf("can't taint node"))
+					m.EXPECT().SetNodeRemediationMechanism(infrav1.NodeRemediationDeletion)
+					return m
+				}
+				m.EXPECT().SetOutOfServiceTaint(context.TODO(), gomock.Any(), node)
+				m.EXPECT().RecordNodeAction(infrav1.NodeRemediationOutOfServiceTaint)
+				m.EXPECT().SetRemediationPhase(infrav1.PhaseWaiting)
+				return m
+			}
 			m.EXPECT().SetNodeBackupAnnotations("{\"foo\":\"bar\"}", "{\"answer\":\"42\"}").Return(!tc.IsNodeBackedUp)
 			if !tc.IsNodeBackedUp {
 				return m
 			}
 			m.EXPECT().DeleteNode(context.TODO(), gomock.Any(), gomock.Any())
+			m.EXPECT().RecordNodeAction(infrav1.NodeRemediationDeletion)
 			return m
 		}
 
@@ -173,10 +290,20 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 
 		m.EXPECT().IsPowerOffRequested(context.TODO()).Return(tc.IsPowerOffRequested, nil)
 		if tc.IsPowerOffRequested {
+			if !tc.IsNodeDeleted {
+				m.EXPECT().GetNodeRemediationMechanism().Return(tc.NodeRemediationMechanism)
+				if tc.NodeRemediationMechanism == infrav1.NodeRemediationOutOfServiceTaint {
+					m.EXPECT().WaitForNodeDrain(context.TODO(), gomock.Any(), node).Return(!tc.IsNodeDraining, nil)
+					if tc.IsNodeDraining {
+						return m
+					}
+				}
+			}
 			m.EXPECT().RemovePowerOffAnnotation(context.TODO())
 		}
 
 		m.EXPECT().IsPoweredOn(context.TODO()).Return(tc.IsPoweredOn, nil)
+		m.EXPECT().RecordPowerState(tc.IsPoweredOn)
 		if !tc.IsPoweredOn {
 			return m
 		}
@@ -184,13 +311,30 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 		m.EXPECT().HasFinalizer().Return(tc.IsFinalizerSet)
 		if tc.IsFinalizerSet {
 			if !tc.IsNodeDeleted {
+				m.EXPECT().GetNodeRemediationMechanism().Return(tc.NodeRemediationMechanism)
+				if tc.NodeRemediationMechanism == infrav1.NodeRemediationOutOfServiceTaint {
+					if tc.OutOfServiceTaintFails {
+						m.EXPECT().RemoveOutOfServiceTaint(context.TODO(), gomock.Any(), node).Return(fmt.Errorf("can't untaint node"))
+						return m
+					}
+					m.EXPECT().RemoveOutOfServiceTaint(context.TODO(), gomock.Any(), node)
+					m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
+					m.EXPECT().EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
+					m.EXPECT().RemoveNodeBackupAnnotations()
+					m.EXPECT().UnsetFinalizer()
+					return m
+				}
 				m.EXPECT().GetNodeBackupAnnotations().Return("{\"foo\":\"bar\"}", "{\"answer\":\"42\"}")
 				m.EXPECT().UpdateNode(context.TODO(), gomock.Any(), gomock.Any())
+				m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
+				m.EXPECT().EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
 				m.EXPECT().RemoveNodeBackupAnnotations()
 				m.EXPECT().UnsetFinalizer()
 				return m
 			}
 			if tc.IsNodeForbidden {
+				m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
+				m.EXPECT().EndRemediationAttempt(infrav1.RemediationOutcomeSucceeded)
 				m.EXPECT().UnsetFinalizer()
 				return m
 			}
@@ -201,19 +345,46 @@ func setReconcileNormalRemediationExpectations(ctrl *gomock.Controller,
 		if tc.IsTimedOut {
 			m.EXPECT().RetryLimitIsSet().Return(true)
 			m.EXPECT().HasReachRetryLimit().Return(tc.IsRetryLimitReached)
+			m.EXPECT().EndRemediationAttempt(infrav1.RemediationOutcomeTimedOut)
 			if !tc.IsRetryLimitReached {
 				m.EXPECT().SetRemediationPhase(infrav1.PhaseRunning)
 				m.EXPECT().SetLastRemediationTime(gomock.Any())
 				m.EXPECT().IncreaseRetryCount()
+				m.EXPECT().StartRemediationAttempt(gomock.Any())
 				return m
 			}
+			if remediationType == infrav1.EscalateRemediationStrategy {
+				m.EXPECT().SetRemediationPhase(infrav1.PhaseDeprovisioning)
+				return m
+			}
+			m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
 			m.EXPECT().SetOwnerRemediatedConditionNew(context.TODO())
 			m.EXPECT().SetUnhealthyAnnotation(context.TODO())
 			m.EXPECT().SetRemediationPhase(infrav1.PhaseDeleting)
 		}
 
+	case infrav1.PhaseDeprovisioning:
+		expectGetNode()
+		if !expectRemoveOutOfServiceTaint() {
+			return m
+		}
+
+		m.EXPECT().RemoveNodeBackupAnnotations()
+		m.EXPECT().UnsetFinalizer()
+		m.EXPECT().RemoveCapiMachineDeleteHook(context.TODO())
+		if tc.DeleteMachineFails {
+			m.EXPECT().DeleteCapiMachine(context.TODO()).Return(fmt.Errorf("can't delete machine"))
+			return m
+		}
+		m.EXPECT().DeleteCapiMachine(context.TODO()).Return(nil)
+		m.EXPECT().SetRemediationPhase(infrav1.PhaseDone)
+
 	case infrav1.PhaseDeleting:
 		expectGetNode()
+		expectRemoveOutOfServiceTaint()
+
+	case infrav1.PhaseDone:
+		expectGetNode()
 
 	case infrav1.PhaseFailed:
 		expectGetNode()
@@ -257,15 +428,21 @@ var _ = Describe("Metal3Remediation controller", func() {
 				if tc.Machine != nil {
 					objects = append(objects, tc.Machine)
 				}
-				fakeClient = fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+				fakeClient = fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(objects...).Build()
 			}
 			testReconciler = &Metal3RemediationReconciler{
 				Client:         fakeClient,
 				ManagerFactory: baremetal.NewManagerFactory(fakeClient),
 				Log:            logr.Discard(),
 			}
-			_, err := testReconciler.Reconcile(context.TODO(), tc.TestRequest)
+			result, err := testReconciler.Reconcile(context.TODO(), tc.TestRequest)
 			evaluateTestError(tc.ExpectedError, err)
+			if tc.ExpectPaused {
+				Expect(result).To(Equal(ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}))
+				remediation := &infrav1.Metal3Remediation{}
+				Expect(fakeClient.Get(context.TODO(), tc.TestRequest.NamespacedName, remediation)).To(Succeed())
+				Expect(conditions.IsTrue(remediation, infrav1.PausedCondition)).To(BeTrue())
+			}
 		},
 		Entry("Metal3Remediation haven't been found",
 			reconcileRemediationTestCase{
@@ -327,17 +504,336 @@ var _ = Describe("Metal3Remediation controller", func() {
 				}},
 				Machine: newMachine(clusterName, machineName, "", "mynode"),
 			}),
+		Entry("Metal3Remediation is paused with the CAPM3 annotation",
+			reconcileRemediationTestCase{
+				TestRequest:   defaultTestRequest,
+				ExpectedError: nil,
+				ExpectPaused:  true,
+				Metal3Remediation: &infrav1.Metal3Remediation{ObjectMeta: metav1.ObjectMeta{
+					Name:      metal3RemediationName,
+					Namespace: namespaceName,
+					Annotations: map[string]string{
+						infrav1.PausedAnnotation: "",
+					},
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Machine",
+							Name:       "wrongName",
+						},
+					},
+				}},
+				Machine: newMachine(clusterName, machineName, metal3machineName, "mynode"),
+			}),
+	)
+
+	type orphanedRemediationTestCase struct {
+		MachineExists      bool
+		NodeTainted        bool
+		HostConsumerName   string
+		HostConsumerUID    types.UID
+		ExpectPowerOffKept bool
+	}
+
+	DescribeTable("Metal3Remediation with deleted owners",
+		func(tc orphanedRemediationTestCase) {
+			remediation := &infrav1.Metal3Remediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       metal3RemediationName,
+					Namespace:  namespaceName,
+					UID:        "remediation-uid",
+					Finalizers: []string{infrav1.RemediationFinalizer},
+					Annotations: map[string]string{
+						"remediation.metal3.io/node-labels-backup": "{}",
+					},
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Machine",
+							Name:       machineName,
+						},
+					},
+				},
+				Spec: infrav1.Metal3RemediationSpec{
+					Strategy: &infrav1.RemediationStrategy{
+						Type:    infrav1.RebootRemediationStrategy,
+						Timeout: &metav1.Duration{Duration: 600 * time.Second},
+					},
+				},
+				Status: infrav1.Metal3RemediationStatus{
+					Phase: infrav1.PhaseWaiting,
+					HostRef: &corev1.ObjectReference{
+						Name:      baremetalhostName,
+						Namespace: namespaceName,
+						UID:       "bmh-uid",
+					},
+					ConsumerRef: &corev1.ObjectReference{
+						Name:      metal3machineName,
+						Namespace: namespaceName,
+						UID:       "m3m-uid",
+					},
+				},
+			}
+			powerOffKey := "reboot.metal3.io/metal3-remediation-remediation-uid"
+			host := &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        baremetalhostName,
+					Namespace:   namespaceName,
+					UID:         "bmh-uid",
+					Annotations: map[string]string{powerOffKey: "{\"mode\":\"hard\"}"},
+				},
+			}
+			if tc.HostConsumerName != "" {
+				host.Spec.ConsumerRef = &corev1.ObjectReference{
+					Name:      tc.HostConsumerName,
+					Namespace: namespaceName,
+					UID:       tc.HostConsumerUID,
+				}
+			}
+			objects := []client.Object{defaultCluster, remediation, host}
+			machine := newMachine(clusterName, machineName, metal3machineName, "mynode")
+			if tc.MachineExists {
+				if machine.Annotations == nil {
+					machine.Annotations = map[string]string{}
+				}
+				machine.Annotations[infrav1.RemediationDeleteHookAnnotation] = metal3RemediationName
+				objects = append(objects, machine)
+			}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mynode"}}
+			if tc.NodeTainted {
+				remediation.Status.NodeRemediationMechanism = infrav1.NodeRemediationOutOfServiceTaint
+				node.Spec.Taints = []corev1.Taint{{
+					Key:    corev1.TaintNodeOutOfService,
+					Value:  "nodeshutdown",
+					Effect: corev1.TaintEffectNoExecute,
+				}}
+			}
+			clientset := clientfake.NewSimpleClientset(node)
+			clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+				return clientset.CoreV1(), nil
+			}
+			fakeClient = fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(remediation).Build()
+			testReconciler = &Metal3RemediationReconciler{
+				Client:         fakeClient,
+				ManagerFactory: baremetal.NewManagerFactory(fakeClient).WithClientGetter(clientGetter),
+				Log:            logr.Discard(),
+			}
+
+			// The first reconcile cleans up the host and removes the finalizer.
+			_, err := testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+			Expect(err).NotTo(HaveOccurred())
+			savedRemediation := &infrav1.Metal3Remediation{}
+			Expect(fakeClient.Get(context.TODO(), defaultTestRequest.NamespacedName, savedRemediation)).To(Succeed())
+			Expect(savedRemediation.Finalizers).To(BeEmpty())
+			Expect(savedRemediation.Annotations).NotTo(HaveKey("remediation.metal3.io/node-labels-backup"))
+
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			if tc.ExpectPowerOffKept {
+				Expect(savedHost.Annotations).To(HaveKey(powerOffKey))
+			} else {
+				Expect(savedHost.Annotations).NotTo(HaveKey(powerOffKey))
+			}
+
+			// The remediation does not hold the deletion of the Machine anymore.
+			if tc.MachineExists {
+				savedMachine := &clusterv1.Machine{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(machine), savedMachine)).To(Succeed())
+				Expect(savedMachine.Annotations).NotTo(HaveKey(infrav1.RemediationDeleteHookAnnotation))
+			}
+
+			savedNode, err := clientset.CoreV1().Nodes().Get(context.TODO(), "mynode", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			if tc.NodeTainted && !tc.MachineExists {
+				Expect(savedNode.Spec.Taints).NotTo(BeEmpty())
+			} else {
+				Expect(savedNode.Spec.Taints).To(BeEmpty())
+			}
+
+			// The next reconcile deletes the remediation.
+			_, err = testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+			Expect(err).NotTo(HaveOccurred())
+			err = fakeClient.Get(context.TODO(), defaultTestRequest.NamespacedName, savedRemediation)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		},
+		Entry("Machine deleted, host released", orphanedRemediationTestCase{}),
+		Entry("Machine deleted, host still consumed by the remediated machine", orphanedRemediationTestCase{
+			HostConsumerName: metal3machineName,
+			HostConsumerUID:  "m3m-uid",
+		}),
+		Entry("Machine deleted, host reused by another machine", orphanedRemediationTestCase{
+			HostConsumerName:   "othermachine",
+			HostConsumerUID:    "other-uid",
+			ExpectPowerOffKept: true,
+		}),
+		Entry("Machine deleted, host reused by a new machine with the same name", orphanedRemediationTestCase{
+			HostConsumerName:   metal3machineName,
+			HostConsumerUID:    "new-m3m-uid",
+			ExpectPowerOffKept: true,
+		}),
+		Entry("Metal3Machine deleted", orphanedRemediationTestCase{
+			MachineExists: true,
+		}),
+		Entry("Metal3Machine deleted, out-of-service taint removed", orphanedRemediationTestCase{
+			MachineExists: true,
+			NodeTainted:   true,
+		}),
+		Entry("Machine deleted, node left to the deletion of the Machine", orphanedRemediationTestCase{
+			NodeTainted: true,
+		}),
+	)
+
+	type nodeRemediationTestCase struct {
+		GitVersion        string
+		TaintFails        bool
+		ExpectedMechanism infrav1.NodeRemediationMechanism
+	}
+
+	DescribeTable("Metal3Remediation of the Node",
+		func(tc nodeRemediationTestCase) {
+			remediation := &infrav1.Metal3Remediation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       metal3RemediationName,
+					Namespace:  namespaceName,
+					UID:        "remediation-uid",
+					Finalizers: []string{infrav1.RemediationFinalizer},
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: clusterv1.GroupVersion.String(),
+							Kind:       "Machine",
+							Name:       machineName,
+						},
+					},
+				},
+				Spec: infrav1.Metal3RemediationSpec{
+					Strategy: &infrav1.RemediationStrategy{
+						Type:    infrav1.RebootRemediationStrategy,
+						Timeout: &metav1.Duration{Duration: 600 * time.Second},
+					},
+				},
+				Status: infrav1.Metal3RemediationStatus{
+					Phase: infrav1.PhaseRunning,
+				},
+			}
+			m3m := newMetal3Machine(metal3machineName, nil, nil, nil, false)
+			m3m.Annotations[baremetal.HostAnnotation] = namespaceName + "/" + baremetalhostName
+			host := newBareMetalHost(baremetalhostName, &bmov1alpha1.BareMetalHostSpec{Online: true}, nil, nil, false)
+			host.Annotations = map[string]string{
+				"reboot.metal3.io/metal3-remediation-remediation-uid": "{\"mode\":\"hard\"}",
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(
+				defaultCluster, remediation, m3m, host, newMachine(clusterName, machineName, metal3machineName, "mynode"),
+			).WithStatusSubresource(remediation, host).Build()
+
+			clientset := clientfake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:   "mynode",
+				Labels: map[string]string{"answer": "42"},
+			}})
+			if tc.TaintFails {
+				clientset.PrependReactor("update", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, fmt.Errorf("can't update node")
+				})
+			}
+			clusterClient := newWorkloadCoreV1(clientset, tc.GitVersion)
+			clientGetter := func(_ context.Context, _ client.Client, _ *clusterv1.Cluster) (clientcorev1.CoreV1Interface, error) {
+				return clusterClient, nil
+			}
+			testReconciler = &Metal3RemediationReconciler{
+				Client:         fakeClient,
+				ManagerFactory: baremetal.NewManagerFactory(fakeClient).WithClientGetter(clientGetter),
+				Log:            logr.Discard(),
+			}
+			getRemediation := func() *infrav1.Metal3Remediation {
+				savedRemediation := &infrav1.Metal3Remediation{}
+				Expect(fakeClient.Get(context.TODO(), defaultTestRequest.NamespacedName, savedRemediation)).To(Succeed())
+				return savedRemediation
+			}
+			getNode := func() (*corev1.Node, error) {
+				return clientset.CoreV1().Nodes().Get(context.TODO(), "mynode", metav1.GetOptions{})
+			}
+			getMachineAnnotations := func() map[string]string {
+				savedMachine := &clusterv1.Machine{}
+				Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: machineName, Namespace: namespaceName}, savedMachine)).To(Succeed())
+				return savedMachine.Annotations
+			}
+
+			// The host is powered off, the mechanism is chosen. The deletion
+			// of the Machine is held during the remediation.
+			_, err := testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getRemediation().Status.NodeRemediationMechanism).To(Equal(tc.ExpectedMechanism))
+			Expect(getMachineAnnotations()).To(HaveKeyWithValue(infrav1.RemediationDeleteHookAnnotation, metal3RemediationName))
+
+			if tc.ExpectedMechanism == infrav1.NodeRemediationDeletion {
+				// The node is backed up, and then deleted.
+				for i := 0; i < 2; i++ {
+					_, err = testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(getRemediation().Annotations).To(HaveKey("remediation.metal3.io/node-labels-backup"))
+				_, err = getNode()
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				return
+			}
+
+			// The node is kept with the out-of-service taint while the host is
+			// powered off.
+			node, err := getNode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Spec.Taints).To(ConsistOf(corev1.Taint{
+				Key:    corev1.TaintNodeOutOfService,
+				Value:  "nodeshutdown",
+				Effect: corev1.TaintEffectNoExecute,
+			}))
+			Expect(getRemediation().Status.Phase).To(Equal(infrav1.PhaseWaiting))
+			Expect(getRemediation().Annotations).NotTo(HaveKey("remediation.metal3.io/node-labels-backup"))
+
+			// The taint is removed once the host is powered on again.
+			_, err = testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+			Expect(err).NotTo(HaveOccurred())
+			savedHost := &bmov1alpha1.BareMetalHost{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(host), savedHost)).To(Succeed())
+			Expect(savedHost.Annotations).To(BeEmpty())
+			savedHost.Status.PoweredOn = true
+			Expect(fakeClient.Status().Update(context.TODO(), savedHost)).To(Succeed())
+
+			_, err = testReconciler.Reconcile(context.TODO(), defaultTestRequest)
+			Expect(err).NotTo(HaveOccurred())
+			node, err = getNode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Spec.Taints).To(BeEmpty())
+			Expect(getRemediation().Finalizers).To(BeEmpty())
+			Expect(getMachineAnnotations()).NotTo(HaveKey(infrav1.RemediationDeleteHookAnnotation))
+		},
+		Entry("Kubernetes 1.28, out-of-service taint", nodeRemediationTestCase{
+			GitVersion:        "v1.28.2",
+			ExpectedMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+		}),
+		Entry("Kubernetes 1.27, node deletion", nodeRemediationTestCase{
+			GitVersion:        "v1.27.6",
+			ExpectedMechanism: infrav1.NodeRemediationDeletion,
+		}),
+		Entry("Taint failure, node deletion", nodeRemediationTestCase{
+			GitVersion:        "v1.28.2",
+			TaintFails:        true,
+			ExpectedMechanism: infrav1.NodeRemediationDeletion,
+		}),
 	)
 
 	DescribeTable("ReconcileNormal tests", func(tc reconcileNormalRemediationTestCase) {
 		fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
+		recorder := record.NewFakeRecorder(10)
 		testReconciler = &Metal3RemediationReconciler{
 			Client:         fakeClient,
 			ManagerFactory: baremetal.NewManagerFactory(fakeClient),
 			Log:            logr.Discard(),
+			Recorder:       recorder,
 		}
 		m := setReconcileNormalRemediationExpectations(goMockCtrl, tc)
-		res, err := testReconciler.reconcileNormal(context.TODO(), m)
+		metal3Remediation := &infrav1.Metal3Remediation{
+			Status: infrav1.Metal3RemediationStatus{FailureDomain: "fd-1", HostZone: "zone-a"},
+		}
+		res, err := testReconciler.reconcileNormal(context.TODO(), metal3Remediation, m)
 
 		if tc.ExpectError {
 			Expect(err).To(HaveOccurred())
@@ -349,7 +845,45 @@ var _ = Describe("Metal3Remediation controller", func() {
 		} else {
 			Expect(res.Requeue || res.RequeueAfter > 0).To(BeFalse())
 		}
+		if tc.ExpectKubeconfigReason != "" {
+			condition := conditions.Get(metal3Remediation, infrav1.WorkloadClusterKubeconfigAvailableCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(tc.ExpectKubeconfigReason))
+		} else {
+			Expect(conditions.IsFalse(metal3Remediation, infrav1.WorkloadClusterKubeconfigAvailableCondition)).To(BeFalse())
+		}
+		if tc.ExpectVacatedEvent {
+			Expect(recorder.Events).To(Receive(Equal(fmt.Sprintf("Normal %s Unhealthy machine is deleted, vacating failure domain %q and host zone %q",
+				infrav1.FailureDomainVacatedReason, "fd-1", "zone-a"))))
+		}
+		Expect(recorder.Events).NotTo(Receive())
 	},
+		Entry("Should requeue without error if the kubeconfig is not found", reconcileNormalRemediationTestCase{
+			ExpectError:      false,
+			ExpectRequeue:    true,
+			RemediationPhase: infrav1.PhaseRunning,
+			KubeconfigError: &infraremote.KubeconfigError{
+				Reason: infrav1.KubeconfigNotFoundReason, Err: errors.New("secret not found"),
+			},
+			ExpectKubeconfigReason: infrav1.KubeconfigNotFoundReason,
+		}),
+		Entry("Should requeue without error if the kubeconfig is invalid", reconcileNormalRemediationTestCase{
+			ExpectError:      false,
+			ExpectRequeue:    true,
+			RemediationPhase: infrav1.PhaseRunning,
+			KubeconfigError: &infraremote.KubeconfigError{
+				Reason: infrav1.KubeconfigInvalidReason, Err: errors.New("invalid kubeconfig"),
+			},
+			ExpectKubeconfigReason: infrav1.KubeconfigInvalidReason,
+		}),
+		Entry("Should requeue without error if the kubeconfig is unauthorized", reconcileNormalRemediationTestCase{
+			ExpectError:            false,
+			ExpectRequeue:          true,
+			RemediationPhase:       infrav1.PhaseRunning,
+			NodeUnauthorized:       true,
+			ExpectKubeconfigReason: infrav1.KubeconfigUnauthorizedReason,
+		}),
 		Entry("Should error if unhealthy host not found", reconcileNormalRemediationTestCase{
 			ExpectError:           true,
 			ExpectRequeue:         false,
@@ -431,6 +965,35 @@ var _ = Describe("Metal3Remediation controller", func() {
 			IsNodeDeleted:       false,
 			IsTimedOut:          false,
 		}),
+		Entry("Should delete node when the cluster version was already checked, and then requeue", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            true,
+			RemediationPhase:         infrav1.PhaseRunning,
+			IsFinalizerSet:           true,
+			IsPowerOffRequested:      true,
+			IsPoweredOn:              false,
+			IsNodeBackedUp:           true,
+			NodeRemediationMechanism: infrav1.NodeRemediationDeletion,
+		}),
+		Entry("Should taint node as out of service when supported, and switch to waiting", reconcileNormalRemediationTestCase{
+			ExpectError:                false,
+			ExpectRequeue:              true,
+			RemediationPhase:           infrav1.PhaseRunning,
+			IsFinalizerSet:             true,
+			IsPowerOffRequested:        true,
+			IsPoweredOn:                false,
+			OutOfServiceTaintSupported: true,
+		}),
+		Entry("Should fall back to node deletion when the taint fails, and then requeue", reconcileNormalRemediationTestCase{
+			ExpectError:                false,
+			ExpectRequeue:              true,
+			RemediationPhase:           infrav1.PhaseRunning,
+			IsFinalizerSet:             true,
+			IsPowerOffRequested:        true,
+			IsPoweredOn:                false,
+			OutOfServiceTaintSupported: true,
+			OutOfServiceTaintFails:     true,
+		}),
 		Entry("Should update phase when node is deleted", reconcileNormalRemediationTestCase{
 			ExpectError:         false,
 			ExpectRequeue:       true,
@@ -453,6 +1016,25 @@ var _ = Describe("Metal3Remediation controller", func() {
 			IsNodeDeleted:       true,
 			IsTimedOut:          false,
 		}),
+		Entry("Should wait for the drain of the out-of-service node before powering on", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            true,
+			RemediationPhase:         infrav1.PhaseWaiting,
+			IsFinalizerSet:           true,
+			IsPowerOffRequested:      true,
+			IsPoweredOn:              false,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+			IsNodeDraining:           true,
+		}),
+		Entry("Should request power on once the out-of-service node is drained, and then requeue", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            true,
+			RemediationPhase:         infrav1.PhaseWaiting,
+			IsFinalizerSet:           true,
+			IsPowerOffRequested:      true,
+			IsPoweredOn:              false,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+		}),
 		Entry("Should requeue while still powered off", reconcileNormalRemediationTestCase{
 			ExpectError:         false,
 			ExpectRequeue:       true,
@@ -497,6 +1079,25 @@ var _ = Describe("Metal3Remediation controller", func() {
 			IsNodeDeleted:       false,
 			IsTimedOut:          false,
 		}),
+		Entry("Should remove the out-of-service taint and clean up and requeue", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            true,
+			RemediationPhase:         infrav1.PhaseWaiting,
+			IsFinalizerSet:           true,
+			IsPowerOffRequested:      false,
+			IsPoweredOn:              true,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+		}),
+		Entry("Should return an error if the out-of-service taint can't be removed", reconcileNormalRemediationTestCase{
+			ExpectError:              true,
+			ExpectRequeue:            false,
+			RemediationPhase:         infrav1.PhaseWaiting,
+			IsFinalizerSet:           true,
+			IsPowerOffRequested:      false,
+			IsPoweredOn:              true,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+			OutOfServiceTaintFails:   true,
+		}),
 		Entry("Should skip restore node if forbidden and clean up and requeue", reconcileNormalRemediationTestCase{
 			ExpectError:         false,
 			ExpectRequeue:       true,
@@ -532,12 +1133,87 @@ var _ = Describe("Metal3Remediation controller", func() {
 			IsNodeDeleted:       true,
 			IsTimedOut:          true,
 			IsRetryLimitReached: true,
+			ExpectVacatedEvent:  true,
+		}),
+		Entry("Escalate: should restart remediation if retry limit is not reached, and then requeue", reconcileNormalRemediationTestCase{
+			ExpectError:         false,
+			ExpectRequeue:       true,
+			RemediationType:     infrav1.EscalateRemediationStrategy,
+			RemediationPhase:    infrav1.PhaseWaiting,
+			IsFinalizerSet:      true,
+			IsPowerOffRequested: false,
+			IsPoweredOn:         true,
+			IsNodeBackedUp:      true,
+			IsNodeDeleted:       true,
+			IsTimedOut:          true,
+			IsRetryLimitReached: false,
+		}),
+		Entry("Escalate: should switch to deprovisioning after the last failed reboot, and requeue", reconcileNormalRemediationTestCase{
+			ExpectError:         false,
+			ExpectRequeue:       true,
+			RemediationType:     infrav1.EscalateRemediationStrategy,
+			RemediationPhase:    infrav1.PhaseWaiting,
+			IsFinalizerSet:      true,
+			IsPowerOffRequested: false,
+			IsPoweredOn:         true,
+			IsNodeBackedUp:      true,
+			IsNodeDeleted:       true,
+			IsTimedOut:          true,
+			IsRetryLimitReached: true,
+			ExpectVacatedEvent:  true,
+		}),
+		Entry("Escalate: should delete the machine and set phase Done, and don't requeue", reconcileNormalRemediationTestCase{
+			ExpectError:      false,
+			ExpectRequeue:    false,
+			RemediationType:  infrav1.EscalateRemediationStrategy,
+			RemediationPhase: infrav1.PhaseDeprovisioning,
+		}),
+		Entry("Escalate: should return an error if the machine deletion fails", reconcileNormalRemediationTestCase{
+			ExpectError:        true,
+			ExpectRequeue:      false,
+			RemediationType:    infrav1.EscalateRemediationStrategy,
+			RemediationPhase:   infrav1.PhaseDeprovisioning,
+			DeleteMachineFails: true,
+		}),
+		Entry("Escalate: should remove the out-of-service taint before deleting the machine", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            false,
+			RemediationType:          infrav1.EscalateRemediationStrategy,
+			RemediationPhase:         infrav1.PhaseDeprovisioning,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+		}),
+		Entry("Escalate: should return an error if the out-of-service taint can't be removed", reconcileNormalRemediationTestCase{
+			ExpectError:              true,
+			ExpectRequeue:            false,
+			RemediationType:          infrav1.EscalateRemediationStrategy,
+			RemediationPhase:         infrav1.PhaseDeprovisioning,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+			OutOfServiceTaintFails:   true,
 		}),
 		Entry("Should not requeue for Phase Deleting", reconcileNormalRemediationTestCase{
 			ExpectError:      false,
 			ExpectRequeue:    false,
 			RemediationPhase: infrav1.PhaseDeleting,
 		}),
+		Entry("Should remove the out-of-service taint for Phase Deleting, and don't requeue", reconcileNormalRemediationTestCase{
+			ExpectError:              false,
+			ExpectRequeue:            false,
+			RemediationPhase:         infrav1.PhaseDeleting,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+		}),
+		Entry("Should return an error if the out-of-service taint can't be removed for Phase Deleting", reconcileNormalRemediationTestCase{
+			ExpectError:              true,
+			ExpectRequeue:            false,
+			RemediationPhase:         infrav1.PhaseDeleting,
+			NodeRemediationMechanism: infrav1.NodeRemediationOutOfServiceTaint,
+			OutOfServiceTaintFails:   true,
+		}),
+		Entry("Should not requeue for Phase Done", reconcileNormalRemediationTestCase{
+			ExpectError:      false,
+			ExpectRequeue:    false,
+			RemediationType:  infrav1.EscalateRemediationStrategy,
+			RemediationPhase: infrav1.PhaseDone,
+		}),
 		Entry("Should not requeue for Phase Failed", reconcileNormalRemediationTestCase{
 			ExpectError:      false,
 			ExpectRequeue:    false,
@@ -545,6 +1221,48 @@ var _ = Describe("Metal3Remediation controller", func() {
 		}),
 	)
 
+	DescribeTable("Test setHostRemediatedCondition",
+		func(phase string, history []infrav1.RemediationAttempt, expectSet bool, expectedReason string,
+			expectedSeverity clusterv1.ConditionSeverity) {
+			metal3Remediation := &infrav1.Metal3Remediation{
+				Status: infrav1.Metal3RemediationStatus{
+					Phase:   phase,
+					History: history,
+				},
+			}
+
+			setHostRemediatedCondition(metal3Remediation)
+
+			if !expectSet {
+				Expect(conditions.Has(metal3Remediation, infrav1.HostRemediatedCondition)).To(BeFalse())
+				return
+			}
+			if expectedReason == "" {
+				Expect(conditions.IsTrue(metal3Remediation, infrav1.HostRemediatedCondition)).To(BeTrue())
+				return
+			}
+			Expect(conditions.IsFalse(metal3Remediation, infrav1.HostRemediatedCondition)).To(BeTrue())
+			Expect(conditions.GetReason(metal3Remediation, infrav1.HostRemediatedCondition)).To(Equal(expectedReason))
+			Expect(conditions.GetSeverity(metal3Remediation, infrav1.HostRemediatedCondition)).To(Equal(&expectedSeverity))
+		},
+		Entry("Not started", "", nil, false, "", clusterv1.ConditionSeverity("")),
+		Entry("Running", infrav1.PhaseRunning, []infrav1.RemediationAttempt{{}}, true,
+			infrav1.RemediationInProgressReason, clusterv1.ConditionSeverityInfo),
+		Entry("Waiting, after a timed out attempt", infrav1.PhaseWaiting,
+			[]infrav1.RemediationAttempt{{Outcome: infrav1.RemediationOutcomeTimedOut}, {}}, true,
+			infrav1.RemediationInProgressReason, clusterv1.ConditionSeverityInfo),
+		Entry("Waiting, remediation succeeded", infrav1.PhaseWaiting,
+			[]infrav1.RemediationAttempt{{Outcome: infrav1.RemediationOutcomeSucceeded}}, true,
+			"", clusterv1.ConditionSeverity("")),
+		Entry("Deprovisioning", infrav1.PhaseDeprovisioning, nil, true,
+			infrav1.RemediationEscalatedReason, clusterv1.ConditionSeverityWarning),
+		Entry("Done", infrav1.PhaseDone, nil, true, "", clusterv1.ConditionSeverity("")),
+		Entry("Deleting machine", infrav1.PhaseDeleting, nil, true,
+			infrav1.RemediationFailedReason, clusterv1.ConditionSeverityError),
+		Entry("Failed", infrav1.PhaseFailed, nil, true,
+			infrav1.RemediationFailedReason, clusterv1.ConditionSeverityError),
+	)
+
 	DescribeTable("Metal3Remediation marshal test",
 		func(tc marshallRemediationTestCase) {
 			nodeAnnotations, err := marshal(tc.Map)
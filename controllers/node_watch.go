@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// watchClusterNodes registers a watch on the Nodes of the workload cluster
+// through the ClusterCacheTracker, so that the events on the Nodes are mapped
+// to reconcile requests by mapFunc. The watch is registered once per name and
+// cluster, and torn down by the tracker when the cluster is deleted or
+// unreachable. It is a no-op if no tracker is configured or if the control
+// plane of the cluster is not initialized yet.
+func watchClusterNodes(ctx context.Context, tracker *remote.ClusterCacheTracker, watcher remote.Watcher,
+	name string, cluster *clusterv1.Cluster, mapFunc handler.MapFunc,
+) error {
+	if tracker == nil || watcher == nil {
+		return nil
+	}
+	if !conditions.IsTrue(cluster, clusterv1.ControlPlaneInitializedCondition) {
+		return nil
+	}
+	return tracker.Watch(ctx, remote.WatchInput{
+		Name:         name,
+		Cluster:      util.ObjectKey(cluster),
+		Watcher:      watcher,
+		Kind:         &corev1.Node{},
+		EventHandler: handler.EnqueueRequestsFromMapFunc(mapFunc),
+	})
+}
+
+// nodeToMachineKey returns the key of the Machine of a workload cluster Node,
+// from the annotations set on the Node by CAPI.
+func nodeToMachineKey(o client.Object) (client.ObjectKey, bool) {
+	node, ok := o.(*corev1.Node)
+	if !ok {
+		return client.ObjectKey{}, false
+	}
+	annotations := node.GetAnnotations()
+	machineName, ok := annotations[clusterv1.MachineAnnotation]
+	if !ok || machineName == "" {
+		return client.ObjectKey{}, false
+	}
+	namespace, ok := annotations[clusterv1.ClusterNamespaceAnnotation]
+	if !ok || namespace == "" {
+		return client.ObjectKey{}, false
+	}
+	return client.ObjectKey{Namespace: namespace, Name: machineName}, true
+}
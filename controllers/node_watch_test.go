@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Workload cluster Node watch", func() {
+	newNode := func(annotations map[string]string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-0",
+				Annotations: annotations,
+			},
+		}
+	}
+	nodeAnnotations := map[string]string{
+		clusterv1.MachineAnnotation:          machineName,
+		clusterv1.ClusterNamespaceAnnotation: namespaceName,
+	}
+
+	type testCaseNodeToObjects struct {
+		Node                  client.Object
+		ExpectMetal3Machine   bool
+		ExpectBareMetalHost   bool
+		ExpectMetal3Remediate string
+	}
+
+	DescribeTable("Node to objects tests",
+		func(tc testCaseNodeToObjects) {
+			objects := []client.Object{
+				newMachine(clusterName, machineName, metal3machineName, ""),
+				newMetal3Machine(metal3machineName, m3mObjectMeta(), nil, nil, false),
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+
+			machineReconciler := Metal3MachineReconciler{Client: fakeClient, Log: logr.Discard()}
+			reqs := machineReconciler.NodeToMetal3Machines(context.Background(), tc.Node)
+			if tc.ExpectMetal3Machine {
+				Expect(reqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+					Name:      metal3machineName,
+					Namespace: namespaceName,
+				}}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+
+			labelSyncReconciler := Metal3LabelSyncReconciler{Client: fakeClient, Log: logr.Discard()}
+			reqs = labelSyncReconciler.NodeToBareMetalHosts(context.Background(), tc.Node)
+			if tc.ExpectBareMetalHost {
+				Expect(reqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+					Name:      baremetalhostName,
+					Namespace: namespaceName,
+				}}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+
+			remediationReconciler := Metal3RemediationReconciler{Client: fakeClient, Log: logr.Discard()}
+			reqs = remediationReconciler.NodeToMetal3Remediation(context.Background(), tc.Node)
+			if tc.ExpectMetal3Remediate != "" {
+				Expect(reqs).To(Equal([]ctrl.Request{{NamespacedName: types.NamespacedName{
+					Name:      tc.ExpectMetal3Remediate,
+					Namespace: namespaceName,
+				}}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+		},
+		Entry("Node of a Machine", testCaseNodeToObjects{
+			Node:                  newNode(nodeAnnotations),
+			ExpectMetal3Machine:   true,
+			ExpectBareMetalHost:   true,
+			ExpectMetal3Remediate: machineName,
+		}),
+		Entry("Node without annotations", testCaseNodeToObjects{
+			Node: newNode(nil),
+		}),
+		Entry("Node without cluster namespace annotation", testCaseNodeToObjects{
+			Node: newNode(map[string]string{clusterv1.MachineAnnotation: machineName}),
+		}),
+		Entry("Node of a missing Machine", testCaseNodeToObjects{
+			Node: newNode(map[string]string{
+				clusterv1.MachineAnnotation:          "missing-machine",
+				clusterv1.ClusterNamespaceAnnotation: namespaceName,
+			}),
+			ExpectMetal3Remediate: "missing-machine",
+		}),
+		Entry("Not a Node", testCaseNodeToObjects{
+			Node: newMachine(clusterName, machineName, metal3machineName, ""),
+		}),
+	)
+
+	It("Does not watch the Nodes without a tracker", func() {
+		cluster := newCluster(clusterName, nil, nil)
+		conditions.MarkTrue(cluster, clusterv1.ControlPlaneInitializedCondition)
+		err := watchClusterNodes(context.Background(), nil, nil, "test", cluster,
+			(&Metal3RemediationReconciler{}).NodeToMetal3Remediation)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
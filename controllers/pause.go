@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// hasPausedAnnotation returns whether the reconciliation of the object is
+// paused with the CAPM3 paused annotation.
+func hasPausedAnnotation(obj metav1.Object) bool {
+	_, ok := obj.GetAnnotations()[infrav1.PausedAnnotation]
+	return ok
+}
+
+// setPausedCondition makes the paused state of the object visible in its
+// conditions.
+func setPausedCondition(obj conditions.Setter, paused bool) {
+	if paused {
+		conditions.MarkTrue(obj, infrav1.PausedCondition)
+		return
+	}
+	conditions.Delete(obj, infrav1.PausedCondition)
+}
+
+// ResourceNotPausedByAnnotation returns a predicate that filters out the events
+// of objects with the CAPM3 paused annotation. Updates adding or removing the
+// annotation are kept, so that the paused state is reported and the
+// reconciliation resumes as soon as the annotation is removed.
+func ResourceNotPausedByAnnotation(logger logr.Logger) predicate.Funcs {
+	log := logger.WithValues("predicate", "ResourceNotPausedByAnnotation")
+	notPaused := func(obj client.Object) bool {
+		if hasPausedAnnotation(obj) {
+			log.V(4).Info("Resource is paused, will not attempt to map resource", "namespace", obj.GetNamespace(), "name", obj.GetName())
+			return false
+		}
+		return true
+	}
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if hasPausedAnnotation(e.ObjectOld) != hasPausedAnnotation(e.ObjectNew) {
+				return true
+			}
+			return notPaused(e.ObjectNew)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return notPaused(e.Object)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return notPaused(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return notPaused(e.Object)
+		},
+	}
+}
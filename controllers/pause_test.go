@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Paused annotation", func() {
+	objectWithAnnotations := func(annotations map[string]string) *infrav1.Metal3Machine {
+		return &infrav1.Metal3Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        metal3machineName,
+				Namespace:   namespaceName,
+				Annotations: annotations,
+			},
+		}
+	}
+	paused := map[string]string{infrav1.PausedAnnotation: ""}
+	notPaused := map[string]string{"foo": "bar"}
+
+	type testCasePredicate struct {
+		OldAnnotations map[string]string
+		NewAnnotations map[string]string
+		ExpectedResult bool
+	}
+
+	DescribeTable("ResourceNotPausedByAnnotation",
+		func(tc testCasePredicate) {
+			p := ResourceNotPausedByAnnotation(logr.Discard())
+			oldObj := objectWithAnnotations(tc.OldAnnotations)
+			newObj := objectWithAnnotations(tc.NewAnnotations)
+			Expect(p.Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj})).To(Equal(tc.ExpectedResult))
+			if hasPausedAnnotation(oldObj) == hasPausedAnnotation(newObj) {
+				Expect(p.Create(event.CreateEvent{Object: newObj})).To(Equal(tc.ExpectedResult))
+				Expect(p.Delete(event.DeleteEvent{Object: newObj})).To(Equal(tc.ExpectedResult))
+				Expect(p.Generic(event.GenericEvent{Object: newObj})).To(Equal(tc.ExpectedResult))
+			}
+		},
+		Entry("Not paused", testCasePredicate{
+			OldAnnotations: notPaused,
+			NewAnnotations: notPaused,
+			ExpectedResult: true,
+		}),
+		Entry("Paused", testCasePredicate{
+			OldAnnotations: paused,
+			NewAnnotations: paused,
+		}),
+		Entry("Annotation added", testCasePredicate{
+			OldAnnotations: notPaused,
+			NewAnnotations: paused,
+			ExpectedResult: true,
+		}),
+		Entry("Annotation removed", testCasePredicate{
+			OldAnnotations: paused,
+			NewAnnotations: nil,
+			ExpectedResult: true,
+		}),
+	)
+
+	It("Sets and removes the Paused condition", func() {
+		obj := objectWithAnnotations(nil)
+		setPausedCondition(obj, true)
+		Expect(conditions.Get(obj, infrav1.PausedCondition)).NotTo(BeNil())
+		Expect(conditions.Get(obj, infrav1.PausedCondition).Status).To(Equal(corev1.ConditionTrue))
+		setPausedCondition(obj, false)
+		Expect(conditions.Has(obj, infrav1.PausedCondition)).To(BeFalse())
+	})
+})
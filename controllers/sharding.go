@@ -0,0 +1,184 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ShardKey is what the objects are hashed on to be assigned to a shard.
+type ShardKey string
+
+const (
+	// ShardByCluster hashes the objects on their namespace and the name of
+	// their cluster.
+	ShardByCluster ShardKey = "cluster"
+	// ShardByNamespace hashes the objects on their namespace only.
+	ShardByNamespace ShardKey = "namespace"
+)
+
+// ShardOptions configures how several CAPM3 instances split the objects. With
+// a watch-filter value, each instance adopts its share of the objects that do
+// not carry the watch-filter label. Without watch-filter value, each instance
+// reconciles its share of all the objects.
+type ShardOptions struct {
+	// Count is the number of CAPM3 instances sharing the objects. Objects
+	// are not sharded when it is lower than 2.
+	Count int
+	// Index is the index of this instance, from 0 to Count-1.
+	Index int
+	// Key is what the objects are hashed on, ShardByNamespace if empty as
+	// with the --shard-key flag.
+	Key ShardKey
+}
+
+// Enabled returns whether objects without watch-filter label are sharded.
+func (s ShardOptions) Enabled() bool {
+	return s.Count > 1
+}
+
+// Owns returns whether an object belongs to this shard. Objects are hashed on
+// their namespace only, so that all objects of a namespace land in the same
+// shard. With ShardByCluster, objects are hashed on their namespace and the
+// name of their cluster, so that all objects of a cluster land in the same
+// shard. Objects without cluster name label are then hashed on their own name.
+func (s ShardOptions) Owns(obj metav1.Object) bool {
+	if !s.Enabled() {
+		return false
+	}
+	key := obj.GetNamespace()
+	if s.Key == ShardByCluster {
+		name := baremetal.ClusterNameLabelValue(obj.GetLabels())
+		if name == "" {
+			name = obj.GetName()
+		}
+		key += "/" + name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32()%uint32(s.Count)) == s.Index
+}
+
+// LeaderElectionID returns the leader election ID of this shard, so that one
+// instance per shard is active. It is the given ID when sharding is disabled.
+func (s ShardOptions) LeaderElectionID(id string) string {
+	if !s.Enabled() {
+		return id
+	}
+	return fmt.Sprintf("%s-shard-%d", id, s.Index)
+}
+
+// ResourceHasFilterLabelOrShard returns a predicate that returns true if the
+// resource has the watch-filter label with the configured value, or if the
+// resource has no watch-filter label and belongs to the shard. When no label
+// value is configured, the resources of the shard are accepted, all resources
+// without sharding.
+func ResourceHasFilterLabelOrShard(logger logr.Logger, labelValue string, shard ShardOptions) predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return processIfLabelMatchOrShard(logger.WithValues("predicate", "ResourceHasFilterLabelOrShard"), obj, labelValue, shard)
+	})
+}
+
+// ResourceNotPausedAndHasFilterLabelOrShard returns a predicate that returns
+// true only if the ResourceNotPaused and ResourceHasFilterLabelOrShard
+// predicates return true.
+func ResourceNotPausedAndHasFilterLabelOrShard(logger logr.Logger, labelValue string, shard ShardOptions) predicate.Funcs {
+	return predicates.All(logger, predicates.ResourceNotPaused(logger), ResourceHasFilterLabelOrShard(logger, labelValue, shard))
+}
+
+// ResourceNotPausedAndInShard returns a predicate for the objects that do not
+// carry the watch-filter label, e.g. BareMetalHosts and Secrets. With a label
+// value, they are all accepted, the reconciler filters them on the label of
+// the related Cluster API or CAPM3 object. Without label value, only the
+// resources of the shard are accepted, all resources without sharding.
+func ResourceNotPausedAndInShard(logger logr.Logger, labelValue string, shard ShardOptions) predicate.Funcs {
+	if labelValue != "" {
+		return predicates.ResourceNotPaused(logger)
+	}
+	return ResourceNotPausedAndHasFilterLabelOrShard(logger, "", shard)
+}
+
+func processIfLabelMatchOrShard(logger logr.Logger, obj client.Object, labelValue string, shard ShardOptions) bool {
+	log := logger.WithValues("namespace", obj.GetNamespace(), strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind), obj.GetName())
+	if labelValue == "" {
+		if !shard.Enabled() || shard.Owns(obj) {
+			return true
+		}
+		log.V(6).Info("Resource does not belong to this shard, will not attempt to map resource")
+		return false
+	}
+	value, ok := obj.GetLabels()[clusterv1.WatchLabel]
+	if ok {
+		if value == labelValue {
+			return true
+		}
+		log.V(6).Info("Resource does not match label, will not attempt to map resource")
+		return false
+	}
+	if shard.Owns(obj) {
+		log.V(6).Info("Resource without label belongs to this shard, will attempt to map resource")
+		return true
+	}
+	log.V(6).Info("Resource without label does not belong to this shard, will not attempt to map resource")
+	return false
+}
+
+// adoptObject sets the watch-filter label on an object of this shard that has
+// none, so that it stays with this instance if the shards change and so that
+// the objects created from it inherit the label.
+func adoptObject(obj metav1.Object, labelValue string, shard ShardOptions) bool {
+	if labelValue == "" || !shard.Enabled() {
+		return false
+	}
+	labels := obj.GetLabels()
+	if _, ok := labels[clusterv1.WatchLabel]; ok {
+		return false
+	}
+	if !shard.Owns(obj) {
+		return false
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[clusterv1.WatchLabel] = labelValue
+	obj.SetLabels(labels)
+	return true
+}
+
+// ownedByOtherShard returns whether the object is reconciled by another
+// instance: without watch-filter value, the objects that do not belong to the
+// shard. With a watch-filter value, the objects labeled with another value,
+// and the objects without watch-filter label that do not belong to the shard.
+func ownedByOtherShard(obj metav1.Object, labelValue string, shard ShardOptions) bool {
+	if labelValue == "" {
+		return shard.Enabled() && !shard.Owns(obj)
+	}
+	if value, ok := obj.GetLabels()[clusterv1.WatchLabel]; ok {
+		return value != labelValue
+	}
+	return !shard.Owns(obj)
+}
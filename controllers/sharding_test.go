@@ -0,0 +1,542 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-logr/logr"
+	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Watch-filter sharding", func() {
+	shards := map[string]ShardOptions{
+		"shard-a": {Count: 2, Index: 0, Key: ShardByCluster},
+		"shard-b": {Count: 2, Index: 1, Key: ShardByCluster},
+	}
+
+	objectWithLabels := func(name string, labels map[string]string) *infrav1.Metal3Data {
+		return &infrav1.Metal3Data{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespaceName,
+				Labels:    labels,
+			},
+		}
+	}
+
+	type testCaseFilter struct {
+		LabelValue     string
+		Shard          ShardOptions
+		Labels         map[string]string
+		ExpectedResult bool
+	}
+
+	DescribeTable("ResourceHasFilterLabelOrShard",
+		func(tc testCaseFilter) {
+			p := ResourceHasFilterLabelOrShard(logr.Discard(), tc.LabelValue, tc.Shard)
+			obj := objectWithLabels("abc", tc.Labels)
+			Expect(p.Create(event.CreateEvent{Object: obj})).To(Equal(tc.ExpectedResult))
+			Expect(p.Update(event.UpdateEvent{ObjectOld: obj, ObjectNew: obj})).To(Equal(tc.ExpectedResult))
+			Expect(p.Delete(event.DeleteEvent{Object: obj})).To(Equal(tc.ExpectedResult))
+			Expect(p.Generic(event.GenericEvent{Object: obj})).To(Equal(tc.ExpectedResult))
+		},
+		Entry("No watch-filter", testCaseFilter{
+			ExpectedResult: true,
+		}),
+		Entry("Matching label", testCaseFilter{
+			LabelValue:     "shard-a",
+			Labels:         map[string]string{clusterv1.WatchLabel: "shard-a"},
+			ExpectedResult: true,
+		}),
+		Entry("Matching label, sharded", testCaseFilter{
+			LabelValue:     "shard-a",
+			Shard:          ShardOptions{Count: 2, Index: 0, Key: ShardByCluster},
+			Labels:         map[string]string{clusterv1.WatchLabel: "shard-a"},
+			ExpectedResult: true,
+		}),
+		Entry("Other label, sharded", testCaseFilter{
+			LabelValue: "shard-a",
+			Shard:      ShardOptions{Count: 2, Index: 0, Key: ShardByCluster},
+			Labels:     map[string]string{clusterv1.WatchLabel: "shard-b"},
+		}),
+		Entry("No label, sharding disabled", testCaseFilter{
+			LabelValue: "shard-a",
+		}),
+		Entry("No label, single shard", testCaseFilter{
+			LabelValue: "shard-a",
+			Shard:      ShardOptions{Count: 1, Index: 0},
+		}),
+	)
+
+	DescribeTable("ResourceNotPausedAndInShard",
+		func(tc testCaseFilter) {
+			p := ResourceNotPausedAndInShard(logr.Discard(), tc.LabelValue, tc.Shard)
+			obj := objectWithLabels("abc", tc.Labels)
+			Expect(p.Create(event.CreateEvent{Object: obj})).To(Equal(tc.ExpectedResult))
+			Expect(p.Generic(event.GenericEvent{Object: obj})).To(Equal(tc.ExpectedResult))
+		},
+		Entry("No watch-filter", testCaseFilter{
+			ExpectedResult: true,
+		}),
+		Entry("No label, watch-filter", testCaseFilter{
+			LabelValue:     "shard-a",
+			ExpectedResult: true,
+		}),
+		Entry("No label, watch-filter, sharded", testCaseFilter{
+			LabelValue:     "shard-a",
+			Shard:          ShardOptions{Count: 2, Index: 0, Key: ShardByCluster},
+			ExpectedResult: true,
+		}),
+		Entry("No label, other shard", testCaseFilter{
+			Shard: ShardOptions{Count: 2, Index: 1, Key: ShardByCluster},
+		}),
+	)
+
+	It("Accepts every unlabeled object in exactly one shard", func() {
+		for i := 0; i < 100; i++ {
+			obj := objectWithLabels(fmt.Sprintf("object-%d", i), nil)
+			owners := 0
+			for value, shard := range shards {
+				p := ResourceHasFilterLabelOrShard(logr.Discard(), value, shard)
+				if p.Generic(event.GenericEvent{Object: obj}) {
+					owners++
+				}
+			}
+			Expect(owners).To(Equal(1), "object %s", obj.Name)
+		}
+	})
+
+	It("Keeps the unlabeled objects of a cluster in the same shard", func() {
+		clusterLabels := map[string]string{clusterv1.ClusterNameLabel: clusterName}
+		for value, shard := range shards {
+			expected := shard.Owns(objectWithLabels("first", clusterLabels))
+			for i := 0; i < 20; i++ {
+				obj := objectWithLabels(fmt.Sprintf("object-%d", i), clusterLabels)
+				p := ResourceHasFilterLabelOrShard(logr.Discard(), value, shard)
+				Expect(p.Generic(event.GenericEvent{Object: obj})).To(Equal(expected))
+			}
+		}
+	})
+
+	It("Keeps the objects with a legacy cluster label in the shard of their cluster", func() {
+		Expect(baremetal.SetLegacyLabelKeys(map[string]string{"example.com/cluster-name": clusterv1.ClusterNameLabel})).To(Succeed())
+		DeferCleanup(func() { baremetal.LegacyLabelKeys = nil })
+		for _, shard := range shards {
+			expected := shard.Owns(objectWithLabels("first", map[string]string{clusterv1.ClusterNameLabel: clusterName}))
+			for i := 0; i < 20; i++ {
+				obj := objectWithLabels(fmt.Sprintf("object-%d", i), map[string]string{"example.com/cluster-name": clusterName})
+				Expect(shard.Owns(obj)).To(Equal(expected))
+			}
+		}
+	})
+
+	It("Adopts an unlabeled object in the owning shard only", func() {
+		for i := 0; i < 20; i++ {
+			adopted := 0
+			for value, shard := range shards {
+				obj := objectWithLabels(fmt.Sprintf("object-%d", i), map[string]string{"foo": "bar"})
+				if adoptObject(obj, value, shard) {
+					adopted++
+					Expect(obj.Labels).To(Equal(map[string]string{
+						"foo":                "bar",
+						clusterv1.WatchLabel: value,
+					}))
+				} else {
+					Expect(obj.Labels).To(Equal(map[string]string{"foo": "bar"}))
+				}
+			}
+			Expect(adopted).To(Equal(1))
+		}
+	})
+
+	It("Skips the objects of the other instances", func() {
+		Expect(ownedByOtherShard(objectWithLabels("abc", map[string]string{clusterv1.WatchLabel: "shard-a"}), "shard-a", ShardOptions{})).To(BeFalse())
+		Expect(ownedByOtherShard(objectWithLabels("abc", map[string]string{clusterv1.WatchLabel: "shard-b"}), "shard-a", ShardOptions{})).To(BeTrue())
+		Expect(ownedByOtherShard(objectWithLabels("abc", nil), "shard-a", ShardOptions{})).To(BeTrue())
+		for i := 0; i < 20; i++ {
+			obj := objectWithLabels(fmt.Sprintf("object-%d", i), nil)
+			owners := 0
+			for value, shard := range shards {
+				if !ownedByOtherShard(obj, value, shard) {
+					owners++
+					Expect(shard.Owns(obj)).To(BeTrue())
+				}
+			}
+			Expect(owners).To(Equal(1), "object %s", obj.Name)
+		}
+	})
+
+	It("Reconciles the Metal3Machine of a BareMetalHost in its instance only", func() {
+		for _, labels := range []map[string]string{
+			{clusterv1.WatchLabel: "shard-a"},
+			{clusterv1.WatchLabel: "shard-b"},
+			nil,
+		} {
+			m3m := newMetal3Machine(metal3machineName, m3mObjectMetaWithOwnerRef(), nil, nil, false)
+			m3m.Labels = labels
+			host := newBareMetalHost(baremetalhostName, &bmov1alpha1.BareMetalHostSpec{
+				ConsumerRef: &corev1.ObjectReference{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       "Metal3Machine",
+					Name:       metal3machineName,
+					Namespace:  namespaceName,
+				},
+			}, nil, nil, false)
+			fakeClient := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(m3m, host).WithStatusSubresource(m3m).Build()
+
+			// The reconciler of each instance goes past the shard check for
+			// its Metal3Machines only, and then fetches their Machine.
+			fetched := sets.New[string]()
+			for value, shard := range shards {
+				value := value
+				mgrClient := machineRecordingClient{
+					Client: fakeClient,
+					record: func(_ client.ObjectKey) { fetched.Insert(value) },
+				}
+				r := &Metal3MachineReconciler{
+					Client:           mgrClient,
+					ManagerFactory:   baremetal.NewManagerFactory(mgrClient),
+					Log:              logr.Discard(),
+					WatchFilterValue: value,
+					Shard:            shard,
+				}
+				for _, req := range r.BareMetalHostToMetal3Machines(context.TODO(), host) {
+					_, _ = r.Reconcile(context.TODO(), req)
+				}
+			}
+
+			expected := labels[clusterv1.WatchLabel]
+			if expected == "" {
+				for value, shard := range shards {
+					if shard.Owns(m3m) {
+						expected = value
+					}
+				}
+			}
+			Expect(fetched).To(Equal(sets.New[string](expected)), "labels %v", labels)
+		}
+	})
+
+	It("Does not adopt labeled objects or without sharding", func() {
+		obj := objectWithLabels("abc", map[string]string{clusterv1.WatchLabel: "shard-b"})
+		Expect(adoptObject(obj, "shard-a", ShardOptions{Count: 2, Index: 0, Key: ShardByCluster})).To(BeFalse())
+		Expect(adoptObject(obj, "shard-a", ShardOptions{Count: 2, Index: 1, Key: ShardByCluster})).To(BeFalse())
+		Expect(obj.Labels[clusterv1.WatchLabel]).To(Equal("shard-b"))
+
+		obj = objectWithLabels("abc", nil)
+		Expect(adoptObject(obj, "shard-a", ShardOptions{})).To(BeFalse())
+		Expect(adoptObject(obj, "", ShardOptions{Count: 2, Index: 0, Key: ShardByCluster})).To(BeFalse())
+		Expect(obj.Labels).To(BeNil())
+	})
+})
+
+var _ = Describe("Replica sharding", func() {
+	shards := []ShardOptions{
+		{Count: 2, Index: 0, Key: ShardByNamespace},
+		{Count: 2, Index: 1, Key: ShardByNamespace},
+	}
+
+	objectInNamespace := func(namespace, name string, labels map[string]string) *infrav1.Metal3Data {
+		return &infrav1.Metal3Data{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    labels,
+			},
+		}
+	}
+
+	It("Accepts every object in exactly one shard", func() {
+		for i := 0; i < 100; i++ {
+			obj := objectInNamespace(fmt.Sprintf("namespace-%d", i), "abc", map[string]string{clusterv1.WatchLabel: "shard-a"})
+			owners := 0
+			for _, shard := range shards {
+				p := ResourceHasFilterLabelOrShard(logr.Discard(), "", shard)
+				if p.Generic(event.GenericEvent{Object: obj}) {
+					owners++
+					Expect(ownedByOtherShard(obj, "", shard)).To(BeFalse())
+				} else {
+					Expect(ownedByOtherShard(obj, "", shard)).To(BeTrue())
+				}
+			}
+			Expect(owners).To(Equal(1), "namespace %s", obj.Namespace)
+		}
+	})
+
+	It("Keeps the objects of a namespace in the same shard", func() {
+		for _, shard := range shards {
+			expected := shard.Owns(objectInNamespace(namespaceName, "first", nil))
+			for i := 0; i < 20; i++ {
+				obj := objectInNamespace(namespaceName, fmt.Sprintf("object-%d", i),
+					map[string]string{clusterv1.ClusterNameLabel: fmt.Sprintf("cluster-%d", i)},
+				)
+				Expect(shard.Owns(obj)).To(Equal(expected))
+			}
+		}
+	})
+
+	It("Hashes the objects on their namespace by default", func() {
+		for _, shard := range shards {
+			byDefault := ShardOptions{Count: shard.Count, Index: shard.Index}
+			for i := 0; i < 20; i++ {
+				obj := objectInNamespace(fmt.Sprintf("namespace-%d", i), "abc",
+					map[string]string{clusterv1.ClusterNameLabel: fmt.Sprintf("cluster-%d", i)},
+				)
+				Expect(byDefault.Owns(obj)).To(Equal(shard.Owns(obj)))
+			}
+		}
+	})
+
+	It("Does not skip objects without sharding", func() {
+		obj := objectInNamespace(namespaceName, "abc", nil)
+		Expect(ownedByOtherShard(obj, "", ShardOptions{})).To(BeFalse())
+		Expect(ownedByOtherShard(obj, "", ShardOptions{Count: 1})).To(BeFalse())
+	})
+
+	DescribeTable("Test LeaderElectionID",
+		func(shard ShardOptions, expected string) {
+			Expect(shard.LeaderElectionID("controller-leader-election-capm3")).To(Equal(expected))
+		},
+		Entry("Sharding disabled", ShardOptions{}, "controller-leader-election-capm3"),
+		Entry("Single shard", ShardOptions{Count: 1}, "controller-leader-election-capm3"),
+		Entry("First shard", ShardOptions{Count: 3, Index: 0}, "controller-leader-election-capm3-shard-0"),
+		Entry("Last shard", ShardOptions{Count: 3, Index: 2}, "controller-leader-election-capm3-shard-2"),
+	)
+
+	It("Reconciles the objects in two managers with disjoint sets", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+
+		var namespaces []string
+		for i := 0; i < 10; i++ {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "shard-"}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+			namespaces = append(namespaces, namespace.Name)
+		}
+
+		var mu sync.Mutex
+		reconciled := make([]sets.Set[types.NamespacedName], len(shards))
+		for i, shard := range shards {
+			i, shard := i, shard
+			reconciled[i] = sets.New[types.NamespacedName]()
+			mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+				Scheme:                        scheme.Scheme,
+				MetricsBindAddress:            "0",
+				LeaderElection:                true,
+				LeaderElectionNamespace:       "default",
+				LeaderElectionID:              shard.LeaderElectionID("capm3-sharding-test"),
+				LeaderElectionReleaseOnCancel: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			mgrClient := mgr.GetClient()
+			err = ctrl.NewControllerManagedBy(mgr).
+				Named(fmt.Sprintf("sharding-test-%d", i)).
+				For(&corev1.ConfigMap{}).
+				WithEventFilter(ResourceHasFilterLabelOrShard(logr.Discard(), "", shard)).
+				// Any secret requests the reconciliation of the ConfigMaps of
+				// all namespaces, including those of the other shard.
+				Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+					func(_ context.Context, _ client.Object) []reconcile.Request {
+						requests := []reconcile.Request{}
+						for _, namespace := range namespaces {
+							requests = append(requests, reconcile.Request{
+								NamespacedName: types.NamespacedName{Namespace: namespace, Name: "sharded"},
+							})
+						}
+						return requests
+					},
+				)).
+				Complete(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+					configMap := &corev1.ConfigMap{}
+					if err := mgrClient.Get(ctx, req.NamespacedName, configMap); err != nil {
+						return reconcile.Result{}, client.IgnoreNotFound(err)
+					}
+					if ownedByOtherShard(configMap, "", shard) {
+						return reconcile.Result{}, nil
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					reconciled[i].Insert(req.NamespacedName)
+					return reconcile.Result{}, nil
+				}))
+			Expect(err).NotTo(HaveOccurred())
+			go func() {
+				defer GinkgoRecover()
+				Expect(mgr.Start(ctx)).To(Succeed())
+			}()
+		}
+
+		expected := sets.New[types.NamespacedName]()
+		for _, namespace := range namespaces {
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "sharded", Namespace: namespace},
+			})).To(Succeed())
+			expected.Insert(types.NamespacedName{Namespace: namespace, Name: "sharded"})
+		}
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sharded", Namespace: namespaces[0]},
+		})).To(Succeed())
+
+		Eventually(func() sets.Set[types.NamespacedName] {
+			mu.Lock()
+			defer mu.Unlock()
+			return reconciled[0].Union(reconciled[1])
+		}, "30s").Should(Equal(expected))
+		Consistently(func() sets.Set[types.NamespacedName] {
+			mu.Lock()
+			defer mu.Unlock()
+			return reconciled[0].Intersection(reconciled[1])
+		}, "2s").Should(BeEmpty())
+	})
+})
+
+// machineRecordingClient records the Machines fetched through it, which are
+// never found, the Cluster API CRDs not being installed in the test
+// environment.
+type machineRecordingClient struct {
+	client.Client
+	record func(key client.ObjectKey)
+}
+
+func (c machineRecordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*clusterv1.Machine); ok {
+		c.record(key)
+		return apierrors.NewNotFound(clusterv1.GroupVersion.WithResource("machines").GroupResource(), key.Name)
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+var _ = Describe("Metal3LabelSync with watch-filter instances", func() {
+	It("Reconciles the unlabeled BareMetalHosts in the instance of their Metal3Machine", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+
+		_, err := envtest.InstallCRDs(cfg, envtest.CRDInstallOptions{
+			Paths: []string{filepath.Join("..", "examples", "metal3crds")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "labelsync-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+		// The reconciler of each instance goes past the watch-filter check
+		// for the hosts of its Metal3Machines only, and then fetches their
+		// Machine.
+		var mu sync.Mutex
+		fetchedMachines := map[string]sets.Set[string]{}
+		for _, value := range []string{"instance-a", "instance-b"} {
+			value := value
+			fetchedMachines[value] = sets.New[string]()
+			mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+				Scheme:             scheme.Scheme,
+				MetricsBindAddress: "0",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			mgrClient := machineRecordingClient{
+				Client: mgr.GetClient(),
+				record: func(key client.ObjectKey) {
+					mu.Lock()
+					defer mu.Unlock()
+					fetchedMachines[value].Insert(key.Name)
+				},
+			}
+			Expect((&Metal3LabelSyncReconciler{
+				Client:           mgrClient,
+				ManagerFactory:   baremetal.NewManagerFactory(mgrClient),
+				Log:              logr.Discard(),
+				WatchFilterValue: value,
+			}).SetupWithManager(ctx, mgr, controller.Options{})).To(Succeed())
+			go func() {
+				defer GinkgoRecover()
+				Expect(mgr.Start(ctx)).To(Succeed())
+			}()
+		}
+
+		for _, value := range []string{"instance-a", "instance-b"} {
+			Expect(k8sClient.Create(ctx, &infrav1.Metal3Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "m3m-" + value,
+					Namespace: namespace.Name,
+					Labels:    map[string]string{clusterv1.WatchLabel: value},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "machine-" + value,
+						UID:        types.UID("machine-uid-" + value),
+					}},
+				},
+				Spec: infrav1.Metal3MachineSpec{
+					Image: infrav1.Image{URL: "http://localhost/image.qcow2", Checksum: "http://localhost/image.qcow2.sha256sum"},
+				},
+			})).To(Succeed())
+			Expect(k8sClient.Create(ctx, &bmov1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "host-" + value,
+					Namespace: namespace.Name,
+				},
+				Spec: bmov1alpha1.BareMetalHostSpec{
+					ConsumerRef: &corev1.ObjectReference{
+						APIVersion: infrav1.GroupVersion.String(),
+						Kind:       "Metal3Machine",
+						Name:       "m3m-" + value,
+						Namespace:  namespace.Name,
+					},
+				},
+			})).To(Succeed())
+		}
+
+		Eventually(func() map[string]sets.Set[string] {
+			mu.Lock()
+			defer mu.Unlock()
+			result := map[string]sets.Set[string]{}
+			for value, machines := range fetchedMachines {
+				result[value] = machines.Clone()
+			}
+			return result
+		}, "30s").Should(Equal(map[string]sets.Set[string]{
+			"instance-a": sets.New[string]("machine-instance-a"),
+			"instance-b": sets.New[string]("machine-instance-b"),
+		}))
+		Consistently(func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return fetchedMachines["instance-a"].Has("machine-instance-b") || fetchedMachines["instance-b"].Has("machine-instance-a")
+		}, "2s").Should(BeFalse())
+	})
+})
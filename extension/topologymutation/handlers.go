@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package topologymutation implements the topology mutation hooks of the
+// Cluster API Runtime SDK, so that the ClusterClasses can expose the image,
+// the hostSelector and the dataTemplate of the Metal3MachineTemplates as
+// typed variables instead of raw JSON patches.
+package topologymutation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	runtimehooksv1 "sigs.k8s.io/cluster-api/exp/runtime/hooks/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/exp/runtime/topologymutation"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// ImageVariable is the name of the variable setting the image of the
+	// Metal3MachineTemplates, an object with the fields of the image of a
+	// Metal3Machine.
+	ImageVariable = "metal3Image"
+
+	// HostSelectorVariable is the name of the variable setting the
+	// hostSelector of the Metal3MachineTemplates, an object with the fields of
+	// the hostSelector of a Metal3Machine.
+	HostSelectorVariable = "metal3HostSelector"
+
+	// DataTemplateVariable is the name of the variable setting the name of the
+	// Metal3DataTemplate of the Metal3MachineTemplates, in the namespace of
+	// the Cluster.
+	DataTemplateVariable = "metal3DataTemplate"
+)
+
+// ExtensionHandlers implements the GeneratePatches and ValidateTopology
+// hooks for the Metal3 templates.
+type ExtensionHandlers struct {
+	decoder runtime.Decoder
+}
+
+// NewExtensionHandlers returns the handlers decoding the templates with the
+// given scheme, which must contain the Metal3 types.
+func NewExtensionHandlers(scheme *runtime.Scheme) *ExtensionHandlers {
+	return &ExtensionHandlers{
+		decoder: serializer.NewCodecFactory(scheme).UniversalDecoder(infrav1.GroupVersion),
+	}
+}
+
+// GeneratePatches patches the Metal3MachineTemplates of the topology with
+// the values of the Metal3 variables. The variables not set leave the
+// templates unchanged, and the variables of a MachineDeployment topology
+// override the ones of the Cluster.
+func (h *ExtensionHandlers) GeneratePatches(ctx context.Context, req *runtimehooksv1.GeneratePatchesRequest,
+	resp *runtimehooksv1.GeneratePatchesResponse,
+) {
+	log := ctrl.LoggerFrom(ctx)
+	log.Info("GeneratePatches is called")
+
+	topologymutation.WalkTemplates(ctx, h.decoder, req, resp, func(ctx context.Context, obj runtime.Object,
+		variables map[string]apiextensionsv1.JSON, _ runtimehooksv1.HolderReference,
+	) error {
+		m3mt, ok := obj.(*infrav1.Metal3MachineTemplate)
+		if !ok {
+			return nil
+		}
+		return patchMetal3MachineTemplate(ctx, m3mt, variables)
+	})
+}
+
+// ValidateTopology validates the Metal3MachineTemplates of the topology,
+// once patched, with the checks of the Metal3MachineTemplate webhook, so
+// that an invalid variable value is reported on the Cluster rather than when
+// the templates are created.
+func (h *ExtensionHandlers) ValidateTopology(ctx context.Context, req *runtimehooksv1.ValidateTopologyRequest,
+	resp *runtimehooksv1.ValidateTopologyResponse,
+) {
+	log := ctrl.LoggerFrom(ctx)
+	log.Info("ValidateTopology is called")
+
+	var messages []string
+	for _, item := range req.Items {
+		obj, _, err := h.decoder.Decode(item.Object.Raw, nil, item.Object.Object)
+		if err != nil {
+			// The templates of other providers are not validated.
+			continue
+		}
+		m3mt, ok := obj.(*infrav1.Metal3MachineTemplate)
+		if !ok {
+			continue
+		}
+		if _, err := m3mt.ValidateCreate(); err != nil {
+			messages = append(messages, fmt.Sprintf("%s %s: %s", item.HolderReference.Kind,
+				item.HolderReference.Name, err.Error()))
+		}
+	}
+	if len(messages) != 0 {
+		resp.Status = runtimehooksv1.ResponseStatusFailure
+		resp.Message = strings.Join(messages, "; ")
+		return
+	}
+	resp.Status = runtimehooksv1.ResponseStatusSuccess
+}
+
+// patchMetal3MachineTemplate sets the fields of the template given by the
+// variables.
+func patchMetal3MachineTemplate(ctx context.Context, m3mt *infrav1.Metal3MachineTemplate,
+	variables map[string]apiextensionsv1.JSON,
+) error {
+	log := ctrl.LoggerFrom(ctx)
+	spec := &m3mt.Spec.Template.Spec
+
+	image := infrav1.Image{}
+	found, err := getObjectVariable(variables, ImageVariable, &image)
+	if err != nil {
+		return err
+	}
+	if found {
+		log.Info("Setting the image", "url", image.URL)
+		spec.Image = image
+	}
+
+	hostSelector := infrav1.HostSelector{}
+	found, err = getObjectVariable(variables, HostSelectorVariable, &hostSelector)
+	if err != nil {
+		return err
+	}
+	if found {
+		log.Info("Setting the hostSelector")
+		spec.HostSelector = hostSelector
+	}
+
+	dataTemplate, found, err := topologymutation.GetStringVariable(variables, DataTemplateVariable)
+	if err != nil {
+		return err
+	}
+	if found {
+		log.Info("Setting the dataTemplate", "name", dataTemplate)
+		spec.DataTemplate = &corev1.ObjectReference{Name: dataTemplate}
+	}
+	return nil
+}
+
+// getObjectVariable unmarshals the value of the variable, and returns false
+// if it is not set.
+func getObjectVariable(variables map[string]apiextensionsv1.JSON, name string, value interface{}) (bool, error) {
+	variable, found, err := topologymutation.GetVariable(variables, name)
+	if err != nil || !found {
+		return false, err
+	}
+	if err := json.Unmarshal(variable.Raw, value); err != nil {
+		return false, errors.Wrapf(err, "failed to unmarshal the %s variable", name)
+	}
+	return true, nil
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package topologymutation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	infrav1 "github.com/metal3-io/cluster-api-provider-metal3/api/v1beta1"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimehooksv1 "sigs.k8s.io/cluster-api/exp/runtime/hooks/api/v1alpha1"
+)
+
+func newHandlers(t *testing.T) *ExtensionHandlers {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return NewExtensionHandlers(scheme)
+}
+
+func newTemplate(image infrav1.Image) *infrav1.Metal3MachineTemplate {
+	return &infrav1.Metal3MachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       "Metal3MachineTemplate",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "metal3"},
+		Spec: infrav1.Metal3MachineTemplateSpec{
+			Template: infrav1.Metal3MachineTemplateResource{
+				Spec: infrav1.Metal3MachineSpec{Image: image},
+			},
+		},
+	}
+}
+
+func toRaw(t *testing.T, obj interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func newVariable(t *testing.T, name string, value interface{}) runtimehooksv1.Variable {
+	t.Helper()
+	return runtimehooksv1.Variable{Name: name, Value: apiextensionsv1.JSON{Raw: toRaw(t, value)}}
+}
+
+var holderReference = runtimehooksv1.HolderReference{
+	APIVersion: clusterv1.GroupVersion.String(),
+	Kind:       "MachineDeployment",
+	Namespace:  "metal3",
+	Name:       "workers",
+	FieldPath:  "spec.template.spec.infrastructureRef",
+}
+
+func TestGeneratePatches(t *testing.T) {
+	clusterImage := infrav1.Image{
+		URL:      "http://172.22.0.1/images/cluster.qcow2",
+		Checksum: "http://172.22.0.1/images/cluster.qcow2.sha256sum",
+	}
+	deploymentImage := infrav1.Image{
+		URL:      "http://172.22.0.1/images/workers.qcow2",
+		Checksum: "http://172.22.0.1/images/workers.qcow2.sha256sum",
+	}
+	hostSelector := infrav1.HostSelector{MatchLabels: map[string]string{"role": "worker"}}
+
+	tests := []struct {
+		name                 string
+		variables            []runtimehooksv1.Variable
+		templateVariables    []runtimehooksv1.Variable
+		object               interface{}
+		expectedImage        infrav1.Image
+		expectedHostSelector infrav1.HostSelector
+		expectedDataTemplate string
+		expectFailure        bool
+	}{
+		{
+			name:          "no variable",
+			object:        newTemplate(clusterImage),
+			expectedImage: clusterImage,
+		},
+		{
+			name: "cluster variables",
+			variables: []runtimehooksv1.Variable{
+				newVariable(t, ImageVariable, deploymentImage),
+				newVariable(t, HostSelectorVariable, hostSelector),
+				newVariable(t, DataTemplateVariable, "workers-data"),
+			},
+			object:               newTemplate(infrav1.Image{}),
+			expectedImage:        deploymentImage,
+			expectedHostSelector: hostSelector,
+			expectedDataTemplate: "workers-data",
+		},
+		{
+			name:              "machine deployment variable overriding a cluster variable",
+			variables:         []runtimehooksv1.Variable{newVariable(t, ImageVariable, clusterImage)},
+			templateVariables: []runtimehooksv1.Variable{newVariable(t, ImageVariable, deploymentImage)},
+			object:            newTemplate(infrav1.Image{}),
+			expectedImage:     deploymentImage,
+		},
+		{
+			name:          "invalid variable",
+			variables:     []runtimehooksv1.Variable{newVariable(t, HostSelectorVariable, "role=worker")},
+			object:        newTemplate(clusterImage),
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			original := toRaw(t, tt.object)
+			req := &runtimehooksv1.GeneratePatchesRequest{
+				Variables: tt.variables,
+				Items: []runtimehooksv1.GeneratePatchesRequestItem{{
+					UID:             "1",
+					HolderReference: holderReference,
+					Object:          runtime.RawExtension{Raw: original},
+					Variables:       tt.templateVariables,
+				}},
+			}
+			resp := &runtimehooksv1.GeneratePatchesResponse{}
+			newHandlers(t).GeneratePatches(context.TODO(), req, resp)
+
+			if tt.expectFailure {
+				g.Expect(resp.Status).To(Equal(runtimehooksv1.ResponseStatusFailure))
+				return
+			}
+			g.Expect(resp.Status).To(Equal(runtimehooksv1.ResponseStatusSuccess))
+			g.Expect(resp.Items).To(HaveLen(1))
+			g.Expect(resp.Items[0].PatchType).To(Equal(runtimehooksv1.JSONPatchType))
+
+			patch, err := jsonpatch.DecodePatch(resp.Items[0].Patch)
+			g.Expect(err).NotTo(HaveOccurred())
+			patched, err := patch.Apply(original)
+			g.Expect(err).NotTo(HaveOccurred())
+			m3mt := &infrav1.Metal3MachineTemplate{}
+			g.Expect(json.Unmarshal(patched, m3mt)).To(Succeed())
+			g.Expect(m3mt.Spec.Template.Spec.Image).To(Equal(tt.expectedImage))
+			g.Expect(m3mt.Spec.Template.Spec.HostSelector).To(Equal(tt.expectedHostSelector))
+			if tt.expectedDataTemplate == "" {
+				g.Expect(m3mt.Spec.Template.Spec.DataTemplate).To(BeNil())
+			} else {
+				g.Expect(m3mt.Spec.Template.Spec.DataTemplate.Name).To(Equal(tt.expectedDataTemplate))
+			}
+		})
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	valid := newTemplate(infrav1.Image{
+		URL:      "http://172.22.0.1/images/workers.qcow2",
+		Checksum: "http://172.22.0.1/images/workers.qcow2.sha256sum",
+	})
+	invalid := newTemplate(infrav1.Image{URL: "http://172.22.0.1/images/workers.qcow2"})
+
+	tests := []struct {
+		name          string
+		objects       []interface{}
+		expectFailure bool
+	}{
+		{
+			name:    "valid template",
+			objects: []interface{}{valid},
+		},
+		{
+			name:          "template without image checksum",
+			objects:       []interface{}{valid, invalid},
+			expectFailure: true,
+		},
+		{
+			name: "template of another provider",
+			objects: []interface{}{map[string]interface{}{
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1beta1",
+				"kind":       "DockerMachineTemplate",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			req := &runtimehooksv1.ValidateTopologyRequest{}
+			for _, obj := range tt.objects {
+				req.Items = append(req.Items, &runtimehooksv1.ValidateTopologyRequestItem{
+					HolderReference: holderReference,
+					Object:          runtime.RawExtension{Raw: toRaw(t, obj)},
+				})
+			}
+			resp := &runtimehooksv1.ValidateTopologyResponse{}
+			newHandlers(t).ValidateTopology(context.TODO(), req, resp)
+
+			if tt.expectFailure {
+				g.Expect(resp.Status).To(Equal(runtimehooksv1.ResponseStatusFailure))
+				g.Expect(resp.Message).To(ContainSubstring("MachineDeployment workers"))
+				return
+			}
+			g.Expect(resp.Status).To(Equal(runtimehooksv1.ResponseStatusSuccess))
+		})
+	}
+}
@@ -19,11 +19,14 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	bmov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -32,14 +35,18 @@ import (
 	"github.com/metal3-io/cluster-api-provider-metal3/baremetal"
 	infraremote "github.com/metal3-io/cluster-api-provider-metal3/baremetal/remote"
 	"github.com/metal3-io/cluster-api-provider-metal3/controllers"
+	"github.com/metal3-io/cluster-api-provider-metal3/extension/topologymutation"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/logs"
@@ -47,9 +54,20 @@ import (
 	_ "k8s.io/component-base/logs/json/register"
 	"k8s.io/klog/v2/klogr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	caipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	runtimecatalog "sigs.k8s.io/cluster-api/exp/runtime/catalog"
+	runtimehooksv1 "sigs.k8s.io/cluster-api/exp/runtime/hooks/api/v1alpha1"
+	runtimeserver "sigs.k8s.io/cluster-api/exp/runtime/server"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -70,6 +88,7 @@ var (
 	setupLog                         = ctrl.Log.WithName("setup")
 	waitForMetal3Controller          = false
 	metricsBindAddr                  string
+	profilerAddress                  string
 	enableLeaderElection             bool
 	leaderElectionLeaseDuration      time.Duration
 	leaderElectionRenewDeadline      time.Duration
@@ -82,19 +101,80 @@ var (
 	metal3LabelSyncConcurrency       int
 	metal3MachineTemplateConcurrency int
 	metal3RemediationConcurrency     int
+	metal3MachinePoolConcurrency     int
 	restConfigQPS                    float32
 	restConfigBurst                  int
 	webhookPort                      int
 	webhookCertDir                   string
+	runtimeExtensionPort             int
 	healthAddr                       string
 	watchNamespace                   string
 	watchFilterValue                 string
+	watchFilterShards                controllers.ShardOptions
+	replicaShards                    controllers.ShardOptions
+	replicaShardKey                  string
+	shards                           controllers.ShardOptions
 	logOptions                       = logs.NewOptions()
 	enableBMHNameBasedPreallocation  bool
+	enableClusterCacheTracker        bool
+	legacyLabelKeys                  map[string]string
+	bmhNamespaces                    []string
+	providerIDFormat                 string
+	enableHostClusters               bool
+	enableMachinePool                bool
+	hostClusterKubeconfigSecret      string
+	bmhLabelSelector                 string
+	hostLabelSelector                labels.Selector
+	nodeReuseLabelTTL                time.Duration
+	dataClaimOrphanGracePeriod       time.Duration
+	maxConcurrentProvisioning        int
+	controllersFlag                  []string
+	webhooksFlag                     []string
+	enabledControllers               map[string]bool
+	enabledWebhooks                  map[string]bool
 	tlsOptions                       = TLSOptions{}
 	tlsSupportedVersions             = []string{TLSVersion12, TLSVersion13}
 )
 
+// The names of the reconcilers accepted by --controllers.
+const (
+	controllerMetal3Machine         = "metal3machine"
+	controllerMetal3Cluster         = "metal3cluster"
+	controllerMetal3DataTemplate    = "metal3datatemplate"
+	controllerMetal3Data            = "metal3data"
+	controllerMetal3LabelSync       = "metal3labelsync"
+	controllerMetal3MachineTemplate = "metal3machinetemplate"
+	controllerMetal3Remediation     = "metal3remediation"
+	controllerMetal3MachinePool     = "metal3machinepool"
+)
+
+// controllerNames are the reconcilers that can be selected with
+// --controllers.
+var controllerNames = []string{
+	controllerMetal3Machine,
+	controllerMetal3Cluster,
+	controllerMetal3DataTemplate,
+	controllerMetal3Data,
+	controllerMetal3LabelSync,
+	controllerMetal3MachineTemplate,
+	controllerMetal3Remediation,
+	controllerMetal3MachinePool,
+}
+
+// webhookNames are the webhooks that can be selected with --webhooks, named
+// after their kind.
+var webhookNames = []string{
+	"metal3cluster",
+	"metal3machine",
+	"metal3machinetemplate",
+	"metal3datatemplate",
+	"metal3data",
+	"metal3dataclaim",
+	"metal3remediation",
+	"metal3remediationtemplate",
+	"metal3machinepool",
+}
+
 func init() {
 	_ = scheme.AddToScheme(myscheme)
 	_ = ipamv1.AddToScheme(myscheme)
@@ -102,6 +182,8 @@ func init() {
 	_ = infrav1.AddToScheme(myscheme)
 	_ = infrav1alpha5.AddToScheme(myscheme)
 	_ = clusterv1.AddToScheme(myscheme)
+	_ = expv1.AddToScheme(myscheme)
+	_ = controlplanev1.AddToScheme(myscheme)
 	_ = bmov1alpha1.AddToScheme(myscheme)
 	// +kubebuilder:scaffold:scheme
 }
@@ -117,6 +199,78 @@ func main() {
 		os.Exit(1)
 	}
 
+	if watchFilterShards.Enabled() && (watchFilterValue == "" || watchFilterShards.Index < 0 || watchFilterShards.Index >= watchFilterShards.Count) {
+		setupLog.Error(fmt.Errorf("invalid watch-filter shard index %d for %d shards", watchFilterShards.Index, watchFilterShards.Count),
+			"--watch-filter is required and --watch-filter-shard-index must be lower than --watch-filter-shard-count")
+		os.Exit(1)
+	}
+
+	replicaShards.Key = controllers.ShardKey(replicaShardKey)
+	if replicaShards.Enabled() {
+		if watchFilterValue != "" || watchFilterShards.Enabled() {
+			setupLog.Error(errors.New("--shard-count can not be combined with --watch-filter"), "invalid flags")
+			os.Exit(1)
+		}
+		if replicaShards.Index < 0 || replicaShards.Index >= replicaShards.Count {
+			setupLog.Error(fmt.Errorf("invalid shard index %d for %d shards", replicaShards.Index, replicaShards.Count),
+				"--shard-index must be lower than --shard-count")
+			os.Exit(1)
+		}
+		if replicaShards.Key != controllers.ShardByNamespace && replicaShards.Key != controllers.ShardByCluster {
+			setupLog.Error(fmt.Errorf("invalid shard key %q", replicaShardKey), "invalid --shard-key")
+			os.Exit(1)
+		}
+		shards = replicaShards
+	} else {
+		// The watch-filter shards keep the objects of a cluster together.
+		watchFilterShards.Key = controllers.ShardByCluster
+		shards = watchFilterShards
+	}
+
+	if hostClusterKubeconfigSecret != "" && !enableHostClusters {
+		setupLog.Error(errors.New("--host-cluster-kubeconfig-secret requires --enable-host-clusters"), "invalid flags")
+		os.Exit(1)
+	}
+
+	if bmhLabelSelector != "" {
+		var err error
+		hostLabelSelector, err = labels.Parse(bmhLabelSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid --bmh-label-selector")
+			os.Exit(1)
+		}
+	}
+
+	if _, err := baremetal.ParseProviderIDFormat(providerIDFormat); err != nil {
+		setupLog.Error(err, "invalid --provider-id-format")
+		os.Exit(1)
+	}
+
+	var err error
+	enabledControllers, err = parseComponents(controllersFlag, controllerNames)
+	if err != nil {
+		setupLog.Error(err, "invalid --controllers")
+		os.Exit(1)
+	}
+	// The webhooks are disabled with --webhook-port=0, e.g. when they run in
+	// another deployment than the controllers.
+	enabledWebhooks = map[string]bool{}
+	if webhookPort != 0 {
+		enabledWebhooks, err = parseComponents(webhooksFlag, webhookNames)
+		if err != nil {
+			setupLog.Error(err, "invalid --webhooks")
+			os.Exit(1)
+		}
+	}
+	if len(enabledControllers) == 0 && len(enabledWebhooks) == 0 && runtimeExtensionPort == 0 {
+		setupLog.Error(errors.New("no controller, webhook nor runtime extension enabled"), "invalid flags")
+		os.Exit(1)
+	}
+	if runtimeExtensionPort != 0 && runtimeExtensionPort == webhookPort {
+		setupLog.Error(errors.New("--runtime-extension-port must differ from --webhook-port"), "invalid flags")
+		os.Exit(1)
+	}
+
 	ctrl.SetLogger(klogr.New())
 	restConfig := ctrl.GetConfigOrDie()
 	restConfig.QPS = restConfigQPS
@@ -128,14 +282,18 @@ func main() {
 		setupLog.Error(err, "unable to add TLS settings to the webhook server")
 		os.Exit(1)
 	}
+	// Only the controllers need a leader, the webhooks are served by all the
+	// replicas.
+	leaderElection := enableLeaderElection && len(enabledControllers) != 0
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                     myscheme,
 		MetricsBindAddress:         metricsBindAddr,
+		PprofBindAddress:           profilerAddress,
 		LeaseDuration:              &leaderElectionLeaseDuration,
 		RenewDeadline:              &leaderElectionRenewDeadline,
 		RetryPeriod:                &leaderElectionRetryPeriod,
-		LeaderElection:             enableLeaderElection,
-		LeaderElectionID:           "controller-leader-election-capm3",
+		LeaderElection:             leaderElection,
+		LeaderElectionID:           replicaShards.LeaderElectionID("controller-leader-election-capm3"),
 		LeaderElectionResourceLock: resourcelock.LeasesResourceLock,
 		SyncPeriod:                 &syncPeriod,
 		Port:                       webhookPort,
@@ -143,6 +301,7 @@ func main() {
 		HealthProbeBindAddress:     healthAddr,
 		Namespace:                  watchNamespace,
 		TLSOpts:                    tlsOptionOverrides,
+		Cache:                      ctrlcache.Options{ByObject: hostCacheByObject()},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -163,10 +322,32 @@ func main() {
 	if enableBMHNameBasedPreallocation {
 		baremetal.EnableBMHNameBasedPreallocation = enableBMHNameBasedPreallocation
 	}
+	if err := baremetal.SetLegacyLabelKeys(legacyLabelKeys); err != nil {
+		setupLog.Error(err, "invalid flags")
+		os.Exit(1)
+	}
+	if watchNamespace != "" && len(bmhNamespaces) != 0 {
+		setupLog.Error(errors.New("--bmh-namespaces requires watching all namespaces"), "invalid flags")
+		os.Exit(1)
+	}
+	baremetal.BMHNamespaces = bmhNamespaces
+	baremetal.NodeReuseLabelTTL = nodeReuseLabelTTL
+	baremetal.DataClaimOrphanGracePeriod = dataClaimOrphanGracePeriod
+	baremetal.MaxConcurrentProvisioning = maxConcurrentProvisioning
 
 	setupChecks(mgr)
-	setupReconcilers(ctx, mgr)
-	setupWebhooks(mgr)
+	if len(enabledControllers) != 0 {
+		if len(legacyLabelKeys) != 0 {
+			setupLegacyLabelsAudit(mgr)
+		}
+		setupReconcilers(ctx, mgr)
+	}
+	if len(enabledWebhooks) != 0 {
+		setupWebhooks(mgr)
+	}
+	if runtimeExtensionPort != 0 {
+		setupRuntimeExtension(mgr)
+	}
 
 	// +kubebuilder:scaffold:builder
 	setupLog.Info("starting manager")
@@ -187,6 +368,13 @@ func initFlags(fs *pflag.FlagSet) {
 		"The address the metric endpoint binds to.",
 	)
 
+	fs.StringVar(
+		&profilerAddress,
+		"profiler-address",
+		"",
+		"Bind address to expose the pprof profiler (e.g. localhost:6060). The profiler is disabled if empty.",
+	)
+
 	fs.BoolVar(
 		&enableLeaderElection,
 		"leader-elect",
@@ -201,6 +389,84 @@ func initFlags(fs *pflag.FlagSet) {
 		"If set to true, it enables PreAllocation field to use Metal3IPClaim name structured with BaremetalHost and M3IPPool names",
 	)
 
+	fs.BoolVar(
+		&enableClusterCacheTracker,
+		"enable-cluster-cache-tracker",
+		false,
+		"If set to true, the Nodes of the workload clusters are watched through a shared cluster cache, instead of being polled by the Metal3Machine, Metal3LabelSync and Metal3Remediation controllers.",
+	)
+
+	fs.StringToStringVar(
+		&legacyLabelKeys,
+		"legacy-label-keys",
+		map[string]string{},
+		"Deprecated: the node reuse and cluster label keys used by older releases, mapped to their current key (infrastructure.cluster.x-k8s.io/node-reuse or cluster.x-k8s.io/cluster-name), e.g. example.com/cluster=cluster.x-k8s.io/cluster-name. The legacy keys are read on BareMetalHosts and BMC secrets, and migrated to the current keys when CAPM3 updates them. The support of the legacy keys will be removed in a later release.",
+	)
+
+	fs.StringSliceVar(
+		&bmhNamespaces,
+		"bmh-namespaces",
+		[]string{},
+		"Comma-separated list of namespaces, other than their own, in which the Metal3Machines can consume BareMetalHosts. The secrets of the Metal3Machines are copied in the namespace of their BareMetalHost.",
+	)
+
+	fs.DurationVar(
+		&nodeReuseLabelTTL,
+		"node-reuse-label-ttl",
+		0,
+		"Time after which the node reuse label of an available BareMetalHost is removed, unless the KubeadmControlPlane, MachineDeployment or node reuse group it names is being scaled or rolled out (e.g. 24h). The nodeReuseGracePeriod of the Metal3MachineTemplate that released the host takes precedence. Disabled when 0.",
+	)
+
+	fs.DurationVar(
+		&dataClaimOrphanGracePeriod,
+		"dataclaim-orphan-grace-period",
+		baremetal.DataClaimOrphanGracePeriod,
+		"Time after which a Metal3DataClaim whose Metal3Machine is not found is deleted, releasing its index and Metal3Data. It protects the claims from a lagging cache, e.g. during a pivot.",
+	)
+
+	fs.IntVar(
+		&maxConcurrentProvisioning,
+		"max-concurrent-provisioning",
+		0,
+		"Maximum number of BareMetalHosts, among all the watched BareMetalHosts, being provisioned at the same time. The other Metal3Machines wait for a provisioning slot. Deprovisioning is exempt and takes no slot. Unlimited when 0.",
+	)
+
+	fs.StringVar(
+		&providerIDFormat,
+		"provider-id-format",
+		string(baremetal.ProviderIDFormatNamespacedName),
+		fmt.Sprintf("Format of the providerID set on the Nodes of new Metal3Machines, either %q (metal3://<bmh-uid>) or %q (metal3://<namespace>/<bmh-name>/<metal3machine-name>). The providerID already set on existing Metal3Machines is kept.",
+			baremetal.ProviderIDFormatUID, baremetal.ProviderIDFormatNamespacedName),
+	)
+
+	fs.BoolVar(
+		&enableHostClusters,
+		"enable-host-clusters",
+		false,
+		"Alpha: if set to true, the BareMetalHosts of the Metal3Machines are read from the cluster given by --host-cluster-kubeconfig-secret or by the hostClusterKubeconfigSecret of their Metal3Cluster, in which the baremetal-operator runs, and their secrets are copied there. The BareMetalHosts of each host cluster are watched.",
+	)
+
+	fs.BoolVar(
+		&enableMachinePool,
+		"enable-machine-pool",
+		false,
+		"Experimental: if set to true, the Metal3MachinePools are reconciled, each replica of their MachinePool being a BareMetalHost. The Metal3MachinePool CRD must be installed.",
+	)
+
+	fs.StringVar(
+		&hostClusterKubeconfigSecret,
+		"host-cluster-kubeconfig-secret",
+		"",
+		"Namespace and name, as <namespace>/<name>, of the secret holding under its value key the kubeconfig of the cluster of the BareMetalHosts of the Metal3Clusters not setting hostClusterKubeconfigSecret. The BareMetalHosts of that cluster are watched. Requires --enable-host-clusters.",
+	)
+
+	fs.StringVar(
+		&bmhLabelSelector,
+		"bmh-label-selector",
+		"",
+		"Label selector, e.g. owner=capm3,!reserved, restricting the BareMetalHosts that CAPM3 may consume, whatever the hostSelector of the Metal3Machines. The other BareMetalHosts are neither cached nor counted as available hosts.",
+	)
+
 	fs.DurationVar(
 		&leaderElectionLeaseDuration,
 		"leader-elect-lease-duration",
@@ -236,6 +502,41 @@ func initFlags(fs *pflag.FlagSet) {
 		fmt.Sprintf("Label value that the controller watches to reconcile cluster-api objects. Label key is always %s. If unspecified, the controller watches for all cluster-api objects.", clusterv1.WatchLabel),
 	)
 
+	fs.IntVar(
+		&watchFilterShards.Count,
+		"watch-filter-shard-count",
+		0,
+		"Number of controller instances sharing the objects, each with its own --watch-filter value. When greater than 1, objects without the watch-filter label are adopted by exactly one instance, chosen by hashing their namespace and cluster name.",
+	)
+
+	fs.IntVar(
+		&watchFilterShards.Index,
+		"watch-filter-shard-index",
+		0,
+		"Index of this controller instance, from 0 to --watch-filter-shard-count minus 1.",
+	)
+
+	fs.IntVar(
+		&replicaShards.Count,
+		"shard-count",
+		0,
+		"Number of active controller replicas sharing the objects. When greater than 1, each object is reconciled by exactly one replica, chosen by hashing its namespace or cluster name, and one replica per shard is elected leader. Can not be combined with --watch-filter.",
+	)
+
+	fs.IntVar(
+		&replicaShards.Index,
+		"shard-index",
+		0,
+		"Index of the shard of this controller replica, from 0 to --shard-count minus 1.",
+	)
+
+	fs.StringVar(
+		&replicaShardKey,
+		"shard-key",
+		string(controllers.ShardByNamespace),
+		fmt.Sprintf("What the objects are hashed on to be assigned to a shard, %s or %s.", controllers.ShardByNamespace, controllers.ShardByCluster),
+	)
+
 	fs.DurationVar(
 		&syncPeriod,
 		"sync-period",
@@ -254,7 +555,30 @@ func initFlags(fs *pflag.FlagSet) {
 		&webhookCertDir,
 		"webhook-cert-dir",
 		"/tmp/k8s-webhook-server/serving-certs/",
-		"Webhook cert dir, only used when webhook-port is specified.",
+		"Webhook cert dir, only used when webhook-port or runtime-extension-port is not 0.",
+	)
+
+	fs.IntVar(
+		&runtimeExtensionPort,
+		"runtime-extension-port",
+		0,
+		"Port of the Runtime SDK extension serving the GeneratePatches and ValidateTopology hooks for the Metal3 ClusterClass variables, 0 to disable it.",
+	)
+
+	fs.StringSliceVar(
+		&controllersFlag,
+		"controllers",
+		[]string{allComponents},
+		fmt.Sprintf("Comma-separated list of the controllers to run, %q or %q. Leader election is skipped when no controller runs. Valid controllers: %s.",
+			allComponents, noComponents, strings.Join(controllerNames, ", ")),
+	)
+
+	fs.StringSliceVar(
+		&webhooksFlag,
+		"webhooks",
+		[]string{allComponents},
+		fmt.Sprintf("Comma-separated list of the webhooks to serve, %q or %q. No webhook is served when webhook-port is 0. Valid webhooks: %s.",
+			allComponents, noComponents, strings.Join(webhookNames, ", ")),
 	)
 
 	fs.StringVar(
@@ -265,7 +589,7 @@ func initFlags(fs *pflag.FlagSet) {
 	)
 
 	fs.IntVar(&metal3MachineConcurrency, "metal3machine-concurrency", 1,
-		"Number of metal3machines to process simultaneously. WARNING! Currently not safe to set > 1.")
+		"Number of metal3machines to process simultaneously. The hosts are claimed with an optimistic lock, and the provisioning slots and the spreading across a topology account for the concurrent reconciles.")
 
 	fs.IntVar(&metal3ClusterConcurrency, "metal3cluster-concurrency", 10,
 		"Number of metal3clusters to process simultaneously")
@@ -273,7 +597,7 @@ func initFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&metal3DataTemplateConcurrency, "metal3datatemplate-concurrency", 10,
 		"Number of metal3datatemplates to process simultaneously")
 
-	fs.IntVar(&metal3DataConcurrency, "metal3data-concurrency", 10,
+	fs.IntVar(&metal3DataConcurrency, "metal3data-concurrency", 20,
 		"Number of metal3data to process simultaneously")
 
 	fs.IntVar(&metal3LabelSyncConcurrency, "metal3labelsync-concurrency", 10,
@@ -285,6 +609,9 @@ func initFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&metal3RemediationConcurrency, "metal3remediation-concurrency", 10,
 		"Number of metal3remediations to process simultaneously")
 
+	fs.IntVar(&metal3MachinePoolConcurrency, "metal3machinepool-concurrency", 10,
+		"Number of metal3machinepools to process simultaneously")
+
 	fs.Float32Var(&restConfigQPS, "kube-api-qps", 20,
 		"Maximum queries per second from the controller client to the Kubernetes API server. Default 20")
 
@@ -309,17 +636,22 @@ func initFlags(fs *pflag.FlagSet) {
 			"Insecure values: "+strings.Join(tlsCipherInsecureValues, ", ")+".")
 }
 
+// metal3GV is the API group version of the BareMetalHosts.
+var metal3GV = schema.GroupVersion{
+	Group:   "metal3.io",
+	Version: "v1alpha1",
+}
+
+// apiReadyCheckInterval is how long the result of the discovery of the
+// metal3.io API group is reused by the readiness check.
+const apiReadyCheckInterval = 10 * time.Second
+
 func waitForAPIs(cfg *rest.Config) error {
 	c, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
 		return err
 	}
 
-	metal3GV := schema.GroupVersion{
-		Group:   "metal3.io",
-		Version: "v1alpha1",
-	}
-
 	for {
 		err = discovery.ServerSupportsVersion(c, metal3GV)
 		if err != nil {
@@ -334,129 +666,442 @@ func waitForAPIs(cfg *rest.Config) error {
 	return nil
 }
 
+// apiGroupChecker is a readiness check failing until an API group version is
+// served. The result of the discovery is reused for the given interval.
+type apiGroupChecker struct {
+	client   discovery.DiscoveryInterface
+	gv       schema.GroupVersion
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastErr   error
+}
+
+func newAPIGroupChecker(client discovery.DiscoveryInterface, gv schema.GroupVersion, interval time.Duration) *apiGroupChecker {
+	return &apiGroupChecker{
+		client:   client,
+		gv:       gv,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Check implements healthz.Checker.
+func (c *apiGroupChecker) Check(_ *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && now.Sub(c.lastCheck) < c.interval {
+		return c.lastErr
+	}
+	c.lastCheck = now
+	c.lastErr = nil
+	if err := discovery.ServerSupportsVersion(c.client, c.gv); err != nil {
+		c.lastErr = fmt.Errorf("API group %v is not available, are the baremetal-operator CRDs installed: %w", c.gv, err)
+	}
+	return c.lastErr
+}
+
 func setupChecks(mgr ctrl.Manager) {
-	if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+	// Getting the webhook server starts it, it is only checked when serving
+	// webhooks.
+	if len(enabledWebhooks) != 0 {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to create ready check")
+			os.Exit(1)
+		}
+		if err := mgr.AddHealthzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to create health check")
+			os.Exit(1)
+		}
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	apiChecker := newAPIGroupChecker(discoveryClient, metal3GV, apiReadyCheckInterval)
+	if err := mgr.AddReadyzCheck("metal3-api", apiChecker.Check); err != nil {
 		setupLog.Error(err, "unable to create ready check")
 		os.Exit(1)
 	}
+}
 
-	if err := mgr.AddHealthzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
-		setupLog.Error(err, "unable to create health check")
+// allReplicasRunnable runs a runnable on all the replicas, not only on the
+// leader.
+type allReplicasRunnable struct {
+	manager.Runnable
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (allReplicasRunnable) NeedLeaderElection() bool {
+	return false
+}
+
+// setupRuntimeExtension serves the topology mutation hooks with the
+// certificate of the webhooks. Like the webhooks, they are served by all the
+// replicas.
+func setupRuntimeExtension(mgr ctrl.Manager) {
+	catalog := runtimecatalog.New()
+	if err := runtimehooksv1.AddToCatalog(catalog); err != nil {
+		setupLog.Error(err, "unable to add the runtime hooks to the catalog")
+		os.Exit(1)
+	}
+	server, err := runtimeserver.New(runtimeserver.Options{
+		Catalog: catalog,
+		Port:    runtimeExtensionPort,
+		CertDir: webhookCertDir,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create the runtime extension server")
+		os.Exit(1)
+	}
+	handlers := topologymutation.NewExtensionHandlers(myscheme)
+	for _, handler := range []runtimeserver.ExtensionHandler{
+		{
+			Hook:        runtimehooksv1.GeneratePatches,
+			Name:        "generate-patches",
+			HandlerFunc: handlers.GeneratePatches,
+		},
+		{
+			Hook:        runtimehooksv1.ValidateTopology,
+			Name:        "validate-topology",
+			HandlerFunc: handlers.ValidateTopology,
+		},
+	} {
+		if err := server.AddExtensionHandler(handler); err != nil {
+			setupLog.Error(err, "unable to add the runtime extension handler", "handler", handler.Name)
+			os.Exit(1)
+		}
+	}
+	if err := mgr.Add(allReplicasRunnable{server}); err != nil {
+		setupLog.Error(err, "unable to add the runtime extension server")
 		os.Exit(1)
 	}
 }
 
-func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
-	if err := (&controllers.Metal3MachineReconciler{
-		Client:           mgr.GetClient(),
-		ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("Metal3Machine"),
-		CapiClientGetter: infraremote.NewClusterClient,
-		WatchFilterValue: watchFilterValue,
-	}).SetupWithManager(ctx, mgr, concurrency(metal3MachineConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3MachineReconciler")
+// setupLegacyLabelsAudit reports, once the cache is started, the
+// BareMetalHosts that still carry the label keys of older releases.
+func setupLegacyLabelsAudit(mgr ctrl.Manager) {
+	err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		if !mgr.GetCache().WaitForCacheSync(ctx) {
+			return nil
+		}
+		log := ctrl.Log.WithName("legacy-labels-audit")
+		count, err := baremetal.AuditLegacyLabels(ctx, mgr.GetClient(), log)
+		if err != nil {
+			log.Error(err, "unable to audit the BareMetalHost labels")
+			return nil
+		}
+		if count != 0 {
+			log.Info("BareMetalHosts use legacy label keys, they are migrated when next updated by CAPM3", "count", count)
+		}
+		return nil
+	}))
+	if err != nil {
+		setupLog.Error(err, "unable to create the legacy labels audit")
 		os.Exit(1)
 	}
+}
 
-	if err := (&controllers.Metal3ClusterReconciler{
-		Client:           mgr.GetClient(),
-		ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("Metal3Cluster"),
-		WatchFilterValue: watchFilterValue,
-	}).SetupWithManager(ctx, mgr, concurrency(metal3ClusterConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3ClusterReconciler")
-		os.Exit(1)
+func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
+	var tracker *remote.ClusterCacheTracker
+	capiClientGetter := baremetal.ClientGetter(infraremote.NewClusterClientCache().NewClusterClient)
+	if enableClusterCacheTracker {
+		var err error
+		tracker, err = setupClusterCacheTracker(ctx, mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create cluster cache tracker")
+			os.Exit(1)
+		}
+		capiClientGetter = infraremote.NewClusterClientFromTracker(tracker)
 	}
 
-	if err := (&controllers.Metal3DataTemplateReconciler{
-		Client:           mgr.GetClient(),
-		ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("Metal3DataTemplate"),
-		WatchFilterValue: watchFilterValue,
-	}).SetupWithManager(ctx, mgr, concurrency(metal3DataTemplateConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3DataTemplateReconciler")
-		os.Exit(1)
+	// The provisioning estimates are persisted in the namespace of the
+	// controller.
+	provisioningEstimator := baremetal.NewProvisioningEstimator(mgr.GetClient(), mgr.GetAPIReader(), types.NamespacedName{
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		Name:      baremetal.ProvisioningEstimatesConfigMapName,
+	})
+	// The BareMetalHosts are read from the cache of the Metal3Machine
+	// controller.
+	metrics.Registry.MustRegister(baremetal.NewHostProvisionCountCollector(mgr.GetClient(),
+		ctrl.Log.WithName("metrics"),
+	))
+	metrics.Registry.MustRegister(baremetal.NodeReuseLabelExpirations)
+	metrics.Registry.MustRegister(baremetal.NewOrphanedDataClaimCollector(mgr.GetClient(),
+		ctrl.Log.WithName("metrics"),
+	))
+	metrics.Registry.MustRegister(baremetal.OrphanedDataClaimDeletions)
+	metrics.Registry.MustRegister(baremetal.ProvisioningMetrics)
+	machineManagerFactory := baremetal.NewManagerFactory(mgr.GetClient()).
+		WithProviderIDFormat(baremetal.ProviderIDFormat(providerIDFormat)).
+		WithEventRecorder(mgr.GetEventRecorderFor("metal3machine-controller")).
+		WithHostLabelSelector(hostLabelSelector).
+		WithAPIReader(mgr.GetAPIReader())
+	var hostCluster cluster.Cluster
+	var hostClusters *infraremote.HostClusterClients
+	if enableHostClusters {
+		var err error
+		hostCluster, err = setupHostCluster(ctx, mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create the host cluster")
+			os.Exit(1)
+		}
+		var defaultHostClient client.Client
+		if hostCluster != nil {
+			defaultHostClient = hostCluster.GetClient()
+		}
+		hostClusters = infraremote.NewHostClusterClients(ctx, defaultHostClient, myscheme, hostCacheByObject())
+		machineManagerFactory = machineManagerFactory.WithHostClientGetter(hostClusters.HostClient)
+	}
+	if enabledControllers[controllerMetal3Machine] {
+		if err := (&controllers.Metal3MachineReconciler{
+			Client:                mgr.GetClient(),
+			ManagerFactory:        machineManagerFactory,
+			Log:                   ctrl.Log.WithName("controllers").WithName("Metal3Machine"),
+			CapiClientGetter:      capiClientGetter,
+			WatchFilterValue:      watchFilterValue,
+			Shard:                 shards,
+			Tracker:               tracker,
+			ProvisioningEstimator: provisioningEstimator,
+			HostCluster:           hostCluster,
+			HostClusters:          hostClusters,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3MachineConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3MachineReconciler")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&controllers.Metal3DataReconciler{
-		Client:           mgr.GetClient(),
-		ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("Metal3Data"),
-		WatchFilterValue: watchFilterValue,
-	}).SetupWithManager(ctx, mgr, concurrency(metal3DataConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3DataReconciler")
-		os.Exit(1)
+	if enabledControllers[controllerMetal3Cluster] {
+		if err := (&controllers.Metal3ClusterReconciler{
+			Client: mgr.GetClient(),
+			ManagerFactory: baremetal.NewManagerFactory(mgr.GetClient()).
+				WithEventRecorder(mgr.GetEventRecorderFor("metal3cluster-controller")),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3Cluster"),
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3ClusterConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3ClusterReconciler")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&controllers.Metal3LabelSyncReconciler{
-		Client:           mgr.GetClient(),
-		ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("Metal3LabelSync"),
-		CapiClientGetter: infraremote.NewClusterClient,
-	}).SetupWithManager(ctx, mgr, concurrency(metal3LabelSyncConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3LabelSyncReconciler")
-		os.Exit(1)
+	if enabledControllers[controllerMetal3DataTemplate] {
+		if err := (&controllers.Metal3DataTemplateReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3DataTemplate"),
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3DataTemplateConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3DataTemplateReconciler")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&controllers.Metal3MachineTemplateReconciler{
-		Client:         mgr.GetClient(),
-		ManagerFactory: baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:            ctrl.Log.WithName("controllers").WithName("Metal3MachineTemplate"),
-	}).SetupWithManager(ctx, mgr, concurrency(metal3MachineTemplateConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3MachineTemplateReconciler")
-		os.Exit(1)
+	if enabledControllers[controllerMetal3Data] {
+		if err := (&controllers.Metal3DataReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3Data"),
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+			Recorder:         mgr.GetEventRecorderFor("metal3data-controller"),
+		}).SetupWithManager(ctx, mgr, concurrency(metal3DataConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3DataReconciler")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&controllers.Metal3RemediationReconciler{
-		Client:         mgr.GetClient(),
-		ManagerFactory: baremetal.NewManagerFactory(mgr.GetClient()),
-		Log:            ctrl.Log.WithName("controllers").WithName("Metal3Remediation"),
-	}).SetupWithManager(ctx, mgr, concurrency(metal3RemediationConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Metal3Remediation")
-		os.Exit(1)
+	if enabledControllers[controllerMetal3LabelSync] {
+		if err := (&controllers.Metal3LabelSyncReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3LabelSync"),
+			CapiClientGetter: capiClientGetter,
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+			Tracker:          tracker,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3LabelSyncConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3LabelSyncReconciler")
+			os.Exit(1)
+		}
 	}
-}
 
-func setupWebhooks(mgr ctrl.Manager) {
-	if err := (&infrav1.Metal3Cluster{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3Cluster")
-		os.Exit(1)
+	templateManagerFactory := baremetal.NewManagerFactory(mgr.GetClient()).
+		WithEventRecorder(mgr.GetEventRecorderFor("metal3machinetemplate-controller"))
+	if enabledControllers[controllerMetal3MachineTemplate] {
+		if err := (&controllers.Metal3MachineTemplateReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   templateManagerFactory,
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3MachineTemplate"),
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3MachineTemplateConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3MachineTemplateReconciler")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&infrav1.Metal3Machine{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3Machine")
-		os.Exit(1)
+	if enabledControllers[controllerMetal3Remediation] {
+		if err := (&controllers.Metal3RemediationReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()).WithClientGetter(capiClientGetter),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3Remediation"),
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+			Tracker:          tracker,
+			Recorder:         mgr.GetEventRecorderFor("metal3remediation-controller"),
+		}).SetupWithManager(ctx, mgr, concurrency(metal3RemediationConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3Remediation")
+			os.Exit(1)
+		}
 	}
 
-	if err := (&infrav1.Metal3MachineTemplate{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3MachineTemplate")
-		os.Exit(1)
+	if enableMachinePool && enabledControllers[controllerMetal3MachinePool] {
+		if err := (&controllers.Metal3MachinePoolReconciler{
+			Client:           mgr.GetClient(),
+			ManagerFactory:   baremetal.NewManagerFactory(mgr.GetClient()),
+			Log:              ctrl.Log.WithName("controllers").WithName("Metal3MachinePool"),
+			CapiClientGetter: capiClientGetter,
+			WatchFilterValue: watchFilterValue,
+			Shard:            shards,
+		}).SetupWithManager(ctx, mgr, concurrency(metal3MachinePoolConcurrency)); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Metal3MachinePoolReconciler")
+			os.Exit(1)
+		}
 	}
+}
 
-	if err := (&infrav1.Metal3DataTemplate{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3DataTemplate")
-		os.Exit(1)
+// setupHostCluster creates the cluster given by --host-cluster-kubeconfig-secret,
+// whose cache of BareMetalHosts is started with the manager. It returns nil
+// if the flag is unset.
+func setupHostCluster(ctx context.Context, mgr ctrl.Manager) (cluster.Cluster, error) {
+	if hostClusterKubeconfigSecret == "" {
+		return nil, nil
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(hostClusterKubeconfigSecret)
+	if err != nil || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid --host-cluster-kubeconfig-secret %q, expected <namespace>/<name>", hostClusterKubeconfigSecret)
+	}
+	restConfig, err := infraremote.HostClusterRESTConfig(ctx, mgr.GetAPIReader(), types.NamespacedName{Namespace: namespace, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	restConfig.UserAgent = "cluster-api-provider-metal3-manager"
+	hostCluster, err := cluster.New(restConfig, func(o *cluster.Options) {
+		o.Scheme = myscheme
+		o.Cache.ByObject = hostCacheByObject()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(hostCluster); err != nil {
+		return nil, err
 	}
+	return hostCluster, nil
+}
 
-	if err := (&infrav1.Metal3Data{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3Data")
-		os.Exit(1)
+// hostCacheByObject returns the cache options restricting the cached
+// BareMetalHosts to those matching --bmh-label-selector, nil if unset.
+func hostCacheByObject() map[client.Object]ctrlcache.ByObject {
+	if hostLabelSelector == nil {
+		return nil
+	}
+	return map[client.Object]ctrlcache.ByObject{
+		&bmov1alpha1.BareMetalHost{}: {Label: hostLabelSelector},
 	}
+}
 
-	if err := (&infrav1.Metal3DataClaim{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3DataClaim")
-		os.Exit(1)
+// setupClusterCacheTracker creates the tracker of the workload cluster caches
+// shared by the controllers, and the reconciler tearing down the cache of a
+// cluster once it is deleted.
+func setupClusterCacheTracker(ctx context.Context, mgr ctrl.Manager) (*remote.ClusterCacheTracker, error) {
+	trackerLog := ctrl.Log.WithName("remote").WithName("ClusterCacheTracker")
+	tracker, err := remote.NewClusterCacheTracker(mgr, remote.ClusterCacheTrackerOptions{
+		Log:            &trackerLog,
+		ControllerName: "cluster-api-provider-metal3-manager",
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := (&infrav1.Metal3Remediation{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3Remediation")
-		os.Exit(1)
+	// With sharding, the Clusters adopted by this instance do not carry the
+	// watch-filter label, so all Clusters are watched. Tearing down the cache
+	// of a Cluster not tracked by this instance is a no-op.
+	clusterCacheWatchFilterValue := watchFilterValue
+	if watchFilterShards.Enabled() {
+		clusterCacheWatchFilterValue = ""
+	}
+	if err := (&remote.ClusterCacheReconciler{
+		Client:           mgr.GetClient(),
+		Tracker:          tracker,
+		WatchFilterValue: clusterCacheWatchFilterValue,
+	}).SetupWithManager(ctx, mgr, concurrency(metal3ClusterConcurrency)); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
+func setupWebhooks(mgr ctrl.Manager) {
+	webhooks := []struct {
+		name string
+		kind string
+		obj  interface{ SetupWebhookWithManager(ctrl.Manager) error }
+	}{
+		{"metal3cluster", "Metal3Cluster", &infrav1.Metal3Cluster{}},
+		{"metal3machine", "Metal3Machine", &infrav1.Metal3Machine{}},
+		{"metal3machinetemplate", "Metal3MachineTemplate", &infrav1.Metal3MachineTemplate{}},
+		{"metal3datatemplate", "Metal3DataTemplate", &infrav1.Metal3DataTemplate{}},
+		{"metal3data", "Metal3Data", &infrav1.Metal3Data{}},
+		{"metal3dataclaim", "Metal3DataClaim", &infrav1.Metal3DataClaim{}},
+		{"metal3remediation", "Metal3Remediation", &infrav1.Metal3Remediation{}},
+		{"metal3remediationtemplate", "Metal3RemediationTemplate", &infrav1.Metal3RemediationTemplate{}},
+		{"metal3machinepool", "Metal3MachinePool", &infrav1.Metal3MachinePool{}},
+	}
+	for _, webhook := range webhooks {
+		if !enabledWebhooks[webhook.name] {
+			continue
+		}
+		if err := webhook.obj.SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", webhook.kind)
+			os.Exit(1)
+		}
 	}
+}
 
-	if err := (&infrav1.Metal3RemediationTemplate{}).SetupWebhookWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create webhook", "webhook", "Metal3RemediationTemplate")
-		os.Exit(1)
+// The values of --controllers and --webhooks enabling all or none of the
+// components.
+const (
+	allComponents = "all"
+	noComponents  = "none"
+)
+
+// parseComponents returns the set of the known components enabled by the
+// value of --controllers or --webhooks: "all", "none" or a list of names.
+func parseComponents(values []string, known []string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	for _, value := range values {
+		name := strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case name == allComponents:
+			for _, k := range known {
+				enabled[k] = true
+			}
+		case name == noComponents:
+		case baremetal.Contains(known, name):
+			enabled[name] = true
+		default:
+			return nil, fmt.Errorf("unknown name %q, expected %q, %q or one of %s",
+				value, allComponents, noComponents, strings.Join(known, ", "))
+		}
 	}
+	return enabled, nil
 }
 
 func concurrency(c int) controller.Options {
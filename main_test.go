@@ -19,8 +19,13 @@ package main
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -109,3 +114,132 @@ func TestTLSOptions(t *testing.T) {
 		g.Expect(err).Should(BeNil())
 	})
 }
+
+func TestAPIGroupChecker(t *testing.T) {
+	coreResources := []*metav1.APIResourceList{
+		{GroupVersion: "v1"},
+	}
+	metal3Resources := []*metav1.APIResourceList{
+		{GroupVersion: "v1"},
+		{GroupVersion: metal3GV.String()},
+	}
+	t.Run("should fail while the API group is not served", func(t *testing.T) {
+		g := NewWithT(t)
+		fakeDiscovery := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		fakeDiscovery.Resources = coreResources
+		now := time.Now()
+		checker := newAPIGroupChecker(fakeDiscovery, metal3GV, apiReadyCheckInterval)
+		checker.now = func() time.Time { return now }
+
+		err := checker.Check(nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("API group metal3.io/v1alpha1 is not available"))
+
+		// The result is reused until the interval elapsed.
+		fakeDiscovery.Resources = metal3Resources
+		now = now.Add(apiReadyCheckInterval - time.Second)
+		g.Expect(checker.Check(nil)).To(HaveOccurred())
+		g.Expect(fakeDiscovery.Actions()).To(HaveLen(1))
+
+		now = now.Add(time.Second)
+		g.Expect(checker.Check(nil)).To(Succeed())
+		g.Expect(fakeDiscovery.Actions()).To(HaveLen(2))
+	})
+	t.Run("should pass when the API group is served", func(t *testing.T) {
+		g := NewWithT(t)
+		fakeDiscovery := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		fakeDiscovery.Resources = metal3Resources
+		checker := newAPIGroupChecker(fakeDiscovery, metal3GV, apiReadyCheckInterval)
+
+		g.Expect(checker.Check(nil)).To(Succeed())
+		g.Expect(checker.Check(nil)).To(Succeed())
+		g.Expect(fakeDiscovery.Actions()).To(HaveLen(1))
+	})
+}
+
+func TestParseComponents(t *testing.T) {
+	known := []string{"metal3machine", "metal3cluster", "metal3data"}
+	testCases := []struct {
+		name          string
+		values        []string
+		expected      map[string]bool
+		expectedError string
+	}{
+		{
+			name:     "all",
+			values:   []string{"all"},
+			expected: map[string]bool{"metal3machine": true, "metal3cluster": true, "metal3data": true},
+		},
+		{
+			name:     "none",
+			values:   []string{"none"},
+			expected: map[string]bool{},
+		},
+		{
+			name:     "subset",
+			values:   []string{"metal3machine", " Metal3Data"},
+			expected: map[string]bool{"metal3machine": true, "metal3data": true},
+		},
+		{
+			name:          "unknown name",
+			values:        []string{"metal3machine", "metal3host"},
+			expectedError: `unknown name "metal3host"`,
+		},
+		{
+			name:          "empty name",
+			values:        []string{""},
+			expectedError: `unknown name ""`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			enabled, err := parseComponents(tc.values, known)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedError)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(enabled).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestFlags(t *testing.T) {
+	// initFlags also registers flags of the standard flag package, it can
+	// only be called once.
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	initFlags(fs)
+
+	t.Run("should enable all the controllers and webhooks by default", func(t *testing.T) {
+		g := NewWithT(t)
+		controllers, err := parseComponents(controllersFlag, controllerNames)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(controllers).To(HaveLen(len(controllerNames)))
+		webhooks, err := parseComponents(webhooksFlag, webhookNames)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(webhooks).To(HaveLen(len(webhookNames)))
+	})
+	t.Run("should disable the profiler by default", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(profilerAddress).To(BeEmpty())
+	})
+	t.Run("should expose the profiler on the given address", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(fs.Parse([]string{"--profiler-address=localhost:6060"})).To(Succeed())
+		g.Expect(profilerAddress).To(Equal("localhost:6060"))
+	})
+	t.Run("should select the given controllers", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(fs.Parse([]string{"--controllers=metal3machine,metal3cluster,metal3data", "--webhook-port=0"})).To(Succeed())
+
+		controllers, err := parseComponents(controllersFlag, controllerNames)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(controllers).To(Equal(map[string]bool{
+			controllerMetal3Machine: true,
+			controllerMetal3Cluster: true,
+			controllerMetal3Data:    true,
+		}))
+		g.Expect(webhookPort).To(BeZero())
+	})
+}